@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/boteval"
+	"github.com/alonsoalpizar/fabricalaser/internal/channels"
+	adminchat "github.com/alonsoalpizar/fabricalaser/internal/handlers/admin/chat"
+	"github.com/alonsoalpizar/fabricalaser/internal/llm"
+	"github.com/alonsoalpizar/fabricalaser/internal/services/designfiles"
+	"github.com/alonsoalpizar/fabricalaser/internal/services/knowledge"
+	"github.com/alonsoalpizar/fabricalaser/internal/whatsapp"
+	"github.com/redis/go-redis/v9"
+)
+
+// defaultUserContext es el bloque DATOS DEL CLIENTE de un cliente nuevo por
+// WhatsApp; el escenario lo reemplaza con user_context
+const defaultUserContext = "\n\nDATOS DEL CLIENTE:\nCanal: WhatsApp\nCliente NO registrado en FabricaLaser.\n"
+
+// evalAdminID identifica al gestor de las corridas en la traza de acciones
+const evalAdminID = 0
+
+// agentFactories arma los agentes reales sobre el modelo de cada escenario.
+// Nada sale del proceso: el canal descarta los mensajes, no se abren atenciones
+// ni se mueven leads, y las acciones del chat admin quedan sin confirmar.
+func agentFactories(rc *redis.Client) map[string]boteval.AgentFactory {
+	waContext := whatsapp.NewWAContextProvider()
+	files := designfiles.NewService(whatsapp.NewRedisAdapter(rc))
+	kb := knowledge.NewService()
+	sink := &sinkChannel{}
+	registry := channels.NewRegistry(sink)
+	adminContext := adminchat.NewContextProvider()
+
+	return map[string]boteval.AgentFactory{
+		boteval.AgentWhatsApp: func(model llm.Provider, sc *boteval.Scenario) (boteval.Agent, error) {
+			agent := whatsapp.NewGeminiAdapter(model, waContext, registry, files, nil, nil, nil, kb)
+			userCtx := sc.UserContext
+			if userCtx == "" {
+				userCtx = defaultUserContext
+			}
+			return &waAgent{agent: agent, userCtx: userCtx, from: evalIdentity(sc)}, nil
+		},
+		boteval.AgentAdmin: func(model llm.Provider, sc *boteval.Scenario) (boteval.Agent, error) {
+			return &adminAgent{agent: adminchat.NewAgent(model, adminContext, kb), session: "eval-" + sc.Name}, nil
+		},
+	}
+}
+
+func evalIdentity(sc *boteval.Scenario) channels.Identity {
+	return channels.Identity{
+		Channel: channels.WhatsApp,
+		ID:      "eval",
+		Key:     "eval:" + sc.Name,
+		Name:    "Cliente de prueba",
+	}
+}
+
+type waAgent struct {
+	agent   channels.Agent
+	userCtx string
+	from    channels.Identity
+}
+
+func (a *waAgent) Reply(ctx context.Context, history []channels.Turn, message string) (string, error) {
+	return a.agent.CallWithTools(ctx, a.from, history, message, a.userCtx)
+}
+
+type adminAgent struct {
+	agent   *adminchat.Agent
+	session string
+}
+
+func (a *adminAgent) Reply(ctx context.Context, history []channels.Turn, message string) (string, error) {
+	turns := make([]adminchat.ChatTurn, len(history))
+	for i, t := range history {
+		turns[i] = adminchat.ChatTurn{Role: t.Role, Content: t.Content}
+	}
+	res, err := a.agent.Call(ctx, evalAdminID, "Evaluación", a.session, turns, message)
+	if err != nil {
+		return "", err
+	}
+	return res.Reply, nil
+}
+
+// sinkChannel es un canal WhatsApp que descarta lo que se envía (avisos al
+// asesor, botones, PDFs). El agente no distingue una corrida de evaluación.
+type sinkChannel struct{}
+
+func (c *sinkChannel) Name() string      { return channels.WhatsApp }
+func (c *sinkChannel) Label() string     { return "WhatsApp" }
+func (c *sinkChannel) Namespace() string { return "eval" }
+
+func (c *sinkChannel) SendText(context.Context, channels.Identity, string) error {
+	return nil
+}
+func (c *sinkChannel) SendImage(context.Context, channels.Identity, []byte, string, string) error {
+	return nil
+}
+func (c *sinkChannel) SendDocument(context.Context, channels.Identity, []byte, string, string, string) error {
+	return nil
+}
+func (c *sinkChannel) SendButtons(context.Context, channels.Identity, string, []channels.Button) error {
+	return nil
+}
+func (c *sinkChannel) DownloadMedia(context.Context, *channels.Media) ([]byte, string, error) {
+	return nil, "", fmt.Errorf("boteval: el canal de evaluación no tiene adjuntos")
+}
+func (c *sinkChannel) Asesor(context.Context) (channels.Identity, bool) {
+	return channels.Identity{Channel: channels.WhatsApp, ID: "asesor-eval"}, true
+}
+func (c *sinkChannel) IsAsesor(context.Context, channels.Identity) bool { return false }
+func (c *sinkChannel) UserContext(context.Context, channels.Identity) string {
+	return defaultUserContext
+}
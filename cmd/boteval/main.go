@@ -0,0 +1,122 @@
+// cmd/boteval/main.go — regression runner for the bot agents.
+// Replays the YAML conversations in cmd/boteval/scenarios through the
+// WhatsApp and admin agents and checks tool calls, arguments and prices.
+//
+// Usage:
+//
+//	./bin/fabricalaser-boteval                         # fake model (scripted steps in each scenario)
+//	./bin/fabricalaser-boteval -model recorded         # replay cmd/boteval/scenarios/recordings
+//	./bin/fabricalaser-boteval -model vertex -record   # live model, refresh the recordings
+//	./bin/fabricalaser-boteval -run cotizacion -v      # filter by name, print transcripts
+//
+// Tools run for real: needs the database and Redis from .env, and the API on
+// :8083 for calcular_cotizacion. Point it at a development database.
+// Exit code 1 if any scenario fails.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/boteval"
+	"github.com/alonsoalpizar/fabricalaser/internal/database"
+	"github.com/alonsoalpizar/fabricalaser/internal/llm"
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	dir := flag.String("dir", "cmd/boteval/scenarios", "directorio de escenarios YAML")
+	modelFlag := flag.String("model", "fake", "modelo: fake | recorded | vertex")
+	record := flag.Bool("record", false, "con -model vertex, guardar las respuestas para -model recorded")
+	recordings := flag.String("recordings", "", "directorio de grabaciones (default <dir>/recordings)")
+	run := flag.String("run", "", "regexp: correr solo los escenarios cuyo nombre coincide")
+	verbose := flag.Bool("v", false, "mostrar la transcripción y las tools de cada turno")
+	jsonOut := flag.String("json", "", "escribir los resultados en este archivo JSON")
+	timeout := flag.Duration("timeout", 60*time.Second, "tiempo máximo por turno")
+	flag.Parse()
+
+	_ = godotenv.Load()
+
+	scenarios, err := boteval.LoadDir(*dir)
+	if err != nil {
+		log.Fatalf("escenarios: %v", err)
+	}
+	if *run != "" {
+		re, err := regexp.Compile(*run)
+		if err != nil {
+			log.Fatalf("-run: %v", err)
+		}
+		filtered := scenarios[:0]
+		for _, sc := range scenarios {
+			if re.MatchString(sc.Name) {
+				filtered = append(filtered, sc)
+			}
+		}
+		scenarios = filtered
+	}
+	if len(scenarios) == 0 {
+		log.Fatalf("no hay escenarios en %s", *dir)
+	}
+
+	recDir := *recordings
+	if recDir == "" {
+		recDir = filepath.Join(*dir, "recordings")
+	}
+	ctx := context.Background()
+
+	var backend boteval.Backend
+	switch *modelFlag {
+	case "fake":
+		backend = boteval.FakeBackend{}
+	case "recorded":
+		backend = boteval.ReplayBackend{Dir: recDir}
+	case "vertex":
+		gemini, err := llm.NewGemini(ctx, llm.GCPProjectID, llm.GCPLocation)
+		if err != nil {
+			log.Fatalf("vertex: %v", err)
+		}
+		live := boteval.LiveBackend{Model: llm.WithRetry(gemini, llm.DefaultRetryDelays)}
+		if *record {
+			live.RecordDir = recDir
+		}
+		backend = live
+	default:
+		log.Fatalf("-model debe ser fake, recorded o vertex")
+	}
+
+	if _, err := database.Connect(); err != nil {
+		log.Fatalf("DB: %v", err)
+	}
+	defer database.Close()
+	rc, err := database.ConnectRedis()
+	if err != nil {
+		log.Fatalf("Redis: %v", err)
+	}
+
+	runner := &boteval.Runner{
+		Agents:      agentFactories(rc),
+		Backend:     backend,
+		TurnTimeout: *timeout,
+	}
+	results := runner.Run(ctx, scenarios)
+	boteval.WriteReport(os.Stdout, backend.Name(), results, *verbose)
+
+	if *jsonOut != "" {
+		data, err := json.MarshalIndent(results, "", "  ")
+		if err == nil {
+			err = os.WriteFile(*jsonOut, data, 0o644)
+		}
+		if err != nil {
+			log.Printf("-json: %v", err)
+		}
+	}
+	if boteval.Failed(results) {
+		os.Exit(1)
+	}
+}
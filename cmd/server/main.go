@@ -1,13 +1,23 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/alonsoalpizar/fabricalaser/internal/config"
 	"github.com/alonsoalpizar/fabricalaser/internal/database"
+	"github.com/alonsoalpizar/fabricalaser/internal/events"
 	"github.com/alonsoalpizar/fabricalaser/internal/handlers"
+	"github.com/alonsoalpizar/fabricalaser/internal/services/analytics"
+	"github.com/alonsoalpizar/fabricalaser/internal/services/notifications"
+	"github.com/alonsoalpizar/fabricalaser/internal/services/payments"
+	"github.com/alonsoalpizar/fabricalaser/internal/services/webhooks"
 	"github.com/alonsoalpizar/fabricalaser/internal/whatsapp"
 	"github.com/joho/godotenv"
 )
@@ -48,11 +58,33 @@ func main() {
 		log.Fatalf("Failed to connect to Redis: %v", err)
 	}
 
+	// Lifecycle context: cancelled on SIGINT/SIGTERM, stops the background jobs and the server
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	// Start WhatsApp digest email scheduler (every 4 hours)
 	whatsapp.StartDigestScheduler(redisClient)
 
+	// Start payment reconciliation job (expira cobros vencidos y reintenta movimientos sin conciliar).
+	// Los cobros son solo del tenant por defecto: sus rutas están detrás de tenant.DefaultOnly
+	payments.NewServiceFromConfig().StartReconciliationJob(ctx, 15*time.Minute)
+
+	// Avisos proactivos a clientes (eventos de cotizaciones/pedidos, reintentos y cotizaciones por vencer).
+	// Cada evento y cada reintento corre con su tenant; los vencimientos se buscan en cada tenant activo
+	notifications.NewDispatcher().Start(ctx, events.Default, 5*time.Minute)
+
+	// Webhooks salientes a socios y automatizaciones (entregas firmadas y reintentos con espera exponencial).
+	// Cada entrega se reintenta con el tenant de su fila
+	webhooks.NewDispatcher().Start(ctx, events.Default, 30*time.Second)
+
+	// Rollups nocturnos de la analítica de conversaciones (2:00 hora Costa Rica), por tenant activo
+	analytics.NewService().StartNightlyJob(ctx)
+
 	// Setup router
-	router := handlers.NewRouter(redisClient)
+	router, jobs := handlers.NewRouter(redisClient)
+
+	// Memoria de largo plazo: resume las sesiones terminadas de los contactos de cada tenant activo
+	jobs.CustomerMemory.StartJob(ctx, 30*time.Minute)
 
 	// Start server
 	addr := ":" + cfg.Port
@@ -60,7 +92,18 @@ func main() {
 	log.Printf("Health check: http://localhost%s/api/v1/health", addr)
 	log.Printf("Auth endpoints: http://localhost%s/api/v1/auth/*", addr)
 
-	if err := http.ListenAndServe(addr, router); err != nil {
+	srv := &http.Server{Addr: addr, Handler: router}
+	go func() {
+		<-ctx.Done()
+		log.Println("Shutting down server")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Server shutdown failed: %v", err)
+		}
+	}()
+
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		log.Fatalf("Server failed: %v", err)
 	}
 }
@@ -0,0 +1,234 @@
+package boteval
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/llm"
+)
+
+// Call es una llamada a tool observada en un turno, con su resultado
+type Call struct {
+	Name   string         `json:"name"`
+	Args   map[string]any `json:"args"`
+	Result map[string]any `json:"result,omitempty"`
+}
+
+// tap envuelve el proveedor del modelo y observa la conversación: las tools
+// que el modelo pide, los resultados que el agente le devuelve y el system
+// prompt. Así el harness no depende de cómo cada agente expone sus tools.
+type tap struct {
+	inner llm.Provider
+
+	mu        sync.Mutex
+	calls     []Call
+	responses []*llm.Response
+	system    string
+}
+
+func newTap(inner llm.Provider) *tap {
+	return &tap{inner: inner}
+}
+
+// Generate implementa llm.Provider
+func (t *tap) Generate(ctx context.Context, cfg llm.Config, contents []llm.Message) (*llm.Response, error) {
+	t.mu.Lock()
+	if t.system == "" {
+		t.system = cfg.System
+	}
+	if n := len(contents); n > 0 {
+		t.attachResults(contents[n-1])
+	}
+	t.mu.Unlock()
+
+	resp, err := t.inner.Generate(ctx, cfg, contents)
+	if err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.responses = append(t.responses, resp)
+	for _, p := range resp.Parts {
+		if p.Call != nil {
+			t.calls = append(t.calls, Call{Name: p.Call.Name, Args: p.Call.Args})
+		}
+	}
+	return resp, nil
+}
+
+// attachResults liga los resultados de tools del mensaje con las llamadas
+// pendientes, en orden
+func (t *tap) attachResults(msg llm.Message) {
+	for _, p := range msg.Parts {
+		if p.Response == nil {
+			continue
+		}
+		for i := range t.calls {
+			if t.calls[i].Name == p.Response.Name && t.calls[i].Result == nil {
+				t.calls[i].Result = p.Response.Result
+				break
+			}
+		}
+	}
+}
+
+// takeCalls retorna las llamadas observadas desde la última vez
+func (t *tap) takeCalls() []Call {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	calls := t.calls
+	t.calls = nil
+	return calls
+}
+
+// ─── Backends del modelo ─────────────────────────────────────────────────────
+
+// Backend provee el modelo para cada escenario
+type Backend interface {
+	// Name identifica el backend en el reporte
+	Name() string
+	// Provider retorna el modelo para el escenario; ErrSkip si el backend no
+	// puede correrlo (sin guion o sin grabación)
+	Provider(sc *Scenario) (llm.Provider, error)
+	// Finish recibe lo observado al terminar el escenario (grabar, avisar que
+	// la grabación quedó vieja); retorna advertencias para el reporte
+	Finish(sc *Scenario, t *tap) ([]string, error)
+}
+
+// ErrSkip marca un escenario que el backend no puede correr
+type ErrSkip struct{ Reason string }
+
+func (e ErrSkip) Error() string { return e.Reason }
+
+// FakeBackend responde con los pasos "model" del propio escenario. Prueba las
+// tools y el calculador con argumentos fijos, sin red ni costo.
+type FakeBackend struct{}
+
+func (FakeBackend) Name() string { return "fake" }
+
+func (FakeBackend) Provider(sc *Scenario) (llm.Provider, error) {
+	if !sc.HasScript() {
+		return nil, ErrSkip{"el escenario no trae guion para el modelo fake"}
+	}
+	var steps []llm.Step
+	for _, turn := range sc.Turns {
+		for _, s := range turn.Model {
+			if s.Call != "" {
+				steps = append(steps, llm.CallTool(s.Call, s.Args))
+			} else {
+				steps = append(steps, llm.Reply(s.Reply))
+			}
+		}
+	}
+	return llm.NewScript(steps...), nil
+}
+
+func (FakeBackend) Finish(*Scenario, *tap) ([]string, error) { return nil, nil }
+
+// Recording es la grabación de las respuestas del modelo en un escenario
+type Recording struct {
+	Scenario   string          `json:"scenario"`
+	Agent      string          `json:"agent"`
+	PromptHash string          `json:"prompt_hash"`
+	RecordedAt time.Time       `json:"recorded_at"`
+	Responses  []*llm.Response `json:"responses"`
+}
+
+// ReplayBackend reproduce las respuestas grabadas en Dir/<escenario>.json
+type ReplayBackend struct {
+	Dir string
+}
+
+func (b ReplayBackend) Name() string { return "recorded" }
+
+func (b ReplayBackend) Provider(sc *Scenario) (llm.Provider, error) {
+	rec, err := b.load(sc)
+	if os.IsNotExist(err) {
+		return nil, ErrSkip{"sin grabación: correr con -model vertex -record"}
+	}
+	if err != nil {
+		return nil, err
+	}
+	steps := make([]llm.Step, len(rec.Responses))
+	for i, r := range rec.Responses {
+		steps[i] = llm.Step{Response: r}
+	}
+	return llm.NewScript(steps...), nil
+}
+
+// Finish avisa si el system prompt cambió desde la grabación: las respuestas
+// grabadas ya no dicen cómo reacciona el modelo al prompt nuevo
+func (b ReplayBackend) Finish(sc *Scenario, t *tap) ([]string, error) {
+	rec, err := b.load(sc)
+	if err != nil {
+		return nil, err
+	}
+	if rec.PromptHash != promptHash(t.system) {
+		return []string{fmt.Sprintf("el system prompt cambió desde la grabación del %s: regrabá con -model vertex -record", rec.RecordedAt.Format("2006-01-02"))}, nil
+	}
+	return nil, nil
+}
+
+func (b ReplayBackend) load(sc *Scenario) (*Recording, error) {
+	data, err := os.ReadFile(recordingPath(b.Dir, sc))
+	if err != nil {
+		return nil, err
+	}
+	var rec Recording
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, fmt.Errorf("grabación %s: %w", recordingPath(b.Dir, sc), err)
+	}
+	return &rec, nil
+}
+
+// LiveBackend usa el modelo real. Con RecordDir guarda las respuestas para
+// reproducirlas después con ReplayBackend.
+type LiveBackend struct {
+	Model     llm.Provider
+	RecordDir string
+}
+
+func (b LiveBackend) Name() string { return "vertex" }
+
+func (b LiveBackend) Provider(*Scenario) (llm.Provider, error) { return b.Model, nil }
+
+func (b LiveBackend) Finish(sc *Scenario, t *tap) ([]string, error) {
+	if b.RecordDir == "" {
+		return nil, nil
+	}
+	t.mu.Lock()
+	rec := Recording{
+		Scenario:   sc.Name,
+		Agent:      sc.Agent,
+		PromptHash: promptHash(t.system),
+		RecordedAt: time.Now(),
+		Responses:  t.responses,
+	}
+	t.mu.Unlock()
+
+	data, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(b.RecordDir, 0o755); err != nil {
+		return nil, err
+	}
+	return nil, os.WriteFile(recordingPath(b.RecordDir, sc), data, 0o644)
+}
+
+func recordingPath(dir string, sc *Scenario) string {
+	return filepath.Join(dir, sc.Name+".json")
+}
+
+func promptHash(system string) string {
+	sum := sha256.Sum256([]byte(system))
+	return hex.EncodeToString(sum[:8])
+}
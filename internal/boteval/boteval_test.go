@@ -0,0 +1,174 @@
+package boteval
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/channels"
+	"github.com/alonsoalpizar/fabricalaser/internal/llm"
+	"gopkg.in/yaml.v3"
+)
+
+const testScenario = `
+name: mdf_co2
+agent: whatsapp
+turns:
+  - user: "50 llaveros de MDF, 5x3 cm"
+    expect:
+      tools:
+        - name: calcular_cotizacion
+          args:
+            technology_id: 1
+            cantidad: {min: 50, max: 50}
+            material_id: [1, 2]
+          price: {min: 1000, max: 5000}
+      forbid_tools: [escalar_a_humano]
+      reply_contains: ["₡"]
+    model:
+      - call: calcular_cotizacion
+        args: {technology_id: 1, material_id: 1, cantidad: 50}
+      - reply: "Quedan en ₡2.500"
+`
+
+// toyAgent es un agente mínimo con una tool de precio: 50 por unidad
+func toyAgent(model llm.Provider, _ *Scenario) (Agent, error) {
+	return agentFunc(func(ctx context.Context, history []channels.Turn, msg string) (string, error) {
+		tools := llm.NewRegistry()
+		tools.Register(llm.Tool{Name: "calcular_cotizacion"}, func(_ context.Context, args map[string]any) (map[string]any, error) {
+			n, _ := number(args["cantidad"])
+			return map[string]any{"precio_estimado": 50 * n}, nil
+		})
+		chat := llm.NewChat(model, llm.Config{System: "prompt v1", Tools: tools.Tools()}, nil)
+		resp, err := chat.Send(ctx, llm.Text(msg))
+		if err != nil {
+			return "", err
+		}
+		resp, err = chat.RunTools(ctx, resp, 3, tools, nil)
+		if err != nil {
+			return "", err
+		}
+		return resp.Text(), nil
+	}), nil
+}
+
+type agentFunc func(context.Context, []channels.Turn, string) (string, error)
+
+func (f agentFunc) Reply(ctx context.Context, h []channels.Turn, m string) (string, error) {
+	return f(ctx, h, m)
+}
+
+func writeScenario(t *testing.T, dir, body string) *Scenario {
+	t.Helper()
+	path := filepath.Join(dir, "sc.yaml")
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sc, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sc
+}
+
+func TestRunFakeBackend(t *testing.T) {
+	sc := writeScenario(t, t.TempDir(), testScenario)
+	runner := &Runner{Agents: map[string]AgentFactory{AgentWhatsApp: toyAgent}, Backend: FakeBackend{}}
+
+	res := runner.RunOne(context.Background(), sc)
+	if res.Status != StatusPass {
+		t.Fatalf("status = %s, failures: %v", res.Status, res.Failures)
+	}
+	if calls := res.Turns[0].Calls; len(calls) != 1 || calls[0].Result["precio_estimado"] != 2500.0 {
+		t.Errorf("calls = %+v", calls)
+	}
+
+	// UV en lugar de CO2 y un precio fuera de rango
+	wrong := strings.Replace(testScenario, "args: {technology_id: 1, material_id: 1, cantidad: 50}", "args: {technology_id: 2, material_id: 1, cantidad: 500}", 1)
+	sc = writeScenario(t, t.TempDir(), wrong)
+	res = runner.RunOne(context.Background(), sc)
+	if res.Status != StatusFail || len(res.Failures) != 1 {
+		t.Fatalf("status = %s, failures: %v", res.Status, res.Failures)
+	}
+	for _, want := range []string{"turno 1", "technology_id", "cantidad"} {
+		if !strings.Contains(res.Failures[0], want) {
+			t.Errorf("failure %q missing %q", res.Failures[0], want)
+		}
+	}
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	dir := t.TempDir()
+	sc := writeScenario(t, dir, testScenario)
+	agents := map[string]AgentFactory{AgentWhatsApp: toyAgent}
+
+	// "En vivo" con un guion, grabando
+	live := LiveBackend{Model: llm.NewScript(
+		llm.CallTool("calcular_cotizacion", map[string]any{"technology_id": 1, "material_id": 2, "cantidad": 50}),
+		llm.Reply("Son ₡2.500"),
+	), RecordDir: dir}
+	if res := (&Runner{Agents: agents, Backend: live}).RunOne(context.Background(), sc); res.Status != StatusPass {
+		t.Fatalf("live: %s %v %v", res.Status, res.Failures, res.Warnings)
+	}
+
+	replay := &Runner{Agents: agents, Backend: ReplayBackend{Dir: dir}}
+	res := replay.RunOne(context.Background(), sc)
+	if res.Status != StatusPass || len(res.Warnings) != 0 {
+		t.Fatalf("replay: %s %v %v", res.Status, res.Failures, res.Warnings)
+	}
+
+	// Sin grabación el escenario se omite
+	other := *sc
+	other.Name = "sin_grabar"
+	if res := replay.RunOne(context.Background(), &other); res.Status != StatusSkip {
+		t.Errorf("status = %s, want SKIP", res.Status)
+	}
+}
+
+func TestConstraintYAML(t *testing.T) {
+	var args map[string]Constraint
+	src := "a: 1\nb: [grabado, corte]\nc: {min: 2.5, max: 3}\nd: {contains: mdf}\n"
+	if err := yaml.Unmarshal([]byte(src), &args); err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		key  string
+		v    any
+		pass bool
+	}{
+		{"a", 1.0, true},
+		{"a", 2.0, false},
+		{"b", "Corte", true},
+		{"b", "grabado_corte", false},
+		{"c", 3.0, true},
+		{"c", 3.5, false},
+		{"c", "3", false},
+		{"d", "Madera / MDF", true},
+	}
+	for _, c := range cases {
+		if got := args[c.key].Check(c.v, true) == ""; got != c.pass {
+			t.Errorf("%s=%v: pass = %v, want %v", c.key, c.v, got, c.pass)
+		}
+	}
+	if args["a"].Check(nil, false) == "" {
+		t.Error("missing arg passed")
+	}
+}
+
+// Los escenarios del repo tienen que cargar (cmd/boteval los corre)
+func TestShippedScenarios(t *testing.T) {
+	scenarios, err := LoadDir("../../cmd/boteval/scenarios")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scenarios) == 0 {
+		t.Fatal("no scenarios found")
+	}
+	for _, sc := range scenarios {
+		if !sc.HasScript() {
+			t.Errorf("%s: sin guion para el modelo fake", sc.Name)
+		}
+	}
+}
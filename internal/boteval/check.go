@@ -0,0 +1,233 @@
+package boteval
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Constraint restringe un argumento de una tool. En el YAML un escalar es
+// igualdad (technology_id: 1), una lista es "uno de" (material_id: [1, 2]) y
+// un mapa combina eq, min, max, one_of y contains.
+type Constraint struct {
+	Eq       any      `yaml:"eq"`
+	Min      *float64 `yaml:"min"`
+	Max      *float64 `yaml:"max"`
+	OneOf    []any    `yaml:"one_of"`
+	Contains string   `yaml:"contains"`
+}
+
+// UnmarshalYAML acepta las tres formas de Constraint
+func (c *Constraint) UnmarshalYAML(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		return node.Decode(&c.Eq)
+	case yaml.SequenceNode:
+		return node.Decode(&c.OneOf)
+	}
+	type plain Constraint
+	return node.Decode((*plain)(c))
+}
+
+// Check retorna "" si el valor cumple, o la descripción de lo que no cumple
+func (c Constraint) Check(v any, present bool) string {
+	if !present {
+		return "no vino"
+	}
+	if c.Eq != nil && !equal(v, c.Eq) {
+		return fmt.Sprintf("= %s, se esperaba %s", show(v), show(c.Eq))
+	}
+	if len(c.OneOf) > 0 {
+		ok := false
+		for _, want := range c.OneOf {
+			ok = ok || equal(v, want)
+		}
+		if !ok {
+			return fmt.Sprintf("= %s, se esperaba uno de %s", show(v), show(c.OneOf))
+		}
+	}
+	if c.Min != nil || c.Max != nil {
+		n, ok := number(v)
+		if !ok {
+			return fmt.Sprintf("= %s, se esperaba un número", show(v))
+		}
+		if c.Min != nil && n < *c.Min {
+			return fmt.Sprintf("= %s, menor que %g", show(v), *c.Min)
+		}
+		if c.Max != nil && n > *c.Max {
+			return fmt.Sprintf("= %s, mayor que %g", show(v), *c.Max)
+		}
+	}
+	if c.Contains != "" {
+		s, _ := v.(string)
+		if !strings.Contains(strings.ToLower(s), strings.ToLower(c.Contains)) {
+			return fmt.Sprintf("= %s, no contiene %q", show(v), c.Contains)
+		}
+	}
+	return ""
+}
+
+// checkTurn compara lo observado en un turno con lo esperado y retorna las
+// fallas (vacío si el turno pasa)
+func checkTurn(exp Expect, reply string, calls []Call) []string {
+	var failures []string
+
+	// Tools esperadas: subsecuencia de las llamadas del turno
+	cursor := 0
+	for _, want := range exp.Tools {
+		found := -1
+		var firstMiss string
+		for i := cursor; i < len(calls); i++ {
+			if calls[i].Name != want.Name {
+				continue
+			}
+			miss := matchCall(want, calls[i])
+			if miss == "" {
+				found = i
+				break
+			}
+			if firstMiss == "" {
+				firstMiss = miss
+			}
+		}
+		switch {
+		case found >= 0:
+			cursor = found + 1
+		case firstMiss != "":
+			failures = append(failures, fmt.Sprintf("%s: %s", want.Name, firstMiss))
+		default:
+			failures = append(failures, fmt.Sprintf("no llamó %s (llamó: %s)", want.Name, callNames(calls)))
+		}
+	}
+
+	if exp.NoTools && len(calls) > 0 {
+		failures = append(failures, fmt.Sprintf("no debía llamar tools (llamó: %s)", callNames(calls)))
+	}
+	for _, name := range exp.ForbidTools {
+		for _, c := range calls {
+			if c.Name == name {
+				failures = append(failures, fmt.Sprintf("llamó %s, que no debía", name))
+				break
+			}
+		}
+	}
+
+	lower := strings.ToLower(reply)
+	for _, s := range exp.ReplyContains {
+		if !strings.Contains(lower, strings.ToLower(s)) {
+			failures = append(failures, fmt.Sprintf("la respuesta no menciona %q", s))
+		}
+	}
+	for _, s := range exp.ReplyNotContains {
+		if strings.Contains(lower, strings.ToLower(s)) {
+			failures = append(failures, fmt.Sprintf("la respuesta menciona %q", s))
+		}
+	}
+	return failures
+}
+
+// matchCall verifica argumentos y precio de una llamada; "" si cumple
+func matchCall(want ToolExpect, call Call) string {
+	keys := make([]string, 0, len(want.Args))
+	for k := range want.Args {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var misses []string
+	for _, k := range keys {
+		v, present := call.Args[k]
+		if miss := want.Args[k].Check(v, present); miss != "" {
+			misses = append(misses, k+" "+miss)
+		}
+	}
+	if len(misses) > 0 {
+		return strings.Join(misses, "; ")
+	}
+	if want.Price != nil {
+		return checkPrice(*want.Price, call.Result)
+	}
+	return ""
+}
+
+func checkPrice(want PriceRange, result map[string]any) string {
+	if result == nil {
+		return "la tool no devolvió resultado"
+	}
+	if e, failed := result["error"]; failed {
+		return fmt.Sprintf("la tool devolvió error: %v", e)
+	}
+	fields := []string{want.Field}
+	if want.Field == "" {
+		fields = []string{"precio_estimado", "precio_total"}
+	}
+	for _, f := range fields {
+		v, ok := result[f]
+		if !ok {
+			continue
+		}
+		price, ok := number(v)
+		if !ok {
+			return fmt.Sprintf("%s = %s no es un número", f, show(v))
+		}
+		if price < want.Min || (want.Max > 0 && price > want.Max) {
+			return fmt.Sprintf("%s = %g fuera del rango [%g, %g]", f, price, want.Min, want.Max)
+		}
+		return ""
+	}
+	return fmt.Sprintf("el resultado no trae %s", strings.Join(fields, " ni "))
+}
+
+// equal compara valores de YAML y de JSON: números por valor, strings sin
+// distinguir mayúsculas
+func equal(got, want any) bool {
+	if a, ok := number(got); ok {
+		b, ok := number(want)
+		return ok && a == b
+	}
+	if a, ok := got.(string); ok {
+		b, ok := want.(string)
+		return ok && strings.EqualFold(a, b)
+	}
+	return fmt.Sprint(got) == fmt.Sprint(want)
+}
+
+func number(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func show(v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+func callNames(calls []Call) string {
+	if len(calls) == 0 {
+		return "ninguna"
+	}
+	names := make([]string, len(calls))
+	for i, c := range calls {
+		names[i] = c.Name
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,183 @@
+package boteval
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/channels"
+	"github.com/alonsoalpizar/fabricalaser/internal/llm"
+)
+
+// Agent responde un turno con el historial de la conversación
+type Agent interface {
+	Reply(ctx context.Context, history []channels.Turn, message string) (string, error)
+}
+
+// AgentFactory arma el agente de un escenario sobre el modelo del backend
+type AgentFactory func(model llm.Provider, sc *Scenario) (Agent, error)
+
+// Status es el resultado de un escenario
+type Status string
+
+const (
+	StatusPass Status = "PASS"
+	StatusFail Status = "FAIL"
+	StatusSkip Status = "SKIP"
+)
+
+// Result es el resultado de un escenario con lo que pasó en cada turno
+type Result struct {
+	Scenario string        `json:"scenario"`
+	Agent    string        `json:"agent"`
+	Status   Status        `json:"status"`
+	Failures []string      `json:"failures,omitempty"`
+	Warnings []string      `json:"warnings,omitempty"`
+	Turns    []TurnLog     `json:"turns,omitempty"`
+	Elapsed  time.Duration `json:"elapsed_ns"`
+}
+
+// TurnLog es lo observado en un turno
+type TurnLog struct {
+	User  string `json:"user"`
+	Reply string `json:"reply"`
+	Calls []Call `json:"calls,omitempty"`
+}
+
+// Runner corre escenarios contra los agentes
+type Runner struct {
+	Agents  map[string]AgentFactory
+	Backend Backend
+	// TurnTimeout acota cada turno (modelo + tools); 0 usa 60 segundos
+	TurnTimeout time.Duration
+}
+
+// Run corre los escenarios en orden
+func (r *Runner) Run(ctx context.Context, scenarios []*Scenario) []Result {
+	results := make([]Result, 0, len(scenarios))
+	for _, sc := range scenarios {
+		results = append(results, r.RunOne(ctx, sc))
+	}
+	return results
+}
+
+// RunOne corre un escenario. Un turno que falla no corta el escenario: los
+// siguientes se siguen evaluando con la respuesta que hubo.
+func (r *Runner) RunOne(ctx context.Context, sc *Scenario) Result {
+	start := time.Now()
+	res := Result{Scenario: sc.Name, Agent: sc.Agent, Status: StatusPass}
+	defer func() { res.Elapsed = time.Since(start) }()
+
+	factory, ok := r.Agents[sc.Agent]
+	if !ok {
+		res.Status = StatusSkip
+		res.Warnings = []string{fmt.Sprintf("agente %q no disponible", sc.Agent)}
+		return res
+	}
+	model, err := r.Backend.Provider(sc)
+	var skip ErrSkip
+	if errors.As(err, &skip) {
+		res.Status = StatusSkip
+		res.Warnings = []string{skip.Reason}
+		return res
+	}
+	if err != nil {
+		return failed(res, err.Error())
+	}
+
+	t := newTap(model)
+	agent, err := factory(t, sc)
+	if err != nil {
+		return failed(res, "armando el agente: "+err.Error())
+	}
+
+	timeout := r.TurnTimeout
+	if timeout <= 0 {
+		timeout = 60 * time.Second
+	}
+	var history []channels.Turn
+	for i, turn := range sc.Turns {
+		turnCtx, cancel := context.WithTimeout(ctx, timeout)
+		reply, err := agent.Reply(turnCtx, history, turn.User)
+		cancel()
+		calls := t.takeCalls()
+		res.Turns = append(res.Turns, TurnLog{User: turn.User, Reply: reply, Calls: calls})
+
+		prefix := fmt.Sprintf("turno %d: ", i+1)
+		if err != nil {
+			res.Failures = append(res.Failures, prefix+err.Error())
+			continue
+		}
+		for _, f := range checkTurn(turn.Expect, reply, calls) {
+			res.Failures = append(res.Failures, prefix+f)
+		}
+		history = append(history,
+			channels.Turn{Role: llm.RoleUser, Content: turn.User},
+			channels.Turn{Role: llm.RoleModel, Content: reply},
+		)
+	}
+
+	warnings, err := r.Backend.Finish(sc, t)
+	res.Warnings = append(res.Warnings, warnings...)
+	if err != nil {
+		res.Warnings = append(res.Warnings, fmt.Sprintf("%s: %v", r.Backend.Name(), err))
+	}
+	if len(res.Failures) > 0 {
+		res.Status = StatusFail
+	}
+	return res
+}
+
+func failed(res Result, msg string) Result {
+	res.Status = StatusFail
+	res.Failures = append(res.Failures, msg)
+	return res
+}
+
+// Failed indica si algún escenario falló
+func Failed(results []Result) bool {
+	for _, r := range results {
+		if r.Status == StatusFail {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteReport escribe el resultado de cada escenario y el total. verbose
+// agrega la transcripción con las tools de cada turno.
+func WriteReport(w io.Writer, backend string, results []Result, verbose bool) {
+	counts := map[Status]int{}
+	for _, r := range results {
+		counts[r.Status]++
+		fmt.Fprintf(w, "%s  %-40s %-9s %6.1fs\n", r.Status, r.Scenario, r.Agent, r.Elapsed.Seconds())
+		for _, f := range r.Failures {
+			fmt.Fprintf(w, "      ✗ %s\n", f)
+		}
+		for _, warn := range r.Warnings {
+			fmt.Fprintf(w, "      ! %s\n", warn)
+		}
+		if verbose {
+			for i, t := range r.Turns {
+				fmt.Fprintf(w, "      [%d] > %s\n", i+1, t.User)
+				for _, c := range t.Calls {
+					fmt.Fprintf(w, "          · %s %s → %s\n", c.Name, show(c.Args), abbreviate(show(c.Result), 160))
+				}
+				fmt.Fprintf(w, "          < %s\n", abbreviate(strings.ReplaceAll(t.Reply, "\n", " "), 240))
+			}
+		}
+	}
+	fmt.Fprintf(w, "\n%d escenarios (modelo %s): %d pasan, %d fallan, %d omitidos\n",
+		len(results), backend, counts[StatusPass], counts[StatusFail], counts[StatusSkip])
+}
+
+func abbreviate(s string, max int) string {
+	r := []rune(s)
+	if len(r) <= max {
+		return s
+	}
+	return string(r[:max-1]) + "…"
+}
@@ -0,0 +1,170 @@
+// Package boteval reproduce conversaciones guionadas (escenarios YAML) contra
+// los agentes del bot y verifica qué tools llamaron, con qué argumentos y qué
+// precio devolvió el cotizador. Es la prueba de regresión de los prompts: un
+// cambio en gemini_adapter.go o prompt.go que hace preguntar de más o elegir
+// UV en lugar de CO2 aparece como escenario fallido.
+//
+// El modelo es intercambiable (Backend): un guion fake escrito en el mismo
+// escenario, una grabación de una corrida anterior, o el modelo real
+// (opcionalmente grabando). Las tools se ejecutan de verdad, así que los
+// precios salen del calculador actual.
+package boteval
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Agentes que se pueden evaluar
+const (
+	AgentWhatsApp = "whatsapp"
+	AgentAdmin    = "admin"
+)
+
+// Scenario es una conversación guionada con lo que se espera en cada turno
+type Scenario struct {
+	Name        string `yaml:"name"`
+	Agent       string `yaml:"agent"` // whatsapp | admin
+	Description string `yaml:"description"`
+	// UserContext reemplaza el bloque DATOS DEL CLIENTE (solo whatsapp)
+	UserContext string `yaml:"user_context"`
+	Turns       []Turn `yaml:"turns"`
+
+	Path string `yaml:"-"`
+}
+
+// Turn es un mensaje del cliente (o del gestor), lo esperado y, para el
+// backend fake, lo que responde el modelo
+type Turn struct {
+	User   string      `yaml:"user"`
+	Expect Expect      `yaml:"expect"`
+	Model  []ModelStep `yaml:"model"`
+}
+
+// Expect son las verificaciones de un turno
+type Expect struct {
+	// Tools esperadas, en orden (puede haber otras llamadas entre medio)
+	Tools            []ToolExpect `yaml:"tools"`
+	ForbidTools      []string     `yaml:"forbid_tools"`
+	NoTools          bool         `yaml:"no_tools"`
+	ReplyContains    []string     `yaml:"reply_contains"`
+	ReplyNotContains []string     `yaml:"reply_not_contains"`
+}
+
+// ToolExpect es una llamada esperada con restricciones sobre sus argumentos
+// y, si es una cotización, el rango de precio que debe devolver
+type ToolExpect struct {
+	Name  string                `yaml:"name"`
+	Args  map[string]Constraint `yaml:"args"`
+	Price *PriceRange           `yaml:"price"`
+}
+
+// PriceRange acota el precio del resultado de la tool. Field es la clave del
+// resultado; vacío usa precio_estimado (bot) o precio_total (chat admin).
+type PriceRange struct {
+	Min   float64 `yaml:"min"`
+	Max   float64 `yaml:"max"`
+	Field string  `yaml:"field"`
+}
+
+// ModelStep es una respuesta guionada del modelo para el backend fake:
+// una llamada a tool o un texto
+type ModelStep struct {
+	Call  string         `yaml:"call"`
+	Args  map[string]any `yaml:"args"`
+	Reply string         `yaml:"reply"`
+}
+
+// Load lee un escenario y valida su forma
+func Load(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var sc Scenario
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&sc); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	sc.Path = path
+	if sc.Name == "" {
+		sc.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if err := sc.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &sc, nil
+}
+
+// LoadDir lee todos los escenarios *.yaml / *.yml de dir, ordenados por nombre
+func LoadDir(dir string) ([]*Scenario, error) {
+	var paths []string
+	for _, pattern := range []string{"*.yaml", "*.yml"} {
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, matches...)
+	}
+	sort.Strings(paths)
+
+	scenarios := make([]*Scenario, 0, len(paths))
+	seen := map[string]string{}
+	for _, p := range paths {
+		sc, err := Load(p)
+		if err != nil {
+			return nil, err
+		}
+		if prev, dup := seen[sc.Name]; dup {
+			return nil, fmt.Errorf("escenario %q repetido en %s y %s", sc.Name, prev, p)
+		}
+		seen[sc.Name] = p
+		scenarios = append(scenarios, sc)
+	}
+	return scenarios, nil
+}
+
+// HasScript indica si el escenario trae guion para el backend fake
+func (sc *Scenario) HasScript() bool {
+	for _, t := range sc.Turns {
+		if len(t.Model) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (sc *Scenario) validate() error {
+	if sc.Agent != AgentWhatsApp && sc.Agent != AgentAdmin {
+		return fmt.Errorf("agent debe ser %q o %q, no %q", AgentWhatsApp, AgentAdmin, sc.Agent)
+	}
+	if len(sc.Turns) == 0 {
+		return fmt.Errorf("el escenario no tiene turnos")
+	}
+	for i, t := range sc.Turns {
+		if strings.TrimSpace(t.User) == "" {
+			return fmt.Errorf("turno %d: user es obligatorio", i+1)
+		}
+		for _, te := range t.Expect.Tools {
+			if te.Name == "" {
+				return fmt.Errorf("turno %d: tool esperada sin name", i+1)
+			}
+			if te.Price != nil && te.Price.Max > 0 && te.Price.Min > te.Price.Max {
+				return fmt.Errorf("turno %d: price.min mayor que price.max", i+1)
+			}
+		}
+		for j, step := range t.Model {
+			if (step.Call == "") == (step.Reply == "") {
+				return fmt.Errorf("turno %d, paso %d del modelo: usar call o reply (uno solo)", i+1, j+1)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+// Package channels define la abstracción de canal de mensajería (WhatsApp,
+// Telegram, ...) y el motor de conversación común a todos ellos.
+//
+// Cada canal traduce su webhook a Message, sabe enviar respuestas y conoce al
+// asesor en ese canal. Todo lo demás — deduplicación, límites, historial,
+// agente y archivo — vive en Engine y no se repite por canal.
+package channels
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Nombres de canal — se usan en logs, notificaciones y para elegir el canal del asesor
+const (
+	WhatsApp = "whatsapp"
+	Telegram = "telegram"
+	Web      = "web" // Chat del sitio: comparte el archivo pero no pasa por el motor
+)
+
+// ErrUnsupported indica que el canal no soporta la operación (ej. botones en un canal sin ellos)
+var ErrUnsupported = errors.New("channels: operación no soportada por el canal")
+
+// ErrRateLimited indica que el proveedor del canal rechazó el envío por límite propio
+var ErrRateLimited = errors.New("channels: límite de envío del proveedor alcanzado")
+
+// Identity identifica a un contacto dentro de un canal
+type Identity struct {
+	Channel  string // Nombre del canal (WhatsApp, Telegram)
+	ID       string // Identificador nativo: E.164 sin "+" en WhatsApp, chat ID en Telegram
+	Key      string // Clave de conversación persistida (columna phone de whatsapp_conversations)
+	Phone    string // Teléfono E.164 si el canal lo expone; vacío si no
+	Name     string
+	Username string
+}
+
+// MediaKind distingue los adjuntos entrantes
+type MediaKind string
+
+const (
+	MediaImage    MediaKind = "image"
+	MediaDocument MediaKind = "document"
+)
+
+// Media es un adjunto entrante; los bytes se descargan bajo demanda con Channel.DownloadMedia
+type Media struct {
+	Kind     MediaKind
+	ID       string // media ID (WhatsApp) o file_id (Telegram)
+	MimeType string
+	Filename string
+	Caption  string
+}
+
+// Message es un mensaje entrante ya normalizado
+type Message struct {
+	ID     string // ID del mensaje en el canal, para deduplicación
+	From   Identity
+	Text   string
+	Media  *Media
+	Choice *Button // Opción tocada por el cliente (botón o fila de lista); Text lleva su título
+}
+
+// Button es una opción de respuesta rápida. El ID viaja de vuelta cuando el
+// cliente la toca, así que conviene que sea legible para el agente ("material_id=3").
+type Button struct {
+	ID          string
+	Title       string
+	Description string // Solo la muestran los canales con listas
+}
+
+// Channel es un canal de mensajería conectado al motor de conversación
+type Channel interface {
+	// Name retorna el nombre del canal (WhatsApp, Telegram)
+	Name() string
+	// Label retorna el nombre para mostrar al asesor y al agente ("WhatsApp")
+	Label() string
+	// Namespace es el prefijo de las claves Redis del canal ("wa", "tg")
+	Namespace() string
+
+	SendText(ctx context.Context, to Identity, text string) error
+	SendImage(ctx context.Context, to Identity, data []byte, mimeType, caption string) error
+	SendDocument(ctx context.Context, to Identity, data []byte, mimeType, filename, caption string) error
+	SendButtons(ctx context.Context, to Identity, text string, buttons []Button) error
+	DownloadMedia(ctx context.Context, media *Media) ([]byte, string, error)
+
+	// Asesor retorna la identidad del asesor del tenant de ctx en este canal, si está configurada
+	Asesor(ctx context.Context) (Identity, bool)
+	// IsAsesor indica si el contacto es el asesor (exento del límite diario)
+	IsAsesor(ctx context.Context, id Identity) bool
+	// UserContext retorna el bloque de DATOS DEL CLIENTE para el system prompt
+	UserContext(ctx context.Context, from Identity) string
+}
+
+// KeyChannel deduce el canal de una clave de conversación archivada:
+// "tg:<chat_id>" es Telegram, "web:<user_id>" el chat del sitio y el número crudo WhatsApp
+func KeyChannel(key string) string {
+	switch {
+	case strings.HasPrefix(key, "tg:"):
+		return Telegram
+	case strings.HasPrefix(key, "web:"):
+		return Web
+	}
+	return WhatsApp
+}
+
+// Describe arma las líneas que identifican al cliente en un mensaje para el asesor
+func Describe(label string, id Identity) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Canal: %s", label)
+	if id.Name != "" {
+		fmt.Fprintf(&b, "\nNombre: %s", id.Name)
+	}
+	if id.Username != "" {
+		fmt.Fprintf(&b, "\nUsuario: @%s", id.Username)
+	}
+	if id.Phone != "" {
+		fmt.Fprintf(&b, "\nNúmero del cliente: %s", id.Phone)
+	} else {
+		fmt.Fprintf(&b, "\nID de chat: %s", id.ID)
+	}
+	return b.String()
+}
+
+// ButtonsAsText degrada una respuesta con botones a texto plano numerado,
+// para canales (o clientes) que no soportan botones
+func ButtonsAsText(text string, buttons []Button) string {
+	var b strings.Builder
+	b.WriteString(text)
+	for i, btn := range buttons {
+		fmt.Fprintf(&b, "\n%d. %s", i+1, btn.Title)
+	}
+	return b.String()
+}
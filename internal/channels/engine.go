@@ -0,0 +1,552 @@
+package channels
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+	"github.com/alonsoalpizar/fabricalaser/internal/tenant"
+)
+
+// ─── Interfaces — permiten tests sin dependencias reales ─────────────────────
+
+// Store define las operaciones Redis que necesita el motor.
+type Store interface {
+	SetNX(ctx context.Context, key string, value string, ttl time.Duration) (bool, error)
+	Get(ctx context.Context, key string) (string, error)
+	Set(ctx context.Context, key string, value string, ttl time.Duration) error
+	Incr(ctx context.Context, key string) (int64, error)
+	Expire(ctx context.Context, key string, ttl time.Duration) error
+}
+
+// Archive persiste los turnos de conversación (whatsapp_conversations).
+type Archive interface {
+	SaveTurn(ctx context.Context, turn ArchivedTurn) error
+}
+
+// Agent es el agente conversacional (Gemini con tools).
+type Agent interface {
+	CallWithTools(ctx context.Context, from Identity, history []Turn, newMessage string, userCtx string) (string, error)
+	CallWithImage(ctx context.Context, from Identity, history []Turn, imageBytes []byte, mimeType string, caption string, userCtx string) (string, error)
+	SummarizeConversation(ctx context.Context, history []Turn) (string, error)
+}
+
+// Limiter es el rate limiter global de conversaciones nuevas por día.
+type Limiter interface {
+	Allow(ctx context.Context, key string) bool
+}
+
+// Settings expone la configuración operativa del tenant que el motor lee en cada mensaje.
+type Settings interface {
+	GetMaxMensajesDia(ctx context.Context) int
+	// GetAsesorPhone es el teléfono que se ofrece al cliente cuando el agente falla
+	GetAsesorPhone(ctx context.Context) string
+}
+
+// DocumentAnalyzer analiza archivos de diseño (SVG/DXF) que el cliente manda como documento
+// y recuerda la última imagen para que el agente pueda vectorizarla.
+// AnalyzeDocument retorna la respuesta para el cliente; error solo en fallas internas.
+type DocumentAnalyzer interface {
+	Supports(filename, mimeType string) bool
+	AnalyzeDocument(ctx context.Context, from Identity, data []byte, mimeType, filename string) (string, error)
+	RememberImage(ctx context.Context, from Identity, data []byte, mimeType string)
+}
+
+// Handoffs indica si la conversación está en manos de un asesor (escalada o tomada
+// desde el panel). Mientras tanto el motor no llama al agente: solo archiva lo que
+// manda el cliente para que el asesor lo vea en la bandeja.
+type Handoffs interface {
+	Paused(ctx context.Context, from Identity) bool
+}
+
+// Leads registra cada contacto en el embudo de ventas (primer contacto y actividad).
+type Leads interface {
+	Touch(ctx context.Context, from Identity)
+}
+
+// BotInbox indica si la conversación pasa por el embudo de leads y la bandeja de
+// atención humana. Esas tablas cuelgan de conversation_key sin tenant y solo
+// atienden al tenant 1 (ver migración 045): en los demás talleres el bot
+// responde siempre y el asesor se entera por su canal.
+func BotInbox(ctx context.Context) bool {
+	return tenant.ID(ctx) == models.DefaultTenantID
+}
+
+// Memory entrega el resumen de las conversaciones anteriores del contacto
+// (preferencias, productos, materiales, pendientes) para sumarlo a DATOS DEL
+// CLIENTE. Retorna "" si no hay memoria.
+type Memory interface {
+	Context(ctx context.Context, from Identity) string
+}
+
+// ─── Modelos de datos ────────────────────────────────────────────────────────
+
+// Turn representa un turno en el historial de conversación (formato Vertex AI).
+type Turn struct {
+	Role    string `json:"role"` // "user" o "model"
+	Content string `json:"content"`
+}
+
+// ArchivedTurn es lo que se persiste en PostgreSQL como archivo.
+// Phone guarda Identity.Key: el número en WhatsApp, "tg:<chat_id>" en Telegram.
+type ArchivedTurn struct {
+	Phone     string    `json:"phone"`
+	Role      string    `json:"role"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ─── TTLs y límites ──────────────────────────────────────────────────────────
+
+const (
+	sessionTTL       = 4 * time.Hour
+	deduplicationTTL = 60 * time.Second
+	optinTTL         = 30 * 24 * time.Hour
+	maxHistoryTurns  = 50
+	warningRemaining = 2
+)
+
+const (
+	msgTechError  = "En este momento tengo un problema técnico. Por favor intentá de nuevo en unos segundos."
+	msgImageError = "No pude procesar la imagen. ¿Me podés describir qué querés hacer?"
+	msgImageAgent = "No pude analizar la imagen. ¿Me podés describir qué querés hacer?"
+	imageTurn     = "[El cliente mandó una imagen]"
+
+	msgDocumentUnsupported = "Por ahora solo puedo leer archivos de diseño *SVG* o *DXF*. " +
+		"Si tenés el diseño en otro formato, exportalo a SVG o DXF, o mandame una foto."
+	msgDocumentError = "No pude procesar el archivo. ¿Me lo podés mandar de nuevo?"
+	documentTurn     = "[El cliente mandó el archivo %s]"
+)
+
+// ─── Engine ──────────────────────────────────────────────────────────────────
+
+// Engine orquesta el flujo completo para cualquier canal:
+// deduplicación → rate limit → lead → opt-in → asesor a cargo → límite diario → historial → agente → responder → archivar
+type Engine struct {
+	store     Store
+	archive   Archive
+	agent     Agent
+	limiter   Limiter
+	settings  Settings
+	registry  *Registry
+	documents DocumentAnalyzer
+	handoffs  Handoffs
+	leads     Leads
+	memory    Memory
+}
+
+// NewEngine construye el motor con sus dependencias.
+// limiter puede ser nil — en ese caso el rate limiting queda deshabilitado (fail open).
+// documents puede ser nil — los documentos se responden como formato no soportado.
+// handoffs puede ser nil — el bot responde siempre.
+// leads puede ser nil — los contactos no se registran en el embudo.
+// memory puede ser nil — el agente solo ve la sesión en curso.
+func NewEngine(store Store, archive Archive, agent Agent, limiter Limiter, settings Settings, registry *Registry, documents DocumentAnalyzer, handoffs Handoffs, leads Leads, memory Memory) *Engine {
+	return &Engine{
+		store:     store,
+		archive:   archive,
+		agent:     agent,
+		limiter:   limiter,
+		settings:  settings,
+		registry:  registry,
+		documents: documents,
+		handoffs:  handoffs,
+		leads:     leads,
+		memory:    memory,
+	}
+}
+
+// Handle procesa un mensaje entrante normalizado. Los errores se registran en el log:
+// el webhook ya respondió al proveedor y no hay a quién devolvérselos.
+func (e *Engine) Handle(ctx context.Context, ch Channel, msg Message) {
+	isImage := msg.Media != nil && msg.Media.Kind == MediaImage
+	isDocument := msg.Media != nil && msg.Media.Kind == MediaDocument
+	if !isImage && !isDocument && msg.Text == "" {
+		slog.Info("channels: tipo de mensaje ignorado", "canal", ch.Name(), "from", msg.From.ID)
+		return
+	}
+
+	if err := e.process(ctx, ch, msg, isImage); err != nil {
+		slog.Error("channels: error procesando mensaje",
+			"canal", ch.Name(),
+			"error", err,
+			"message_id", msg.ID,
+			"from", msg.From.ID,
+		)
+	}
+}
+
+func (e *Engine) process(ctx context.Context, ch Channel, msg Message, isImage bool) error {
+	from := msg.From
+	ns := ch.Namespace()
+	receivedAt := time.Now()
+
+	// 1. Deduplicación — los proveedores pueden reenviar el mismo webhook
+	isNew, err := e.store.SetNX(ctx, stateKey(ctx, ns, "dedup", msg.ID), "1", deduplicationTTL)
+	if err != nil {
+		return fmt.Errorf("process: error verificando deduplicación: %w", err)
+	}
+	if !isNew {
+		slog.Info("channels: mensaje duplicado ignorado", "canal", ch.Name(), "message_id", msg.ID)
+		return nil
+	}
+
+	// 2. Rate limiting — solo cuenta conversaciones nuevas del día
+	if e.limiter != nil && !e.limiter.Allow(ctx, from.Key) {
+		slog.Warn("channels: mensaje descartado por rate limiter", "canal", ch.Name(), "from", from.ID)
+		return nil
+	}
+
+	slog.Info("channels: procesando mensaje",
+		"canal", ch.Name(),
+		"from", from.ID,
+		"message_id", msg.ID,
+		"imagen", isImage,
+		"length", len(msg.Text),
+	)
+
+	// Embudo de ventas: cuenta también los mensajes que atiende un asesor
+	if e.leads != nil && BotInbox(ctx) {
+		e.leads.Touch(ctx, from)
+	}
+
+	// 3. Opt-in — disclaimer de privacidad en primer contacto (1 vez cada 30 días)
+	if first, _ := e.store.SetNX(ctx, stateKey(ctx, ns, "optin", from.ID), "1", optinTTL); first {
+		_ = ch.SendText(ctx, from, e.shop(ctx).optin())
+	}
+
+	// 4. Conversación en manos de un asesor: se archiva sin llamar al agente
+	if e.handoffs != nil && BotInbox(ctx) && e.handoffs.Paused(ctx, from) {
+		slog.Info("channels: conversación atendida por asesor, bot en pausa", "canal", ch.Name(), "from", from.ID)
+		go e.saveTurnsAsync(context.WithoutCancel(ctx), ns, from.Key, receivedAt, Turn{Role: "user", Content: customerTurn(msg)})
+		return nil
+	}
+
+	// 5. Límite diario de mensajes (el asesor está exento; las imágenes cuentan igual)
+	count, limitErr := e.checkDailyLimit(ctx, ns, from.Key)
+	isAsesor := ch.IsAsesor(ctx, from)
+	maxMsgs := e.settings.GetMaxMensajesDia(ctx)
+	if limitErr != nil {
+		slog.Warn("channels: error verificando límite diario, continuando normalmente", "canal", ch.Name(), "error", limitErr)
+	} else if !isAsesor && count > int64(maxMsgs) {
+		slog.Info("channels: límite diario alcanzado", "canal", ch.Name(), "from", from.ID, "count", count, "max", maxMsgs)
+		_ = ch.SendText(ctx, from, e.shop(ctx).limit())
+		e.notifyAsesorLimit(ctx, ch, from, maxMsgs)
+		return nil
+	}
+
+	// 6. Documentos: archivos de diseño van al analizador, sin pasar por el agente
+	if msg.Media != nil && msg.Media.Kind == MediaDocument {
+		return e.processDocument(ctx, ch, from, msg.Media, receivedAt)
+	}
+
+	// 7. Descargar la imagen antes de gastar una llamada al agente
+	var imageBytes []byte
+	var mimeType string
+	if isImage {
+		imageBytes, mimeType, err = ch.DownloadMedia(ctx, msg.Media)
+		if err != nil {
+			_ = ch.SendText(ctx, from, msgImageError)
+			return fmt.Errorf("process: error descargando imagen: %w", err)
+		}
+		if e.documents != nil {
+			e.documents.RememberImage(ctx, from, imageBytes, mimeType)
+		}
+	}
+
+	// 8. Historial de la sesión activa y contexto del cliente
+	history, err := e.loadHistory(ctx, ns, from.Key)
+	if err != nil {
+		slog.Warn("channels: no se pudo cargar historial, continuando sin él", "canal", ch.Name(), "error", err)
+		history = []Turn{}
+	}
+	userCtx := ch.UserContext(ctx, from)
+	if e.memory != nil {
+		userCtx += e.memory.Context(ctx, from)
+	}
+
+	// 9. Llamar al agente
+	var response, userTurn string
+	if isImage {
+		userTurn = imageTurn
+		response, err = e.agent.CallWithImage(ctx, from, history, imageBytes, mimeType, msg.Media.Caption, userCtx)
+		if err != nil {
+			_ = ch.SendText(ctx, from, msgImageAgent)
+			return fmt.Errorf("process: error llamando al agente con imagen: %w", err)
+		}
+	} else {
+		userTurn = customerTurn(msg)
+		response, err = e.agent.CallWithTools(ctx, from, history, userTurn, userCtx)
+		if err != nil {
+			// Mensaje amigable en lugar de dejar al cliente en silencio;
+			// el teléfono de la tienda solo se ofrece en canales telefónicos
+			techMsg := msgTechError
+			if from.Phone != "" {
+				techMsg += e.shop(ctx).phoneHint()
+			}
+			_ = ch.SendText(ctx, from, techMsg)
+			return fmt.Errorf("process: error llamando al agente: %w", err)
+		}
+	}
+
+	// Aviso preventivo cuando quedan 2 mensajes automáticos (exento si es el asesor)
+	if limitErr == nil && !isAsesor && count == int64(maxMsgs-warningRemaining) {
+		response += "\n\n(Nota: te quedan 2 consultas automáticas por hoy. " +
+			"Si necesitás más ayuda, un asesor puede atenderte.)"
+	}
+
+	// 10. Responder por el mismo canal
+	if err := ch.SendText(ctx, from, response); err != nil {
+		if errors.Is(err, ErrRateLimited) {
+			// Límite del proveedor — se registra pero no es un error del flujo
+			slog.Error("channels: límite del proveedor — respuesta no enviada", "canal", ch.Name(), "from", from.ID)
+			return nil
+		}
+		return fmt.Errorf("process: error enviando respuesta: %w", err)
+	}
+
+	// 11. Historial Redis + archivo en PostgreSQL (async)
+	go e.saveTurnsAsync(context.WithoutCancel(ctx), ns, from.Key, receivedAt,
+		Turn{Role: "user", Content: userTurn},
+		Turn{Role: "model", Content: response},
+	)
+
+	return nil
+}
+
+// customerTurn es el texto con que el mensaje del cliente queda en el historial
+func customerTurn(msg Message) string {
+	switch {
+	case msg.Media != nil && msg.Media.Kind == MediaImage:
+		return imageTurn
+	case msg.Media != nil && msg.Media.Kind == MediaDocument:
+		turn := fmt.Sprintf(documentTurn, msg.Media.Filename)
+		if msg.Media.Caption != "" {
+			turn += " " + msg.Media.Caption
+		}
+		return turn
+	case msg.Choice != nil && msg.Choice.ID != "":
+		// El agente recibe el ID de la opción para no tener que adivinarlo desde el título
+		return fmt.Sprintf("%s [opción elegida: %s]", msg.Text, msg.Choice.ID)
+	}
+	return msg.Text
+}
+
+// processDocument analiza un SVG/DXF y responde con la geometría y el precio de
+// referencia. El turno queda en el historial para que el agente pueda seguir la
+// conversación (cambiar material, cantidad, etc.) sobre el mismo archivo.
+func (e *Engine) processDocument(ctx context.Context, ch Channel, from Identity, media *Media, receivedAt time.Time) error {
+	if e.documents == nil || !e.documents.Supports(media.Filename, media.MimeType) {
+		_ = ch.SendText(ctx, from, msgDocumentUnsupported)
+		return nil
+	}
+
+	data, mimeType, err := ch.DownloadMedia(ctx, media)
+	if err != nil {
+		_ = ch.SendText(ctx, from, msgDocumentError)
+		return fmt.Errorf("processDocument: error descargando documento: %w", err)
+	}
+	if media.MimeType != "" {
+		// El mime del webhook es más confiable que el inferido en la descarga
+		mimeType = media.MimeType
+	}
+
+	response, err := e.documents.AnalyzeDocument(ctx, from, data, mimeType, media.Filename)
+	if err != nil {
+		_ = ch.SendText(ctx, from, msgDocumentError)
+		return fmt.Errorf("processDocument: error analizando documento: %w", err)
+	}
+
+	if err := ch.SendText(ctx, from, response); err != nil {
+		if errors.Is(err, ErrRateLimited) {
+			slog.Error("channels: límite del proveedor — respuesta no enviada", "canal", ch.Name(), "from", from.ID)
+			return nil
+		}
+		return fmt.Errorf("processDocument: error enviando respuesta: %w", err)
+	}
+
+	userTurn := customerTurn(Message{Media: media})
+	go e.saveTurnsAsync(context.WithoutCancel(ctx), ch.Namespace(), from.Key, receivedAt,
+		Turn{Role: "user", Content: userTurn},
+		Turn{Role: "model", Content: response},
+	)
+	return nil
+}
+
+// notifyAsesorLimit avisa al asesor, con un resumen de la conversación, que un
+// cliente alcanzó el límite diario. Usa el canal del cliente si el asesor está ahí.
+func (e *Engine) notifyAsesorLimit(ctx context.Context, ch Channel, from Identity, maxMsgs int) {
+	resumenConversacion := ""
+	if history, _ := e.loadHistory(ctx, ch.Namespace(), from.Key); len(history) > 0 {
+		if s, err := e.agent.SummarizeConversation(ctx, history); err == nil {
+			resumenConversacion = "\n\nResumen de la conversación:\n" + s
+		}
+	}
+
+	titulo := fmt.Sprintf("Límite alcanzado (%s)", ch.Label())
+	if name := e.shop(ctx).name; name != "" {
+		titulo = name + " — " + titulo
+	}
+	resumen := fmt.Sprintf(
+		"%s\n\n"+
+			"⚠️ Cliente alcanzó el límite de %d mensajes hoy.\n"+
+			"Requiere atención humana para completar su consulta.\n\n"+
+			"%s%s",
+		titulo, maxMsgs, Describe(ch.Label(), from), resumenConversacion)
+
+	if err := e.registry.NotifyAsesor(ctx, ch.Name(), resumen); err != nil {
+		slog.Error("channels: error notificando límite al asesor", "canal", ch.Name(), "from", from.ID, "error", err)
+	}
+}
+
+// ─── Datos del taller ────────────────────────────────────────────────────────
+
+// shop son los datos del taller (tenant de ctx) que aparecen en los mensajes
+// fijos al cliente y al asesor
+type shop struct {
+	name  string // Nombre comercial del branding
+	site  string // Primer host del taller, para el link de privacidad
+	phone string // Teléfono del asesor
+}
+
+func (e *Engine) shop(ctx context.Context) shop {
+	t := tenant.Lookup(tenant.ID(ctx))
+	s := shop{name: t.Brand().DisplayName, phone: e.settings.GetAsesorPhone(ctx)}
+	if hosts := t.HostList(); len(hosts) > 0 {
+		s.site = hosts[0]
+	}
+	return s
+}
+
+// optin es el aviso de privacidad del primer contacto
+func (s shop) optin() string {
+	with := "nosotros"
+	if s.name != "" {
+		with = s.name
+	}
+	msg := fmt.Sprintf("Al comunicarte con %s por este canal, aceptás que "+
+		"procesemos tu número, nombre y mensajes para brindarte cotizaciones "+
+		"y atención al cliente.", with)
+	if s.site != "" {
+		msg += " Más info: " + s.site + "/privacidad"
+	}
+	return msg
+}
+
+// limit es la respuesta al cliente que alcanzó el límite diario
+func (s shop) limit() string {
+	asesor := "Un asesor"
+	if s.name != "" {
+		asesor += " de " + s.name
+	}
+	return "Hemos alcanzado el límite de mensajes automáticos por hoy. " +
+		asesor + " te va a contactar para ayudarte. ¡Gracias por tu paciencia!"
+}
+
+// phoneHint ofrece el teléfono del asesor tras un error técnico; "" si no hay
+func (s shop) phoneHint() string {
+	if s.phone == "" {
+		return ""
+	}
+	return " También podés escribirnos al " + s.phone + "."
+}
+
+// ─── Historial Redis ─────────────────────────────────────────────────────────
+
+// stateKey arma la clave Redis del estado de una conversación. Lleva el tenant:
+// el mismo número puede escribirle a dos talleres y cada uno tiene su historial,
+// su límite diario, su opt-in y su deduplicación.
+func stateKey(ctx context.Context, ns, kind, key string) string {
+	return fmt.Sprintf("%s:%s:%d:%s", ns, kind, tenant.ID(ctx), key)
+}
+
+func (e *Engine) loadHistory(ctx context.Context, ns, key string) ([]Turn, error) {
+	return loadHistory(ctx, e.store, ns, key)
+}
+
+func loadHistory(ctx context.Context, store Store, ns, key string) ([]Turn, error) {
+	raw, err := store.Get(ctx, stateKey(ctx, ns, "hist", key))
+	if err != nil {
+		return []Turn{}, nil
+	}
+
+	var history []Turn
+	if err := json.Unmarshal([]byte(raw), &history); err != nil {
+		return nil, fmt.Errorf("loadHistory: error deserializando historial: %w", err)
+	}
+
+	if len(history) > maxHistoryTurns {
+		history = history[len(history)-maxHistoryTurns:]
+	}
+	return history, nil
+}
+
+// AppendHistory agrega turnos al historial de la sesión activa. Lo usa el motor y
+// también quien escribe en la conversación por fuera del agente (el asesor desde
+// el panel), para que el bot tenga el contexto cuando retome.
+func AppendHistory(ctx context.Context, store Store, ns, key string, turns ...Turn) error {
+	history, err := loadHistory(ctx, store, ns, key)
+	if err != nil {
+		history = []Turn{}
+	}
+	history = append(history, turns...)
+
+	raw, err := json.Marshal(history)
+	if err != nil {
+		return fmt.Errorf("AppendHistory: error serializando: %w", err)
+	}
+	if err := store.Set(ctx, stateKey(ctx, ns, "hist", key), string(raw), sessionTTL); err != nil {
+		return fmt.Errorf("AppendHistory: error guardando en Redis: %w", err)
+	}
+	return nil
+}
+
+// saveTurnsAsync archiva los turnos del cliente con la hora en que llegó el mensaje
+// y los del bot con la hora de la respuesta: la diferencia es la latencia del bot.
+func (e *Engine) saveTurnsAsync(ctx context.Context, ns, key string, receivedAt time.Time, turns ...Turn) {
+	if err := AppendHistory(ctx, e.store, ns, key, turns...); err != nil {
+		slog.Error("channels: error actualizando historial en Redis", "error", err, "key", key)
+	}
+
+	now := time.Now()
+	for _, turn := range turns {
+		at := now
+		if turn.Role == "user" {
+			at = receivedAt
+		}
+		archived := ArchivedTurn{Phone: key, Role: turn.Role, Content: turn.Content, CreatedAt: at}
+		if err := e.archive.SaveTurn(ctx, archived); err != nil {
+			slog.Error("channels: error archivando turno en PostgreSQL",
+				"error", err,
+				"key", key,
+				"role", turn.Role,
+			)
+		}
+	}
+}
+
+// ─── Límite diario ───────────────────────────────────────────────────────────
+
+// checkDailyLimit incrementa el contador diario del contacto y retorna el valor actual.
+// La clave expira a medianoche hora Costa Rica.
+func (e *Engine) checkDailyLimit(ctx context.Context, ns, key string) (int64, error) {
+	loc, _ := time.LoadLocation("America/Costa_Rica")
+	now := time.Now().In(loc)
+	limitKey := stateKey(ctx, ns, "limit", key+":"+now.Format("2006-01-02"))
+
+	count, err := e.store.Incr(ctx, limitKey)
+	if err != nil {
+		return 0, fmt.Errorf("checkDailyLimit: error incrementando contador: %w", err)
+	}
+
+	// Setear TTL solo en el primer mensaje del día
+	if count == 1 {
+		midnight := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, loc)
+		_ = e.store.Expire(ctx, limitKey, midnight.Sub(now))
+	}
+	return count, nil
+}
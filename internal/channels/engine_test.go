@@ -0,0 +1,344 @@
+package channels
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/tenant"
+)
+
+type memStore struct {
+	mu   sync.Mutex
+	data map[string]string
+	nums map[string]int64
+}
+
+func newMemStore() *memStore {
+	return &memStore{data: map[string]string{}, nums: map[string]int64{}}
+}
+
+func (s *memStore) SetNX(_ context.Context, key, value string, _ time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.data[key]; ok {
+		return false, nil
+	}
+	s.data[key] = value
+	return true, nil
+}
+
+func (s *memStore) Get(_ context.Context, key string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if v, ok := s.data[key]; ok {
+		return v, nil
+	}
+	return "", errors.New("not found")
+}
+
+func (s *memStore) Set(_ context.Context, key, value string, _ time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data[key] = value
+	return nil
+}
+
+func (s *memStore) Incr(_ context.Context, key string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nums[key]++
+	return s.nums[key], nil
+}
+
+func (s *memStore) Expire(context.Context, string, time.Duration) error { return nil }
+
+type nopArchive struct{}
+
+func (nopArchive) SaveTurn(context.Context, ArchivedTurn) error { return nil }
+
+type echoAgent struct{ from []Identity }
+
+func (a *echoAgent) CallWithTools(_ context.Context, from Identity, _ []Turn, msg, _ string) (string, error) {
+	a.from = append(a.from, from)
+	return "eco: " + msg, nil
+}
+
+func (a *echoAgent) CallWithImage(_ context.Context, from Identity, _ []Turn, _ []byte, mimeType, _, _ string) (string, error) {
+	return "imagen " + mimeType, nil
+}
+
+func (a *echoAgent) SummarizeConversation(context.Context, []Turn) (string, error) {
+	return "resumen", nil
+}
+
+type fixedSettings int
+
+func (s fixedSettings) GetMaxMensajesDia(context.Context) int { return int(s) }
+func (s fixedSettings) GetAsesorPhone(context.Context) string { return "+50670000000" }
+
+type sent struct{ to, text string }
+
+type fakeChannel struct {
+	name, ns string
+	asesor   string
+	sent     []sent
+}
+
+func (c *fakeChannel) Name() string      { return c.name }
+func (c *fakeChannel) Label() string     { return strings.ToUpper(c.name) }
+func (c *fakeChannel) Namespace() string { return c.ns }
+func (c *fakeChannel) SendText(_ context.Context, to Identity, text string) error {
+	c.sent = append(c.sent, sent{to.ID, text})
+	return nil
+}
+func (c *fakeChannel) SendImage(context.Context, Identity, []byte, string, string) error {
+	return ErrUnsupported
+}
+func (c *fakeChannel) SendDocument(context.Context, Identity, []byte, string, string, string) error {
+	return ErrUnsupported
+}
+func (c *fakeChannel) SendButtons(ctx context.Context, to Identity, text string, buttons []Button) error {
+	return c.SendText(ctx, to, ButtonsAsText(text, buttons))
+}
+func (c *fakeChannel) DownloadMedia(context.Context, *Media) ([]byte, string, error) {
+	return []byte{0xff}, "image/png", nil
+}
+func (c *fakeChannel) Asesor(context.Context) (Identity, bool) {
+	return Identity{Channel: c.name, ID: c.asesor}, c.asesor != ""
+}
+func (c *fakeChannel) IsAsesor(_ context.Context, id Identity) bool {
+	return c.asesor != "" && id.ID == c.asesor
+}
+func (c *fakeChannel) UserContext(context.Context, Identity) string {
+	return ""
+}
+
+func TestEngineHandle(t *testing.T) {
+	ctx := context.Background()
+	store := newMemStore()
+	agent := &echoAgent{}
+	wa := &fakeChannel{name: WhatsApp, ns: "wa", asesor: "50670000000"}
+	tg := &fakeChannel{name: Telegram, ns: "tg"} // sin asesor: las notificaciones caen a WhatsApp
+	engine := NewEngine(store, nopArchive{}, agent, nil, fixedSettings(3), NewRegistry(wa, tg), nil, nil, nil, nil)
+
+	from := Identity{Channel: Telegram, ID: "42", Key: "tg:42", Name: "Ana"}
+	msg := func(id, text string) Message { return Message{ID: id, From: from, Text: text} }
+
+	// Primer mensaje: disclaimer + respuesta (con aviso: con límite 3 quedan 2)
+	engine.Handle(ctx, tg, msg("1", "hola"))
+	if len(tg.sent) != 2 || tg.sent[0].text != engine.shop(ctx).optin() {
+		t.Fatalf("sent = %+v, want opt-in + reply", tg.sent)
+	}
+	if reply := tg.sent[1].text; !strings.HasPrefix(reply, "eco: hola") || !strings.Contains(reply, "te quedan 2") {
+		t.Fatalf("reply = %q", reply)
+	}
+	if len(agent.from) != 1 || agent.from[0].Key != "tg:42" {
+		t.Fatalf("agent identity = %+v", agent.from)
+	}
+
+	// Duplicado: ignorado
+	engine.Handle(ctx, tg, msg("1", "hola"))
+	if len(tg.sent) != 2 {
+		t.Fatalf("duplicate was processed: %+v", tg.sent)
+	}
+
+	// Imagen: se descarga por el canal y va al agente de imágenes
+	engine.Handle(ctx, tg, Message{ID: "2", From: from, Media: &Media{Kind: MediaImage, ID: "f1"}})
+	if last := tg.sent[len(tg.sent)-1].text; last != "imagen image/png" {
+		t.Fatalf("image reply = %q", last)
+	}
+
+	// Tercer mensaje llega al límite; el cuarto lo excede y avisa al asesor por WhatsApp
+	engine.Handle(ctx, tg, msg("3", "tres"))
+	engine.Handle(ctx, tg, msg("4", "cuatro"))
+	if last := tg.sent[len(tg.sent)-1].text; last != engine.shop(ctx).limit() {
+		t.Fatalf("last message = %q, want limit notice", last)
+	}
+	if len(wa.sent) != 1 || wa.sent[0].to != "50670000000" {
+		t.Fatalf("asesor notifications = %+v", wa.sent)
+	}
+	if !strings.Contains(wa.sent[0].text, "Canal: TELEGRAM") || !strings.Contains(wa.sent[0].text, "Nombre: Ana") {
+		t.Errorf("asesor notification = %q", wa.sent[0].text)
+	}
+
+	// Mensajes sin texto ni imagen no llegan al agente
+	calls := len(agent.from)
+	engine.Handle(ctx, tg, Message{ID: "5", From: from})
+	if len(agent.from) != calls {
+		t.Errorf("empty message reached the agent")
+	}
+}
+
+func TestEngineChoice(t *testing.T) {
+	agent := &echoAgent{}
+	ch := &fakeChannel{name: WhatsApp, ns: "wa"}
+	engine := NewEngine(newMemStore(), nopArchive{}, agent, nil, fixedSettings(50), NewRegistry(ch), nil, nil, nil, nil)
+
+	from := Identity{Channel: WhatsApp, ID: "50688887777", Key: "50688887777", Phone: "50688887777"}
+	engine.Handle(context.Background(), ch, Message{
+		ID:     "m1",
+		From:   from,
+		Text:   "Acrílico",
+		Choice: &Button{ID: "material_id=4", Title: "Acrílico"},
+	})
+
+	want := "eco: Acrílico [opción elegida: material_id=4]"
+	if got := ch.sent[len(ch.sent)-1].text; got != want {
+		t.Errorf("reply = %q, want %q", got, want)
+	}
+}
+
+type fakeDocuments struct{ got, image []byte }
+
+func (d *fakeDocuments) Supports(filename, _ string) bool { return strings.HasSuffix(filename, ".dxf") }
+
+func (d *fakeDocuments) AnalyzeDocument(_ context.Context, _ Identity, data []byte, _, filename string) (string, error) {
+	d.got = data
+	return "analizado " + filename, nil
+}
+
+func (d *fakeDocuments) RememberImage(_ context.Context, _ Identity, data []byte, _ string) {
+	d.image = data
+}
+
+func TestEngineDocument(t *testing.T) {
+	agent := &echoAgent{}
+	docs := &fakeDocuments{}
+	ch := &fakeChannel{name: WhatsApp, ns: "wa"}
+	engine := NewEngine(newMemStore(), nopArchive{}, agent, nil, fixedSettings(50), NewRegistry(ch), docs, nil, nil, nil)
+
+	from := Identity{Channel: WhatsApp, ID: "50688887777", Key: "50688887777", Phone: "50688887777"}
+	doc := func(id, filename string) Message {
+		return Message{ID: id, From: from, Media: &Media{Kind: MediaDocument, ID: "d" + id, Filename: filename}}
+	}
+
+	// DXF: se descarga por el canal y responde el analizador, no el agente
+	engine.Handle(context.Background(), ch, doc("1", "pieza.dxf"))
+	if got := ch.sent[len(ch.sent)-1].text; got != "analizado pieza.dxf" || len(docs.got) == 0 {
+		t.Fatalf("reply = %q, downloaded %d bytes", got, len(docs.got))
+	}
+
+	// Formato no soportado: aviso sin descargar
+	docs.got = nil
+	engine.Handle(context.Background(), ch, doc("2", "cotizacion.pdf"))
+	if got := ch.sent[len(ch.sent)-1].text; got != msgDocumentUnsupported || docs.got != nil {
+		t.Errorf("reply = %q", got)
+	}
+	if len(agent.from) != 0 {
+		t.Errorf("documents reached the agent: %+v", agent.from)
+	}
+
+	// Las imágenes van al agente y quedan guardadas para vectorizar
+	engine.Handle(context.Background(), ch, Message{ID: "3", From: from, Media: &Media{Kind: MediaImage, ID: "i3"}})
+	if len(docs.image) == 0 {
+		t.Error("image was not remembered")
+	}
+}
+
+type fakeHandoffs map[string]bool
+
+type countingLeads map[string]int
+
+func (l countingLeads) Touch(_ context.Context, from Identity) { l[from.Key]++ }
+
+func (h fakeHandoffs) Paused(_ context.Context, from Identity) bool { return h[from.Key] }
+
+func TestEngineHandoffPausesBot(t *testing.T) {
+	agent := &echoAgent{}
+	ch := &fakeChannel{name: WhatsApp, ns: "wa"}
+	handoffs := fakeHandoffs{}
+	leads := countingLeads{}
+	engine := NewEngine(newMemStore(), nopArchive{}, agent, nil, fixedSettings(50), NewRegistry(ch), nil, handoffs, leads, nil)
+
+	from := Identity{Channel: WhatsApp, ID: "50688887777", Key: "50688887777", Phone: "50688887777"}
+	engine.Handle(context.Background(), ch, Message{ID: "1", From: from, Text: "hola"})
+	replies := len(ch.sent)
+
+	// Con un asesor a cargo el mensaje no llega al agente ni recibe respuesta automática
+	handoffs[from.Key] = true
+	engine.Handle(context.Background(), ch, Message{ID: "2", From: from, Text: "¿sigue ahí?"})
+	if len(agent.from) != 1 || len(ch.sent) != replies {
+		t.Errorf("bot answered during handoff: %d agent calls, %+v", len(agent.from), ch.sent)
+	}
+
+	// Al devolver la conversación el bot retoma
+	handoffs[from.Key] = false
+	engine.Handle(context.Background(), ch, Message{ID: "3", From: from, Text: "gracias"})
+	if len(agent.from) != 2 {
+		t.Errorf("bot did not resume: %d agent calls", len(agent.from))
+	}
+
+	// El embudo cuenta todos los mensajes, también los que atendió el asesor
+	if leads[from.Key] != 3 {
+		t.Errorf("lead touched %d times, want 3", leads[from.Key])
+	}
+}
+
+func TestEngineSkipsBotInboxForOtherTenants(t *testing.T) {
+	agent := &echoAgent{}
+	ch := &fakeChannel{name: WhatsApp, ns: "wa"}
+	from := Identity{Channel: WhatsApp, ID: "50688887777", Key: "50688887777", Phone: "50688887777"}
+	handoffs := fakeHandoffs{from.Key: true}
+	leads := countingLeads{}
+	engine := NewEngine(newMemStore(), nopArchive{}, agent, nil, fixedSettings(50), NewRegistry(ch), nil, handoffs, leads, nil)
+
+	// La pausa y el embudo son del tenant 1: en otro taller el bot responde
+	engine.Handle(tenant.WithID(context.Background(), 2), ch, Message{ID: "1", From: from, Text: "hola"})
+	if len(agent.from) != 1 || leads[from.Key] != 0 {
+		t.Errorf("tenant 2: %d agent calls, %d lead touches; want 1, 0", len(agent.from), leads[from.Key])
+	}
+}
+
+func TestEngineStateIsPerTenant(t *testing.T) {
+	agent := &echoAgent{}
+	ch := &fakeChannel{name: WhatsApp, ns: "wa"}
+	engine := NewEngine(newMemStore(), nopArchive{}, agent, nil, fixedSettings(1), NewRegistry(ch), nil, nil, nil, nil)
+
+	from := Identity{Channel: WhatsApp, ID: "50688887777", Key: "50688887777", Phone: "50688887777"}
+	shopA, shopB := tenant.WithID(context.Background(), 1), tenant.WithID(context.Background(), 2)
+	engine.Handle(shopA, ch, Message{ID: "1", From: from, Text: "hola"})
+
+	// El mismo número en otro taller: ni la deduplicación, ni el opt-in, ni el
+	// límite diario del primero cuentan
+	engine.Handle(shopB, ch, Message{ID: "1", From: from, Text: "hola"})
+	if len(agent.from) != 2 {
+		t.Errorf("agent calls = %d, want 2 (one per tenant)", len(agent.from))
+	}
+	optins := 0
+	for _, m := range ch.sent {
+		if strings.Contains(m.text, "aceptás") {
+			optins++
+		}
+	}
+	if optins != 2 {
+		t.Errorf("opt-in sent %d times, want once per tenant: %+v", optins, ch.sent)
+	}
+	if count, _ := engine.checkDailyLimit(shopB, "wa", from.Key); count != 2 {
+		t.Errorf("daily count in tenant 2 = %d, want 2", count)
+	}
+}
+
+func TestShopMessages(t *testing.T) {
+	s := shop{name: "Taller Sur", site: "tallersur.cr", phone: "+50622223333"}
+	if msg := s.optin(); !strings.Contains(msg, "con Taller Sur por este canal") || !strings.HasSuffix(msg, "tallersur.cr/privacidad") {
+		t.Errorf("optin = %q", msg)
+	}
+	if msg := s.limit(); !strings.Contains(msg, "Un asesor de Taller Sur te va a contactar") {
+		t.Errorf("limit = %q", msg)
+	}
+	if hint := s.phoneHint(); hint != " También podés escribirnos al +50622223333." {
+		t.Errorf("phoneHint = %q", hint)
+	}
+
+	// Sin branding ni teléfono los mensajes no nombran a ningún taller
+	var empty shop
+	for _, msg := range []string{empty.optin(), empty.limit(), empty.phoneHint()} {
+		if strings.Contains(msg, "FabricaLaser") || strings.Contains(msg, "privacidad") {
+			t.Errorf("mensaje sin branding = %q", msg)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package channels
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// Registry agrupa los canales activos y enruta las notificaciones al asesor
+type Registry struct {
+	fallback string
+	channels map[string]Channel
+}
+
+// NewRegistry registra los canales; el primero es el canal por defecto del asesor
+// (se usa cuando el canal del cliente no tiene asesor configurado o falla el envío).
+func NewRegistry(channels ...Channel) *Registry {
+	r := &Registry{channels: make(map[string]Channel, len(channels))}
+	for i, ch := range channels {
+		if i == 0 {
+			r.fallback = ch.Name()
+		}
+		r.channels[ch.Name()] = ch
+	}
+	return r
+}
+
+// Get retorna el canal por nombre
+func (r *Registry) Get(name string) (Channel, bool) {
+	ch, ok := r.channels[name]
+	return ch, ok
+}
+
+// Label retorna el nombre para mostrar del canal, o el nombre crudo si no está registrado
+func (r *Registry) Label(name string) string {
+	if ch, ok := r.channels[name]; ok {
+		return ch.Label()
+	}
+	return name
+}
+
+// NotifyAsesor envía text al asesor por el canal preferido (normalmente el del
+// cliente) y, si ahí no está configurado o falla, por el canal por defecto.
+// Sin canal preferido se usa directamente el canal por defecto.
+func (r *Registry) NotifyAsesor(ctx context.Context, preferred, text string) error {
+	if preferred == "" {
+		preferred = r.fallback
+	}
+	if err := r.sendToAsesor(ctx, preferred, text); err == nil {
+		return nil
+	} else if preferred == r.fallback {
+		return err
+	} else {
+		slog.Warn("channels: no se pudo notificar al asesor por el canal del cliente, usando canal por defecto",
+			"canal", preferred,
+			"fallback", r.fallback,
+			"error", err,
+		)
+	}
+	return r.sendToAsesor(ctx, r.fallback, text)
+}
+
+func (r *Registry) sendToAsesor(ctx context.Context, name, text string) error {
+	ch, ok := r.channels[name]
+	if !ok {
+		return fmt.Errorf("channels: canal %q no registrado", name)
+	}
+	asesor, ok := ch.Asesor(ctx)
+	if !ok {
+		return fmt.Errorf("channels: asesor no configurado en %s", ch.Label())
+	}
+	if err := ch.SendText(ctx, asesor, text); err != nil {
+		return fmt.Errorf("channels: error notificando al asesor por %s: %w", ch.Label(), err)
+	}
+	return nil
+}
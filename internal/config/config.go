@@ -30,6 +30,30 @@ type Config struct {
 	// GoMeta API (Cedula Validation)
 	GoMetaTimeout          int  // Timeout in seconds for GoMeta API calls
 	GoMetaRequireValidation bool // If true, registration fails when GoMeta is offline
+
+	// Facturación electrónica (Hacienda v4.4)
+	FEAmbiente           string // "stag" (pruebas) o "prod"
+	FEP12Path            string // Llave criptográfica .p12 descargada de ATV
+	FEP12Pin             string
+	FEATVUser            string // Usuario API de ATV (cpf-XX-XXXX-XXXX@stag.comprobanteselectronicos.go.cr)
+	FEATVPassword        string
+	FEEmisorNombre       string
+	FEEmisorNombreComer  string
+	FEEmisorTipoID       string // 01 física, 02 jurídica
+	FEEmisorCedula       string
+	FEEmisorActividad    string // Código de actividad económica (6 dígitos)
+	FEEmisorProvincia    string
+	FEEmisorCanton       string
+	FEEmisorDistrito     string
+	FEEmisorOtrasSenas   string
+	FEEmisorCorreo       string
+	FEEmisorTelefono     string
+	FEProveedorSistemas  string // Cédula del proveedor del sistema (default: emisor)
+	FESucursal           int
+	FETerminal           int
+
+	// Pagos con tarjeta (pasarela con checkout hospedado + webhook firmado)
+	PayCardWebhookSecret string // HMAC-SHA256 del header X-Signature
 }
 
 var cfg *Config
@@ -43,6 +67,8 @@ func Load() *Config {
 	maxFileSize, _ := strconv.ParseInt(getEnv("FABRICALASER_MAX_FILE_SIZE", "10485760"), 10, 64)
 	goMetaTimeout, _ := strconv.Atoi(getEnv("FABRICALASER_GOMETA_TIMEOUT", "10"))
 	goMetaRequire := getEnv("FABRICALASER_GOMETA_REQUIRE_VALIDATION", "false") == "true"
+	feSucursal, _ := strconv.Atoi(getEnv("FABRICALASER_FE_SUCURSAL", "1"))
+	feTerminal, _ := strconv.Atoi(getEnv("FABRICALASER_FE_TERMINAL", "1"))
 
 	cfg = &Config{
 		Port:        getEnv("FABRICALASER_PORT", "8083"),
@@ -64,6 +90,28 @@ func Load() *Config {
 
 		GoMetaTimeout:           goMetaTimeout,
 		GoMetaRequireValidation: goMetaRequire,
+
+		FEAmbiente:          getEnv("FABRICALASER_FE_AMBIENTE", "stag"),
+		FEP12Path:           getEnv("FABRICALASER_FE_P12_PATH", ""),
+		FEP12Pin:            getEnv("FABRICALASER_FE_P12_PIN", ""),
+		FEATVUser:           getEnv("FABRICALASER_FE_ATV_USER", ""),
+		FEATVPassword:       getEnv("FABRICALASER_FE_ATV_PASSWORD", ""),
+		FEEmisorNombre:      getEnv("FABRICALASER_FE_EMISOR_NOMBRE", ""),
+		FEEmisorNombreComer: getEnv("FABRICALASER_FE_EMISOR_NOMBRE_COMERCIAL", "FabricaLaser"),
+		FEEmisorTipoID:      getEnv("FABRICALASER_FE_EMISOR_TIPO_ID", "01"),
+		FEEmisorCedula:      getEnv("FABRICALASER_FE_EMISOR_CEDULA", ""),
+		FEEmisorActividad:   getEnv("FABRICALASER_FE_EMISOR_ACTIVIDAD", ""),
+		FEEmisorProvincia:   getEnv("FABRICALASER_FE_EMISOR_PROVINCIA", ""),
+		FEEmisorCanton:      getEnv("FABRICALASER_FE_EMISOR_CANTON", ""),
+		FEEmisorDistrito:    getEnv("FABRICALASER_FE_EMISOR_DISTRITO", ""),
+		FEEmisorOtrasSenas:  getEnv("FABRICALASER_FE_EMISOR_OTRAS_SENAS", ""),
+		FEEmisorCorreo:      getEnv("FABRICALASER_FE_EMISOR_CORREO", ""),
+		FEEmisorTelefono:    getEnv("FABRICALASER_FE_EMISOR_TELEFONO", ""),
+		FEProveedorSistemas: getEnv("FABRICALASER_FE_PROVEEDOR_SISTEMAS", ""),
+		FESucursal:          feSucursal,
+		FETerminal:          feTerminal,
+
+		PayCardWebhookSecret: getEnv("FABRICALASER_PAY_CARD_WEBHOOK_SECRET", ""),
 	}
 
 	return cfg
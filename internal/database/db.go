@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/alonsoalpizar/fabricalaser/internal/config"
+	"github.com/alonsoalpizar/fabricalaser/internal/tenant"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
@@ -36,6 +37,11 @@ func Connect() (*gorm.DB, error) {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
 
+	// Aislamiento por tenant_id en todos los repositorios
+	if err := tenant.Setup(db); err != nil {
+		return nil, fmt.Errorf("failed to register tenant plugin: %w", err)
+	}
+
 	sqlDB, err := db.DB()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get sql.DB: %w", err)
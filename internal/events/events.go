@@ -0,0 +1,99 @@
+// Package events es el bus de eventos de dominio en proceso. Los handlers publican
+// lo que pasó (cotización creada, aprobada, pedido actualizado) sin saber quién
+// escucha; los suscriptores (notificaciones, webhooks) reaccionan en su propia
+// goroutine, así una falla o demora nunca afecta la respuesta HTTP. El contexto
+// que recibe cada suscriptor trae el tenant del evento.
+package events
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/tenant"
+)
+
+// Tipos de evento
+const (
+	QuoteCreated       = "quote.created"
+	QuoteApproved      = "quote.approved"
+	QuoteRejected      = "quote.rejected"
+	QuoteExpiring      = "quote.expiring"
+	OrderStatusChanged = "order.status_changed"
+)
+
+// Event es un hecho de dominio sobre un cliente y, normalmente, una cotización
+type Event struct {
+	Type       string
+	TenantID   uint // Taller de la cotización (0 = por defecto)
+	UserID     uint
+	QuoteID    uint
+	Status     string // Estado nuevo (order.status_changed)
+	Note       string // Notas del revisor o motivo
+	OccurredAt time.Time
+}
+
+// Handler reacciona a un evento
+type Handler func(ctx context.Context, ev Event)
+
+type subscription struct {
+	types   map[string]bool // vacío = todos los tipos
+	handler Handler
+}
+
+// Bus entrega cada evento a los suscriptores de su tipo
+type Bus struct {
+	mu   sync.RWMutex
+	subs []subscription
+}
+
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscribe registra handler para los tipos indicados (sin tipos: todos)
+func (b *Bus) Subscribe(handler Handler, types ...string) {
+	sub := subscription{types: make(map[string]bool, len(types)), handler: handler}
+	for _, t := range types {
+		sub.types[t] = true
+	}
+	b.mu.Lock()
+	b.subs = append(b.subs, sub)
+	b.mu.Unlock()
+}
+
+// Publish entrega el evento a cada suscriptor en su propia goroutine
+func (b *Bus) Publish(ev Event) {
+	if ev.OccurredAt.IsZero() {
+		ev.OccurredAt = time.Now()
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, sub := range b.subs {
+		if len(sub.types) > 0 && !sub.types[ev.Type] {
+			continue
+		}
+		go func(h Handler) {
+			defer func() {
+				if r := recover(); r != nil {
+					slog.Error("events: panic en suscriptor", "tipo", ev.Type, "panic", r)
+				}
+			}()
+			h(tenant.WithID(context.Background(), ev.TenantID), ev)
+		}(sub.handler)
+	}
+}
+
+// Default es el bus del proceso
+var Default = NewBus()
+
+// Publish publica en el bus del proceso
+func Publish(ev Event) {
+	Default.Publish(ev)
+}
+
+// Subscribe se suscribe al bus del proceso
+func Subscribe(handler Handler, types ...string) {
+	Default.Subscribe(handler, types...)
+}
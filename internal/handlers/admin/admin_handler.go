@@ -5,11 +5,14 @@ import (
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/alonsoalpizar/fabricalaser/internal/database"
+	"github.com/alonsoalpizar/fabricalaser/internal/events"
 	"github.com/alonsoalpizar/fabricalaser/internal/models"
 	"github.com/alonsoalpizar/fabricalaser/internal/repository"
+	"github.com/alonsoalpizar/fabricalaser/internal/services/facturacion"
 	"github.com/alonsoalpizar/fabricalaser/internal/utils"
 	"github.com/go-chi/chi/v5"
 	"gorm.io/datatypes"
@@ -68,7 +71,7 @@ func (h *AdminHandler) CreateTechnology(w http.ResponseWriter, r *http.Request)
 		tech.Description = &req.Description
 	}
 
-	if err := h.techRepo.Create(tech); err != nil {
+	if err := h.techRepo.WithContext(r.Context()).Create(tech); err != nil {
 		respondError(w, http.StatusInternalServerError, "CREATE_ERROR", "Error al crear tecnología")
 		return
 	}
@@ -86,7 +89,7 @@ func (h *AdminHandler) UpdateTechnology(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	tech, err := h.techRepo.FindByID(uint(id))
+	tech, err := h.techRepo.WithContext(r.Context()).FindByID(uint(id))
 	if err != nil {
 		respondError(w, http.StatusNotFound, "NOT_FOUND", "Tecnología no encontrada")
 		return
@@ -114,7 +117,7 @@ func (h *AdminHandler) UpdateTechnology(w http.ResponseWriter, r *http.Request)
 		tech.IsActive = *req.IsActive
 	}
 
-	if err := h.techRepo.Update(tech); err != nil {
+	if err := h.techRepo.WithContext(r.Context()).Update(tech); err != nil {
 		respondError(w, http.StatusInternalServerError, "UPDATE_ERROR", "Error al actualizar tecnología")
 		return
 	}
@@ -132,7 +135,7 @@ func (h *AdminHandler) DeleteTechnology(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if err := h.techRepo.Delete(uint(id)); err != nil {
+	if err := h.techRepo.WithContext(r.Context()).Delete(uint(id)); err != nil {
 		respondError(w, http.StatusInternalServerError, "DELETE_ERROR", "Error al eliminar tecnología")
 		return
 	}
@@ -152,6 +155,7 @@ func (h *AdminHandler) CreateMaterial(w http.ResponseWriter, r *http.Request) {
 		Factor      float64 `json:"factor"`
 		Thicknesses []int   `json:"thicknesses"`
 		Notes       string  `json:"notes"`
+		CabysCode   string  `json:"cabys_code"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		respondError(w, http.StatusBadRequest, "INVALID_JSON", "JSON inválido")
@@ -175,8 +179,15 @@ func (h *AdminHandler) CreateMaterial(w http.ResponseWriter, r *http.Request) {
 	if req.Notes != "" {
 		material.Notes = &req.Notes
 	}
+	if req.CabysCode != "" {
+		if !facturacion.IsValidCabys(req.CabysCode) {
+			respondError(w, http.StatusBadRequest, "INVALID_CABYS", "El código CABYS debe tener 13 dígitos")
+			return
+		}
+		material.CabysCode = &req.CabysCode
+	}
 
-	if err := h.materialRepo.Create(material); err != nil {
+	if err := h.materialRepo.WithContext(r.Context()).Create(material); err != nil {
 		respondError(w, http.StatusInternalServerError, "CREATE_ERROR", "Error al crear material")
 		return
 	}
@@ -194,7 +205,7 @@ func (h *AdminHandler) UpdateMaterial(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	material, err := h.materialRepo.FindByID(uint(id))
+	material, err := h.materialRepo.WithContext(r.Context()).FindByID(uint(id))
 	if err != nil {
 		respondError(w, http.StatusNotFound, "NOT_FOUND", "Material no encontrado")
 		return
@@ -207,6 +218,7 @@ func (h *AdminHandler) UpdateMaterial(w http.ResponseWriter, r *http.Request) {
 		Thicknesses []int   `json:"thicknesses"`
 		Notes       string  `json:"notes"`
 		IsActive    *bool   `json:"is_active"`
+		CabysCode   *string `json:"cabys_code"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		respondError(w, http.StatusBadRequest, "INVALID_JSON", "JSON inválido")
@@ -232,8 +244,18 @@ func (h *AdminHandler) UpdateMaterial(w http.ResponseWriter, r *http.Request) {
 	if req.IsActive != nil {
 		material.IsActive = *req.IsActive
 	}
+	if req.CabysCode != nil {
+		if *req.CabysCode == "" {
+			material.CabysCode = nil
+		} else if !facturacion.IsValidCabys(*req.CabysCode) {
+			respondError(w, http.StatusBadRequest, "INVALID_CABYS", "El código CABYS debe tener 13 dígitos")
+			return
+		} else {
+			material.CabysCode = req.CabysCode
+		}
+	}
 
-	if err := h.materialRepo.Update(material); err != nil {
+	if err := h.materialRepo.WithContext(r.Context()).Update(material); err != nil {
 		respondError(w, http.StatusInternalServerError, "UPDATE_ERROR", "Error al actualizar material")
 		return
 	}
@@ -251,7 +273,7 @@ func (h *AdminHandler) DeleteMaterial(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.materialRepo.Delete(uint(id)); err != nil {
+	if err := h.materialRepo.WithContext(r.Context()).Delete(uint(id)); err != nil {
 		respondError(w, http.StatusInternalServerError, "DELETE_ERROR", "Error al eliminar material")
 		return
 	}
@@ -291,7 +313,7 @@ func (h *AdminHandler) CreateEngraveType(w http.ResponseWriter, r *http.Request)
 		engraveType.Description = &req.Description
 	}
 
-	if err := h.engraveRepo.Create(engraveType); err != nil {
+	if err := h.engraveRepo.WithContext(r.Context()).Create(engraveType); err != nil {
 		respondError(w, http.StatusInternalServerError, "CREATE_ERROR", "Error al crear tipo de grabado")
 		return
 	}
@@ -309,7 +331,7 @@ func (h *AdminHandler) UpdateEngraveType(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	engraveType, err := h.engraveRepo.FindByID(uint(id))
+	engraveType, err := h.engraveRepo.WithContext(r.Context()).FindByID(uint(id))
 	if err != nil {
 		respondError(w, http.StatusNotFound, "NOT_FOUND", "Tipo de grabado no encontrado")
 		return
@@ -343,7 +365,7 @@ func (h *AdminHandler) UpdateEngraveType(w http.ResponseWriter, r *http.Request)
 		engraveType.IsActive = *req.IsActive
 	}
 
-	if err := h.engraveRepo.Update(engraveType); err != nil {
+	if err := h.engraveRepo.WithContext(r.Context()).Update(engraveType); err != nil {
 		respondError(w, http.StatusInternalServerError, "UPDATE_ERROR", "Error al actualizar tipo de grabado")
 		return
 	}
@@ -361,7 +383,7 @@ func (h *AdminHandler) DeleteEngraveType(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if err := h.engraveRepo.Delete(uint(id)); err != nil {
+	if err := h.engraveRepo.WithContext(r.Context()).Delete(uint(id)); err != nil {
 		respondError(w, http.StatusInternalServerError, "DELETE_ERROR", "Error al eliminar tipo de grabado")
 		return
 	}
@@ -381,7 +403,7 @@ func (h *AdminHandler) UpdateTechRate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	rate, err := h.rateRepo.FindByID(uint(id))
+	rate, err := h.rateRepo.WithContext(r.Context()).FindByID(uint(id))
 	if err != nil {
 		respondError(w, http.StatusNotFound, "NOT_FOUND", "Tarifa no encontrada")
 		return
@@ -402,6 +424,7 @@ func (h *AdminHandler) UpdateTechRate(w http.ResponseWriter, r *http.Request) {
 		SeguroMes         *float64 `json:"seguro_mes"`
 		ConsumiblesMes    *float64 `json:"consumibles_mes"`
 		IsActive          *bool    `json:"is_active"`
+		Currency          *string  `json:"currency"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		respondError(w, http.StatusBadRequest, "INVALID_JSON", "JSON inválido")
@@ -449,8 +472,15 @@ func (h *AdminHandler) UpdateTechRate(w http.ResponseWriter, r *http.Request) {
 	if req.IsActive != nil {
 		rate.IsActive = *req.IsActive
 	}
+	if req.Currency != nil {
+		if !models.IsSupportedCurrency(*req.Currency) {
+			respondError(w, http.StatusBadRequest, "INVALID_CURRENCY", "Moneda no soportada (CRC o USD)")
+			return
+		}
+		rate.Currency = models.NormalizeCurrency(*req.Currency)
+	}
 
-	if err := h.rateRepo.Update(rate); err != nil {
+	if err := h.rateRepo.WithContext(r.Context()).Update(rate); err != nil {
 		respondError(w, http.StatusInternalServerError, "UPDATE_ERROR", "Error al actualizar tarifa")
 		return
 	}
@@ -481,7 +511,7 @@ func (h *AdminHandler) CreateVolumeDiscount(w http.ResponseWriter, r *http.Reque
 		IsActive:    true,
 	}
 
-	if err := h.discountRepo.Create(discount); err != nil {
+	if err := h.discountRepo.WithContext(r.Context()).Create(discount); err != nil {
 		respondError(w, http.StatusInternalServerError, "CREATE_ERROR", "Error al crear descuento")
 		return
 	}
@@ -499,7 +529,7 @@ func (h *AdminHandler) UpdateVolumeDiscount(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	discount, err := h.discountRepo.FindByID(uint(id))
+	discount, err := h.discountRepo.WithContext(r.Context()).FindByID(uint(id))
 	if err != nil {
 		respondError(w, http.StatusNotFound, "NOT_FOUND", "Descuento no encontrado")
 		return
@@ -527,7 +557,7 @@ func (h *AdminHandler) UpdateVolumeDiscount(w http.ResponseWriter, r *http.Reque
 		discount.IsActive = *req.IsActive
 	}
 
-	if err := h.discountRepo.Update(discount); err != nil {
+	if err := h.discountRepo.WithContext(r.Context()).Update(discount); err != nil {
 		respondError(w, http.StatusInternalServerError, "UPDATE_ERROR", "Error al actualizar descuento")
 		return
 	}
@@ -545,7 +575,7 @@ func (h *AdminHandler) DeleteVolumeDiscount(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	if err := h.discountRepo.Delete(uint(id)); err != nil {
+	if err := h.discountRepo.WithContext(r.Context()).Delete(uint(id)); err != nil {
 		respondError(w, http.StatusInternalServerError, "DELETE_ERROR", "Error al eliminar descuento")
 		return
 	}
@@ -587,7 +617,7 @@ func (h *AdminHandler) CreatePriceReference(w http.ResponseWriter, r *http.Reque
 		ref.Description = &req.Description
 	}
 
-	if err := h.priceRefRepo.Create(ref); err != nil {
+	if err := h.priceRefRepo.WithContext(r.Context()).Create(ref); err != nil {
 		respondError(w, http.StatusInternalServerError, "CREATE_ERROR", "Error al crear referencia de precio")
 		return
 	}
@@ -605,7 +635,7 @@ func (h *AdminHandler) UpdatePriceReference(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	ref, err := h.priceRefRepo.FindByID(uint(id))
+	ref, err := h.priceRefRepo.WithContext(r.Context()).FindByID(uint(id))
 	if err != nil {
 		respondError(w, http.StatusNotFound, "NOT_FOUND", "Referencia no encontrada")
 		return
@@ -643,7 +673,7 @@ func (h *AdminHandler) UpdatePriceReference(w http.ResponseWriter, r *http.Reque
 		ref.IsActive = *req.IsActive
 	}
 
-	if err := h.priceRefRepo.Update(ref); err != nil {
+	if err := h.priceRefRepo.WithContext(r.Context()).Update(ref); err != nil {
 		respondError(w, http.StatusInternalServerError, "UPDATE_ERROR", "Error al actualizar referencia")
 		return
 	}
@@ -661,7 +691,7 @@ func (h *AdminHandler) DeletePriceReference(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	if err := h.priceRefRepo.Delete(uint(id)); err != nil {
+	if err := h.priceRefRepo.WithContext(r.Context()).Delete(uint(id)); err != nil {
 		respondError(w, http.StatusInternalServerError, "DELETE_ERROR", "Error al eliminar referencia")
 		return
 	}
@@ -694,7 +724,7 @@ func (h *AdminHandler) GetUsers(w http.ResponseWriter, r *http.Request) {
 		isActive = &val
 	}
 
-	users, total, err := h.userRepo.ListAll(limit, offset, search, role, isActive)
+	users, total, err := h.userRepo.WithContext(r.Context()).ListAll(limit, offset, search, role, isActive)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "LIST_ERROR", "Error al listar usuarios")
 		return
@@ -750,7 +780,7 @@ func (h *AdminHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Check if user exists
-	existing, _ := h.userRepo.FindByCedula(req.Cedula)
+	existing, _ := h.userRepo.WithContext(r.Context()).FindByCedula(req.Cedula)
 	if existing != nil {
 		respondError(w, http.StatusConflict, "USER_EXISTS", "Ya existe un usuario con esta cédula")
 		return
@@ -784,7 +814,7 @@ func (h *AdminHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 		user.PasswordHash = &hash
 	}
 
-	if err := h.userRepo.Create(user); err != nil {
+	if err := h.userRepo.WithContext(r.Context()).Create(user); err != nil {
 		respondError(w, http.StatusInternalServerError, "CREATE_ERROR", "Error al crear usuario")
 		return
 	}
@@ -806,7 +836,7 @@ func (h *AdminHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := h.userRepo.FindByID(uint(id))
+	user, err := h.userRepo.WithContext(r.Context()).FindByID(uint(id))
 	if err != nil {
 		respondError(w, http.StatusNotFound, "NOT_FOUND", "Usuario no encontrado")
 		return
@@ -820,12 +850,35 @@ func (h *AdminHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 		Role       string `json:"role"`
 		IsActive   *bool  `json:"is_active"`
 		QuoteLimit *int   `json:"quote_limit"`
+
+		// Exoneración de IVA. exoneracion_numero = "" la elimina.
+		ExoneracionNumero      *string  `json:"exoneracion_numero"`
+		ExoneracionTipo        *string  `json:"exoneracion_tipo"`
+		ExoneracionInstitucion *string  `json:"exoneracion_institucion"`
+		ExoneracionPct         *float64 `json:"exoneracion_pct"`
+		ExoneracionFecha       *string  `json:"exoneracion_fecha"` // YYYY-MM-DD
+		ExoneracionVence       *string  `json:"exoneracion_vence"` // YYYY-MM-DD
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		respondError(w, http.StatusBadRequest, "INVALID_JSON", "JSON inválido")
 		return
 	}
 
+	if req.ExoneracionPct != nil && (*req.ExoneracionPct < 0 || *req.ExoneracionPct > 100) {
+		respondError(w, http.StatusBadRequest, "INVALID_EXONERACION", "exoneracion_pct debe estar entre 0 y 100")
+		return
+	}
+	exFecha, err := parseOptionalDate(req.ExoneracionFecha)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "INVALID_EXONERACION", "exoneracion_fecha debe tener formato YYYY-MM-DD")
+		return
+	}
+	exVence, err := parseOptionalDate(req.ExoneracionVence)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "INVALID_EXONERACION", "exoneracion_vence debe tener formato YYYY-MM-DD")
+		return
+	}
+
 	if req.Nombre != "" {
 		user.Nombre = req.Nombre
 	}
@@ -853,7 +906,37 @@ func (h *AdminHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 		user.PasswordHash = &hash
 	}
 
-	if err := h.userRepo.Update(user); err != nil {
+	if req.ExoneracionNumero != nil {
+		if numero := strings.TrimSpace(*req.ExoneracionNumero); numero != "" {
+			user.ExoneracionNumero = &numero
+		} else {
+			user.ExoneracionNumero = nil
+			user.ExoneracionTipo = nil
+			user.ExoneracionInstitucion = nil
+			user.ExoneracionPct = 0
+			user.ExoneracionFecha = nil
+			user.ExoneracionVence = nil
+		}
+	}
+	if user.ExoneracionNumero != nil {
+		if req.ExoneracionTipo != nil {
+			user.ExoneracionTipo = req.ExoneracionTipo
+		}
+		if req.ExoneracionInstitucion != nil {
+			user.ExoneracionInstitucion = req.ExoneracionInstitucion
+		}
+		if req.ExoneracionPct != nil {
+			user.ExoneracionPct = *req.ExoneracionPct
+		}
+		if req.ExoneracionFecha != nil {
+			user.ExoneracionFecha = exFecha
+		}
+		if req.ExoneracionVence != nil {
+			user.ExoneracionVence = exVence
+		}
+	}
+
+	if err := h.userRepo.WithContext(r.Context()).Update(user); err != nil {
 		respondError(w, http.StatusInternalServerError, "UPDATE_ERROR", "Error al actualizar usuario")
 		return
 	}
@@ -875,7 +958,7 @@ func (h *AdminHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.userRepo.Delete(uint(id)); err != nil {
+	if err := h.userRepo.WithContext(r.Context()).Delete(uint(id)); err != nil {
 		respondError(w, http.StatusInternalServerError, "DELETE_ERROR", "Error al eliminar usuario")
 		return
 	}
@@ -905,7 +988,7 @@ func (h *AdminHandler) GetQuotes(w http.ResponseWriter, r *http.Request) {
 		sortOrder = "desc"
 	}
 
-	quotes, total, err := h.quoteRepo.ListAllAdmin(limit, offset, status, sortOrder)
+	quotes, total, err := h.quoteRepo.WithContext(r.Context()).ListAllAdmin(limit, offset, status, sortOrder)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "LIST_ERROR", "Error al listar cotizaciones")
 		return
@@ -917,6 +1000,7 @@ func (h *AdminHandler) GetQuotes(w http.ResponseWriter, r *http.Request) {
 		resp := map[string]interface{}{
 			"id":          q.ID,
 			"status":      q.Status,
+			"source":      q.Source,
 			"total_price": q.PriceFinal,
 			"quantity":    q.Quantity,
 			"created_at":  q.CreatedAt,
@@ -955,7 +1039,7 @@ func (h *AdminHandler) GetQuote(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	quote, err := h.quoteRepo.FindByIDWithRelations(uint(id))
+	quote, err := h.quoteRepo.WithContext(r.Context()).FindByIDWithRelations(uint(id))
 	if err != nil {
 		respondError(w, http.StatusNotFound, "NOT_FOUND", "Cotización no encontrada")
 		return
@@ -972,6 +1056,9 @@ func (h *AdminHandler) GetQuote(w http.ResponseWriter, r *http.Request) {
 		"discount_amount":  0, // Calculate if needed
 		"total_price":      quote.PriceFinal,
 		"admin_notes":      quote.ReviewNotes,
+		"source":           quote.Source,
+		"lead_id":          quote.LeadID,
+		"request_params":   quote.RequestParams,
 		"created_at":       quote.CreatedAt,
 		"updated_at":       quote.UpdatedAt,
 	}
@@ -1015,7 +1102,7 @@ func (h *AdminHandler) UpdateQuote(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	quote, err := h.quoteRepo.FindByID(uint(id))
+	quote, err := h.quoteRepo.WithContext(r.Context()).FindByID(uint(id))
 	if err != nil {
 		respondError(w, http.StatusNotFound, "NOT_FOUND", "Cotización no encontrada")
 		return
@@ -1030,17 +1117,24 @@ func (h *AdminHandler) UpdateQuote(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	prevStatus := quote.Status
 	if req.Status != "" {
 		quote.Status = models.QuoteStatus(req.Status)
 	}
+	// Un borrador del bot de un contacto sin cuenta no se puede aprobar: no hay a quién avisarle
+	if quote.UserID == 0 && quote.Status != prevStatus && quote.Status != models.QuoteStatusRejected {
+		respondError(w, http.StatusBadRequest, "USER_REQUIRED", "Asigná el cliente al borrador antes de aprobarlo")
+		return
+	}
 	if req.AdminNotes != "" {
 		quote.ReviewNotes = &req.AdminNotes
 	}
 
-	if err := h.quoteRepo.Update(quote); err != nil {
+	if err := h.quoteRepo.WithContext(r.Context()).Update(quote); err != nil {
 		respondError(w, http.StatusInternalServerError, "UPDATE_ERROR", "Error al actualizar cotización")
 		return
 	}
+	publishQuoteStatus(quote, prevStatus, req.AdminNotes)
 
 	respondJSON(w, http.StatusOK, map[string]interface{}{
 		"success": true,
@@ -1051,10 +1145,30 @@ func (h *AdminHandler) UpdateQuote(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// publishQuoteStatus avisa al cliente del cambio de estado. Una cotización
+// convertida es un pedido: entrar o salir de converted es un cambio del pedido.
+func publishQuoteStatus(quote *models.Quote, prev models.QuoteStatus, notes string) {
+	if quote.Status == prev || quote.UserID == 0 {
+		return
+	}
+	ev := events.Event{TenantID: quote.TenantID, UserID: quote.UserID, QuoteID: quote.ID, Status: string(quote.Status), Note: notes}
+	switch {
+	case prev == models.QuoteStatusConverted || quote.Status == models.QuoteStatusConverted:
+		ev.Type = events.OrderStatusChanged
+	case quote.Status == models.QuoteStatusApproved:
+		ev.Type = events.QuoteApproved
+	case quote.Status == models.QuoteStatusRejected:
+		ev.Type = events.QuoteRejected
+	default:
+		return
+	}
+	events.Publish(ev)
+}
+
 // ==================== TECH RATES (Admin) ====================
 
 func (h *AdminHandler) GetTechRates(w http.ResponseWriter, r *http.Request) {
-	rates, err := h.rateRepo.FindAll()
+	rates, err := h.rateRepo.WithContext(r.Context()).FindAll()
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "LIST_ERROR", "Error al listar tarifas")
 		return
@@ -1075,6 +1189,7 @@ func (h *AdminHandler) GetTechRates(w http.ResponseWriter, r *http.Request) {
 			"cost_per_min_cut":     rate.CostPerMinCut,
 			"margin_percent":       rate.MarginPercent,
 			"is_active":            rate.IsActive,
+			"currency":             rate.Currency,
 			// Costos fijos por máquina
 			"electricidad_mes":   rate.ElectricidadMes,
 			"mantenimiento_mes":  rate.MantenimientoMes,
@@ -1105,6 +1220,7 @@ func (h *AdminHandler) CreateTechRate(w http.ResponseWriter, r *http.Request) {
 		SeguroMes        float64 `json:"seguro_mes"`
 		ConsumiblesMes   float64 `json:"consumibles_mes"`
 		IsActive         bool    `json:"is_active"`
+		Currency         string  `json:"currency"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		respondError(w, http.StatusBadRequest, "INVALID_JSON", "JSON inválido")
@@ -1115,6 +1231,10 @@ func (h *AdminHandler) CreateTechRate(w http.ResponseWriter, r *http.Request) {
 		respondError(w, http.StatusBadRequest, "MISSING_FIELDS", "Technology es requerido")
 		return
 	}
+	if req.Currency != "" && !models.IsSupportedCurrency(req.Currency) {
+		respondError(w, http.StatusBadRequest, "INVALID_CURRENCY", "Moneda no soportada (CRC o USD)")
+		return
+	}
 
 	rate := &models.TechRate{
 		TechnologyID:      req.TechnologyID,
@@ -1132,9 +1252,10 @@ func (h *AdminHandler) CreateTechRate(w http.ResponseWriter, r *http.Request) {
 		SeguroMes:         req.SeguroMes,
 		ConsumiblesMes:    req.ConsumiblesMes,
 		IsActive:          req.IsActive,
+		Currency:          models.NormalizeCurrency(req.Currency),
 	}
 
-	if err := h.rateRepo.Create(rate); err != nil {
+	if err := h.rateRepo.WithContext(r.Context()).Create(rate); err != nil {
 		respondError(w, http.StatusInternalServerError, "CREATE_ERROR", "Error al crear tarifa")
 		return
 	}
@@ -1154,7 +1275,7 @@ func (h *AdminHandler) DeleteTechRate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.rateRepo.Delete(uint(id)); err != nil {
+	if err := h.rateRepo.WithContext(r.Context()).Delete(uint(id)); err != nil {
 		respondError(w, http.StatusInternalServerError, "DELETE_ERROR", "Error al eliminar tarifa")
 		return
 	}
@@ -1207,6 +1328,18 @@ func (h *AdminHandler) GetDashboardStats(w http.ResponseWriter, r *http.Request)
 	})
 }
 
+// parseOptionalDate parses a YYYY-MM-DD value; nil or "" yield nil
+func parseOptionalDate(s *string) (*time.Time, error) {
+	if s == nil || *s == "" {
+		return nil, nil
+	}
+	t, err := time.Parse("2006-01-02", *s)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
 func respondJSON(w http.ResponseWriter, status int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
@@ -0,0 +1,239 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/channels"
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+	"github.com/alonsoalpizar/fabricalaser/internal/repository"
+	"github.com/alonsoalpizar/fabricalaser/internal/services/analytics"
+)
+
+const (
+	// analyticsDays es el rango por defecto del dashboard
+	analyticsDays = 30
+	// maxRollupDays limita un recálculo manual para no bloquear la base
+	maxRollupDays = 92
+)
+
+// AnalyticsHandler expone la analítica de conversaciones del bot. Lee los rollups
+// diarios que arma el job nocturno; POST /analytics/rollup los recalcula a demanda.
+type AnalyticsHandler struct {
+	service *analytics.Service
+	repo    *repository.AnalyticsRepository
+}
+
+func NewAnalyticsHandler(service *analytics.Service) *AnalyticsHandler {
+	return &AnalyticsHandler{service: service, repo: repository.NewAnalyticsRepository()}
+}
+
+// GET /api/v1/admin/analytics/overview?from=2026-09-01&to=2026-09-30&channel=whatsapp
+// Mensajes, contactos, latencia, escalados, rechazos del límite diario y tools por
+// día y canal, más los totales del rango. Sin fechas: los últimos 30 días.
+func (h *AnalyticsHandler) GetOverview(w http.ResponseWriter, r *http.Request) {
+	from, to, channel, ok := h.analyticsRange(w, r)
+	if !ok {
+		return
+	}
+	rows, err := h.repo.FindStats(from, to, channel)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "DB_ERROR", "Error al obtener la analítica")
+		return
+	}
+	if rows == nil {
+		rows = []models.ConversationStatsDaily{}
+	}
+
+	var messages, contacts, responses, escalations, limitHits, toolCalls int64
+	var latency float64
+	for _, row := range rows {
+		messages += int64(row.Messages)
+		contacts += int64(row.Contacts)
+		responses += int64(row.Responses)
+		escalations += int64(row.Escalations)
+		limitHits += int64(row.LimitHits)
+		toolCalls += int64(row.ToolCalls)
+		latency += row.AvgResponseSecs * float64(row.Responses)
+	}
+	avgLatency := 0.0
+	if responses > 0 {
+		avgLatency = float64(int64(latency/float64(responses)*100)) / 100
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    rows,
+		"totals": map[string]interface{}{
+			"messages":          messages,
+			"contacts":          contacts, // Contactos por día sumados
+			"responses":         responses,
+			"avg_response_secs": avgLatency,
+			"escalations":       escalations,
+			"escalation_rate":   funnelRate(escalations, contacts),
+			"limit_hits":        limitHits,
+			"tool_calls":        toolCalls,
+		},
+		"from": from.Format("2006-01-02"),
+		"to":   to.Format("2006-01-02"),
+	})
+}
+
+// GET /api/v1/admin/analytics/tools?from=&to=&channel=
+func (h *AnalyticsHandler) GetToolUsage(w http.ResponseWriter, r *http.Request) {
+	from, to, channel, ok := h.analyticsRange(w, r)
+	if !ok {
+		return
+	}
+	rows, err := h.repo.ToolUsage(from, to, channel)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "DB_ERROR", "Error al obtener el uso de tools")
+		return
+	}
+	if rows == nil {
+		rows = []models.ToolUsage{}
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"success": true, "data": rows})
+}
+
+// GET /api/v1/admin/analytics/peak-hours?from=&to=&channel=
+// Mensajes de clientes por día de la semana (1 = lunes) y hora, hora Costa Rica.
+func (h *AnalyticsHandler) GetPeakHours(w http.ResponseWriter, r *http.Request) {
+	from, to, channel, ok := h.analyticsRange(w, r)
+	if !ok {
+		return
+	}
+	rows, err := h.repo.PeakHours(from, to, channel)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "DB_ERROR", "Error al obtener las horas pico")
+		return
+	}
+	if rows == nil {
+		rows = []models.HourUsage{}
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"success": true, "data": rows})
+}
+
+// GET /api/v1/admin/analytics/topics?kind=material&limit=10&from=&to=&channel=
+// Materiales y blanks que más nombran los clientes. Sin kind: ambos.
+func (h *AnalyticsHandler) GetTopics(w http.ResponseWriter, r *http.Request) {
+	from, to, channel, ok := h.analyticsRange(w, r)
+	if !ok {
+		return
+	}
+	kind := r.URL.Query().Get("kind")
+	switch kind {
+	case "", models.TopicMaterial, models.TopicBlank:
+	default:
+		respondError(w, http.StatusBadRequest, "INVALID_KIND", "kind debe ser material o blank")
+		return
+	}
+	limit := queryInt(r, "limit", 10)
+	if limit > 100 {
+		limit = 100
+	}
+
+	rows, err := h.repo.TopTopics(from, to, channel, kind, limit)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "DB_ERROR", "Error al obtener los temas")
+		return
+	}
+	if rows == nil {
+		rows = []models.TopicUsage{}
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"success": true, "data": rows})
+}
+
+// POST /api/v1/admin/analytics/rollup
+// Body opcional: {"from": "2026-09-01", "to": "2026-09-30"}. Sin fechas recalcula
+// hoy, que el job nocturno todavía no resumió.
+func (h *AnalyticsHandler) Rollup(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		From string `json:"from"`
+		To   string `json:"to"`
+	}
+	if r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, "INVALID_JSON", "JSON inválido")
+			return
+		}
+	}
+
+	to := h.service.Today()
+	if req.To != "" {
+		t, err := analytics.ParseDay(req.To)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "INVALID_DATE", "to debe tener formato YYYY-MM-DD")
+			return
+		}
+		to = t
+	}
+	from := to
+	if req.From != "" {
+		t, err := analytics.ParseDay(req.From)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "INVALID_DATE", "from debe tener formato YYYY-MM-DD")
+			return
+		}
+		from = t
+	}
+	if to.Before(from) {
+		respondError(w, http.StatusBadRequest, "INVALID_RANGE", "from debe ser anterior o igual a to")
+		return
+	}
+	if to.Sub(from) >= maxRollupDays*24*time.Hour {
+		respondError(w, http.StatusBadRequest, "INVALID_RANGE", "El recálculo admite hasta 92 días")
+		return
+	}
+
+	days, err := h.service.RollupRange(r.Context(), from, to)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "ROLLUP_ERROR", "Error al recalcular la analítica")
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data": map[string]interface{}{
+			"days": days,
+			"from": from.Format("2006-01-02"),
+			"to":   to.Format("2006-01-02"),
+		},
+	})
+}
+
+// analyticsRange lee from, to (inclusive, YYYY-MM-DD) y channel de la query
+func (h *AnalyticsHandler) analyticsRange(w http.ResponseWriter, r *http.Request) (time.Time, time.Time, string, bool) {
+	q := r.URL.Query()
+	channel := q.Get("channel")
+	switch channel {
+	case "", channels.WhatsApp, channels.Telegram, channels.Web:
+	default:
+		respondError(w, http.StatusBadRequest, "INVALID_CHANNEL", "channel debe ser whatsapp, telegram o web")
+		return time.Time{}, time.Time{}, "", false
+	}
+
+	to := h.service.Today()
+	if raw := q.Get("to"); raw != "" {
+		t, err := analytics.ParseDay(raw)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "INVALID_DATE", "to debe tener formato YYYY-MM-DD")
+			return time.Time{}, time.Time{}, "", false
+		}
+		to = t
+	}
+	from := to.AddDate(0, 0, 1-analyticsDays)
+	if raw := q.Get("from"); raw != "" {
+		t, err := analytics.ParseDay(raw)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "INVALID_DATE", "from debe tener formato YYYY-MM-DD")
+			return time.Time{}, time.Time{}, "", false
+		}
+		from = t
+	}
+	if to.Before(from) {
+		respondError(w, http.StatusBadRequest, "INVALID_RANGE", "from debe ser anterior o igual a to")
+		return time.Time{}, time.Time{}, "", false
+	}
+	return from, to, channel, true
+}
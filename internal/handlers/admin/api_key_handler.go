@@ -0,0 +1,190 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+	"github.com/alonsoalpizar/fabricalaser/internal/repository"
+	"github.com/alonsoalpizar/fabricalaser/internal/services/apikeys"
+	"github.com/go-chi/chi/v5"
+)
+
+// APIKeyHandler gestiona las API keys de los clientes B2B de la API pública:
+// emisión, edición, rotación, revocación y consumo.
+type APIKeyHandler struct {
+	keys     *apikeys.Service
+	userRepo *repository.UserRepository
+}
+
+func NewAPIKeyHandler(keys *apikeys.Service) *APIKeyHandler {
+	return &APIKeyHandler{keys: keys, userRepo: repository.NewUserRepository()}
+}
+
+// rotateRequest es el periodo de gracia de la key anterior; nil = 24 horas
+type rotateRequest struct {
+	GraceHours *int `json:"grace_hours"`
+}
+
+// GET /api/v1/admin/api-keys
+func (h *APIKeyHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.keys.List(r.Context())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "DB_ERROR", err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]any{"keys": keys, "scopes": models.APIScopes, "total": len(keys)})
+}
+
+// POST /api/v1/admin/api-keys
+// La key en claro solo viaja en esta respuesta.
+func (h *APIKeyHandler) Create(w http.ResponseWriter, r *http.Request) {
+	in, ok := h.keyInput(w, r)
+	if !ok {
+		return
+	}
+	key, raw, err := h.keys.Create(r.Context(), in, adminUserID(r))
+	if err != nil {
+		respondAPIKeyError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusCreated, map[string]any{
+		"key":     key,
+		"api_key": raw,
+		"message": "Guardá la key ahora: no se vuelve a mostrar",
+	})
+}
+
+// PUT /api/v1/admin/api-keys/{id}
+func (h *APIKeyHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id, ok := apiKeyID(w, r)
+	if !ok {
+		return
+	}
+	in, ok := h.keyInput(w, r)
+	if !ok {
+		return
+	}
+	key, err := h.keys.Update(r.Context(), id, in)
+	if err != nil {
+		respondAPIKeyError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]any{"key": key})
+}
+
+// POST /api/v1/admin/api-keys/{id}/rotate
+// Emite la key de reemplazo; la anterior sigue valiendo grace_hours.
+func (h *APIKeyHandler) Rotate(w http.ResponseWriter, r *http.Request) {
+	id, ok := apiKeyID(w, r)
+	if !ok {
+		return
+	}
+	var req rotateRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, "INVALID_BODY", err.Error())
+			return
+		}
+	}
+	grace := apikeys.DefaultRotateGrace
+	if req.GraceHours != nil {
+		if *req.GraceHours < 0 || *req.GraceHours > 24*30 {
+			respondError(w, http.StatusBadRequest, "INVALID_GRACE", "grace_hours debe estar entre 0 y 720")
+			return
+		}
+		grace = time.Duration(*req.GraceHours) * time.Hour
+	}
+
+	key, raw, err := h.keys.Rotate(r.Context(), id, grace, adminUserID(r))
+	if err != nil {
+		respondAPIKeyError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusCreated, map[string]any{
+		"key":     key,
+		"api_key": raw,
+		"message": "Guardá la key ahora: no se vuelve a mostrar",
+	})
+}
+
+// POST /api/v1/admin/api-keys/{id}/revoke
+func (h *APIKeyHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	id, ok := apiKeyID(w, r)
+	if !ok {
+		return
+	}
+	key, err := h.keys.Revoke(r.Context(), id)
+	if err != nil {
+		respondAPIKeyError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]any{"key": key})
+}
+
+// GET /api/v1/admin/api-keys/{id}/usage
+// Uso diario por endpoint de los últimos 30 días.
+func (h *APIKeyHandler) GetUsage(w http.ResponseWriter, r *http.Request) {
+	id, ok := apiKeyID(w, r)
+	if !ok {
+		return
+	}
+	key, usage, err := h.keys.Usage(r.Context(), id)
+	if err != nil {
+		respondAPIKeyError(w, err)
+		return
+	}
+	requests, failed := 0, 0
+	for _, u := range usage {
+		requests += u.Requests
+		failed += u.Errors
+	}
+	respondJSON(w, http.StatusOK, map[string]any{
+		"key":      key,
+		"usage":    usage,
+		"requests": requests,
+		"errors":   failed,
+	})
+}
+
+// keyInput lee el cuerpo de una key y verifica que la cuenta exista en el taller
+func (h *APIKeyHandler) keyInput(w http.ResponseWriter, r *http.Request) (apikeys.KeyInput, bool) {
+	var in apikeys.KeyInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		respondError(w, http.StatusBadRequest, "INVALID_BODY", err.Error())
+		return in, false
+	}
+	if in.UserID != 0 {
+		if _, err := h.userRepo.WithContext(r.Context()).FindByID(in.UserID); err != nil {
+			respondError(w, http.StatusBadRequest, "USER_NOT_FOUND", "La cuenta del cliente no existe")
+			return in, false
+		}
+	}
+	return in, true
+}
+
+func apiKeyID(w http.ResponseWriter, r *http.Request) (uint, bool) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "INVALID_ID", "ID inválido")
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func respondAPIKeyError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, repository.ErrAPIKeyNotFound):
+		respondError(w, http.StatusNotFound, "NOT_FOUND", err.Error())
+	case errors.Is(err, apikeys.ErrNoName), errors.Is(err, apikeys.ErrNoOwner), errors.Is(err, apikeys.ErrUnknownOwner),
+		errors.Is(err, apikeys.ErrNoScopes), errors.Is(err, apikeys.ErrInvalidScope), errors.Is(err, apikeys.ErrInvalidLimit):
+		respondError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error())
+	case errors.Is(err, apikeys.ErrNotActive):
+		respondError(w, http.StatusConflict, "NOT_ACTIVE", err.Error())
+	default:
+		respondError(w, http.StatusInternalServerError, "DB_ERROR", err.Error())
+	}
+}
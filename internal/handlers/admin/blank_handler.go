@@ -1,29 +1,41 @@
 package admin
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
-	"sort"
 	"strconv"
+	"time"
 
+	"github.com/alonsoalpizar/fabricalaser/internal/database"
 	"github.com/alonsoalpizar/fabricalaser/internal/models"
 	"github.com/alonsoalpizar/fabricalaser/internal/repository"
+	"github.com/alonsoalpizar/fabricalaser/internal/services/blankconfig"
+	"github.com/alonsoalpizar/fabricalaser/internal/services/pricing"
+	"github.com/alonsoalpizar/fabricalaser/internal/services/tax"
 	"github.com/go-chi/chi/v5"
 )
 
 // BlankHandler gestiona el CRUD admin de blanks y el endpoint público
 // de consulta usado por el agente de WhatsApp.
 type BlankHandler struct {
-	repo *repository.BlankRepository
+	repo         *repository.BlankRepository
+	configLoader *pricing.ConfigLoader
+	configurator *blankconfig.Configurator
 }
 
 func NewBlankHandler() *BlankHandler {
-	return &BlankHandler{repo: repository.NewBlankRepository()}
+	return &BlankHandler{
+		repo:         repository.NewBlankRepository(),
+		configLoader: pricing.NewConfigLoader(database.Get()),
+		configurator: blankconfig.NewConfigurator(),
+	}
 }
 
 // GetAll retorna todos los blanks (activos e inactivos) para el panel admin.
 func (h *BlankHandler) GetAll(w http.ResponseWriter, r *http.Request) {
-	blanks, err := h.repo.FindAllAdmin()
+	blanks, err := h.repo.WithContext(r.Context()).FindAllAdmin()
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "DB_ERROR", err.Error())
 		return
@@ -52,7 +64,7 @@ func (h *BlankHandler) Create(w http.ResponseWriter, r *http.Request) {
 		blank.Aliases = []byte("[]")
 	}
 	blank.IsActive = true
-	if err := h.repo.Create(&blank); err != nil {
+	if err := h.repo.WithContext(r.Context()).Create(&blank); err != nil {
 		respondError(w, http.StatusInternalServerError, "DB_ERROR", err.Error())
 		return
 	}
@@ -66,7 +78,7 @@ func (h *BlankHandler) Update(w http.ResponseWriter, r *http.Request) {
 		respondError(w, http.StatusBadRequest, "INVALID_ID", "ID inválido")
 		return
 	}
-	existing, err := h.repo.FindByID(uint(id))
+	existing, err := h.repo.WithContext(r.Context()).FindByID(uint(id))
 	if err != nil {
 		respondError(w, http.StatusNotFound, "NOT_FOUND", "Blank no encontrado")
 		return
@@ -77,6 +89,10 @@ func (h *BlankHandler) Update(w http.ResponseWriter, r *http.Request) {
 		respondError(w, http.StatusBadRequest, "INVALID_BODY", err.Error())
 		return
 	}
+	if updates.MaxSides < 0 || updates.MaxSides > 2 {
+		respondError(w, http.StatusBadRequest, "VALIDATION_ERROR", "max_sides debe ser 1 o 2")
+		return
+	}
 
 	existing.Name = updates.Name
 	existing.Category = updates.Category
@@ -88,6 +104,14 @@ func (h *BlankHandler) Update(w http.ResponseWriter, r *http.Request) {
 	existing.StockQty = updates.StockQty
 	existing.StockAlert = updates.StockAlert
 	existing.IsActive = updates.IsActive
+	existing.MaterialID = updates.MaterialID
+	existing.TechnologyID = updates.TechnologyID
+	existing.Thickness = updates.Thickness
+	existing.EngraveMaxAltoCM = updates.EngraveMaxAltoCM
+	existing.EngraveMaxAnchoCM = updates.EngraveMaxAnchoCM
+	if updates.MaxSides > 0 {
+		existing.MaxSides = updates.MaxSides
+	}
 	if updates.PriceBreaks != nil {
 		existing.PriceBreaks = updates.PriceBreaks
 	}
@@ -98,7 +122,7 @@ func (h *BlankHandler) Update(w http.ResponseWriter, r *http.Request) {
 		existing.Aliases = updates.Aliases
 	}
 
-	if err := h.repo.Update(existing); err != nil {
+	if err := h.repo.WithContext(r.Context()).Update(existing); err != nil {
 		respondError(w, http.StatusInternalServerError, "DB_ERROR", err.Error())
 		return
 	}
@@ -112,7 +136,7 @@ func (h *BlankHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		respondError(w, http.StatusBadRequest, "INVALID_ID", "ID inválido")
 		return
 	}
-	if err := h.repo.Delete(uint(id)); err != nil {
+	if err := h.repo.WithContext(r.Context()).Delete(uint(id)); err != nil {
 		respondError(w, http.StatusInternalServerError, "DB_ERROR", err.Error())
 		return
 	}
@@ -139,11 +163,11 @@ func (h *BlankHandler) UpdateStock(w http.ResponseWriter, r *http.Request) {
 		respondError(w, http.StatusBadRequest, "INVALID_OPERATION", "operation debe ser 'add' o 'set'")
 		return
 	}
-	if err := h.repo.UpdateStock(uint(id), body.Qty, body.Operation); err != nil {
+	if err := h.repo.WithContext(r.Context()).UpdateStock(uint(id), body.Qty, body.Operation); err != nil {
 		respondError(w, http.StatusInternalServerError, "DB_ERROR", err.Error())
 		return
 	}
-	blank, _ := h.repo.FindByID(uint(id))
+	blank, _ := h.repo.WithContext(r.Context()).FindByID(uint(id))
 	respondJSON(w, http.StatusOK, map[string]any{"stock_qty": blank.StockQty})
 }
 
@@ -154,11 +178,11 @@ func (h *BlankHandler) ToggleFeatured(w http.ResponseWriter, r *http.Request) {
 		respondError(w, http.StatusBadRequest, "INVALID_ID", "ID inválido")
 		return
 	}
-	if err := h.repo.ToggleFeatured(uint(id)); err != nil {
+	if err := h.repo.WithContext(r.Context()).ToggleFeatured(uint(id)); err != nil {
 		respondError(w, http.StatusInternalServerError, "DB_ERROR", err.Error())
 		return
 	}
-	blank, _ := h.repo.FindByID(uint(id))
+	blank, _ := h.repo.WithContext(r.Context()).FindByID(uint(id))
 	respondJSON(w, http.StatusOK, map[string]any{"is_featured": blank.IsFeatured})
 }
 
@@ -183,7 +207,7 @@ func (h *BlankHandler) ConsultarBlank(w http.ResponseWriter, r *http.Request) {
 
 	// Búsqueda por ID específico
 	if req.BlankID > 0 {
-		blank, err := h.repo.FindByID(uint(req.BlankID))
+		blank, err := h.repo.WithContext(r.Context()).FindByID(uint(req.BlankID))
 		if err != nil || !blank.IsActive {
 			respondJSON(w, http.StatusOK, map[string]any{
 				"encontrado": false,
@@ -191,13 +215,13 @@ func (h *BlankHandler) ConsultarBlank(w http.ResponseWriter, r *http.Request) {
 			})
 			return
 		}
-		respondJSON(w, http.StatusOK, h.buildBlankResult(blank, req.Cantidad))
-		go h.repo.IncrementQuoteCount(blank.ID)
+		respondJSON(w, http.StatusOK, h.buildBlankResult(r.Context(), blank, req.Cantidad))
+		go h.repo.WithContext(context.WithoutCancel(r.Context())).IncrementQuoteCount(blank.ID)
 		return
 	}
 
 	// Búsqueda por categoría
-	blanks, err := h.repo.FindByCategory(req.Categoria)
+	blanks, err := h.repo.WithContext(r.Context()).FindByCategory(req.Categoria)
 	if err != nil || len(blanks) == 0 {
 		respondJSON(w, http.StatusOK, map[string]any{
 			"encontrado": false,
@@ -240,14 +264,14 @@ func (h *BlankHandler) ConsultarBlank(w http.ResponseWriter, r *http.Request) {
 
 	// Un solo resultado
 	blank := blanks[0]
-	respondJSON(w, http.StatusOK, h.buildBlankResult(&blank, req.Cantidad))
-	go h.repo.IncrementQuoteCount(blank.ID)
+	respondJSON(w, http.StatusOK, h.buildBlankResult(r.Context(), &blank, req.Cantidad))
+	go h.repo.WithContext(context.WithoutCancel(r.Context())).IncrementQuoteCount(blank.ID)
 }
 
 // buildBlankResult construye la respuesta de consulta para un blank específico,
 // calculando el precio correcto según la cantidad solicitada.
-func (h *BlankHandler) buildBlankResult(b *models.Blank, qty int) map[string]any {
-	unitPrice := computePriceForQty(b, qty)
+func (h *BlankHandler) buildBlankResult(ctx context.Context, b *models.Blank, qty int) map[string]any {
+	unitPrice, _ := blankconfig.UnitPrice(b, qty)
 	totalPrice := unitPrice * qty
 
 	dim := ""
@@ -272,6 +296,20 @@ func (h *BlankHandler) buildBlankResult(b *models.Blank, qty int) map[string]any
 		"accesorios_opcionales": accessories,
 	}
 
+	// IVA: los precios de blanks son sin IVA. Si la config no carga, tarifa general.
+	config, err := h.configLoader.LoadContext(ctx)
+	if err != nil {
+		config = nil
+	}
+	// Convert CRC→CRC solo redondea a colones enteros
+	iva := tax.For(config, tax.KindBlank, float64(totalPrice), nil, time.Now()).
+		Convert(models.CurrencyCRC, models.CurrencyCRC, 0)
+	result["subtotal"] = iva.Subtotal
+	result["tarifa_iva"] = iva.Rate
+	result["iva"] = iva.Tax
+	result["total"] = iva.Total
+	result["modo_iva"] = iva.Display
+
 	// Avisos de stock
 	if b.StockQty == 0 {
 		result["sin_stock"] = true
@@ -290,22 +328,30 @@ func (h *BlankHandler) buildBlankResult(b *models.Blank, qty int) map[string]any
 	return result
 }
 
-// computePriceForQty retorna el precio unitario correcto para la cantidad dada,
-// usando la tabla price_breaks del blank. Si qty no alcanza ningún tier, devuelve base_price.
-func computePriceForQty(b *models.Blank, qty int) int {
-	var breaks []struct {
-		Qty       int `json:"qty"`
-		UnitPrice int `json:"unit_price"`
-	}
-	if err := json.Unmarshal(b.PriceBreaks, &breaks); err != nil || len(breaks) == 0 {
-		return b.BasePrice
-	}
-	// Ordenar de mayor a menor para encontrar el tier más alto que aplica
-	sort.Slice(breaks, func(i, j int) bool { return breaks[i].Qty > breaks[j].Qty })
-	for _, br := range breaks {
-		if qty >= br.Qty {
-			return br.UnitPrice
-		}
+// Configurar cotiza un blank configurado (cantidad, accesorios y grabado) con el
+// desglose completo. Lo usan el catálogo web y, por el mismo servicio, las tools
+// configurar_blank del bot y del chat admin. Sin auth, igual que ConsultarBlank.
+func (h *BlankHandler) Configurar(w http.ResponseWriter, r *http.Request) {
+	var in blankconfig.Input
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		respondError(w, http.StatusBadRequest, "INVALID_BODY", err.Error())
+		return
+	}
+	result, err := h.configurator.Configure(r.Context(), in)
+	switch {
+	case errors.Is(err, blankconfig.ErrBlankNotFound):
+		respondError(w, http.StatusNotFound, "NOT_FOUND", err.Error())
+		return
+	case errors.Is(err, blankconfig.ErrBelowMinimum):
+		respondError(w, http.StatusBadRequest, "BELOW_MIN_QTY", err.Error())
+		return
+	case blankconfig.IsConfigError(err):
+		respondError(w, http.StatusBadRequest, "INVALID_CONFIGURATION", err.Error())
+		return
+	case err != nil:
+		respondError(w, http.StatusInternalServerError, "PRICING_ERROR", err.Error())
+		return
 	}
-	return b.BasePrice
+	respondJSON(w, http.StatusOK, result)
+	go h.repo.WithContext(context.WithoutCancel(r.Context())).IncrementQuoteCount(result.BlankID)
 }
@@ -0,0 +1,596 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/events"
+	"github.com/alonsoalpizar/fabricalaser/internal/llm"
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+	"github.com/alonsoalpizar/fabricalaser/internal/services/quotedraft"
+	"github.com/alonsoalpizar/fabricalaser/internal/utils"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// Acciones de escritura en dos fases: el modelo las PROPONE (se guardan como
+// pending y la UI muestra una tarjeta) y solo se ejecutan cuando el gestor
+// confirma con POST /api/v1/admin/chat/actions/{id}/confirm. El modelo no
+// tiene forma de confirmar por su cuenta. Al guardar el turno del modelo, cada
+// acción queda enlazada al mensaje que la propuso (message_id).
+
+// Estados de admin_chat_actions
+const (
+	ActionPending   = "pending"
+	ActionExecuted  = "executed"
+	ActionFailed    = "failed"
+	ActionCancelled = "cancelled"
+	ActionExpired   = "expired"
+)
+
+// actionTTL es cuánto vale una propuesta sin confirmar. Pasado ese tiempo los
+// datos pueden haber cambiado (stock, estado de la cotización) y hay que
+// proponerla de nuevo.
+const actionTTL = 30 * time.Minute
+
+var (
+	ErrActionNotFound = errors.New("acción no encontrada")
+	ErrActionDecided  = errors.New("la acción ya fue confirmada o cancelada")
+	ErrActionExpired  = errors.New("la propuesta expiró: pedile al asistente que la arme de nuevo")
+)
+
+// PendingAction es la tarjeta de confirmación que la UI muestra al gestor.
+type PendingAction struct {
+	ID      string         `json:"id"`
+	Tool    string         `json:"tool"`
+	Summary string         `json:"summary"`
+	Args    map[string]any `json:"args"`
+}
+
+// writeAction es una tool de escritura. prepare valida los argumentos contra el
+// estado actual y arma el texto de la tarjeta; run ejecuta tras la confirmación
+// (vuelve a validar: entre proponer y confirmar pudo cambiar algo).
+type writeAction struct {
+	tool    llm.Tool
+	prepare func(args map[string]any) (string, error)
+	run     func(adminID uint, args map[string]any) (map[string]any, error)
+}
+
+func (e *toolExecutor) writeActions() []writeAction {
+	return []writeAction{
+		{crearCotizacionTool(), e.prepareCrearCotizacion, e.runCrearCotizacion},
+		{revisarCotizacionTool(), e.prepareRevisarCotizacion, e.runRevisarCotizacion},
+		{ajustarStockBlankTool(), e.prepareAjustarStock, e.runAjustarStock},
+		{ampliarCuotaTool(), e.prepareAmpliarCuota, e.runAmpliarCuota},
+		{registrarClienteTool(), e.prepareRegistrarCliente, e.runRegistrarCliente},
+	}
+}
+
+func (e *toolExecutor) writeAction(name string) (writeAction, bool) {
+	for _, a := range e.writeActions() {
+		if a.tool.Name == name {
+			return a, true
+		}
+	}
+	return writeAction{}, false
+}
+
+// propose es el handler que ve el modelo: no ejecuta nada, deja la acción
+// pendiente y le devuelve el id para que avise al gestor.
+func (e *toolExecutor) propose(a writeAction, adminID uint, sessionID string) llm.ToolHandler {
+	return func(_ context.Context, args map[string]any) (map[string]any, error) {
+		summary, err := a.prepare(args)
+		if err != nil {
+			return map[string]any{"error": err.Error()}, nil
+		}
+		raw, err := json.Marshal(args)
+		if err != nil {
+			return nil, fmt.Errorf("propose %s marshal: %w", a.tool.Name, err)
+		}
+		id, err := e.actions.CreateAction(adminID, sessionID, a.tool.Name, raw, summary)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{
+			"requiere_confirmacion": true,
+			"accion_id":             id,
+			"resumen":               summary,
+			"nota":                  "NO está hecho: el gestor debe confirmar en la tarjeta",
+		}, nil
+	}
+}
+
+// confirm ejecuta una acción pendiente del gestor y guarda el resultado.
+// Un error de la acción (p. ej. el stock cambió) no es error de la llamada:
+// queda como status failed con su mensaje.
+func (e *toolExecutor) confirm(adminID uint, id string) (*ActionRow, error) {
+	action, err := e.claim(adminID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if time.Since(action.CreatedAt) > actionTTL {
+		return e.finish(action, ActionExpired, nil, ErrActionExpired)
+	}
+
+	var args map[string]any
+	if err := json.Unmarshal(action.Args, &args); err != nil {
+		return e.finish(action, ActionFailed, nil, fmt.Errorf("argumentos inválidos: %w", err))
+	}
+	wa, ok := e.writeAction(action.Tool)
+	if !ok {
+		return e.finish(action, ActionFailed, nil, fmt.Errorf("acción desconocida: %s", action.Tool))
+	}
+
+	result, runErr := wa.run(adminID, args)
+	if runErr != nil {
+		slog.Warn("admin_chat: acción fallida", "action_id", id, "tool", action.Tool, "error", runErr)
+		return e.finish(action, ActionFailed, nil, runErr)
+	}
+	slog.Info("admin_chat: acción ejecutada", "action_id", id, "tool", action.Tool, "admin_id", adminID)
+	return e.finish(action, ActionExecuted, result, nil)
+}
+
+// cancel descarta una acción pendiente del gestor.
+func (e *toolExecutor) cancel(adminID uint, id string) (*ActionRow, error) {
+	action, err := e.claim(adminID, id)
+	if err != nil {
+		return nil, err
+	}
+	return e.finish(action, ActionCancelled, nil, nil)
+}
+
+// claim busca la acción del gestor y la toma para decidirla. Solo quien la
+// propuso puede confirmarla o cancelarla.
+func (e *toolExecutor) claim(adminID uint, id string) (*ActionRow, error) {
+	action, err := e.actions.GetAction(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && action.AdminID != adminID) {
+		return nil, ErrActionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if action.Status != ActionPending {
+		return nil, ErrActionDecided
+	}
+	ok, err := e.actions.ClaimAction(id)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrActionDecided
+	}
+	return action, nil
+}
+
+func (e *toolExecutor) finish(action *ActionRow, status string, result map[string]any, actionErr error) (*ActionRow, error) {
+	var raw datatypes.JSON
+	if result != nil {
+		b, err := json.Marshal(result)
+		if err != nil {
+			return nil, fmt.Errorf("finish marshal: %w", err)
+		}
+		raw = b
+	}
+	var errMsg *string
+	if actionErr != nil {
+		msg := actionErr.Error()
+		errMsg = &msg
+	}
+	if err := e.actions.FinishAction(action.ID, status, raw, errMsg); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	action.Status, action.Result, action.Error, action.DecidedAt = status, raw, errMsg, &now
+	return action, nil
+}
+
+// outcomeText es el turno que queda en el historial tras la decisión del gestor,
+// para que el modelo sepa en la próxima consulta qué se hizo y qué no.
+func outcomeText(a *ActionRow) string {
+	switch a.Status {
+	case ActionExecuted:
+		return fmt.Sprintf("[Acción confirmada y ejecutada] %s. Resultado: %s", a.Summary, string(a.Result))
+	case ActionCancelled:
+		return fmt.Sprintf("[Acción cancelada por el gestor] %s", a.Summary)
+	default:
+		msg := ""
+		if a.Error != nil {
+			msg = *a.Error
+		}
+		return fmt.Sprintf("[Acción NO ejecutada (%s)] %s: %s", a.Status, a.Summary, msg)
+	}
+}
+
+// ─── Declaraciones ───────────────────────────────────────────────────────────
+
+const confirmNote = " No ejecuta: deja la acción pendiente hasta que el gestor la confirme en la tarjeta."
+
+func crearCotizacionTool() llm.Tool {
+	params := calcularCotizacionTool().Parameters
+	props := map[string]*llm.Schema{
+		"user_id": {Type: llm.TypeInteger, Description: "ID del cliente (obtener con buscar_cliente o registrar_cliente)"},
+	}
+	for k, v := range params.Properties {
+		props[k] = v
+	}
+	return llm.Tool{
+		Name: "crear_cotizacion",
+		Description: "Guarda una cotización a nombre de un cliente registrado, con los mismos datos de calcular_cotizacion. " +
+			"Usar cuando el gestor pide dejarla registrada (no para solo consultar precio)." + confirmNote,
+		Parameters: &llm.Schema{
+			Type:       llm.TypeObject,
+			Properties: props,
+			Required:   append([]string{"user_id"}, params.Required...),
+		},
+	}
+}
+
+func revisarCotizacionTool() llm.Tool {
+	return llm.Tool{
+		Name:        "revisar_cotizacion",
+		Description: "Aprueba o rechaza una cotización en estado needs_review. El cliente recibe la notificación correspondiente." + confirmNote,
+		Parameters: &llm.Schema{
+			Type: llm.TypeObject,
+			Properties: map[string]*llm.Schema{
+				"quote_id": {Type: llm.TypeInteger, Description: "ID de la cotización"},
+				"decision": {Type: llm.TypeString, Description: "aprobar o rechazar", Enum: []string{"aprobar", "rechazar"}},
+				"notas":    {Type: llm.TypeString, Description: "Notas de revisión para el cliente (motivo del rechazo, condiciones)"},
+			},
+			Required: []string{"quote_id", "decision"},
+		},
+	}
+}
+
+func ajustarStockBlankTool() llm.Tool {
+	return llm.Tool{
+		Name:        "ajustar_stock_blank",
+		Description: "Ajusta el stock de un blank del catálogo: sumar (entrada o salida con cantidad negativa) o fijar el conteo físico." + confirmNote,
+		Parameters: &llm.Schema{
+			Type: llm.TypeObject,
+			Properties: map[string]*llm.Schema{
+				"blank_id":  {Type: llm.TypeInteger, Description: "ID del blank (ver consultar_blank)"},
+				"cantidad":  {Type: llm.TypeInteger, Description: "Unidades a sumar (negativo para descontar) o stock final si operacion=fijar"},
+				"operacion": {Type: llm.TypeString, Description: "sumar o fijar", Enum: []string{"sumar", "fijar"}},
+			},
+			Required: []string{"blank_id", "cantidad", "operacion"},
+		},
+	}
+}
+
+func ampliarCuotaTool() llm.Tool {
+	return llm.Tool{
+		Name:        "ampliar_cuota",
+		Description: "Amplía la cuota de cotizaciones de un cliente (cuando ya no puede cotizar en la web)." + confirmNote,
+		Parameters: &llm.Schema{
+			Type: llm.TypeObject,
+			Properties: map[string]*llm.Schema{
+				"user_id":     {Type: llm.TypeInteger, Description: "ID del cliente (obtener con buscar_cliente)"},
+				"adicionales": {Type: llm.TypeInteger, Description: "Cotizaciones a sumar a la cuota actual"},
+				"ilimitada":   {Type: llm.TypeBoolean, Description: "true para dejar la cuota sin límite (ignora adicionales)"},
+			},
+			Required: []string{"user_id"},
+		},
+	}
+}
+
+func registrarClienteTool() llm.Tool {
+	return llm.Tool{
+		Name: "registrar_cliente",
+		Description: "Registra un cliente nuevo por cédula (física 9 dígitos, jurídica 10) sin contraseña; " +
+			"al registrarse en la web con esa cédula completa su cuenta. Verificar antes con buscar_cliente que no exista." + confirmNote,
+		Parameters: &llm.Schema{
+			Type: llm.TypeObject,
+			Properties: map[string]*llm.Schema{
+				"cedula":   {Type: llm.TypeString, Description: "Cédula física (9 dígitos) o jurídica (10 dígitos)"},
+				"nombre":   {Type: llm.TypeString, Description: "Nombre completo o razón social"},
+				"email":    {Type: llm.TypeString, Description: "Correo del cliente (opcional)"},
+				"telefono": {Type: llm.TypeString, Description: "Teléfono de 8 dígitos (opcional)"},
+			},
+			Required: []string{"cedula", "nombre"},
+		},
+	}
+}
+
+// ─── crear_cotizacion ────────────────────────────────────────────────────────
+
+func (e *toolExecutor) prepareCrearCotizacion(args map[string]any) (string, error) {
+	user, p, err := e.quoteRequest(args)
+	if err != nil {
+		return "", err
+	}
+	techName, materialName := fmt.Sprint(p.TechnologyID), fmt.Sprint(p.MaterialID)
+	if cfg, err := e.configLoader.Load(); err == nil {
+		if t := cfg.GetTechnology(p.TechnologyID); t != nil {
+			techName = t.Name
+		}
+		if m := cfg.GetMaterial(p.MaterialID); m != nil {
+			materialName = m.Name
+		}
+		thickness := p.Thickness
+		if thickness <= 0 {
+			thickness = 3.0
+		}
+		if ok, _ := cfg.IsCompatible(p.TechnologyID, p.MaterialID, thickness); !ok {
+			return "", quotedraft.ErrIncompatible
+		}
+	}
+	summary := fmt.Sprintf("Crear cotización para %s (cédula %s): %d × %g×%g cm en %s con %s",
+		user.Nombre, user.Cedula, max(p.Cantidad, 1), p.AnchoCM, p.AltoCM, materialName, techName)
+	if p.IncluyeCorte != nil && *p.IncluyeCorte {
+		summary += ", con corte"
+	}
+	return summary, nil
+}
+
+func (e *toolExecutor) runCrearCotizacion(_ uint, args map[string]any) (map[string]any, error) {
+	user, p, err := e.quoteRequest(args)
+	if err != nil {
+		return nil, err
+	}
+	quote, err := e.quoteDrafts.ForCustomer(user.ID, p)
+	if err != nil {
+		return nil, err
+	}
+	events.Publish(events.Event{Type: events.QuoteCreated, TenantID: quote.TenantID, UserID: user.ID, QuoteID: quote.ID})
+	return map[string]any{
+		"quote_id":      quote.ID,
+		"status":        string(quote.Status),
+		"precio":        quote.ToDisplayCurrency(quote.PriceFinal),
+		"total_con_iva": quote.ToDisplayCurrency(quote.PriceTotalWithTax),
+		"moneda":        quote.Currency,
+	}, nil
+}
+
+func (e *toolExecutor) quoteRequest(args map[string]any) (*models.User, quotedraft.Params, error) {
+	p, err := quotedraft.ParamsFromArgs(args)
+	if err != nil {
+		return nil, p, fmt.Errorf("argumentos inválidos: %w", err)
+	}
+	if !p.Priceable() {
+		return nil, p, quotedraft.ErrIncomplete
+	}
+	user, err := e.userRepo.FindByID(uint(getInt(args, "user_id")))
+	if err != nil {
+		return nil, p, quotedraft.ErrUserNotFound
+	}
+	return user, p, nil
+}
+
+// ─── revisar_cotizacion ──────────────────────────────────────────────────────
+
+func (e *toolExecutor) prepareRevisarCotizacion(args map[string]any) (string, error) {
+	quote, status, err := e.reviewRequest(args)
+	if err != nil {
+		return "", err
+	}
+	verb := "Aprobar"
+	if status == models.QuoteStatusRejected {
+		verb = "Rechazar"
+	}
+	summary := fmt.Sprintf("%s cotización #%d", verb, quote.ID)
+	if quote.User != nil {
+		summary += " de " + quote.User.Nombre
+	}
+	summary += fmt.Sprintf(" (%.0f %s)", quote.ToDisplayCurrency(quote.PriceFinal), quote.Currency)
+	if notas := strings.TrimSpace(getString(args, "notas")); notas != "" {
+		summary += ". Notas: " + notas
+	}
+	return summary, nil
+}
+
+func (e *toolExecutor) runRevisarCotizacion(adminID uint, args map[string]any) (map[string]any, error) {
+	quote, status, err := e.reviewRequest(args)
+	if err != nil {
+		return nil, err
+	}
+	var notes *string
+	if notas := strings.TrimSpace(getString(args, "notas")); notas != "" {
+		notes = &notas
+	}
+	if err := e.quoteRepo.UpdateStatus(quote.ID, status, &adminID, notes); err != nil {
+		return nil, fmt.Errorf("error actualizando cotización: %w", err)
+	}
+
+	ev := events.Event{Type: events.QuoteApproved, TenantID: quote.TenantID, UserID: quote.UserID, QuoteID: quote.ID}
+	if status == models.QuoteStatusRejected {
+		ev.Type = events.QuoteRejected
+	}
+	if notes != nil {
+		ev.Note = *notes
+	}
+	events.Publish(ev)
+
+	return map[string]any{"quote_id": quote.ID, "status": string(status)}, nil
+}
+
+func (e *toolExecutor) reviewRequest(args map[string]any) (*models.Quote, models.QuoteStatus, error) {
+	var status models.QuoteStatus
+	switch getString(args, "decision") {
+	case "aprobar":
+		status = models.QuoteStatusApproved
+	case "rechazar":
+		status = models.QuoteStatusRejected
+	default:
+		return nil, "", errors.New("decision debe ser 'aprobar' o 'rechazar'")
+	}
+	quote, err := e.quoteRepo.FindByIDWithRelations(uint(getInt(args, "quote_id")))
+	if err != nil {
+		return nil, "", errors.New("cotización no encontrada")
+	}
+	if !quote.NeedsReview() {
+		return nil, "", fmt.Errorf("la cotización #%d está en %s, solo se revisan las que están en needs_review", quote.ID, quote.Status)
+	}
+	if quote.UserID == 0 {
+		return nil, "", errors.New("la cotización no tiene cliente asignado")
+	}
+	return quote, status, nil
+}
+
+// ─── ajustar_stock_blank ─────────────────────────────────────────────────────
+
+func (e *toolExecutor) prepareAjustarStock(args map[string]any) (string, error) {
+	blank, next, err := e.stockRequest(args)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Stock de %s (#%d): %d → %d", blank.Name, blank.ID, blank.StockQty, next), nil
+}
+
+func (e *toolExecutor) runAjustarStock(_ uint, args map[string]any) (map[string]any, error) {
+	blank, _, err := e.stockRequest(args)
+	if err != nil {
+		return nil, err
+	}
+	op := "set"
+	if getString(args, "operacion") == "sumar" {
+		op = "add"
+	}
+	if err := e.blankRepo.UpdateStock(blank.ID, getInt(args, "cantidad"), op); err != nil {
+		return nil, fmt.Errorf("error actualizando stock: %w", err)
+	}
+	updated, err := e.blankRepo.FindByID(blank.ID)
+	if err != nil {
+		return nil, fmt.Errorf("error leyendo stock: %w", err)
+	}
+	return map[string]any{"blank_id": updated.ID, "stock_anterior": blank.StockQty, "stock_qty": updated.StockQty}, nil
+}
+
+// stockRequest valida el ajuste y devuelve el stock que quedaría
+func (e *toolExecutor) stockRequest(args map[string]any) (*models.Blank, int, error) {
+	blank, err := e.blankRepo.FindByID(uint(getInt(args, "blank_id")))
+	if err != nil {
+		return nil, 0, errors.New("blank no encontrado")
+	}
+	qty := getInt(args, "cantidad")
+	next := qty
+	switch getString(args, "operacion") {
+	case "sumar":
+		if qty == 0 {
+			return nil, 0, errors.New("cantidad a sumar no puede ser 0")
+		}
+		next = blank.StockQty + qty
+	case "fijar":
+	default:
+		return nil, 0, errors.New("operacion debe ser 'sumar' o 'fijar'")
+	}
+	if next < 0 {
+		return nil, 0, fmt.Errorf("el stock quedaría negativo (%d, hay %d)", next, blank.StockQty)
+	}
+	return blank, next, nil
+}
+
+// ─── ampliar_cuota ───────────────────────────────────────────────────────────
+
+func (e *toolExecutor) prepareAmpliarCuota(args map[string]any) (string, error) {
+	user, next, err := e.quotaRequest(args)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Cuota de cotizaciones de %s (cédula %s): %s → %s (usadas: %d)",
+		user.Nombre, user.Cedula, quotaLabel(user.QuoteQuota), quotaLabel(next), user.QuotesUsed), nil
+}
+
+func (e *toolExecutor) runAmpliarCuota(_ uint, args map[string]any) (map[string]any, error) {
+	user, next, err := e.quotaRequest(args)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.userRepo.UpdateQuoteQuota(user.ID, next); err != nil {
+		return nil, fmt.Errorf("error actualizando cuota: %w", err)
+	}
+	return map[string]any{"user_id": user.ID, "cuota_anterior": user.QuoteQuota, "quote_quota": next}, nil
+}
+
+// quotaRequest valida la ampliación y devuelve la cuota nueva (-1 = ilimitada)
+func (e *toolExecutor) quotaRequest(args map[string]any) (*models.User, int, error) {
+	user, err := e.userRepo.FindByID(uint(getInt(args, "user_id")))
+	if err != nil {
+		return nil, 0, quotedraft.ErrUserNotFound
+	}
+	if user.QuoteQuota == -1 {
+		return nil, 0, errors.New("el cliente ya tiene cuota ilimitada")
+	}
+	if getBool(args, "ilimitada") {
+		return user, -1, nil
+	}
+	extra := getInt(args, "adicionales")
+	if extra <= 0 {
+		return nil, 0, errors.New("adicionales debe ser mayor a 0 (o ilimitada=true)")
+	}
+	return user, user.QuoteQuota + extra, nil
+}
+
+func quotaLabel(quota int) string {
+	if quota == -1 {
+		return "ilimitada"
+	}
+	return fmt.Sprint(quota)
+}
+
+// ─── registrar_cliente ───────────────────────────────────────────────────────
+
+func (e *toolExecutor) prepareRegistrarCliente(args map[string]any) (string, error) {
+	user, err := e.customerRequest(args)
+	if err != nil {
+		return "", err
+	}
+	summary := fmt.Sprintf("Registrar cliente %s, cédula %s %s", user.Nombre, user.CedulaType, user.Cedula)
+	if user.Email != "" {
+		summary += ", " + user.Email
+	}
+	if user.Telefono != nil {
+		summary += ", tel. " + *user.Telefono
+	}
+	return summary, nil
+}
+
+func (e *toolExecutor) runRegistrarCliente(_ uint, args map[string]any) (map[string]any, error) {
+	user, err := e.customerRequest(args)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.userRepo.Create(user); err != nil {
+		return nil, fmt.Errorf("error creando cliente: %w", err)
+	}
+	return map[string]any{"user_id": user.ID, "cedula": user.Cedula, "nombre": user.Nombre}, nil
+}
+
+// customerRequest arma el cliente a registrar. Queda sin contraseña, igual que
+// los creados desde el panel: el registro web con esa cédula completa la cuenta.
+func (e *toolExecutor) customerRequest(args map[string]any) (*models.User, error) {
+	validation := utils.ValidateCedula(getString(args, "cedula"))
+	if !validation.Valid {
+		return nil, errors.New("cédula inválida: física 9 dígitos, jurídica 10")
+	}
+	nombre := strings.TrimSpace(getString(args, "nombre"))
+	if nombre == "" {
+		return nil, errors.New("el nombre es requerido")
+	}
+	if existing, _ := e.userRepo.FindByCedula(validation.Cedula); existing != nil {
+		return nil, fmt.Errorf("ya existe un cliente con la cédula %s (user_id %d)", validation.Cedula, existing.ID)
+	}
+
+	user := &models.User{
+		Cedula:     validation.Cedula,
+		CedulaType: string(validation.Type),
+		Nombre:     nombre,
+		Email:      strings.TrimSpace(getString(args, "email")),
+		Role:       "customer",
+		QuoteQuota: 5,
+		Activo:     true,
+	}
+	if tel := getString(args, "telefono"); tel != "" {
+		if !utils.ValidateTelefonoCR(tel) {
+			return nil, errors.New("teléfono inválido: 8 dígitos")
+		}
+		clean := utils.CleanTelefono(tel)
+		user.Telefono = &clean
+	}
+	return user, nil
+}
@@ -4,177 +4,152 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
-	"log/slog"
 	"os"
-	"strings"
-	"time"
 
-	"cloud.google.com/go/vertexai/genai"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
+	"github.com/alonsoalpizar/fabricalaser/internal/llm"
+	"github.com/alonsoalpizar/fabricalaser/internal/services/knowledge"
 )
 
 const (
-	adminGCPProjectID  = "div-aloalpizar"
-	adminGCPLocation   = "us-central1"
-	defaultAdminModel  = "gemini-2.5-flash"
-	adminToolLoopMax   = 8
-	adminTemperature   = 0.4
-	adminTopP          = 0.95
-	adminMaxOutputTok  = 2048
+	adminToolLoopMax  = 8
+	adminTemperature  = 0.4
+	adminTopP         = 0.95
+	adminMaxOutputTok = 2048
 )
 
 // ToolCallTrace registra una invocación de tool para auditoría.
 // Se serializa a JSONB en admin_chat_messages.tool_calls.
+// ActionID liga la traza con la acción propuesta en admin_chat_actions.
 type ToolCallTrace struct {
-	Name   string         `json:"name"`
-	Args   map[string]any `json:"args"`
-	Result map[string]any `json:"result"`
+	Name     string         `json:"name"`
+	Args     map[string]any `json:"args"`
+	Result   map[string]any `json:"result"`
+	ActionID string         `json:"action_id,omitempty"`
 }
 
-// CallResult es lo que devuelve el adapter al handler: texto + traza de tools.
+// CallResult es lo que devuelve el adapter al handler: texto + traza de tools
+// + acciones de escritura que esperan confirmación del gestor.
 type CallResult struct {
-	Reply     string          `json:"reply"`
-	ToolCalls []ToolCallTrace `json:"tool_calls,omitempty"`
+	Reply          string          `json:"reply"`
+	ToolCalls      []ToolCallTrace `json:"tool_calls,omitempty"`
+	PendingActions []PendingAction `json:"pending_actions,omitempty"`
 }
 
-// geminiAdapter encapsula el cliente Vertex AI y el ContextProvider.
+// CallHooks son los callbacks del chat en streaming (SendMessageStream): texto
+// parcial y comienzo/fin de cada tool. Nil en la llamada normal.
+type CallHooks struct {
+	OnText      func(chunk string)
+	OnToolStart func(name string)
+	OnToolEnd   func(trace ToolCallTrace)
+}
+
+// geminiAdapter encapsula el proveedor del modelo y el ContextProvider.
 type geminiAdapter struct {
-	client          *genai.Client
+	llm             llm.Provider
 	contextProvider *ContextProvider
 	executor        *toolExecutor
 	modelName       string
 }
 
 // newGeminiAdapter construye el adapter. R7: el modelo se lee de
-// ADMIN_GEMINI_MODEL (default: gemini-2.5-flash, ya validado en producción
-// por el bot de WhatsApp).
-func newGeminiAdapter(provider *ContextProvider, executor *toolExecutor) *geminiAdapter {
-	ctx := context.Background()
-	client, err := genai.NewClient(ctx, adminGCPProjectID, adminGCPLocation)
-	if err != nil {
-		log.Fatalf("admin_chat: failed to create Vertex AI client: %v", err)
-	}
-
-	model := os.Getenv("ADMIN_GEMINI_MODEL")
-	if model == "" {
-		model = defaultAdminModel
-	}
-
+// ADMIN_GEMINI_MODEL (vacío: el default del proveedor, gemini-2.5-flash, ya
+// validado en producción por el bot de WhatsApp).
+func newGeminiAdapter(model llm.Provider, provider *ContextProvider, executor *toolExecutor) *geminiAdapter {
 	return &geminiAdapter{
-		client:          client,
+		llm:             model,
 		contextProvider: provider,
 		executor:        executor,
-		modelName:       model,
+		modelName:       os.Getenv("ADMIN_GEMINI_MODEL"),
 	}
 }
 
 // Call es la llamada principal. Recibe historial de turnos previos + mensaje
 // nuevo + datos del gestor, ejecuta el tool loop hasta 8 iteraciones y
-// devuelve respuesta + traza de tools usadas.
+// devuelve respuesta + traza de tools usadas. sessionID liga las acciones
+// propuestas a la sesión. hooks (opcional) recibe la respuesta a medida que se
+// genera.
 func (g *geminiAdapter) Call(
 	ctx context.Context,
 	adminID uint,
 	adminName string,
+	sessionID string,
 	history []ChatTurn,
 	newMessage string,
+	hooks *CallHooks,
 ) (*CallResult, error) {
-	model := g.client.GenerativeModel(g.modelName)
-
 	dynCtx := g.contextProvider.Get()
 	adminCtx := buildAdminContextBlock(adminID, adminName)
 
-	model.SystemInstruction = &genai.Content{
-		Parts: []genai.Part{genai.Text(systemPromptAdmin + adminCtx + dynCtx)},
+	tools := g.executor.registry(adminID, sessionID)
+	messages := make([]llm.Message, len(history))
+	for i, turn := range history {
+		messages[i] = llm.Message{Role: turn.Role, Parts: []llm.Part{llm.Text(turn.Content)}}
 	}
-	model.Tools = []*genai.Tool{{FunctionDeclarations: toolDeclarations()}}
-
-	temp := float32(adminTemperature)
-	topP := float32(adminTopP)
-	maxOut := int32(adminMaxOutputTok)
-	model.Temperature = &temp
-	model.TopP = &topP
-	model.MaxOutputTokens = &maxOut
-
-	chat := model.StartChat()
-	for _, turn := range history {
-		chat.History = append(chat.History, &genai.Content{
-			Role:  turn.Role,
-			Parts: []genai.Part{genai.Text(turn.Content)},
-		})
+	chat := llm.NewChat(g.llm, llm.Config{
+		Model:           g.modelName,
+		System:          systemPromptAdmin + adminCtx + dynCtx,
+		Tools:           tools.Tools(),
+		Temperature:     llm.Float32(adminTemperature),
+		TopP:            llm.Float32(adminTopP),
+		MaxOutputTokens: llm.Int32(adminMaxOutputTok),
+	}, messages)
+	if hooks != nil {
+		chat.OnText = hooks.OnText
+		if hooks.OnToolStart != nil {
+			chat.OnToolStart = func(call llm.FunctionCall) { hooks.OnToolStart(call.Name) }
+		}
 	}
 
-	resp, err := sendWithRetryAdmin(ctx, chat, genai.Text(newMessage))
+	resp, err := chat.Send(ctx, llm.Text(newMessage))
 	if err != nil {
 		return nil, fmt.Errorf("admin_chat Call: %w", err)
 	}
 
 	var traces []ToolCallTrace
-
-	for i := 0; i < adminToolLoopMax; i++ {
-		if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil {
-			break
+	var pending []PendingAction
+	resp, err = chat.RunTools(ctx, resp, adminToolLoopMax, tools, func(tr llm.ToolTrace) {
+		trace := ToolCallTrace{
+			Name:   tr.Call.Name,
+			Args:   tr.Call.Args,
+			Result: tr.Result,
 		}
-
-		var fc *genai.FunctionCall
-		for _, part := range resp.Candidates[0].Content.Parts {
-			if call, ok := part.(genai.FunctionCall); ok {
-				fc = &call
-				break
-			}
+		if id, ok := tr.Result["accion_id"].(string); ok {
+			trace.ActionID = id
+			summary, _ := tr.Result["resumen"].(string)
+			pending = append(pending, PendingAction{ID: id, Tool: tr.Call.Name, Summary: summary, Args: tr.Call.Args})
 		}
-
-		if fc == nil {
-			// Sin tool call → respuesta final, extraer texto
-			text := extractText(resp)
-			if text != "" {
-				return &CallResult{Reply: text, ToolCalls: traces}, nil
-			}
-			break
-		}
-
-		result, err := g.executor.executeFunction(ctx, adminID, fc)
-		if err != nil {
-			slog.Error("admin_chat: error ejecutando tool",
-				"tool", fc.Name, "admin_id", adminID, "error", err)
-			result = map[string]any{"error": err.Error()}
-		}
-
-		traces = append(traces, ToolCallTrace{
-			Name:   fc.Name,
-			Args:   fc.Args,
-			Result: result,
-		})
-
-		resp, err = sendWithRetryAdmin(ctx, chat, genai.FunctionResponse{
-			Name:     fc.Name,
-			Response: result,
-		})
-		if err != nil {
-			return nil, fmt.Errorf("admin_chat FunctionResponse: %w", err)
+		traces = append(traces, trace)
+		if hooks != nil && hooks.OnToolEnd != nil {
+			hooks.OnToolEnd(trace)
 		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("admin_chat FunctionResponse: %w", err)
 	}
 
-	// Fallback: tool loop terminado, intentar extraer texto
-	text := extractText(resp)
+	// Fallback: tool loop terminado sin texto
+	text := resp.Text()
 	if text == "" {
 		text = "No pude generar una respuesta. Intentá reformular la consulta."
 	}
-	return &CallResult{Reply: text, ToolCalls: traces}, nil
+	return &CallResult{Reply: text, ToolCalls: traces, PendingActions: pending}, nil
 }
 
-func extractText(resp *genai.GenerateContentResponse) string {
-	if resp == nil || len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil {
-		return ""
-	}
-	var sb strings.Builder
-	for _, part := range resp.Candidates[0].Content.Parts {
-		if t, ok := part.(genai.Text); ok {
-			sb.WriteString(string(t))
-		}
-	}
-	return sb.String()
+// Agent es el agente del chat admin sin el handler HTTP (sesión Redis e
+// historial en la base): cmd/boteval lo usa para reproducir conversaciones.
+// Las acciones de escritura quedan propuestas, nunca se confirman.
+type Agent struct {
+	gemini *geminiAdapter
+}
+
+// NewAgent arma el agente con el modelo dado
+func NewAgent(model llm.Provider, ctxProvider *ContextProvider, kb *knowledge.Service) *Agent {
+	return &Agent{gemini: newGeminiAdapter(model, ctxProvider, newToolExecutor(kb))}
+}
+
+// Call responde un mensaje del gestor con el historial dado (ver geminiAdapter.Call)
+func (a *Agent) Call(ctx context.Context, adminID uint, adminName, sessionID string, history []ChatTurn, newMessage string) (*CallResult, error) {
+	return a.gemini.Call(ctx, adminID, adminName, sessionID, history, newMessage, nil)
 }
 
 // SerializeToolCalls convierte traces a JSON string (nil si vacío) para guardar
@@ -190,40 +165,3 @@ func SerializeToolCalls(traces []ToolCallTrace) *string {
 	s := string(b)
 	return &s
 }
-
-// sendWithRetryAdmin reintenta hasta 3 veces ante 429 ResourceExhausted con
-// backoff 2s/4s/8s. Patrón copiado del WA adapter (R: duplicado consciente
-// para no acoplar paquetes).
-func sendWithRetryAdmin(ctx context.Context, chat *genai.ChatSession, part genai.Part) (*genai.GenerateContentResponse, error) {
-	delays := []time.Duration{2 * time.Second, 4 * time.Second, 8 * time.Second}
-
-	var lastErr error
-	for attempt := 0; attempt <= len(delays); attempt++ {
-		resp, err := chat.SendMessage(ctx, part)
-		if err == nil {
-			return resp, nil
-		}
-
-		st, ok := status.FromError(err)
-		if !ok || st.Code() != codes.ResourceExhausted {
-			return nil, err
-		}
-
-		if attempt == len(delays) {
-			lastErr = err
-			break
-		}
-
-		slog.Warn("admin_chat: Vertex AI 429 — reintentando",
-			"attempt", attempt+1, "wait", delays[attempt])
-
-		select {
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		case <-time.After(delays[attempt]):
-		}
-		lastErr = err
-	}
-
-	return nil, fmt.Errorf("sendWithRetryAdmin: agotados reintentos tras 429: %w", lastErr)
-}
@@ -3,13 +3,18 @@ package chat
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"strconv"
 	"time"
 
+	"github.com/alonsoalpizar/fabricalaser/internal/llm"
+	"github.com/alonsoalpizar/fabricalaser/internal/services/knowledge"
+	"github.com/alonsoalpizar/fabricalaser/internal/sse"
 	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -20,7 +25,7 @@ const (
 	requestTimeout  = 60 * time.Second
 )
 
-// Handler expone los 5 endpoints del chat administrativo bajo /api/v1/admin/chat/*.
+// Handler expone los endpoints del chat administrativo bajo /api/v1/admin/chat/*.
 type Handler struct {
 	redis    *redis.Client
 	repo     *ConversationRepository
@@ -30,14 +35,15 @@ type Handler struct {
 
 // NewHandler construye el handler con todas las dependencias.
 // El context provider se inyecta porque también lo usa el ContextProvider
-// global del servidor (cache compartido).
-func NewHandler(redisClient *redis.Client, ctxProvider *ContextProvider) *Handler {
-	executor := newToolExecutor()
+// global del servidor (cache compartido); kb es la base de conocimiento que
+// comparten todos los agentes.
+func NewHandler(model llm.Provider, redisClient *redis.Client, ctxProvider *ContextProvider, kb *knowledge.Service) *Handler {
+	executor := newToolExecutor(kb)
 	return &Handler{
 		redis:    redisClient,
 		repo:     NewConversationRepository(),
 		executor: executor,
-		gemini:   newGeminiAdapter(ctxProvider, executor),
+		gemini:   newGeminiAdapter(model, ctxProvider, executor),
 	}
 }
 
@@ -48,9 +54,10 @@ type sendMessageRequest struct {
 }
 
 type sendMessageResponse struct {
-	Reply     string          `json:"reply"`
-	SessionID string          `json:"session_id"`
-	ToolCalls []ToolCallTrace `json:"tool_calls,omitempty"`
+	Reply          string          `json:"reply"`
+	SessionID      string          `json:"session_id"`
+	ToolCalls      []ToolCallTrace `json:"tool_calls,omitempty"`
+	PendingActions []PendingAction `json:"pending_actions,omitempty"`
 }
 
 // SendMessage atiende POST /api/v1/admin/chat/message.
@@ -60,7 +67,8 @@ type sendMessageResponse struct {
 //  2. loadHistory con fallback Redis→DB (R3).
 //  3. Si no hay sesión activa: cierra huérfanas previas (R6) y crea nueva.
 //  4. Append turno user a Redis + persist async con logging (R2, R4).
-//  5. Llama a Gemini (tool loop hasta 8).
+//  5. Llama a Gemini (tool loop hasta 8). Las tools de escritura quedan
+//     pendientes y vuelven en pending_actions para que la UI pida confirmación.
 //  6. Append turno model a Redis + persist async con tool_calls (R2, R4).
 func (h *Handler) SendMessage(w http.ResponseWriter, r *http.Request) {
 	adminID, adminName, ok := extractAdmin(r)
@@ -82,11 +90,98 @@ func (h *Handler) SendMessage(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
 	defer cancel()
 
+	history, sessionID, err := h.beginTurn(ctx, adminID, req.Content)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// 2. Llamar a Gemini
+	result, err := h.gemini.Call(ctx, adminID, adminName, sessionID, history, req.Content, nil)
+	if err != nil {
+		slog.Error("admin_chat: error llamando Gemini",
+			"admin_id", adminID, "session_id", sessionID, "error", err)
+		writeJSONError(w, http.StatusInternalServerError, "error generando respuesta")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, h.finishTurn(ctx, adminID, sessionID, history, req.Content, result))
+}
+
+// SendMessageStream atiende POST /api/v1/admin/chat/message/stream: mismo flujo
+// que SendMessage pero respondiendo por SSE. Eventos:
+//   - text: {"text"} fragmento de la respuesta
+//   - tool_start: {"name","label"} al empezar una tool ("calculando cotización…")
+//   - tool_end: {"name","result","action_id"} al terminarla
+//   - done: la misma respuesta de SendMessage (incluye pending_actions)
+//   - error: {"error":{"message"}}
+//
+// Si el gestor cierra la pestaña la generación sigue con un contexto desligado
+// del request: el turno completo queda guardado en Redis y en DB igual.
+func (h *Handler) SendMessageStream(w http.ResponseWriter, r *http.Request) {
+	adminID, adminName, ok := extractAdmin(r)
+	if !ok {
+		writeJSONError(w, http.StatusUnauthorized, "no autenticado")
+		return
+	}
+
+	var req sendMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "JSON inválido")
+		return
+	}
+	if req.Content == "" {
+		writeJSONError(w, http.StatusBadRequest, "content vacío")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), requestTimeout)
+	defer cancel()
+
+	history, sessionID, err := h.beginTurn(ctx, adminID, req.Content)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	stream, err := sse.NewWriter(w, r)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "streaming no soportado")
+		return
+	}
+
+	result, err := h.gemini.Call(ctx, adminID, adminName, sessionID, history, req.Content, &CallHooks{
+		OnText: func(chunk string) {
+			stream.Send("text", map[string]string{"text": chunk})
+		},
+		OnToolStart: func(name string) {
+			stream.Send("tool_start", map[string]string{"name": name, "label": toolLabel(name)})
+		},
+		OnToolEnd: func(trace ToolCallTrace) {
+			stream.Send("tool_end", map[string]any{"name": trace.Name, "result": trace.Result, "action_id": trace.ActionID})
+		},
+	})
+	if err != nil {
+		slog.Error("admin_chat: error llamando Gemini (stream)",
+			"admin_id", adminID, "session_id", sessionID, "error", err)
+		stream.Send("error", map[string]any{"error": map[string]string{"message": "error generando respuesta"}})
+		return
+	}
+
+	resp := h.finishTurn(ctx, adminID, sessionID, history, req.Content, result)
+	if !stream.Send("done", resp) {
+		slog.Info("admin_chat: cliente desconectado antes de terminar el stream",
+			"admin_id", adminID, "session_id", sessionID)
+	}
+}
+
+// beginTurn carga el historial, abre sesión si no hay una activa y persiste el
+// turno user. El error ya viene con el mensaje para el cliente.
+func (h *Handler) beginTurn(ctx context.Context, adminID uint, content string) ([]ChatTurn, string, error) {
 	history, sessionID, err := h.loadHistory(ctx, adminID)
 	if err != nil {
 		slog.Error("admin_chat: error cargando historial", "admin_id", adminID, "error", err)
-		writeJSONError(w, http.StatusInternalServerError, "error cargando historial")
-		return
+		return nil, "", errors.New("error cargando historial")
 	}
 
 	// Si no hay sesión activa: cerrar huérfanas (R6) y crear una nueva
@@ -97,41 +192,138 @@ func (h *Handler) SendMessage(w http.ResponseWriter, r *http.Request) {
 		newID, err := h.repo.CreateSession(adminID)
 		if err != nil {
 			slog.Error("admin_chat: error creando sesión", "admin_id", adminID, "error", err)
-			writeJSONError(w, http.StatusInternalServerError, "error creando sesión")
-			return
+			return nil, "", errors.New("error creando sesión")
 		}
 		sessionID = newID
 		_ = h.redis.Set(ctx, fmt.Sprintf(redisSessionKey, adminID), sessionID, sessionTTL).Err()
 	}
 
 	// 1. Persistir turno user (async + logging)
-	go h.persistMessage(sessionID, adminID, "user", req.Content, nil)
+	go h.persistMessage(sessionID, adminID, "user", content, nil)
 
-	// 2. Llamar a Gemini
-	result, err := h.gemini.Call(ctx, adminID, adminName, history, req.Content)
-	if err != nil {
-		slog.Error("admin_chat: error llamando Gemini",
-			"admin_id", adminID, "session_id", sessionID, "error", err)
-		writeJSONError(w, http.StatusInternalServerError, "error generando respuesta")
-		return
-	}
+	return history, sessionID, nil
+}
 
-	// 3. Persistir turno model (async + logging) con tool_calls JSONB
+// finishTurn persiste el turno model y actualiza el historial Redis.
+func (h *Handler) finishTurn(ctx context.Context, adminID uint, sessionID string, history []ChatTurn, content string, result *CallResult) sendMessageResponse {
+	// 3. Persistir turno model (async + logging) con tool_calls JSONB, enlazado
+	// a las acciones que propuso
 	toolCallsJSON := SerializeToolCalls(result.ToolCalls)
-	go h.persistMessage(sessionID, adminID, "model", result.Reply, toolCallsJSON)
+	actionIDs := make([]string, len(result.PendingActions))
+	for i, a := range result.PendingActions {
+		actionIDs[i] = a.ID
+	}
+	go h.persistMessage(sessionID, adminID, "model", result.Reply, toolCallsJSON, actionIDs...)
 
 	// 4. Actualizar historial Redis (síncrono — la próxima llamada lo necesita)
 	updatedHistory := append(history,
-		ChatTurn{Role: "user", Content: req.Content},
+		ChatTurn{Role: "user", Content: content},
 		ChatTurn{Role: "model", Content: result.Reply},
 	)
 	h.saveHistoryRedis(ctx, adminID, updatedHistory)
 
-	writeJSON(w, http.StatusOK, sendMessageResponse{
-		Reply:     result.Reply,
-		SessionID: sessionID,
-		ToolCalls: result.ToolCalls,
-	})
+	return sendMessageResponse{
+		Reply:          result.Reply,
+		SessionID:      sessionID,
+		ToolCalls:      result.ToolCalls,
+		PendingActions: result.PendingActions,
+	}
+}
+
+// toolLabels es el texto que la UI muestra mientras corre cada tool
+var toolLabels = map[string]string{
+	"calcular_cotizacion":    "calculando cotización…",
+	"consultar_blank":        "consultando el catálogo…",
+	"configurar_blank":       "cotizando el blank…",
+	"recomendar_tecnologia":  "eligiendo tecnología…",
+	"listar_materiales":      "revisando materiales…",
+	"listar_tecnologias":     "revisando tecnologías…",
+	"buscar_cliente":         "buscando cliente…",
+	"historial_cotizaciones": "trayendo historial de cotizaciones…",
+	"buscar_conocimiento":    "buscando en la base de conocimiento…",
+}
+
+func toolLabel(name string) string {
+	if label, ok := toolLabels[name]; ok {
+		return label
+	}
+	return "preparando acción…"
+}
+
+// ConfirmAction atiende POST /api/v1/admin/chat/actions/{id}/confirm: ejecuta
+// una acción propuesta por el asistente. Es la única vía de ejecución.
+func (h *Handler) ConfirmAction(w http.ResponseWriter, r *http.Request) {
+	h.decideAction(w, r, h.executor.confirm)
+}
+
+// CancelAction atiende POST /api/v1/admin/chat/actions/{id}/cancel.
+func (h *Handler) CancelAction(w http.ResponseWriter, r *http.Request) {
+	h.decideAction(w, r, h.executor.cancel)
+}
+
+func (h *Handler) decideAction(w http.ResponseWriter, r *http.Request, decide func(adminID uint, id string) (*ActionRow, error)) {
+	adminID, _, ok := extractAdmin(r)
+	if !ok {
+		writeJSONError(w, http.StatusUnauthorized, "no autenticado")
+		return
+	}
+
+	action, err := decide(adminID, chi.URLParam(r, "id"))
+	switch {
+	case errors.Is(err, ErrActionNotFound):
+		writeJSONError(w, http.StatusNotFound, err.Error())
+		return
+	case errors.Is(err, ErrActionDecided):
+		writeJSONError(w, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		slog.Error("admin_chat: error decidiendo acción", "admin_id", adminID, "error", err)
+		writeJSONError(w, http.StatusInternalServerError, "error procesando la acción")
+		return
+	}
+
+	h.recordOutcome(r.Context(), adminID, action)
+	writeJSON(w, http.StatusOK, action)
+}
+
+// recordOutcome deja la decisión como turno model de la sesión activa, para que
+// el asistente sepa en la próxima consulta si la acción se hizo o no.
+func (h *Handler) recordOutcome(ctx context.Context, adminID uint, action *ActionRow) {
+	history, sessionID, err := h.loadHistory(ctx, adminID)
+	if err != nil || sessionID == "" || action.SessionID == nil || *action.SessionID != sessionID {
+		return
+	}
+	text := outcomeText(action)
+	go h.persistMessage(sessionID, adminID, "model", text, nil)
+	h.saveHistoryRedis(ctx, adminID, append(history, ChatTurn{Role: "model", Content: text}))
+}
+
+type listActionsResponse struct {
+	Actions []ActionRow `json:"actions"`
+	Total   int64       `json:"total"`
+}
+
+// ListActions retorna la bitácora de acciones (auditoría, todos los admins).
+// ?session_id= filtra por sesión.
+func (h *Handler) ListActions(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.URL.Query().Get("session_id")
+	if sessionID != "" {
+		if _, err := uuid.Parse(sessionID); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "session_id inválido")
+			return
+		}
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+
+	actions, total, err := h.repo.ListActions(sessionID, limit, offset)
+	if err != nil {
+		slog.Error("admin_chat ListActions: error", "error", err)
+		writeJSONError(w, http.StatusInternalServerError, "error listando acciones")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, listActionsResponse{Actions: actions, Total: total})
 }
 
 type resetResponse struct {
@@ -295,11 +487,11 @@ func (h *Handler) saveHistoryRedis(ctx context.Context, adminID uint, history []
 // persistMessage guarda en DB de forma asíncrona, loggeando errores (R2).
 // Usa context.Background() porque el request HTTP puede haber terminado
 // para cuando esto corra.
-func (h *Handler) persistMessage(sessionID string, adminID uint, role, content string, toolCalls *string) {
+func (h *Handler) persistMessage(sessionID string, adminID uint, role, content string, toolCalls *string, actionIDs ...string) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	if err := h.repo.SaveMessage(ctx, sessionID, adminID, role, content, toolCalls); err != nil {
+	if err := h.repo.SaveMessage(ctx, sessionID, adminID, role, content, toolCalls, actionIDs...); err != nil {
 		slog.Error("admin_chat: failed to persist message",
 			"session_id", sessionID, "admin_id", adminID, "role", role, "error", err)
 	}
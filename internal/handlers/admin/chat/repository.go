@@ -7,12 +7,13 @@ import (
 
 	"github.com/alonsoalpizar/fabricalaser/internal/database"
 	"github.com/google/uuid"
+	"gorm.io/datatypes"
 	"gorm.io/gorm"
 )
 
 // ConversationRepository persiste sesiones y mensajes del chat administrativo.
 // Acceso GORM directo sobre tablas admin_chat_sessions y admin_chat_messages
-// (definidas en migration 029).
+// (definidas en migration 029) y admin_chat_actions (migrations 042 y 049).
 type ConversationRepository struct {
 	db *gorm.DB
 }
@@ -53,6 +54,23 @@ type SessionRow struct {
 	PrimeraConsultaPreview *string    `gorm:"column:primera_consulta_preview" json:"primera_consulta_preview,omitempty"`
 }
 
+// ActionRow es una acción de escritura propuesta por el asistente. Es a la vez
+// la tarjeta de confirmación y el registro de auditoría de lo que se ejecutó.
+type ActionRow struct {
+	ID        string         `gorm:"column:id"         json:"id"`
+	SessionID *string        `gorm:"column:session_id" json:"session_id,omitempty"`
+	MessageID *int64         `gorm:"column:message_id" json:"message_id,omitempty"`
+	AdminID   uint           `gorm:"column:admin_id"   json:"admin_id"`
+	Tool      string         `gorm:"column:tool"       json:"tool"`
+	Args      datatypes.JSON `gorm:"column:args"       json:"args"`
+	Summary   string         `gorm:"column:summary"    json:"summary"`
+	Status    string         `gorm:"column:status"     json:"status"`
+	Result    datatypes.JSON `gorm:"column:result"     json:"result,omitempty"`
+	Error     *string        `gorm:"column:error"      json:"error,omitempty"`
+	CreatedAt time.Time      `gorm:"column:created_at" json:"created_at"`
+	DecidedAt *time.Time     `gorm:"column:decided_at" json:"decided_at,omitempty"`
+}
+
 // ─── Sesiones ────────────────────────────────────────────────────────────────
 
 // CreateSession crea una nueva sesión vacía y devuelve su UUID.
@@ -110,7 +128,8 @@ func (r *ConversationRepository) EndAllOpenForAdmin(adminID uint, exceptID strin
 // dentro de una transacción (R4 del plan).
 //
 // toolCalls puede ser nil (mayoría de turnos) o un JSON serializado
-// (cuando el modelo invocó funciones).
+// (cuando el modelo invocó funciones). actionIDs son las acciones que el turno
+// propuso: quedan enlazadas al mensaje (admin_chat_actions.message_id).
 func (r *ConversationRepository) SaveMessage(
 	ctx context.Context,
 	sessionID string,
@@ -118,15 +137,28 @@ func (r *ConversationRepository) SaveMessage(
 	role string,
 	content string,
 	toolCalls *string,
+	actionIDs ...string,
 ) error {
 	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		if err := tx.Exec(`
+		var messageID int64
+		if err := tx.Raw(`
 			INSERT INTO admin_chat_messages (session_id, admin_id, role, content, tool_calls, created_at)
 			VALUES (?, ?, ?, ?, ?, NOW())
-		`, sessionID, adminID, role, content, toolCalls).Error; err != nil {
+			RETURNING id
+		`, sessionID, adminID, role, content, toolCalls).Scan(&messageID).Error; err != nil {
 			return fmt.Errorf("SaveMessage insert: %w", err)
 		}
 
+		if len(actionIDs) > 0 {
+			if err := tx.Exec(`
+				UPDATE admin_chat_actions
+				SET message_id = ?
+				WHERE id IN ? AND message_id IS NULL
+			`, messageID, actionIDs).Error; err != nil {
+				return fmt.Errorf("SaveMessage link actions: %w", err)
+			}
+		}
+
 		if err := tx.Exec(`
 			UPDATE admin_chat_sessions
 			SET message_count = message_count + 1
@@ -226,3 +258,106 @@ func (r *ConversationRepository) ListSessions(limit, offset int) ([]SessionRow,
 
 	return rows, total, nil
 }
+
+// ─── Acciones ────────────────────────────────────────────────────────────────
+
+// CreateAction registra una acción propuesta (status pending) y devuelve su UUID.
+// sessionID vacío = sin sesión (no debería pasar desde SendMessage).
+func (r *ConversationRepository) CreateAction(adminID uint, sessionID, tool string, args datatypes.JSON, summary string) (string, error) {
+	id := uuid.New().String()
+	var session *string
+	if sessionID != "" {
+		session = &sessionID
+	}
+	err := r.db.Exec(`
+		INSERT INTO admin_chat_actions (id, session_id, admin_id, tool, args, summary, status, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, 'pending', NOW())
+	`, id, session, adminID, tool, args, summary).Error
+	if err != nil {
+		return "", fmt.Errorf("CreateAction: %w", err)
+	}
+	return id, nil
+}
+
+// GetAction busca una acción por id. gorm.ErrRecordNotFound si no existe.
+func (r *ConversationRepository) GetAction(id string) (*ActionRow, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	var rows []ActionRow
+	err := r.db.Raw(`
+		SELECT id, session_id, message_id, admin_id, tool, args, summary, status, result, error, created_at, decided_at
+		FROM admin_chat_actions
+		WHERE id = ?
+	`, id).Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("GetAction: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &rows[0], nil
+}
+
+// ClaimAction toma una acción pendiente para decidirla (decided_at = NOW()).
+// Devuelve false si otra petición ya la tomó: evita ejecutar dos veces la misma
+// acción con dos clics en "Confirmar".
+func (r *ConversationRepository) ClaimAction(id string) (bool, error) {
+	res := r.db.Exec(`
+		UPDATE admin_chat_actions
+		SET decided_at = NOW()
+		WHERE id = ? AND status = 'pending' AND decided_at IS NULL
+	`, id)
+	if res.Error != nil {
+		return false, fmt.Errorf("ClaimAction: %w", res.Error)
+	}
+	return res.RowsAffected == 1, nil
+}
+
+// FinishAction guarda el estado final de una acción ya tomada con ClaimAction.
+func (r *ConversationRepository) FinishAction(id, status string, result datatypes.JSON, errMsg *string) error {
+	if len(result) == 0 {
+		result = nil
+	}
+	err := r.db.Exec(`
+		UPDATE admin_chat_actions
+		SET status = ?, result = ?, error = ?
+		WHERE id = ?
+	`, status, result, errMsg, id).Error
+	if err != nil {
+		return fmt.Errorf("FinishAction: %w", err)
+	}
+	return nil
+}
+
+// ListActions retorna acciones paginadas (más recientes primero), opcionalmente
+// de una sola sesión.
+func (r *ConversationRepository) ListActions(sessionID string, limit, offset int) ([]ActionRow, int64, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	query := r.db.Table("admin_chat_actions")
+	if sessionID != "" {
+		query = query.Where("session_id = ?", sessionID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("ListActions count: %w", err)
+	}
+
+	var rows []ActionRow
+	err := query.
+		Select("id, session_id, message_id, admin_id, tool, args, summary, status, result, error, created_at, decided_at").
+		Order("created_at DESC").
+		Limit(limit).Offset(offset).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("ListActions: %w", err)
+	}
+	return rows, total, nil
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
@@ -14,11 +15,15 @@ import (
 	"strings"
 	"time"
 
-	"cloud.google.com/go/vertexai/genai"
 	"github.com/alonsoalpizar/fabricalaser/internal/database"
+	"github.com/alonsoalpizar/fabricalaser/internal/llm"
 	"github.com/alonsoalpizar/fabricalaser/internal/models"
 	"github.com/alonsoalpizar/fabricalaser/internal/repository"
+	"github.com/alonsoalpizar/fabricalaser/internal/services/blankconfig"
+	"github.com/alonsoalpizar/fabricalaser/internal/services/knowledge"
 	"github.com/alonsoalpizar/fabricalaser/internal/services/pricing"
+	"github.com/alonsoalpizar/fabricalaser/internal/services/quotedraft"
+	"github.com/alonsoalpizar/fabricalaser/internal/services/tax"
 )
 
 const (
@@ -29,27 +34,39 @@ const (
 var cedulaRegex = regexp.MustCompile(`^\d{9,10}$`)
 
 // toolExecutor agrupa las dependencias compartidas que necesitan los handlers
-// de las 6 tools del chat admin (R1: usa pricing.Calculator directo, no HTTP).
+// de las tools del chat admin (R1: usa pricing.Calculator directo, no HTTP).
 type toolExecutor struct {
 	calculator    *pricing.Calculator
 	configLoader  *pricing.ConfigLoader
+	recommender   *pricing.Recommender
 	userRepo      *repository.UserRepository
 	quoteRepo     *repository.QuoteRepository
 	materialRepo  *repository.MaterialRepository
 	techRepo      *repository.TechnologyRepository
+	blankRepo     *repository.BlankRepository
+	blanks        *blankconfig.Configurator
+	quoteDrafts   *quotedraft.Service
+	knowledge     *knowledge.Service
+	actions       *ConversationRepository
 	httpClient    *http.Client
 	internalToken string
 }
 
-func newToolExecutor() *toolExecutor {
+func newToolExecutor(kb *knowledge.Service) *toolExecutor {
 	configLoader := pricing.NewConfigLoader(database.Get())
 	return &toolExecutor{
 		calculator:    pricing.NewCalculator(configLoader),
 		configLoader:  configLoader,
+		recommender:   pricing.NewRecommender(configLoader),
 		userRepo:      repository.NewUserRepository(),
 		quoteRepo:     repository.NewQuoteRepository(),
 		materialRepo:  repository.NewMaterialRepository(),
 		techRepo:      repository.NewTechnologyRepository(),
+		blankRepo:     repository.NewBlankRepository(),
+		blanks:        blankconfig.NewConfigurator(),
+		quoteDrafts:   quotedraft.NewService(),
+		knowledge:     kb,
+		actions:       NewConversationRepository(),
 		httpClient:    &http.Client{Timeout: httpToolTimeout},
 		internalToken: os.Getenv("INTERNAL_API_TOKEN"),
 	}
@@ -57,167 +74,138 @@ func newToolExecutor() *toolExecutor {
 
 // ─── Function Declarations ───────────────────────────────────────────────────
 
-func toolDeclarations() []*genai.FunctionDeclaration {
-	return []*genai.FunctionDeclaration{
-		calcularCotizacionTool(),
-		consultarBlankTool(),
-		listarMaterialesTool(),
-		listarTecnologiasTool(),
-		buscarClienteTool(),
-		historialCotizacionesTool(),
-	}
+// registry arma las tools del chat admin: las de consulta se ejecutan directo;
+// las de escritura (actions.go) solo quedan propuestas a nombre del gestor y la
+// sesión, hasta que él las confirme.
+func (e *toolExecutor) registry(adminID uint, sessionID string) *llm.Registry {
+	r := llm.NewRegistry()
+	r.Register(calcularCotizacionTool(), e.execCalcularCotizacion)
+	r.Register(consultarBlankTool(), e.execConsultarBlank)
+	r.Register(blankconfig.Tool(), e.blanks.Handler())
+	r.Register(recomendarTecnologiaTool(), e.execRecomendarTecnologia)
+	r.Register(listarMaterialesTool(), e.execListarMateriales)
+	r.Register(listarTecnologiasTool(), e.execListarTecnologias)
+	r.Register(buscarClienteTool(), e.execBuscarCliente)
+	r.Register(historialCotizacionesTool(), e.execHistorialCotizaciones)
+	r.Register(knowledge.Tool(), e.knowledge.Handler(true))
+	for _, a := range e.writeActions() {
+		r.Register(a.tool, e.propose(a, adminID, sessionID))
+	}
+	return r
 }
 
-func calcularCotizacionTool() *genai.FunctionDeclaration {
-	return &genai.FunctionDeclaration{
+func calcularCotizacionTool() llm.Tool {
+	return llm.Tool{
 		Name: "calcular_cotizacion",
 		Description: "Calcula precio detallado de un trabajo de grabado o corte láser usando el motor de pricing oficial. " +
 			"Devuelve breakdown completo: tiempos, costos, factores aplicados, ambos modelos de precio (híbrido y por valor) y cuál ganó. " +
 			"Usar cuando el gestor ya proporcionó material, medidas y cantidad.",
-		Parameters: &genai.Schema{
-			Type: genai.TypeObject,
-			Properties: map[string]*genai.Schema{
-				"alto_cm":           {Type: genai.TypeNumber, Description: "Alto del área a grabar/cortar en centímetros"},
-				"ancho_cm":          {Type: genai.TypeNumber, Description: "Ancho del área a grabar/cortar en centímetros"},
-				"cantidad":          {Type: genai.TypeInteger, Description: "Número de unidades a producir"},
-				"technology_id":     {Type: genai.TypeInteger, Description: "ID de tecnología láser (ver listar_tecnologias o IDs en system prompt)"},
-				"material_id":       {Type: genai.TypeInteger, Description: "ID del material (ver listar_materiales o IDs en system prompt)"},
-				"engrave_type_id":   {Type: genai.TypeInteger, Description: "1=Vectorial, 2=Rasterizado, 3=Fotograbado, 4=3D/Relieve. Default: 1"},
-				"thickness":         {Type: genai.TypeNumber, Description: "Grosor del material en mm. Default: 3.0"},
-				"material_included": {Type: genai.TypeBoolean, Description: "true si FabricaLaser provee el material, false si el cliente lo trae"},
-				"incluye_corte":     {Type: genai.TypeBoolean, Description: "true si el trabajo incluye corte del perímetro además del grabado"},
-				"cut_technology_id": {Type: genai.TypeInteger, Description: "ID de tecnología para el corte cuando es diferente. Solo en Caso 3B (UV graba + CO2 corta)"},
+		Parameters: &llm.Schema{
+			Type: llm.TypeObject,
+			Properties: map[string]*llm.Schema{
+				"alto_cm":           {Type: llm.TypeNumber, Description: "Alto del área a grabar/cortar en centímetros"},
+				"ancho_cm":          {Type: llm.TypeNumber, Description: "Ancho del área a grabar/cortar en centímetros"},
+				"cantidad":          {Type: llm.TypeInteger, Description: "Número de unidades a producir"},
+				"technology_id":     {Type: llm.TypeInteger, Description: "ID de tecnología láser (ver listar_tecnologias o IDs en system prompt)"},
+				"material_id":       {Type: llm.TypeInteger, Description: "ID del material (ver listar_materiales o IDs en system prompt)"},
+				"engrave_type_id":   {Type: llm.TypeInteger, Description: "1=Vectorial, 2=Rasterizado, 3=Fotograbado, 4=3D/Relieve. Default: 1"},
+				"thickness":         {Type: llm.TypeNumber, Description: "Grosor del material en mm. Default: 3.0"},
+				"material_included": {Type: llm.TypeBoolean, Description: "true si FabricaLaser provee el material, false si el cliente lo trae"},
+				"incluye_corte":     {Type: llm.TypeBoolean, Description: "true si el trabajo incluye corte del perímetro además del grabado"},
+				"cut_technology_id": {Type: llm.TypeInteger, Description: "ID de tecnología para el corte cuando es diferente. Usar el que indique recomendar_tecnologia (cotizar_con)"},
 			},
 			Required: []string{"alto_cm", "ancho_cm", "cantidad", "technology_id", "material_id", "material_included", "incluye_corte"},
 		},
 	}
 }
 
-func consultarBlankTool() *genai.FunctionDeclaration {
-	return &genai.FunctionDeclaration{
+func consultarBlankTool() llm.Tool {
+	return llm.Tool{
 		Name:        "consultar_blank",
 		Description: "Consulta precio y disponibilidad de un blank (producto preconfigurado: llaveros, medallas, etc.). Usar cuando el gestor pregunte por estos productos del catálogo.",
-		Parameters: &genai.Schema{
-			Type: genai.TypeObject,
-			Properties: map[string]*genai.Schema{
-				"categoria": {Type: genai.TypeString, Description: "Categoría del blank: 'llavero', 'medalla', etc."},
-				"cantidad":  {Type: genai.TypeInteger, Description: "Cantidad de unidades que el cliente quiere"},
-				"blank_id":  {Type: genai.TypeInteger, Description: "ID específico del blank. 0 (o no incluir) si no se conoce — el tool retorna todas las opciones de la categoría"},
+		Parameters: &llm.Schema{
+			Type: llm.TypeObject,
+			Properties: map[string]*llm.Schema{
+				"categoria": {Type: llm.TypeString, Description: "Categoría del blank: 'llavero', 'medalla', etc."},
+				"cantidad":  {Type: llm.TypeInteger, Description: "Cantidad de unidades que el cliente quiere"},
+				"blank_id":  {Type: llm.TypeInteger, Description: "ID específico del blank. 0 (o no incluir) si no se conoce — el tool retorna todas las opciones de la categoría"},
 			},
 			Required: []string{"categoria", "cantidad"},
 		},
 	}
 }
 
-func listarMaterialesTool() *genai.FunctionDeclaration {
-	return &genai.FunctionDeclaration{
+func recomendarTecnologiaTool() llm.Tool {
+	return llm.Tool{
+		Name:        "recomendar_tecnologia",
+		Description: "Planes de tecnología válidos para un trabajo (mejor primero) según material, operación, grosor y acabado de color, con motivos, grosores de corte válidos y los technology_id/cut_technology_id/incluye_corte para calcular_cotizacion. Es la fuente de verdad del árbol de decisión.",
+		Parameters: &llm.Schema{
+			Type: llm.TypeObject,
+			Properties: map[string]*llm.Schema{
+				"material_id":   {Type: llm.TypeInteger, Description: "ID del material (ver listar_materiales o IDs en system prompt)"},
+				"operacion":     {Type: llm.TypeString, Description: "grabado, corte o grabado_corte", Enum: []string{"grabado", "corte", "grabado_corte"}},
+				"thickness":     {Type: llm.TypeNumber, Description: "Grosor en mm. 0 si no se conoce"},
+				"acabado_color": {Type: llm.TypeBoolean, Description: "true si la pieza tiene acabado de color a respetar (anodizado, termo con coating)"},
+			},
+			Required: []string{"material_id", "operacion"},
+		},
+	}
+}
+
+func listarMaterialesTool() llm.Tool {
+	return llm.Tool{
 		Name:        "listar_materiales",
 		Description: "Lista los materiales disponibles en el catálogo, opcionalmente filtrando por cortabilidad o categoría. Útil cuando el gestor pregunta '¿qué materiales tenemos?' o '¿qué cortamos?'.",
-		Parameters: &genai.Schema{
-			Type: genai.TypeObject,
-			Properties: map[string]*genai.Schema{
-				"cortable_solo": {Type: genai.TypeBoolean, Description: "true para listar solo materiales que se pueden cortar con CO2"},
-				"categoria":     {Type: genai.TypeString, Description: "Filtrar por categoría (ej: 'madera', 'acrilico', 'metal'). Vacío = todos"},
+		Parameters: &llm.Schema{
+			Type: llm.TypeObject,
+			Properties: map[string]*llm.Schema{
+				"cortable_solo": {Type: llm.TypeBoolean, Description: "true para listar solo materiales que se pueden cortar con CO2"},
+				"categoria":     {Type: llm.TypeString, Description: "Filtrar por categoría (ej: 'madera', 'acrilico', 'metal'). Vacío = todos"},
 			},
 		},
 	}
 }
 
-func listarTecnologiasTool() *genai.FunctionDeclaration {
-	return &genai.FunctionDeclaration{
+func listarTecnologiasTool() llm.Tool {
+	return llm.Tool{
 		Name:        "listar_tecnologias",
 		Description: "Lista las tecnologías láser disponibles (CO2, UV, Fibra, MOPA) con sus IDs. Útil cuando el gestor pregunta '¿qué tecnologías tenemos?'.",
-		Parameters: &genai.Schema{
-			Type:       genai.TypeObject,
-			Properties: map[string]*genai.Schema{},
+		Parameters: &llm.Schema{
+			Type:       llm.TypeObject,
+			Properties: map[string]*llm.Schema{},
 		},
 	}
 }
 
-func buscarClienteTool() *genai.FunctionDeclaration {
-	return &genai.FunctionDeclaration{
+func buscarClienteTool() llm.Tool {
+	return llm.Tool{
 		Name:        "buscar_cliente",
 		Description: "Busca un cliente en la base de datos por cédula (9 o 10 dígitos) o por nombre/email (búsqueda fuzzy). Devuelve hasta 20 resultados con datos de contacto.",
-		Parameters: &genai.Schema{
-			Type: genai.TypeObject,
-			Properties: map[string]*genai.Schema{
-				"query": {Type: genai.TypeString, Description: "Cédula numérica (9-10 dígitos) o nombre/email para buscar"},
+		Parameters: &llm.Schema{
+			Type: llm.TypeObject,
+			Properties: map[string]*llm.Schema{
+				"query": {Type: llm.TypeString, Description: "Cédula numérica (9-10 dígitos) o nombre/email para buscar"},
 			},
 			Required: []string{"query"},
 		},
 	}
 }
 
-func historialCotizacionesTool() *genai.FunctionDeclaration {
-	return &genai.FunctionDeclaration{
+func historialCotizacionesTool() llm.Tool {
+	return llm.Tool{
 		Name:        "historial_cotizaciones",
 		Description: "Devuelve las cotizaciones recientes de un cliente (por user_id obtenido con buscar_cliente). Incluye tecnología, material, precio final y fecha.",
-		Parameters: &genai.Schema{
-			Type: genai.TypeObject,
-			Properties: map[string]*genai.Schema{
-				"user_id": {Type: genai.TypeInteger, Description: "ID del usuario (obtener primero con buscar_cliente)"},
-				"limit":   {Type: genai.TypeInteger, Description: "Máximo de cotizaciones a retornar. Default 10, máximo 50"},
+		Parameters: &llm.Schema{
+			Type: llm.TypeObject,
+			Properties: map[string]*llm.Schema{
+				"user_id": {Type: llm.TypeInteger, Description: "ID del usuario (obtener primero con buscar_cliente)"},
+				"limit":   {Type: llm.TypeInteger, Description: "Máximo de cotizaciones a retornar. Default 10, máximo 50"},
 			},
 			Required: []string{"user_id"},
 		},
 	}
 }
 
-// ─── Dispatcher ──────────────────────────────────────────────────────────────
-
-// executeFunction despacha la llamada a la implementación correcta.
-// adminID se pasa por parámetro para futuras decisiones de auditoría/permisos por gestor
-// (hoy no se usa pero queda en la firma para no romper en v2).
-//
-// Aplica normalización JSON al resultado antes de devolverlo: el SDK de Vertex AI
-// (genai.FunctionResponse) requiere tipos compatibles con structpb, y NO acepta
-// []map[string]any. El round-trip JSON convierte todo a map[string]any + []any.
-func (e *toolExecutor) executeFunction(ctx context.Context, adminID uint, fc *genai.FunctionCall) (map[string]any, error) {
-	_ = adminID
-	var result map[string]any
-	var err error
-
-	switch fc.Name {
-	case "calcular_cotizacion":
-		result, err = e.execCalcularCotizacion(ctx, fc.Args)
-	case "consultar_blank":
-		result, err = e.execConsultarBlank(ctx, fc.Args)
-	case "listar_materiales":
-		result, err = e.execListarMateriales(ctx, fc.Args)
-	case "listar_tecnologias":
-		result, err = e.execListarTecnologias(ctx, fc.Args)
-	case "buscar_cliente":
-		result, err = e.execBuscarCliente(ctx, fc.Args)
-	case "historial_cotizaciones":
-		result, err = e.execHistorialCotizaciones(ctx, fc.Args)
-	default:
-		return nil, fmt.Errorf("tool desconocida: %s", fc.Name)
-	}
-
-	if err != nil {
-		return nil, err
-	}
-	return normalizeForStructPB(result), nil
-}
-
-// normalizeForStructPB hace round-trip JSON para que cualquier slice/map
-// concretos ([]map[string]any, []SomeStruct, []clienteOut, etc.) se conviertan
-// a tipos plain ([]any, map[string]any) que structpb sí acepta.
-func normalizeForStructPB(v map[string]any) map[string]any {
-	if v == nil {
-		return map[string]any{}
-	}
-	raw, err := json.Marshal(v)
-	if err != nil {
-		return map[string]any{"error": "no se pudo serializar resultado: " + err.Error()}
-	}
-	var out map[string]any
-	if err := json.Unmarshal(raw, &out); err != nil {
-		return map[string]any{"error": "no se pudo deserializar resultado: " + err.Error()}
-	}
-	return out
-}
-
 // ─── Implementaciones ────────────────────────────────────────────────────────
 
 func (e *toolExecutor) execCalcularCotizacion(ctx context.Context, args map[string]any) (map[string]any, error) {
@@ -272,18 +260,28 @@ func (e *toolExecutor) execCalcularCotizacion(ctx context.Context, args map[stri
 
 	// Resolver nombres de tech y material para que la explicación sea legible
 	techName, materialName := "", ""
-	if cfg, err := e.configLoader.Load(); err == nil {
+	cfg, err := e.configLoader.Load()
+	if err == nil {
 		if t := cfg.GetTechnology(techID); t != nil {
 			techName = t.Name
 		}
 		if m := cfg.GetMaterial(materialID); m != nil {
 			materialName = m.Name
 		}
+	} else {
+		cfg = nil // tax usa la tarifa general
 	}
+	iva := tax.For(cfg, tax.KindForMaterial(materialIncluded), priceFinal, nil, time.Now()).
+		Convert(models.CurrencyCRC, models.CurrencyCRC, 0)
 
 	resp := map[string]any{
 		"precio_total":      math.Round(priceFinal),
 		"precio_unitario":   priceUnit,
+		"subtotal":          iva.Subtotal,
+		"tarifa_iva":        iva.Rate,
+		"iva":               iva.Tax,
+		"total_con_iva":     iva.Total,
+		"modo_iva":          iva.Display,
 		"cantidad":          cantidad,
 		"area_cm2":          altoCM * anchoCM,
 		"tecnologia":        techName,
@@ -366,6 +364,32 @@ func (e *toolExecutor) execConsultarBlank(ctx context.Context, args map[string]a
 	return result, nil
 }
 
+func (e *toolExecutor) execRecomendarTecnologia(ctx context.Context, args map[string]any) (map[string]any, error) {
+	_ = ctx
+	op, err := pricing.ParseOperation(getString(args, "operacion"))
+	if err != nil {
+		return map[string]any{"error": err.Error()}, nil
+	}
+	rec, err := e.recommender.Recommend(pricing.RecommendInput{
+		MaterialID:  uint(getInt(args, "material_id")),
+		Thickness:   getNumber(args, "thickness"),
+		Operation:   op,
+		ColorFinish: getBool(args, "acabado_color"),
+	})
+	if errors.Is(err, pricing.ErrRecommendMaterial) {
+		return map[string]any{"error": err.Error()}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("recomendar_tecnologia: %w", err)
+	}
+
+	result := map[string]any{"recomendacion": rec}
+	if len(rec.Plans) > 0 {
+		result["cotizar_con"] = rec.Plans[0].QuoteArgs()
+	}
+	return result, nil
+}
+
 func (e *toolExecutor) execListarMateriales(ctx context.Context, args map[string]any) (map[string]any, error) {
 	_ = ctx
 	cortableSolo := getBool(args, "cortable_solo")
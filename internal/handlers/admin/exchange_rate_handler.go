@@ -0,0 +1,236 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+	"github.com/alonsoalpizar/fabricalaser/internal/repository"
+	"github.com/alonsoalpizar/fabricalaser/internal/services/currency"
+	"github.com/go-chi/chi/v5"
+)
+
+const maxExchangeRateFileSize = 2 * 1024 * 1024 // 2MB
+
+type ExchangeRateHandler struct {
+	repo *repository.ExchangeRateRepository
+}
+
+func NewExchangeRateHandler() *ExchangeRateHandler {
+	return &ExchangeRateHandler{
+		repo: repository.NewExchangeRateRepository(),
+	}
+}
+
+// GetExchangeRates returns the exchange rate history (newest first)
+func (h *ExchangeRateHandler) GetExchangeRates(w http.ResponseWriter, r *http.Request) {
+	limit := 90
+	if l := r.URL.Query().Get("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 1000 {
+			limit = parsed
+		}
+	}
+
+	rates, err := h.repo.FindAll(limit)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "LIST_ERROR", "Error al listar tipos de cambio")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    rates,
+	})
+}
+
+// GetCurrentExchangeRate returns the rate the quoter would use today
+func (h *ExchangeRateHandler) GetCurrentExchangeRate(w http.ResponseWriter, r *http.Request) {
+	rate, err := h.repo.FindLatest(models.CurrencyUSD, models.CurrencyCRC, time.Now())
+	if err != nil {
+		if errors.Is(err, repository.ErrExchangeRateNotFound) {
+			respondError(w, http.StatusNotFound, "NOT_FOUND", "No hay tipo de cambio registrado — se usa usd_crc_fallback_rate")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "DB_ERROR", "Error al obtener tipo de cambio")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    rate,
+	})
+}
+
+// CreateExchangeRate creates (or overwrites) the rate for a date
+func (h *ExchangeRateHandler) CreateExchangeRate(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		RateDate string  `json:"rate_date"` // YYYY-MM-DD
+		BuyRate  float64 `json:"buy_rate"`
+		SellRate float64 `json:"sell_rate"`
+		Notes    string  `json:"notes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "INVALID_JSON", "JSON invalido")
+		return
+	}
+
+	date, err := time.Parse("2006-01-02", req.RateDate)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "INVALID_DATE", "rate_date debe tener formato YYYY-MM-DD")
+		return
+	}
+	if req.BuyRate <= 0 && req.SellRate <= 0 {
+		respondError(w, http.StatusBadRequest, "MISSING_FIELDS", "buy_rate o sell_rate es requerido")
+		return
+	}
+	if req.BuyRate <= 0 {
+		req.BuyRate = req.SellRate
+	}
+	if req.SellRate <= 0 {
+		req.SellRate = req.BuyRate
+	}
+
+	rate := models.ExchangeRate{
+		RateDate:      date,
+		BaseCurrency:  models.CurrencyUSD,
+		QuoteCurrency: models.CurrencyCRC,
+		BuyRate:       req.BuyRate,
+		SellRate:      req.SellRate,
+		Source:        models.ExchangeRateSourceManual,
+	}
+	if req.Notes != "" {
+		rate.Notes = &req.Notes
+	}
+
+	if err := h.repo.Upsert([]models.ExchangeRate{rate}); err != nil {
+		respondError(w, http.StatusInternalServerError, "CREATE_ERROR", "Error al guardar tipo de cambio")
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, map[string]interface{}{
+		"success": true,
+		"data":    rate,
+	})
+}
+
+// UpdateExchangeRate updates an existing exchange rate
+func (h *ExchangeRateHandler) UpdateExchangeRate(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "INVALID_ID", "ID invalido")
+		return
+	}
+
+	rate, err := h.repo.FindByID(uint(id))
+	if err != nil {
+		respondError(w, http.StatusNotFound, "NOT_FOUND", "Tipo de cambio no encontrado")
+		return
+	}
+
+	var req struct {
+		BuyRate  *float64 `json:"buy_rate"`
+		SellRate *float64 `json:"sell_rate"`
+		Notes    *string  `json:"notes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "INVALID_JSON", "JSON invalido")
+		return
+	}
+
+	if req.BuyRate != nil {
+		if *req.BuyRate <= 0 {
+			respondError(w, http.StatusBadRequest, "INVALID_RATE", "buy_rate debe ser mayor a 0")
+			return
+		}
+		rate.BuyRate = *req.BuyRate
+	}
+	if req.SellRate != nil {
+		if *req.SellRate <= 0 {
+			respondError(w, http.StatusBadRequest, "INVALID_RATE", "sell_rate debe ser mayor a 0")
+			return
+		}
+		rate.SellRate = *req.SellRate
+	}
+	if req.Notes != nil {
+		rate.Notes = req.Notes
+	}
+	rate.Source = models.ExchangeRateSourceManual
+
+	if err := h.repo.Update(rate); err != nil {
+		respondError(w, http.StatusInternalServerError, "UPDATE_ERROR", "Error al actualizar tipo de cambio")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    rate,
+	})
+}
+
+// DeleteExchangeRate deletes an exchange rate
+func (h *ExchangeRateHandler) DeleteExchangeRate(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "INVALID_ID", "ID invalido")
+		return
+	}
+
+	if err := h.repo.Delete(uint(id)); err != nil {
+		respondError(w, http.StatusInternalServerError, "DELETE_ERROR", "Error al eliminar tipo de cambio")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Tipo de cambio eliminado",
+	})
+}
+
+// ImportExchangeRates imports rates from a CSV or BCCR export.
+// Accepts multipart (campo "file") or the raw CSV as body. ?source=bccr marks the origin.
+// Existing dates are overwritten.
+func (h *ExchangeRateHandler) ImportExchangeRates(w http.ResponseWriter, r *http.Request) {
+	source := models.ExchangeRateSourceCSV
+	if strings.EqualFold(r.URL.Query().Get("source"), models.ExchangeRateSourceBCCR) {
+		source = models.ExchangeRateSourceBCCR
+	}
+
+	var body io.Reader = io.LimitReader(r.Body, maxExchangeRateFileSize)
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := r.ParseMultipartForm(maxExchangeRateFileSize); err != nil {
+			respondError(w, http.StatusBadRequest, "INVALID_REQUEST", "Error leyendo formulario")
+			return
+		}
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "NO_FILE", "Falta el archivo (campo file)")
+			return
+		}
+		defer file.Close()
+		body = io.LimitReader(file, maxExchangeRateFileSize)
+	}
+
+	result, err := currency.ParseRatesCSV(body, source)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "INVALID_FILE", err.Error())
+		return
+	}
+
+	if err := h.repo.Upsert(result.Rates); err != nil {
+		respondError(w, http.StatusInternalServerError, "IMPORT_ERROR", "Error al guardar tipos de cambio")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data": map[string]interface{}{
+			"imported": len(result.Rates),
+			"errors":   result.Errors,
+		},
+	})
+}
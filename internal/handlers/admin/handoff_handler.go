@@ -0,0 +1,161 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+	"github.com/alonsoalpizar/fabricalaser/internal/repository"
+	"github.com/alonsoalpizar/fabricalaser/internal/services/handoff"
+	"github.com/go-chi/chi/v5"
+)
+
+// HandoffHandler es la bandeja de atención humana: conversaciones de WhatsApp y
+// Telegram escaladas por el bot que un admin toma y responde desde el panel.
+type HandoffHandler struct {
+	service *handoff.Service
+}
+
+func NewHandoffHandler(service *handoff.Service) *HandoffHandler {
+	return &HandoffHandler{service: service}
+}
+
+// GET /api/v1/admin/handoffs?status=pending_human,human&page=1&limit=20
+// Sin status lista las abiertas (pending_human y human).
+func (h *HandoffHandler) GetHandoffs(w http.ResponseWriter, r *http.Request) {
+	var statuses []models.HandoffStatus
+	if raw := r.URL.Query().Get("status"); raw != "" {
+		for _, s := range strings.Split(raw, ",") {
+			switch status := models.HandoffStatus(strings.TrimSpace(s)); status {
+			case models.HandoffPending, models.HandoffHuman, models.HandoffResolved:
+				statuses = append(statuses, status)
+			default:
+				respondError(w, http.StatusBadRequest, "INVALID_STATUS", "status debe ser pending_human, human o resolved")
+				return
+			}
+		}
+	}
+	page := queryInt(r, "page", 1)
+	limit := queryInt(r, "limit", 20)
+	if limit > 100 {
+		limit = 100
+	}
+
+	handoffs, total, err := h.service.List(page, limit, statuses...)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "DB_ERROR", "Error al obtener la bandeja")
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    handoffs,
+		"total":   total,
+		"page":    page,
+		"limit":   limit,
+	})
+}
+
+// GET /api/v1/admin/handoffs/{id}
+// Los mensajes se leen con /whatsapp/conversations/{conversation_key}.
+func (h *HandoffHandler) GetHandoff(w http.ResponseWriter, r *http.Request) {
+	id, ok := handoffID(w, r)
+	if !ok {
+		return
+	}
+	item, err := h.service.Get(id)
+	if err != nil {
+		h.respondHandoffError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"success": true, "data": item})
+}
+
+// POST /api/v1/admin/handoffs/{id}/claim
+func (h *HandoffHandler) Claim(w http.ResponseWriter, r *http.Request) {
+	id, ok := handoffID(w, r)
+	if !ok {
+		return
+	}
+	item, err := h.service.Claim(r.Context(), id, adminUserID(r))
+	if err != nil {
+		h.respondHandoffError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"success": true, "data": item})
+}
+
+// POST /api/v1/admin/handoffs/{id}/reply
+// Body: {"text": "Hola, soy Ana de FabricaLaser..."}
+func (h *HandoffHandler) Reply(w http.ResponseWriter, r *http.Request) {
+	id, ok := handoffID(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		Text string `json:"text"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "INVALID_JSON", "JSON inválido")
+		return
+	}
+
+	item, err := h.service.Reply(r.Context(), id, adminUserID(r), req.Text)
+	if err != nil {
+		h.respondHandoffError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"success": true, "data": item})
+}
+
+// POST /api/v1/admin/handoffs/{id}/resolve
+func (h *HandoffHandler) Resolve(w http.ResponseWriter, r *http.Request) {
+	id, ok := handoffID(w, r)
+	if !ok {
+		return
+	}
+	item, err := h.service.Resolve(r.Context(), id)
+	if err != nil {
+		h.respondHandoffError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    item,
+		"message": "Conversación devuelta al bot",
+	})
+}
+
+func (h *HandoffHandler) respondHandoffError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, repository.ErrHandoffNotFound):
+		respondError(w, http.StatusNotFound, "NOT_FOUND", err.Error())
+	case errors.Is(err, handoff.ErrEmptyMessage):
+		respondError(w, http.StatusBadRequest, "INVALID_INPUT", err.Error())
+	case errors.Is(err, handoff.ErrNotOpen), errors.Is(err, handoff.ErrAlreadyClaimed),
+		errors.Is(err, handoff.ErrNotClaimed):
+		respondError(w, http.StatusConflict, "HANDOFF_CONFLICT", err.Error())
+	case errors.Is(err, handoff.ErrChannelUnavailable):
+		respondError(w, http.StatusBadGateway, "CHANNEL_ERROR", err.Error())
+	default:
+		slog.Error("handoff: error en la bandeja", "error", err)
+		respondError(w, http.StatusInternalServerError, "HANDOFF_ERROR", "Error procesando la atención")
+	}
+}
+
+func handoffID(w http.ResponseWriter, r *http.Request) (uint, bool) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "INVALID_ID", "ID inválido")
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func adminUserID(r *http.Request) uint {
+	id, _ := r.Context().Value("userID").(uint)
+	return id
+}
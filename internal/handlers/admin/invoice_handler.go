@@ -0,0 +1,164 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/repository"
+	"github.com/alonsoalpizar/fabricalaser/internal/services/facturacion"
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+)
+
+type InvoiceHandler struct {
+	repo    *repository.InvoiceRepository
+	service *facturacion.Service // nil si la facturación electrónica no está configurada
+}
+
+func NewInvoiceHandler() *InvoiceHandler {
+	service, err := facturacion.NewServiceFromConfig()
+	if err != nil {
+		slog.Warn("facturacion: deshabilitada", "error", err)
+	}
+	return &InvoiceHandler{
+		repo:    repository.NewInvoiceRepository(),
+		service: service,
+	}
+}
+
+// IssueInvoice emits the electronic invoice of a converted quote
+func (h *InvoiceHandler) IssueInvoice(w http.ResponseWriter, r *http.Request) {
+	if h.service == nil {
+		respondError(w, http.StatusServiceUnavailable, "FE_NOT_CONFIGURED", facturacion.ErrNotConfigured.Error())
+		return
+	}
+
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "INVALID_ID", "ID inválido")
+		return
+	}
+
+	invoice, err := h.service.IssueForQuote(r.Context(), uint(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			respondError(w, http.StatusNotFound, "NOT_FOUND", "Cotización no encontrada")
+		case errors.Is(err, facturacion.ErrQuoteNotConverted):
+			respondError(w, http.StatusConflict, "QUOTE_NOT_CONVERTED", err.Error())
+		case errors.Is(err, facturacion.ErrAlreadyInvoiced):
+			respondError(w, http.StatusConflict, "ALREADY_INVOICED", err.Error())
+		case errors.Is(err, facturacion.ErrMissingCabys):
+			respondError(w, http.StatusUnprocessableEntity, "MISSING_CABYS", err.Error())
+		default:
+			slog.Error("facturacion: error emitiendo", "quote_id", id, "error", err)
+			respondError(w, http.StatusInternalServerError, "INVOICE_ERROR", "Error al emitir factura")
+		}
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, map[string]interface{}{
+		"success": true,
+		"data":    invoice,
+	})
+}
+
+// GetInvoices lists invoices with pagination
+func (h *InvoiceHandler) GetInvoices(w http.ResponseWriter, r *http.Request) {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit < 1 || limit > 100 {
+		limit = 15
+	}
+
+	invoices, total, err := h.repo.FindAll(page, limit, r.URL.Query().Get("status"))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "LIST_ERROR", "Error al listar facturas")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data": map[string]interface{}{
+			"invoices": invoices,
+			"total":    total,
+			"page":     page,
+			"limit":    limit,
+		},
+	})
+}
+
+// GetInvoice returns a single invoice
+func (h *InvoiceHandler) GetInvoice(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "INVALID_ID", "ID inválido")
+		return
+	}
+
+	invoice, err := h.repo.FindByID(uint(id))
+	if err != nil {
+		respondError(w, http.StatusNotFound, "NOT_FOUND", "Factura no encontrada")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    invoice,
+	})
+}
+
+// DownloadInvoiceXML returns the signed XML as sent to Hacienda
+func (h *InvoiceHandler) DownloadInvoiceXML(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "INVALID_ID", "ID inválido")
+		return
+	}
+
+	invoice, err := h.repo.FindByID(uint(id))
+	if err != nil {
+		respondError(w, http.StatusNotFound, "NOT_FOUND", "Factura no encontrada")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.xml"`, invoice.Clave))
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(invoice.XMLSigned))
+}
+
+// RefreshInvoiceStatus polls Hacienda (or resends if the invoice never arrived)
+func (h *InvoiceHandler) RefreshInvoiceStatus(w http.ResponseWriter, r *http.Request) {
+	if h.service == nil {
+		respondError(w, http.StatusServiceUnavailable, "FE_NOT_CONFIGURED", facturacion.ErrNotConfigured.Error())
+		return
+	}
+
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "INVALID_ID", "ID inválido")
+		return
+	}
+
+	invoice, err := h.service.RefreshStatus(r.Context(), uint(id))
+	if err != nil {
+		if errors.Is(err, repository.ErrInvoiceNotFound) {
+			respondError(w, http.StatusNotFound, "NOT_FOUND", "Factura no encontrada")
+			return
+		}
+		respondError(w, http.StatusBadGateway, "HACIENDA_ERROR", err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    invoice,
+	})
+}
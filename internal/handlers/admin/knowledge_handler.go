@@ -0,0 +1,194 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+	"github.com/alonsoalpizar/fabricalaser/internal/repository"
+	"github.com/alonsoalpizar/fabricalaser/internal/services/knowledge"
+	"github.com/go-chi/chi/v5"
+)
+
+// KnowledgeHandler gestiona el CRUD admin de la base de conocimiento que usan
+// los agentes (tool buscar_conocimiento). Cada cambio invalida el índice.
+type KnowledgeHandler struct {
+	repo *repository.KnowledgeRepository
+	kb   *knowledge.Service
+}
+
+func NewKnowledgeHandler(kb *knowledge.Service) *KnowledgeHandler {
+	return &KnowledgeHandler{
+		repo: repository.NewKnowledgeRepository(),
+		kb:   kb,
+	}
+}
+
+// GetAll retorna todos los artículos (activos e inactivos). ?category= filtra.
+func (h *KnowledgeHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	articles, err := h.repo.FindAllAdmin(r.URL.Query().Get("category"))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "DB_ERROR", err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]any{"articles": articles, "total": len(articles)})
+}
+
+// Get retorna un artículo con los pasajes en que se parte para el índice.
+func (h *KnowledgeHandler) Get(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "INVALID_ID", "ID inválido")
+		return
+	}
+	article, err := h.repo.FindByID(uint(id))
+	if err != nil {
+		respondError(w, http.StatusNotFound, "NOT_FOUND", "Artículo no encontrado")
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]any{"article": article, "passages": h.passages(article)})
+}
+
+// Create crea un artículo.
+func (h *KnowledgeHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var article models.KBArticle
+	if err := json.NewDecoder(r.Body).Decode(&article); err != nil {
+		respondError(w, http.StatusBadRequest, "INVALID_BODY", err.Error())
+		return
+	}
+	if code, msg := validateArticle(&article); code != "" {
+		respondError(w, http.StatusBadRequest, code, msg)
+		return
+	}
+	article.ID = 0
+	article.IsActive = true
+	if err := h.repo.Create(&article); err != nil {
+		respondError(w, http.StatusInternalServerError, "DB_ERROR", err.Error())
+		return
+	}
+	h.kb.Invalidate()
+	respondJSON(w, http.StatusCreated, map[string]any{"article": article, "passages": h.passages(&article)})
+}
+
+// Update reemplaza un artículo existente.
+func (h *KnowledgeHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "INVALID_ID", "ID inválido")
+		return
+	}
+	existing, err := h.repo.FindByID(uint(id))
+	if err != nil {
+		respondError(w, http.StatusNotFound, "NOT_FOUND", "Artículo no encontrado")
+		return
+	}
+
+	var updates models.KBArticle
+	if err := json.NewDecoder(r.Body).Decode(&updates); err != nil {
+		respondError(w, http.StatusBadRequest, "INVALID_BODY", err.Error())
+		return
+	}
+	keepTags := updates.Tags == nil
+	if code, msg := validateArticle(&updates); code != "" {
+		respondError(w, http.StatusBadRequest, code, msg)
+		return
+	}
+
+	existing.Title = updates.Title
+	existing.Category = updates.Category
+	existing.Content = updates.Content
+	existing.Audience = updates.Audience
+	existing.IsActive = updates.IsActive
+	if !keepTags {
+		existing.Tags = updates.Tags
+	}
+
+	if err := h.repo.Update(existing); err != nil {
+		respondError(w, http.StatusInternalServerError, "DB_ERROR", err.Error())
+		return
+	}
+	h.kb.Invalidate()
+	respondJSON(w, http.StatusOK, map[string]any{"article": existing, "passages": h.passages(existing)})
+}
+
+// Delete desactiva un artículo (deja de indexarse).
+func (h *KnowledgeHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "INVALID_ID", "ID inválido")
+		return
+	}
+	if err := h.repo.Delete(uint(id)); err != nil {
+		respondError(w, http.StatusInternalServerError, "DB_ERROR", err.Error())
+		return
+	}
+	h.kb.Invalidate()
+	respondJSON(w, http.StatusOK, map[string]any{"deleted": true})
+}
+
+// Search prueba la búsqueda tal como la ve el agente: ?q=&limit=&internal=true
+func (h *KnowledgeHandler) Search(w http.ResponseWriter, r *http.Request) {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" {
+		respondError(w, http.StatusBadRequest, "MISSING_FIELDS", "q es obligatorio")
+		return
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	internal := r.URL.Query().Get("internal") == "true"
+
+	results, err := h.kb.Search(q, limit, internal)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "DB_ERROR", err.Error())
+		return
+	}
+	out := make([]map[string]any, len(results))
+	for i, res := range results {
+		out[i] = map[string]any{
+			"article_id": res.ArticleID,
+			"citation":   res.Citation(),
+			"text":       res.Text,
+			"score":      res.Score,
+		}
+	}
+	respondJSON(w, http.StatusOK, map[string]any{"results": out, "total": len(out)})
+}
+
+// passages resume cómo se parte el artículo en el índice
+func (h *KnowledgeHandler) passages(a *models.KBArticle) []map[string]any {
+	passages := h.kb.Preview(*a)
+	out := make([]map[string]any, len(passages))
+	for i, p := range passages {
+		out[i] = map[string]any{"citation": p.Citation(), "text": p.Text}
+	}
+	return out
+}
+
+// validateArticle completa defaults y valida campos obligatorios; retorna el
+// código de error ("" si es válido)
+func validateArticle(a *models.KBArticle) (string, string) {
+	a.Title = strings.TrimSpace(a.Title)
+	a.Content = strings.TrimSpace(a.Content)
+	if a.Title == "" || a.Content == "" {
+		return "MISSING_FIELDS", "title y content son obligatorios"
+	}
+	if a.Category == "" {
+		a.Category = "faq"
+	}
+	if a.Audience == "" {
+		a.Audience = models.KBAudiencePublic
+	}
+	if a.Audience != models.KBAudiencePublic && a.Audience != models.KBAudienceInternal {
+		return "INVALID_AUDIENCE", "audience debe ser 'public' o 'internal'"
+	}
+	if a.Tags == nil {
+		a.Tags = []byte("[]")
+	} else {
+		var tags []string
+		if err := json.Unmarshal(a.Tags, &tags); err != nil {
+			return "INVALID_TAGS", "tags debe ser un array de strings"
+		}
+	}
+	return "", ""
+}
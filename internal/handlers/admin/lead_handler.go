@@ -0,0 +1,161 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/channels"
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+	"github.com/alonsoalpizar/fabricalaser/internal/repository"
+	"github.com/go-chi/chi/v5"
+)
+
+// funnelWeeks es el rango por defecto del reporte de embudo
+const funnelWeeks = 12
+
+// LeadHandler expone los leads de las conversaciones del bot y el embudo de ventas
+type LeadHandler struct {
+	repo *repository.LeadRepository
+}
+
+func NewLeadHandler() *LeadHandler {
+	return &LeadHandler{repo: repository.NewLeadRepository()}
+}
+
+// GET /api/v1/admin/leads?stage=hot&channel=whatsapp&page=1&limit=20
+func (h *LeadHandler) GetLeads(w http.ResponseWriter, r *http.Request) {
+	stage := models.LeadStage(r.URL.Query().Get("stage"))
+	switch stage {
+	case "", models.LeadNew, models.LeadQuoted, models.LeadHot, models.LeadConverted:
+	default:
+		respondError(w, http.StatusBadRequest, "INVALID_STAGE", "stage debe ser new, quoted, hot o converted")
+		return
+	}
+	channel, ok := leadChannel(w, r)
+	if !ok {
+		return
+	}
+	page := queryInt(r, "page", 1)
+	limit := queryInt(r, "limit", 20)
+	if limit > 100 {
+		limit = 100
+	}
+
+	leads, total, err := h.repo.FindAll(page, limit, stage, channel)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "DB_ERROR", "Error al obtener leads")
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    leads,
+		"total":   total,
+		"page":    page,
+		"limit":   limit,
+	})
+}
+
+// GET /api/v1/admin/leads/{id}
+// Incluye la cuenta asociada y las cotizaciones ligadas. Los mensajes se leen con
+// /whatsapp/conversations/{conversation_key}.
+func (h *LeadHandler) GetLead(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "INVALID_ID", "ID inválido")
+		return
+	}
+	lead, err := h.repo.FindByID(uint(id))
+	if err != nil {
+		if errors.Is(err, repository.ErrLeadNotFound) {
+			respondError(w, http.StatusNotFound, "NOT_FOUND", err.Error())
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "DB_ERROR", "Error al obtener el lead")
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"success": true, "data": lead})
+}
+
+// GET /api/v1/admin/leads/funnel?from=2026-01-05&to=2026-03-30&channel=telegram
+// Contactos → cotizados → escalados → convertidos, por semana del primer contacto
+// y canal. Sin fechas: las últimas 12 semanas. to es inclusive.
+func (h *LeadHandler) GetFunnel(w http.ResponseWriter, r *http.Request) {
+	channel, ok := leadChannel(w, r)
+	if !ok {
+		return
+	}
+	to := time.Now().AddDate(0, 0, 1)
+	if raw := r.URL.Query().Get("to"); raw != "" {
+		t, err := time.Parse("2006-01-02", raw)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "INVALID_DATE", "to debe tener formato YYYY-MM-DD")
+			return
+		}
+		to = t.AddDate(0, 0, 1)
+	}
+	from := to.AddDate(0, 0, -7*funnelWeeks)
+	if raw := r.URL.Query().Get("from"); raw != "" {
+		t, err := time.Parse("2006-01-02", raw)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "INVALID_DATE", "from debe tener formato YYYY-MM-DD")
+			return
+		}
+		from = t
+	}
+	if !from.Before(to) {
+		respondError(w, http.StatusBadRequest, "INVALID_RANGE", "from debe ser anterior a to")
+		return
+	}
+
+	rows, err := h.repo.Funnel(from, to, channel)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "DB_ERROR", "Error al calcular el embudo")
+		return
+	}
+	if rows == nil {
+		rows = []models.LeadFunnelRow{}
+	}
+
+	totals := models.LeadFunnelRow{Channel: channel}
+	for _, row := range rows {
+		totals.Contacts += row.Contacts
+		totals.Quoted += row.Quoted
+		totals.Escalated += row.Escalated
+		totals.Converted += row.Converted
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    rows,
+		"totals": map[string]interface{}{
+			"contacts":        totals.Contacts,
+			"quoted":          totals.Quoted,
+			"escalated":       totals.Escalated,
+			"converted":       totals.Converted,
+			"quote_rate":      funnelRate(totals.Quoted, totals.Contacts),
+			"escalate_rate":   funnelRate(totals.Escalated, totals.Contacts),
+			"conversion_rate": funnelRate(totals.Converted, totals.Contacts),
+		},
+		"from": from.Format("2006-01-02"),
+		"to":   to.AddDate(0, 0, -1).Format("2006-01-02"),
+	})
+}
+
+func leadChannel(w http.ResponseWriter, r *http.Request) (string, bool) {
+	channel := r.URL.Query().Get("channel")
+	switch channel {
+	case "", channels.WhatsApp, channels.Telegram:
+		return channel, true
+	}
+	respondError(w, http.StatusBadRequest, "INVALID_CHANNEL", "channel debe ser whatsapp o telegram")
+	return "", false
+}
+
+// funnelRate: porcentaje con un decimal; 0 sin contactos
+func funnelRate(part, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(part*1000/total) / 10
+}
@@ -35,9 +35,9 @@ func (h *MaterialCostHandler) GetMaterialCosts(w http.ResponseWriter, r *http.Re
 			respondError(w, http.StatusBadRequest, "INVALID_PARAM", "material_id invalido")
 			return
 		}
-		costs, err = h.repo.FindByMaterial(uint(materialID))
+		costs, err = h.repo.WithContext(r.Context()).FindByMaterial(uint(materialID))
 	} else {
-		costs, err = h.repo.FindAll()
+		costs, err = h.repo.WithContext(r.Context()).FindAll()
 	}
 
 	if err != nil {
@@ -59,7 +59,7 @@ func (h *MaterialCostHandler) GetMaterialCost(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	cost, err := h.repo.FindByID(uint(id))
+	cost, err := h.repo.WithContext(r.Context()).FindByID(uint(id))
 	if err != nil {
 		respondError(w, http.StatusNotFound, "NOT_FOUND", "Costo de material no encontrado")
 		return
@@ -82,6 +82,7 @@ func (h *MaterialCostHandler) CreateMaterialCost(w http.ResponseWriter, r *http.
 		SheetWidthMm  *float64 `json:"sheet_width_mm"`
 		SheetHeightMm *float64 `json:"sheet_height_mm"`
 		Notes         string   `json:"notes"`
+		Currency      string   `json:"currency"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -93,6 +94,10 @@ func (h *MaterialCostHandler) CreateMaterialCost(w http.ResponseWriter, r *http.
 		respondError(w, http.StatusBadRequest, "MISSING_FIELDS", "material_id es requerido")
 		return
 	}
+	if req.Currency != "" && !models.IsSupportedCurrency(req.Currency) {
+		respondError(w, http.StatusBadRequest, "INVALID_CURRENCY", "Moneda no soportada (CRC o USD)")
+		return
+	}
 
 	cost := &models.MaterialCost{
 		MaterialID:    req.MaterialID,
@@ -102,6 +107,7 @@ func (h *MaterialCostHandler) CreateMaterialCost(w http.ResponseWriter, r *http.
 		SheetWidthMm:  req.SheetWidthMm,
 		SheetHeightMm: req.SheetHeightMm,
 		IsActive:      true,
+		Currency:      models.NormalizeCurrency(req.Currency),
 	}
 
 	if req.WastePct != nil {
@@ -125,7 +131,7 @@ func (h *MaterialCostHandler) CreateMaterialCost(w http.ResponseWriter, r *http.
 		return
 	}
 
-	if err := h.repo.Create(cost); err != nil {
+	if err := h.repo.WithContext(r.Context()).Create(cost); err != nil {
 		respondError(w, http.StatusInternalServerError, "CREATE_ERROR", "Error al crear costo de material")
 		return
 	}
@@ -147,7 +153,7 @@ func (h *MaterialCostHandler) UpdateMaterialCost(w http.ResponseWriter, r *http.
 		return
 	}
 
-	cost, err := h.repo.FindByID(uint(id))
+	cost, err := h.repo.WithContext(r.Context()).FindByID(uint(id))
 	if err != nil {
 		respondError(w, http.StatusNotFound, "NOT_FOUND", "Costo de material no encontrado")
 		return
@@ -161,6 +167,7 @@ func (h *MaterialCostHandler) UpdateMaterialCost(w http.ResponseWriter, r *http.
 		SheetHeightMm *float64 `json:"sheet_height_mm"`
 		Notes         *string  `json:"notes"`
 		IsActive      *bool    `json:"is_active"`
+		Currency      *string  `json:"currency"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -189,8 +196,15 @@ func (h *MaterialCostHandler) UpdateMaterialCost(w http.ResponseWriter, r *http.
 	if req.IsActive != nil {
 		cost.IsActive = *req.IsActive
 	}
+	if req.Currency != nil {
+		if !models.IsSupportedCurrency(*req.Currency) {
+			respondError(w, http.StatusBadRequest, "INVALID_CURRENCY", "Moneda no soportada (CRC o USD)")
+			return
+		}
+		cost.Currency = models.NormalizeCurrency(*req.Currency)
+	}
 
-	if err := h.repo.Update(cost); err != nil {
+	if err := h.repo.WithContext(r.Context()).Update(cost); err != nil {
 		respondError(w, http.StatusInternalServerError, "UPDATE_ERROR", "Error al actualizar costo de material")
 		return
 	}
@@ -209,7 +223,7 @@ func (h *MaterialCostHandler) DeleteMaterialCost(w http.ResponseWriter, r *http.
 		return
 	}
 
-	if err := h.repo.Delete(uint(id)); err != nil {
+	if err := h.repo.WithContext(r.Context()).Delete(uint(id)); err != nil {
 		respondError(w, http.StatusInternalServerError, "DELETE_ERROR", "Error al eliminar costo de material")
 		return
 	}
@@ -228,7 +242,7 @@ func (h *MaterialCostHandler) RecalculateMaterialCost(w http.ResponseWriter, r *
 		return
 	}
 
-	cost, err := h.repo.FindByID(uint(id))
+	cost, err := h.repo.WithContext(r.Context()).FindByID(uint(id))
 	if err != nil {
 		respondError(w, http.StatusNotFound, "NOT_FOUND", "Costo de material no encontrado")
 		return
@@ -249,7 +263,7 @@ func (h *MaterialCostHandler) RecalculateMaterialCost(w http.ResponseWriter, r *
 	newCostPerMm2 := *cost.SheetCost / area
 	cost.CostPerMm2 = newCostPerMm2
 
-	if err := h.repo.Update(cost); err != nil {
+	if err := h.repo.WithContext(r.Context()).Update(cost); err != nil {
 		respondError(w, http.StatusInternalServerError, "UPDATE_ERROR", "Error al actualizar costo")
 		return
 	}
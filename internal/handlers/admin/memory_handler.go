@@ -0,0 +1,127 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/services/memory"
+	"github.com/go-chi/chi/v5"
+)
+
+// MemoryHandler expone la memoria de largo plazo de los clientes del bot:
+// ver qué recuerda el agente, corregirlo y borrarlo ante un pedido de privacidad.
+type MemoryHandler struct {
+	memory *memory.Service
+}
+
+func NewMemoryHandler(mem *memory.Service) *MemoryHandler {
+	return &MemoryHandler{memory: mem}
+}
+
+// memoryUpdateRequest es la corrección manual de una memoria
+type memoryUpdateRequest struct {
+	memory.Profile
+	LastSession string `json:"last_session"`
+}
+
+// GET /api/v1/admin/customer-memories?q=&limit=&offset=
+func (h *MemoryHandler) List(w http.ResponseWriter, r *http.Request) {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit < 1 || limit > 100 {
+		limit = 30
+	}
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+	if offset < 0 {
+		offset = 0
+	}
+
+	memories, total, err := h.memory.List(strings.TrimSpace(r.URL.Query().Get("q")), limit, offset)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "DB_ERROR", err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]any{"memories": memories, "total": total})
+}
+
+// GET /api/v1/admin/customer-memories/{key}
+// Incluye el bloque tal como lo recibe el agente.
+func (h *MemoryHandler) Get(w http.ResponseWriter, r *http.Request) {
+	key, ok := memoryKey(w, r)
+	if !ok {
+		return
+	}
+	m, err := h.memory.Get(key)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "DB_ERROR", err.Error())
+		return
+	}
+	if m == nil {
+		respondError(w, http.StatusNotFound, "NOT_FOUND", "El contacto no tiene memoria")
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]any{
+		"memory":  m,
+		"profile": memory.ProfileOf(m),
+		"prompt":  memory.Render(m, memory.DefaultBudget),
+	})
+}
+
+// PUT /api/v1/admin/customer-memories/{key}
+func (h *MemoryHandler) Update(w http.ResponseWriter, r *http.Request) {
+	key, ok := memoryKey(w, r)
+	if !ok {
+		return
+	}
+	var req memoryUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "INVALID_BODY", err.Error())
+		return
+	}
+
+	m, err := h.memory.Edit(key, req.Profile, req.LastSession, adminUserID(r))
+	if errors.Is(err, memory.ErrNotFound) {
+		respondError(w, http.StatusNotFound, "NOT_FOUND", "El contacto no tiene memoria")
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "DB_ERROR", err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]any{"memory": m, "profile": memory.ProfileOf(m)})
+}
+
+// DELETE /api/v1/admin/customer-memories/{key}?opt_out=true
+// Borra lo que el bot recuerda del contacto; opt_out=true además deja de guardar
+// memoria de sus próximas conversaciones.
+func (h *MemoryHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	key, ok := memoryKey(w, r)
+	if !ok {
+		return
+	}
+	optOut := r.URL.Query().Get("opt_out") == "true"
+
+	m, err := h.memory.Forget(key, optOut, adminUserID(r))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "DB_ERROR", err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]any{"deleted": true, "opted_out": m.OptedOut})
+}
+
+// memoryKey lee la clave de conversación ("tg:" llega escapado en la URL)
+func memoryKey(w http.ResponseWriter, r *http.Request) (string, bool) {
+	raw := chi.URLParam(r, "key")
+	key, err := url.PathUnescape(raw)
+	if err != nil {
+		key = raw
+	}
+	if key == "" || len(key) > 40 {
+		respondError(w, http.StatusBadRequest, "INVALID_KEY", "Clave de conversación inválida")
+		return "", false
+	}
+	return key, true
+}
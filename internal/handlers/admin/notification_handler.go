@@ -0,0 +1,88 @@
+package admin
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+	"github.com/alonsoalpizar/fabricalaser/internal/repository"
+	"github.com/alonsoalpizar/fabricalaser/internal/services/notifications"
+	"github.com/go-chi/chi/v5"
+)
+
+// NotificationHandler expone la bitácora de avisos proactivos a clientes
+type NotificationHandler struct {
+	repo       *repository.NotificationRepository
+	dispatcher *notifications.Dispatcher
+}
+
+func NewNotificationHandler(dispatcher *notifications.Dispatcher) *NotificationHandler {
+	return &NotificationHandler{
+		repo:       repository.NewNotificationRepository(),
+		dispatcher: dispatcher,
+	}
+}
+
+// GET /api/v1/admin/notifications?status=failed&user_id=12&page=1&limit=20
+func (h *NotificationHandler) GetNotifications(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	switch models.NotificationStatus(status) {
+	case "", models.NotificationPending, models.NotificationSent, models.NotificationFailed, models.NotificationSkipped:
+	default:
+		respondError(w, http.StatusBadRequest, "INVALID_STATUS", "status debe ser pending, sent, failed o skipped")
+		return
+	}
+	page := queryInt(r, "page", 1)
+	limit := queryInt(r, "limit", 20)
+	if limit > 100 {
+		limit = 100
+	}
+
+	items, total, err := h.repo.WithContext(r.Context()).FindAll(page, limit, status, uint(queryInt(r, "user_id", 0)))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "DB_ERROR", "Error al obtener avisos")
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    items,
+		"total":   total,
+		"page":    page,
+		"limit":   limit,
+	})
+}
+
+// POST /api/v1/admin/notifications/{id}/retry
+func (h *NotificationHandler) Retry(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "INVALID_ID", "ID inválido")
+		return
+	}
+
+	item, err := h.dispatcher.Retry(r.Context(), uint(id))
+	switch {
+	case errors.Is(err, repository.ErrNotificationNotFound):
+		respondError(w, http.StatusNotFound, "NOT_FOUND", err.Error())
+		return
+	case errors.Is(err, notifications.ErrNotRetryable):
+		respondError(w, http.StatusConflict, "NOT_RETRYABLE", err.Error())
+		return
+	case err != nil:
+		slog.Error("notifications: error reintentando aviso", "id", id, "error", err)
+		respondError(w, http.StatusInternalServerError, "RETRY_ERROR", "Error al reintentar el aviso")
+		return
+	}
+
+	message := "Aviso enviado"
+	if item.Status != models.NotificationSent {
+		message = "El envío falló de nuevo; quedó programado un reintento"
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    item,
+		"message": message,
+	})
+}
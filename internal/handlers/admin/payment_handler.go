@@ -0,0 +1,417 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/repository"
+	"github.com/alonsoalpizar/fabricalaser/internal/services/payments"
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+)
+
+const (
+	maxStatementFileSize = 5 * 1024 * 1024 // 5MB
+	maxWebhookBodySize   = 64 * 1024
+)
+
+type PaymentHandler struct {
+	repo    *repository.PaymentRepository
+	service *payments.Service
+}
+
+func NewPaymentHandler(service *payments.Service) *PaymentHandler {
+	return &PaymentHandler{
+		repo:    repository.NewPaymentRepository(),
+		service: service,
+	}
+}
+
+// GetProviders lists the enabled payment providers
+func (h *PaymentHandler) GetProviders(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    h.service.Providers(),
+	})
+}
+
+// CreateIntent opens a payment (adelanto, saldo o total) for an order
+func (h *PaymentHandler) CreateIntent(w http.ResponseWriter, r *http.Request) {
+	quoteID, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "INVALID_ID", "ID inválido")
+		return
+	}
+
+	var req struct {
+		Kind     string  `json:"kind"`     // deposit, balance, full (default)
+		Provider string  `json:"provider"` // sinpe (default), card
+		Amount   float64 `json:"amount"`   // 0 = según kind
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "INVALID_JSON", "JSON inválido")
+		return
+	}
+	if req.Provider == "" {
+		req.Provider = "sinpe"
+	}
+
+	intent, err := h.service.CreateIntent(r.Context(), uint(quoteID), req.Kind, req.Provider, req.Amount)
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			respondError(w, http.StatusNotFound, "NOT_FOUND", "Cotización no encontrada")
+		case errors.Is(err, payments.ErrUnknownProvider), errors.Is(err, payments.ErrInvalidKind),
+			errors.Is(err, payments.ErrInvalidAmount):
+			respondError(w, http.StatusBadRequest, "INVALID_PAYMENT", err.Error())
+		case errors.Is(err, payments.ErrQuoteNotPayable), errors.Is(err, payments.ErrNothingToPay):
+			respondError(w, http.StatusConflict, "NOT_PAYABLE", err.Error())
+		default:
+			slog.Error("payments: error creando cobro", "quote_id", quoteID, "error", err)
+			respondError(w, http.StatusInternalServerError, "PAYMENT_ERROR", "Error al crear el cobro")
+		}
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, map[string]interface{}{
+		"success": true,
+		"data": map[string]interface{}{
+			"intent":       intent,
+			"instructions": h.service.Instructions(intent),
+		},
+	})
+}
+
+// GetQuoteIntents lists the payments of an order
+func (h *PaymentHandler) GetQuoteIntents(w http.ResponseWriter, r *http.Request) {
+	quoteID, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "INVALID_ID", "ID inválido")
+		return
+	}
+
+	intents, err := h.repo.FindIntentsByQuoteID(uint(quoteID))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "LIST_ERROR", "Error al listar cobros")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    intents,
+	})
+}
+
+// GetIntents lists payment intents with pagination
+func (h *PaymentHandler) GetIntents(w http.ResponseWriter, r *http.Request) {
+	page, limit := paymentPagination(r)
+	intents, total, err := h.repo.FindAllIntents(page, limit, r.URL.Query().Get("status"))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "LIST_ERROR", "Error al listar cobros")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data": map[string]interface{}{
+			"intents": intents,
+			"total":   total,
+			"page":    page,
+			"limit":   limit,
+		},
+	})
+}
+
+// CancelIntent cancels a pending intent
+func (h *PaymentHandler) CancelIntent(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "INVALID_ID", "ID inválido")
+		return
+	}
+
+	intent, err := h.service.CancelIntent(uint(id))
+	if err != nil {
+		h.respondPaymentError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    intent,
+	})
+}
+
+// RefundIntent refunds part or all of an intent
+func (h *PaymentHandler) RefundIntent(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "INVALID_ID", "ID inválido")
+		return
+	}
+
+	var req struct {
+		Amount float64 `json:"amount"` // 0 = todo lo cobrado
+		Reason string  `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "INVALID_JSON", "JSON inválido")
+		return
+	}
+
+	var adminID *uint
+	if id, ok := r.Context().Value("userID").(uint); ok {
+		adminID = &id
+	}
+
+	refund, err := h.service.Refund(r.Context(), uint(id), req.Amount, req.Reason, adminID)
+	if err != nil {
+		h.respondPaymentError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, map[string]interface{}{
+		"success": true,
+		"data":    refund,
+	})
+}
+
+// CompleteRefund confirms a manual (SINPE) refund once the transfer is done
+func (h *PaymentHandler) CompleteRefund(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "INVALID_ID", "ID inválido")
+		return
+	}
+
+	var req struct {
+		Success     *bool  `json:"success"` // default true
+		ProviderRef string `json:"provider_ref"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "INVALID_JSON", "JSON inválido")
+		return
+	}
+	success := req.Success == nil || *req.Success
+
+	refund, err := h.service.CompleteRefund(uint(id), success, req.ProviderRef)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			respondError(w, http.StatusNotFound, "NOT_FOUND", "Devolución no encontrada")
+			return
+		}
+		h.respondPaymentError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    refund,
+	})
+}
+
+// ImportStatement imports a bank statement CSV and reconciles it.
+// Accepts multipart (campo "file") or the raw CSV as body.
+func (h *PaymentHandler) ImportStatement(w http.ResponseWriter, r *http.Request) {
+	var body io.Reader = io.LimitReader(r.Body, maxStatementFileSize)
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := r.ParseMultipartForm(maxStatementFileSize); err != nil {
+			respondError(w, http.StatusBadRequest, "INVALID_REQUEST", "Error leyendo formulario")
+			return
+		}
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "NO_FILE", "Falta el archivo (campo file)")
+			return
+		}
+		defer file.Close()
+		body = io.LimitReader(file, maxStatementFileSize)
+	}
+
+	parsed, err := payments.ParseStatementCSV(body)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "INVALID_FILE", err.Error())
+		return
+	}
+	h.importParsed(w, parsed)
+}
+
+// ImportSINPEText imports pasted SINPE Móvil SMS/notifications and reconciles them
+func (h *PaymentHandler) ImportSINPEText(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Text string `json:"text"`
+	}
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxStatementFileSize)).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "INVALID_JSON", "JSON inválido")
+		return
+	}
+
+	parsed, err := payments.ParseSINPEText(req.Text, time.Now())
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "INVALID_TEXT", err.Error())
+		return
+	}
+	h.importParsed(w, parsed)
+}
+
+func (h *PaymentHandler) importParsed(w http.ResponseWriter, parsed *payments.ParseResult) {
+	result, err := h.service.Import(parsed)
+	if err != nil {
+		slog.Error("payments: error importando movimientos", "error", err)
+		respondError(w, http.StatusInternalServerError, "IMPORT_ERROR", "Error al importar movimientos")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    result,
+	})
+}
+
+// RunReconciliation expires stale intents and retries unmatched movements now
+func (h *PaymentHandler) RunReconciliation(w http.ResponseWriter, r *http.Request) {
+	expired, matched, err := h.service.RunReconciliation()
+	if err != nil {
+		slog.Error("payments: error en conciliación", "error", err)
+		respondError(w, http.StatusInternalServerError, "RECONCILE_ERROR", "Error al conciliar")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data": map[string]interface{}{
+			"expired": expired,
+			"matched": matched,
+		},
+	})
+}
+
+// GetTransactions lists imported movements (?status=unmatched para la bandeja de revisión)
+func (h *PaymentHandler) GetTransactions(w http.ResponseWriter, r *http.Request) {
+	page, limit := paymentPagination(r)
+	txs, total, err := h.repo.FindAllTransactions(page, limit, r.URL.Query().Get("status"))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "LIST_ERROR", "Error al listar movimientos")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data": map[string]interface{}{
+			"transactions": txs,
+			"total":        total,
+			"page":         page,
+			"limit":        limit,
+		},
+	})
+}
+
+// MatchTransaction assigns a movement to an intent by hand
+func (h *PaymentHandler) MatchTransaction(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "INVALID_ID", "ID inválido")
+		return
+	}
+
+	var req struct {
+		IntentID uint `json:"intent_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.IntentID == 0 {
+		respondError(w, http.StatusBadRequest, "INVALID_JSON", "intent_id es requerido")
+		return
+	}
+
+	intent, err := h.service.MatchManually(uint(id), req.IntentID)
+	if err != nil {
+		h.respondPaymentError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    intent,
+	})
+}
+
+// IgnoreTransaction marks a movement that is not a customer payment
+func (h *PaymentHandler) IgnoreTransaction(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "INVALID_ID", "ID inválido")
+		return
+	}
+
+	tx, err := h.service.IgnoreTransaction(uint(id))
+	if err != nil {
+		h.respondPaymentError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    tx,
+	})
+}
+
+// HandleWebhook receives gateway notifications (POST /api/v1/payments/webhook/{provider}).
+// Público: la autenticidad se valida con la firma del proveedor.
+func (h *PaymentHandler) HandleWebhook(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBodySize))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "INVALID_REQUEST", "Error leyendo el cuerpo")
+		return
+	}
+
+	provider := chi.URLParam(r, "provider")
+	if err := h.service.HandleWebhook(r.Context(), provider, body, r.Header); err != nil {
+		switch {
+		case errors.Is(err, payments.ErrInvalidSignature):
+			respondError(w, http.StatusUnauthorized, "INVALID_SIGNATURE", err.Error())
+		case errors.Is(err, payments.ErrUnknownProvider), errors.Is(err, payments.ErrWebhookUnsupported):
+			respondError(w, http.StatusNotFound, "UNKNOWN_PROVIDER", err.Error())
+		case errors.Is(err, repository.ErrPaymentIntentNotFound):
+			respondError(w, http.StatusNotFound, "NOT_FOUND", err.Error())
+		default:
+			slog.Error("payments: error procesando webhook", "provider", provider, "error", err)
+			respondError(w, http.StatusInternalServerError, "WEBHOOK_ERROR", "Error procesando webhook")
+		}
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{"success": true})
+}
+
+func (h *PaymentHandler) respondPaymentError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, repository.ErrPaymentIntentNotFound), errors.Is(err, repository.ErrPaymentTransactionNotFound):
+		respondError(w, http.StatusNotFound, "NOT_FOUND", err.Error())
+	case errors.Is(err, payments.ErrInvalidAmount), errors.Is(err, payments.ErrRefundExceeds),
+		errors.Is(err, payments.ErrUnknownProvider):
+		respondError(w, http.StatusBadRequest, "INVALID_PAYMENT", err.Error())
+	case errors.Is(err, payments.ErrIntentNotPending), errors.Is(err, payments.ErrAlreadyMatched),
+		errors.Is(err, payments.ErrRefundNotPending):
+		respondError(w, http.StatusConflict, "INVALID_STATE", err.Error())
+	default:
+		slog.Error("payments: error", "error", err)
+		respondError(w, http.StatusInternalServerError, "PAYMENT_ERROR", "Error procesando el pago")
+	}
+}
+
+func paymentPagination(r *http.Request) (page, limit int) {
+	page, _ = strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	limit, _ = strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit < 1 || limit > 100 {
+		limit = 15
+	}
+	return page, limit
+}
@@ -0,0 +1,67 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/services/quotedraft"
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+)
+
+// QuoteDraftHandler ajusta los borradores de cotización que arma el bot. Para
+// aprobarlos se usa PUT /quotes/{id} como con cualquier cotización.
+type QuoteDraftHandler struct {
+	service *quotedraft.Service
+}
+
+func NewQuoteDraftHandler(service *quotedraft.Service) *QuoteDraftHandler {
+	return &QuoteDraftHandler{service: service}
+}
+
+// PUT /api/v1/admin/quotes/{id}/draft
+// Body: mismos campos que extrae el bot (ancho_cm, alto_cm, cantidad, material_id,
+// thickness, technology_id, engrave_type_id, incluye_corte, material_included, ...)
+// más user_id para asignar la cuenta del cliente. Solo se cambian los campos enviados.
+func (h *QuoteDraftHandler) AdjustDraft(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "INVALID_ID", "ID inválido")
+		return
+	}
+	var req struct {
+		quotedraft.Params
+		UserID *uint `json:"user_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "INVALID_JSON", "JSON inválido")
+		return
+	}
+
+	quote, err := h.service.Adjust(uint(id), req.Params, req.UserID)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		respondError(w, http.StatusNotFound, "NOT_FOUND", "Cotización no encontrada")
+		return
+	case errors.Is(err, quotedraft.ErrNotDraft):
+		respondError(w, http.StatusConflict, "NOT_DRAFT", err.Error())
+		return
+	case errors.Is(err, quotedraft.ErrIncomplete), errors.Is(err, quotedraft.ErrIncompatible),
+		errors.Is(err, quotedraft.ErrUserNotFound):
+		respondError(w, http.StatusBadRequest, "INVALID_DRAFT", err.Error())
+		return
+	case err != nil:
+		slog.Error("quotedraft: error ajustando borrador", "id", id, "error", err)
+		respondError(w, http.StatusInternalServerError, "UPDATE_ERROR", "Error al ajustar el borrador")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    quote.ToDetailedJSON(),
+		"message": "Borrador recalculado",
+	})
+}
@@ -22,7 +22,7 @@ func NewSystemConfigHandler() *SystemConfigHandler {
 
 // GetSystemConfigs returns all system configurations
 func (h *SystemConfigHandler) GetSystemConfigs(w http.ResponseWriter, r *http.Request) {
-	configs, err := h.repo.FindAll()
+	configs, err := h.repo.WithContext(r.Context()).FindAll()
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "LIST_ERROR", "Error al listar configuraciones")
 		return
@@ -42,7 +42,7 @@ func (h *SystemConfigHandler) GetSystemConfig(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	config, err := h.repo.FindByID(uint(id))
+	config, err := h.repo.WithContext(r.Context()).FindByID(uint(id))
 	if err != nil {
 		respondError(w, http.StatusNotFound, "NOT_FOUND", "Configuracion no encontrada")
 		return
@@ -75,7 +75,7 @@ func (h *SystemConfigHandler) CreateSystemConfig(w http.ResponseWriter, r *http.
 	}
 
 	// Check if key already exists
-	existing, _ := h.repo.FindByKey(req.ConfigKey)
+	existing, _ := h.repo.WithContext(r.Context()).FindByKey(req.ConfigKey)
 	if existing != nil {
 		respondError(w, http.StatusConflict, "KEY_EXISTS", "Ya existe una configuracion con esta clave")
 		return
@@ -97,7 +97,7 @@ func (h *SystemConfigHandler) CreateSystemConfig(w http.ResponseWriter, r *http.
 		config.Description = &req.Description
 	}
 
-	if err := h.repo.Create(config); err != nil {
+	if err := h.repo.WithContext(r.Context()).Create(config); err != nil {
 		respondError(w, http.StatusInternalServerError, "CREATE_ERROR", "Error al crear configuracion")
 		return
 	}
@@ -116,7 +116,7 @@ func (h *SystemConfigHandler) UpdateSystemConfig(w http.ResponseWriter, r *http.
 		return
 	}
 
-	config, err := h.repo.FindByID(uint(id))
+	config, err := h.repo.WithContext(r.Context()).FindByID(uint(id))
 	if err != nil {
 		respondError(w, http.StatusNotFound, "NOT_FOUND", "Configuracion no encontrada")
 		return
@@ -143,7 +143,7 @@ func (h *SystemConfigHandler) UpdateSystemConfig(w http.ResponseWriter, r *http.
 		config.IsActive = *req.IsActive
 	}
 
-	if err := h.repo.Update(config); err != nil {
+	if err := h.repo.WithContext(r.Context()).Update(config); err != nil {
 		respondError(w, http.StatusInternalServerError, "UPDATE_ERROR", "Error al actualizar configuracion")
 		return
 	}
@@ -162,7 +162,7 @@ func (h *SystemConfigHandler) DeleteSystemConfig(w http.ResponseWriter, r *http.
 		return
 	}
 
-	if err := h.repo.Delete(uint(id)); err != nil {
+	if err := h.repo.WithContext(r.Context()).Delete(uint(id)); err != nil {
 		respondError(w, http.StatusInternalServerError, "DELETE_ERROR", "Error al eliminar configuracion")
 		return
 	}
@@ -39,9 +39,9 @@ func (h *TechMaterialSpeedHandler) GetTechMaterialSpeeds(w http.ResponseWriter,
 	var err error
 
 	if techID > 0 || materialID > 0 {
-		speeds, err = h.speedRepo.FindByTechAndMaterial(techID, materialID)
+		speeds, err = h.speedRepo.WithContext(r.Context()).FindByTechAndMaterial(techID, materialID)
 	} else {
-		speeds, err = h.speedRepo.FindAll()
+		speeds, err = h.speedRepo.WithContext(r.Context()).FindAll()
 	}
 
 	if err != nil {
@@ -63,7 +63,7 @@ func (h *TechMaterialSpeedHandler) GetTechMaterialSpeed(w http.ResponseWriter, r
 		return
 	}
 
-	speed, err := h.speedRepo.FindByID(uint(id))
+	speed, err := h.speedRepo.WithContext(r.Context()).FindByID(uint(id))
 	if err != nil {
 		respondError(w, http.StatusNotFound, "NOT_FOUND", "Configuracion de velocidad no encontrada")
 		return
@@ -84,6 +84,8 @@ func (h *TechMaterialSpeedHandler) CreateTechMaterialSpeed(w http.ResponseWriter
 		CutSpeedMmMin     *float64 `json:"cut_speed_mm_min"`
 		EngraveSpeedMmMin *float64 `json:"engrave_speed_mm_min"`
 		IsCompatible      *bool    `json:"is_compatible"`
+		EngraveRank       *int     `json:"engrave_rank"`
+		ColorMarking      bool     `json:"color_marking"`
 		Notes             string   `json:"notes"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -103,16 +105,20 @@ func (h *TechMaterialSpeedHandler) CreateTechMaterialSpeed(w http.ResponseWriter
 		CutSpeedMmMin:     req.CutSpeedMmMin,
 		EngraveSpeedMmMin: req.EngraveSpeedMmMin,
 		IsCompatible:      true, // default
+		ColorMarking:      req.ColorMarking,
 		IsActive:          true,
 	}
 	if req.IsCompatible != nil {
 		speed.IsCompatible = *req.IsCompatible
 	}
+	if req.EngraveRank != nil {
+		speed.EngraveRank = *req.EngraveRank
+	}
 	if req.Notes != "" {
 		speed.Notes = &req.Notes
 	}
 
-	if err := h.speedRepo.Create(speed); err != nil {
+	if err := h.speedRepo.WithContext(r.Context()).Create(speed); err != nil {
 		respondError(w, http.StatusInternalServerError, "CREATE_ERROR", "Error al crear configuracion de velocidad")
 		return
 	}
@@ -133,7 +139,7 @@ func (h *TechMaterialSpeedHandler) UpdateTechMaterialSpeed(w http.ResponseWriter
 		return
 	}
 
-	speed, err := h.speedRepo.FindByID(uint(id))
+	speed, err := h.speedRepo.WithContext(r.Context()).FindByID(uint(id))
 	if err != nil {
 		respondError(w, http.StatusNotFound, "NOT_FOUND", "Configuracion de velocidad no encontrada")
 		return
@@ -143,6 +149,8 @@ func (h *TechMaterialSpeedHandler) UpdateTechMaterialSpeed(w http.ResponseWriter
 		CutSpeedMmMin     *float64 `json:"cut_speed_mm_min"`
 		EngraveSpeedMmMin *float64 `json:"engrave_speed_mm_min"`
 		IsCompatible      *bool    `json:"is_compatible"`
+		EngraveRank       *int     `json:"engrave_rank"`
+		ColorMarking      *bool    `json:"color_marking"`
 		Notes             *string  `json:"notes"`
 		IsActive          *bool    `json:"is_active"`
 	}
@@ -161,6 +169,12 @@ func (h *TechMaterialSpeedHandler) UpdateTechMaterialSpeed(w http.ResponseWriter
 	if req.IsCompatible != nil {
 		speed.IsCompatible = *req.IsCompatible
 	}
+	if req.EngraveRank != nil {
+		speed.EngraveRank = *req.EngraveRank
+	}
+	if req.ColorMarking != nil {
+		speed.ColorMarking = *req.ColorMarking
+	}
 	if req.Notes != nil {
 		speed.Notes = req.Notes
 	}
@@ -168,7 +182,7 @@ func (h *TechMaterialSpeedHandler) UpdateTechMaterialSpeed(w http.ResponseWriter
 		speed.IsActive = *req.IsActive
 	}
 
-	if err := h.speedRepo.Update(speed); err != nil {
+	if err := h.speedRepo.WithContext(r.Context()).Update(speed); err != nil {
 		respondError(w, http.StatusInternalServerError, "UPDATE_ERROR", "Error al actualizar configuracion de velocidad")
 		return
 	}
@@ -187,7 +201,7 @@ func (h *TechMaterialSpeedHandler) DeleteTechMaterialSpeed(w http.ResponseWriter
 		return
 	}
 
-	if err := h.speedRepo.Delete(uint(id)); err != nil {
+	if err := h.speedRepo.WithContext(r.Context()).Delete(uint(id)); err != nil {
 		respondError(w, http.StatusInternalServerError, "DELETE_ERROR", "Error al eliminar configuracion de velocidad")
 		return
 	}
@@ -244,7 +258,7 @@ func (h *TechMaterialSpeedHandler) BulkCreateTechMaterialSpeeds(w http.ResponseW
 		}
 	}
 
-	if err := h.speedRepo.BulkCreate(speeds); err != nil {
+	if err := h.speedRepo.WithContext(r.Context()).BulkCreate(speeds); err != nil {
 		respondError(w, http.StatusInternalServerError, "CREATE_ERROR", "Error al crear configuraciones de velocidad")
 		return
 	}
@@ -0,0 +1,197 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+	"github.com/alonsoalpizar/fabricalaser/internal/repository"
+	"github.com/alonsoalpizar/fabricalaser/internal/services/webhooks"
+	"github.com/go-chi/chi/v5"
+)
+
+// WebhookHandler gestiona las suscripciones a webhooks del taller y su bitácora
+// de entregas, con prueba y reenvío manual.
+type WebhookHandler struct {
+	dispatcher *webhooks.Dispatcher
+}
+
+func NewWebhookHandler(dispatcher *webhooks.Dispatcher) *WebhookHandler {
+	return &WebhookHandler{dispatcher: dispatcher}
+}
+
+// GET /api/v1/admin/webhooks
+func (h *WebhookHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	endpoints, err := h.dispatcher.Endpoints(r.Context())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "DB_ERROR", err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]any{"webhooks": endpoints, "events": webhooks.EventTypes, "total": len(endpoints)})
+}
+
+// POST /api/v1/admin/webhooks
+// El secreto de firma solo viaja en esta respuesta.
+func (h *WebhookHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var in webhooks.EndpointInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		respondError(w, http.StatusBadRequest, "INVALID_BODY", err.Error())
+		return
+	}
+	endpoint, secret, err := h.dispatcher.CreateEndpoint(r.Context(), in, adminUserID(r))
+	if err != nil {
+		respondWebhookError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusCreated, map[string]any{
+		"webhook": endpoint,
+		"secret":  secret,
+		"message": "Guardá el secreto ahora: no se vuelve a mostrar",
+	})
+}
+
+// PUT /api/v1/admin/webhooks/{id}
+// is_active=true reactiva un endpoint deshabilitado por fallas.
+func (h *WebhookHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id, ok := webhookID(w, r)
+	if !ok {
+		return
+	}
+	var in webhooks.EndpointInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		respondError(w, http.StatusBadRequest, "INVALID_BODY", err.Error())
+		return
+	}
+	endpoint, err := h.dispatcher.UpdateEndpoint(r.Context(), id, in)
+	if err != nil {
+		respondWebhookError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]any{"webhook": endpoint})
+}
+
+// DELETE /api/v1/admin/webhooks/{id}
+func (h *WebhookHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, ok := webhookID(w, r)
+	if !ok {
+		return
+	}
+	if err := h.dispatcher.DeleteEndpoint(r.Context(), id); err != nil {
+		respondWebhookError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]any{"message": "Webhook eliminado"})
+}
+
+// POST /api/v1/admin/webhooks/{id}/rotate-secret
+func (h *WebhookHandler) RotateSecret(w http.ResponseWriter, r *http.Request) {
+	id, ok := webhookID(w, r)
+	if !ok {
+		return
+	}
+	secret, err := h.dispatcher.RotateSecret(r.Context(), id)
+	if err != nil {
+		respondWebhookError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]any{
+		"secret":  secret,
+		"message": "Guardá el secreto ahora: no se vuelve a mostrar",
+	})
+}
+
+// POST /api/v1/admin/webhooks/{id}/test
+// Envía un webhook.ping y retorna la entrega con la respuesta del endpoint.
+func (h *WebhookHandler) Test(w http.ResponseWriter, r *http.Request) {
+	id, ok := webhookID(w, r)
+	if !ok {
+		return
+	}
+	delivery, err := h.dispatcher.Test(r.Context(), id)
+	if err != nil {
+		respondWebhookError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]any{"delivery": delivery})
+}
+
+// GET /api/v1/admin/webhook-deliveries?endpoint_id=3&status=failed&page=1&limit=20
+func (h *WebhookHandler) GetDeliveries(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	switch models.WebhookStatus(status) {
+	case "", models.WebhookPending, models.WebhookSent, models.WebhookFailed:
+	default:
+		respondError(w, http.StatusBadRequest, "INVALID_STATUS", "status debe ser pending, sent o failed")
+		return
+	}
+	page := queryInt(r, "page", 1)
+	limit := queryInt(r, "limit", 20)
+	if limit > 100 {
+		limit = 100
+	}
+
+	items, total, err := h.dispatcher.Deliveries(r.Context(), page, limit, uint(queryInt(r, "endpoint_id", 0)), status)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "DB_ERROR", "Error al obtener entregas")
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]any{
+		"success": true,
+		"data":    items,
+		"total":   total,
+		"page":    page,
+		"limit":   limit,
+	})
+}
+
+// POST /api/v1/admin/webhook-deliveries/{id}/replay
+// Reenvía la entrega como una nueva, con el mismo event id y cuerpo.
+func (h *WebhookHandler) Replay(w http.ResponseWriter, r *http.Request) {
+	id, ok := webhookID(w, r)
+	if !ok {
+		return
+	}
+	delivery, err := h.dispatcher.Replay(r.Context(), id)
+	if err != nil {
+		respondWebhookError(w, err)
+		return
+	}
+
+	message := "Entrega reenviada"
+	if delivery.Status != models.WebhookSent {
+		message = "El reenvío falló; quedó programado un reintento"
+	}
+	respondJSON(w, http.StatusOK, map[string]any{
+		"success": true,
+		"data":    delivery,
+		"message": message,
+	})
+}
+
+func webhookID(w http.ResponseWriter, r *http.Request) (uint, bool) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "INVALID_ID", "ID inválido")
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func respondWebhookError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, repository.ErrWebhookNotFound), errors.Is(err, repository.ErrWebhookDeliveryNotFound):
+		respondError(w, http.StatusNotFound, "NOT_FOUND", err.Error())
+	case errors.Is(err, webhooks.ErrInvalidURL), errors.Is(err, webhooks.ErrInvalidEvent):
+		respondError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error())
+	case errors.Is(err, repository.ErrAPIKeyNotFound):
+		respondError(w, http.StatusBadRequest, "API_KEY_NOT_FOUND", err.Error())
+	case errors.Is(err, webhooks.ErrEndpointDisabled):
+		respondError(w, http.StatusConflict, "ENDPOINT_DISABLED", err.Error())
+	default:
+		slog.Error("webhooks: error en el panel", "error", err)
+		respondError(w, http.StatusInternalServerError, "DB_ERROR", err.Error())
+	}
+}
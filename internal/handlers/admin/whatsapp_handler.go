@@ -3,9 +3,11 @@ package admin
 import (
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 
 	"github.com/alonsoalpizar/fabricalaser/internal/repository"
 	"github.com/alonsoalpizar/fabricalaser/internal/whatsapp"
@@ -16,12 +18,14 @@ import (
 type WhatsappHandler struct {
 	waRepo *repository.WhatsappRepository
 	rc     *redis.Client
+	sender *whatsapp.Sender
 }
 
 func NewWhatsappHandler(rc *redis.Client) *WhatsappHandler {
 	return &WhatsappHandler{
 		waRepo: repository.NewWhatsappRepository(),
 		rc:     rc,
+		sender: whatsapp.NewSender(),
 	}
 }
 
@@ -183,10 +187,69 @@ func (h *WhatsappHandler) SendDigest(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// ─────────────────────────────────────────────
+// Templates (mensajes fuera de la ventana de 24h)
+// ─────────────────────────────────────────────
+
+// POST /api/v1/admin/whatsapp/template
+//
+// Body: {"phone":"88887777","template":"pedido_listo","language":"es","params":["Ana","#123"]}
+// La plantilla debe estar aprobada en Meta Business Manager; params llena {{1}}, {{2}}, ...
+func (h *WhatsappHandler) SendTemplate(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Phone    string   `json:"phone"`
+		Template string   `json:"template"`
+		Language string   `json:"language"`
+		Params   []string `json:"params"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "INVALID_INPUT", "Datos inválidos")
+		return
+	}
+	phone := normalizeWAPhone(req.Phone)
+	if phone == "" || req.Template == "" {
+		respondError(w, http.StatusBadRequest, "INVALID_INPUT", "phone y template son requeridos")
+		return
+	}
+	if req.Language == "" {
+		req.Language = "es"
+	}
+
+	if err := h.sender.SendTemplate(r.Context(), phone, req.Template, req.Language, req.Params); err != nil {
+		respondError(w, http.StatusBadGateway, "WHATSAPP_ERROR", err.Error())
+		return
+	}
+
+	// Queda en la bitácora junto al resto de la conversación
+	content := fmt.Sprintf("[plantilla %s] %s", req.Template, strings.Join(req.Params, " | "))
+	if err := h.waRepo.SaveMessage(phone, "model", content); err != nil {
+		slog.Error("whatsapp: error archivando plantilla enviada", "error", err, "phone", phone)
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    map[string]string{"phone": phone, "template": req.Template},
+	})
+}
+
 // ─────────────────────────────────────────────
 // Helpers
 // ─────────────────────────────────────────────
 
+// normalizeWAPhone deja solo dígitos y antepone 506 a números locales de 8 dígitos
+func normalizeWAPhone(phone string) string {
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, phone)
+	if len(digits) == 8 {
+		digits = "506" + digits
+	}
+	return digits
+}
+
 func queryInt(r *http.Request, key string, def int) int {
 	v := r.URL.Query().Get(key)
 	if v == "" {
@@ -6,6 +6,7 @@ import (
 	"regexp"
 	"strings"
 
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
 	authService "github.com/alonsoalpizar/fabricalaser/internal/services/auth"
 )
 
@@ -59,7 +60,7 @@ func (h *AuthHandler) VerificarCedula(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, err := h.service.VerificarCedula(req.Identificacion)
+	result, err := h.service.WithContext(r.Context()).VerificarCedula(req.Identificacion)
 	if err != nil {
 		code := "INVALID_CEDULA"
 		status := http.StatusBadRequest
@@ -96,7 +97,7 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, err := h.service.Login(req.Identificacion, req.Password)
+	result, err := h.service.WithContext(r.Context()).Login(req.Identificacion, req.Password)
 	if err != nil {
 		status := http.StatusUnauthorized
 		code := "AUTH_ERROR"
@@ -161,7 +162,7 @@ func (h *AuthHandler) Registro(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, err := h.service.Registro(
+	result, err := h.service.WithContext(r.Context()).Registro(
 		req.Identificacion,
 		strings.TrimSpace(req.Nombre),
 		strings.ToLower(strings.TrimSpace(req.Email)),
@@ -229,7 +230,7 @@ func (h *AuthHandler) EstablecerPassword(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	result, err := h.service.EstablecerPassword(
+	result, err := h.service.WithContext(r.Context()).EstablecerPassword(
 		req.Identificacion,
 		req.Password,
 		strings.ToLower(strings.TrimSpace(req.Email)),
@@ -266,7 +267,7 @@ func (h *AuthHandler) Me(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := h.service.GetCurrentUser(userID.(uint))
+	user, err := h.service.WithContext(r.Context()).GetCurrentUser(userID.(uint))
 	if err != nil {
 		respondError(w, http.StatusNotFound, "NOT_FOUND", "Usuario no encontrado")
 		return
@@ -285,7 +286,7 @@ func (h *AuthHandler) GetProfile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := h.service.GetCurrentUser(userID.(uint))
+	user, err := h.service.WithContext(r.Context()).GetCurrentUser(userID.(uint))
 	if err != nil {
 		respondError(w, http.StatusNotFound, "NOT_FOUND", "Usuario no encontrado")
 		return
@@ -314,6 +315,77 @@ func (h *AuthHandler) GetProfile(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// GetNotificationPrefs handles GET /api/v1/auth/notifications
+func (h *AuthHandler) GetNotificationPrefs(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID")
+	if userID == nil {
+		respondError(w, http.StatusUnauthorized, "UNAUTHORIZED", "No autenticado")
+		return
+	}
+
+	user, err := h.service.WithContext(r.Context()).GetCurrentUser(userID.(uint))
+	if err != nil {
+		respondError(w, http.StatusNotFound, "NOT_FOUND", "Usuario no encontrado")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, notificationPrefs(user))
+}
+
+// UpdateNotificationPrefs handles PUT /api/v1/auth/notifications
+// telegram_chat_id = 0 desvincula el chat de Telegram.
+func (h *AuthHandler) UpdateNotificationPrefs(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID")
+	if userID == nil {
+		respondError(w, http.StatusUnauthorized, "UNAUTHORIZED", "No autenticado")
+		return
+	}
+
+	var req struct {
+		NotifChannel   *string `json:"notif_channel"`
+		NotifOptOut    *bool   `json:"notif_opt_out"`
+		TelegramChatID *int64  `json:"telegram_chat_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "INVALID_JSON", "JSON inválido")
+		return
+	}
+
+	if req.NotifChannel != nil {
+		channel := strings.ToLower(strings.TrimSpace(*req.NotifChannel))
+		switch channel {
+		case models.NotifChannelEmail, models.NotifChannelWhatsApp, models.NotifChannelTelegram:
+		default:
+			respondError(w, http.StatusBadRequest, "INVALID_CHANNEL", "notif_channel debe ser email, whatsapp o telegram")
+			return
+		}
+		req.NotifChannel = &channel
+	}
+	if req.TelegramChatID != nil && *req.TelegramChatID < 0 {
+		respondError(w, http.StatusBadRequest, "INVALID_CHAT_ID", "telegram_chat_id inválido")
+		return
+	}
+
+	user, err := h.service.WithContext(r.Context()).UpdateNotificationPrefs(userID.(uint), req.NotifChannel, req.NotifOptOut, req.TelegramChatID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "UPDATE_ERROR", "Error al actualizar preferencias")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"message":       "Preferencias de avisos actualizadas",
+		"notifications": notificationPrefs(user),
+	})
+}
+
+func notificationPrefs(user *models.User) map[string]interface{} {
+	return map[string]interface{}{
+		"notif_channel":    user.NotifChannel,
+		"notif_opt_out":    user.NotifOptOut,
+		"telegram_chat_id": user.TelegramChatID,
+	}
+}
+
 // UpdateProfile handles PUT /api/v1/auth/profile
 func (h *AuthHandler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value("userID")
@@ -368,7 +440,7 @@ func (h *AuthHandler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
 		req.Distrito = &dist
 	}
 
-	user, err := h.service.UpdateProfile(
+	user, err := h.service.WithContext(r.Context()).UpdateProfile(
 		userID.(uint),
 		req.Email,
 		req.Telefono,
@@ -427,12 +499,12 @@ func (h *AuthHandler) SolicitarRecuperacion(w http.ResponseWriter, r *http.Reque
 
 	// Calcular email_hint antes de llamar al servicio (anti-enumeración: la respuesta siempre es 200)
 	emailHint := ""
-	if user, err := h.service.GetUserByCedula(req.Identificacion); err == nil && user != nil && user.Email != "" {
+	if user, err := h.service.WithContext(r.Context()).GetUserByCedula(req.Identificacion); err == nil && user != nil && user.Email != "" {
 		emailHint = maskEmail(user.Email)
 	}
 
 	// Siempre retorna nil — nunca revela si la cuenta existe
-	_ = h.service.SolicitarRecuperacion(req.Identificacion)
+	_ = h.service.WithContext(r.Context()).SolicitarRecuperacion(req.Identificacion)
 
 	respondJSON(w, http.StatusOK, map[string]interface{}{
 		"message":    "Si existe una cuenta con esa cédula, recibirás un email con el enlace de recuperación.",
@@ -462,7 +534,7 @@ func (h *AuthHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.service.ResetPassword(req.Token, req.Password); err != nil {
+	if err := h.service.WithContext(r.Context()).ResetPassword(req.Token, req.Password); err != nil {
 		code := "RESET_ERROR"
 		status := http.StatusBadRequest
 
@@ -510,7 +582,7 @@ func (h *AuthHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.service.CambiarPassword(userID.(uint), req.CurrentPassword, req.NewPassword); err != nil {
+	if err := h.service.WithContext(r.Context()).CambiarPassword(userID.(uint), req.CurrentPassword, req.NewPassword); err != nil {
 		code := "CHANGE_PASSWORD_ERROR"
 		status := http.StatusBadRequest
 
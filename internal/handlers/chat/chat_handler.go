@@ -11,9 +11,11 @@ import (
 	"sync"
 	"time"
 
-	"cloud.google.com/go/vertexai/genai"
 	"github.com/alonsoalpizar/fabricalaser/internal/database"
+	"github.com/alonsoalpizar/fabricalaser/internal/llm"
 	"github.com/alonsoalpizar/fabricalaser/internal/repository"
+	"github.com/alonsoalpizar/fabricalaser/internal/services/knowledge"
+	"github.com/alonsoalpizar/fabricalaser/internal/sse"
 )
 
 // publicSystemInstruction — visible a visitantes sin cuenta (landing page)
@@ -74,6 +76,9 @@ Cuando mandés al cliente a coordinar por mensajería, SIEMPRE ofrecé las dos o
 "Podés seguir por [WhatsApp](https://wa.me/50670183073) o por [Telegram](https://t.me/FabricalaserBot)"
 "También te invitamos a [crear tu cuenta gratis](https://fabricalaser.com/?login=1) para acceder al cotizador online y ver el catálogo completo con precios."
 
+## Base de conocimiento:
+Para dudas sobre envíos, retiro, tiempos de producción, archivos de diseño o cuidado de las piezas llamá buscar_conocimiento y respondé solo con lo que dicen los pasajes (sin mencionar la cita).
+
 ## Restricciones:
 - NO reveles precios específicos de productos — eso es exclusivo para usuarios registrados
 - NO des cotizaciones ni rangos de precio
@@ -211,24 +216,9 @@ Fibra de pulso variable. Lo más avanzado para metales y colores. Extiende las c
 El cliente sube su archivo SVG, selecciona tecnología y material, y recibe cotización instantánea.
 Para cotizar necesita registrarse en fabricalaser.com con su cédula costarricense.
 
-## Ubicación del taller:
-Avenida 67, San Jerónimo, Tibás, San José. Código postal 11301.
-Google Maps: https://maps.app.goo.gl/DY5kv5QwCwBCo3kJ7
-
-## Retiro en taller (IMPORTANTE — aplicá esto sin excepción):
-El retiro es SOLO con cita previa coordinada por WhatsApp o Telegram — con día y hora confirmados.
-No se puede llegar sin cita porque el encargado puede no estar disponible.
-Nunca le digas al cliente que puede pasar directamente. Siempre indicá que debe coordinar primero por WhatsApp o Telegram.
-
-## Envíos:
-Enviamos a todo el país por Correos de Costa Rica o mensajería.
-Tarifa: ₡3.500 por el primer kilo (cubre la mayoría de pedidos de llaveros y medallas).
-El costo de envío lo asume el cliente y se coordina al confirmar el pedido por WhatsApp.
-No hacemos entregas a domicilio por cuenta propia.
-
-## Tiempo de producción:
-Los pedidos se procesan en **1 día hábil** desde que se confirma el pago.
-Este tiempo aplica para llaveros y medallas estándar; diseños muy complejos pueden requerir coordinación adicional.
+## Base de conocimiento (buscar_conocimiento):
+Ubicación y retiro en taller, envíos, tiempos de producción, preparación del archivo de diseño, cuidado de las piezas y preguntas frecuentes están en la base de conocimiento. Ante cualquiera de esas dudas llamá buscar_conocimiento y respondé solo con lo que dicen los pasajes, en tus palabras y sin mencionar la cita.
+Si no encuentra nada, no inventés: mandá al WhatsApp o Telegram.
 
 ## Cómo se hace un pedido:
 1. El cliente define qué quiere (producto, forma, cantidad, color)
@@ -251,12 +241,6 @@ Los links en formato markdown garantizan que sean clickeables en el chat.
 - No inventés información que no tenés — mejor decirlo y mandar al WhatsApp o Telegram
 - Si preguntan cosas que no son del negocio, redirigí amablemente al tema`
 
-var (
-	projectID = "div-aloalpizar"
-	location  = "us-central1"
-	modelName = "gemini-2.5-flash"
-)
-
 // ChatRequest represents an incoming chat message
 type ChatRequest struct {
 	Message string         `json:"message"`
@@ -288,23 +272,26 @@ type Handler struct {
 	sysConfigRepo *repository.SystemConfigRepository
 	mu            sync.RWMutex
 	cache         *dynamicContext
-	genai         *genai.Client
+	llm           llm.Provider
+	tools         *llm.Registry
 }
 
-const cacheTTL = 5 * time.Minute
+const (
+	cacheTTL    = 5 * time.Minute
+	toolLoopMax = 3
+)
 
-// NewHandler creates a new chat handler with a shared Vertex AI client
-func NewHandler() *Handler {
-	ctx := context.Background()
-	client, err := genai.NewClient(ctx, projectID, location)
-	if err != nil {
-		log.Fatalf("chat: failed to create Vertex AI client: %v", err)
-	}
+// NewHandler creates a new chat handler on the shared model provider; kb backs
+// the buscar_conocimiento tool
+func NewHandler(model llm.Provider, kb *knowledge.Service) *Handler {
+	tools := llm.NewRegistry()
+	tools.Register(knowledge.Tool(), kb.Handler(false))
 	return &Handler{
 		techRepo:      repository.NewTechnologyRepository(),
 		matRepo:       repository.NewMaterialRepository(),
 		sysConfigRepo: repository.NewSystemConfigRepository(),
-		genai:         client,
+		llm:           model,
+		tools:         tools,
 	}
 }
 
@@ -379,7 +366,7 @@ func (h *Handler) HandleChat(w http.ResponseWriter, r *http.Request) {
 	defer cancel()
 
 	dynCtx := h.getDynamicContext()
-	response, err := h.callGemini(ctx, req.Message, req.History, userName, dynCtx)
+	response, err := h.callGemini(ctx, req.Message, req.History, userName, dynCtx, nil)
 	if err != nil {
 		log.Printf("Gemini error: %v", err)
 		sendError(w, "Error procesando la solicitud", http.StatusInternalServerError)
@@ -428,9 +415,54 @@ func persistWebTurns(userID uint, userMsg, modelMsg string) {
 	}
 }
 
-func (h *Handler) callGemini(ctx context.Context, message string, history []HistoryEntry, userName string, dynCtx string) (string, error) {
-	model := h.genai.GenerativeModel(modelName)
+// HandleChatStream es la variante SSE de HandleChat: emite el texto a medida que
+// el modelo lo genera (eventos text), y al final done con la respuesta completa
+// o error. La generación sigue aunque el cliente se desconecte, para que el
+// turno completo quede guardado igual que en HandleChat.
+func (h *Handler) HandleChatStream(w http.ResponseWriter, r *http.Request) {
+	var req ChatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, "Solicitud inválida", http.StatusBadRequest)
+		return
+	}
 
+	if strings.TrimSpace(req.Message) == "" {
+		sendError(w, "El mensaje no puede estar vacío", http.StatusBadRequest)
+		return
+	}
+
+	userName, _ := r.Context().Value("userName").(string)
+	userID, _ := r.Context().Value("userID").(uint)
+
+	stream, err := sse.NewWriter(w, r)
+	if err != nil {
+		sendError(w, "Streaming no soportado", http.StatusInternalServerError)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), 30*time.Second)
+	defer cancel()
+
+	dynCtx := h.getDynamicContext()
+	response, err := h.callGemini(ctx, req.Message, req.History, userName, dynCtx, func(chunk string) {
+		stream.Send("text", map[string]string{"text": chunk})
+	})
+	if err != nil {
+		log.Printf("Gemini error (stream): %v", err)
+		stream.Send("error", ChatResponse{Error: "Error procesando la solicitud"})
+		return
+	}
+
+	if userID > 0 {
+		go persistWebTurns(userID, req.Message, response)
+	}
+
+	stream.Send("done", ChatResponse{Response: response})
+}
+
+// callGemini genera la respuesta del chat. Con onText != nil el texto se emite
+// en fragmentos mientras se genera; el retorno es siempre la respuesta completa.
+func (h *Handler) callGemini(ctx context.Context, message string, history []HistoryEntry, userName string, dynCtx string, onText func(string)) (string, error) {
 	// Choose instruction based on auth state, then append live DB context
 	var instruction string
 	if userName == "" {
@@ -441,48 +473,42 @@ func (h *Handler) callGemini(ctx context.Context, message string, history []Hist
 			dynCtx
 	}
 
-	model.SystemInstruction = &genai.Content{
-		Parts: []genai.Part{genai.Text(instruction)},
-	}
-	model.SetTemperature(0.7)
-	model.SetTopP(0.95)
-	model.SetMaxOutputTokens(2048)
-
-	chat := model.StartChat()
-
+	messages := make([]llm.Message, 0, len(history))
 	for _, h := range history {
-		role := "user"
+		role := llm.RoleUser
 		if h.Role == "assistant" {
-			role = "model"
+			role = llm.RoleModel
 		}
-		chat.History = append(chat.History, &genai.Content{
-			Role:  role,
-			Parts: []genai.Part{genai.Text(h.Content)},
-		})
+		messages = append(messages, llm.Message{Role: role, Parts: []llm.Part{llm.Text(h.Content)}})
 	}
 
-	resp, err := chat.SendMessage(ctx, genai.Text(message))
+	chat := llm.NewChat(h.llm, llm.Config{
+		System:          instruction,
+		Tools:           h.tools.Tools(),
+		Temperature:     llm.Float32(0.7),
+		TopP:            llm.Float32(0.95),
+		MaxOutputTokens: llm.Int32(2048),
+	}, messages)
+	chat.OnText = onText
+
+	resp, err := chat.Send(ctx, llm.Text(message))
 	if err != nil {
 		return "", fmt.Errorf("failed to send message: %w", err)
 	}
-
-	if len(resp.Candidates) == 0 || len(resp.Candidates[0].Content.Parts) == 0 {
-		return "", fmt.Errorf("empty response from model")
+	resp, err = chat.RunTools(ctx, resp, toolLoopMax, h.tools, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to run tools: %w", err)
 	}
 
-	candidate := resp.Candidates[0]
-	if candidate.FinishReason == genai.FinishReasonMaxTokens {
+	if resp.Truncated {
 		log.Printf("chat: respuesta cortada por límite de tokens (MaxOutputTokens=%d)", 2048)
 	}
 
-	var result strings.Builder
-	for _, part := range candidate.Content.Parts {
-		if text, ok := part.(genai.Text); ok {
-			result.WriteString(string(text))
-		}
+	text := resp.Text()
+	if text == "" {
+		return "", fmt.Errorf("empty response from model")
 	}
-
-	return result.String(), nil
+	return text, nil
 }
 
 // SummaryRequest represents the conversation history to summarize
@@ -518,12 +544,11 @@ func (h *Handler) HandleSummary(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), 20*time.Second)
 	defer cancel()
 
-	model := h.genai.GenerativeModel(modelName)
-	model.SystemInstruction = &genai.Content{
-		Parts: []genai.Part{genai.Text("Sos un asistente especializado en resumir conversaciones de ventas para traspasar contexto a un asesor humano. Respondé solo con el resumen estructurado, sin saludos, sin explicaciones adicionales.")},
+	cfg := llm.Config{
+		System:          "Sos un asistente especializado en resumir conversaciones de ventas para traspasar contexto a un asesor humano. Respondé solo con el resumen estructurado, sin saludos, sin explicaciones adicionales.",
+		Temperature:     llm.Float32(0.2),
+		MaxOutputTokens: llm.Int32(700),
 	}
-	model.SetTemperature(0.2)
-	model.SetMaxOutputTokens(700)
 
 	prompt := `Analizá la conversación completa y generá un resumen estructurado para el asesor de FabricaLaser que va a atender al cliente por WhatsApp.
 
@@ -546,22 +571,15 @@ Reglas:
 Conversación:
 ` + conv.String()
 
-	resp, err := model.GenerateContent(ctx, genai.Text(prompt))
-	if err != nil || len(resp.Candidates) == 0 || len(resp.Candidates[0].Content.Parts) == 0 {
+	text, err := llm.Summarize(ctx, h.llm, cfg, prompt)
+	if err != nil {
 		log.Printf("summary: error from model: %v", err)
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(SummaryResponse{Error: "No se pudo generar el resumen"})
 		return
 	}
 
-	var result strings.Builder
-	for _, part := range resp.Candidates[0].Content.Parts {
-		if text, ok := part.(genai.Text); ok {
-			result.WriteString(string(text))
-		}
-	}
-
-	summary := "Consulta desde el chat de FabricaLaser.com:\n\n" + strings.TrimSpace(result.String())
+	summary := "Consulta desde el chat de FabricaLaser.com:\n\n" + text
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(SummaryResponse{Summary: summary})
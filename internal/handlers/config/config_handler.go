@@ -2,10 +2,13 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 
+	"github.com/alonsoalpizar/fabricalaser/internal/database"
 	"github.com/alonsoalpizar/fabricalaser/internal/repository"
+	"github.com/alonsoalpizar/fabricalaser/internal/services/pricing"
 )
 
 type ConfigHandler struct {
@@ -16,6 +19,7 @@ type ConfigHandler struct {
 	discountRepo  *repository.VolumeDiscountRepository
 	priceRefRepo  *repository.PriceReferenceRepository
 	speedRepo     *repository.TechMaterialSpeedRepository
+	recommender   *pricing.Recommender
 }
 
 func NewConfigHandler() *ConfigHandler {
@@ -27,12 +31,13 @@ func NewConfigHandler() *ConfigHandler {
 		discountRepo:  repository.NewVolumeDiscountRepository(),
 		priceRefRepo:  repository.NewPriceReferenceRepository(),
 		speedRepo:     repository.NewTechMaterialSpeedRepository(),
+		recommender:   pricing.NewRecommender(pricing.NewConfigLoader(database.Get())),
 	}
 }
 
 // GetTechnologies returns all active technologies
 func (h *ConfigHandler) GetTechnologies(w http.ResponseWriter, r *http.Request) {
-	technologies, err := h.techRepo.FindAll()
+	technologies, err := h.techRepo.WithContext(r.Context()).FindAll()
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "FETCH_ERROR", "Error al obtener tecnologías")
 		return
@@ -45,7 +50,7 @@ func (h *ConfigHandler) GetTechnologies(w http.ResponseWriter, r *http.Request)
 
 // GetMaterials returns all active materials
 func (h *ConfigHandler) GetMaterials(w http.ResponseWriter, r *http.Request) {
-	materials, err := h.materialRepo.FindAll()
+	materials, err := h.materialRepo.WithContext(r.Context()).FindAll()
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "FETCH_ERROR", "Error al obtener materiales")
 		return
@@ -58,7 +63,7 @@ func (h *ConfigHandler) GetMaterials(w http.ResponseWriter, r *http.Request) {
 
 // GetEngraveTypes returns all active engrave types
 func (h *ConfigHandler) GetEngraveTypes(w http.ResponseWriter, r *http.Request) {
-	types, err := h.engraveRepo.FindAll()
+	types, err := h.engraveRepo.WithContext(r.Context()).FindAll()
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "FETCH_ERROR", "Error al obtener tipos de grabado")
 		return
@@ -71,7 +76,7 @@ func (h *ConfigHandler) GetEngraveTypes(w http.ResponseWriter, r *http.Request)
 
 // GetTechRates returns all active tech rates with technology info
 func (h *ConfigHandler) GetTechRates(w http.ResponseWriter, r *http.Request) {
-	rates, err := h.rateRepo.FindAll()
+	rates, err := h.rateRepo.WithContext(r.Context()).FindAll()
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "FETCH_ERROR", "Error al obtener tarifas")
 		return
@@ -84,7 +89,7 @@ func (h *ConfigHandler) GetTechRates(w http.ResponseWriter, r *http.Request) {
 
 // GetVolumeDiscounts returns all active volume discounts
 func (h *ConfigHandler) GetVolumeDiscounts(w http.ResponseWriter, r *http.Request) {
-	discounts, err := h.discountRepo.FindAll()
+	discounts, err := h.discountRepo.WithContext(r.Context()).FindAll()
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "FETCH_ERROR", "Error al obtener descuentos")
 		return
@@ -97,7 +102,7 @@ func (h *ConfigHandler) GetVolumeDiscounts(w http.ResponseWriter, r *http.Reques
 
 // GetPriceReferences returns all active price references
 func (h *ConfigHandler) GetPriceReferences(w http.ResponseWriter, r *http.Request) {
-	refs, err := h.priceRefRepo.FindAll()
+	refs, err := h.priceRefRepo.WithContext(r.Context()).FindAll()
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "FETCH_ERROR", "Error al obtener referencias de precio")
 		return
@@ -110,37 +115,37 @@ func (h *ConfigHandler) GetPriceReferences(w http.ResponseWriter, r *http.Reques
 
 // GetAll returns all configuration data in a single response (for initial load)
 func (h *ConfigHandler) GetAll(w http.ResponseWriter, r *http.Request) {
-	technologies, err := h.techRepo.FindAll()
+	technologies, err := h.techRepo.WithContext(r.Context()).FindAll()
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "FETCH_ERROR", "Error al obtener configuración")
 		return
 	}
 
-	materials, err := h.materialRepo.FindAll()
+	materials, err := h.materialRepo.WithContext(r.Context()).FindAll()
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "FETCH_ERROR", "Error al obtener configuración")
 		return
 	}
 
-	engraveTypes, err := h.engraveRepo.FindAll()
+	engraveTypes, err := h.engraveRepo.WithContext(r.Context()).FindAll()
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "FETCH_ERROR", "Error al obtener configuración")
 		return
 	}
 
-	rates, err := h.rateRepo.FindAll()
+	rates, err := h.rateRepo.WithContext(r.Context()).FindAll()
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "FETCH_ERROR", "Error al obtener configuración")
 		return
 	}
 
-	discounts, err := h.discountRepo.FindAll()
+	discounts, err := h.discountRepo.WithContext(r.Context()).FindAll()
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "FETCH_ERROR", "Error al obtener configuración")
 		return
 	}
 
-	priceRefs, err := h.priceRefRepo.FindAll()
+	priceRefs, err := h.priceRefRepo.WithContext(r.Context()).FindAll()
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "FETCH_ERROR", "Error al obtener configuración")
 		return
@@ -202,75 +207,70 @@ func (h *ConfigHandler) GetCompatibleOptions(w http.ResponseWriter, r *http.Requ
 		}
 	}
 
-	// Get compatible speeds
-	speeds, err := h.speedRepo.FindCompatibleTechnologies(uint(materialID), thickness)
+	// Compatible technologies grouped with their thicknesses
+	technologies, err := h.speedRepo.WithContext(r.Context()).FindCompatibleOptions(uint(materialID), thickness)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "FETCH_ERROR", "Error al obtener opciones compatibles")
 		return
 	}
+	if technologies == nil {
+		technologies = []repository.CompatibleTechnology{}
+	}
 
-	// Group by technology
-	techMap := make(map[uint]*struct {
-		ID          uint      `json:"id"`
-		Name        string    `json:"name"`
-		Code        string    `json:"code"`
-		Thicknesses []float64 `json:"thicknesses"`
-		CanCut      bool      `json:"can_cut"`
-		CanEngrave  bool      `json:"can_engrave"`
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data": map[string]interface{}{
+			"technologies": technologies,
+		},
 	})
+}
 
-	for _, s := range speeds {
-		tech, exists := techMap[s.TechnologyID]
-		if !exists {
-			tech = &struct {
-				ID          uint      `json:"id"`
-				Name        string    `json:"name"`
-				Code        string    `json:"code"`
-				Thicknesses []float64 `json:"thicknesses"`
-				CanCut      bool      `json:"can_cut"`
-				CanEngrave  bool      `json:"can_engrave"`
-			}{
-				ID:          s.TechnologyID,
-				Name:        s.Technology.Name,
-				Code:        s.Technology.Code,
-				Thicknesses: []float64{},
-				CanCut:      false,
-				CanEngrave:  false,
-			}
-			techMap[s.TechnologyID] = tech
-		}
-
-		// Add thickness to list (avoid duplicates)
-		found := false
-		for _, t := range tech.Thicknesses {
-			if t == s.Thickness {
-				found = true
-				break
-			}
-		}
-		if !found {
-			tech.Thicknesses = append(tech.Thicknesses, s.Thickness)
-		}
+// GetRecommendation returns the ranked technology plans for a job
+// Query params:
+//   - material_id (required): ID of the material
+//   - operation (required): grabado, corte or grabado_corte
+//   - thickness (optional): thickness in mm; needed to validate cuts
+//   - color (optional): true when the piece has a colored finish (anodized, coated)
+func (h *ConfigHandler) GetRecommendation(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	materialID, err := strconv.ParseUint(q.Get("material_id"), 10, 32)
+	if err != nil || materialID == 0 {
+		respondError(w, http.StatusBadRequest, "INVALID_PARAM", "material_id es requerido")
+		return
+	}
+	operation, err := pricing.ParseOperation(q.Get("operation"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "INVALID_PARAM", err.Error())
+		return
+	}
 
-		// Update capabilities
-		if s.CutSpeedMmMin != nil && *s.CutSpeedMmMin > 0 {
-			tech.CanCut = true
-		}
-		if s.EngraveSpeedMmMin != nil && *s.EngraveSpeedMmMin > 0 {
-			tech.CanEngrave = true
+	var thickness float64
+	if thicknessStr := q.Get("thickness"); thicknessStr != "" {
+		thickness, err = strconv.ParseFloat(thicknessStr, 64)
+		if err != nil || thickness < 0 {
+			respondError(w, http.StatusBadRequest, "INVALID_PARAM", "thickness invalido")
+			return
 		}
 	}
+	color, _ := strconv.ParseBool(q.Get("color"))
 
-	// Convert map to slice
-	technologies := make([]interface{}, 0, len(techMap))
-	for _, tech := range techMap {
-		technologies = append(technologies, tech)
+	rec, err := h.recommender.WithContext(r.Context()).Recommend(pricing.RecommendInput{
+		MaterialID:  uint(materialID),
+		Thickness:   thickness,
+		Operation:   operation,
+		ColorFinish: color,
+	})
+	if errors.Is(err, pricing.ErrRecommendMaterial) {
+		respondError(w, http.StatusNotFound, "NOT_FOUND", err.Error())
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "FETCH_ERROR", "Error al calcular la recomendación")
+		return
 	}
 
 	respondJSON(w, http.StatusOK, map[string]interface{}{
 		"success": true,
-		"data": map[string]interface{}{
-			"technologies": technologies,
-		},
+		"data":    rec,
 	})
 }
@@ -0,0 +1,306 @@
+// Package public expone la API pública /api/public/v1 para integraciones B2B
+// (tiendas de revendedores, plugins de e-commerce). Cada ruta exige una API key
+// con su scope (ver services/apikeys); la key define el tenant y la cuenta del
+// cliente. Los tipos de request y respuesta de este paquete y de quote generan
+// el spec OpenAPI (ver openapi.go).
+package public
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/database"
+	"github.com/alonsoalpizar/fabricalaser/internal/handlers/quote"
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+	"github.com/alonsoalpizar/fabricalaser/internal/services/pricing"
+)
+
+// Version es la versión de la API pública (prefijo de las rutas)
+const Version = "v1"
+
+// Handler atiende la API pública; el cálculo lo comparte con el cotizador web
+type Handler struct {
+	quotes       *quote.Handler
+	configLoader *pricing.ConfigLoader
+}
+
+func NewHandler(quotes *quote.Handler) *Handler {
+	return &Handler{
+		quotes:       quotes,
+		configLoader: pricing.NewConfigLoader(database.Get()),
+	}
+}
+
+// ─── Tipos de la API ─────────────────────────────────────────────────────────
+
+// CatalogResponse es lo que se puede cotizar en el taller
+type CatalogResponse struct {
+	Currency        string               `json:"currency"` // Moneda base de los precios
+	Technologies    []CatalogTechnology  `json:"technologies"`
+	Materials       []CatalogMaterial    `json:"materials"`
+	EngraveTypes    []CatalogEngraveType `json:"engrave_types"`
+	VolumeDiscounts []CatalogDiscount    `json:"volume_discounts"`
+}
+
+type CatalogTechnology struct {
+	ID          uint   `json:"id"`
+	Code        string `json:"code"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+type CatalogMaterial struct {
+	ID           uint      `json:"id"`
+	Name         string    `json:"name"`
+	Category     string    `json:"category"`
+	Thicknesses  []float64 `json:"thicknesses"` // mm
+	IsCuttable   bool      `json:"is_cuttable"`
+	Technologies []uint    `json:"technologies"` // IDs de tecnologías compatibles
+}
+
+type CatalogEngraveType struct {
+	ID          uint   `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+type CatalogDiscount struct {
+	MinQty      int     `json:"min_qty"`
+	MaxQty      *int    `json:"max_qty,omitempty"` // Sin tope si no viene
+	DiscountPct float64 `json:"discount_pct"`      // 0.10 = 10%
+}
+
+// AnalysisResponse es la geometría de un SVG analizado
+type AnalysisResponse struct {
+	ID             uint     `json:"id"` // analysis_id para /calculate
+	Filename       string   `json:"filename"`
+	WidthMM        float64  `json:"width_mm"`
+	HeightMM       float64  `json:"height_mm"`
+	CutLengthMM    float64  `json:"cut_length_mm"`
+	VectorLengthMM float64  `json:"vector_length_mm"`
+	RasterAreaMM2  float64  `json:"raster_area_mm2"`
+	ElementCount   int      `json:"element_count"`
+	Warnings       []string `json:"warnings"`
+	Cached         bool     `json:"cached"` // El mismo archivo ya se había analizado
+}
+
+// QuoteResponse es una cotización guardada a nombre del cliente
+type QuoteResponse struct {
+	ID            uint      `json:"id"`
+	Status        string    `json:"status"` // auto_approved, needs_review, rejected
+	AnalysisID    uint      `json:"analysis_id"`
+	TechnologyID  uint      `json:"technology_id"`
+	MaterialID    uint      `json:"material_id"`
+	EngraveTypeID uint      `json:"engrave_type_id"`
+	Quantity      int       `json:"quantity"`
+	Currency      string    `json:"currency"`
+	UnitPrice     float64   `json:"unit_price"` // Sin IVA
+	Subtotal      float64   `json:"subtotal"`   // Sin IVA
+	TaxRate       float64   `json:"tax_rate"`   // % IVA
+	Tax           float64   `json:"tax"`
+	Total         float64   `json:"total"`
+	TaxDisplay    string    `json:"tax_display"` // exclusive (+ IVA) o inclusive (IVA incluido)
+	ValidUntil    time.Time `json:"valid_until"`
+	Warning       string    `json:"warning,omitempty"` // El trabajo requiere revisión de un asesor
+}
+
+// ErrorResponse es el cuerpo de toda respuesta de error
+type ErrorResponse struct {
+	Error ErrorBody `json:"error"`
+}
+
+type ErrorBody struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ─── Handlers ────────────────────────────────────────────────────────────────
+
+// Catalog handles GET /api/public/v1/catalog
+func (h *Handler) Catalog(w http.ResponseWriter, r *http.Request) {
+	config, err := h.configLoader.LoadContext(r.Context())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "CONFIG_ERROR", "Error loading configuration")
+		return
+	}
+	respondData(w, buildCatalog(config))
+}
+
+// Estimate handles POST /api/public/v1/estimate
+func (h *Handler) Estimate(w http.ResponseWriter, r *http.Request) {
+	var req quote.EstimateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "INVALID_JSON", "Invalid request body")
+		return
+	}
+	resp, status := h.quotes.Estimate(r.Context(), req)
+	if resp.Error != "" {
+		respondError(w, status, "ESTIMATE_ERROR", resp.Error)
+		return
+	}
+	respondData(w, resp)
+}
+
+// Analyze handles POST /api/public/v1/analyze (multipart, campo svg)
+func (h *Handler) Analyze(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(uint)
+	filename, content, err := quote.ReadSVGUpload(r)
+	if err != nil {
+		respondQuoteError(w, r, err)
+		return
+	}
+	analysis, cached, err := h.quotes.Analyze(userID, filename, content)
+	if err != nil {
+		respondQuoteError(w, r, err)
+		return
+	}
+
+	resp := AnalysisResponse{
+		ID:             analysis.ID,
+		Filename:       analysis.Filename,
+		WidthMM:        analysis.Width,
+		HeightMM:       analysis.Height,
+		CutLengthMM:    analysis.CutLengthMM,
+		VectorLengthMM: analysis.VectorLengthMM,
+		RasterAreaMM2:  analysis.RasterAreaMM2,
+		ElementCount:   analysis.ElementCount,
+		Warnings:       []string{},
+		Cached:         cached,
+	}
+	_ = json.Unmarshal(analysis.Warnings, &resp.Warnings)
+	respondData(w, resp)
+}
+
+// Calculate handles POST /api/public/v1/calculate
+func (h *Handler) Calculate(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(uint)
+	var req quote.CalculateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "INVALID_JSON", "Invalid request body")
+		return
+	}
+	q, err := h.quotes.Calculate(r.Context(), userID, req)
+	if err != nil {
+		respondQuoteError(w, r, err)
+		return
+	}
+	respondData(w, toQuoteResponse(q))
+}
+
+// ─── Helpers ─────────────────────────────────────────────────────────────────
+
+// buildCatalog arma el catálogo activo del config, ordenado por nombre
+func buildCatalog(config *pricing.PricingConfig) CatalogResponse {
+	resp := CatalogResponse{
+		Currency:        config.GetBaseCurrency(),
+		Technologies:    []CatalogTechnology{},
+		Materials:       []CatalogMaterial{},
+		EngraveTypes:    []CatalogEngraveType{},
+		VolumeDiscounts: []CatalogDiscount{},
+	}
+
+	var techIDs []uint
+	for _, t := range config.Technologies {
+		if !t.IsActive {
+			continue
+		}
+		techIDs = append(techIDs, t.ID)
+		resp.Technologies = append(resp.Technologies, CatalogTechnology{ID: t.ID, Code: t.Code, Name: t.Name, Description: deref(t.Description)})
+	}
+	sort.Slice(techIDs, func(i, j int) bool { return techIDs[i] < techIDs[j] })
+
+	for _, m := range config.Materials {
+		if !m.IsActive {
+			continue
+		}
+		cm := CatalogMaterial{ID: m.ID, Name: m.Name, Category: m.Category, Thicknesses: []float64{}, IsCuttable: m.IsCuttable, Technologies: []uint{}}
+		_ = json.Unmarshal(m.Thicknesses, &cm.Thicknesses)
+		for _, id := range techIDs {
+			if ok, _ := config.IsCompatible(id, m.ID, 0); ok {
+				cm.Technologies = append(cm.Technologies, id)
+			}
+		}
+		resp.Materials = append(resp.Materials, cm)
+	}
+
+	for _, e := range config.EngraveTypes {
+		if e.IsActive {
+			resp.EngraveTypes = append(resp.EngraveTypes, CatalogEngraveType{ID: e.ID, Name: e.Name, Description: deref(e.Description)})
+		}
+	}
+
+	for _, d := range config.VolumeDiscounts {
+		if d.IsActive {
+			resp.VolumeDiscounts = append(resp.VolumeDiscounts, CatalogDiscount{MinQty: d.MinQty, MaxQty: d.MaxQty, DiscountPct: d.DiscountPct})
+		}
+	}
+
+	sort.Slice(resp.Technologies, func(i, j int) bool { return resp.Technologies[i].Name < resp.Technologies[j].Name })
+	sort.Slice(resp.Materials, func(i, j int) bool { return resp.Materials[i].Name < resp.Materials[j].Name })
+	sort.Slice(resp.EngraveTypes, func(i, j int) bool { return resp.EngraveTypes[i].Name < resp.EngraveTypes[j].Name })
+	return resp
+}
+
+// toQuoteResponse presenta la cotización en su moneda, sin el desglose interno de costos
+func toQuoteResponse(q *models.Quote) QuoteResponse {
+	subtotal := q.ToDisplayCurrency(q.PriceFinal)
+	resp := QuoteResponse{
+		ID:            q.ID,
+		Status:        string(q.Status),
+		AnalysisID:    q.SVGAnalysisID,
+		TechnologyID:  q.TechnologyID,
+		MaterialID:    q.MaterialID,
+		EngraveTypeID: q.EngraveTypeID,
+		Quantity:      q.Quantity,
+		Currency:      q.Currency,
+		UnitPrice:     models.RoundCurrency(subtotal/math.Max(float64(q.Quantity), 1), q.Currency),
+		Subtotal:      subtotal,
+		TaxRate:       q.TaxRate,
+		Tax:           q.ToDisplayCurrency(q.TaxAmount),
+		Total:         q.ToDisplayCurrency(q.PriceTotalWithTax),
+		TaxDisplay:    q.TaxDisplay,
+		ValidUntil:    q.ValidUntil,
+	}
+	if q.NeedsReview() || q.Status == models.QuoteStatusRejected {
+		resp.Warning = "Este trabajo requiere revisión de un asesor antes de confirmar precio final"
+	}
+	return resp
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func respondData(w http.ResponseWriter, data any) {
+	respondJSON(w, http.StatusOK, map[string]any{"data": data})
+}
+
+func respondJSON(w http.ResponseWriter, status int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
+func respondError(w http.ResponseWriter, status int, code, message string) {
+	respondJSON(w, status, ErrorResponse{Error: ErrorBody{Code: code, Message: message}})
+}
+
+// respondQuoteError responde los errores del cotizador con su status. Los
+// errores internos quedan en el log; al integrador le llega un mensaje genérico.
+func respondQuoteError(w http.ResponseWriter, r *http.Request, err error) {
+	var re *quote.RequestError
+	if errors.As(err, &re) {
+		respondError(w, re.Status, re.Code, re.Message)
+		return
+	}
+	slog.Error("public: error cotizando", "path", r.URL.Path, "error", err)
+	respondError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Internal error")
+}
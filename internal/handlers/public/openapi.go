@@ -0,0 +1,229 @@
+package public
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/handlers/quote"
+	"github.com/alonsoalpizar/fabricalaser/internal/middleware"
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+	"github.com/alonsoalpizar/fabricalaser/internal/services/apikeys"
+	"github.com/go-chi/chi/v5"
+)
+
+// BasePath es el prefijo de la API pública
+const BasePath = "/api/public/" + Version
+
+// Operation es una ruta de la API pública. La misma tabla monta las rutas y
+// genera el spec: Request y Response son valores de los tipos que decodifica y
+// responde el handler. Los campos obligatorios del request llevan el tag
+// openapi:"required".
+type Operation struct {
+	ID        string // operationId y endpoint en api_key_usage
+	Method    string
+	Path      string // Relativa a BasePath
+	Scope     string
+	Summary   string
+	Request   any  // nil = sin cuerpo
+	Multipart bool // Request es un archivo SVG en el campo svg
+	Response  any  // Contenido de data en la respuesta 200
+	Handler   http.HandlerFunc
+}
+
+// Operations retorna las rutas de la API pública
+func (h *Handler) Operations() []Operation {
+	return []Operation{
+		{
+			ID: "getCatalog", Method: http.MethodGet, Path: "/catalog", Scope: models.ScopeCatalogRead,
+			Summary:  "Tecnologías, materiales, tipos de grabado y descuentos por volumen del taller",
+			Response: CatalogResponse{}, Handler: h.Catalog,
+		},
+		{
+			ID: "estimate", Method: http.MethodPost, Path: "/estimate", Scope: models.ScopeEstimate,
+			Summary: "Precio de referencia por medidas, sin archivo ni cotización guardada",
+			Request: quote.EstimateRequest{}, Response: quote.EstimateResponse{}, Handler: h.Estimate,
+		},
+		{
+			ID: "analyzeSVG", Method: http.MethodPost, Path: "/analyze", Scope: models.ScopeAnalyze,
+			Summary:   "Analiza un SVG (rojo = corte, azul = vector, negro = raster)",
+			Multipart: true, Response: AnalysisResponse{}, Handler: h.Analyze,
+		},
+		{
+			ID: "createQuote", Method: http.MethodPost, Path: "/calculate", Scope: models.ScopeCreateQuote,
+			Summary: "Cotiza un análisis y guarda la cotización a nombre del cliente",
+			Request: quote.CalculateRequest{}, Response: QuoteResponse{}, Handler: h.Calculate,
+		},
+	}
+}
+
+// Routes monta la API pública: el spec sin autenticación y cada operación con
+// la API key de su scope.
+func (h *Handler) Routes(keys *apikeys.Service) func(chi.Router) {
+	ops := h.Operations()
+	spec, _ := json.MarshalIndent(Spec(ops), "", "  ")
+	return func(r chi.Router) {
+		r.Get("/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(spec)
+		})
+		for _, op := range ops {
+			r.With(middleware.APIKey(keys, op.Scope, op.ID)).Method(op.Method, op.Path, op.Handler)
+		}
+	}
+}
+
+// Spec genera el documento OpenAPI 3.0 de ops
+func Spec(ops []Operation) map[string]any {
+	g := &schemaGen{schemas: map[string]any{}}
+	errorRef := g.schema(reflect.TypeOf(ErrorResponse{}))
+
+	paths := map[string]any{}
+	for _, op := range ops {
+		item, _ := paths[BasePath+op.Path].(map[string]any)
+		if item == nil {
+			item = map[string]any{}
+			paths[BasePath+op.Path] = item
+		}
+
+		errorResp := func(desc string) map[string]any {
+			return map[string]any{"description": desc, "content": jsonContent(errorRef)}
+		}
+		o := map[string]any{
+			"operationId": op.ID,
+			"summary":     op.Summary,
+			"description": "Requiere una API key con el scope `" + op.Scope + "`.",
+			"security":    []any{map[string]any{"apiKey": []any{}}},
+			"x-scope":     op.Scope,
+			"responses": map[string]any{
+				"200": map[string]any{
+					"description": "OK",
+					"content": jsonContent(map[string]any{
+						"type":       "object",
+						"required":   []string{"data"},
+						"properties": map[string]any{"data": g.schema(reflect.TypeOf(op.Response))},
+					}),
+				},
+				"400": errorResp("Request inválido"),
+				"401": errorResp("API key faltante, inválida o vencida"),
+				"403": errorResp("La API key no tiene el scope"),
+				"429": errorResp("Límite de requests por minuto alcanzado (ver X-RateLimit-* y Retry-After)"),
+			},
+		}
+		switch {
+		case op.Multipart:
+			o["requestBody"] = map[string]any{
+				"required": true,
+				"content": map[string]any{
+					"multipart/form-data": map[string]any{"schema": map[string]any{
+						"type":       "object",
+						"required":   []string{"svg"},
+						"properties": map[string]any{"svg": map[string]any{"type": "string", "format": "binary"}},
+					}},
+				},
+			}
+		case op.Request != nil:
+			o["requestBody"] = map[string]any{"required": true, "content": jsonContent(g.schema(reflect.TypeOf(op.Request)))}
+		}
+		item[strings.ToLower(op.Method)] = o
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":       "FabricaLaser API pública",
+			"version":     Version,
+			"description": "Cotización de trabajos láser para integraciones B2B. Autenticación: Authorization: Bearer <API key>.",
+		},
+		"servers": []any{map[string]any{"url": "/"}},
+		"paths":   paths,
+		"components": map[string]any{
+			"schemas": g.schemas,
+			"securitySchemes": map[string]any{
+				"apiKey": map[string]any{"type": "http", "scheme": "bearer", "bearerFormat": "flk_<prefix>_<secret>"},
+			},
+		},
+	}
+}
+
+func jsonContent(schema any) map[string]any {
+	return map[string]any{"application/json": map[string]any{"schema": schema}}
+}
+
+// schemaGen arma los schemas JSON de los tipos Go a partir de sus tags json
+type schemaGen struct {
+	schemas map[string]any // components.schemas por nombre de tipo
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schema retorna el schema de t; los structs con nombre van a components y se
+// referencian con $ref
+func (g *schemaGen) schema(t reflect.Type) map[string]any {
+	switch t.Kind() {
+	case reflect.Pointer:
+		s := g.schema(t.Elem())
+		if _, isRef := s["$ref"]; isRef {
+			return map[string]any{"allOf": []any{s}, "nullable": true}
+		}
+		s["nullable"] = true
+		return s
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		if t == timeType {
+			return map[string]any{"type": "string", "format": "date-time"}
+		}
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		if _, ok := g.schemas[t.Name()]; !ok {
+			g.schemas[t.Name()] = nil // reserva el nombre ante tipos recursivos
+			g.schemas[t.Name()] = g.object(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + t.Name()}
+	default:
+		return map[string]any{}
+	}
+}
+
+// object retorna el schema de los campos exportados de un struct
+func (g *schemaGen) object(t reflect.Type) map[string]any {
+	props := map[string]any{}
+	var required []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = g.schema(f.Type)
+		if f.Tag.Get("openapi") == "required" {
+			required = append(required, name)
+		}
+	}
+	s := map[string]any{"type": "object", "properties": props}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
@@ -0,0 +1,51 @@
+package public
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestSpecFromHandlerTypes(t *testing.T) {
+	h := &Handler{}
+	spec := Spec(h.Operations())
+	if _, err := json.Marshal(spec); err != nil {
+		t.Fatal(err)
+	}
+
+	paths := spec["paths"].(map[string]any)
+	for _, p := range []string{"/api/public/v1/catalog", "/api/public/v1/estimate", "/api/public/v1/analyze", "/api/public/v1/calculate"} {
+		if paths[p] == nil {
+			t.Errorf("missing path %s", p)
+		}
+	}
+	calc := paths["/api/public/v1/calculate"].(map[string]any)["post"].(map[string]any)
+	if calc["x-scope"] != "create-quote" {
+		t.Errorf("calculate scope = %v", calc["x-scope"])
+	}
+
+	schemas := spec["components"].(map[string]any)["schemas"].(map[string]any)
+	est := schemas["EstimateRequest"].(map[string]any)
+	props := est["properties"].(map[string]any)
+	if props["alto_cm"].(map[string]any)["type"] != "number" || props["cantidad"].(map[string]any)["type"] != "integer" {
+		t.Errorf("EstimateRequest properties: %v", props)
+	}
+	if cut := props["cut_technology_id"].(map[string]any); cut["nullable"] != true {
+		t.Errorf("pointer field not nullable: %v", cut)
+	}
+	want := []string{"alto_cm", "ancho_cm", "technology_id", "material_id"}
+	if got := est["required"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("required = %v, want %v", got, want)
+	}
+
+	// Los tipos anidados se referencian
+	catalog := schemas["CatalogResponse"].(map[string]any)["properties"].(map[string]any)
+	items := catalog["materials"].(map[string]any)["items"].(map[string]any)
+	if items["$ref"] != "#/components/schemas/CatalogMaterial" || schemas["CatalogMaterial"] == nil {
+		t.Errorf("materials items = %v", items)
+	}
+	quote := schemas["QuoteResponse"].(map[string]any)["properties"].(map[string]any)
+	if quote["valid_until"].(map[string]any)["format"] != "date-time" {
+		t.Errorf("time field = %v", quote["valid_until"])
+	}
+}
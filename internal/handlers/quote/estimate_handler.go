@@ -1,36 +1,47 @@
 package quote
 
 import (
+	"context"
 	"encoding/json"
 	"math"
 	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/alonsoalpizar/fabricalaser/internal/models"
 	"github.com/alonsoalpizar/fabricalaser/internal/services/pricing"
+	"github.com/alonsoalpizar/fabricalaser/internal/services/tax"
 )
 
 // EstimateRequest — lo que recibe el endpoint desde el tool de Gemini.
 // Usa nombres en español para que el tool de Gemini sea legible y natural.
 type EstimateRequest struct {
-	AltoCM           float64 `json:"alto_cm"`             // Alto del área a grabar/cortar
-	AnchoCM          float64 `json:"ancho_cm"`            // Ancho del área a grabar/cortar
-	Cantidad         int     `json:"cantidad"`            // Unidades a producir
-	TechnologyID     uint    `json:"technology_id"`       // ID de tecnología (de la BD)
-	MaterialID       uint    `json:"material_id"`         // ID de material (de la BD)
-	EngraveTypeID    uint    `json:"engrave_type_id"`     // ID de tipo de grabado (de la BD)
-	Thickness        float64 `json:"thickness,omitempty"`         // Grosor en mm (opcional, default 3.0)
-	MaterialIncluded bool    `json:"material_included"`           // true = FabricaLaser provee el material
-	IncluyeCorte     bool    `json:"incluye_corte"`               // true = incluir perímetro de corte
-	CutTechnologyID  *uint   `json:"cut_technology_id,omitempty"` // nil = misma tech para corte
-	IgnoreCutLines   bool    `json:"ignore_cut_lines,omitempty"`  // true = material no cortable
+	AltoCM           float64 `json:"alto_cm" openapi:"required"`       // Alto del área a grabar/cortar
+	AnchoCM          float64 `json:"ancho_cm" openapi:"required"`      // Ancho del área a grabar/cortar
+	Cantidad         int     `json:"cantidad"`                         // Unidades a producir
+	TechnologyID     uint    `json:"technology_id" openapi:"required"` // ID de tecnología (de la BD)
+	MaterialID       uint    `json:"material_id" openapi:"required"`   // ID de material (de la BD)
+	EngraveTypeID    uint    `json:"engrave_type_id"`                  // ID de tipo de grabado (de la BD)
+	Thickness        float64 `json:"thickness,omitempty"`              // Grosor en mm (opcional, default 3.0)
+	MaterialIncluded bool    `json:"material_included"`                // true = FabricaLaser provee el material
+	IncluyeCorte     bool    `json:"incluye_corte"`                    // true = incluir perímetro de corte
+	CutTechnologyID  *uint   `json:"cut_technology_id,omitempty"`      // nil = misma tech para corte
+	IgnoreCutLines   bool    `json:"ignore_cut_lines,omitempty"`       // true = material no cortable
+	Moneda           string  `json:"moneda,omitempty"`                 // "CRC" (default) o "USD"
 }
 
 // EstimateResponse — lo que retorna el endpoint al tool de Gemini.
 type EstimateResponse struct {
-	PrecioEstimado   float64 `json:"precio_estimado"`           // Precio final en CRC
-	PrecioUnitario   float64 `json:"precio_unitario"`           // Precio por unidad
+	PrecioEstimado   float64 `json:"precio_estimado"`           // Precio final en Moneda, sin IVA
+	PrecioUnitario   float64 `json:"precio_unitario"`           // Precio por unidad, sin IVA
+	Subtotal         float64 `json:"subtotal"`                  // = PrecioEstimado
+	TarifaIVA        float64 `json:"tarifa_iva"`                // % IVA aplicado
+	IVA              float64 `json:"iva"`                       // Monto de IVA
+	Total            float64 `json:"total"`                     // Subtotal + IVA
+	ModoIVA          string  `json:"modo_iva"`                  // exclusive (+ IVA) o inclusive (IVA incluido)
+	Moneda           string  `json:"moneda"`                    // Moneda de los precios (CRC o USD)
+	TipoCambio       float64 `json:"tipo_cambio,omitempty"`     // CRC por USD usado en la conversión
 	AreaCM2          float64 `json:"area_cm2"`                  // Área calculada
 	DescuentoVolumen float64 `json:"descuento_volumen"`         // % de descuento aplicado
 	Tecnologia       string  `json:"tecnologia"`                // Nombre de la tecnología
@@ -59,22 +70,33 @@ func (h *Handler) HandleEstimate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	resp, status := h.Estimate(r.Context(), req)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Estimate calcula el precio de referencia de req. Retorna la respuesta y su
+// status HTTP; en error, la respuesta solo trae Error. Lo usan el endpoint
+// interno y la API pública.
+func (h *Handler) Estimate(ctx context.Context, req EstimateRequest) (EstimateResponse, int) {
 	// Validaciones
 	if req.AltoCM <= 0 || req.AnchoCM <= 0 {
-		sendEstimateError(w, "Las medidas deben ser mayores a 0", http.StatusBadRequest)
-		return
+		return EstimateResponse{Error: "Las medidas deben ser mayores a 0"}, http.StatusBadRequest
 	}
 	if req.AltoCM > 100 || req.AnchoCM > 100 {
-		sendEstimateError(w, "Medidas fuera del rango de trabajo (máx 100cm)", http.StatusBadRequest)
-		return
+		return EstimateResponse{Error: "Medidas fuera del rango de trabajo (máx 100cm)"}, http.StatusBadRequest
 	}
 	if req.TechnologyID == 0 || req.MaterialID == 0 {
-		sendEstimateError(w, "technology_id y material_id son requeridos", http.StatusBadRequest)
-		return
+		return EstimateResponse{Error: "technology_id y material_id son requeridos"}, http.StatusBadRequest
 	}
 	if req.Cantidad < 1 {
 		req.Cantidad = 1
 	}
+	if req.Moneda != "" && !models.IsSupportedCurrency(req.Moneda) {
+		return EstimateResponse{Error: "moneda debe ser CRC o USD"}, http.StatusBadRequest
+	}
+	moneda := models.NormalizeCurrency(req.Moneda)
 	if req.Thickness <= 0 {
 		req.Thickness = 3.0 // grosor más común
 	}
@@ -93,7 +115,7 @@ func (h *Handler) HandleEstimate(w http.ResponseWriter, r *http.Request) {
 	analysis := pricing.BuildSyntheticAnalysis(altoMM, anchoMM, req.IncluyeCorte, req.EngraveTypeID)
 
 	// Llamar al Calculator sin modificar su lógica
-	priceResult, err := h.calculator.Calculate(
+	priceResult, err := h.calculator.WithContext(ctx).Calculate(
 		analysis,
 		req.TechnologyID,
 		req.MaterialID,
@@ -105,20 +127,39 @@ func (h *Handler) HandleEstimate(w http.ResponseWriter, r *http.Request) {
 		req.IgnoreCutLines,
 	)
 	if err != nil {
-		sendEstimateError(w, "Error calculando precio: "+err.Error(), http.StatusInternalServerError)
-		return
+		return EstimateResponse{Error: "Error calculando precio: " + err.Error()}, http.StatusInternalServerError
 	}
 
 	// PriceFinal = MAX(Hybrid, Value) — igual que ToQuoteModel
-	priceFinal := math.Max(priceResult.PriceHybridTotal, priceResult.PriceValueTotal)
-	precioUnitario := math.Round(priceFinal / float64(req.Cantidad))
+	// El Calculator trabaja en moneda base; convertir a la moneda pedida
+	basePrice := math.Max(priceResult.PriceHybridTotal, priceResult.PriceValueTotal)
+	priceFinal := models.ConvertCurrency(basePrice, priceResult.BaseCurrency, moneda, priceResult.ExchangeRate)
+
+	// Si falla la carga de config, tax usa la tarifa general por defecto
+	config, err := h.configLoader.LoadContext(ctx)
+	if err != nil {
+		config = nil
+	}
+
+	// IVA sobre el monto en moneda base; estimado anónimo → sin exoneración
+	iva := tax.For(config, tax.KindForMaterial(req.MaterialIncluded), basePrice, nil, time.Now()).
+		Convert(priceResult.BaseCurrency, moneda, priceResult.ExchangeRate)
 
 	resp := EstimateResponse{
-		PrecioEstimado:   math.Round(priceFinal),
-		PrecioUnitario:   precioUnitario,
+		PrecioEstimado:   models.RoundCurrency(priceFinal, moneda),
+		PrecioUnitario:   models.RoundCurrency(priceFinal/float64(req.Cantidad), moneda),
+		Subtotal:         iva.Subtotal,
+		TarifaIVA:        iva.Rate,
+		IVA:              iva.Tax,
+		Total:            iva.Total,
+		ModoIVA:          iva.Display,
+		Moneda:           moneda,
 		AreaCM2:          req.AltoCM * req.AnchoCM,
 		DescuentoVolumen: priceResult.DiscountVolumePct,
 	}
+	if moneda != priceResult.BaseCurrency {
+		resp.TipoCambio = priceResult.ExchangeRate
+	}
 
 	// Advertencia si el trabajo necesita revisión humana
 	if priceResult.Status == models.QuoteStatusNeedsReview {
@@ -128,7 +169,7 @@ func (h *Handler) HandleEstimate(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Obtener nombres desde config cacheada (sin hit extra a BD)
-	if config, err := h.configLoader.Load(); err == nil {
+	if config != nil {
 		if tech := config.GetTechnology(req.TechnologyID); tech != nil {
 			resp.Tecnologia = tech.Name
 		}
@@ -137,8 +178,7 @@ func (h *Handler) HandleEstimate(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
+	return resp, http.StatusOK
 }
 
 func sendEstimateError(w http.ResponseWriter, msg string, status int) {
@@ -1,16 +1,23 @@
 package quote
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"strconv"
 	"strings"
 
 	"github.com/alonsoalpizar/fabricalaser/internal/database"
+	"github.com/alonsoalpizar/fabricalaser/internal/events"
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
 	"github.com/alonsoalpizar/fabricalaser/internal/repository"
 	"github.com/alonsoalpizar/fabricalaser/internal/services/pricing"
+	"github.com/alonsoalpizar/fabricalaser/internal/services/quotepdf"
 	"github.com/alonsoalpizar/fabricalaser/internal/services/svgengine"
+	"github.com/alonsoalpizar/fabricalaser/internal/services/tax"
 	"github.com/go-chi/chi/v5"
 )
 
@@ -45,59 +52,82 @@ func NewHandler() *Handler {
 func (h *Handler) AnalyzeSVG(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value("userID").(uint)
 
+	filename, svgContent, err := ReadSVGUpload(r)
+	if err != nil {
+		respondRequestError(w, err)
+		return
+	}
+
+	analysis, cached, err := h.Analyze(userID, filename, svgContent)
+	if err != nil {
+		respondRequestError(w, err)
+		return
+	}
+
+	if cached {
+		// Return existing analysis instead of creating duplicate
+		respondJSON(w, http.StatusOK, map[string]interface{}{
+			"data":       analysis.ToSummary(),
+			"cached":     true,
+			"message":    "Este archivo ya fue analizado previamente",
+		})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"data":    analysis.ToSummary(),
+		"cached":  false,
+		"message": "SVG analizado correctamente",
+	})
+}
+
+// ReadSVGUpload reads the "svg" file of a multipart request
+func ReadSVGUpload(r *http.Request) (string, string, error) {
 	// Parse multipart form
 	if err := r.ParseMultipartForm(maxSVGSize); err != nil {
-		respondError(w, http.StatusBadRequest, "INVALID_REQUEST", "Error parsing form data")
-		return
+		return "", "", &RequestError{http.StatusBadRequest, "INVALID_REQUEST", "Error parsing form data"}
 	}
 
 	// Get the SVG file
 	file, header, err := r.FormFile("svg")
 	if err != nil {
-		respondError(w, http.StatusBadRequest, "NO_FILE", "No SVG file provided")
-		return
+		return "", "", &RequestError{http.StatusBadRequest, "NO_FILE", "No SVG file provided"}
 	}
 	defer file.Close()
 
 	// Validate file extension
 	filename := header.Filename
 	if !strings.HasSuffix(strings.ToLower(filename), ".svg") {
-		respondError(w, http.StatusBadRequest, "INVALID_FILE_TYPE", "File must be an SVG")
-		return
+		return "", "", &RequestError{http.StatusBadRequest, "INVALID_FILE_TYPE", "File must be an SVG"}
 	}
 
 	// Read file content
 	svgContent, err := io.ReadAll(io.LimitReader(file, maxSVGSize))
 	if err != nil {
-		respondError(w, http.StatusBadRequest, "READ_ERROR", "Error reading file")
-		return
+		return "", "", &RequestError{http.StatusBadRequest, "READ_ERROR", "Error reading file"}
 	}
+	return filename, string(svgContent), nil
+}
 
+// Analyze analyzes an SVG for the user and saves the analysis. An SVG the user
+// already analyzed returns the existing analysis with cached = true.
+func (h *Handler) Analyze(userID uint, filename, contentStr string) (*models.SVGAnalysis, bool, error) {
 	// Basic SVG validation
-	contentStr := string(svgContent)
 	if !strings.Contains(contentStr, "<svg") {
-		respondError(w, http.StatusBadRequest, "INVALID_SVG", "File does not appear to be a valid SVG")
-		return
+		return nil, false, &RequestError{http.StatusBadRequest, "INVALID_SVG", "File does not appear to be a valid SVG"}
 	}
 
 	// Check for duplicate (same file hash)
 	fileHash := svgengine.CalculateFileHash(contentStr)
 	existingAnalysis, _ := h.svgAnalysisRepo.FindByFileHash(userID, fileHash)
 	if existingAnalysis != nil {
-		// Return existing analysis instead of creating duplicate
-		respondJSON(w, http.StatusOK, map[string]interface{}{
-			"data":       existingAnalysis.ToSummary(),
-			"cached":     true,
-			"message":    "Este archivo ya fue analizado previamente",
-		})
-		return
+		return existingAnalysis, true, nil
 	}
 
 	// Analyze the SVG
 	result, err := h.analyzer.Analyze(contentStr)
 	if err != nil {
-		respondError(w, http.StatusBadRequest, "ANALYSIS_ERROR", "Error analyzing SVG: "+err.Error())
-		return
+		return nil, false, &RequestError{http.StatusBadRequest, "ANALYSIS_ERROR", "Error analyzing SVG: " + err.Error()}
 	}
 
 	// Convert to model
@@ -105,35 +135,29 @@ func (h *Handler) AnalyzeSVG(w http.ResponseWriter, r *http.Request) {
 
 	// Validate: SVG must have at least one type of work (cut, vector, or raster)
 	if !analysis.HasAnyWork() {
-		respondError(w, http.StatusBadRequest, "EMPTY_SVG",
+		return nil, false, &RequestError{http.StatusBadRequest, "EMPTY_SVG",
 			"El archivo no contiene elementos procesables. "+
 			"Debe tener al menos: corte (rojo #FF0000), grabado vectorial (azul #0000FF), "+
-			"o grabado raster (negro #000000)")
-		return
+			"o grabado raster (negro #000000)"}
 	}
 	if err := h.svgAnalysisRepo.Create(analysis); err != nil {
-		respondError(w, http.StatusInternalServerError, "DB_ERROR", "Error saving analysis")
-		return
+		return nil, false, &RequestError{http.StatusInternalServerError, "DB_ERROR", "Error saving analysis"}
 	}
-
-	respondJSON(w, http.StatusOK, map[string]interface{}{
-		"data":    analysis.ToSummary(),
-		"cached":  false,
-		"message": "SVG analizado correctamente",
-	})
+	return analysis, false, nil
 }
 
 // CalculateRequest represents the request body for price calculation
 type CalculateRequest struct {
-	AnalysisID       uint    `json:"analysis_id"`
-	TechnologyID     uint    `json:"technology_id"`
-	MaterialID       uint    `json:"material_id"`
-	EngraveTypeID    uint    `json:"engrave_type_id"`
+	AnalysisID       uint    `json:"analysis_id" openapi:"required"`
+	TechnologyID     uint    `json:"technology_id" openapi:"required"`
+	MaterialID       uint    `json:"material_id" openapi:"required"`
+	EngraveTypeID    uint    `json:"engrave_type_id" openapi:"required"`
 	Quantity         int     `json:"quantity"`
 	Thickness        float64 `json:"thickness,omitempty"`
 	MaterialIncluded *bool   `json:"material_included,omitempty"` // default true if not specified
 	CutTechnologyID  *uint   `json:"cut_technology_id,omitempty"` // nil = misma tech para corte
 	IgnoreCutLines   bool    `json:"ignore_cut_lines,omitempty"`  // true = material no cortable
+	Currency         string  `json:"currency,omitempty"`          // "CRC" (default) o "USD" — moneda de presentación
 }
 
 // CalculatePrice handles POST /api/v1/quotes/calculate
@@ -147,16 +171,34 @@ func (h *Handler) CalculatePrice(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	quote, err := h.Calculate(r.Context(), userID, req)
+	if err != nil {
+		respondRequestError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"data":    quote.ToDetailedJSON(),
+		"message": "Cotización calculada correctamente",
+	})
+}
+
+// Calculate prices one of the user's analyses and saves the quote in the
+// tenant of ctx. The quote is returned with its relations loaded.
+func (h *Handler) Calculate(ctx context.Context, userID uint, req CalculateRequest) (*models.Quote, error) {
 	// Validate required fields
 	if req.AnalysisID == 0 || req.TechnologyID == 0 || req.MaterialID == 0 || req.EngraveTypeID == 0 {
-		respondError(w, http.StatusBadRequest, "MISSING_FIELDS", "analysis_id, technology_id, material_id, and engrave_type_id are required")
-		return
+		return nil, &RequestError{http.StatusBadRequest, "MISSING_FIELDS", "analysis_id, technology_id, material_id, and engrave_type_id are required"}
 	}
 
 	if req.Quantity < 1 {
 		req.Quantity = 1
 	}
 
+	if req.Currency != "" && !models.IsSupportedCurrency(req.Currency) {
+		return nil, &RequestError{http.StatusBadRequest, "INVALID_CURRENCY", "currency debe ser CRC o USD"}
+	}
+
 	// Default material_included to true if not specified
 	materialIncluded := true
 	if req.MaterialIncluded != nil {
@@ -166,40 +208,36 @@ func (h *Handler) CalculatePrice(w http.ResponseWriter, r *http.Request) {
 	// Get the analysis
 	analysis, err := h.svgAnalysisRepo.FindByID(req.AnalysisID)
 	if err != nil {
-		respondError(w, http.StatusNotFound, "ANALYSIS_NOT_FOUND", "SVG analysis not found")
-		return
+		return nil, &RequestError{http.StatusNotFound, "ANALYSIS_NOT_FOUND", "SVG analysis not found"}
 	}
 
 	// Verify ownership
 	if analysis.UserID != userID {
-		respondError(w, http.StatusForbidden, "FORBIDDEN", "No tiene permiso para usar este análisis")
-		return
+		return nil, &RequestError{http.StatusForbidden, "FORBIDDEN", "No tiene permiso para usar este análisis"}
 	}
 
 	// Validate tech×material compatibility BEFORE calculating
 	// This prevents calculating prices for impossible combinations (e.g., CO2 + Metal)
-	config, err := h.configLoader.Load()
+	config, err := h.configLoader.LoadContext(ctx)
 	if err != nil {
-		respondError(w, http.StatusInternalServerError, "CONFIG_ERROR", "Error loading configuration")
-		return
+		return nil, &RequestError{http.StatusInternalServerError, "CONFIG_ERROR", "Error loading configuration"}
 	}
 	compatible, reason := config.IsCompatible(req.TechnologyID, req.MaterialID, req.Thickness)
 	if !compatible {
-		respondError(w, http.StatusBadRequest, "INCOMPATIBLE_COMBINATION", reason)
-		return
+		return nil, &RequestError{http.StatusBadRequest, "INCOMPATIBLE_COMBINATION", reason}
 	}
 
 	// Calculate pricing (uses DB config, NO hardcode)
 	// Now includes thickness for specific speed lookups from tech_material_speeds
 	// and materialIncluded for raw material cost calculation
-	priceResult, err := h.calculator.Calculate(analysis, req.TechnologyID, req.MaterialID, req.EngraveTypeID, req.Thickness, req.Quantity, materialIncluded, req.CutTechnologyID, req.IgnoreCutLines)
+	calculator := h.calculator.WithContext(ctx)
+	priceResult, err := calculator.Calculate(analysis, req.TechnologyID, req.MaterialID, req.EngraveTypeID, req.Thickness, req.Quantity, materialIncluded, req.CutTechnologyID, req.IgnoreCutLines)
 	if err != nil {
-		respondError(w, http.StatusInternalServerError, "CALC_ERROR", "Error calculating price: "+err.Error())
-		return
+		return nil, &RequestError{http.StatusInternalServerError, "CALC_ERROR", "Error calculating price: " + err.Error()}
 	}
 
 	// Create and save quote
-	quote := h.calculator.ToQuoteModel(
+	quote := calculator.ToQuoteModel(
 		priceResult,
 		userID,
 		analysis.ID,
@@ -210,23 +248,28 @@ func (h *Handler) CalculatePrice(w http.ResponseWriter, r *http.Request) {
 		req.Thickness,
 		req.CutTechnologyID,
 		req.IgnoreCutLines,
+		req.Currency,
 	)
 
-	if err := h.quoteRepo.Create(quote); err != nil {
-		respondError(w, http.StatusInternalServerError, "DB_ERROR", "Error saving quote")
-		return
+	// IVA según tipo de venta y exoneración vigente del cliente
+	userRepo := h.userRepo.WithContext(ctx)
+	user, _ := userRepo.FindByID(userID)
+	tax.ApplyToQuote(quote, config, user)
+
+	quoteRepo := h.quoteRepo.WithContext(ctx)
+	if err := quoteRepo.Create(quote); err != nil {
+		return nil, &RequestError{http.StatusInternalServerError, "DB_ERROR", "Error saving quote"}
 	}
 
 	// Increment user's quotes used
-	h.userRepo.IncrementQuotesUsed(userID)
+	userRepo.IncrementQuotesUsed(userID)
+	events.Publish(events.Event{Type: events.QuoteCreated, TenantID: quote.TenantID, UserID: userID, QuoteID: quote.ID})
 
 	// Load relations for response
-	quote, _ = h.quoteRepo.FindByIDWithRelations(quote.ID)
-
-	respondJSON(w, http.StatusOK, map[string]interface{}{
-		"data":    quote.ToDetailedJSON(),
-		"message": "Cotización calculada correctamente",
-	})
+	if loaded, err := quoteRepo.FindByIDWithRelations(quote.ID); err == nil {
+		quote = loaded
+	}
+	return quote, nil
 }
 
 // GetQuote handles GET /api/v1/quotes/:id
@@ -241,14 +284,14 @@ func (h *Handler) GetQuote(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	quote, err := h.quoteRepo.FindByIDWithRelations(uint(id))
+	quote, err := h.quoteRepo.WithContext(r.Context()).FindByIDWithRelations(uint(id))
 	if err != nil {
 		respondError(w, http.StatusNotFound, "NOT_FOUND", "Quote not found")
 		return
 	}
 
 	// Check ownership (unless admin)
-	user, _ := h.userRepo.FindByID(userID)
+	user, _ := h.userRepo.WithContext(r.Context()).FindByID(userID)
 	if quote.UserID != userID && !user.IsAdmin() {
 		respondError(w, http.StatusForbidden, "FORBIDDEN", "No tiene permiso para ver esta cotización")
 		return
@@ -259,6 +302,50 @@ func (h *Handler) GetQuote(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// GetQuotePDF handles GET /api/v1/quotes/:id/pdf
+// Returns the quote as a formal PDF document
+func (h *Handler) GetQuotePDF(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(uint)
+
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "INVALID_ID", "Invalid quote ID")
+		return
+	}
+
+	quote, err := h.quoteRepo.WithContext(r.Context()).FindByIDWithRelations(uint(id))
+	if err != nil {
+		respondError(w, http.StatusNotFound, "NOT_FOUND", "Quote not found")
+		return
+	}
+
+	// Check ownership (unless admin)
+	user, _ := h.userRepo.WithContext(r.Context()).FindByID(userID)
+	if quote.UserID != userID && (user == nil || !user.IsAdmin()) {
+		respondError(w, http.StatusForbidden, "FORBIDDEN", "No tiene permiso para ver esta cotización")
+		return
+	}
+
+	config, err := h.configLoader.LoadContext(r.Context())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "CONFIG_ERROR", "Error cargando configuración")
+		return
+	}
+
+	pdf, err := quotepdf.Generate(quote, config)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "PDF_ERROR", "Error generando el PDF de la cotización")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="%s"`, quotepdf.Filename(quote)))
+	w.Header().Set("Cache-Control", "private, no-cache")
+	w.WriteHeader(http.StatusOK)
+	w.Write(pdf)
+}
+
 // GetMyQuotes handles GET /api/v1/quotes/my
 // Returns current user's quotes
 func (h *Handler) GetMyQuotes(w http.ResponseWriter, r *http.Request) {
@@ -278,7 +365,7 @@ func (h *Handler) GetMyQuotes(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	quotes, err := h.quoteRepo.FindByUserIDWithRelations(userID, limit, offset)
+	quotes, err := h.quoteRepo.WithContext(r.Context()).FindByUserIDWithRelations(userID, limit, offset)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "DB_ERROR", "Error fetching quotes")
 		return
@@ -291,7 +378,7 @@ func (h *Handler) GetMyQuotes(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get total count
-	total, _ := h.quoteRepo.CountByUser(userID)
+	total, _ := h.quoteRepo.WithContext(r.Context()).CountByUser(userID)
 
 	respondJSON(w, http.StatusOK, map[string]interface{}{
 		"data":   list,
@@ -381,6 +468,25 @@ func respondJSON(w http.ResponseWriter, status int, data interface{}) {
 	json.NewEncoder(w).Encode(data)
 }
 
+// RequestError is a validation or business error with its HTTP status
+type RequestError struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *RequestError) Error() string { return e.Message }
+
+// respondRequestError responds err; errors other than RequestError are 500
+func respondRequestError(w http.ResponseWriter, err error) {
+	var re *RequestError
+	if errors.As(err, &re) {
+		respondError(w, re.Status, re.Code, re.Message)
+		return
+	}
+	respondError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+}
+
 func respondError(w http.ResponseWriter, status int, code, message string) {
 	respondJSON(w, status, map[string]interface{}{
 		"error": map[string]interface{}{
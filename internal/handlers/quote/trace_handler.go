@@ -0,0 +1,120 @@
+package quote
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/services/svgengine"
+	"github.com/alonsoalpizar/fabricalaser/internal/services/tracer"
+)
+
+const maxImageSize = 10 * 1024 * 1024 // 10MB max imagen a vectorizar
+
+// TraceImage handles POST /api/v1/quotes/trace
+// Vectoriza una foto o imagen de logo al tamaño indicado y la guarda como análisis,
+// así el cotizador web la cotiza con /calculate igual que un SVG subido.
+// Campos multipart: image (PNG/JPG/GIF), width_mm y/o height_mm, mode (raster|vector),
+// threshold (1-254, opcional) y adaptive (true para fotos con sombras).
+func (h *Handler) TraceImage(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(uint)
+
+	if err := r.ParseMultipartForm(maxImageSize); err != nil {
+		respondError(w, http.StatusBadRequest, "INVALID_REQUEST", "Error parsing form data")
+		return
+	}
+
+	file, header, err := r.FormFile("image")
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "NO_FILE", "No image file provided")
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxImageSize))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "READ_ERROR", "Error reading file")
+		return
+	}
+
+	opts := tracer.Options{
+		Mode:     r.FormValue("mode"),
+		Adaptive: r.FormValue("adaptive") == "true",
+	}
+	opts.WidthMM, _ = strconv.ParseFloat(r.FormValue("width_mm"), 64)
+	opts.HeightMM, _ = strconv.ParseFloat(r.FormValue("height_mm"), 64)
+	opts.Threshold, _ = strconv.Atoi(r.FormValue("threshold"))
+	if opts.Mode != "" && opts.Mode != tracer.ModeRaster && opts.Mode != tracer.ModeVector {
+		respondError(w, http.StatusBadRequest, "INVALID_MODE", "mode debe ser raster o vector")
+		return
+	}
+
+	traced, err := tracer.Trace(data, opts)
+	switch {
+	case errors.Is(err, tracer.ErrInvalidSize):
+		respondError(w, http.StatusBadRequest, "INVALID_SIZE", err.Error())
+		return
+	case errors.Is(err, tracer.ErrInvalidImage):
+		respondError(w, http.StatusBadRequest, "INVALID_IMAGE", err.Error())
+		return
+	case errors.Is(err, tracer.ErrNoShapes):
+		respondError(w, http.StatusBadRequest, "EMPTY_IMAGE", err.Error())
+		return
+	case err != nil:
+		respondError(w, http.StatusInternalServerError, "TRACE_ERROR", "Error vectorizando la imagen")
+		return
+	}
+
+	mode := opts.Mode
+	if mode == "" {
+		mode = tracer.ModeRaster
+	}
+	base := strings.TrimSuffix(header.Filename, filepath.Ext(header.Filename))
+	filename := fmt.Sprintf("%s-%s-%gx%gmm.svg", base, mode, traced.WidthMM, traced.HeightMM)
+
+	traceInfo := map[string]interface{}{
+		"width_mm":  traced.WidthMM,
+		"height_mm": traced.HeightMM,
+		"shapes":    traced.Shapes,
+		"holes":     traced.Holes,
+		"inverted":  traced.Inverted,
+	}
+
+	// Misma imagen con las mismas opciones produce el mismo SVG
+	fileHash := svgengine.CalculateFileHash(traced.SVG)
+	if existing, _ := h.svgAnalysisRepo.FindByFileHash(userID, fileHash); existing != nil {
+		respondJSON(w, http.StatusOK, map[string]interface{}{
+			"data":    existing.ToSummary(),
+			"trace":   traceInfo,
+			"cached":  true,
+			"message": "Esta imagen ya fue vectorizada previamente",
+		})
+		return
+	}
+
+	result, err := h.analyzer.Analyze(traced.SVG)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "ANALYSIS_ERROR", "Error analyzing traced SVG: "+err.Error())
+		return
+	}
+	analysis := h.analyzer.ToModel(result, userID, filename, traced.SVG)
+	if !analysis.HasAnyWork() {
+		respondError(w, http.StatusBadRequest, "EMPTY_IMAGE", tracer.ErrNoShapes.Error())
+		return
+	}
+	if err := h.svgAnalysisRepo.Create(analysis); err != nil {
+		respondError(w, http.StatusInternalServerError, "DB_ERROR", "Error saving analysis")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"data":    analysis.ToSummary(),
+		"trace":   traceInfo,
+		"cached":  false,
+		"message": "Imagen vectorizada correctamente",
+	})
+}
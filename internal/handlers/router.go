@@ -1,21 +1,39 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"log"
 	"net/http"
 	"os"
 	"path/filepath"
 	"time"
 
+	"github.com/alonsoalpizar/fabricalaser/internal/channels"
 	"github.com/alonsoalpizar/fabricalaser/internal/database"
+	"github.com/alonsoalpizar/fabricalaser/internal/events"
 	"github.com/alonsoalpizar/fabricalaser/internal/handlers/admin"
 	adminchat "github.com/alonsoalpizar/fabricalaser/internal/handlers/admin/chat"
 	"github.com/alonsoalpizar/fabricalaser/internal/handlers/auth"
 	"github.com/alonsoalpizar/fabricalaser/internal/handlers/chat"
 	"github.com/alonsoalpizar/fabricalaser/internal/handlers/config"
+	"github.com/alonsoalpizar/fabricalaser/internal/handlers/public"
 	"github.com/alonsoalpizar/fabricalaser/internal/handlers/quote"
+	"github.com/alonsoalpizar/fabricalaser/internal/llm"
 	"github.com/alonsoalpizar/fabricalaser/internal/middleware"
+	"github.com/alonsoalpizar/fabricalaser/internal/services/analytics"
+	"github.com/alonsoalpizar/fabricalaser/internal/services/apikeys"
+	"github.com/alonsoalpizar/fabricalaser/internal/services/designfiles"
+	"github.com/alonsoalpizar/fabricalaser/internal/services/handoff"
+	"github.com/alonsoalpizar/fabricalaser/internal/services/knowledge"
+	"github.com/alonsoalpizar/fabricalaser/internal/services/leads"
+	"github.com/alonsoalpizar/fabricalaser/internal/services/memory"
+	"github.com/alonsoalpizar/fabricalaser/internal/services/notifications"
+	"github.com/alonsoalpizar/fabricalaser/internal/services/payments"
+	"github.com/alonsoalpizar/fabricalaser/internal/services/quotedraft"
+	"github.com/alonsoalpizar/fabricalaser/internal/services/webhooks"
 	"github.com/alonsoalpizar/fabricalaser/internal/telegram"
+	"github.com/alonsoalpizar/fabricalaser/internal/tenant"
 	"github.com/alonsoalpizar/fabricalaser/internal/whatsapp"
 	"github.com/go-chi/chi/v5"
 	chiMiddleware "github.com/go-chi/chi/v5/middleware"
@@ -24,7 +42,13 @@ import (
 
 const Version = "1.0.0"
 
-func NewRouter(redisClient *redis.Client) *chi.Mux {
+// Jobs son los procesos periódicos que dependen de servicios armados en el
+// router; cmd/server los arranca con el contexto de vida del servidor.
+type Jobs struct {
+	CustomerMemory *memory.Service // Consolida la memoria de largo plazo de los contactos
+}
+
+func NewRouter(redisClient *redis.Client) (*chi.Mux, *Jobs) {
 	r := chi.NewRouter()
 
 	// Global middleware
@@ -32,12 +56,58 @@ func NewRouter(redisClient *redis.Client) *chi.Mux {
 	r.Use(chiMiddleware.RealIP)
 	r.Use(chiMiddleware.Logger)
 	r.Use(chiMiddleware.Recoverer)
-	r.Use(chiMiddleware.Timeout(60 * time.Second))
+	// Los chats en streaming quedan fuera del corte global: tienen su propio plazo
+	r.Use(middleware.Timeout(60*time.Second, "/api/v1/admin/chat/message/stream", "/api/v1/chat/stream"))
 	r.Use(middleware.CORS)
+	// Taller del request (X-API-Key o Host); los repositorios filtran por él
+	r.Use(tenant.Shared.Middleware)
 
 	// Health check
 	r.Get("/api/v1/health", healthHandler)
 
+	// Pagos: un solo servicio para el panel admin y los webhooks de pasarela
+	paymentService := payments.NewServiceFromConfig()
+
+	// Modelo de lenguaje compartido por los agentes (bot, chat admin, chat web),
+	// con reintentos ante 429 de Vertex AI
+	gemini, err := llm.NewGemini(context.Background(), llm.GCPProjectID, llm.GCPLocation)
+	if err != nil {
+		log.Fatalf("router: %v", err)
+	}
+	model := llm.WithRetry(gemini, llm.DefaultRetryDelays)
+
+	// Canales de mensajería — WhatsApp y Telegram comparten el motor de conversación.
+	// WhatsApp va primero: es el canal por defecto para avisar al asesor.
+	waContextProvider := whatsapp.NewWAContextProvider()
+	waRedis := whatsapp.NewRedisAdapter(redisClient)
+	waPG := whatsapp.NewPGAdapter(database.Get())
+	waChannel := whatsapp.NewChannel(waRedis, waPG, waContextProvider)
+	tgChannel := telegram.NewChannel(waContextProvider)
+	channelRegistry := channels.NewRegistry(waChannel, tgChannel)
+	designFiles := designfiles.NewService(waRedis)
+	handoffService := handoff.NewService(waRedis, waPG, channelRegistry)
+	leadService := leads.NewService()
+	leadService.Subscribe(events.Default)
+	quoteDrafts := quotedraft.NewService()
+	// Base de conocimiento compartida por los agentes (el panel admin invalida su índice)
+	knowledgeBase := knowledge.NewService()
+	waAgent := whatsapp.NewGeminiAdapter(model, waContextProvider, channelRegistry, designFiles, handoffService, leadService, quoteDrafts, knowledgeBase)
+	// Memoria de largo plazo: el job resume las sesiones terminadas y el motor
+	// la suma a DATOS DEL CLIENTE en el próximo contacto
+	customerMemory := memory.NewService(waAgent, model)
+	conversationEngine := channels.NewEngine(
+		waRedis,
+		waPG,
+		waAgent,
+		whatsapp.NewRateLimiter(redisClient),
+		waContextProvider,
+		channelRegistry,
+		designFiles,
+		handoffService,
+		leadService,
+		customerMemory,
+	)
+
 	// Auth routes (public)
 	authHandler := auth.NewAuthHandler()
 	r.Route("/api/v1/auth", func(r chi.Router) {
@@ -55,6 +125,8 @@ func NewRouter(redisClient *redis.Client) *chi.Mux {
 			r.Get("/profile", authHandler.GetProfile)
 			r.Put("/profile", authHandler.UpdateProfile)
 			r.Put("/change-password", authHandler.ChangePassword)
+			r.Get("/notifications", authHandler.GetNotificationPrefs)
+			r.Put("/notifications", authHandler.UpdateNotificationPrefs)
 		})
 	})
 
@@ -69,8 +141,12 @@ func NewRouter(redisClient *redis.Client) *chi.Mux {
 		r.Get("/volume-discounts", configHandler.GetVolumeDiscounts)
 		r.Get("/price-references", configHandler.GetPriceReferences)
 		r.Get("/compatible-options", configHandler.GetCompatibleOptions) // Compatible tech/material options
+		r.Get("/recommend", configHandler.GetRecommendation)             // Ranked technology plans for a job
 	})
 
+	// API keys de clientes B2B (API pública y su administración)
+	apiKeys := apikeys.NewService(redisClient)
+
 	// Admin routes (protected)
 	adminHandler := admin.NewAdminHandler()
 	systemConfigHandler := admin.NewSystemConfigHandler()
@@ -142,6 +218,7 @@ func NewRouter(redisClient *redis.Client) *chi.Mux {
 		r.Get("/quotes", adminHandler.GetQuotes)
 		r.Get("/quotes/{id}", adminHandler.GetQuote)
 		r.Put("/quotes/{id}", adminHandler.UpdateQuote)
+		r.With(tenant.DefaultOnly).Put("/quotes/{id}/draft", admin.NewQuoteDraftHandler(quoteDrafts).AdjustDraft)
 
 		// Tech rates (full CRUD)
 		r.Get("/tech-rates", adminHandler.GetTechRates)
@@ -156,6 +233,45 @@ func NewRouter(redisClient *redis.Client) *chi.Mux {
 		r.Delete("/material-costs/{id}", materialCostHandler.DeleteMaterialCost)
 		r.Post("/material-costs/{id}/recalculate", materialCostHandler.RecalculateMaterialCost)
 
+		// Rutas que todavía no propagan el tenant: solo el taller por defecto
+		// (los tipos de cambio además son compartidos por todos los talleres)
+		r.Group(func(r chi.Router) {
+			r.Use(tenant.DefaultOnly)
+
+			// Tipos de cambio USD→CRC (manual o importados de CSV/BCCR)
+			exchangeRateHandler := admin.NewExchangeRateHandler()
+			r.Get("/exchange-rates", exchangeRateHandler.GetExchangeRates)
+			r.Get("/exchange-rates/current", exchangeRateHandler.GetCurrentExchangeRate)
+			r.Post("/exchange-rates", exchangeRateHandler.CreateExchangeRate)
+			r.Post("/exchange-rates/import", exchangeRateHandler.ImportExchangeRates)
+			r.Put("/exchange-rates/{id}", exchangeRateHandler.UpdateExchangeRate)
+			r.Delete("/exchange-rates/{id}", exchangeRateHandler.DeleteExchangeRate)
+
+			// Facturación electrónica (Hacienda v4.4)
+			invoiceHandler := admin.NewInvoiceHandler()
+			r.Post("/quotes/{id}/invoice", invoiceHandler.IssueInvoice)
+			r.Get("/invoices", invoiceHandler.GetInvoices)
+			r.Get("/invoices/{id}", invoiceHandler.GetInvoice)
+			r.Get("/invoices/{id}/xml", invoiceHandler.DownloadInvoiceXML)
+			r.Post("/invoices/{id}/refresh", invoiceHandler.RefreshInvoiceStatus)
+
+			// Pagos — SINPE Móvil, tarjeta, conciliación y devoluciones
+			paymentHandler := admin.NewPaymentHandler(paymentService)
+			r.Get("/payments/providers", paymentHandler.GetProviders)
+			r.Post("/quotes/{id}/payment-intents", paymentHandler.CreateIntent)
+			r.Get("/quotes/{id}/payment-intents", paymentHandler.GetQuoteIntents)
+			r.Get("/payment-intents", paymentHandler.GetIntents)
+			r.Post("/payment-intents/{id}/cancel", paymentHandler.CancelIntent)
+			r.Post("/payment-intents/{id}/refunds", paymentHandler.RefundIntent)
+			r.Post("/payment-refunds/{id}/complete", paymentHandler.CompleteRefund)
+			r.Post("/payments/import", paymentHandler.ImportStatement)
+			r.Post("/payments/sinpe-text", paymentHandler.ImportSINPEText)
+			r.Post("/payments/reconcile", paymentHandler.RunReconciliation)
+			r.Get("/payment-transactions", paymentHandler.GetTransactions)
+			r.Post("/payment-transactions/{id}/match", paymentHandler.MatchTransaction)
+			r.Post("/payment-transactions/{id}/ignore", paymentHandler.IgnoreTransaction)
+		})
+
 		// Blanks (catálogo preconfigurado) CRUD
 		blankHandler := admin.NewBlankHandler()
 		r.Get("/blanks", blankHandler.GetAll)
@@ -165,59 +281,125 @@ func NewRouter(redisClient *redis.Client) *chi.Mux {
 		r.Patch("/blanks/{id}/stock", blankHandler.UpdateStock)
 		r.Patch("/blanks/{id}/featured", blankHandler.ToggleFeatured)
 
-		// WhatsApp bitácora — sesiones paginadas + depuración + digest manual
-		waAdminHandler := admin.NewWhatsappHandler(redisClient)
-		r.Get("/whatsapp/sessions", waAdminHandler.GetSessions)
-		r.Get("/whatsapp/sessions/{phone}/{date}", waAdminHandler.GetSessionMessages)
-		r.Post("/whatsapp/purge", waAdminHandler.PurgeConversations)
-		r.Post("/whatsapp/digest/send", waAdminHandler.SendDigest)
-		// Legacy
-		r.Get("/whatsapp/conversations", waAdminHandler.GetConversations)
-		r.Get("/whatsapp/conversations/{phone}", waAdminHandler.GetConversation)
-
-		// Chat administrativo — asistente Gemini para gestores
-		adminChatCtxProvider := adminchat.NewContextProvider()
-		adminChatHandler := adminchat.NewHandler(redisClient, adminChatCtxProvider)
-		r.Post("/chat/message", adminChatHandler.SendMessage)
-		r.Post("/chat/reset", adminChatHandler.Reset)
-		r.Get("/chat/history", adminChatHandler.GetHistory)
-		r.Get("/chat/sessions", adminChatHandler.ListSessions)
-		r.Get("/chat/sessions/{id}", adminChatHandler.GetSessionMessages)
+		// API keys de la API pública — emisión, rotación, revocación y consumo
+		apiKeyHandler := admin.NewAPIKeyHandler(apiKeys)
+		r.Get("/api-keys", apiKeyHandler.GetAll)
+		r.Post("/api-keys", apiKeyHandler.Create)
+		r.Put("/api-keys/{id}", apiKeyHandler.Update)
+		r.Post("/api-keys/{id}/rotate", apiKeyHandler.Rotate)
+		r.Post("/api-keys/{id}/revoke", apiKeyHandler.Revoke)
+		r.Get("/api-keys/{id}/usage", apiKeyHandler.GetUsage)
+
+		// Webhooks salientes — suscripciones, bitácora de entregas, prueba y reenvío
+		webhookHandler := admin.NewWebhookHandler(webhooks.NewDispatcher())
+		r.Get("/webhooks", webhookHandler.GetAll)
+		r.Post("/webhooks", webhookHandler.Create)
+		r.Put("/webhooks/{id}", webhookHandler.Update)
+		r.Delete("/webhooks/{id}", webhookHandler.Delete)
+		r.Post("/webhooks/{id}/rotate-secret", webhookHandler.RotateSecret)
+		r.Post("/webhooks/{id}/test", webhookHandler.Test)
+		r.Get("/webhook-deliveries", webhookHandler.GetDeliveries)
+		r.Post("/webhook-deliveries/{id}/replay", webhookHandler.Replay)
+
+		r.Group(func(r chi.Router) {
+			r.Use(tenant.DefaultOnly)
+
+			// Base de conocimiento de los agentes (tool buscar_conocimiento)
+			knowledgeHandler := admin.NewKnowledgeHandler(knowledgeBase)
+			r.Get("/knowledge", knowledgeHandler.GetAll)
+			r.Get("/knowledge/search", knowledgeHandler.Search)
+			r.Get("/knowledge/{id}", knowledgeHandler.Get)
+			r.Post("/knowledge", knowledgeHandler.Create)
+			r.Put("/knowledge/{id}", knowledgeHandler.Update)
+			r.Delete("/knowledge/{id}", knowledgeHandler.Delete)
+
+			// Memoria de los clientes del bot (ver, corregir, borrar por privacidad)
+			memoryHandler := admin.NewMemoryHandler(customerMemory)
+			r.Get("/customer-memories", memoryHandler.List)
+			r.Get("/customer-memories/{key}", memoryHandler.Get)
+			r.Put("/customer-memories/{key}", memoryHandler.Update)
+			r.Delete("/customer-memories/{key}", memoryHandler.Delete)
+
+			// WhatsApp bitácora — sesiones paginadas + depuración + digest manual
+			waAdminHandler := admin.NewWhatsappHandler(redisClient)
+			r.Get("/whatsapp/sessions", waAdminHandler.GetSessions)
+			r.Get("/whatsapp/sessions/{phone}/{date}", waAdminHandler.GetSessionMessages)
+			r.Post("/whatsapp/purge", waAdminHandler.PurgeConversations)
+			r.Post("/whatsapp/digest/send", waAdminHandler.SendDigest)
+			r.Post("/whatsapp/template", waAdminHandler.SendTemplate)
+			// Legacy
+			r.Get("/whatsapp/conversations", waAdminHandler.GetConversations)
+			r.Get("/whatsapp/conversations/{phone}", waAdminHandler.GetConversation)
+
+			// Bandeja de atención humana — conversaciones escaladas por el bot
+			handoffHandler := admin.NewHandoffHandler(handoffService)
+			r.Get("/handoffs", handoffHandler.GetHandoffs)
+			r.Get("/handoffs/{id}", handoffHandler.GetHandoff)
+			r.Post("/handoffs/{id}/claim", handoffHandler.Claim)
+			r.Post("/handoffs/{id}/reply", handoffHandler.Reply)
+			r.Post("/handoffs/{id}/resolve", handoffHandler.Resolve)
+
+			// Avisos proactivos a clientes — bitácora y reintento manual
+			notificationHandler := admin.NewNotificationHandler(notifications.NewDispatcher())
+			r.Get("/notifications", notificationHandler.GetNotifications)
+			r.Post("/notifications/{id}/retry", notificationHandler.Retry)
+
+			// Leads del bot y embudo de ventas por canal y semana
+			leadHandler := admin.NewLeadHandler()
+			r.Get("/leads", leadHandler.GetLeads)
+			r.Get("/leads/funnel", leadHandler.GetFunnel)
+			r.Get("/leads/{id}", leadHandler.GetLead)
+
+			// Analítica de conversaciones del bot (rollups diarios del job nocturno)
+			analyticsHandler := admin.NewAnalyticsHandler(analytics.NewService())
+			r.Get("/analytics/overview", analyticsHandler.GetOverview)
+			r.Get("/analytics/tools", analyticsHandler.GetToolUsage)
+			r.Get("/analytics/peak-hours", analyticsHandler.GetPeakHours)
+			r.Get("/analytics/topics", analyticsHandler.GetTopics)
+			r.Post("/analytics/rollup", analyticsHandler.Rollup)
+
+			// Chat administrativo — asistente Gemini para gestores
+			adminChatCtxProvider := adminchat.NewContextProvider()
+			adminChatHandler := adminchat.NewHandler(model, redisClient, adminChatCtxProvider, knowledgeBase)
+			r.Post("/chat/message", adminChatHandler.SendMessage)
+			r.Post("/chat/message/stream", adminChatHandler.SendMessageStream)
+			r.Post("/chat/reset", adminChatHandler.Reset)
+			r.Get("/chat/history", adminChatHandler.GetHistory)
+			r.Get("/chat/sessions", adminChatHandler.ListSessions)
+			r.Get("/chat/sessions/{id}", adminChatHandler.GetSessionMessages)
+			r.Get("/chat/actions", adminChatHandler.ListActions)
+			r.Post("/chat/actions/{id}/confirm", adminChatHandler.ConfirmAction)
+			r.Post("/chat/actions/{id}/cancel", adminChatHandler.CancelAction)
+		})
 	})
 
 	// WhatsApp webhook
-	waContextProvider := whatsapp.NewWAContextProvider()
-	waHandler := whatsapp.NewHandler(
-		whatsapp.NewRedisAdapter(redisClient),
-		whatsapp.NewPGAdapter(database.Get()),
-		whatsapp.NewGeminiAdapter(waContextProvider),
-		whatsapp.NewRateLimiter(redisClient),
-		waContextProvider,
-	)
+	waHandler := whatsapp.NewHandler(waChannel, conversationEngine)
 	r.Route("/api/v1/whatsapp", func(r chi.Router) {
 		r.Get("/webhook", waHandler.VerifyWebhook)
 		r.Post("/webhook", waHandler.HandleMessage)
 	})
 
 	// Telegram webhook
-	tgProcessor := telegram.NewProcessor(
-		whatsapp.NewRedisAdapter(redisClient),
-		whatsapp.NewPGAdapter(database.Get()),
-		whatsapp.NewGeminiAdapter(waContextProvider),
-		whatsapp.NewRateLimiter(redisClient),
-		waContextProvider,
-	)
-	tgHandler := telegram.NewHandler(tgProcessor)
+	tgHandler := telegram.NewHandler(tgChannel, conversationEngine)
 	r.Post("/api/v1/telegram/webhook", tgHandler.HandleWebhook)
+	r.Post("/api/v1/telegram/webhook/{tenant}", tgHandler.HandleWebhook)
+
+	// Webhooks de pasarelas de pago (firmados por el proveedor, sin JWT)
+	r.With(tenant.DefaultOnly).Post("/api/v1/payments/webhook/{provider}", admin.NewPaymentHandler(paymentService).HandleWebhook)
 
-	// Blanks consultar — endpoint interno para el agente de WhatsApp (sin JWT)
-	r.Post("/api/v1/blanks/consultar", admin.NewBlankHandler().ConsultarBlank)
+	// Blanks — consultar (agente de WhatsApp) y configurar con grabado (catálogo web), sin JWT
+	blankPublic := admin.NewBlankHandler()
+	r.Post("/api/v1/blanks/consultar", blankPublic.ConsultarBlank)
+	r.Post("/api/v1/blanks/configurar", blankPublic.Configurar)
 
 	// Chat route (public - auth optional, enriches context if logged in)
-	chatHandler := chat.NewHandler()
+	chatHandler := chat.NewHandler(model, knowledgeBase)
 	r.Route("/api/v1/chat", func(r chi.Router) {
+		r.Use(tenant.DefaultOnly)
 		r.Use(middleware.AuthOptional)
 		r.Post("/", chatHandler.HandleChat)
+		r.Post("/stream", chatHandler.HandleChatStream)
 		r.Post("/summary", chatHandler.HandleSummary)
 	})
 
@@ -234,47 +416,54 @@ func NewRouter(redisClient *redis.Client) *chi.Mux {
 		r.With(middleware.AuthMiddleware).Get("/analyses", quoteHandler.GetMyAnalyses)
 		r.With(middleware.AuthMiddleware).Get("/analyses/{id}/svg", quoteHandler.GetAnalysisSVG)
 		r.With(middleware.AuthMiddleware).Get("/{id}", quoteHandler.GetQuote)
+		r.With(middleware.AuthMiddleware).Get("/{id}/pdf", quoteHandler.GetQuotePDF)
 
 		// POST endpoints — requieren JWT + cuota
 		r.With(middleware.AuthMiddleware, middleware.QuotaMiddleware).Post("/analyze", quoteHandler.AnalyzeSVG)
+		r.With(middleware.AuthMiddleware, middleware.QuotaMiddleware).Post("/trace", quoteHandler.TraceImage)
 		r.With(middleware.AuthMiddleware, middleware.QuotaMiddleware).Post("/calculate", quoteHandler.CalculatePrice)
 	})
 
-	// Static file routes
-	webDir := "/opt/FabricaLaser/web"
+	// API pública versionada para integraciones B2B (API key por cliente)
+	r.Route(public.BasePath, public.NewHandler(quoteHandler).Routes(apiKeys))
+
+	// Static file routes — cada taller sirve su sitio (branding.web_dir)
+	webDir := func(r *http.Request) string {
+		return tenantWebDir(r, "/opt/FabricaLaser/web")
+	}
 
 	// Landing page
 	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
-		http.ServeFile(w, r, filepath.Join(webDir, "landing", "index.html"))
+		http.ServeFile(w, r, filepath.Join(webDir(r), "landing", "index.html"))
 	})
 	r.Get("/landing", func(w http.ResponseWriter, r *http.Request) {
-		http.ServeFile(w, r, filepath.Join(webDir, "landing", "index.html"))
+		http.ServeFile(w, r, filepath.Join(webDir(r), "landing", "index.html"))
 	})
 	r.Get("/landing/", func(w http.ResponseWriter, r *http.Request) {
-		http.ServeFile(w, r, filepath.Join(webDir, "landing", "index.html"))
+		http.ServeFile(w, r, filepath.Join(webDir(r), "landing", "index.html"))
 	})
 
 	// SEO files
 	r.Get("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
-		http.ServeFile(w, r, filepath.Join(webDir, "robots.txt"))
+		http.ServeFile(w, r, filepath.Join(webDir(r), "robots.txt"))
 	})
 	r.Get("/sitemap.xml", func(w http.ResponseWriter, r *http.Request) {
-		http.ServeFile(w, r, filepath.Join(webDir, "sitemap.xml"))
+		http.ServeFile(w, r, filepath.Join(webDir(r), "sitemap.xml"))
 	})
 	r.Get("/favicon.svg", func(w http.ResponseWriter, r *http.Request) {
-		http.ServeFile(w, r, filepath.Join(webDir, "favicon.svg"))
+		http.ServeFile(w, r, filepath.Join(webDir(r), "favicon.svg"))
 	})
 	r.Get("/logo-oficial.svg", func(w http.ResponseWriter, r *http.Request) {
-		http.ServeFile(w, r, filepath.Join(webDir, "logo-oficial.svg"))
+		http.ServeFile(w, r, filepath.Join(webDir(r), "logo-oficial.svg"))
 	})
 	r.Get("/logo.png", func(w http.ResponseWriter, r *http.Request) {
-		http.ServeFile(w, r, filepath.Join(webDir, "logo.png"))
+		http.ServeFile(w, r, filepath.Join(webDir(r), "logo.png"))
 	})
 	r.Get("/favicon.ico", func(w http.ResponseWriter, r *http.Request) {
-		http.ServeFile(w, r, filepath.Join(webDir, "favicon.ico"))
+		http.ServeFile(w, r, filepath.Join(webDir(r), "favicon.ico"))
 	})
 	r.Get("/googleeb4aa376b55ad413.html", func(w http.ResponseWriter, r *http.Request) {
-		http.ServeFile(w, r, filepath.Join(webDir, "googleeb4aa376b55ad413.html"))
+		http.ServeFile(w, r, filepath.Join(webDir(r), "googleeb4aa376b55ad413.html"))
 	})
 
 	// Admin pages (redirect /admin to /admin/ for correct relative paths)
@@ -282,31 +471,31 @@ func NewRouter(redisClient *redis.Client) *chi.Mux {
 		http.Redirect(w, r, "/admin/", http.StatusMovedPermanently)
 	})
 	r.Get("/admin/", func(w http.ResponseWriter, r *http.Request) {
-		http.ServeFile(w, r, filepath.Join(webDir, "admin", "index.html"))
+		http.ServeFile(w, r, filepath.Join(webDir(r), "admin", "index.html"))
 	})
-	r.Handle("/admin/*", http.StripPrefix("/admin/", http.FileServer(http.Dir(filepath.Join(webDir, "admin")))))
+	r.Handle("/admin/*", siteFiles("/admin/", "admin", webDir))
 
 	// Mi cuenta page
 	r.Get("/mi-cuenta", func(w http.ResponseWriter, r *http.Request) {
-		http.ServeFile(w, r, filepath.Join(webDir, "mi-cuenta", "index.html"))
+		http.ServeFile(w, r, filepath.Join(webDir(r), "mi-cuenta", "index.html"))
 	})
 
 	// Reset password page
 	r.Get("/reset-password", func(w http.ResponseWriter, r *http.Request) {
-		http.ServeFile(w, r, filepath.Join(webDir, "reset-password", "index.html"))
+		http.ServeFile(w, r, filepath.Join(webDir(r), "reset-password", "index.html"))
 	})
 
 	// Cotizar page (Phase 1 - requires auth via JS)
 	r.Get("/cotizar", func(w http.ResponseWriter, r *http.Request) {
-		http.ServeFile(w, r, filepath.Join(webDir, "cotizar", "index.html"))
+		http.ServeFile(w, r, filepath.Join(webDir(r), "cotizar", "index.html"))
 	})
 	r.Get("/cotizar/", func(w http.ResponseWriter, r *http.Request) {
-		http.ServeFile(w, r, filepath.Join(webDir, "cotizar", "index.html"))
+		http.ServeFile(w, r, filepath.Join(webDir(r), "cotizar", "index.html"))
 	})
 
 	// Privacidad page
 	r.Get("/privacidad", func(w http.ResponseWriter, r *http.Request) {
-		http.ServeFile(w, r, filepath.Join(webDir, "privacidad", "index.html"))
+		http.ServeFile(w, r, filepath.Join(webDir(r), "privacidad", "index.html"))
 	})
 	r.Get("/privacidad/", func(w http.ResponseWriter, r *http.Request) {
 		http.Redirect(w, r, "/privacidad", http.StatusMovedPermanently)
@@ -314,7 +503,7 @@ func NewRouter(redisClient *redis.Client) *chi.Mux {
 
 	// Términos de servicio
 	r.Get("/terminos", func(w http.ResponseWriter, r *http.Request) {
-		http.ServeFile(w, r, filepath.Join(webDir, "terminos", "index.html"))
+		http.ServeFile(w, r, filepath.Join(webDir(r), "terminos", "index.html"))
 	})
 	r.Get("/terminos/", func(w http.ResponseWriter, r *http.Request) {
 		http.Redirect(w, r, "/terminos", http.StatusMovedPermanently)
@@ -322,7 +511,7 @@ func NewRouter(redisClient *redis.Client) *chi.Mux {
 
 	// Documentation pages
 	r.Get("/docs/pricing", func(w http.ResponseWriter, r *http.Request) {
-		http.ServeFile(w, r, filepath.Join(webDir, "docs", "pricing.html"))
+		http.ServeFile(w, r, filepath.Join(webDir(r), "docs", "pricing.html"))
 	})
 	r.Get("/docs/pricing/", func(w http.ResponseWriter, r *http.Request) {
 		http.Redirect(w, r, "/docs/pricing", http.StatusMovedPermanently)
@@ -333,12 +522,12 @@ func NewRouter(redisClient *redis.Client) *chi.Mux {
 		http.Redirect(w, r, "/catalogo/", http.StatusMovedPermanently)
 	})
 	r.Get("/catalogo/", func(w http.ResponseWriter, r *http.Request) {
-		http.ServeFile(w, r, filepath.Join(webDir, "catalogo", "index.html"))
+		http.ServeFile(w, r, filepath.Join(webDir(r), "catalogo", "index.html"))
 	})
 
 	// Static assets
-	r.Handle("/assets/*", http.StripPrefix("/assets/", http.FileServer(http.Dir(filepath.Join(webDir, "assets")))))
-	r.Handle("/static/*", http.StripPrefix("/static/", http.FileServer(http.Dir(filepath.Join(webDir, "static")))))
+	r.Handle("/assets/*", siteFiles("/assets/", "assets", webDir))
+	r.Handle("/static/*", siteFiles("/static/", "static", webDir))
 
 	// Uploads — imágenes de blanks y otros archivos subidos
 	uploadsDir := os.Getenv("FABRICALASER_UPLOAD_DIR")
@@ -347,7 +536,24 @@ func NewRouter(redisClient *redis.Client) *chi.Mux {
 	}
 	r.Handle("/uploads/*", http.StripPrefix("/uploads/", http.FileServer(http.Dir(uploadsDir))))
 
-	return r
+	return r, &Jobs{CustomerMemory: customerMemory}
+}
+
+// tenantWebDir retorna la raíz del sitio estático del taller del request
+func tenantWebDir(r *http.Request, fallback string) string {
+	if t := tenant.FromContext(r.Context()); t != nil {
+		if dir := t.Brand().WebDir; dir != "" {
+			return dir
+		}
+	}
+	return fallback
+}
+
+// siteFiles sirve el subdirectorio sub del sitio del taller bajo prefix
+func siteFiles(prefix, sub string, webDir func(*http.Request) string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.StripPrefix(prefix, http.FileServer(http.Dir(filepath.Join(webDir(r), sub)))).ServeHTTP(w, r)
+	})
 }
 
 // ensureDir checks if directory exists
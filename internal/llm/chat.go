@@ -0,0 +1,154 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// Chat es una sesión con historial sobre un Provider
+type Chat struct {
+	provider Provider
+	config   Config
+	History  []Message
+
+	// OnText (opcional) recibe el texto del modelo a medida que se genera: con
+	// él las llamadas van por Stream. Incluye el texto de respuestas que además
+	// piden una tool.
+	OnText func(string)
+	// OnToolStart (opcional) se llama antes de ejecutar cada tool en RunTools
+	OnToolStart func(FunctionCall)
+}
+
+// NewChat abre una sesión con el historial previo (turnos de texto ya guardados)
+func NewChat(p Provider, cfg Config, history []Message) *Chat {
+	return &Chat{provider: p, config: cfg, History: append([]Message(nil), history...)}
+}
+
+// Send manda un turno del usuario (texto, imagen o resultados de tools) y agrega
+// al historial el turno y la respuesta. Si falla, el historial queda como estaba.
+func (c *Chat) Send(ctx context.Context, parts ...Part) (*Response, error) {
+	contents := make([]Message, len(c.History), len(c.History)+2)
+	copy(contents, c.History)
+	contents = append(contents, Message{Role: RoleUser, Parts: parts})
+	var resp *Response
+	var err error
+	if c.OnText != nil {
+		resp, err = Stream(ctx, c.provider, c.config, contents, c.OnText)
+	} else {
+		resp, err = c.provider.Generate(ctx, c.config, contents)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.History = contents
+	if len(resp.Parts) > 0 {
+		c.History = append(c.History, Message{Role: RoleModel, Parts: resp.Parts})
+	}
+	return resp, nil
+}
+
+// ToolTrace es una llamada a tool ya ejecutada. Err es el error de la tool, si
+// lo hubo: al modelo le llega como {"error": "..."}.
+type ToolTrace struct {
+	Call   FunctionCall
+	Result map[string]any
+	Err    error
+}
+
+// RunTools ejecuta el loop de function calling: mientras la respuesta pida una
+// tool la ejecuta con tools y le devuelve el resultado al modelo, hasta maxIter
+// vueltas. Retorna la última respuesta; si no trae texto, el llamador decide el
+// mensaje de respaldo. onCall (opcional) recibe cada llamada ejecutada.
+func (c *Chat) RunTools(ctx context.Context, resp *Response, maxIter int, tools *Registry, onCall func(ToolTrace)) (*Response, error) {
+	for i := 0; i < maxIter; i++ {
+		call := resp.FunctionCall()
+		if call == nil {
+			break
+		}
+
+		if c.OnToolStart != nil {
+			c.OnToolStart(*call)
+		}
+		result, err := tools.Execute(ctx, *call)
+		if err != nil {
+			slog.Error("llm: error ejecutando tool", "tool", call.Name, "error", err)
+			result = map[string]any{"error": err.Error()}
+		}
+		if onCall != nil {
+			onCall(ToolTrace{Call: *call, Result: result, Err: err})
+		}
+
+		resp, err = c.Send(ctx, ToolResult(call.Name, result))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+// DefaultRetryDelays: hasta 3 reintentos ante 429 — 2s, 4s, 8s
+var DefaultRetryDelays = []time.Duration{2 * time.Second, 4 * time.Second, 8 * time.Second}
+
+type retrying struct {
+	Provider
+	delays []time.Duration
+}
+
+// retryingStreamer conserva el streaming del proveedor envuelto
+type retryingStreamer struct {
+	*retrying
+	streamer Streamer
+}
+
+// WithRetry reintenta las llamadas que fallan con ErrRateLimited, esperando
+// delays entre intentos. Vertex AI retorna 429 sobre todo en el segundo turno del
+// loop de tools, cuando se envía el resultado. Otros errores vuelven de inmediato.
+func WithRetry(p Provider, delays []time.Duration) Provider {
+	r := &retrying{Provider: p, delays: delays}
+	if s, ok := p.(Streamer); ok {
+		return &retryingStreamer{retrying: r, streamer: s}
+	}
+	return r
+}
+
+func (r *retrying) Generate(ctx context.Context, cfg Config, contents []Message) (*Response, error) {
+	return r.do(ctx, nil, func() (*Response, error) {
+		return r.Provider.Generate(ctx, cfg, contents)
+	})
+}
+
+// GenerateStream reintenta solo si todavía no se entregó texto: un 429 a mitad
+// de la respuesta dejaría fragmentos repetidos del lado del cliente.
+func (r *retryingStreamer) GenerateStream(ctx context.Context, cfg Config, contents []Message, onText func(string)) (*Response, error) {
+	streamed := false
+	canRetry := func() bool { return !streamed }
+	return r.do(ctx, canRetry, func() (*Response, error) {
+		return r.streamer.GenerateStream(ctx, cfg, contents, func(s string) {
+			streamed = true
+			onText(s)
+		})
+	})
+}
+
+// do ejecuta call con los reintentos ante 429. canRetry (opcional) puede
+// impedir el reintento.
+func (r *retrying) do(ctx context.Context, canRetry func() bool, call func() (*Response, error)) (*Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := call()
+		if err == nil || !errors.Is(err, ErrRateLimited) || attempt == len(r.delays) || (canRetry != nil && !canRetry()) {
+			return resp, err
+		}
+
+		slog.Warn("llm: límite de solicitudes (429) — reintentando",
+			"attempt", attempt+1,
+			"wait", r.delays[attempt],
+		)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(r.delays[attempt]):
+		}
+	}
+}
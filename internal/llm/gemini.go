@@ -0,0 +1,195 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"cloud.google.com/go/vertexai/genai"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Proyecto y región de Vertex AI de FabricaLaser
+const (
+	GCPProjectID = "div-aloalpizar"
+	GCPLocation  = "us-central1"
+	DefaultModel = "gemini-2.5-flash"
+)
+
+// Gemini implementa Provider con Gemini en Vertex AI
+type Gemini struct {
+	client *genai.Client
+}
+
+// NewGemini crea el cliente de Vertex AI (credenciales por ADC)
+func NewGemini(ctx context.Context, projectID, location string) (*Gemini, error) {
+	client, err := genai.NewClient(ctx, projectID, location)
+	if err != nil {
+		return nil, fmt.Errorf("llm: error creando cliente Vertex AI: %w", err)
+	}
+	return &Gemini{client: client}, nil
+}
+
+// Close libera la conexión con Vertex AI
+func (g *Gemini) Close() error {
+	return g.client.Close()
+}
+
+// Generate implementa Provider. El último mensaje es el turno nuevo; los
+// anteriores van como historial de la sesión.
+func (g *Gemini) Generate(ctx context.Context, cfg Config, contents []Message) (*Response, error) {
+	chat, parts, err := g.session(cfg, contents)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := chat.SendMessage(ctx, parts...)
+	if err != nil {
+		return nil, vertexError(err)
+	}
+	return toResponse(resp), nil
+}
+
+// GenerateStream implementa Streamer: entrega cada fragmento de texto apenas
+// llega y retorna la respuesta combinada, igual a la de Generate.
+func (g *Gemini) GenerateStream(ctx context.Context, cfg Config, contents []Message, onText func(string)) (*Response, error) {
+	chat, parts, err := g.session(cfg, contents)
+	if err != nil {
+		return nil, err
+	}
+	iter := chat.SendMessageStream(ctx, parts...)
+	for {
+		chunk, err := iter.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			return nil, vertexError(err)
+		}
+		if len(chunk.Candidates) == 0 || chunk.Candidates[0].Content == nil {
+			continue
+		}
+		for _, p := range chunk.Candidates[0].Content.Parts {
+			if t, ok := p.(genai.Text); ok && t != "" {
+				onText(string(t))
+			}
+		}
+	}
+	return toResponse(iter.MergedResponse()), nil
+}
+
+// session arma el modelo con cfg y una sesión con todos los mensajes menos el
+// último, que se retorna como las partes a enviar.
+func (g *Gemini) session(cfg Config, contents []Message) (*genai.ChatSession, []genai.Part, error) {
+	if len(contents) == 0 {
+		return nil, nil, fmt.Errorf("llm: conversación vacía")
+	}
+	name := cfg.Model
+	if name == "" {
+		name = DefaultModel
+	}
+	model := g.client.GenerativeModel(name)
+	if cfg.System != "" {
+		model.SystemInstruction = &genai.Content{Parts: []genai.Part{genai.Text(cfg.System)}}
+	}
+	if len(cfg.Tools) > 0 {
+		decls := make([]*genai.FunctionDeclaration, len(cfg.Tools))
+		for i, t := range cfg.Tools {
+			decls[i] = &genai.FunctionDeclaration{Name: t.Name, Description: t.Description, Parameters: toGenaiSchema(t.Parameters)}
+		}
+		model.Tools = []*genai.Tool{{FunctionDeclarations: decls}}
+	}
+	model.Temperature = cfg.Temperature
+	model.TopP = cfg.TopP
+	model.MaxOutputTokens = cfg.MaxOutputTokens
+
+	chat := model.StartChat()
+	last := len(contents) - 1
+	for _, m := range contents[:last] {
+		chat.History = append(chat.History, &genai.Content{Role: m.Role, Parts: toGenaiParts(m.Parts)})
+	}
+	return chat, toGenaiParts(contents[last].Parts), nil
+}
+
+// vertexError traduce la cuota agotada (429) a ErrRateLimited
+func vertexError(err error) error {
+	if st, ok := status.FromError(err); ok && st.Code() == codes.ResourceExhausted {
+		return fmt.Errorf("%w: %v", ErrRateLimited, err)
+	}
+	return err
+}
+
+func toResponse(resp *genai.GenerateContentResponse) *Response {
+	out := &Response{}
+	if resp == nil || len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil {
+		return out
+	}
+	cand := resp.Candidates[0]
+	out.Truncated = cand.FinishReason == genai.FinishReasonMaxTokens
+	for _, p := range cand.Content.Parts {
+		switch v := p.(type) {
+		case genai.Text:
+			out.Parts = append(out.Parts, Text(string(v)))
+		case genai.FunctionCall:
+			out.Parts = append(out.Parts, Call(v.Name, v.Args))
+		}
+	}
+	return out
+}
+
+func toGenaiParts(parts []Part) []genai.Part {
+	out := make([]genai.Part, 0, len(parts))
+	for _, p := range parts {
+		switch {
+		case p.Blob != nil:
+			out = append(out, genai.Blob{MIMEType: p.Blob.MIMEType, Data: p.Blob.Data})
+		case p.Call != nil:
+			out = append(out, genai.FunctionCall{Name: p.Call.Name, Args: p.Call.Args})
+		case p.Response != nil:
+			out = append(out, genai.FunctionResponse{Name: p.Response.Name, Response: plainJSON(p.Response.Result)})
+		default:
+			out = append(out, genai.Text(p.Text))
+		}
+	}
+	return out
+}
+
+func toGenaiSchema(s *Schema) *genai.Schema {
+	if s == nil {
+		return nil
+	}
+	out := &genai.Schema{
+		Type:        genai.Type(s.Type),
+		Description: s.Description,
+		Enum:        s.Enum,
+		Items:       toGenaiSchema(s.Items),
+		Required:    s.Required,
+	}
+	if s.Properties != nil {
+		out.Properties = make(map[string]*genai.Schema, len(s.Properties))
+		for k, v := range s.Properties {
+			out.Properties[k] = toGenaiSchema(v)
+		}
+	}
+	return out
+}
+
+// plainJSON hace round-trip JSON del resultado de una tool: genai.FunctionResponse
+// pasa por structpb, que no acepta slices ni structs concretos ([]map[string]any,
+// []clienteOut...). Después del round-trip todo es map[string]any y []any.
+func plainJSON(v map[string]any) map[string]any {
+	if v == nil {
+		return map[string]any{}
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return map[string]any{"error": "no se pudo serializar resultado: " + err.Error()}
+	}
+	var out map[string]any
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return map[string]any{"error": "no se pudo deserializar resultado: " + err.Error()}
+	}
+	return out
+}
@@ -0,0 +1,201 @@
+// Package llm abstrae el modelo de lenguaje detrás de los agentes: el bot de
+// WhatsApp/Telegram, el chat administrativo, el chat web y los resúmenes.
+//
+// Provider es la única dependencia del modelo: recibe la conversación completa y
+// retorna la respuesta. Encima de eso viven, sin saber qué modelo hay detrás, la
+// sesión de chat con historial (Chat), el registro de tools con el loop de
+// function calling (Registry, Chat.RunTools), los reintentos ante 429
+// (WithRetry) y los resúmenes (Summarize). Los proveedores que además son
+// Streamer entregan el texto a medida que se genera (Chat.OnText), para las
+// respuestas en streaming (SSE). Gemini en Vertex AI es una implementación;
+// Script es un proveedor guionado para tests sin red.
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Roles de los mensajes
+const (
+	RoleUser  = "user"
+	RoleModel = "model"
+)
+
+var (
+	// ErrRateLimited indica que el proveedor rechazó la llamada por cuota (429);
+	// WithRetry la reintenta
+	ErrRateLimited = errors.New("llm: límite de solicitudes del modelo alcanzado")
+	// ErrEmptyResponse indica que el modelo no devolvió texto
+	ErrEmptyResponse = errors.New("llm: respuesta vacía del modelo")
+)
+
+// Provider genera la respuesta del modelo para una conversación
+type Provider interface {
+	Generate(ctx context.Context, cfg Config, contents []Message) (*Response, error)
+}
+
+// Streamer es un Provider que puede entregar el texto de la respuesta por
+// fragmentos mientras se genera. onText recibe cada fragmento nuevo; la
+// respuesta completa se retorna igual que en Generate.
+type Streamer interface {
+	Provider
+	GenerateStream(ctx context.Context, cfg Config, contents []Message, onText func(string)) (*Response, error)
+}
+
+// Stream genera con streaming si p lo soporta; si no, entrega el texto completo
+// en un solo fragmento al terminar.
+func Stream(ctx context.Context, p Provider, cfg Config, contents []Message, onText func(string)) (*Response, error) {
+	if s, ok := p.(Streamer); ok {
+		return s.GenerateStream(ctx, cfg, contents, onText)
+	}
+	resp, err := p.Generate(ctx, cfg, contents)
+	if err == nil {
+		if text := resp.Text(); text != "" {
+			onText(text)
+		}
+	}
+	return resp, err
+}
+
+// Config es la configuración de una llamada. Los punteros en nil usan el
+// default del proveedor.
+type Config struct {
+	Model           string // Vacío: el modelo por defecto del proveedor
+	System          string // System instruction
+	Tools           []Tool
+	Temperature     *float32
+	TopP            *float32
+	MaxOutputTokens *int32
+}
+
+// Message es un turno de la conversación
+type Message struct {
+	Role  string
+	Parts []Part
+}
+
+// Part es un fragmento de un mensaje: texto, imagen, llamada a tool o su resultado.
+// Solo uno de los campos va lleno.
+type Part struct {
+	Text     string
+	Blob     *Blob
+	Call     *FunctionCall
+	Response *FunctionResponse
+}
+
+// Blob es un adjunto binario (imagen)
+type Blob struct {
+	MIMEType string
+	Data     []byte
+}
+
+// FunctionCall es una llamada a tool pedida por el modelo
+type FunctionCall struct {
+	Name string
+	Args map[string]any
+}
+
+// FunctionResponse es el resultado de una tool que se devuelve al modelo
+type FunctionResponse struct {
+	Name   string
+	Result map[string]any
+}
+
+// Text arma una parte de texto
+func Text(s string) Part {
+	return Part{Text: s}
+}
+
+// Image arma una parte con una imagen inline
+func Image(mimeType string, data []byte) Part {
+	return Part{Blob: &Blob{MIMEType: mimeType, Data: data}}
+}
+
+// ToolResult arma la parte con el resultado de una tool
+func ToolResult(name string, result map[string]any) Part {
+	return Part{Response: &FunctionResponse{Name: name, Result: result}}
+}
+
+// Call arma la parte con una llamada a tool (para guiones de prueba)
+func Call(name string, args map[string]any) Part {
+	return Part{Call: &FunctionCall{Name: name, Args: args}}
+}
+
+// UserText arma un turno del cliente con solo texto
+func UserText(s string) Message {
+	return Message{Role: RoleUser, Parts: []Part{Text(s)}}
+}
+
+// ModelText arma un turno del modelo con solo texto
+func ModelText(s string) Message {
+	return Message{Role: RoleModel, Parts: []Part{Text(s)}}
+}
+
+// Response es la respuesta del modelo (el primer candidato)
+type Response struct {
+	Parts     []Part
+	Truncated bool // Se cortó por MaxOutputTokens
+}
+
+// Text concatena las partes de texto de la respuesta
+func (r *Response) Text() string {
+	if r == nil {
+		return ""
+	}
+	var sb strings.Builder
+	for _, p := range r.Parts {
+		sb.WriteString(p.Text)
+	}
+	return sb.String()
+}
+
+// FunctionCall retorna la primera llamada a tool de la respuesta, o nil
+func (r *Response) FunctionCall() *FunctionCall {
+	if r == nil {
+		return nil
+	}
+	for _, p := range r.Parts {
+		if p.Call != nil {
+			return p.Call
+		}
+	}
+	return nil
+}
+
+// Summarize pide al modelo un texto de una sola vuelta (sin historial ni tools)
+// y lo retorna sin espacios sobrantes. ErrEmptyResponse si no hubo texto.
+func Summarize(ctx context.Context, p Provider, cfg Config, prompt string) (string, error) {
+	resp, err := p.Generate(ctx, cfg, []Message{UserText(prompt)})
+	if err != nil {
+		return "", err
+	}
+	text := strings.TrimSpace(resp.Text())
+	if text == "" {
+		return "", ErrEmptyResponse
+	}
+	return text, nil
+}
+
+// Float32 y Int32 arman los punteros de Config
+func Float32(v float32) *float32 { return &v }
+func Int32(v int32) *int32       { return &v }
+
+func (m Message) String() string {
+	var parts []string
+	for _, p := range m.Parts {
+		switch {
+		case p.Call != nil:
+			parts = append(parts, fmt.Sprintf("call:%s", p.Call.Name))
+		case p.Response != nil:
+			parts = append(parts, fmt.Sprintf("result:%s", p.Response.Name))
+		case p.Blob != nil:
+			parts = append(parts, fmt.Sprintf("blob:%s", p.Blob.MIMEType))
+		default:
+			parts = append(parts, p.Text)
+		}
+	}
+	return m.Role + ": " + strings.Join(parts, " | ")
+}
@@ -0,0 +1,167 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChatSendKeepsHistory(t *testing.T) {
+	script := NewScript(Reply("hola"), Fail(errors.New("caído")), Reply("listo"))
+	chat := NewChat(script, Config{System: "sys"}, []Message{UserText("antes"), ModelText("previo")})
+
+	if resp, err := chat.Send(context.Background(), Text("uno")); err != nil || resp.Text() != "hola" {
+		t.Fatalf("send 1 = %v, %v", resp, err)
+	}
+	if len(chat.History) != 4 {
+		t.Fatalf("history = %v", chat.History)
+	}
+	if _, err := chat.Send(context.Background(), Text("dos")); err == nil {
+		t.Fatal("send 2 sin error")
+	}
+	if len(chat.History) != 4 {
+		t.Fatalf("history tras error = %v", chat.History)
+	}
+	if _, err := chat.Send(context.Background(), Text("tres")); err != nil {
+		t.Fatal(err)
+	}
+
+	reqs := script.Requests()
+	if len(reqs) != 3 || reqs[2].Config.System != "sys" {
+		t.Fatalf("requests = %+v", reqs)
+	}
+	// El turno fallido no queda en la conversación del tercer intento
+	if got := fmt.Sprint(reqs[2].Contents); got != "[user: antes model: previo user: uno model: hola user: tres]" {
+		t.Errorf("contents = %s", got)
+	}
+}
+
+func TestRunTools(t *testing.T) {
+	script := NewScript(
+		CallTool("sumar", map[string]any{"a": 2.0, "b": 3.0}),
+		CallTool("romper", nil),
+		Reply("son 5"),
+	)
+	tools := NewRegistry()
+	tools.Register(Tool{Name: "sumar"}, func(_ context.Context, args map[string]any) (map[string]any, error) {
+		return map[string]any{"total": args["a"].(float64) + args["b"].(float64)}, nil
+	})
+	tools.Register(Tool{Name: "romper"}, func(context.Context, map[string]any) (map[string]any, error) {
+		return nil, errors.New("sin datos")
+	})
+
+	chat := NewChat(script, Config{Tools: tools.Tools()}, nil)
+	resp, err := chat.Send(context.Background(), Text("¿2+3?"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var traces []ToolTrace
+	resp, err = chat.RunTools(context.Background(), resp, 5, tools, func(tr ToolTrace) { traces = append(traces, tr) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text() != "son 5" {
+		t.Errorf("text = %q", resp.Text())
+	}
+	if len(traces) != 2 || traces[0].Result["total"] != 5.0 || traces[1].Err == nil {
+		t.Fatalf("traces = %+v", traces)
+	}
+	last := script.Requests()[2].Last()
+	if r := last.Parts[0].Response; r == nil || r.Name != "romper" || r.Result["error"] != "sin datos" {
+		t.Errorf("último turno = %v", last)
+	}
+}
+
+func TestRunToolsStopsAtMaxIter(t *testing.T) {
+	script := NewScript(CallTool("x", nil), CallTool("x", nil), CallTool("x", nil))
+	tools := NewRegistry()
+	calls := 0
+	tools.Register(Tool{Name: "x"}, func(context.Context, map[string]any) (map[string]any, error) {
+		calls++
+		return map[string]any{}, nil
+	})
+	chat := NewChat(script, Config{}, nil)
+	resp, _ := chat.Send(context.Background(), Text("loop"))
+	resp, err := chat.RunTools(context.Background(), resp, 2, tools, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 || resp.FunctionCall() == nil || resp.Text() != "" {
+		t.Errorf("calls = %d, resp = %+v", calls, resp)
+	}
+}
+
+func TestChatStreams(t *testing.T) {
+	script := NewScript(CallTool("buscar", map[string]any{"q": "acrílico"}), Reply("tenemos tres grosores"))
+	tools := NewRegistry()
+	tools.Register(Tool{Name: "buscar"}, func(context.Context, map[string]any) (map[string]any, error) {
+		return map[string]any{"ok": true}, nil
+	})
+
+	// WithRetry conserva el streaming del proveedor
+	chat := NewChat(WithRetry(script, []time.Duration{time.Millisecond}), Config{}, nil)
+	var chunks, started []string
+	chat.OnText = func(s string) { chunks = append(chunks, s) }
+	chat.OnToolStart = func(c FunctionCall) { started = append(started, c.Name) }
+
+	resp, err := chat.Send(context.Background(), Text("¿grosores?"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp, err = chat.RunTools(context.Background(), resp, 3, tools, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(chunks, "|"); got != "tenemos |tres |grosores" || resp.Text() != "tenemos tres grosores" {
+		t.Errorf("chunks = %q, text = %q", chunks, resp.Text())
+	}
+	if len(started) != 1 || started[0] != "buscar" {
+		t.Errorf("started = %v", started)
+	}
+
+	// Un 429 antes del primer fragmento se reintenta
+	script = NewScript(Fail(ErrRateLimited), Reply("ok"))
+	chunks = nil
+	resp, err = Stream(context.Background(), WithRetry(script, []time.Duration{time.Millisecond}), Config{}, []Message{UserText("x")},
+		func(s string) { chunks = append(chunks, s) })
+	if err != nil || resp.Text() != "ok" || len(chunks) != 1 {
+		t.Fatalf("resp = %v, err = %v, chunks = %q", resp, err, chunks)
+	}
+}
+
+func TestWithRetry(t *testing.T) {
+	delays := []time.Duration{time.Millisecond, time.Millisecond}
+
+	script := NewScript(Fail(ErrRateLimited), Fail(fmt.Errorf("%w: 429", ErrRateLimited)), Reply("ok"))
+	resp, err := WithRetry(script, delays).Generate(context.Background(), Config{}, []Message{UserText("hola")})
+	if err != nil || resp.Text() != "ok" || len(script.Requests()) != 3 {
+		t.Fatalf("resp = %v, err = %v, requests = %d", resp, err, len(script.Requests()))
+	}
+
+	// Se agotan los reintentos
+	script = NewScript(Fail(ErrRateLimited), Fail(ErrRateLimited), Fail(ErrRateLimited), Reply("tarde"))
+	if _, err := WithRetry(script, delays).Generate(context.Background(), Config{}, nil); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("err = %v", err)
+	}
+	if script.Pending() != 1 {
+		t.Errorf("pending = %d", script.Pending())
+	}
+
+	// Otros errores no se reintentan
+	script = NewScript(Fail(errors.New("400")), Reply("nunca"))
+	if _, err := WithRetry(script, delays).Generate(context.Background(), Config{}, nil); err == nil || script.Pending() != 1 {
+		t.Fatalf("err = %v, pending = %d", err, script.Pending())
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	script := NewScript(Reply("  resumen \n"), Reply("   "))
+	if got, err := Summarize(context.Background(), script, Config{}, "p"); err != nil || got != "resumen" {
+		t.Fatalf("got %q, %v", got, err)
+	}
+	if _, err := Summarize(context.Background(), script, Config{}, "p"); !errors.Is(err, ErrEmptyResponse) {
+		t.Fatalf("err = %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Script es un Provider guionado para tests: responde cada llamada con el
+// siguiente paso del guion y guarda lo que recibió. Determinista y sin red.
+type Script struct {
+	mu       sync.Mutex
+	steps    []Step
+	requests []Request
+}
+
+// Step es la respuesta (o el error) de una llamada
+type Step struct {
+	Response *Response
+	Err      error
+}
+
+// Request es lo que recibió el proveedor en una llamada
+type Request struct {
+	Config   Config
+	Contents []Message
+}
+
+// Last es el turno nuevo de la llamada
+func (r Request) Last() Message {
+	if len(r.Contents) == 0 {
+		return Message{}
+	}
+	return r.Contents[len(r.Contents)-1]
+}
+
+func NewScript(steps ...Step) *Script {
+	return &Script{steps: steps}
+}
+
+// Reply: el modelo responde con texto
+func Reply(text string) Step {
+	return Step{Response: &Response{Parts: []Part{Text(text)}}}
+}
+
+// CallTool: el modelo pide ejecutar una tool
+func CallTool(name string, args map[string]any) Step {
+	return Step{Response: &Response{Parts: []Part{Call(name, args)}}}
+}
+
+// Fail: la llamada falla con err
+func Fail(err error) Step {
+	return Step{Err: err}
+}
+
+// Generate implementa Provider. Sin pasos pendientes retorna error.
+func (s *Script) Generate(ctx context.Context, cfg Config, contents []Message) (*Response, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, Request{Config: cfg, Contents: append([]Message(nil), contents...)})
+	if len(s.steps) == 0 {
+		return nil, fmt.Errorf("llm: guion agotado en la llamada %d", len(s.requests))
+	}
+	step := s.steps[0]
+	s.steps = s.steps[1:]
+	if step.Err != nil {
+		return nil, step.Err
+	}
+	if step.Response == nil {
+		return &Response{}, nil
+	}
+	return step.Response, nil
+}
+
+// GenerateStream implementa Streamer: entrega el texto del paso palabra por
+// palabra (como llegaría del modelo) y retorna la respuesta completa.
+func (s *Script) GenerateStream(ctx context.Context, cfg Config, contents []Message, onText func(string)) (*Response, error) {
+	resp, err := s.Generate(ctx, cfg, contents)
+	if err != nil {
+		return nil, err
+	}
+	for _, chunk := range strings.SplitAfter(resp.Text(), " ") {
+		if chunk != "" {
+			onText(chunk)
+		}
+	}
+	return resp, nil
+}
+
+// Requests retorna las llamadas recibidas, en orden
+func (s *Script) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// Pending retorna cuántos pasos del guion no se usaron
+func (s *Script) Pending() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.steps)
+}
@@ -0,0 +1,78 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+)
+
+// Type es el tipo de un parámetro de tool (subconjunto de OpenAPI)
+type Type int
+
+const (
+	TypeString Type = iota + 1
+	TypeNumber
+	TypeInteger
+	TypeBoolean
+	TypeArray
+	TypeObject
+)
+
+// Schema describe los parámetros de una tool
+type Schema struct {
+	Type        Type
+	Description string
+	Enum        []string
+	Items       *Schema
+	Properties  map[string]*Schema
+	Required    []string
+}
+
+// Tool es la declaración de una tool que el modelo puede llamar
+type Tool struct {
+	Name        string
+	Description string
+	Parameters  *Schema
+}
+
+// ToolHandler ejecuta una tool con los argumentos que mandó el modelo
+type ToolHandler func(ctx context.Context, args map[string]any) (map[string]any, error)
+
+// Registry agrupa las tools de un agente: sus declaraciones para el modelo y el
+// handler de cada una. Los agentes arman uno por llamada para que los handlers
+// conozcan al cliente o gestor de la conversación.
+type Registry struct {
+	tools    []Tool
+	handlers map[string]ToolHandler
+}
+
+func NewRegistry() *Registry {
+	return &Registry{handlers: map[string]ToolHandler{}}
+}
+
+// Register agrega una tool; una declaración con el mismo nombre la reemplaza
+func (r *Registry) Register(tool Tool, handler ToolHandler) {
+	if _, exists := r.handlers[tool.Name]; exists {
+		for i := range r.tools {
+			if r.tools[i].Name == tool.Name {
+				r.tools[i] = tool
+			}
+		}
+	} else {
+		r.tools = append(r.tools, tool)
+	}
+	r.handlers[tool.Name] = handler
+}
+
+// Tools retorna las declaraciones en orden de registro (para Config.Tools)
+func (r *Registry) Tools() []Tool {
+	return r.tools
+}
+
+// Execute despacha la llamada del modelo a su handler
+func (r *Registry) Execute(ctx context.Context, call FunctionCall) (map[string]any, error) {
+	handler, ok := r.handlers[call.Name]
+	if !ok {
+		return nil, fmt.Errorf("tool desconocida: %s", call.Name)
+	}
+	return handler(ctx, call.Args)
+}
@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/services/apikeys"
+	"github.com/alonsoalpizar/fabricalaser/internal/tenant"
+	"github.com/alonsoalpizar/fabricalaser/internal/utils"
+	chiMiddleware "github.com/go-chi/chi/v5/middleware"
+)
+
+// APIKey autentica los requests de la API pública con la key del header
+// Authorization (Bearer flk_...). La key define el tenant y la cuenta del
+// request (userID), debe tener el scope del endpoint y respetar su límite por
+// minuto. Cada request queda medido en api_key_usage bajo endpoint.
+func APIKey(keys *apikeys.Service, scope, endpoint string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			raw := utils.ExtractTokenFromHeader(r.Header.Get("Authorization"))
+			if raw == "" {
+				respondAuthError(w, http.StatusUnauthorized, "UNAUTHORIZED", "API key requerida (Authorization: Bearer <key>)")
+				return
+			}
+
+			key, err := keys.Authenticate(raw)
+			switch {
+			case errors.Is(err, apikeys.ErrInvalidKey):
+				respondAuthError(w, http.StatusUnauthorized, "INVALID_API_KEY", "API key inválida")
+				return
+			case errors.Is(err, apikeys.ErrExpiredKey):
+				respondAuthError(w, http.StatusUnauthorized, "API_KEY_EXPIRED", "API key vencida o revocada")
+				return
+			case err != nil:
+				log.Printf("[apikeys] error autenticando: %v", err)
+				respondAuthError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "No se pudo verificar la API key")
+				return
+			}
+
+			if !key.HasScope(scope) {
+				keys.Record(key, endpoint, http.StatusForbidden)
+				respondAuthError(w, http.StatusForbidden, "INSUFFICIENT_SCOPE", "La API key no tiene el scope "+scope)
+				return
+			}
+
+			t := tenant.Lookup(key.TenantID)
+			if !t.IsActive {
+				respondAuthError(w, http.StatusNotFound, "TENANT_INACTIVE", "Este sitio no está disponible")
+				return
+			}
+
+			limit := keys.Allow(r.Context(), key)
+			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(limit.Limit))
+			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(limit.Remaining))
+			w.Header().Set("X-RateLimit-Reset", strconv.Itoa(int(limit.Reset.Seconds()+0.5)))
+			if !limit.Allowed {
+				keys.Record(key, endpoint, http.StatusTooManyRequests)
+				w.Header().Set("Retry-After", strconv.Itoa(int(limit.Reset.Seconds()+0.5)))
+				respondAuthError(w, http.StatusTooManyRequests, "RATE_LIMITED", "Límite de requests por minuto alcanzado")
+				return
+			}
+
+			// La key manda sobre el host: el tenant y la cuenta son los del cliente
+			ctx := tenant.WithTenant(r.Context(), t)
+			ctx = context.WithValue(ctx, "userID", key.UserID)
+			ctx = context.WithValue(ctx, "apiKeyID", key.ID)
+
+			ww := chiMiddleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r.WithContext(ctx))
+			keys.Record(key, endpoint, ww.Status())
+		})
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+	"github.com/alonsoalpizar/fabricalaser/internal/tenant"
 	"github.com/alonsoalpizar/fabricalaser/internal/utils"
 )
 
@@ -33,6 +35,12 @@ func AuthMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
+		// Un token vale solo en el taller que lo emitió
+		if !sameTenant(r, claims) {
+			respondAuthError(w, http.StatusUnauthorized, "INVALID_TOKEN", "Token inválido")
+			return
+		}
+
 		// Add user info to context
 		ctx := context.WithValue(r.Context(), "userID", claims.ID)
 		ctx = context.WithValue(ctx, "userCedula", claims.Cedula)
@@ -50,7 +58,7 @@ func AuthOptional(next http.Handler) http.Handler {
 		token := utils.ExtractTokenFromHeader(r.Header.Get("Authorization"))
 		if token != "" {
 			claims, err := utils.ValidateToken(token)
-			if err == nil {
+			if err == nil && sameTenant(r, claims) {
 				ctx := context.WithValue(r.Context(), "userID", claims.ID)
 				ctx = context.WithValue(ctx, "userCedula", claims.Cedula)
 				ctx = context.WithValue(ctx, "userName", claims.Nombre)
@@ -63,6 +71,15 @@ func AuthOptional(next http.Handler) http.Handler {
 	})
 }
 
+// sameTenant indica si el token es del tenant del request
+func sameTenant(r *http.Request, claims *utils.TokenClaims) bool {
+	tokenTenant := claims.Tenant
+	if tokenTenant == 0 {
+		tokenTenant = models.DefaultTenantID
+	}
+	return tokenTenant == tenant.ID(r.Context())
+}
+
 func respondAuthError(w http.ResponseWriter, status int, code, message string) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
@@ -15,7 +15,7 @@ func QuotaMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		userRepo := repository.NewUserRepository()
+		userRepo := repository.NewUserRepository().WithContext(r.Context())
 		user, err := userRepo.FindByID(userID.(uint))
 		if err != nil {
 			respondAuthError(w, http.StatusUnauthorized, "USER_NOT_FOUND", "Usuario no encontrado")
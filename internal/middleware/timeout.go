@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	chiMiddleware "github.com/go-chi/chi/v5/middleware"
+)
+
+// Timeout corta los requests que pasan de d (chi Timeout: cancela el contexto
+// y responde 504), salvo los streams SSE de streamPaths. Un stream ya respondió
+// 200 cuando vence el plazo, así que el corte solo lo dejaría mudo: cada handler
+// de streaming tiene su propio plazo y lo informa con un evento error antes de
+// cerrar.
+func Timeout(d time.Duration, streamPaths ...string) func(http.Handler) http.Handler {
+	streams := make(map[string]bool, len(streamPaths))
+	for _, p := range streamPaths {
+		streams[p] = true
+	}
+	timeout := chiMiddleware.Timeout(d)
+	return func(next http.Handler) http.Handler {
+		limited := timeout(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if streams[r.URL.Path] {
+				next.ServeHTTP(w, r)
+				return
+			}
+			limited.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package models
+
+import "time"
+
+// Tipos de bot_events
+const (
+	BotEventToolCall  = "tool_call"  // El agente ejecutó una tool
+	BotEventRateLimit = "rate_limit" // El límite diario rechazó una conversación nueva
+)
+
+// Tipos de tema mencionado por los clientes (conversation_topics_daily.kind)
+const (
+	TopicMaterial = "material"
+	TopicBlank    = "blank"
+)
+
+// BotEvent es un hecho del bot que no queda en el archivo de mensajes
+type BotEvent struct {
+	ID              uint      `gorm:"primaryKey" json:"id"`
+	TenantID        uint      `gorm:"not null;default:1" json:"-"`
+	Kind            string    `gorm:"type:varchar(20);not null" json:"kind"`
+	Channel         string    `gorm:"type:varchar(20);not null" json:"channel"`
+	ConversationKey string    `gorm:"type:varchar(40);not null" json:"conversation_key"` // = whatsapp_conversations.phone
+	Name            *string   `gorm:"type:varchar(60)" json:"name,omitempty"`
+	OK              bool      `gorm:"column:ok;not null" json:"ok"` // Sin default en el tag: GORM omitiría el false
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+func (BotEvent) TableName() string {
+	return "bot_events"
+}
+
+// ConversationStatsDaily es el resumen de un día (hora Costa Rica) de un canal
+type ConversationStatsDaily struct {
+	TenantID        uint      `gorm:"primaryKey;default:1" json:"-"`
+	Day             time.Time `gorm:"type:date;primaryKey" json:"day"`
+	Channel         string    `gorm:"type:varchar(20);primaryKey" json:"channel"`
+	Messages        int       `json:"messages"`
+	UserMessages    int       `json:"user_messages"`
+	Contacts        int       `json:"contacts"`
+	Responses       int       `json:"responses"`
+	AvgResponseSecs float64   `json:"avg_response_secs"`
+	P90ResponseSecs float64   `gorm:"column:p90_response_secs" json:"p90_response_secs"`
+	Escalations     int       `json:"escalations"`
+	LimitHits       int       `json:"limit_hits"`
+	ToolCalls       int       `json:"tool_calls"`
+	RolledUpAt      time.Time `json:"rolled_up_at"`
+}
+
+func (ConversationStatsDaily) TableName() string {
+	return "conversation_stats_daily"
+}
+
+// ConversationToolDaily cuenta las llamadas de una tool en un día
+type ConversationToolDaily struct {
+	TenantID uint      `gorm:"primaryKey;default:1" json:"-"`
+	Day      time.Time `gorm:"type:date;primaryKey" json:"day"`
+	Channel  string    `gorm:"type:varchar(20);primaryKey" json:"channel"`
+	Tool     string    `gorm:"type:varchar(60);primaryKey" json:"tool"`
+	Calls    int       `json:"calls"`
+	Failures int       `json:"failures"`
+}
+
+func (ConversationToolDaily) TableName() string {
+	return "conversation_tool_daily"
+}
+
+// ConversationHourly cuenta los mensajes de clientes de una hora del día
+type ConversationHourly struct {
+	TenantID uint      `gorm:"primaryKey;default:1" json:"-"`
+	Day      time.Time `gorm:"type:date;primaryKey" json:"day"`
+	Channel  string    `gorm:"type:varchar(20);primaryKey" json:"channel"`
+	Hour     int       `gorm:"primaryKey" json:"hour"`
+	Messages int       `json:"messages"`
+}
+
+func (ConversationHourly) TableName() string {
+	return "conversation_hourly"
+}
+
+// ConversationTopicDaily cuenta las menciones de un material o blank en un día
+type ConversationTopicDaily struct {
+	TenantID uint      `gorm:"primaryKey;default:1" json:"-"`
+	Day      time.Time `gorm:"type:date;primaryKey" json:"day"`
+	Channel  string    `gorm:"type:varchar(20);primaryKey" json:"channel"`
+	Kind     string    `gorm:"type:varchar(20);primaryKey" json:"kind"`
+	RefID    uint      `gorm:"primaryKey" json:"ref_id"`
+	Name     string    `gorm:"type:varchar(160);not null" json:"name"`
+	Mentions int       `json:"mentions"`
+	Contacts int       `json:"contacts"`
+}
+
+func (ConversationTopicDaily) TableName() string {
+	return "conversation_topics_daily"
+}
+
+// ToolUsage son las llamadas de una tool en un rango de días
+type ToolUsage struct {
+	Tool     string `json:"tool"`
+	Calls    int64  `json:"calls"`
+	Failures int64  `json:"failures"`
+}
+
+// HourUsage son los mensajes de clientes por día de la semana (1 = lunes) y hora
+type HourUsage struct {
+	Weekday  int   `json:"weekday"`
+	Hour     int   `json:"hour"`
+	Messages int64 `json:"messages"`
+}
+
+// TopicUsage son las menciones de un material o blank en un rango de días.
+// Contacts suma los contactos de cada día: alguien que pregunta dos días cuenta dos veces.
+type TopicUsage struct {
+	Kind     string `json:"kind"`
+	RefID    uint   `json:"ref_id"`
+	Name     string `json:"name"`
+	Mentions int64  `json:"mentions"`
+	Contacts int64  `json:"contacts"`
+}
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// Scopes de las API keys de /api/public/v1
+const (
+	ScopeEstimate    = "estimate"     // Precio de referencia por medidas
+	ScopeAnalyze     = "analyze"      // Análisis de SVG
+	ScopeCreateQuote = "create-quote" // Cotización guardada a nombre del cliente
+	ScopeCatalogRead = "catalog-read" // Tecnologías, materiales y tipos de grabado
+)
+
+// APIScopes son los scopes válidos, en el orden en que se muestran
+var APIScopes = []string{ScopeEstimate, ScopeAnalyze, ScopeCreateQuote, ScopeCatalogRead}
+
+// IsValidScope indica si s es un scope conocido
+func IsValidScope(s string) bool {
+	for _, v := range APIScopes {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// APIKey es la credencial de un cliente B2B para la API pública. De la key solo
+// se guarda el hash; Prefix es la parte visible para identificarla.
+type APIKey struct {
+	ID                 uint           `gorm:"primaryKey" json:"id"`
+	TenantID           uint           `gorm:"not null;default:1" json:"-"`
+	UserID             uint           `gorm:"not null" json:"user_id"` // Cuenta dueña de análisis y cotizaciones
+	Name               string         `gorm:"type:varchar(120);not null" json:"name"`
+	Prefix             string         `gorm:"type:varchar(20);not null;uniqueIndex" json:"prefix"`
+	KeyHash            string         `gorm:"type:varchar(64);not null" json:"-"`
+	Scopes             datatypes.JSON `gorm:"type:jsonb;not null;default:'[]'" json:"scopes"` // ["estimate", "catalog-read"]
+	RateLimitPerMinute int            `gorm:"not null;default:60" json:"rate_limit_per_minute"`
+	IsActive           bool           `gorm:"not null;default:true" json:"is_active"`
+	ExpiresAt          *time.Time     `json:"expires_at,omitempty"`
+	LastUsedAt         *time.Time     `json:"last_used_at,omitempty"`
+	RotatedFromID      *uint          `json:"rotated_from_id,omitempty"`
+	CreatedBy          *uint          `json:"created_by,omitempty"`
+	CreatedAt          time.Time      `json:"created_at"`
+	UpdatedAt          time.Time      `json:"updated_at"`
+
+	// Relations
+	User *User `gorm:"foreignKey:UserID" json:"user,omitempty"`
+}
+
+func (APIKey) TableName() string {
+	return "api_keys"
+}
+
+// ScopeList retorna los scopes de la key
+func (k *APIKey) ScopeList() []string {
+	var scopes []string
+	_ = json.Unmarshal(k.Scopes, &scopes)
+	return scopes
+}
+
+// HasScope indica si la key permite el scope
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.ScopeList() {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Usable indica si la key está activa y no venció en now
+func (k *APIKey) Usable(now time.Time) bool {
+	return k.IsActive && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
+
+// APIKeyUsage es el uso de una key en un día y endpoint
+type APIKeyUsage struct {
+	APIKeyID uint      `gorm:"primaryKey" json:"api_key_id"`
+	Day      time.Time `gorm:"primaryKey;type:date" json:"day"`
+	Endpoint string    `gorm:"primaryKey;type:varchar(60)" json:"endpoint"`
+	Requests int       `gorm:"not null;default:0" json:"requests"`
+	Errors   int       `gorm:"not null;default:0" json:"errors"` // Respuestas 4xx y 5xx
+}
+
+func (APIKeyUsage) TableName() string {
+	return "api_key_usage"
+}
@@ -10,6 +10,7 @@ import (
 // (llaveros, medallas, etc.) que se vende con grabado láser personalizado.
 type Blank struct {
 	ID          uint           `json:"id"           gorm:"primaryKey"`
+	TenantID    uint           `json:"-" gorm:"not null;default:1"`
 	Name        string         `json:"name"         gorm:"not null"`
 	Category    string         `json:"category"     gorm:"not null"`
 	Description string         `json:"description"`
@@ -38,6 +39,15 @@ type Blank struct {
 	// Formato: array de strings. Ejemplo: ["acrílicos redondos", "discos"]
 	Aliases datatypes.JSON `json:"aliases" gorm:"column:aliases;type:jsonb;default:'[]'"`
 
+	// Grabado: material, grosor y tecnología con que el configurador cotiza el
+	// grabado. Sin MaterialID el blank se vende sin grabado.
+	MaterialID        *uint   `json:"material_id"          gorm:"column:material_id"`
+	TechnologyID      *uint   `json:"technology_id"        gorm:"column:technology_id"`
+	Thickness         float64 `json:"thickness"            gorm:"column:thickness;not null;default:0"`
+	EngraveMaxAltoCM  float64 `json:"engrave_max_alto_cm"  gorm:"column:engrave_max_alto_cm;not null;default:0"`  // 0 = sin límite
+	EngraveMaxAnchoCM float64 `json:"engrave_max_ancho_cm" gorm:"column:engrave_max_ancho_cm;not null;default:0"` // 0 = sin límite
+	MaxSides          int     `json:"max_sides"            gorm:"column:max_sides;not null;default:1"`            // Caras grabables (1 o 2)
+
 	StockQty   int  `json:"stock_qty"   gorm:"column:stock_qty;not null;default:0"`
 	StockAlert int  `json:"stock_alert" gorm:"column:stock_alert;not null;default:10"`
 	IsFeatured bool `json:"is_featured" gorm:"column:is_featured;default:false"`
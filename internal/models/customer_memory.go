@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// CustomerMemory es la memoria de largo plazo de un contacto del bot: lo que
+// quedó de sus sesiones anteriores, resumido por el job de memoria. Se suma a
+// DATOS DEL CLIENTE en el próximo contacto.
+type CustomerMemory struct {
+	ID              uint   `gorm:"primaryKey" json:"id"`
+	TenantID        uint   `gorm:"not null;default:1" json:"-"`
+	ConversationKey string `gorm:"type:varchar(40);not null" json:"conversation_key"` // = whatsapp_conversations.phone; única por tenant
+	Channel         string `gorm:"type:varchar(20);not null" json:"channel"`
+	Summary         string `gorm:"type:text;not null;default:''" json:"summary"` // Quién es y qué hace (negocio, uso)
+
+	// Listas de strings. Formato: ["prefiere acrílico transparente", ...]
+	Preferences      datatypes.JSON `gorm:"type:jsonb;not null;default:'[]'" json:"preferences"`
+	PastProducts     datatypes.JSON `gorm:"type:jsonb;not null;default:'[]'" json:"past_products"`
+	TypicalMaterials datatypes.JSON `gorm:"type:jsonb;not null;default:'[]'" json:"typical_materials"`
+	OpenQuestions    datatypes.JSON `gorm:"type:jsonb;not null;default:'[]'" json:"open_questions"`
+
+	LastSession       string     `gorm:"type:text;not null;default:''" json:"last_session"` // Resumen de la última sesión
+	Sessions          int        `gorm:"not null;default:0" json:"sessions"`
+	SummarizedThrough time.Time  `gorm:"not null" json:"summarized_through"`
+	OptedOut          bool       `gorm:"not null;default:false" json:"opted_out"`
+	EditedBy          *uint      `json:"edited_by,omitempty"`
+	EditedAt          *time.Time `json:"edited_at,omitempty"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+}
+
+func (CustomerMemory) TableName() string {
+	return "customer_memories"
+}
+
+// Empty indica si la memoria no tiene nada que contarle al agente
+func (m *CustomerMemory) Empty() bool {
+	isEmptyList := func(j datatypes.JSON) bool {
+		s := string(j)
+		return s == "" || s == "[]" || s == "null"
+	}
+	return m.Summary == "" && m.LastSession == "" &&
+		isEmptyList(m.Preferences) && isEmptyList(m.PastProducts) &&
+		isEmptyList(m.TypicalMaterials) && isEmptyList(m.OpenQuestions)
+}
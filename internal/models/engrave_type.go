@@ -6,6 +6,7 @@ import (
 
 type EngraveType struct {
 	ID              uint    `gorm:"primaryKey" json:"id"`
+	TenantID        uint    `gorm:"not null;default:1" json:"-"`
 	Name            string  `gorm:"type:varchar(50);not null" json:"name"` // vectorial, rasterizado, fotograbado, 3d_relieve
 	Factor          float64 `gorm:"type:decimal(5,4);default:1.0" json:"factor"` // 1.0 - 3.0
 	SpeedMultiplier float64 `gorm:"type:decimal(5,4);default:1.0" json:"speed_multiplier"` // Relative speed
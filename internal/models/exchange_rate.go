@@ -0,0 +1,85 @@
+package models
+
+import (
+	"math"
+	"strings"
+	"time"
+)
+
+// Monedas soportadas por el cotizador
+const (
+	CurrencyCRC = "CRC"
+	CurrencyUSD = "USD"
+)
+
+// Fuentes de un tipo de cambio
+const (
+	ExchangeRateSourceManual = "manual"
+	ExchangeRateSourceCSV    = "csv"
+	ExchangeRateSourceBCCR   = "bccr"
+)
+
+// ExchangeRate guarda el tipo de cambio de un día: 1 BaseCurrency = N QuoteCurrency.
+// Hoy solo se usa USD→CRC, pero el par queda explícito.
+type ExchangeRate struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	RateDate      time.Time `gorm:"type:date;not null;uniqueIndex:idx_exchange_rates_pair" json:"rate_date"`
+	BaseCurrency  string    `gorm:"type:varchar(3);not null;default:'USD';uniqueIndex:idx_exchange_rates_pair" json:"base_currency"`
+	QuoteCurrency string    `gorm:"type:varchar(3);not null;default:'CRC';uniqueIndex:idx_exchange_rates_pair" json:"quote_currency"`
+	BuyRate       float64   `gorm:"type:decimal(12,4);not null" json:"buy_rate"`  // Compra
+	SellRate      float64   `gorm:"type:decimal(12,4);not null" json:"sell_rate"` // Venta — usado por el cotizador
+	Source        string    `gorm:"type:varchar(20);not null;default:'manual'" json:"source"`
+	Notes         *string   `gorm:"type:text" json:"notes,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+func (ExchangeRate) TableName() string {
+	return "exchange_rates"
+}
+
+// Rate returns the rate used for conversions (sell, falling back to buy)
+func (e *ExchangeRate) Rate() float64 {
+	if e.SellRate > 0 {
+		return e.SellRate
+	}
+	return e.BuyRate
+}
+
+// NormalizeCurrency uppercases a currency code; empty or unknown codes map to CRC
+func NormalizeCurrency(code string) string {
+	switch strings.ToUpper(strings.TrimSpace(code)) {
+	case CurrencyUSD:
+		return CurrencyUSD
+	default:
+		return CurrencyCRC
+	}
+}
+
+// IsSupportedCurrency returns true for the currencies the quoter can present
+func IsSupportedCurrency(code string) bool {
+	c := strings.ToUpper(strings.TrimSpace(code))
+	return c == CurrencyCRC || c == CurrencyUSD
+}
+
+// ConvertCurrency converts amount between CRC and USD using crcPerUSD.
+// Same currency, or a non-positive rate, returns the amount unchanged.
+func ConvertCurrency(amount float64, from, to string, crcPerUSD float64) float64 {
+	from, to = NormalizeCurrency(from), NormalizeCurrency(to)
+	if from == to || crcPerUSD <= 0 {
+		return amount
+	}
+	if from == CurrencyUSD {
+		return amount * crcPerUSD
+	}
+	return amount / crcPerUSD
+}
+
+// RoundCurrency rounds to the display precision of the currency
+// (colones sin decimales, dólares a 2 decimales)
+func RoundCurrency(amount float64, currency string) float64 {
+	if NormalizeCurrency(currency) == CurrencyUSD {
+		return math.Round(amount*100) / 100
+	}
+	return math.Round(amount)
+}
@@ -0,0 +1,52 @@
+package models
+
+import "time"
+
+// HandoffStatus es el estado de atención de una conversación de mensajería.
+// "bot" no se persiste: es la ausencia de un handoff abierto.
+type HandoffStatus string
+
+const (
+	HandoffBot      HandoffStatus = "bot"
+	HandoffPending  HandoffStatus = "pending_human" // Escalada, esperando que un admin la tome
+	HandoffHuman    HandoffStatus = "human"         // Un admin la tomó: el bot no responde
+	HandoffResolved HandoffStatus = "resolved"
+)
+
+// Motivos de cierre
+const (
+	HandoffResolvedByAdmin = "admin"
+	HandoffResolvedTimeout = "timeout"
+)
+
+// Handoff es el traspaso de una conversación (WhatsApp, Telegram) del bot a un asesor
+type Handoff struct {
+	ID              uint          `gorm:"primaryKey" json:"id"`
+	Channel         string        `gorm:"type:varchar(20);not null" json:"channel"`
+	ConversationKey string        `gorm:"type:varchar(40);not null" json:"conversation_key"` // = whatsapp_conversations.phone
+	ContactID       string        `gorm:"type:varchar(40);not null" json:"contact_id"`
+	ContactName     *string       `gorm:"type:varchar(160)" json:"contact_name,omitempty"`
+	ContactUsername *string       `gorm:"type:varchar(80)" json:"contact_username,omitempty"`
+	Phone           *string       `gorm:"type:varchar(20)" json:"phone,omitempty"`
+	Summary         *string       `gorm:"type:text" json:"summary,omitempty"`
+	Status          HandoffStatus `gorm:"type:varchar(20);not null;default:'pending_human'" json:"status"`
+	ClaimedBy       *uint         `json:"claimed_by,omitempty"`
+	ClaimedAt       *time.Time    `json:"claimed_at,omitempty"`
+	LastActivityAt  time.Time     `gorm:"not null" json:"last_activity_at"`
+	ResolvedAt      *time.Time    `json:"resolved_at,omitempty"`
+	ResolvedReason  *string       `gorm:"type:varchar(20)" json:"resolved_reason,omitempty"`
+	CreatedAt       time.Time     `json:"created_at"`
+	UpdatedAt       time.Time     `json:"updated_at"`
+
+	// Relations
+	Admin *User `gorm:"foreignKey:ClaimedBy" json:"admin,omitempty"`
+}
+
+func (Handoff) TableName() string {
+	return "conversation_handoffs"
+}
+
+// IsOpen indica si el bot debe quedarse callado en la conversación
+func (h *Handoff) IsOpen() bool {
+	return h.Status == HandoffPending || h.Status == HandoffHuman
+}
@@ -0,0 +1,71 @@
+package models
+
+import "time"
+
+// Tipos de comprobante electrónico (Hacienda v4.4)
+const (
+	InvoiceDocFactura = "01" // Factura electrónica (receptor identificado)
+	InvoiceDocTiquete = "04" // Tiquete electrónico (consumidor final)
+)
+
+// InvoiceStatus represents the reception state of an electronic invoice
+type InvoiceStatus string
+
+const (
+	InvoiceStatusSigned   InvoiceStatus = "signed"   // Firmada, pendiente de envío
+	InvoiceStatusSent     InvoiceStatus = "sent"     // Recibida por Hacienda, en proceso
+	InvoiceStatusAccepted InvoiceStatus = "accepted" // Aceptada por Hacienda
+	InvoiceStatusRejected InvoiceStatus = "rejected" // Rechazada por Hacienda
+	InvoiceStatusError    InvoiceStatus = "error"    // Error de comunicación, se puede reintentar
+)
+
+// Invoice is an electronic invoice issued from a converted quote
+type Invoice struct {
+	ID               uint          `gorm:"primaryKey" json:"id"`
+	QuoteID          uint          `gorm:"not null;index" json:"quote_id"`
+	UserID           uint          `gorm:"not null" json:"user_id"`
+	DocType          string        `gorm:"type:varchar(2);not null" json:"doc_type"`
+	Clave            string        `gorm:"type:varchar(50);not null;uniqueIndex" json:"clave"`
+	Consecutivo      string        `gorm:"type:varchar(20);not null;uniqueIndex" json:"consecutivo"`
+	IssuedAt         time.Time     `gorm:"not null" json:"issued_at"`
+	Currency         string        `gorm:"type:varchar(3);not null;default:'CRC'" json:"currency"`
+	ExchangeRate     float64       `gorm:"type:decimal(12,4);not null;default:1" json:"exchange_rate"`
+	TotalVenta       float64       `gorm:"type:decimal(18,5);not null;default:0" json:"total_venta"`
+	TotalImpuesto    float64       `gorm:"type:decimal(18,5);not null;default:0" json:"total_impuesto"`
+	TotalComprobante float64       `gorm:"type:decimal(18,5);not null;default:0" json:"total_comprobante"`
+	Status           InvoiceStatus `gorm:"type:varchar(20);not null;default:'signed'" json:"status"`
+	XMLSigned        string        `gorm:"column:xml_signed;type:text;not null" json:"-"`
+	HaciendaStatus   *string       `gorm:"type:varchar(30)" json:"hacienda_status,omitempty"`
+	HaciendaResponse *string       `gorm:"type:text" json:"hacienda_response,omitempty"`
+	LastError        *string       `gorm:"type:text" json:"last_error,omitempty"`
+	SentAt           *time.Time    `json:"sent_at,omitempty"`
+	CreatedAt        time.Time     `json:"created_at"`
+	UpdatedAt        time.Time     `json:"updated_at"`
+
+	// Relations
+	Quote *Quote `gorm:"foreignKey:QuoteID" json:"-"`
+	User  *User  `gorm:"foreignKey:UserID" json:"-"`
+}
+
+func (Invoice) TableName() string {
+	return "invoices"
+}
+
+// IsFinal returns true when Hacienda already accepted or rejected the invoice
+func (i *Invoice) IsFinal() bool {
+	return i.Status == InvoiceStatusAccepted || i.Status == InvoiceStatusRejected
+}
+
+// InvoiceSequence tracks the last consecutive number per branch/terminal/doc type
+type InvoiceSequence struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	Sucursal   int       `gorm:"not null;default:1;uniqueIndex:idx_invoice_sequences_key" json:"sucursal"`
+	Terminal   int       `gorm:"not null;default:1;uniqueIndex:idx_invoice_sequences_key" json:"terminal"`
+	DocType    string    `gorm:"type:varchar(2);not null;uniqueIndex:idx_invoice_sequences_key" json:"doc_type"`
+	LastNumber int64     `gorm:"not null;default:0" json:"last_number"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+func (InvoiceSequence) TableName() string {
+	return "invoice_sequences"
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// Audiencias de un artículo de la base de conocimiento
+const (
+	KBAudiencePublic   = "public"   // Todos los agentes (WhatsApp, Telegram, chat web, chat admin)
+	KBAudienceInternal = "internal" // Solo el chat admin
+)
+
+// KBArticle es un artículo de la base de conocimiento que los agentes consultan
+// con buscar_conocimiento. El contenido se parte en pasajes por encabezado
+// markdown (#) y cada pasaje se cita como "KB-<id> › <sección>".
+type KBArticle struct {
+	ID       uint   `gorm:"primaryKey" json:"id"`
+	Title    string `gorm:"type:varchar(160);not null" json:"title"`
+	Category string `gorm:"type:varchar(40);not null;default:'faq'" json:"category"`
+	Content  string `gorm:"type:text;not null" json:"content"`
+
+	// Tags: sinónimos y palabras clave que se suman al índice.
+	// Formato: array de strings. Ejemplo: ["correos", "encomienda"]
+	Tags datatypes.JSON `gorm:"type:jsonb;not null;default:'[]'" json:"tags"`
+
+	Audience  string    `gorm:"type:varchar(20);not null;default:'public'" json:"audience"`
+	IsActive  bool      `gorm:"not null;default:true" json:"is_active"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (KBArticle) TableName() string {
+	return "kb_articles"
+}
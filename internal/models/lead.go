@@ -0,0 +1,92 @@
+package models
+
+import "time"
+
+// LeadStage es la etapa del embudo de un contacto de mensajería. Solo avanza.
+type LeadStage string
+
+const (
+	LeadNew       LeadStage = "new"       // Primer contacto
+	LeadQuoted    LeadStage = "quoted"    // El bot le dio un precio
+	LeadHot       LeadStage = "hot"       // Escalado a un asesor
+	LeadConverted LeadStage = "converted" // Una cotización de su cuenta pasó a pedido
+)
+
+// leadStageOrder ordena las etapas del embudo
+var leadStageOrder = map[LeadStage]int{
+	LeadNew:       0,
+	LeadQuoted:    1,
+	LeadHot:       2,
+	LeadConverted: 3,
+}
+
+// Before indica si la etapa está antes que other en el embudo
+func (s LeadStage) Before(other LeadStage) bool {
+	return leadStageOrder[s] < leadStageOrder[other]
+}
+
+// Lead es un contacto de WhatsApp o Telegram seguido a lo largo del embudo
+type Lead struct {
+	ID              uint       `gorm:"primaryKey" json:"id"`
+	Channel         string     `gorm:"type:varchar(20);not null" json:"channel"`
+	ConversationKey string     `gorm:"type:varchar(40);not null;uniqueIndex" json:"conversation_key"` // = whatsapp_conversations.phone
+	ContactID       string     `gorm:"type:varchar(40);not null" json:"contact_id"`
+	ContactName     *string    `gorm:"type:varchar(160)" json:"contact_name,omitempty"`
+	ContactUsername *string    `gorm:"type:varchar(80)" json:"contact_username,omitempty"`
+	Phone           *string    `gorm:"type:varchar(20)" json:"phone,omitempty"`
+	UserID          *uint      `json:"user_id,omitempty"`
+	Stage           LeadStage  `gorm:"type:varchar(20);not null;default:'new'" json:"stage"`
+	MessageCount    int        `gorm:"not null;default:0" json:"message_count"`
+	FirstContactAt  time.Time  `gorm:"not null" json:"first_contact_at"`
+	LastContactAt   time.Time  `gorm:"not null" json:"last_contact_at"`
+	QuotedAt        *time.Time `json:"quoted_at,omitempty"`
+	EscalatedAt     *time.Time `json:"escalated_at,omitempty"`
+	ConvertedAt     *time.Time `json:"converted_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+
+	// Relations
+	User   *User   `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	Quotes []Quote `gorm:"many2many:lead_quotes;joinForeignKey:LeadID;joinReferences:QuoteID" json:"quotes,omitempty"`
+	Drafts []Quote `gorm:"foreignKey:LeadID" json:"drafts,omitempty"` // Borradores armados por el bot
+}
+
+func (Lead) TableName() string {
+	return "leads"
+}
+
+// Advance registra el hito de stage (la primera vez) y mueve el lead si stage está
+// más adelante en el embudo. Los hitos se fechan aunque la etapa no cambie: un lead
+// escalado que después pide precio cuenta como cotizado. Retorna si hubo cambios.
+func (l *Lead) Advance(stage LeadStage, at time.Time) bool {
+	changed := false
+	var milestone **time.Time
+	switch stage {
+	case LeadQuoted:
+		milestone = &l.QuotedAt
+	case LeadHot:
+		milestone = &l.EscalatedAt
+	case LeadConverted:
+		milestone = &l.ConvertedAt
+	}
+	if milestone != nil && *milestone == nil {
+		*milestone = &at
+		changed = true
+	}
+	if l.Stage.Before(stage) {
+		l.Stage = stage
+		changed = true
+	}
+	return changed
+}
+
+// LeadFunnelRow es una fila del reporte de embudo: contactos de la semana y canal
+// y cuántos llegaron a cada hito
+type LeadFunnelRow struct {
+	Week      time.Time `json:"week"`
+	Channel   string    `json:"channel"`
+	Contacts  int64     `json:"contacts"`
+	Quoted    int64     `json:"quoted"`
+	Escalated int64     `json:"escalated"`
+	Converted int64     `json:"converted"`
+}
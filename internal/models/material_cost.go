@@ -6,6 +6,7 @@ import (
 
 type MaterialCost struct {
 	ID            uint      `gorm:"primaryKey" json:"id"`
+	TenantID      uint      `gorm:"not null;default:1" json:"-"`
 	MaterialID    uint      `gorm:"not null;index" json:"material_id"`
 	Thickness     float64   `gorm:"type:decimal(5,2);not null" json:"thickness"`
 	CostPerMm2    float64   `gorm:"column:cost_per_mm2;type:decimal(12,8);not null" json:"cost_per_mm2"`
@@ -13,6 +14,7 @@ type MaterialCost struct {
 	SheetCost     *float64  `gorm:"column:sheet_cost;type:decimal(10,2)" json:"sheet_cost,omitempty"`
 	SheetWidthMm  *float64  `gorm:"column:sheet_width_mm;type:decimal(8,2)" json:"sheet_width_mm,omitempty"`
 	SheetHeightMm *float64  `gorm:"column:sheet_height_mm;type:decimal(8,2)" json:"sheet_height_mm,omitempty"`
+	Currency      string    `gorm:"type:varchar(3);not null;default:'CRC'" json:"currency"` // Moneda de cost_per_mm2 y sheet_cost
 	Notes         *string   `gorm:"type:text" json:"notes,omitempty"`
 	IsActive      bool      `gorm:"default:true" json:"is_active"`
 	CreatedAt     time.Time `json:"created_at"`
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// Canales de notificación (users.notif_channel)
+const (
+	NotifChannelEmail    = "email"
+	NotifChannelWhatsApp = "whatsapp"
+	NotifChannelTelegram = "telegram"
+)
+
+// NotificationStatus represents the state of a notification delivery
+type NotificationStatus string
+
+const (
+	NotificationPending NotificationStatus = "pending" // Por enviar o esperando reintento
+	NotificationSent    NotificationStatus = "sent"
+	NotificationFailed  NotificationStatus = "failed"  // Se agotaron los reintentos
+	NotificationSkipped NotificationStatus = "skipped" // El cliente no quiere avisos
+)
+
+// NotificationDelivery is one proactive notice to a customer through one channel.
+// Lleva todo lo necesario para reintentar el envío sin volver a armar el mensaje.
+type NotificationDelivery struct {
+	ID            uint               `gorm:"primaryKey" json:"id"`
+	TenantID      uint               `gorm:"not null;default:1" json:"-"`
+	EventType     string             `gorm:"type:varchar(40);not null" json:"event_type"`
+	UserID        uint               `gorm:"not null;index" json:"user_id"`
+	QuoteID       *uint              `json:"quote_id,omitempty"`
+	Channel       string             `gorm:"type:varchar(20);not null" json:"channel"`
+	Recipient     string             `gorm:"type:varchar(255);not null" json:"recipient"` // email, número E.164 o chat ID
+	Subject       string             `gorm:"type:varchar(200);not null" json:"subject"`
+	Body          string             `gorm:"type:text;not null" json:"body"`
+	Link          *string            `gorm:"type:text" json:"link,omitempty"`
+	Template      *string            `gorm:"type:varchar(60)" json:"template,omitempty"` // Plantilla de WhatsApp
+	Params        datatypes.JSON     `gorm:"type:jsonb;default:'[]'" json:"params"`      // Variables de la plantilla
+	Status        NotificationStatus `gorm:"type:varchar(20);not null;default:'pending'" json:"status"`
+	Attempts      int                `gorm:"not null;default:0" json:"attempts"`
+	LastError     *string            `gorm:"type:text" json:"last_error,omitempty"`
+	NextAttemptAt *time.Time         `json:"next_attempt_at,omitempty"`
+	SentAt        *time.Time         `json:"sent_at,omitempty"`
+	CreatedAt     time.Time          `json:"created_at"`
+	UpdatedAt     time.Time          `json:"updated_at"`
+}
+
+func (NotificationDelivery) TableName() string {
+	return "notification_deliveries"
+}
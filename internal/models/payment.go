@@ -0,0 +1,139 @@
+package models
+
+import "time"
+
+// Proveedores de pago
+const (
+	PaymentProviderSINPE = "sinpe" // SINPE Móvil, conciliado contra estados de cuenta / SMS
+	PaymentProviderCard  = "card"  // Pasarela de tarjeta (webhook)
+)
+
+// Tipos de cobro
+const (
+	PaymentKindDeposit = "deposit" // Adelanto (pago_adelanto_pct del total)
+	PaymentKindBalance = "balance" // Saldo pendiente
+	PaymentKindFull    = "full"    // Total
+)
+
+// PaymentIntentStatus represents the state of an expected payment
+type PaymentIntentStatus string
+
+const (
+	PaymentIntentPending   PaymentIntentStatus = "pending"
+	PaymentIntentSucceeded PaymentIntentStatus = "succeeded"
+	PaymentIntentFailed    PaymentIntentStatus = "failed"
+	PaymentIntentCanceled  PaymentIntentStatus = "canceled"
+	PaymentIntentExpired   PaymentIntentStatus = "expired"
+)
+
+// Estado de pago del pedido (quotes.payment_status)
+const (
+	QuotePaymentUnpaid   = "unpaid"
+	QuotePaymentPartial  = "partial"
+	QuotePaymentPaid     = "paid"
+	QuotePaymentRefunded = "refunded"
+)
+
+// PaymentIntent is an expected payment for an order (converted quote).
+// Amounts are in CRC, IVA included.
+type PaymentIntent struct {
+	ID             uint                `gorm:"primaryKey" json:"id"`
+	QuoteID        uint                `gorm:"not null;index" json:"quote_id"`
+	UserID         uint                `gorm:"not null" json:"user_id"`
+	Provider       string              `gorm:"type:varchar(20);not null" json:"provider"`
+	Kind           string              `gorm:"type:varchar(20);not null;default:'full'" json:"kind"`
+	Reference      string              `gorm:"type:varchar(20);not null;uniqueIndex" json:"reference"` // FL-XXXXXX
+	Amount         float64             `gorm:"type:decimal(12,2);not null" json:"amount"`
+	AmountReceived float64             `gorm:"type:decimal(12,2);not null;default:0" json:"amount_received"`
+	AmountRefunded float64             `gorm:"type:decimal(12,2);not null;default:0" json:"amount_refunded"`
+	Currency       string              `gorm:"type:varchar(3);not null;default:'CRC'" json:"currency"`
+	Status         PaymentIntentStatus `gorm:"type:varchar(20);not null;default:'pending'" json:"status"`
+	PayerPhone     *string             `gorm:"type:varchar(20)" json:"payer_phone,omitempty"`
+	ProviderRef    *string             `gorm:"type:varchar(100)" json:"provider_ref,omitempty"`
+	CheckoutURL    *string             `gorm:"type:text" json:"checkout_url,omitempty"`
+	ExpiresAt      *time.Time          `json:"expires_at,omitempty"`
+	PaidAt         *time.Time          `json:"paid_at,omitempty"`
+	CreatedAt      time.Time           `json:"created_at"`
+	UpdatedAt      time.Time           `json:"updated_at"`
+
+	// Relations
+	Quote *Quote `gorm:"foreignKey:QuoteID" json:"-"`
+}
+
+func (PaymentIntent) TableName() string {
+	return "payment_intents"
+}
+
+// Outstanding returns what is still expected for this intent
+func (p *PaymentIntent) Outstanding() float64 {
+	return max(p.Amount-p.AmountReceived, 0)
+}
+
+// Refundable returns the received amount not yet refunded
+func (p *PaymentIntent) Refundable() float64 {
+	return max(p.AmountReceived-p.AmountRefunded, 0)
+}
+
+// Origen de un movimiento importado
+const (
+	PaymentSourceCSV      = "csv"
+	PaymentSourceSINPESMS = "sinpe_sms"
+	PaymentSourceWebhook  = "webhook"
+	PaymentSourceManual   = "manual"
+)
+
+// Estado de conciliación de un movimiento
+const (
+	PaymentTxMatched   = "matched"
+	PaymentTxUnmatched = "unmatched"
+	PaymentTxIgnored   = "ignored"
+)
+
+// PaymentTransaction is an incoming movement (bank statement line, SINPE SMS or gateway event)
+type PaymentTransaction struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Source      string    `gorm:"type:varchar(20);not null" json:"source"`
+	Fingerprint string    `gorm:"type:varchar(64);not null;uniqueIndex" json:"-"`
+	OccurredAt  time.Time `gorm:"not null" json:"occurred_at"`
+	Amount      float64   `gorm:"type:decimal(12,2);not null" json:"amount"`
+	Currency    string    `gorm:"type:varchar(3);not null;default:'CRC'" json:"currency"`
+	PayerName   *string   `gorm:"type:varchar(160)" json:"payer_name,omitempty"`
+	PayerPhone  *string   `gorm:"type:varchar(20)" json:"payer_phone,omitempty"`
+	BankRef     *string   `gorm:"type:varchar(100)" json:"bank_ref,omitempty"`
+	Description *string   `gorm:"type:text" json:"description,omitempty"`
+	Raw         *string   `gorm:"type:text" json:"raw,omitempty"`
+	Status      string    `gorm:"type:varchar(20);not null;default:'unmatched'" json:"status"`
+	IntentID    *uint     `json:"intent_id,omitempty"`
+	MatchRule   *string   `gorm:"type:varchar(30)" json:"match_rule,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (PaymentTransaction) TableName() string {
+	return "payment_transactions"
+}
+
+// Estado de una devolución
+const (
+	RefundPending   = "pending" // SINPE: falta que el admin haga la transferencia
+	RefundCompleted = "completed"
+	RefundFailed    = "failed"
+)
+
+// PaymentRefund is a partial or full refund of an intent
+type PaymentRefund struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	IntentID    uint       `gorm:"not null;index" json:"intent_id"`
+	Amount      float64    `gorm:"type:decimal(12,2);not null" json:"amount"`
+	Reason      *string    `gorm:"type:text" json:"reason,omitempty"`
+	Status      string     `gorm:"type:varchar(20);not null;default:'pending'" json:"status"`
+	ProviderRef *string    `gorm:"type:varchar(100)" json:"provider_ref,omitempty"`
+	CreatedBy   *uint      `json:"created_by,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+func (PaymentRefund) TableName() string {
+	return "payment_refunds"
+}
@@ -6,6 +6,7 @@ import (
 
 type PriceReference struct {
 	ID          uint    `gorm:"primaryKey" json:"id"`
+	TenantID    uint    `gorm:"not null;default:1" json:"-"`
 	ServiceType string  `gorm:"type:varchar(100);not null" json:"service_type"` // grabado_basico, fotograbado, corte_simple, etc.
 	MinUSD      float64 `gorm:"type:decimal(10,2);not null" json:"min_usd"`
 	MaxUSD      float64 `gorm:"type:decimal(10,2);not null" json:"max_usd"`
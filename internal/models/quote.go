@@ -19,15 +19,28 @@ const (
 	QuoteStatusConverted    QuoteStatus = "converted"     // Converted to order
 )
 
+// Origen de la cotización
+const (
+	QuoteSourceWeb   = "web"   // Cotizador web con archivo SVG
+	QuoteSourceBot   = "bot"   // Borrador armado desde una conversación de WhatsApp/Telegram
+	QuoteSourceAdmin = "admin" // Creada por un gestor desde el asistente admin
+)
+
 // Quote represents a pricing quotation for a laser job
 type Quote struct {
 	ID        uint      `gorm:"primaryKey" json:"id"`
-	UserID    uint      `gorm:"not null;index" json:"user_id"`
+	TenantID  uint      `gorm:"not null;default:1" json:"-"`
+	UserID    uint      `gorm:"index" json:"user_id"` // 0 (NULL) = borrador del bot de un contacto sin cuenta
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 
-	// Source analysis
-	SVGAnalysisID uint `gorm:"not null;index" json:"svg_analysis_id"`
+	// Source analysis (0 / NULL en borradores del bot: las medidas vienen de la conversación)
+	SVGAnalysisID uint `gorm:"index" json:"svg_analysis_id"`
+
+	// Origen: web o borrador del bot ligado al lead de la conversación
+	Source        string         `gorm:"type:varchar(20);not null;default:'web'" json:"source"`
+	LeadID        *uint          `json:"lead_id,omitempty"`
+	RequestParams datatypes.JSON `gorm:"type:jsonb" json:"request_params,omitempty"`
 
 	// Selected options (FK to config tables)
 	TechnologyID    uint  `gorm:"not null" json:"technology_id"`
@@ -81,6 +94,23 @@ type Quote struct {
 	PriceModel       string  `gorm:"type:varchar(10);default:'hybrid'" json:"price_model"` // "hybrid" o "value" — indica cuál modelo determinó el precio final
 	PriceModelDetail string  `gorm:"type:varchar(20)" json:"price_model_detail,omitempty"` // "area" o "perimeter" — detalle del modelo value
 
+	// Currency: montos guardados en BaseCurrency, presentados en Currency.
+	// ExchangeRate (CRC por 1 USD) queda congelado para reproducir cotizaciones históricas.
+	Currency         string     `gorm:"type:varchar(3);not null;default:'CRC'" json:"currency"`
+	BaseCurrency     string     `gorm:"type:varchar(3);not null;default:'CRC'" json:"base_currency"`
+	ExchangeRate     float64    `gorm:"type:decimal(12,4);not null;default:0" json:"exchange_rate"`
+	ExchangeRateDate *time.Time `gorm:"type:date" json:"exchange_rate_date,omitempty"`
+
+	// Tax: PriceFinal es el subtotal sin IVA. Tarifa, exoneración y modo de presentación
+	// quedan congelados al cotizar.
+	TaxKind           string  `gorm:"type:varchar(20);not null;default:'mercancia'" json:"tax_kind"`     // servicio, mercancia, blank
+	TaxRate           float64 `gorm:"type:decimal(5,2);not null;default:0" json:"tax_rate"`              // % IVA
+	TaxExemptPct      float64 `gorm:"type:decimal(5,2);not null;default:0" json:"tax_exempt_pct"`        // % del IVA exonerado
+	TaxExoneracion    *string `gorm:"type:varchar(40)" json:"tax_exoneracion,omitempty"`                 // Número de exoneración aplicada
+	TaxAmount         float64 `gorm:"type:decimal(12,2);not null;default:0" json:"tax_amount"`           // IVA neto
+	PriceTotalWithTax float64 `gorm:"type:decimal(12,2);not null;default:0" json:"price_total_with_tax"` // PriceFinal + TaxAmount
+	TaxDisplay        string  `gorm:"type:varchar(10);not null;default:'exclusive'" json:"tax_display"`  // exclusive, inclusive
+
 	// Simulation: What if we apply FactorMaterial to Hybrid?
 	SimHybridWithMaterialFactor float64 `gorm:"type:decimal(12,2);default:0" json:"sim_hybrid_with_material_factor"`
 	SimDifferencePct            float64 `gorm:"type:decimal(8,4);default:0" json:"sim_difference_pct"`
@@ -100,6 +130,10 @@ type Quote struct {
 	ValidUntil    time.Time   `json:"valid_until"`              // Quote expiration
 	ConvertedToID *uint       `json:"converted_to_id,omitempty"` // Order ID if converted
 
+	// Payment (mantenido por payment_intents; CRC, IVA incluido)
+	AmountPaid    float64 `gorm:"type:decimal(12,2);not null;default:0" json:"amount_paid"`
+	PaymentStatus string  `gorm:"type:varchar(20);not null;default:'unpaid'" json:"payment_status"` // unpaid, partial, paid, refunded
+
 	// Relations
 	User        *User        `gorm:"foreignKey:UserID" json:"-"`
 	SVGAnalysis *SVGAnalysis `gorm:"foreignKey:SVGAnalysisID" json:"svg_analysis,omitempty"`
@@ -127,6 +161,12 @@ func (q *Quote) NeedsReview() bool {
 	return q.Status == QuoteStatusNeedsReview
 }
 
+// ToDisplayCurrency converts an amount stored in BaseCurrency to the presentation Currency
+// using the exchange rate frozen on the quote
+func (q *Quote) ToDisplayCurrency(amount float64) float64 {
+	return RoundCurrency(ConvertCurrency(amount, q.BaseCurrency, q.Currency, q.ExchangeRate), q.Currency)
+}
+
 // ToSummary returns quote summary for list views
 func (q *Quote) ToSummary() map[string]interface{} {
 	return map[string]interface{}{
@@ -135,6 +175,12 @@ func (q *Quote) ToSummary() map[string]interface{} {
 		"quantity":          q.Quantity,
 		"price_hybrid_unit": q.PriceHybridUnit,
 		"price_final":       q.PriceFinal,
+		"currency":          q.Currency,
+		"display_total":     q.ToDisplayCurrency(q.PriceFinal),
+		"tax_amount":        q.TaxAmount,
+		"total_with_tax":    q.PriceTotalWithTax,
+		"tax_display":       q.TaxDisplay,
+		"payment_status":    q.PaymentStatus,
 		"status":            q.Status,
 		"valid_until":       q.ValidUntil,
 		"created_at":        q.CreatedAt,
@@ -148,6 +194,10 @@ func (q *Quote) ToDetailedJSON() map[string]interface{} {
 		"user_id":    q.UserID,
 		"created_at": q.CreatedAt,
 
+		"source":         q.Source,
+		"lead_id":        q.LeadID,
+		"request_params": q.RequestParams,
+
 		"svg_analysis_id":   q.SVGAnalysisID,
 		"technology_id":     q.TechnologyID,
 		"material_id":       q.MaterialID,
@@ -203,6 +253,35 @@ func (q *Quote) ToDetailedJSON() map[string]interface{} {
 			"model_detail": q.PriceModelDetail,
 		},
 
+		"currency": map[string]interface{}{
+			"code":               q.Currency,
+			"base":               q.BaseCurrency,
+			"exchange_rate":      q.ExchangeRate,
+			"exchange_rate_date": q.ExchangeRateDate,
+			"display_unit":       q.ToDisplayCurrency(q.PriceFinal / float64(max(q.Quantity, 1))),
+			"display_total":      q.ToDisplayCurrency(q.PriceFinal),
+		},
+
+		"tax": map[string]interface{}{
+			"kind":             q.TaxKind,
+			"rate":             q.TaxRate,
+			"exempt_pct":       q.TaxExemptPct,
+			"exoneracion":      q.TaxExoneracion,
+			"display":          q.TaxDisplay,
+			"subtotal":         q.PriceFinal,
+			"iva":              q.TaxAmount,
+			"total":            q.PriceTotalWithTax,
+			"display_subtotal": q.ToDisplayCurrency(q.PriceFinal),
+			"display_iva":      q.ToDisplayCurrency(q.TaxAmount),
+			"display_total":    q.ToDisplayCurrency(q.PriceTotalWithTax),
+		},
+
+		"payment": map[string]interface{}{
+			"status":      q.PaymentStatus,
+			"amount_paid": q.AmountPaid,
+			"balance":     max(q.PriceTotalWithTax-q.AmountPaid, 0),
+		},
+
 		"simulation": map[string]interface{}{
 			"hybrid_with_material_factor": q.SimHybridWithMaterialFactor,
 			"difference_pct":              q.SimDifferencePct,
@@ -6,6 +6,7 @@ import (
 
 type TechMaterialSpeed struct {
 	ID                uint     `gorm:"primaryKey" json:"id"`
+	TenantID          uint     `gorm:"not null;default:1" json:"-"`
 	TechnologyID      uint     `gorm:"not null;index" json:"technology_id"`
 	MaterialID        uint     `gorm:"not null;index" json:"material_id"`
 	Thickness         float64  `gorm:"type:decimal(5,2);not null" json:"thickness"`
@@ -17,6 +18,10 @@ type TechMaterialSpeed struct {
 	// Si es nil, se calcula como engrave_speed_mm_min × spot_size_mm (comportamiento legacy).
 	RasterSpeedMm2Min *float64 `gorm:"column:raster_speed_mm2_min;type:decimal(10,2)" json:"raster_speed_mm2_min,omitempty"`
 	IsCompatible      bool     `gorm:"default:true" json:"is_compatible"`
+	// EngraveRank: preferencia para grabar el material con esta tecnología (menor = primero)
+	EngraveRank       int      `gorm:"default:100" json:"engrave_rank"`
+	// ColorMarking: la tecnología conserva o genera color en el acabado (anodizado, recubrimiento)
+	ColorMarking      bool     `json:"color_marking"`
 	Notes             *string  `gorm:"type:text" json:"notes,omitempty"`
 	IsActive          bool     `gorm:"default:true" json:"is_active"`
 	CreatedAt         time.Time `json:"created_at"`
@@ -6,17 +6,19 @@ import (
 
 type TechRate struct {
 	ID               uint    `gorm:"primaryKey" json:"id"`
+	TenantID         uint    `gorm:"not null;default:1" json:"-"`
 	TechnologyID     uint    `gorm:"not null;index" json:"technology_id"`
-	EngraveRateHour  float64 `gorm:"type:decimal(10,4);not null" json:"engrave_rate_hour"`  // USD/hour
-	CutRateHour      float64 `gorm:"type:decimal(10,4);not null" json:"cut_rate_hour"`      // USD/hour
-	DesignRateHour   float64 `gorm:"type:decimal(10,4);not null" json:"design_rate_hour"`   // USD/hour
-	OverheadRateHour float64 `gorm:"type:decimal(10,4);default:3.78" json:"overhead_rate_hour"` // Fixed costs USD/hour
+	EngraveRateHour  float64 `gorm:"type:decimal(10,4);not null" json:"engrave_rate_hour"`  // Currency/hour
+	CutRateHour      float64 `gorm:"type:decimal(10,4);not null" json:"cut_rate_hour"`      // Currency/hour
+	DesignRateHour   float64 `gorm:"type:decimal(10,4);not null" json:"design_rate_hour"`   // Currency/hour
+	OverheadRateHour float64 `gorm:"type:decimal(10,4);default:3.78" json:"overhead_rate_hour"` // Fixed costs Currency/hour
 	SetupFee         float64 `gorm:"type:decimal(10,4);default:0" json:"setup_fee"`         // One-time setup fee
 	CostPerMinEngrave float64 `gorm:"type:decimal(10,6);not null" json:"cost_per_min_engrave"` // Calculated: (engrave + overhead) / 60
 	CostPerMinCut    float64 `gorm:"type:decimal(10,6);not null" json:"cost_per_min_cut"`    // Calculated: (cut + overhead) / 60
 	MarginPercent    float64 `gorm:"type:decimal(5,4);default:0.40" json:"margin_percent"`  // Default 40%
+	Currency         string  `gorm:"type:varchar(3);not null;default:'CRC'" json:"currency"` // Moneda de todos los montos de la fila
 
-	// Costos fijos mensuales por máquina (₡/mes)
+	// Costos fijos mensuales por máquina (Currency/mes)
 	ElectricidadMes  float64 `gorm:"type:float;not null;default:0" json:"electricidad_mes"`
 	MantenimientoMes float64 `gorm:"type:float;not null;default:0" json:"mantenimiento_mes"`
 	DepreciacionMes  float64 `gorm:"type:float;not null;default:0" json:"depreciacion_mes"`
@@ -6,6 +6,7 @@ import (
 
 type Technology struct {
 	ID              uint    `gorm:"primaryKey" json:"id"`
+	TenantID        uint    `gorm:"not null;default:1" json:"-"`
 	Code            string  `gorm:"type:varchar(20);uniqueIndex;not null" json:"code"` // CO2, UV, FIBRA, MOPA
 	Name            string  `gorm:"type:varchar(100);not null" json:"name"`
 	Description     *string `gorm:"type:text" json:"description,omitempty"`
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// DefaultTenantID es el tenant de los datos anteriores a multi-tenant
+// (FabricaLaser) y el que atiende los hosts desconocidos
+const DefaultTenantID uint = 1
+
+// Tenant es un taller que comparte el despliegue: su configuración de precios,
+// catálogo, usuarios, cotizaciones y conversaciones están aislados por tenant_id.
+type Tenant struct {
+	ID          uint           `gorm:"primaryKey" json:"id"`
+	Slug        string         `gorm:"type:varchar(40);not null;uniqueIndex" json:"slug"`
+	Name        string         `gorm:"type:varchar(120);not null" json:"name"`
+	Hosts       datatypes.JSON `gorm:"type:jsonb;not null;default:'[]'" json:"hosts"` // ["taller.com", "www.taller.com"]
+	APIKeyHash  *string        `gorm:"type:varchar(64);uniqueIndex" json:"-"`         // SHA-256 hex de X-API-Key
+	Branding    datatypes.JSON `gorm:"type:jsonb;not null;default:'{}'" json:"branding"`
+	DigestEmail string         `gorm:"type:varchar(255);not null;default:''" json:"digest_email"` // Resumen diario de conversaciones
+	Credentials datatypes.JSON `gorm:"type:jsonb;not null;default:'{}'" json:"-"`
+	IsActive    bool           `gorm:"not null;default:true" json:"is_active"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+}
+
+func (Tenant) TableName() string {
+	return "tenants"
+}
+
+// TenantBranding es la identidad del taller en el sitio, los PDF y los correos
+type TenantBranding struct {
+	DisplayName string `json:"display_name"`
+	LogoPath    string `json:"logo_path"` // PNG o JPG para el PDF de cotización
+	WebDir      string `json:"web_dir"`   // Raíz del sitio estático del taller
+	QuoteURL    string `json:"quote_url"` // URL del cotizador para el QR; %d = ID de la cotización
+}
+
+// TenantCredentials son los tokens de los canales de mensajería del taller
+type TenantCredentials struct {
+	WhatsAppPhoneNumberID string `json:"whatsapp_phone_number_id"`
+	WhatsAppAccessToken   string `json:"whatsapp_access_token"`
+	WhatsAppAppSecret     string `json:"whatsapp_app_secret"`
+	WhatsAppVerifyToken   string `json:"whatsapp_verify_token"`
+	TelegramBotToken      string `json:"telegram_bot_token"`
+}
+
+// HostList retorna los hosts que resuelven a este tenant
+func (t *Tenant) HostList() []string {
+	var hosts []string
+	_ = json.Unmarshal(t.Hosts, &hosts)
+	return hosts
+}
+
+// Brand retorna el branding del tenant; DisplayName vacío usa Name
+func (t *Tenant) Brand() TenantBranding {
+	var b TenantBranding
+	_ = json.Unmarshal(t.Branding, &b)
+	if b.DisplayName == "" {
+		b.DisplayName = t.Name
+	}
+	return b
+}
+
+// ChannelCredentials retorna los tokens de WhatsApp y Telegram del tenant
+func (t *Tenant) ChannelCredentials() TenantCredentials {
+	var c TenantCredentials
+	_ = json.Unmarshal(t.Credentials, &c)
+	return c
+}
@@ -8,6 +8,7 @@ import (
 
 type User struct {
 	ID           uint           `gorm:"primaryKey" json:"id"`
+	TenantID     uint           `gorm:"not null;default:1" json:"-"`
 	Cedula       string         `gorm:"type:varchar(10);uniqueIndex:idx_users_cedula_unique,where:password_hash IS NOT NULL" json:"cedula"`
 	CedulaType   string         `gorm:"type:varchar(10);default:'fisica'" json:"cedula_type"` // fisica, juridica
 	Nombre       string         `gorm:"type:varchar(100);not null" json:"nombre"`
@@ -30,6 +31,19 @@ type User struct {
 	Canton    *string `gorm:"type:varchar(100)" json:"canton,omitempty"`
 	Distrito  *string `gorm:"type:varchar(100)" json:"distrito,omitempty"`
 
+	// Exoneración de IVA (Hacienda)
+	ExoneracionNumero      *string    `gorm:"type:varchar(40)" json:"exoneracion_numero,omitempty"`
+	ExoneracionTipo        *string    `gorm:"type:varchar(2)" json:"exoneracion_tipo,omitempty"`
+	ExoneracionInstitucion *string    `gorm:"type:varchar(160)" json:"exoneracion_institucion,omitempty"`
+	ExoneracionPct         float64    `gorm:"type:decimal(5,2);default:0" json:"exoneracion_pct"` // % del IVA exonerado (100 = total)
+	ExoneracionFecha       *time.Time `gorm:"type:date" json:"exoneracion_fecha,omitempty"`
+	ExoneracionVence       *time.Time `gorm:"type:date" json:"exoneracion_vence,omitempty"`
+
+	// Notificaciones proactivas (cotizaciones y pedidos)
+	NotifChannel   string `gorm:"type:varchar(20);not null;default:'email'" json:"notif_channel"` // email, whatsapp, telegram
+	NotifOptOut    bool   `gorm:"default:false" json:"notif_opt_out"`
+	TelegramChatID *int64 `json:"telegram_chat_id,omitempty"`
+
 	// Password reset (never exposed in API responses)
 	PasswordResetToken   *string    `gorm:"type:varchar(64)" json:"-"`
 	PasswordResetExpires *time.Time `gorm:"type:timestamptz" json:"-"`
@@ -64,6 +78,17 @@ func (u *User) RemainingQuotes() int {
 	return remaining
 }
 
+// ExemptPctAt returns the IVA exemption percentage valid on the given date (0 if none or expired)
+func (u *User) ExemptPctAt(at time.Time) float64 {
+	if u.ExoneracionNumero == nil || *u.ExoneracionNumero == "" || u.ExoneracionPct <= 0 {
+		return 0
+	}
+	if u.ExoneracionVence != nil && at.After(u.ExoneracionVence.AddDate(0, 0, 1)) {
+		return 0
+	}
+	return min(u.ExoneracionPct, 100)
+}
+
 // IsAdmin returns true if user has admin role
 func (u *User) IsAdmin() bool {
 	return u.Role == "admin"
@@ -6,6 +6,7 @@ import (
 
 type VolumeDiscount struct {
 	ID          uint    `gorm:"primaryKey" json:"id"`
+	TenantID    uint    `gorm:"not null;default:1" json:"-"`
 	MinQty      int     `gorm:"not null" json:"min_qty"`
 	MaxQty      *int    `json:"max_qty,omitempty"` // NULL = unlimited
 	DiscountPct float64 `gorm:"type:decimal(5,4);not null" json:"discount_pct"` // 0.00 - 0.20 (0% - 20%)
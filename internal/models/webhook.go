@@ -0,0 +1,90 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// WebhookStatus es el estado de una entrega de webhook
+type WebhookStatus string
+
+const (
+	WebhookPending WebhookStatus = "pending" // Por enviar o esperando reintento
+	WebhookSent    WebhookStatus = "sent"
+	WebhookFailed  WebhookStatus = "failed" // Se agotaron los reintentos o el endpoint se deshabilitó
+)
+
+// WebhookEndpoint es una suscripción de un tenant (o de un cliente de la API)
+// a los eventos de cotizaciones y pedidos
+type WebhookEndpoint struct {
+	ID                  uint           `gorm:"primaryKey" json:"id"`
+	TenantID            uint           `gorm:"not null;default:1" json:"-"`
+	APIKeyID            *uint          `json:"api_key_id,omitempty"` // nil = todo el tenant
+	URL                 string         `gorm:"type:text;not null" json:"url"`
+	Secret              string         `gorm:"type:varchar(80);not null" json:"-"`
+	Events              datatypes.JSON `gorm:"type:jsonb;not null;default:'[]'" json:"events"` // [] = todos
+	Description         string         `gorm:"type:varchar(200);not null;default:''" json:"description"`
+	IsActive            bool           `gorm:"not null;default:true" json:"is_active"`
+	ConsecutiveFailures int            `gorm:"not null;default:0" json:"consecutive_failures"`
+	DisabledAt          *time.Time     `json:"disabled_at,omitempty"`
+	DisabledReason      *string        `gorm:"type:text" json:"disabled_reason,omitempty"`
+	CreatedBy           *uint          `json:"created_by,omitempty"`
+	CreatedAt           time.Time      `json:"created_at"`
+	UpdatedAt           time.Time      `json:"updated_at"`
+
+	// Relations
+	APIKey *APIKey `gorm:"foreignKey:APIKeyID" json:"api_key,omitempty"`
+}
+
+func (WebhookEndpoint) TableName() string {
+	return "webhook_endpoints"
+}
+
+// EventList retorna los tipos de evento suscritos; vacío = todos
+func (e *WebhookEndpoint) EventList() []string {
+	var list []string
+	_ = json.Unmarshal(e.Events, &list)
+	return list
+}
+
+// Wants indica si el endpoint está suscrito al tipo de evento
+func (e *WebhookEndpoint) Wants(eventType string) bool {
+	list := e.EventList()
+	if len(list) == 0 {
+		return true
+	}
+	for _, t := range list {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery es una entrega de un evento a un endpoint. Guarda el cuerpo
+// exacto para reintentar o reenviar con la misma firma.
+type WebhookDelivery struct {
+	ID             uint           `gorm:"primaryKey" json:"id"`
+	TenantID       uint           `gorm:"not null;default:1" json:"-"`
+	EndpointID     uint           `gorm:"not null;index" json:"endpoint_id"`
+	EventID        string         `gorm:"type:varchar(40);not null" json:"event_id"`
+	EventType      string         `gorm:"type:varchar(40);not null" json:"event_type"`
+	QuoteID        *uint          `json:"quote_id,omitempty"`
+	Payload        datatypes.JSON `gorm:"type:jsonb;not null" json:"payload"`
+	Status         WebhookStatus  `gorm:"type:varchar(20);not null;default:'pending'" json:"status"`
+	Attempts       int            `gorm:"not null;default:0" json:"attempts"`
+	ResponseStatus *int           `json:"response_status,omitempty"`
+	ResponseBody   *string        `gorm:"type:text" json:"response_body,omitempty"` // Primeros 2 KB
+	LastError      *string        `gorm:"type:text" json:"last_error,omitempty"`
+	NextAttemptAt  *time.Time     `json:"next_attempt_at,omitempty"`
+	DeliveredAt    *time.Time     `json:"delivered_at,omitempty"`
+	ReplayOfID     *uint          `json:"replay_of_id,omitempty"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+}
+
+func (WebhookDelivery) TableName() string {
+	return "webhook_deliveries"
+}
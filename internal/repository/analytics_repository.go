@@ -0,0 +1,259 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/database"
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+	"github.com/alonsoalpizar/fabricalaser/internal/tenant"
+	"gorm.io/gorm"
+)
+
+type AnalyticsRepository struct {
+	db       *gorm.DB
+	tenantID uint
+}
+
+func NewAnalyticsRepository() *AnalyticsRepository {
+	return &AnalyticsRepository{db: database.Get(), tenantID: models.DefaultTenantID}
+}
+
+// WithContext retorna el repositorio sobre el tenant de ctx. Eventos y rollups
+// los filtra el plugin de tenant; el rollup lee whatsapp_conversations con SQL
+// directo y filtra tenant_id a mano.
+func (r *AnalyticsRepository) WithContext(ctx context.Context) *AnalyticsRepository {
+	return &AnalyticsRepository{db: r.db.WithContext(ctx), tenantID: tenant.ID(ctx)}
+}
+
+// ─────────────────────────────────────────────
+// Eventos del bot
+// ─────────────────────────────────────────────
+
+// RecordEvent stores a tool call or rate limit hit
+func (r *AnalyticsRepository) RecordEvent(ev *models.BotEvent) error {
+	return r.db.Create(ev).Error
+}
+
+// ─────────────────────────────────────────────
+// Rollup de un día (hora Costa Rica)
+// ─────────────────────────────────────────────
+
+// dayBoundsCTE acota el día local ?::date en UTC; usa el índice por created_at
+const dayBoundsCTE = `
+	WITH bounds AS (
+		SELECT (?::date)::timestamp AT TIME ZONE 'America/Costa_Rica' AS since,
+		       (?::date + 1)::timestamp AT TIME ZONE 'America/Costa_Rica' AS until
+	)`
+
+// keyChannelSQL: mismo criterio que channels.KeyChannel
+const keyChannelSQL = `CASE WHEN phone LIKE 'tg:%' THEN 'telegram' WHEN phone LIKE 'web:%' THEN 'web' ELSE 'whatsapp' END`
+
+// maxBotLatency descarta de la latencia las respuestas que no son del bot: un
+// asesor que contesta desde el panel tarda minutos u horas.
+const maxBotLatency = "5 minutes"
+
+// DayStats summarizes the archived messages of a day by channel. Latency is the
+// time between a customer message and the bot reply that follows it; messages
+// archived before both turns had their own timestamp (equal times) are skipped.
+func (r *AnalyticsRepository) DayStats(day time.Time) ([]models.ConversationStatsDaily, error) {
+	date := day.Format("2006-01-02")
+	var rows []models.ConversationStatsDaily
+	err := r.db.Raw(dayBoundsCTE+`,
+		msgs AS (
+			SELECT wc.phone, wc.role, wc.created_at,
+				LAG(wc.role) OVER w AS prev_role,
+				LAG(wc.created_at) OVER w AS prev_at
+			FROM whatsapp_conversations wc, bounds b
+			WHERE wc.tenant_id = ? AND wc.created_at >= b.since AND wc.created_at < b.until
+			WINDOW w AS (PARTITION BY wc.phone ORDER BY wc.created_at, wc.id)
+		),
+		timed AS (
+			SELECT phone, role, `+keyChannelSQL+` AS channel,
+				CASE WHEN role = 'model' AND prev_role = 'user'
+					AND created_at > prev_at AND created_at - prev_at < INTERVAL '`+maxBotLatency+`'
+				THEN EXTRACT(EPOCH FROM created_at - prev_at) END AS latency
+			FROM msgs
+		)
+		SELECT ?::date AS day, channel,
+			COUNT(*) AS messages,
+			COUNT(*) FILTER (WHERE role = 'user') AS user_messages,
+			COUNT(DISTINCT phone) AS contacts,
+			COUNT(latency) AS responses,
+			COALESCE(ROUND(AVG(latency)::numeric, 2), 0) AS avg_response_secs,
+			COALESCE(ROUND((percentile_cont(0.9) WITHIN GROUP (ORDER BY latency))::numeric, 2), 0) AS p90_response_secs
+		FROM timed
+		GROUP BY channel
+		ORDER BY channel`, date, date, r.tenantID, date).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// Escalados, rechazos del rate limiter y tools: canales sin mensajes también
+	// cuentan. La bandeja de atención humana es solo del tenant 1.
+	var counts []struct {
+		Channel string
+		Metric  string
+		N       int
+	}
+	err = r.db.Raw(dayBoundsCTE+`
+		SELECT h.channel, 'escalations' AS metric, COUNT(*) AS n
+		FROM conversation_handoffs h, bounds b
+		WHERE ? AND h.created_at >= b.since AND h.created_at < b.until
+		GROUP BY h.channel
+		UNION ALL
+		SELECT e.channel, e.kind AS metric, COUNT(*) AS n
+		FROM bot_events e, bounds b
+		WHERE e.tenant_id = ? AND e.created_at >= b.since AND e.created_at < b.until
+		GROUP BY e.channel, e.kind`, date, date, r.tenantID == models.DefaultTenantID, r.tenantID).Scan(&counts).Error
+	if err != nil {
+		return nil, err
+	}
+
+	byChannel := make(map[string]int, len(rows))
+	for i := range rows {
+		byChannel[rows[i].Channel] = i
+	}
+	for _, c := range counts {
+		i, ok := byChannel[c.Channel]
+		if !ok {
+			rows = append(rows, models.ConversationStatsDaily{Day: day, Channel: c.Channel})
+			i = len(rows) - 1
+			byChannel[c.Channel] = i
+		}
+		switch c.Metric {
+		case "escalations":
+			rows[i].Escalations = c.N
+		case models.BotEventRateLimit:
+			rows[i].LimitHits = c.N
+		case models.BotEventToolCall:
+			rows[i].ToolCalls = c.N
+		}
+	}
+	return rows, nil
+}
+
+// DayTools counts the tool calls of a day by channel and tool
+func (r *AnalyticsRepository) DayTools(day time.Time) ([]models.ConversationToolDaily, error) {
+	date := day.Format("2006-01-02")
+	var rows []models.ConversationToolDaily
+	err := r.db.Raw(dayBoundsCTE+`
+		SELECT ?::date AS day, e.channel, e.name AS tool,
+			COUNT(*) AS calls,
+			COUNT(*) FILTER (WHERE NOT e.ok) AS failures
+		FROM bot_events e, bounds b
+		WHERE e.tenant_id = ? AND e.kind = ? AND e.name IS NOT NULL
+		  AND e.created_at >= b.since AND e.created_at < b.until
+		GROUP BY e.channel, e.name`, date, date, date, r.tenantID, models.BotEventToolCall).Scan(&rows).Error
+	return rows, err
+}
+
+// DayHours counts customer messages of a day by channel and local hour
+func (r *AnalyticsRepository) DayHours(day time.Time) ([]models.ConversationHourly, error) {
+	date := day.Format("2006-01-02")
+	var rows []models.ConversationHourly
+	err := r.db.Raw(dayBoundsCTE+`
+		SELECT ?::date AS day, `+keyChannelSQL+` AS channel,
+			EXTRACT(HOUR FROM wc.created_at AT TIME ZONE 'America/Costa_Rica')::int AS hour,
+			COUNT(*) AS messages
+		FROM whatsapp_conversations wc, bounds b
+		WHERE wc.tenant_id = ? AND wc.role = 'user' AND wc.created_at >= b.since AND wc.created_at < b.until
+		GROUP BY 2, 3`, date, date, date, r.tenantID).Scan(&rows).Error
+	return rows, err
+}
+
+// DayUserMessages returns the customer messages of a day (for topic extraction)
+func (r *AnalyticsRepository) DayUserMessages(day time.Time) ([]ConversationMessage, error) {
+	date := day.Format("2006-01-02")
+	var rows []ConversationMessage
+	err := r.db.Raw(dayBoundsCTE+`
+		SELECT wc.id, wc.phone, wc.role, wc.content, wc.created_at
+		FROM whatsapp_conversations wc, bounds b
+		WHERE wc.tenant_id = ? AND wc.role = 'user' AND wc.created_at >= b.since AND wc.created_at < b.until
+		ORDER BY wc.created_at`, date, date, r.tenantID).Scan(&rows).Error
+	return rows, err
+}
+
+// SaveDay replaces the tenant's rollup rows of a day in a single transaction
+func (r *AnalyticsRepository) SaveDay(day time.Time, stats []models.ConversationStatsDaily, tools []models.ConversationToolDaily,
+	hours []models.ConversationHourly, topics []models.ConversationTopicDaily) error {
+	date := day.Format("2006-01-02")
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for _, table := range []string{"conversation_stats_daily", "conversation_tool_daily", "conversation_hourly", "conversation_topics_daily"} {
+			if err := tx.Exec("DELETE FROM "+table+" WHERE tenant_id = ? AND day = ?::date", r.tenantID, date).Error; err != nil {
+				return err
+			}
+		}
+		if len(stats) > 0 {
+			if err := tx.Create(&stats).Error; err != nil {
+				return err
+			}
+		}
+		if len(tools) > 0 {
+			if err := tx.Create(&tools).Error; err != nil {
+				return err
+			}
+		}
+		if len(hours) > 0 {
+			if err := tx.Create(&hours).Error; err != nil {
+				return err
+			}
+		}
+		if len(topics) > 0 {
+			if err := tx.Create(&topics).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ─────────────────────────────────────────────
+// Dashboard (lee solo los rollups; from y to inclusive)
+// ─────────────────────────────────────────────
+
+func rollupRange(db *gorm.DB, from, to time.Time, channel string) *gorm.DB {
+	db = db.Where("day BETWEEN ?::date AND ?::date", from.Format("2006-01-02"), to.Format("2006-01-02"))
+	if channel != "" {
+		db = db.Where("channel = ?", channel)
+	}
+	return db
+}
+
+// FindStats lists the daily summaries of a range
+func (r *AnalyticsRepository) FindStats(from, to time.Time, channel string) ([]models.ConversationStatsDaily, error) {
+	var rows []models.ConversationStatsDaily
+	err := rollupRange(r.db, from, to, channel).Order("day, channel").Find(&rows).Error
+	return rows, err
+}
+
+// ToolUsage totals the tool calls of a range, most used first
+func (r *AnalyticsRepository) ToolUsage(from, to time.Time, channel string) ([]models.ToolUsage, error) {
+	var rows []models.ToolUsage
+	err := rollupRange(r.db.Model(&models.ConversationToolDaily{}), from, to, channel).
+		Select("tool, SUM(calls) AS calls, SUM(failures) AS failures").
+		Group("tool").Order("calls DESC, tool").Scan(&rows).Error
+	return rows, err
+}
+
+// PeakHours totals customer messages of a range by weekday and hour
+func (r *AnalyticsRepository) PeakHours(from, to time.Time, channel string) ([]models.HourUsage, error) {
+	var rows []models.HourUsage
+	err := rollupRange(r.db.Model(&models.ConversationHourly{}), from, to, channel).
+		Select("EXTRACT(ISODOW FROM day)::int AS weekday, hour, SUM(messages) AS messages").
+		Group("weekday, hour").Order("weekday, hour").Scan(&rows).Error
+	return rows, err
+}
+
+// TopTopics returns the most mentioned materials or blanks of a range. Empty kind = both.
+func (r *AnalyticsRepository) TopTopics(from, to time.Time, channel, kind string, limit int) ([]models.TopicUsage, error) {
+	var rows []models.TopicUsage
+	query := rollupRange(r.db.Model(&models.ConversationTopicDaily{}), from, to, channel)
+	if kind != "" {
+		query = query.Where("kind = ?", kind)
+	}
+	err := query.
+		Select("kind, ref_id, MAX(name) AS name, SUM(mentions) AS mentions, SUM(contacts) AS contacts").
+		Group("kind, ref_id").Order("mentions DESC, name").Limit(limit).Scan(&rows).Error
+	return rows, err
+}
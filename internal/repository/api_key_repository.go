@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/database"
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+	"github.com/alonsoalpizar/fabricalaser/internal/tenant"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrAPIKeyNotFound = errors.New("API key no encontrada")
+
+type APIKeyRepository struct {
+	db *gorm.DB
+}
+
+func NewAPIKeyRepository() *APIKeyRepository {
+	return &APIKeyRepository{db: database.Get()}
+}
+
+// WithContext retorna el repositorio sobre ctx; las consultas quedan en el tenant del request
+func (r *APIKeyRepository) WithContext(ctx context.Context) *APIKeyRepository {
+	return &APIKeyRepository{db: r.db.WithContext(ctx)}
+}
+
+// Create stores a new key
+func (r *APIKeyRepository) Create(k *models.APIKey) error {
+	return r.db.Omit(clause.Associations).Create(k).Error
+}
+
+// Update saves a key without touching its relations
+func (r *APIKeyRepository) Update(k *models.APIKey) error {
+	return r.db.Omit(clause.Associations).Save(k).Error
+}
+
+// FindAll returns the tenant's keys with their owner, newest first
+func (r *APIKeyRepository) FindAll() ([]models.APIKey, error) {
+	var keys []models.APIKey
+	err := r.db.Preload("User").Order("created_at DESC").Find(&keys).Error
+	return keys, err
+}
+
+// FindByID finds a key of the tenant
+func (r *APIKeyRepository) FindByID(id uint) (*models.APIKey, error) {
+	var k models.APIKey
+	if err := r.db.First(&k, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAPIKeyNotFound
+		}
+		return nil, err
+	}
+	return &k, nil
+}
+
+// FindByPrefix finds a key in any tenant: the key is what identifies the tenant
+func (r *APIKeyRepository) FindByPrefix(prefix string) (*models.APIKey, error) {
+	var k models.APIKey
+	if err := tenant.AllTenants(r.db).Where("prefix = ?", prefix).First(&k).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAPIKeyNotFound
+		}
+		return nil, err
+	}
+	return &k, nil
+}
+
+// RecordUsage counts a request of the key on the endpoint and marks it as used
+func (r *APIKeyRepository) RecordUsage(id uint, endpoint string, failed bool, at time.Time) error {
+	errs := 0
+	if failed {
+		errs = 1
+	}
+	err := r.db.Exec(`
+		INSERT INTO api_key_usage (api_key_id, day, endpoint, requests, errors)
+		VALUES (?, ?, ?, 1, ?)
+		ON CONFLICT (api_key_id, day, endpoint) DO UPDATE SET
+			requests = api_key_usage.requests + 1,
+			errors   = api_key_usage.errors + EXCLUDED.errors`,
+		id, at.Format("2006-01-02"), endpoint, errs).Error
+	if err != nil {
+		return err
+	}
+	return r.db.Exec(`UPDATE api_keys SET last_used_at = ? WHERE id = ?`, at, id).Error
+}
+
+// Usage returns the daily usage of a key since the given day, newest first
+func (r *APIKeyRepository) Usage(id uint, since time.Time) ([]models.APIKeyUsage, error) {
+	var usage []models.APIKeyUsage
+	err := r.db.Where("api_key_id = ? AND day >= ?", id, since.Format("2006-01-02")).
+		Order("day DESC, endpoint").
+		Find(&usage).Error
+	return usage, err
+}
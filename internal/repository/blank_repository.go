@@ -1,6 +1,8 @@
 package repository
 
 import (
+	"context"
+
 	"github.com/alonsoalpizar/fabricalaser/internal/database"
 	"github.com/alonsoalpizar/fabricalaser/internal/models"
 	"gorm.io/gorm"
@@ -14,6 +16,11 @@ func NewBlankRepository() *BlankRepository {
 	return &BlankRepository{db: database.Get()}
 }
 
+// WithContext retorna el repositorio sobre ctx; las consultas quedan en el tenant del request
+func (r *BlankRepository) WithContext(ctx context.Context) *BlankRepository {
+	return &BlankRepository{db: r.db.WithContext(ctx)}
+}
+
 // FindAll retorna todos los blanks activos, ordenados por destacados primero
 // y luego por número de consultas descendente.
 func (r *BlankRepository) FindAll() ([]models.Blank, error) {
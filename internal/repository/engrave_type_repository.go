@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"errors"
 
 	"github.com/alonsoalpizar/fabricalaser/internal/database"
@@ -20,6 +21,11 @@ func NewEngraveTypeRepository() *EngraveTypeRepository {
 	}
 }
 
+// WithContext retorna el repositorio sobre ctx; las consultas quedan en el tenant del request
+func (r *EngraveTypeRepository) WithContext(ctx context.Context) *EngraveTypeRepository {
+	return &EngraveTypeRepository{db: r.db.WithContext(ctx)}
+}
+
 // FindAll returns all active engrave types
 func (r *EngraveTypeRepository) FindAll() ([]models.EngraveType, error) {
 	var types []models.EngraveType
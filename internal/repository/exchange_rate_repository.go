@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/database"
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrExchangeRateNotFound = errors.New("tipo de cambio no encontrado")
+
+type ExchangeRateRepository struct {
+	db *gorm.DB
+}
+
+func NewExchangeRateRepository() *ExchangeRateRepository {
+	return &ExchangeRateRepository{
+		db: database.Get(),
+	}
+}
+
+// FindAll returns exchange rates, newest first
+func (r *ExchangeRateRepository) FindAll(limit int) ([]models.ExchangeRate, error) {
+	var rates []models.ExchangeRate
+	query := r.db.Order("rate_date DESC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	if err := query.Find(&rates).Error; err != nil {
+		return nil, err
+	}
+	return rates, nil
+}
+
+// FindByID finds an exchange rate by ID
+func (r *ExchangeRateRepository) FindByID(id uint) (*models.ExchangeRate, error) {
+	var rate models.ExchangeRate
+	if err := r.db.First(&rate, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrExchangeRateNotFound
+		}
+		return nil, err
+	}
+	return &rate, nil
+}
+
+// FindLatest returns the most recent rate for the pair on or before the given date
+func (r *ExchangeRateRepository) FindLatest(base, quote string, onOrBefore time.Time) (*models.ExchangeRate, error) {
+	var rate models.ExchangeRate
+	if err := r.db.
+		Where("base_currency = ? AND quote_currency = ? AND rate_date <= ?", base, quote, onOrBefore).
+		Order("rate_date DESC").
+		First(&rate).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrExchangeRateNotFound
+		}
+		return nil, err
+	}
+	return &rate, nil
+}
+
+// Create creates a new exchange rate
+func (r *ExchangeRateRepository) Create(rate *models.ExchangeRate) error {
+	return r.db.Create(rate).Error
+}
+
+// Update updates an existing exchange rate
+func (r *ExchangeRateRepository) Update(rate *models.ExchangeRate) error {
+	return r.db.Save(rate).Error
+}
+
+// Upsert inserts rates or overwrites the existing row for the same date and pair
+func (r *ExchangeRateRepository) Upsert(rates []models.ExchangeRate) error {
+	if len(rates) == 0 {
+		return nil
+	}
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "rate_date"}, {Name: "base_currency"}, {Name: "quote_currency"}},
+		DoUpdates: clause.AssignmentColumns([]string{"buy_rate", "sell_rate", "source", "notes", "updated_at"}),
+	}).Create(&rates).Error
+}
+
+// Delete removes an exchange rate (hard delete — quotes keep their own copy of the rate)
+func (r *ExchangeRateRepository) Delete(id uint) error {
+	return r.db.Delete(&models.ExchangeRate{}, id).Error
+}
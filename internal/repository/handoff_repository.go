@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/database"
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrHandoffNotFound = errors.New("atención no encontrada")
+
+type HandoffRepository struct {
+	db *gorm.DB
+}
+
+func NewHandoffRepository() *HandoffRepository {
+	return &HandoffRepository{
+		db: database.Get(),
+	}
+}
+
+// Create stores a new handoff
+func (r *HandoffRepository) Create(h *models.Handoff) error {
+	return r.db.Create(h).Error
+}
+
+// Update saves a handoff without touching its relations
+func (r *HandoffRepository) Update(h *models.Handoff) error {
+	return r.db.Omit(clause.Associations).Save(h).Error
+}
+
+// FindByID finds a handoff by ID
+func (r *HandoffRepository) FindByID(id uint) (*models.Handoff, error) {
+	var h models.Handoff
+	if err := r.db.Preload("Admin").First(&h, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrHandoffNotFound
+		}
+		return nil, err
+	}
+	return &h, nil
+}
+
+// FindOpenByKey finds the open handoff (pending or taken) of a conversation
+func (r *HandoffRepository) FindOpenByKey(key string) (*models.Handoff, error) {
+	var h models.Handoff
+	err := r.db.Where("conversation_key = ? AND status IN ?", key,
+		[]models.HandoffStatus{models.HandoffPending, models.HandoffHuman}).
+		First(&h).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrHandoffNotFound
+		}
+		return nil, err
+	}
+	return &h, nil
+}
+
+// FindByStatus lists handoffs with pagination, most recent activity first.
+// Without statuses it lists the open ones.
+func (r *HandoffRepository) FindByStatus(page, limit int, statuses ...models.HandoffStatus) ([]models.Handoff, int64, error) {
+	if len(statuses) == 0 {
+		statuses = []models.HandoffStatus{models.HandoffPending, models.HandoffHuman}
+	}
+	var handoffs []models.Handoff
+	var total int64
+
+	query := r.db.Model(&models.Handoff{}).Where("status IN ?", statuses)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if err := query.Preload("Admin").Order("last_activity_at DESC").Offset((page - 1) * limit).Limit(limit).Find(&handoffs).Error; err != nil {
+		return nil, 0, err
+	}
+	return handoffs, total, nil
+}
+
+// TouchOpen records activity on the open handoff of a conversation
+func (r *HandoffRepository) TouchOpen(key string, at time.Time) error {
+	return r.db.Model(&models.Handoff{}).
+		Where("conversation_key = ? AND status IN ?", key,
+			[]models.HandoffStatus{models.HandoffPending, models.HandoffHuman}).
+		Updates(map[string]interface{}{"last_activity_at": at, "updated_at": at}).Error
+}
+
+// ResolveInactive closes open handoffs with no activity since before. Returns rows closed.
+func (r *HandoffRepository) ResolveInactive(before, now time.Time) (int64, error) {
+	result := r.db.Model(&models.Handoff{}).
+		Where("status IN ? AND last_activity_at < ?",
+			[]models.HandoffStatus{models.HandoffPending, models.HandoffHuman}, before).
+		Updates(map[string]interface{}{
+			"status":          models.HandoffResolved,
+			"resolved_at":     now,
+			"resolved_reason": models.HandoffResolvedTimeout,
+			"updated_at":      now,
+		})
+	return result.RowsAffected, result.Error
+}
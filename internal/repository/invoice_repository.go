@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/database"
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrInvoiceNotFound = errors.New("factura no encontrada")
+	ErrInvoiceExists   = errors.New("la cotización ya tiene una factura vigente")
+)
+
+type InvoiceRepository struct {
+	db *gorm.DB
+}
+
+func NewInvoiceRepository() *InvoiceRepository {
+	return &InvoiceRepository{
+		db: database.Get(),
+	}
+}
+
+// CreateNumbered reserves the next consecutive number and stores the invoice of
+// quoteID built by fn in the same transaction. If fn fails, the number is not
+// consumed, so the sequence never has gaps (Hacienda requires consecutive
+// numbering). The quote row is locked first and ErrInvoiceExists is returned if
+// it already has a non-rejected invoice, so concurrent calls cannot both issue.
+func (r *InvoiceRepository) CreateNumbered(quoteID uint, sucursal, terminal int, docType string, fn func(numero int64) (*models.Invoice, error)) (*models.Invoice, error) {
+	var invoice *models.Invoice
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT id FROM quotes WHERE id = ? FOR UPDATE", quoteID).Error; err != nil {
+			return err
+		}
+		var existing int64
+		if err := tx.Model(&models.Invoice{}).
+			Where("quote_id = ? AND status <> ?", quoteID, models.InvoiceStatusRejected).
+			Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			return ErrInvoiceExists
+		}
+
+		seq := models.InvoiceSequence{Sucursal: sucursal, Terminal: terminal, DocType: docType}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&seq).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("sucursal = ? AND terminal = ? AND doc_type = ?", sucursal, terminal, docType).
+			First(&seq).Error; err != nil {
+			return err
+		}
+
+		next := seq.LastNumber + 1
+		inv, err := fn(next)
+		if err != nil {
+			return err
+		}
+		if err := tx.Create(inv).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&seq).Updates(map[string]interface{}{
+			"last_number": next,
+			"updated_at":  time.Now(),
+		}).Error; err != nil {
+			return err
+		}
+		invoice = inv
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return invoice, nil
+}
+
+// FindByID finds an invoice by ID
+func (r *InvoiceRepository) FindByID(id uint) (*models.Invoice, error) {
+	var invoice models.Invoice
+	if err := r.db.First(&invoice, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvoiceNotFound
+		}
+		return nil, err
+	}
+	return &invoice, nil
+}
+
+// FindAll returns invoices with pagination, optionally filtered by status
+func (r *InvoiceRepository) FindAll(page, limit int, status string) ([]models.Invoice, int64, error) {
+	var invoices []models.Invoice
+	var total int64
+
+	query := r.db.Model(&models.Invoice{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * limit
+	if err := query.Order("created_at DESC").Offset(offset).Limit(limit).Find(&invoices).Error; err != nil {
+		return nil, 0, err
+	}
+	return invoices, total, nil
+}
+
+// Update saves invoice changes
+func (r *InvoiceRepository) Update(invoice *models.Invoice) error {
+	return r.db.Omit(clause.Associations).Save(invoice).Error
+}
@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"github.com/alonsoalpizar/fabricalaser/internal/database"
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+	"gorm.io/gorm"
+)
+
+type KnowledgeRepository struct {
+	db *gorm.DB
+}
+
+func NewKnowledgeRepository() *KnowledgeRepository {
+	return &KnowledgeRepository{db: database.Get()}
+}
+
+// FindActive retorna los artículos activos (los que se indexan)
+func (r *KnowledgeRepository) FindActive() ([]models.KBArticle, error) {
+	var articles []models.KBArticle
+	result := r.db.Where("is_active = true").Order("id ASC").Find(&articles)
+	return articles, result.Error
+}
+
+// FindAllAdmin retorna todos los artículos (activos e inactivos), filtrando por
+// categoría si no es vacía
+func (r *KnowledgeRepository) FindAllAdmin(category string) ([]models.KBArticle, error) {
+	var articles []models.KBArticle
+	query := r.db.Order("is_active DESC, category ASC, title ASC")
+	if category != "" {
+		query = query.Where("category = ?", category)
+	}
+	result := query.Find(&articles)
+	return articles, result.Error
+}
+
+// FindByID retorna un artículo por ID (activo o no)
+func (r *KnowledgeRepository) FindByID(id uint) (*models.KBArticle, error) {
+	var article models.KBArticle
+	result := r.db.First(&article, id)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &article, nil
+}
+
+// Create inserta un nuevo artículo
+func (r *KnowledgeRepository) Create(article *models.KBArticle) error {
+	return r.db.Create(article).Error
+}
+
+// Update guarda los cambios de un artículo existente
+func (r *KnowledgeRepository) Update(article *models.KBArticle) error {
+	return r.db.Save(article).Error
+}
+
+// Delete desactiva el artículo (is_active = false): deja de indexarse
+func (r *KnowledgeRepository) Delete(id uint) error {
+	return r.db.Model(&models.KBArticle{}).Where("id = ?", id).Update("is_active", false).Error
+}
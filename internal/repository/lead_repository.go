@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/database"
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrLeadNotFound = errors.New("lead no encontrado")
+
+type LeadRepository struct {
+	db *gorm.DB
+}
+
+func NewLeadRepository() *LeadRepository {
+	return &LeadRepository{
+		db: database.Get(),
+	}
+}
+
+// Create stores a new lead
+func (r *LeadRepository) Create(l *models.Lead) error {
+	return r.db.Omit(clause.Associations).Create(l).Error
+}
+
+// Update saves a lead without touching its relations
+func (r *LeadRepository) Update(l *models.Lead) error {
+	return r.db.Omit(clause.Associations).Save(l).Error
+}
+
+// FindByID finds a lead by ID with its user and linked quotes
+func (r *LeadRepository) FindByID(id uint) (*models.Lead, error) {
+	var l models.Lead
+	err := r.db.Preload("User").
+		Preload("Quotes", func(db *gorm.DB) *gorm.DB { return db.Order("quotes.created_at DESC") }).
+		Preload("Drafts", func(db *gorm.DB) *gorm.DB { return db.Order("updated_at DESC") }).
+		First(&l, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrLeadNotFound
+		}
+		return nil, err
+	}
+	return &l, nil
+}
+
+// FindByKey finds the lead of a conversation
+func (r *LeadRepository) FindByKey(key string) (*models.Lead, error) {
+	var l models.Lead
+	if err := r.db.Where("conversation_key = ?", key).First(&l).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrLeadNotFound
+		}
+		return nil, err
+	}
+	return &l, nil
+}
+
+// FindByUserID lists the leads linked to a registered user
+func (r *LeadRepository) FindByUserID(userID uint) ([]models.Lead, error) {
+	var leads []models.Lead
+	err := r.db.Where("user_id = ?", userID).Order("last_contact_at DESC").Find(&leads).Error
+	return leads, err
+}
+
+// FindAll lists leads with pagination, most recent contact first. Empty filters are ignored.
+func (r *LeadRepository) FindAll(page, limit int, stage models.LeadStage, channel string) ([]models.Lead, int64, error) {
+	var leads []models.Lead
+	var total int64
+
+	query := r.db.Model(&models.Lead{})
+	if stage != "" {
+		query = query.Where("stage = ?", stage)
+	}
+	if channel != "" {
+		query = query.Where("channel = ?", channel)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if err := query.Preload("User").Order("last_contact_at DESC").Offset((page - 1) * limit).Limit(limit).Find(&leads).Error; err != nil {
+		return nil, 0, err
+	}
+	return leads, total, nil
+}
+
+// LinkQuote links a quote to a lead (idempotent)
+func (r *LeadRepository) LinkQuote(leadID, quoteID uint) error {
+	return r.db.Exec(
+		"INSERT INTO lead_quotes (lead_id, quote_id, created_at) VALUES (?, ?, NOW()) ON CONFLICT DO NOTHING",
+		leadID, quoteID,
+	).Error
+}
+
+// Funnel counts leads by week of first contact (Costa Rica time) and channel, and
+// how many of them reached each milestone. Weeks start on Monday.
+func (r *LeadRepository) Funnel(from, to time.Time, channel string) ([]models.LeadFunnelRow, error) {
+	var rows []models.LeadFunnelRow
+	query := r.db.Model(&models.Lead{}).
+		Select(`date_trunc('week', first_contact_at AT TIME ZONE 'America/Costa_Rica') AS week,
+			channel,
+			COUNT(*) AS contacts,
+			COUNT(quoted_at) AS quoted,
+			COUNT(escalated_at) AS escalated,
+			COUNT(converted_at) AS converted`).
+		Where("first_contact_at >= ? AND first_contact_at < ?", from, to)
+	if channel != "" {
+		query = query.Where("channel = ?", channel)
+	}
+	err := query.Group("week, channel").Order("week, channel").Scan(&rows).Error
+	return rows, err
+}
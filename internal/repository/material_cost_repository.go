@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"errors"
 
 	"github.com/alonsoalpizar/fabricalaser/internal/database"
@@ -20,6 +21,11 @@ func NewMaterialCostRepository() *MaterialCostRepository {
 	}
 }
 
+// WithContext retorna el repositorio sobre ctx; las consultas quedan en el tenant del request
+func (r *MaterialCostRepository) WithContext(ctx context.Context) *MaterialCostRepository {
+	return &MaterialCostRepository{db: r.db.WithContext(ctx)}
+}
+
 // FindAll returns all active material costs with material info
 func (r *MaterialCostRepository) FindAll() ([]models.MaterialCost, error) {
 	var costs []models.MaterialCost
@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"errors"
 
 	"github.com/alonsoalpizar/fabricalaser/internal/database"
@@ -20,6 +21,11 @@ func NewMaterialRepository() *MaterialRepository {
 	}
 }
 
+// WithContext retorna el repositorio sobre ctx; las consultas quedan en el tenant del request
+func (r *MaterialRepository) WithContext(ctx context.Context) *MaterialRepository {
+	return &MaterialRepository{db: r.db.WithContext(ctx)}
+}
+
 // FindAll returns all active materials
 func (r *MaterialRepository) FindAll() ([]models.Material, error) {
 	var materials []models.Material
@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/database"
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+	"github.com/alonsoalpizar/fabricalaser/internal/tenant"
+	"gorm.io/gorm"
+)
+
+// MemoryCandidate es un contacto con turnos archivados que todavía no entraron
+// en su memoria
+type MemoryCandidate struct {
+	Phone       string    `json:"phone"`
+	FirstUnseen time.Time `json:"first_unseen"`
+	LastMessage time.Time `json:"last_message"`
+}
+
+// MemoryTurn es un turno archivado de whatsapp_conversations
+type MemoryTurn struct {
+	Role      string    `json:"role"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type MemoryRepository struct {
+	db       *gorm.DB
+	tenantID uint
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{db: database.Get(), tenantID: models.DefaultTenantID}
+}
+
+// WithContext retorna el repositorio sobre el tenant de ctx. customer_memories
+// la filtra el plugin de tenant; las consultas sobre whatsapp_conversations son
+// SQL directo y filtran tenant_id a mano.
+func (r *MemoryRepository) WithContext(ctx context.Context) *MemoryRepository {
+	return &MemoryRepository{db: r.db.WithContext(ctx), tenantID: tenant.ID(ctx)}
+}
+
+// FindByKey retorna la memoria de un contacto; nil si no tiene
+func (r *MemoryRepository) FindByKey(key string) (*models.CustomerMemory, error) {
+	var m models.CustomerMemory
+	result := r.db.Where("conversation_key = ?", key).First(&m)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &m, nil
+}
+
+// List retorna las memorias más recientes; q filtra por clave o contenido
+func (r *MemoryRepository) List(q string, limit, offset int) ([]models.CustomerMemory, int64, error) {
+	query := r.db.Model(&models.CustomerMemory{})
+	if q != "" {
+		like := "%" + q + "%"
+		query = query.Where("conversation_key ILIKE ? OR summary ILIKE ? OR last_session ILIKE ?", like, like, like)
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var memories []models.CustomerMemory
+	result := query.Order("updated_at DESC").Limit(limit).Offset(offset).Find(&memories)
+	return memories, total, result.Error
+}
+
+// Save inserta o actualiza la memoria de un contacto
+func (r *MemoryRepository) Save(m *models.CustomerMemory) error {
+	return r.db.Save(m).Error
+}
+
+// PendingCandidates retorna los contactos de WhatsApp y Telegram cuya sesión
+// terminó (sin mensajes desde idleBefore, o con turnos anteriores a
+// dayStart) y que tienen turnos más nuevos que su memoria. El chat web queda
+// fuera: el cliente ya está identificado por su cuenta.
+func (r *MemoryRepository) PendingCandidates(idleBefore, dayStart time.Time, limit int) ([]MemoryCandidate, error) {
+	var results []MemoryCandidate
+	sql := `
+		SELECT wc.phone,
+		       MIN(wc.created_at) AS first_unseen,
+		       MAX(wc.created_at) AS last_message
+		FROM whatsapp_conversations wc
+		LEFT JOIN customer_memories cm ON cm.tenant_id = wc.tenant_id AND cm.conversation_key = wc.phone
+		WHERE wc.tenant_id = ? AND wc.phone NOT LIKE 'web:%'
+		  AND (cm.id IS NULL OR (NOT cm.opted_out AND wc.created_at > cm.summarized_through))
+		GROUP BY wc.phone
+		HAVING MAX(wc.created_at) < ? OR MIN(wc.created_at) < ?
+		ORDER BY MAX(wc.created_at) ASC
+		LIMIT ?
+	`
+	if err := r.db.Raw(sql, r.tenantID, idleBefore, dayStart, limit).Scan(&results).Error; err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// TurnsSince retorna hasta limit turnos posteriores a since, hasta until
+// inclusive, en orden cronológico (los más recientes si hay más)
+func (r *MemoryRepository) TurnsSince(key string, since, until time.Time, limit int) ([]MemoryTurn, error) {
+	var results []MemoryTurn
+	sql := `
+		SELECT role, content, created_at FROM (
+			SELECT role, content, created_at FROM whatsapp_conversations
+			WHERE tenant_id = ? AND phone = ? AND created_at > ? AND created_at <= ?
+			ORDER BY created_at DESC
+			LIMIT ?
+		) t ORDER BY created_at ASC
+	`
+	if err := r.db.Raw(sql, r.tenantID, key, since, until, limit).Scan(&results).Error; err != nil {
+		return nil, err
+	}
+	return results, nil
+}
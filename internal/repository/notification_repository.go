@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/database"
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+	"github.com/alonsoalpizar/fabricalaser/internal/tenant"
+	"gorm.io/gorm"
+)
+
+var ErrNotificationNotFound = errors.New("notificación no encontrada")
+
+type NotificationRepository struct {
+	db *gorm.DB
+}
+
+func NewNotificationRepository() *NotificationRepository {
+	return &NotificationRepository{
+		db: database.Get(),
+	}
+}
+
+// WithContext retorna el repositorio sobre ctx; las consultas quedan en el tenant del request
+func (r *NotificationRepository) WithContext(ctx context.Context) *NotificationRepository {
+	return &NotificationRepository{db: r.db.WithContext(ctx)}
+}
+
+// Create stores a new delivery
+func (r *NotificationRepository) Create(d *models.NotificationDelivery) error {
+	return r.db.Create(d).Error
+}
+
+// Update saves a delivery
+func (r *NotificationRepository) Update(d *models.NotificationDelivery) error {
+	return r.db.Save(d).Error
+}
+
+// FindByID finds a delivery by ID
+func (r *NotificationRepository) FindByID(id uint) (*models.NotificationDelivery, error) {
+	var d models.NotificationDelivery
+	if err := r.db.First(&d, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotificationNotFound
+		}
+		return nil, err
+	}
+	return &d, nil
+}
+
+// FindAll lists deliveries with pagination and optional status / user filters
+func (r *NotificationRepository) FindAll(page, limit int, status string, userID uint) ([]models.NotificationDelivery, int64, error) {
+	var deliveries []models.NotificationDelivery
+	var total int64
+
+	query := r.db.Model(&models.NotificationDelivery{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if userID > 0 {
+		query = query.Where("user_id = ?", userID)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Order("created_at DESC").Offset((page - 1) * limit).Limit(limit).Find(&deliveries).Error
+	return deliveries, total, err
+}
+
+// FindDue returns the pending deliveries of every tenant whose next attempt is due
+func (r *NotificationRepository) FindDue(now time.Time, limit int) ([]models.NotificationDelivery, error) {
+	var deliveries []models.NotificationDelivery
+	err := tenant.AllTenants(r.db).Where("status = ? AND next_attempt_at <= ?", models.NotificationPending, now).
+		Order("next_attempt_at ASC").Limit(limit).Find(&deliveries).Error
+	return deliveries, err
+}
+
+// ExistsForQuote reports whether an event was already notified for a quote
+func (r *NotificationRepository) ExistsForQuote(eventType string, quoteID uint) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.NotificationDelivery{}).
+		Where("event_type = ? AND quote_id = ?", eventType, quoteID).
+		Count(&count).Error
+	return count > 0, err
+}
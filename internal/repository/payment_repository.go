@@ -0,0 +1,314 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/database"
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrPaymentIntentNotFound      = errors.New("cobro no encontrado")
+	ErrPaymentTransactionNotFound = errors.New("movimiento no encontrado")
+	ErrPaymentIntentNotPending    = errors.New("el cobro no está pendiente")
+	ErrPaymentTransactionMatched  = errors.New("el movimiento ya está conciliado")
+)
+
+type PaymentRepository struct {
+	db *gorm.DB
+}
+
+func NewPaymentRepository() *PaymentRepository {
+	return &PaymentRepository{
+		db: database.Get(),
+	}
+}
+
+// CreateIntent stores a new payment intent
+func (r *PaymentRepository) CreateIntent(intent *models.PaymentIntent) error {
+	return r.db.Create(intent).Error
+}
+
+// UpdateIntent saves an intent without touching its relations
+func (r *PaymentRepository) UpdateIntent(intent *models.PaymentIntent) error {
+	return r.db.Omit(clause.Associations).Save(intent).Error
+}
+
+// FindIntentByID finds an intent by ID
+func (r *PaymentRepository) FindIntentByID(id uint) (*models.PaymentIntent, error) {
+	var intent models.PaymentIntent
+	if err := r.db.First(&intent, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPaymentIntentNotFound
+		}
+		return nil, err
+	}
+	return &intent, nil
+}
+
+// FindIntentByReference finds an intent by its FL-XXXXXX reference
+func (r *PaymentRepository) FindIntentByReference(reference string) (*models.PaymentIntent, error) {
+	var intent models.PaymentIntent
+	if err := r.db.Where("reference = ?", reference).First(&intent).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPaymentIntentNotFound
+		}
+		return nil, err
+	}
+	return &intent, nil
+}
+
+// FindIntentsByQuoteID returns all intents of an order, oldest first
+func (r *PaymentRepository) FindIntentsByQuoteID(quoteID uint) ([]models.PaymentIntent, error) {
+	var intents []models.PaymentIntent
+	err := r.db.Where("quote_id = ?", quoteID).Order("created_at ASC").Find(&intents).Error
+	return intents, err
+}
+
+// FindPendingIntents returns pending intents, optionally filtered by provider
+func (r *PaymentRepository) FindPendingIntents(provider string) ([]models.PaymentIntent, error) {
+	var intents []models.PaymentIntent
+	query := r.db.Where("status = ?", models.PaymentIntentPending)
+	if provider != "" {
+		query = query.Where("provider = ?", provider)
+	}
+	err := query.Order("created_at ASC").Find(&intents).Error
+	return intents, err
+}
+
+// FindAllIntents lists intents with pagination and optional status filter
+func (r *PaymentRepository) FindAllIntents(page, limit int, status string) ([]models.PaymentIntent, int64, error) {
+	var intents []models.PaymentIntent
+	var total int64
+
+	query := r.db.Model(&models.PaymentIntent{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Order("created_at DESC").Offset((page - 1) * limit).Limit(limit).Find(&intents).Error
+	return intents, total, err
+}
+
+// ExpireIntents marks pending intents past their expiration as expired
+func (r *PaymentRepository) ExpireIntents(now time.Time) (int64, error) {
+	result := r.db.Model(&models.PaymentIntent{}).
+		Where("status = ? AND expires_at IS NOT NULL AND expires_at < ? AND amount_received = 0",
+			models.PaymentIntentPending, now).
+		Updates(map[string]interface{}{
+			"status":     models.PaymentIntentExpired,
+			"updated_at": now,
+		})
+	return result.RowsAffected, result.Error
+}
+
+// CreateTransaction stores an imported movement. Returns false if the fingerprint
+// already exists (movimiento importado antes).
+func (r *PaymentRepository) CreateTransaction(tx *models.PaymentTransaction) (bool, error) {
+	result := r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "fingerprint"}},
+		DoNothing: true,
+	}).Create(tx)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// FindTransactionByID finds a movement by ID
+func (r *PaymentRepository) FindTransactionByID(id uint) (*models.PaymentTransaction, error) {
+	var tx models.PaymentTransaction
+	if err := r.db.First(&tx, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPaymentTransactionNotFound
+		}
+		return nil, err
+	}
+	return &tx, nil
+}
+
+// FindUnmatchedTransactions returns movements still waiting for an intent
+func (r *PaymentRepository) FindUnmatchedTransactions() ([]models.PaymentTransaction, error) {
+	var txs []models.PaymentTransaction
+	err := r.db.Where("status = ?", models.PaymentTxUnmatched).Order("occurred_at ASC").Find(&txs).Error
+	return txs, err
+}
+
+// FindAllTransactions lists movements with pagination and optional status filter
+func (r *PaymentRepository) FindAllTransactions(page, limit int, status string) ([]models.PaymentTransaction, int64, error) {
+	var txs []models.PaymentTransaction
+	var total int64
+
+	query := r.db.Model(&models.PaymentTransaction{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Order("occurred_at DESC").Offset((page - 1) * limit).Limit(limit).Find(&txs).Error
+	return txs, total, err
+}
+
+// UpdateTransaction saves a movement
+func (r *PaymentRepository) UpdateTransaction(tx *models.PaymentTransaction) error {
+	return r.db.Save(tx).Error
+}
+
+// ApplyPayment links the movement to the intent, adds the amount received and
+// recomputes the order payment status, all in one transaction. Both rows are
+// locked and re-checked first: a movement already matched or an intent that is
+// no longer pending (paid, expired, canceled) is rejected, so concurrent
+// matches cannot count the same money twice.
+func (r *PaymentRepository) ApplyPayment(txn *models.PaymentTransaction, intentID uint, rule string, tolerance float64) (*models.PaymentIntent, error) {
+	var intent models.PaymentIntent
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var current models.PaymentTransaction
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&current, txn.ID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrPaymentTransactionNotFound
+			}
+			return err
+		}
+		if current.Status == models.PaymentTxMatched {
+			return ErrPaymentTransactionMatched
+		}
+
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&intent, intentID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrPaymentIntentNotFound
+			}
+			return err
+		}
+		if intent.Status != models.PaymentIntentPending {
+			return ErrPaymentIntentNotPending
+		}
+
+		intent.AmountReceived += txn.Amount
+		if intent.AmountReceived+tolerance >= intent.Amount {
+			intent.Status = models.PaymentIntentSucceeded
+			paidAt := txn.OccurredAt
+			intent.PaidAt = &paidAt
+		}
+		if intent.PayerPhone == nil && txn.PayerPhone != nil {
+			intent.PayerPhone = txn.PayerPhone
+		}
+		if err := tx.Omit(clause.Associations).Save(&intent).Error; err != nil {
+			return err
+		}
+
+		txn.Status = models.PaymentTxMatched
+		txn.IntentID = &intent.ID
+		txn.MatchRule = &rule
+		if err := tx.Save(txn).Error; err != nil {
+			return err
+		}
+
+		return syncQuotePayment(tx, intent.QuoteID, tolerance)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &intent, nil
+}
+
+// CreateRefund stores a refund and, if completed, discounts it from the order
+func (r *PaymentRepository) CreateRefund(refund *models.PaymentRefund, tolerance float64) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var intent models.PaymentIntent
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&intent, refund.IntentID).Error; err != nil {
+			return err
+		}
+		// Reservar el monto aunque la devolución siga pendiente, para no devolver dos veces
+		intent.AmountRefunded += refund.Amount
+		if err := tx.Omit(clause.Associations).Save(&intent).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(refund).Error; err != nil {
+			return err
+		}
+		return syncQuotePayment(tx, intent.QuoteID, tolerance)
+	})
+}
+
+// FindRefundByID finds a refund by ID
+func (r *PaymentRepository) FindRefundByID(id uint) (*models.PaymentRefund, error) {
+	var refund models.PaymentRefund
+	if err := r.db.First(&refund, id).Error; err != nil {
+		return nil, err
+	}
+	return &refund, nil
+}
+
+// UpdateRefund saves a refund. A failed refund releases the reserved amount.
+func (r *PaymentRepository) UpdateRefund(refund *models.PaymentRefund, tolerance float64) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if refund.Status == models.RefundFailed {
+			var intent models.PaymentIntent
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&intent, refund.IntentID).Error; err != nil {
+				return err
+			}
+			intent.AmountRefunded = max(intent.AmountRefunded-refund.Amount, 0)
+			if err := tx.Omit(clause.Associations).Save(&intent).Error; err != nil {
+				return err
+			}
+			if err := syncQuotePayment(tx, intent.QuoteID, tolerance); err != nil {
+				return err
+			}
+		}
+		return tx.Save(refund).Error
+	})
+}
+
+// FindRefundsByIntentID returns the refunds of an intent
+func (r *PaymentRepository) FindRefundsByIntentID(intentID uint) ([]models.PaymentRefund, error) {
+	var refunds []models.PaymentRefund
+	err := r.db.Where("intent_id = ?", intentID).Order("created_at ASC").Find(&refunds).Error
+	return refunds, err
+}
+
+// syncQuotePayment recomputes quotes.amount_paid / payment_status from its intents
+func syncQuotePayment(tx *gorm.DB, quoteID uint, tolerance float64) error {
+	var quote models.Quote
+	if err := tx.Select("id", "price_total_with_tax", "price_final").First(&quote, quoteID).Error; err != nil {
+		return err
+	}
+
+	var sums struct {
+		Received float64
+		Refunded float64
+	}
+	if err := tx.Model(&models.PaymentIntent{}).
+		Select("COALESCE(SUM(amount_received), 0) AS received, COALESCE(SUM(amount_refunded), 0) AS refunded").
+		Where("quote_id = ?", quoteID).
+		Scan(&sums).Error; err != nil {
+		return err
+	}
+
+	total := quote.PriceTotalWithTax
+	if total <= 0 {
+		total = quote.PriceFinal
+	}
+	paid := max(sums.Received-sums.Refunded, 0)
+
+	status := models.QuotePaymentUnpaid
+	switch {
+	case paid+tolerance >= total && total > 0:
+		status = models.QuotePaymentPaid
+	case paid > 0:
+		status = models.QuotePaymentPartial
+	case sums.Refunded > 0:
+		status = models.QuotePaymentRefunded
+	}
+
+	return tx.Model(&models.Quote{}).Where("id = ?", quoteID).Updates(map[string]interface{}{
+		"amount_paid":    paid,
+		"payment_status": status,
+		"updated_at":     time.Now(),
+	}).Error
+}
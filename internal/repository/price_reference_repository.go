@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"errors"
 
 	"github.com/alonsoalpizar/fabricalaser/internal/database"
@@ -20,6 +21,11 @@ func NewPriceReferenceRepository() *PriceReferenceRepository {
 	}
 }
 
+// WithContext retorna el repositorio sobre ctx; las consultas quedan en el tenant del request
+func (r *PriceReferenceRepository) WithContext(ctx context.Context) *PriceReferenceRepository {
+	return &PriceReferenceRepository{db: r.db.WithContext(ctx)}
+}
+
 // FindAll returns all active price references
 func (r *PriceReferenceRepository) FindAll() ([]models.PriceReference, error) {
 	var refs []models.PriceReference
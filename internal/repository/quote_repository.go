@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"time"
 
 	"github.com/alonsoalpizar/fabricalaser/internal/database"
@@ -20,9 +21,14 @@ func NewQuoteRepository() *QuoteRepository {
 	}
 }
 
+// WithContext retorna el repositorio sobre ctx; las consultas quedan en el tenant del request
+func (r *QuoteRepository) WithContext(ctx context.Context) *QuoteRepository {
+	return &QuoteRepository{db: r.db.WithContext(ctx)}
+}
+
 // Create saves a new quote
 func (r *QuoteRepository) Create(quote *models.Quote) error {
-	return r.db.Create(quote).Error
+	return r.omitUnset(quote).Create(quote).Error
 }
 
 // FindByID retrieves a quote by ID
@@ -162,6 +168,15 @@ func (r *QuoteRepository) ExpireOldQuotes() (int64, error) {
 	return result.RowsAffected, result.Error
 }
 
+// FindExpiring returns approved quotes whose validity ends between from and to
+func (r *QuoteRepository) FindExpiring(from, to time.Time) ([]models.Quote, error) {
+	var quotes []models.Quote
+	err := r.db.Where("status IN (?, ?) AND valid_until > ? AND valid_until <= ?",
+		models.QuoteStatusAutoApproved, models.QuoteStatusApproved, from, to).
+		Find(&quotes).Error
+	return quotes, err
+}
+
 // Delete removes a quote
 func (r *QuoteRepository) Delete(id uint) error {
 	return r.db.Delete(&models.Quote{}, id).Error
@@ -220,5 +235,33 @@ func (r *QuoteRepository) ListAllAdmin(limit, offset int, status string, sortOrd
 
 // Update updates a quote
 func (r *QuoteRepository) Update(quote *models.Quote) error {
-	return r.db.Save(quote).Error
+	return r.omitUnset(quote).Save(quote).Error
+}
+
+// FindDraftByLead returns the open bot draft of a lead
+func (r *QuoteRepository) FindDraftByLead(leadID uint) (*models.Quote, error) {
+	var quote models.Quote
+	err := r.db.Where("lead_id = ? AND source = ? AND status = ?", leadID, models.QuoteSourceBot, models.QuoteStatusDraft).
+		Order("updated_at DESC").
+		First(&quote).Error
+	if err != nil {
+		return nil, err
+	}
+	return &quote, nil
+}
+
+// omitUnset leaves user_id / svg_analysis_id as NULL while a bot draft has no
+// account or file (0 would break the foreign keys)
+func (r *QuoteRepository) omitUnset(quote *models.Quote) *gorm.DB {
+	var omit []string
+	if quote.UserID == 0 {
+		omit = append(omit, "UserID")
+	}
+	if quote.SVGAnalysisID == 0 {
+		omit = append(omit, "SVGAnalysisID")
+	}
+	if len(omit) == 0 {
+		return r.db
+	}
+	return r.db.Omit(omit...)
 }
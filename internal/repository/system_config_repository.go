@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"errors"
 
 	"github.com/alonsoalpizar/fabricalaser/internal/database"
@@ -20,6 +21,11 @@ func NewSystemConfigRepository() *SystemConfigRepository {
 	}
 }
 
+// WithContext retorna el repositorio sobre ctx; las consultas quedan en el tenant del request
+func (r *SystemConfigRepository) WithContext(ctx context.Context) *SystemConfigRepository {
+	return &SystemConfigRepository{db: r.db.WithContext(ctx)}
+}
+
 // FindAll returns all active system configs
 func (r *SystemConfigRepository) FindAll() ([]models.SystemConfig, error) {
 	var configs []models.SystemConfig
@@ -1,7 +1,10 @@
 package repository
 
 import (
+	"context"
 	"errors"
+	"slices"
+	"sort"
 
 	"github.com/alonsoalpizar/fabricalaser/internal/database"
 	"github.com/alonsoalpizar/fabricalaser/internal/models"
@@ -20,6 +23,11 @@ func NewTechMaterialSpeedRepository() *TechMaterialSpeedRepository {
 	}
 }
 
+// WithContext retorna el repositorio sobre ctx; las consultas quedan en el tenant del request
+func (r *TechMaterialSpeedRepository) WithContext(ctx context.Context) *TechMaterialSpeedRepository {
+	return &TechMaterialSpeedRepository{db: r.db.WithContext(ctx)}
+}
+
 // FindAll returns all active tech material speeds with technology and material info
 func (r *TechMaterialSpeedRepository) FindAll() ([]models.TechMaterialSpeed, error) {
 	var speeds []models.TechMaterialSpeed
@@ -90,6 +98,58 @@ func (r *TechMaterialSpeedRepository) FindCompatibleTechnologies(materialID uint
 	return speeds, nil
 }
 
+// CompatibleTechnology groups the compatible speeds of a material by technology
+type CompatibleTechnology struct {
+	ID          uint      `json:"id"`
+	Name        string    `json:"name"`
+	Code        string    `json:"code"`
+	Thicknesses []float64 `json:"thicknesses"`
+	CanCut      bool      `json:"can_cut"`
+	CanEngrave  bool      `json:"can_engrave"`
+}
+
+// FindCompatibleOptions returns the technologies that can work a material, ordered by ID,
+// with their available thicknesses and capabilities
+func (r *TechMaterialSpeedRepository) FindCompatibleOptions(materialID uint, thickness float64) ([]CompatibleTechnology, error) {
+	speeds, err := r.FindCompatibleTechnologies(materialID, thickness)
+	if err != nil {
+		return nil, err
+	}
+
+	var techs []CompatibleTechnology
+	index := make(map[uint]int)
+	for _, s := range speeds {
+		i, exists := index[s.TechnologyID]
+		if !exists {
+			i = len(techs)
+			index[s.TechnologyID] = i
+			techs = append(techs, CompatibleTechnology{
+				ID:          s.TechnologyID,
+				Name:        s.Technology.Name,
+				Code:        s.Technology.Code,
+				Thicknesses: []float64{},
+			})
+		}
+		tech := &techs[i]
+
+		if !slices.Contains(tech.Thicknesses, s.Thickness) {
+			tech.Thicknesses = append(tech.Thicknesses, s.Thickness)
+		}
+		if s.CutSpeedMmMin != nil && *s.CutSpeedMmMin > 0 {
+			tech.CanCut = true
+		}
+		if s.EngraveSpeedMmMin != nil && *s.EngraveSpeedMmMin > 0 {
+			tech.CanEngrave = true
+		}
+	}
+
+	sort.Slice(techs, func(a, b int) bool { return techs[a].ID < techs[b].ID })
+	for i := range techs {
+		sort.Float64s(techs[i].Thicknesses)
+	}
+	return techs, nil
+}
+
 // Create creates a new tech material speed
 func (r *TechMaterialSpeedRepository) Create(speed *models.TechMaterialSpeed) error {
 	return r.db.Create(speed).Error
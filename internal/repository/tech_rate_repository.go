@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"errors"
 
 	"github.com/alonsoalpizar/fabricalaser/internal/database"
@@ -20,6 +21,11 @@ func NewTechRateRepository() *TechRateRepository {
 	}
 }
 
+// WithContext retorna el repositorio sobre ctx; las consultas quedan en el tenant del request
+func (r *TechRateRepository) WithContext(ctx context.Context) *TechRateRepository {
+	return &TechRateRepository{db: r.db.WithContext(ctx)}
+}
+
 // FindAll returns all active tech rates with technology info
 func (r *TechRateRepository) FindAll() ([]models.TechRate, error) {
 	var rates []models.TechRate
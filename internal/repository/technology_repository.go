@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"errors"
 
 	"github.com/alonsoalpizar/fabricalaser/internal/database"
@@ -20,6 +21,11 @@ func NewTechnologyRepository() *TechnologyRepository {
 	}
 }
 
+// WithContext retorna el repositorio sobre ctx; las consultas quedan en el tenant del request
+func (r *TechnologyRepository) WithContext(ctx context.Context) *TechnologyRepository {
+	return &TechnologyRepository{db: r.db.WithContext(ctx)}
+}
+
 // FindAll returns all active technologies
 func (r *TechnologyRepository) FindAll() ([]models.Technology, error) {
 	var technologies []models.Technology
@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"errors"
 	"time"
 
@@ -25,6 +26,11 @@ func NewUserRepository() *UserRepository {
 	}
 }
 
+// WithContext retorna el repositorio sobre ctx; las consultas quedan en el tenant del request
+func (r *UserRepository) WithContext(ctx context.Context) *UserRepository {
+	return &UserRepository{db: r.db.WithContext(ctx)}
+}
+
 // FindByID finds a user by ID
 func (r *UserRepository) FindByID(id uint) (*models.User, error) {
 	var user models.User
@@ -73,6 +79,30 @@ func (r *UserRepository) FindByEmail(email string) (*models.User, error) {
 	return &user, nil
 }
 
+// FindByTelefono finds an active user with password by telefono (8 dígitos CR)
+func (r *UserRepository) FindByTelefono(telefono string) (*models.User, error) {
+	var user models.User
+	if err := r.db.Where("telefono = ? AND activo = true AND password_hash IS NOT NULL", telefono).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// FindByTelegramChatID finds an active user that linked the Telegram chat for notifications
+func (r *UserRepository) FindByTelegramChatID(chatID int64) (*models.User, error) {
+	var user models.User
+	if err := r.db.Where("telegram_chat_id = ? AND activo = true", chatID).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
 // ExistsByCedulaWithPassword checks if a user with password exists for the given cedula
 func (r *UserRepository) ExistsByCedulaWithPassword(cedula string) (bool, error) {
 	var count int64
@@ -141,6 +171,11 @@ func (r *UserRepository) IncrementQuotesUsed(id uint) error {
 		UpdateColumn("quotes_used", gorm.Expr("quotes_used + ?", 1)).Error
 }
 
+// UpdateQuoteQuota updates only the quote_quota field for a user (-1 = unlimited)
+func (r *UserRepository) UpdateQuoteQuota(id uint, quota int) error {
+	return r.db.Model(&models.User{}).Where("id = ?", id).Update("quote_quota", quota).Error
+}
+
 // UpdateMetadata updates only the metadata field for a user
 func (r *UserRepository) UpdateMetadata(id uint, metadata interface{}) error {
 	return r.db.Model(&models.User{}).Where("id = ?", id).Update("metadata", metadata).Error
@@ -176,6 +211,32 @@ func (r *UserRepository) UpdateProfile(id uint, email, telefono, direccion, prov
 	return r.db.Model(&models.User{}).Where("id = ?", id).Updates(updates).Error
 }
 
+// UpdateNotificationPrefs updates the notification channel, opt-out and Telegram chat.
+// telegramChatID = 0 clears the chat.
+func (r *UserRepository) UpdateNotificationPrefs(id uint, channel *string, optOut *bool, telegramChatID *int64) error {
+	updates := make(map[string]interface{})
+
+	if channel != nil {
+		updates["notif_channel"] = *channel
+	}
+	if optOut != nil {
+		updates["notif_opt_out"] = *optOut
+	}
+	if telegramChatID != nil {
+		if *telegramChatID == 0 {
+			updates["telegram_chat_id"] = nil
+		} else {
+			updates["telegram_chat_id"] = *telegramChatID
+		}
+	}
+
+	if len(updates) == 0 {
+		return nil
+	}
+
+	return r.db.Model(&models.User{}).Where("id = ?", id).Updates(updates).Error
+}
+
 // ListAll returns all users with pagination and filters (for admin)
 func (r *UserRepository) ListAll(limit, offset int, search, role string, isActive *bool) ([]models.User, int64, error) {
 	var users []models.User
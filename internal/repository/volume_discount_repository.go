@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"errors"
 
 	"github.com/alonsoalpizar/fabricalaser/internal/database"
@@ -20,6 +21,11 @@ func NewVolumeDiscountRepository() *VolumeDiscountRepository {
 	}
 }
 
+// WithContext retorna el repositorio sobre ctx; las consultas quedan en el tenant del request
+func (r *VolumeDiscountRepository) WithContext(ctx context.Context) *VolumeDiscountRepository {
+	return &VolumeDiscountRepository{db: r.db.WithContext(ctx)}
+}
+
 // FindAll returns all active volume discounts ordered by min_qty
 func (r *VolumeDiscountRepository) FindAll() ([]models.VolumeDiscount, error) {
 	var discounts []models.VolumeDiscount
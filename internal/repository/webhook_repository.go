@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/database"
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrWebhookNotFound         = errors.New("webhook no encontrado")
+	ErrWebhookDeliveryNotFound = errors.New("entrega de webhook no encontrada")
+)
+
+type WebhookRepository struct {
+	db *gorm.DB
+}
+
+func NewWebhookRepository() *WebhookRepository {
+	return &WebhookRepository{db: database.Get()}
+}
+
+// WithContext retorna el repositorio sobre ctx; las consultas quedan en el tenant del request
+func (r *WebhookRepository) WithContext(ctx context.Context) *WebhookRepository {
+	return &WebhookRepository{db: r.db.WithContext(ctx)}
+}
+
+// ==================== ENDPOINTS ====================
+
+// CreateEndpoint stores a new subscription
+func (r *WebhookRepository) CreateEndpoint(e *models.WebhookEndpoint) error {
+	return r.db.Omit(clause.Associations).Create(e).Error
+}
+
+// UpdateEndpoint saves a subscription without touching its relations
+func (r *WebhookRepository) UpdateEndpoint(e *models.WebhookEndpoint) error {
+	return r.db.Omit(clause.Associations).Save(e).Error
+}
+
+// DeleteEndpoint deletes a subscription and its deliveries
+func (r *WebhookRepository) DeleteEndpoint(id uint) error {
+	res := r.db.Delete(&models.WebhookEndpoint{}, id)
+	if res.Error == nil && res.RowsAffected == 0 {
+		return ErrWebhookNotFound
+	}
+	return res.Error
+}
+
+// FindEndpoint finds a subscription of the tenant
+func (r *WebhookRepository) FindEndpoint(id uint) (*models.WebhookEndpoint, error) {
+	var e models.WebhookEndpoint
+	if err := r.db.First(&e, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrWebhookNotFound
+		}
+		return nil, err
+	}
+	return &e, nil
+}
+
+// FindEndpoints lists the tenant's subscriptions with their API key
+func (r *WebhookRepository) FindEndpoints() ([]models.WebhookEndpoint, error) {
+	var endpoints []models.WebhookEndpoint
+	err := r.db.Preload("APIKey").Order("created_at DESC").Find(&endpoints).Error
+	return endpoints, err
+}
+
+// FindActiveEndpoints returns the tenant's active subscriptions with their API key
+func (r *WebhookRepository) FindActiveEndpoints() ([]models.WebhookEndpoint, error) {
+	var endpoints []models.WebhookEndpoint
+	err := r.db.Preload("APIKey").Where("is_active = true").Order("id").Find(&endpoints).Error
+	return endpoints, err
+}
+
+// ==================== DELIVERIES ====================
+
+// CreateDelivery stores a new delivery
+func (r *WebhookRepository) CreateDelivery(d *models.WebhookDelivery) error {
+	return r.db.Create(d).Error
+}
+
+// UpdateDelivery saves a delivery
+func (r *WebhookRepository) UpdateDelivery(d *models.WebhookDelivery) error {
+	return r.db.Save(d).Error
+}
+
+// FindDelivery finds a delivery of the tenant
+func (r *WebhookRepository) FindDelivery(id uint) (*models.WebhookDelivery, error) {
+	var d models.WebhookDelivery
+	if err := r.db.First(&d, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrWebhookDeliveryNotFound
+		}
+		return nil, err
+	}
+	return &d, nil
+}
+
+// FindDeliveries lists deliveries with pagination and optional endpoint / status filters
+func (r *WebhookRepository) FindDeliveries(page, limit int, endpointID uint, status string) ([]models.WebhookDelivery, int64, error) {
+	var deliveries []models.WebhookDelivery
+	var total int64
+
+	query := r.db.Model(&models.WebhookDelivery{})
+	if endpointID > 0 {
+		query = query.Where("endpoint_id = ?", endpointID)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Order("created_at DESC").Offset((page - 1) * limit).Limit(limit).Find(&deliveries).Error
+	return deliveries, total, err
+}
+
+// ClaimDue claims the pending deliveries of every tenant whose next attempt is
+// due, pushing next_attempt_at to leaseUntil in the same statement so another
+// tick or replica cannot pick them up while they are being sent. Rows locked by
+// a concurrent claim are skipped.
+func (r *WebhookRepository) ClaimDue(now, leaseUntil time.Time, limit int) ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+	err := r.db.Raw(`
+		UPDATE webhook_deliveries SET next_attempt_at = ?
+		WHERE id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status = ? AND next_attempt_at <= ?
+			ORDER BY next_attempt_at ASC
+			LIMIT ?
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *`,
+		leaseUntil, models.WebhookPending, now, limit).Scan(&deliveries).Error
+	return deliveries, err
+}
+
+// MarkEndpointSuccess resets the failure streak of a subscription
+func (r *WebhookRepository) MarkEndpointSuccess(id uint) error {
+	return r.db.Exec(`UPDATE webhook_endpoints SET consecutive_failures = 0 WHERE id = ? AND consecutive_failures > 0`, id).Error
+}
+
+// MarkEndpointFailure counts a failed attempt and disables the subscription when
+// the streak reaches limit. Reports whether this attempt disabled it.
+func (r *WebhookRepository) MarkEndpointFailure(id uint, limit int, reason string, at time.Time) (bool, error) {
+	if err := r.db.Exec(`UPDATE webhook_endpoints SET consecutive_failures = consecutive_failures + 1, updated_at = ? WHERE id = ?`, at, id).Error; err != nil {
+		return false, err
+	}
+	res := r.db.Exec(`
+		UPDATE webhook_endpoints SET is_active = false, disabled_at = ?, disabled_reason = ?, updated_at = ?
+		WHERE id = ? AND is_active AND consecutive_failures >= ?`,
+		at, reason, at, id, limit)
+	return res.RowsAffected > 0, res.Error
+}
@@ -1,20 +1,31 @@
 package repository
 
 import (
+	"context"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/alonsoalpizar/fabricalaser/internal/database"
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+	"github.com/alonsoalpizar/fabricalaser/internal/tenant"
 	"gorm.io/gorm"
 )
 
 type WhatsappRepository struct {
-	db *gorm.DB
+	db       *gorm.DB
+	tenantID uint
 }
 
 func NewWhatsappRepository() *WhatsappRepository {
-	return &WhatsappRepository{db: database.Get()}
+	return &WhatsappRepository{db: database.Get(), tenantID: models.DefaultTenantID}
+}
+
+// WithContext retorna el repositorio sobre el tenant de ctx. Las consultas son
+// SQL directo (el plugin de tenant no las ve), así que filtran tenant_id a mano.
+func (r *WhatsappRepository) WithContext(ctx context.Context) *WhatsappRepository {
+	return &WhatsappRepository{db: r.db.WithContext(ctx), tenantID: tenant.ID(ctx)}
 }
 
 // ─────────────────────────────────────────────
@@ -84,17 +95,21 @@ type DigestMessage struct {
 // Sessions (grouped by phone + day)
 // ─────────────────────────────────────────────
 
-// CTE shared by data and count queries.
+// CTE shared by data and count queries, scoped to one tenant.
 // first_msgs uses DISTINCT ON to get the first user message per (phone, day) without
 // a correlated subquery (which is invalid in a GROUP BY context).
-const sessionCTE = `
+func sessionCTE(tenantID uint) string {
+	return strings.ReplaceAll(sessionCTETemplate, "$tenant", strconv.FormatUint(uint64(tenantID), 10))
+}
+
+const sessionCTETemplate = `
 	WITH first_msgs AS (
 		SELECT DISTINCT ON (phone, DATE(created_at AT TIME ZONE 'America/Costa_Rica'))
 			phone,
 			DATE(created_at AT TIME ZONE 'America/Costa_Rica') AS session_date,
 			content AS first_user_message
 		FROM whatsapp_conversations
-		WHERE role = 'user'
+		WHERE role = 'user' AND tenant_id = $tenant
 		ORDER BY phone, DATE(created_at AT TIME ZONE 'America/Costa_Rica'), created_at ASC
 	),
 	sessions AS (
@@ -105,6 +120,7 @@ const sessionCTE = `
 			MIN(created_at) AS first_message_at,
 			MAX(created_at) AS last_message_at
 		FROM whatsapp_conversations
+		WHERE tenant_id = $tenant
 		GROUP BY phone, DATE(created_at AT TIME ZONE 'America/Costa_Rica')
 	),
 	user_lookup AS (
@@ -117,7 +133,7 @@ const sessionCTE = `
 				nombre  AS user_nombre,
 				email   AS user_email
 			FROM users
-			WHERE telefono IS NOT NULL AND activo = true
+			WHERE telefono IS NOT NULL AND activo = true AND tenant_id = $tenant
 			ORDER BY RIGHT(REGEXP_REPLACE(telefono, '[^0-9]', '', 'g'), 8), id ASC
 		)
 
@@ -130,7 +146,7 @@ const sessionCTE = `
 				nombre AS user_nombre,
 				email  AS user_email
 			FROM users
-			WHERE activo = true
+			WHERE activo = true AND tenant_id = $tenant
 		)
 	)
 `
@@ -185,7 +201,7 @@ func (r *WhatsappRepository) GetSessions(f SessionFilter) (SessionsPage, error)
 	where, whereArgs := buildSessionWhere(f)
 
 	// Count
-	countSQL := sessionCTE + fmt.Sprintf(`
+	countSQL := sessionCTE(r.tenantID) + fmt.Sprintf(`
 		SELECT COUNT(*) FROM sessions s
 		LEFT JOIN user_lookup ul ON ul.phone_key = CASE WHEN s.phone LIKE 'web:%%' THEN s.phone ELSE RIGHT(REGEXP_REPLACE(s.phone, '[^0-9]', '', 'g'), 8) END
 		%s`, where)
@@ -197,7 +213,7 @@ func (r *WhatsappRepository) GetSessions(f SessionFilter) (SessionsPage, error)
 
 	// Data
 	dataArgs := append(whereArgs, f.Limit, offset)
-	dataSQL := sessionCTE + fmt.Sprintf(`
+	dataSQL := sessionCTE(r.tenantID) + fmt.Sprintf(`
 		SELECT
 			s.phone,
 			s.session_date::text,
@@ -244,11 +260,11 @@ func (r *WhatsappRepository) GetSessionMessages(phone, date string) ([]Conversat
 	sql := `
 		SELECT id, phone, role, content, created_at
 		FROM whatsapp_conversations
-		WHERE phone = ?
+		WHERE tenant_id = ? AND phone = ?
 		  AND DATE(created_at AT TIME ZONE 'America/Costa_Rica') = ?::date
 		ORDER BY created_at ASC
 	`
-	if err := r.db.Raw(sql, phone, date).Scan(&results).Error; err != nil {
+	if err := r.db.Raw(sql, r.tenantID, phone, date).Scan(&results).Error; err != nil {
 		return nil, err
 	}
 	return results, nil
@@ -265,22 +281,23 @@ func (r *WhatsappRepository) GetContactSummaries() ([]ContactSummary, error) {
 			SELECT DISTINCT ON (phone)
 				phone, content AS last_message, role AS last_role, created_at AS last_message_at
 			FROM whatsapp_conversations
+			WHERE tenant_id = @tenant
 			ORDER BY phone, created_at DESC
 		),
 		counts AS (
-			SELECT phone, COUNT(*) AS total_messages FROM whatsapp_conversations GROUP BY phone
+			SELECT phone, COUNT(*) AS total_messages FROM whatsapp_conversations WHERE tenant_id = @tenant GROUP BY phone
 		)
 		SELECT l.phone, l.last_message, l.last_role, l.last_message_at, c.total_messages,
 		       u.id AS user_id, u.nombre AS user_nombre, u.email AS user_email
 		FROM latest l
 		JOIN counts c ON l.phone = c.phone
 		LEFT JOIN users u
-			ON u.telefono IS NOT NULL AND u.activo = true
+			ON u.telefono IS NOT NULL AND u.activo = true AND u.tenant_id = @tenant
 			AND RIGHT(REGEXP_REPLACE(u.telefono, '[^0-9]', '', 'g'), 8)
 			  = RIGHT(REGEXP_REPLACE(l.phone, '[^0-9]', '', 'g'), 8)
 		ORDER BY l.last_message_at DESC
 	`
-	if err := r.db.Raw(sql).Scan(&results).Error; err != nil {
+	if err := r.db.Raw(sql, map[string]interface{}{"tenant": r.tenantID}).Scan(&results).Error; err != nil {
 		return nil, err
 	}
 	return results, nil
@@ -288,8 +305,8 @@ func (r *WhatsappRepository) GetContactSummaries() ([]ContactSummary, error) {
 
 func (r *WhatsappRepository) GetConversation(phone string) ([]ConversationMessage, error) {
 	var results []ConversationMessage
-	sql := `SELECT id, phone, role, content, created_at FROM whatsapp_conversations WHERE phone = ? ORDER BY created_at ASC`
-	if err := r.db.Raw(sql, phone).Scan(&results).Error; err != nil {
+	sql := `SELECT id, phone, role, content, created_at FROM whatsapp_conversations WHERE tenant_id = ? AND phone = ? ORDER BY created_at ASC`
+	if err := r.db.Raw(sql, r.tenantID, phone).Scan(&results).Error; err != nil {
 		return nil, err
 	}
 	return results, nil
@@ -303,13 +320,13 @@ func (r *WhatsappRepository) GetDigestMessagesSince(since time.Time) ([]DigestMe
 		       COALESCE(u.email, '')  AS user_email
 		FROM whatsapp_conversations wc
 		LEFT JOIN users u
-			ON u.telefono IS NOT NULL AND u.activo = true
+			ON u.telefono IS NOT NULL AND u.activo = true AND u.tenant_id = wc.tenant_id
 			AND RIGHT(REGEXP_REPLACE(u.telefono, '[^0-9]', '', 'g'), 8)
 			  = RIGHT(REGEXP_REPLACE(wc.phone, '[^0-9]', '', 'g'), 8)
-		WHERE wc.created_at > ?
+		WHERE wc.tenant_id = ? AND wc.created_at > ?
 		ORDER BY wc.phone, wc.created_at ASC
 	`
-	if err := r.db.Raw(sql, since).Scan(&results).Error; err != nil {
+	if err := r.db.Raw(sql, r.tenantID, since).Scan(&results).Error; err != nil {
 		return nil, err
 	}
 	return results, nil
@@ -319,12 +336,21 @@ func (r *WhatsappRepository) GetDigestMessagesSince(since time.Time) ([]DigestMe
 // Purge
 // ─────────────────────────────────────────────
 
+// SaveMessage archives an outbound message that did not go through the bot
+// (e.g. a template sent by an admin) so it shows up in the bitácora.
+func (r *WhatsappRepository) SaveMessage(phone, role, content string) error {
+	return r.db.Exec(
+		`INSERT INTO whatsapp_conversations (tenant_id, phone, role, content, created_at) VALUES (?, ?, ?, ?, ?)`,
+		r.tenantID, phone, role, content, time.Now(),
+	).Error
+}
+
 // CountOlderThan returns how many messages would be deleted (dry run).
 func (r *WhatsappRepository) CountOlderThan(days int) (int64, error) {
 	var count int64
 	err := r.db.Raw(
-		`SELECT COUNT(*) FROM whatsapp_conversations WHERE created_at < NOW() - (? * INTERVAL '1 day')`,
-		days,
+		`SELECT COUNT(*) FROM whatsapp_conversations WHERE tenant_id = ? AND created_at < NOW() - (? * INTERVAL '1 day')`,
+		r.tenantID, days,
 	).Scan(&count).Error
 	return count, err
 }
@@ -332,8 +358,8 @@ func (r *WhatsappRepository) CountOlderThan(days int) (int64, error) {
 // PurgeOlderThan deletes messages older than `days` days. Returns rows deleted.
 func (r *WhatsappRepository) PurgeOlderThan(days int) (int64, error) {
 	result := r.db.Exec(
-		`DELETE FROM whatsapp_conversations WHERE created_at < NOW() - (? * INTERVAL '1 day')`,
-		days,
+		`DELETE FROM whatsapp_conversations WHERE tenant_id = ? AND created_at < NOW() - (? * INTERVAL '1 day')`,
+		r.tenantID, days,
 	)
 	return result.RowsAffected, result.Error
 }
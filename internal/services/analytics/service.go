@@ -0,0 +1,293 @@
+// Package analytics resume las conversaciones del bot (WhatsApp, Telegram y chat
+// web) en tablas de rollup diarias: volumen por canal, latencia del bot, uso de
+// tools, escalados, rechazos del límite diario, horas pico y los materiales y
+// blanks que más preguntan los clientes.
+//
+// El job nocturno recalcula los últimos días; el dashboard solo lee los rollups.
+package analytics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/channels"
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+	"github.com/alonsoalpizar/fabricalaser/internal/repository"
+	"github.com/alonsoalpizar/fabricalaser/internal/tenant"
+)
+
+var zonaCR = time.FixedZone("America/Costa_Rica", -6*60*60)
+
+const (
+	// rollupHour es la hora local en que corre el job nocturno
+	rollupHour = 2
+	// rollupLookback días que recalcula cada noche: cubre mensajes archivados tarde
+	// y noches en que el servidor estuvo caído
+	rollupLookback = 3
+)
+
+type Service struct {
+	repo         *repository.AnalyticsRepository
+	materialRepo *repository.MaterialRepository
+	blankRepo    *repository.BlankRepository
+	now          func() time.Time
+}
+
+func NewService() *Service {
+	return &Service{
+		repo:         repository.NewAnalyticsRepository(),
+		materialRepo: repository.NewMaterialRepository(),
+		blankRepo:    repository.NewBlankRepository(),
+		now:          time.Now,
+	}
+}
+
+// Today retorna la fecha de hoy en Costa Rica (medianoche local)
+func (s *Service) Today() time.Time {
+	return localDay(s.now())
+}
+
+// Rollup recalcula los rollups de un día local del tenant de ctx. Es
+// idempotente: reemplaza lo que había.
+func (s *Service) Rollup(ctx context.Context, day time.Time) error {
+	day = localDay(day)
+	repo := s.repo.WithContext(ctx)
+
+	stats, err := repo.DayStats(day)
+	if err != nil {
+		return fmt.Errorf("analytics: resumen del %s: %w", day.Format("2006-01-02"), err)
+	}
+	rolledUpAt := s.now()
+	for i := range stats {
+		stats[i].Day = day
+		stats[i].RolledUpAt = rolledUpAt
+	}
+	tools, err := repo.DayTools(day)
+	if err != nil {
+		return fmt.Errorf("analytics: tools del %s: %w", day.Format("2006-01-02"), err)
+	}
+	hours, err := repo.DayHours(day)
+	if err != nil {
+		return fmt.Errorf("analytics: horas del %s: %w", day.Format("2006-01-02"), err)
+	}
+	topics, err := s.dayTopics(ctx, day)
+	if err != nil {
+		return err
+	}
+
+	if err := repo.SaveDay(day, stats, tools, hours, topics); err != nil {
+		return fmt.Errorf("analytics: error guardando rollup del %s: %w", day.Format("2006-01-02"), err)
+	}
+	return nil
+}
+
+// RollupRange recalcula cada día entre from y to (inclusive) y retorna cuántos procesó
+func (s *Service) RollupRange(ctx context.Context, from, to time.Time) (int, error) {
+	days := 0
+	for day := localDay(from); !day.After(localDay(to)); day = day.AddDate(0, 0, 1) {
+		if err := ctx.Err(); err != nil {
+			return days, err
+		}
+		if err := s.Rollup(ctx, day); err != nil {
+			return days, err
+		}
+		days++
+	}
+	return days, nil
+}
+
+// StartNightlyJob recalcula cada noche, a las 2:00 hora Costa Rica, los últimos
+// días completos de cada tenant activo hasta que ctx termine.
+func (s *Service) StartNightlyJob(ctx context.Context) {
+	go func() {
+		for {
+			timer := time.NewTimer(time.Until(nextRun(s.now(), rollupHour)))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+				yesterday := s.Today().AddDate(0, 0, -1)
+				tenant.EachActive(ctx, func(ctx context.Context) {
+					days, err := s.RollupRange(ctx, yesterday.AddDate(0, 0, 1-rollupLookback), yesterday)
+					if err != nil {
+						slog.Error("analytics: error en rollup nocturno", "tenant_id", tenant.ID(ctx), "dias", days, "error", err)
+						return
+					}
+					slog.Info("analytics: rollup nocturno", "tenant_id", tenant.ID(ctx), "dias", days, "hasta", yesterday.Format("2006-01-02"))
+				})
+			}
+		}
+	}()
+}
+
+// nextRun es la próxima vez que el reloj local marca hour:00
+func nextRun(now time.Time, hour int) time.Time {
+	local := now.In(zonaCR)
+	next := time.Date(local.Year(), local.Month(), local.Day(), hour, 0, 0, 0, zonaCR)
+	if !next.After(local) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// ParseDay lee una fecha YYYY-MM-DD como día local de Costa Rica
+func ParseDay(s string) (time.Time, error) {
+	return time.ParseInLocation("2006-01-02", s, zonaCR)
+}
+
+func localDay(t time.Time) time.Time {
+	local := t.In(zonaCR)
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, zonaCR)
+}
+
+// ─── Materiales y productos mencionados ──────────────────────────────────────
+
+func (s *Service) dayTopics(ctx context.Context, day time.Time) ([]models.ConversationTopicDaily, error) {
+	messages, err := s.repo.WithContext(ctx).DayUserMessages(day)
+	if err != nil {
+		return nil, fmt.Errorf("analytics: mensajes del %s: %w", day.Format("2006-01-02"), err)
+	}
+	if len(messages) == 0 {
+		return nil, nil
+	}
+	materials, err := s.materialRepo.WithContext(ctx).FindAll()
+	if err != nil {
+		return nil, fmt.Errorf("analytics: error cargando materiales: %w", err)
+	}
+	blanks, err := s.blankRepo.WithContext(ctx).FindAll()
+	if err != nil {
+		return nil, fmt.Errorf("analytics: error cargando blanks: %w", err)
+	}
+	return newTopicMatcher(materials, blanks).count(day, messages), nil
+}
+
+type topic struct {
+	kind  string
+	id    uint
+	name  string
+	terms [][]string // Palabras normalizadas de cada nombre o alias (ver normalize)
+}
+
+// topicMatcher busca en los mensajes de los clientes el nombre de cada material
+// activo y el nombre y los aliases de cada blank activo
+type topicMatcher struct {
+	topics []topic
+}
+
+func newTopicMatcher(materials []models.Material, blanks []models.Blank) *topicMatcher {
+	m := &topicMatcher{}
+	for _, mat := range materials {
+		// "Oro / Plata" se busca como "oro" y como "plata"
+		m.add(models.TopicMaterial, mat.ID, mat.Name, strings.Split(mat.Name, "/"))
+	}
+	for _, b := range blanks {
+		var aliases []string
+		if len(b.Aliases) > 0 {
+			_ = json.Unmarshal(b.Aliases, &aliases)
+		}
+		m.add(models.TopicBlank, b.ID, b.Name, append([]string{b.Name}, aliases...))
+	}
+	return m
+}
+
+func (m *topicMatcher) add(kind string, id uint, name string, raw []string) {
+	t := topic{kind: kind, id: id, name: name}
+	for _, term := range raw {
+		words := strings.Fields(normalize(term))
+		// Una sola letra da falsos positivos
+		if len(words) > 1 || len(words) == 1 && len([]rune(words[0])) >= 2 {
+			t.terms = append(t.terms, words)
+		}
+	}
+	if len(t.terms) > 0 {
+		m.topics = append(m.topics, t)
+	}
+}
+
+// match retorna los temas que menciona el texto
+func (m *topicMatcher) match(text string) []topic {
+	words := strings.Fields(normalize(text))
+	var found []topic
+	for _, t := range m.topics {
+		for _, term := range t.terms {
+			if containsTerm(words, term) {
+				found = append(found, t)
+				break
+			}
+		}
+	}
+	return found
+}
+
+// containsTerm busca las palabras del término seguidas en el texto, tolerando el
+// plural de cada una: "llaveros transparentes", "acrílicos", "medallones"
+func containsTerm(words, term []string) bool {
+	for i := 0; i+len(term) <= len(words); i++ {
+		ok := true
+		for j, w := range term {
+			got := words[i+j]
+			if got != w && got != w+"s" && got != w+"es" {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+// count arma las filas del día por canal y tema: mensajes que lo nombran y
+// contactos distintos
+func (m *topicMatcher) count(day time.Time, messages []repository.ConversationMessage) []models.ConversationTopicDaily {
+	type key struct {
+		channel string
+		kind    string
+		id      uint
+	}
+	rows := map[key]*models.ConversationTopicDaily{}
+	contacts := map[key]map[string]bool{}
+	var order []key
+
+	for _, msg := range messages {
+		channel := channels.KeyChannel(msg.Phone)
+		for _, t := range m.match(msg.Content) {
+			k := key{channel, t.kind, t.id}
+			row, ok := rows[k]
+			if !ok {
+				row = &models.ConversationTopicDaily{Day: day, Channel: channel, Kind: t.kind, RefID: t.id, Name: t.name}
+				rows[k] = row
+				contacts[k] = map[string]bool{}
+				order = append(order, k)
+			}
+			row.Mentions++
+			contacts[k][msg.Phone] = true
+		}
+	}
+
+	out := make([]models.ConversationTopicDaily, 0, len(order))
+	for _, k := range order {
+		row := rows[k]
+		row.Contacts = len(contacts[k])
+		out = append(out, *row)
+	}
+	return out
+}
+
+var accents = strings.NewReplacer("á", "a", "é", "e", "í", "i", "ó", "o", "ú", "u", "ü", "u", "ñ", "n")
+
+// normalize pasa a minúsculas sin tildes y deja solo letras y dígitos separados
+// por un espacio
+func normalize(s string) string {
+	s = accents.Replace(strings.ToLower(s))
+	return strings.Join(strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
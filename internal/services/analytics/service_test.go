@@ -0,0 +1,63 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+	"github.com/alonsoalpizar/fabricalaser/internal/repository"
+	"gorm.io/datatypes"
+)
+
+func TestTopicMatcherCount(t *testing.T) {
+	m := newTopicMatcher(
+		[]models.Material{{ID: 1, Name: "Acrílico"}, {ID: 2, Name: "Oro / Plata"}, {ID: 3, Name: "MDF"}},
+		[]models.Blank{{ID: 7, Name: "Llavero acrílico", Aliases: datatypes.JSON(`["llavero transparente","discos de acrílico"]`)}},
+	)
+	day := time.Date(2026, 10, 1, 0, 0, 0, 0, zonaCR)
+	rows := m.count(day, []repository.ConversationMessage{
+		{Phone: "50688887777", Content: "Hola, ¿hacen llaveros transparentes en ACRILICO?"},
+		{Phone: "50688887777", Content: "y en acrílicos de color"},
+		{Phone: "tg:123", Content: "Quiero grabar una medalla de plata"},
+		{Phone: "50611112222", Content: "cotización de 20 piezas de mdf, gracias"},
+		{Phone: "50611112222", Content: "¿el 'madera' incluye el corte?"},
+	})
+
+	got := map[string]models.ConversationTopicDaily{}
+	for _, r := range rows {
+		got[r.Channel+"/"+r.Kind+"/"+r.Name] = r
+	}
+	if len(got) != 4 {
+		t.Fatalf("rows = %+v", rows)
+	}
+	if r := got["whatsapp/material/Acrílico"]; r.Mentions != 2 || r.Contacts != 1 {
+		t.Errorf("acrílico = %+v", r)
+	}
+	if r := got["whatsapp/blank/Llavero acrílico"]; r.Mentions != 1 || r.RefID != 7 {
+		t.Errorf("llavero = %+v", r)
+	}
+	if r := got["telegram/material/Oro / Plata"]; r.Mentions != 1 {
+		t.Errorf("plata = %+v", r)
+	}
+	if r := got["whatsapp/material/MDF"]; r.Contacts != 1 || !r.Day.Equal(day) {
+		t.Errorf("mdf = %+v", r)
+	}
+}
+
+func TestNextRun(t *testing.T) {
+	// 1:30 en Costa Rica: corre hoy a las 2:00
+	now := time.Date(2026, 10, 1, 1, 30, 0, 0, zonaCR)
+	if got, want := nextRun(now, 2), time.Date(2026, 10, 1, 2, 0, 0, 0, zonaCR); !got.Equal(want) {
+		t.Errorf("nextRun = %v, want %v", got, want)
+	}
+	// 2:00 en punto ya pasó: mañana
+	now = time.Date(2026, 10, 1, 2, 0, 0, 0, zonaCR)
+	if got, want := nextRun(now, 2), time.Date(2026, 10, 2, 2, 0, 0, 0, zonaCR); !got.Equal(want) {
+		t.Errorf("nextRun = %v, want %v", got, want)
+	}
+	// Las 23:00 UTC son las 17:00 locales del mismo día
+	now = time.Date(2026, 10, 1, 23, 0, 0, 0, time.UTC)
+	if got := localDay(now); got.Day() != 1 {
+		t.Errorf("localDay = %v", got)
+	}
+}
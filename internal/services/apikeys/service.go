@@ -0,0 +1,324 @@
+// Package apikeys gestiona las API keys de los clientes B2B de /api/public/v1:
+// emisión, rotación con periodo de gracia, autenticación, límite por minuto y
+// medición de uso.
+//
+// La key completa (flk_<prefix>_<secreto>) se muestra una sola vez al crearla;
+// en api_keys queda el prefix para buscarla y el SHA-256 de la key. El límite
+// por minuto es un contador Redis por key y ventana; si Redis no responde se
+// deja pasar el request (fail open) como el limitador de WhatsApp.
+package apikeys
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+	"github.com/alonsoalpizar/fabricalaser/internal/repository"
+	"github.com/alonsoalpizar/fabricalaser/internal/tenant"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/datatypes"
+)
+
+var (
+	ErrInvalidKey   = errors.New("API key inválida")
+	ErrNoName       = errors.New("el nombre del cliente es requerido")
+	ErrExpiredKey   = errors.New("API key vencida o revocada")
+	ErrNoScopes     = errors.New("indicá al menos un scope")
+	ErrInvalidScope = errors.New("scope desconocido")
+	ErrNoOwner      = errors.New("la key necesita la cuenta del cliente (user_id)")
+	ErrUnknownOwner = errors.New("la cuenta del cliente no existe en este taller")
+	ErrInvalidLimit = errors.New("rate_limit_per_minute debe estar entre 1 y 6000")
+	ErrNotActive    = errors.New("la key ya no está activa")
+)
+
+const (
+	keyPrefix          = "flk_"
+	prefixBytes        = 4  // 8 hex visibles
+	secretBytes        = 24 // 48 hex secretos
+	DefaultRateLimit   = 60
+	maxRateLimit       = 6000
+	DefaultRotateGrace = 24 * time.Hour
+	usageDays          = 30
+)
+
+// Limit es el estado del límite por minuto de una key tras contar un request
+type Limit struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	Reset     time.Duration // Hasta el inicio de la próxima ventana
+}
+
+type Service struct {
+	repo     *repository.APIKeyRepository
+	userRepo *repository.UserRepository
+	rc       *redis.Client
+	now      func() time.Time
+}
+
+// NewService arma el servicio; rc puede ser nil (sin límite por minuto)
+func NewService(rc *redis.Client) *Service {
+	return &Service{
+		repo:     repository.NewAPIKeyRepository(),
+		userRepo: repository.NewUserRepository(),
+		rc:       rc,
+		now:      time.Now,
+	}
+}
+
+// KeyInput son los datos editables de una key
+type KeyInput struct {
+	Name               string   `json:"name"`
+	UserID             uint     `json:"user_id"`
+	Scopes             []string `json:"scopes"`
+	RateLimitPerMinute int      `json:"rate_limit_per_minute"`
+}
+
+func (in *KeyInput) validate() error {
+	in.Name = strings.TrimSpace(in.Name)
+	if in.Name == "" {
+		return ErrNoName
+	}
+	if in.UserID == 0 {
+		return ErrNoOwner
+	}
+	if len(in.Scopes) == 0 {
+		return ErrNoScopes
+	}
+	for _, s := range in.Scopes {
+		if !models.IsValidScope(s) {
+			return fmt.Errorf("%w: %s", ErrInvalidScope, s)
+		}
+	}
+	if in.RateLimitPerMinute == 0 {
+		in.RateLimitPerMinute = DefaultRateLimit
+	}
+	if in.RateLimitPerMinute < 0 || in.RateLimitPerMinute > maxRateLimit {
+		return ErrInvalidLimit
+	}
+	return nil
+}
+
+// checkOwner verifica que la cuenta del cliente exista en el tenant de ctx: la
+// key autentica como ese usuario, no puede apuntar a uno de otro taller.
+func (s *Service) checkOwner(ctx context.Context, userID uint) error {
+	if _, err := s.userRepo.WithContext(ctx).FindByID(userID); err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return ErrUnknownOwner
+		}
+		return err
+	}
+	return nil
+}
+
+// Create emite una key para el cliente. Retorna la key en claro: no se puede
+// volver a consultar.
+func (s *Service) Create(ctx context.Context, in KeyInput, createdBy uint) (*models.APIKey, string, error) {
+	if err := in.validate(); err != nil {
+		return nil, "", err
+	}
+	if err := s.checkOwner(ctx, in.UserID); err != nil {
+		return nil, "", err
+	}
+	scopes, _ := json.Marshal(in.Scopes)
+	k := &models.APIKey{
+		UserID:             in.UserID,
+		Name:               in.Name,
+		Scopes:             datatypes.JSON(scopes),
+		RateLimitPerMinute: in.RateLimitPerMinute,
+		IsActive:           true,
+		CreatedBy:          &createdBy,
+	}
+	raw, err := s.issue(ctx, k)
+	if err != nil {
+		return nil, "", err
+	}
+	return k, raw, nil
+}
+
+// issue genera el secreto de k y la guarda
+func (s *Service) issue(ctx context.Context, k *models.APIKey) (string, error) {
+	raw, prefix, err := generateKey()
+	if err != nil {
+		return "", err
+	}
+	k.Prefix = prefix
+	k.KeyHash = tenant.HashAPIKey(raw)
+	if err := s.repo.WithContext(ctx).Create(k); err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+// Update cambia nombre, dueño, scopes y límite de una key
+func (s *Service) Update(ctx context.Context, id uint, in KeyInput) (*models.APIKey, error) {
+	if err := in.validate(); err != nil {
+		return nil, err
+	}
+	if err := s.checkOwner(ctx, in.UserID); err != nil {
+		return nil, err
+	}
+	repo := s.repo.WithContext(ctx)
+	k, err := repo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	scopes, _ := json.Marshal(in.Scopes)
+	k.Name, k.UserID, k.Scopes, k.RateLimitPerMinute = in.Name, in.UserID, datatypes.JSON(scopes), in.RateLimitPerMinute
+	if err := repo.Update(k); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Rotate emite una key nueva con los mismos datos; la anterior vence tras
+// grace (0 = de inmediato). Retorna la key nueva en claro.
+func (s *Service) Rotate(ctx context.Context, id uint, grace time.Duration, rotatedBy uint) (*models.APIKey, string, error) {
+	repo := s.repo.WithContext(ctx)
+	old, err := repo.FindByID(id)
+	if err != nil {
+		return nil, "", err
+	}
+	now := s.now()
+	if !old.Usable(now) {
+		return nil, "", ErrNotActive
+	}
+
+	k := &models.APIKey{
+		UserID:             old.UserID,
+		Name:               old.Name,
+		Scopes:             old.Scopes,
+		RateLimitPerMinute: old.RateLimitPerMinute,
+		IsActive:           true,
+		RotatedFromID:      &old.ID,
+		CreatedBy:          &rotatedBy,
+	}
+	raw, err := s.issue(ctx, k)
+	if err != nil {
+		return nil, "", err
+	}
+
+	expires := now.Add(grace)
+	if old.ExpiresAt == nil || expires.Before(*old.ExpiresAt) {
+		old.ExpiresAt = &expires
+	}
+	if grace <= 0 {
+		old.IsActive = false
+	}
+	if err := repo.Update(old); err != nil {
+		return nil, "", err
+	}
+	return k, raw, nil
+}
+
+// Revoke desactiva una key de inmediato
+func (s *Service) Revoke(ctx context.Context, id uint) (*models.APIKey, error) {
+	repo := s.repo.WithContext(ctx)
+	k, err := repo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	k.IsActive = false
+	if err := repo.Update(k); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// List retorna las keys del tenant
+func (s *Service) List(ctx context.Context) ([]models.APIKey, error) {
+	return s.repo.WithContext(ctx).FindAll()
+}
+
+// Usage retorna el uso diario de una key en los últimos 30 días
+func (s *Service) Usage(ctx context.Context, id uint) (*models.APIKey, []models.APIKeyUsage, error) {
+	repo := s.repo.WithContext(ctx)
+	k, err := repo.FindByID(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	usage, err := repo.Usage(id, s.now().AddDate(0, 0, -usageDays))
+	return k, usage, err
+}
+
+// Authenticate retorna la key de raw si existe, coincide y sigue vigente
+func (s *Service) Authenticate(raw string) (*models.APIKey, error) {
+	prefix, ok := parseKey(raw)
+	if !ok {
+		return nil, ErrInvalidKey
+	}
+	k, err := s.repo.FindByPrefix(prefix)
+	if errors.Is(err, repository.ErrAPIKeyNotFound) {
+		return nil, ErrInvalidKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(k.KeyHash), []byte(tenant.HashAPIKey(raw))) != 1 {
+		return nil, ErrInvalidKey
+	}
+	if !k.Usable(s.now()) {
+		return nil, ErrExpiredKey
+	}
+	return k, nil
+}
+
+// Allow cuenta un request de la key en la ventana del minuto actual
+func (s *Service) Allow(ctx context.Context, k *models.APIKey) Limit {
+	now := s.now()
+	window := now.Truncate(time.Minute)
+	lim := Limit{Allowed: true, Limit: k.RateLimitPerMinute, Remaining: k.RateLimitPerMinute, Reset: window.Add(time.Minute).Sub(now)}
+	if s.rc == nil || k.RateLimitPerMinute <= 0 {
+		return lim
+	}
+
+	key := fmt.Sprintf("apikey:rl:%d:%d", k.ID, window.Unix())
+	pipe := s.rc.TxPipeline()
+	incr := pipe.Incr(ctx, key)
+	pipe.Expire(ctx, key, 2*time.Minute)
+	if _, err := pipe.Exec(ctx); err != nil {
+		slog.Warn("apikeys: rate limit sin Redis, se deja pasar", "key_id", k.ID, "error", err)
+		return lim
+	}
+	count := int(incr.Val())
+	lim.Remaining = max(k.RateLimitPerMinute-count, 0)
+	lim.Allowed = count <= k.RateLimitPerMinute
+	return lim
+}
+
+// Record mide un request de la key; los errores solo se registran en el log
+func (s *Service) Record(k *models.APIKey, endpoint string, status int) {
+	if err := s.repo.RecordUsage(k.ID, endpoint, status >= 400, s.now()); err != nil {
+		slog.Warn("apikeys: no se pudo medir el uso", "key_id", k.ID, "endpoint", endpoint, "error", err)
+	}
+}
+
+// generateKey retorna una key nueva y su prefix
+func generateKey() (raw, prefix string, err error) {
+	buf := make([]byte, prefixBytes+secretBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	prefix = keyPrefix + hex.EncodeToString(buf[:prefixBytes])
+	return prefix + "_" + hex.EncodeToString(buf[prefixBytes:]), prefix, nil
+}
+
+// parseKey retorna el prefix de una key con el formato flk_<prefix>_<secreto>
+func parseKey(raw string) (string, bool) {
+	if !strings.HasPrefix(raw, keyPrefix) {
+		return "", false
+	}
+	prefix, secret, ok := strings.Cut(raw[len(keyPrefix):], "_")
+	if !ok || len(prefix) != 2*prefixBytes || len(secret) != 2*secretBytes {
+		return "", false
+	}
+	return keyPrefix + prefix, true
+}
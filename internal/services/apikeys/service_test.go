@@ -0,0 +1,71 @@
+package apikeys
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+)
+
+func TestGenerateAndParseKey(t *testing.T) {
+	raw, prefix, err := generateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, ok := parseKey(raw)
+	if !ok || got != prefix {
+		t.Fatalf("parseKey(%q) = %q %v, want %q", raw, got, ok, prefix)
+	}
+
+	for _, bad := range []string{"", "flk_", prefix, "xyz_" + raw[4:], raw + "0", "Bearer " + raw} {
+		if _, ok := parseKey(bad); ok {
+			t.Errorf("parseKey(%q) accepted", bad)
+		}
+	}
+}
+
+func TestKeyInputValidate(t *testing.T) {
+	in := KeyInput{Name: " Tienda ", UserID: 5, Scopes: []string{models.ScopeEstimate}}
+	if err := in.validate(); err != nil {
+		t.Fatal(err)
+	}
+	if in.Name != "Tienda" || in.RateLimitPerMinute != DefaultRateLimit {
+		t.Errorf("defaults not applied: %+v", in)
+	}
+
+	cases := []struct {
+		in   KeyInput
+		want error
+	}{
+		{KeyInput{UserID: 5, Scopes: []string{models.ScopeEstimate}}, ErrNoName},
+		{KeyInput{Name: "x", Scopes: []string{models.ScopeEstimate}}, ErrNoOwner},
+		{KeyInput{Name: "x", UserID: 5}, ErrNoScopes},
+		{KeyInput{Name: "x", UserID: 5, Scopes: []string{"admin"}}, ErrInvalidScope},
+		{KeyInput{Name: "x", UserID: 5, Scopes: []string{models.ScopeAnalyze}, RateLimitPerMinute: -1}, ErrInvalidLimit},
+	}
+	for _, c := range cases {
+		if err := c.in.validate(); !errors.Is(err, c.want) {
+			t.Errorf("validate(%+v) = %v, want %v", c.in, err, c.want)
+		}
+	}
+}
+
+func TestKeyUsable(t *testing.T) {
+	now := time.Now()
+	past, future := now.Add(-time.Minute), now.Add(time.Minute)
+	cases := []struct {
+		k    models.APIKey
+		want bool
+	}{
+		{models.APIKey{IsActive: true}, true},
+		{models.APIKey{IsActive: true, ExpiresAt: &future}, true},
+		{models.APIKey{IsActive: true, ExpiresAt: &past}, false},
+		{models.APIKey{IsActive: false}, false},
+	}
+	for i, c := range cases {
+		if got := c.k.Usable(now); got != c.want {
+			t.Errorf("case %d: Usable = %v, want %v", i, got, c.want)
+		}
+	}
+}
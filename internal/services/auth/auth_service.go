@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
@@ -79,6 +80,13 @@ func NewAuthService() *AuthService {
 	}
 }
 
+// WithContext retorna el servicio sobre ctx: los usuarios son del tenant del request
+func (s *AuthService) WithContext(ctx context.Context) *AuthService {
+	clone := *s
+	clone.userRepo = s.userRepo.WithContext(ctx)
+	return &clone
+}
+
 // VerificarCedula checks if a cedula exists, validates against GoMeta, and returns official data
 func (s *AuthService) VerificarCedula(identificacion string) (*VerifyCedulaResult, error) {
 	// First validate format locally
@@ -199,7 +207,7 @@ func (s *AuthService) Login(identificacion, password string) (*AuthResult, error
 	_ = s.userRepo.UpdateLastLogin(user.ID)
 
 	// Generate token
-	token, err := utils.GenerateToken(user.ID, user.Cedula, user.Nombre, user.Email, user.Role)
+	token, err := utils.GenerateToken(user.ID, user.TenantID, user.Cedula, user.Nombre, user.Email, user.Role)
 	if err != nil {
 		return nil, err
 	}
@@ -330,7 +338,7 @@ func (s *AuthService) Registro(identificacion, nombre, email, telefono, password
 		_ = s.userRepo.UpdateLastLogin(existingUser.ID)
 		emailSvc.SendWelcome(existingUser.Email, existingUser.Nombre)
 
-		token, err := utils.GenerateToken(existingUser.ID, existingUser.Cedula, existingUser.Nombre, existingUser.Email, existingUser.Role)
+		token, err := utils.GenerateToken(existingUser.ID, existingUser.TenantID, existingUser.Cedula, existingUser.Nombre, existingUser.Email, existingUser.Role)
 		if err != nil {
 			return nil, err
 		}
@@ -370,7 +378,7 @@ func (s *AuthService) Registro(identificacion, nombre, email, telefono, password
 	_ = s.userRepo.UpdateLastLogin(user.ID)
 	emailSvc.SendWelcome(user.Email, user.Nombre)
 
-	token, err := utils.GenerateToken(user.ID, user.Cedula, user.Nombre, user.Email, user.Role)
+	token, err := utils.GenerateToken(user.ID, user.TenantID, user.Cedula, user.Nombre, user.Email, user.Role)
 	if err != nil {
 		return nil, err
 	}
@@ -442,7 +450,7 @@ func (s *AuthService) EstablecerPassword(identificacion, password, email, telefo
 	// Reload user
 	user, _ = s.userRepo.FindByID(user.ID)
 
-	token, err := utils.GenerateToken(user.ID, user.Cedula, user.Nombre, user.Email, user.Role)
+	token, err := utils.GenerateToken(user.ID, user.TenantID, user.Cedula, user.Nombre, user.Email, user.Role)
 	if err != nil {
 		return nil, err
 	}
@@ -486,6 +494,15 @@ func (s *AuthService) UpdateProfile(userID uint, email, telefono, direccion, pro
 	return s.userRepo.FindByID(userID)
 }
 
+// UpdateNotificationPrefs actualiza canal preferido, opt-out y chat de Telegram
+// de los avisos de cotizaciones y pedidos
+func (s *AuthService) UpdateNotificationPrefs(userID uint, channel *string, optOut *bool, telegramChatID *int64) (*models.User, error) {
+	if err := s.userRepo.UpdateNotificationPrefs(userID, channel, optOut, telegramChatID); err != nil {
+		return nil, err
+	}
+	return s.userRepo.FindByID(userID)
+}
+
 // SolicitarRecuperacion genera un token de reset y envía el email.
 // Siempre retorna nil (anti-enumeración: no revela si la cédula existe).
 func (s *AuthService) SolicitarRecuperacion(identificacion string) error {
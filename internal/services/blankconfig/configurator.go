@@ -0,0 +1,330 @@
+// Package blankconfig cotiza un blank del catálogo configurado por el cliente:
+// cantidad, accesorios y grabado. Es la única fuente del precio de un blank
+// para el bot, el chat admin y el catálogo web; el agente no suma montos.
+//
+// El precio sale de:
+//   - price_breaks: precio unitario del tramo más alto que alcanza la cantidad
+//   - accessories: cada accesorio se vende en paquetes de min_qty_pack
+//   - grabado: pricing.Calculator sobre el material, grosor y tecnología del
+//     blank, una vez por cara
+//   - min_qty: cantidades por debajo del mínimo se rechazan
+package blankconfig
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/database"
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+	"github.com/alonsoalpizar/fabricalaser/internal/repository"
+	"github.com/alonsoalpizar/fabricalaser/internal/services/pricing"
+	"github.com/alonsoalpizar/fabricalaser/internal/services/tax"
+)
+
+var (
+	ErrBlankNotFound    = errors.New("blank no encontrado")
+	ErrBelowMinimum     = errors.New("cantidad por debajo del mínimo")
+	ErrUnknownAccessory = errors.New("accesorio no disponible para este blank")
+	ErrNotEngravable    = errors.New("este blank no tiene grabado configurado")
+	ErrEngraveArea      = errors.New("área de grabado inválida")
+	ErrSides            = errors.New("caras de grabado inválidas")
+	ErrEngraveType      = errors.New("tipo de grabado inválido")
+)
+
+// defaultEngraveType es el grabado cuando no se indica: vectorial, igual que /quotes/estimate
+const defaultEngraveType = 1
+
+// Input es la configuración pedida por el cliente
+type Input struct {
+	BlankID        uint     `json:"blank_id"`
+	Cantidad       int      `json:"cantidad"`
+	Accesorios     []string `json:"accesorios"`       // Nombres de accessories del blank
+	GrabadoAltoCM  float64  `json:"grabado_alto_cm"`  // 0 = sin grabado
+	GrabadoAnchoCM float64  `json:"grabado_ancho_cm"` // 0 = sin grabado
+	EngraveTypeID  uint     `json:"engrave_type_id"`  // 0 = vectorial
+	Caras          int      `json:"caras"`            // 1 o 2; 0 = 1
+}
+
+// Result es el desglose completo, en colones y sin IVA salvo iva y total
+type Result struct {
+	BlankID   uint   `json:"blank_id"`
+	Nombre    string `json:"nombre"`
+	Categoria string `json:"categoria"`
+	Cantidad  int    `json:"cantidad"`
+	MinQty    int    `json:"min_qty"`
+
+	PrecioUnitarioBlank int `json:"precio_unitario_blank"` // Según el tramo de price_breaks
+	TramoCantidad       int `json:"tramo_cantidad"`        // Cantidad del tramo aplicado; 0 = base_price
+	SubtotalBlanks      int `json:"subtotal_blanks"`
+
+	Accesorios         []AccessoryLine `json:"accesorios"`
+	SubtotalAccesorios int             `json:"subtotal_accesorios"`
+
+	Grabado *Engraving `json:"grabado,omitempty"`
+
+	Subtotal       float64 `json:"subtotal"`
+	PrecioUnitario float64 `json:"precio_unitario"` // Subtotal / cantidad
+	TarifaIVA      float64 `json:"tarifa_iva"`
+	IVA            float64 `json:"iva"`
+	Total          float64 `json:"total"`
+	ModoIVA        string  `json:"modo_iva"`
+	Moneda         string  `json:"moneda"`
+
+	Avisos []string `json:"avisos,omitempty"` // Stock
+}
+
+// AccessoryLine es un accesorio vendido en paquetes completos
+type AccessoryLine struct {
+	Nombre         string `json:"nombre"`
+	PrecioUnitario int    `json:"precio_unitario"`
+	Paquete        int    `json:"paquete"`  // min_qty_pack
+	Paquetes       int    `json:"paquetes"` // Paquetes para cubrir la cantidad
+	Cantidad       int    `json:"cantidad"` // Paquetes × paquete
+	Subtotal       int    `json:"subtotal"`
+}
+
+// Engraving es el costo del grabado de todas las unidades
+type Engraving struct {
+	AltoCM         float64 `json:"alto_cm"`
+	AnchoCM        float64 `json:"ancho_cm"`
+	Caras          int     `json:"caras"`
+	EngraveTypeID  uint    `json:"engrave_type_id"`
+	TipoGrabado    string  `json:"tipo_grabado,omitempty"`
+	Material       string  `json:"material,omitempty"`
+	Tecnologia     string  `json:"tecnologia,omitempty"`
+	CostoPorCara   float64 `json:"costo_por_cara"` // Todas las unidades, una cara
+	PrecioUnitario float64 `json:"precio_unitario"`
+	Subtotal       float64 `json:"subtotal"`
+}
+
+type Configurator struct {
+	blankRepo    *repository.BlankRepository
+	configLoader *pricing.ConfigLoader
+	calculator   *pricing.Calculator
+	now          func() time.Time
+}
+
+func NewConfigurator() *Configurator {
+	configLoader := pricing.NewConfigLoader(database.Get())
+	return &Configurator{
+		blankRepo:    repository.NewBlankRepository(),
+		configLoader: configLoader,
+		calculator:   pricing.NewCalculator(configLoader),
+		now:          time.Now,
+	}
+}
+
+// Configure cotiza la configuración con los precios y la config del tenant de ctx
+func (c *Configurator) Configure(ctx context.Context, in Input) (*Result, error) {
+	blank, err := c.blankRepo.WithContext(ctx).FindByID(in.BlankID)
+	if err != nil || !blank.IsActive {
+		return nil, ErrBlankNotFound
+	}
+	if in.Cantidad < blank.MinQty || in.Cantidad < 1 {
+		return nil, fmt.Errorf("%w: el mínimo de %s es %d unidades", ErrBelowMinimum, blank.Name, max(blank.MinQty, 1))
+	}
+
+	unit, tier := UnitPrice(blank, in.Cantidad)
+	res := &Result{
+		BlankID:             blank.ID,
+		Nombre:              blank.Name,
+		Categoria:           blank.Category,
+		Cantidad:            in.Cantidad,
+		MinQty:              blank.MinQty,
+		PrecioUnitarioBlank: unit,
+		TramoCantidad:       tier,
+		SubtotalBlanks:      unit * in.Cantidad,
+		Moneda:              models.CurrencyCRC,
+	}
+
+	if res.Accesorios, err = AccessoryLines(blank, in.Accesorios, in.Cantidad); err != nil {
+		return nil, err
+	}
+	for _, a := range res.Accesorios {
+		res.SubtotalAccesorios += a.Subtotal
+	}
+
+	// Si la config no carga, IVA con la tarifa general; el grabado sí la necesita
+	config, cfgErr := c.configLoader.LoadContext(ctx)
+	if cfgErr != nil {
+		config = nil
+	}
+
+	if in.GrabadoAltoCM > 0 || in.GrabadoAnchoCM > 0 {
+		if cfgErr != nil {
+			return nil, fmt.Errorf("error cargando config de pricing: %w", cfgErr)
+		}
+		if res.Grabado, err = c.engrave(ctx, config, blank, in); err != nil {
+			return nil, err
+		}
+	}
+
+	subtotal := float64(res.SubtotalBlanks + res.SubtotalAccesorios)
+	if res.Grabado != nil {
+		subtotal += res.Grabado.Subtotal
+	}
+	// Convert CRC→CRC solo redondea a colones enteros
+	iva := tax.For(config, tax.KindBlank, subtotal, nil, c.now()).
+		Convert(models.CurrencyCRC, models.CurrencyCRC, 0)
+	res.Subtotal = iva.Subtotal
+	res.PrecioUnitario = models.RoundCurrency(iva.Subtotal/float64(in.Cantidad), models.CurrencyCRC)
+	res.TarifaIVA = iva.Rate
+	res.IVA = iva.Tax
+	res.Total = iva.Total
+	res.ModoIVA = iva.Display
+
+	switch {
+	case blank.StockQty < in.Cantidad:
+		res.Avisos = append(res.Avisos, fmt.Sprintf("Stock insuficiente (%d disponibles) — confirmar disponibilidad con el asesor", blank.StockQty))
+	case blank.StockQty-in.Cantidad <= blank.StockAlert:
+		res.Avisos = append(res.Avisos, "Stock limitado — confirmar disponibilidad con el asesor")
+	}
+	return res, nil
+}
+
+// engrave cotiza el grabado de todas las unidades con el Calculator: el blank
+// pone el material, sin corte, y cada cara es un trabajo aparte (se voltean las piezas)
+func (c *Configurator) engrave(ctx context.Context, config *pricing.PricingConfig, b *models.Blank, in Input) (*Engraving, error) {
+	if b.MaterialID == nil || b.TechnologyID == nil {
+		return nil, ErrNotEngravable
+	}
+	if err := checkEngraveArea(b, in.GrabadoAltoCM, in.GrabadoAnchoCM); err != nil {
+		return nil, err
+	}
+	caras := max(in.Caras, 1)
+	if caras > max(b.MaxSides, 1) {
+		return nil, fmt.Errorf("%w: %s se graba por %d cara(s)", ErrSides, b.Name, max(b.MaxSides, 1))
+	}
+	engraveType := in.EngraveTypeID
+	if engraveType == 0 {
+		engraveType = defaultEngraveType
+	}
+	et := config.GetEngraveType(engraveType)
+	if et == nil {
+		return nil, fmt.Errorf("%w: %d", ErrEngraveType, engraveType)
+	}
+
+	analysis := pricing.BuildSyntheticAnalysis(in.GrabadoAltoCM*10, in.GrabadoAnchoCM*10, false, engraveType)
+	priced, err := c.calculator.WithContext(ctx).Calculate(
+		analysis,
+		*b.TechnologyID,
+		*b.MaterialID,
+		engraveType,
+		b.Thickness,
+		in.Cantidad,
+		false, // el material es el blank, ya cobrado
+		nil,
+		true,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error calculando grabado: %w", err)
+	}
+
+	// PriceFinal = MAX(Hybrid, Value) — igual que ToQuoteModel
+	perSide := models.ConvertCurrency(math.Max(priced.PriceHybridTotal, priced.PriceValueTotal),
+		priced.BaseCurrency, models.CurrencyCRC, priced.ExchangeRate)
+	perSide = models.RoundCurrency(perSide, models.CurrencyCRC)
+	subtotal := perSide * float64(caras)
+
+	e := &Engraving{
+		AltoCM:         in.GrabadoAltoCM,
+		AnchoCM:        in.GrabadoAnchoCM,
+		Caras:          caras,
+		EngraveTypeID:  engraveType,
+		TipoGrabado:    et.Name,
+		CostoPorCara:   perSide,
+		PrecioUnitario: models.RoundCurrency(subtotal/float64(in.Cantidad), models.CurrencyCRC),
+		Subtotal:       subtotal,
+	}
+	if m := config.GetMaterial(*b.MaterialID); m != nil {
+		e.Material = m.Name
+	}
+	if t := config.GetTechnology(*b.TechnologyID); t != nil {
+		e.Tecnologia = t.Name
+	}
+	return e, nil
+}
+
+// checkEngraveArea valida las medidas contra el área grabable del blank; la
+// pieza se puede girar, así que alto y ancho se comparan en cualquier orientación
+func checkEngraveArea(b *models.Blank, alto, ancho float64) error {
+	if alto <= 0 || ancho <= 0 {
+		return fmt.Errorf("%w: indicá alto y ancho del grabado en cm", ErrEngraveArea)
+	}
+	maxAlto, maxAncho := b.EngraveMaxAltoCM, b.EngraveMaxAnchoCM
+	if maxAlto <= 0 || maxAncho <= 0 {
+		return nil
+	}
+	fits := (alto <= maxAlto && ancho <= maxAncho) || (alto <= maxAncho && ancho <= maxAlto)
+	if !fits {
+		return fmt.Errorf("%w: el máximo grabable de %s es %g × %g cm", ErrEngraveArea, b.Name, maxAlto, maxAncho)
+	}
+	return nil
+}
+
+// UnitPrice retorna el precio unitario para la cantidad según price_breaks y la
+// cantidad del tramo aplicado. Si qty no alcanza ningún tramo, base_price (tramo 0).
+func UnitPrice(b *models.Blank, qty int) (price, tier int) {
+	var breaks []struct {
+		Qty       int `json:"qty"`
+		UnitPrice int `json:"unit_price"`
+	}
+	if err := json.Unmarshal(b.PriceBreaks, &breaks); err != nil || len(breaks) == 0 {
+		return b.BasePrice, 0
+	}
+	// Ordenar de mayor a menor para encontrar el tramo más alto que aplica
+	sort.Slice(breaks, func(i, j int) bool { return breaks[i].Qty > breaks[j].Qty })
+	for _, br := range breaks {
+		if qty >= br.Qty {
+			return br.UnitPrice, br.Qty
+		}
+	}
+	return b.BasePrice, 0
+}
+
+// AccessoryLines cotiza los accesorios pedidos (por nombre, sin distinguir
+// mayúsculas) para qty unidades, redondeando a paquetes completos de min_qty_pack
+func AccessoryLines(b *models.Blank, names []string, qty int) ([]AccessoryLine, error) {
+	var available []struct {
+		Name       string `json:"name"`
+		Price      int    `json:"price"`
+		MinQtyPack int    `json:"min_qty_pack"`
+	}
+	_ = json.Unmarshal(b.Accessories, &available)
+
+	lines := []AccessoryLine{}
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		found := false
+		for _, a := range available {
+			if !strings.EqualFold(a.Name, name) {
+				continue
+			}
+			pack := max(a.MinQtyPack, 1)
+			packs := (qty + pack - 1) / pack
+			lines = append(lines, AccessoryLine{
+				Nombre:         a.Name,
+				PrecioUnitario: a.Price,
+				Paquete:        pack,
+				Paquetes:       packs,
+				Cantidad:       packs * pack,
+				Subtotal:       packs * pack * a.Price,
+			})
+			found = true
+			break
+		}
+		if !found {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownAccessory, name)
+		}
+	}
+	return lines, nil
+}
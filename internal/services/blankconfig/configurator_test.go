@@ -0,0 +1,74 @@
+package blankconfig
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+	"gorm.io/datatypes"
+)
+
+func testBlank() *models.Blank {
+	return &models.Blank{
+		Name:              "Llavero acrílico 5cm",
+		BasePrice:         300,
+		MinQty:            10,
+		PriceBreaks:       datatypes.JSON(`[{"qty": 25, "unit_price": 240}, {"qty": 50, "unit_price": 220}]`),
+		Accessories:       datatypes.JSON(`[{"name": "Argolla metálica", "price": 150, "min_qty_pack": 25}]`),
+		EngraveMaxAltoCM:  4,
+		EngraveMaxAnchoCM: 3,
+	}
+}
+
+func TestUnitPrice(t *testing.T) {
+	b := testBlank()
+	tests := []struct {
+		qty, price, tier int
+	}{
+		{10, 300, 0},
+		{25, 240, 25},
+		{49, 240, 25},
+		{120, 220, 50},
+	}
+	for _, tt := range tests {
+		price, tier := UnitPrice(b, tt.qty)
+		if price != tt.price || tier != tt.tier {
+			t.Errorf("UnitPrice(%d) = %d, %d; want %d, %d", tt.qty, price, tier, tt.price, tt.tier)
+		}
+	}
+}
+
+func TestAccessoryLinesRoundsToPacks(t *testing.T) {
+	lines, err := AccessoryLines(testBlank(), []string{"argolla METÁLICA"}, 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 1 {
+		t.Fatalf("len(lines) = %d, want 1", len(lines))
+	}
+	l := lines[0]
+	if l.Paquetes != 2 || l.Cantidad != 50 || l.Subtotal != 50*150 {
+		t.Errorf("línea = %+v; want 2 paquetes, 50 unidades, ₡7500", l)
+	}
+
+	if _, err := AccessoryLines(testBlank(), []string{"Cadena"}, 30); !errors.Is(err, ErrUnknownAccessory) {
+		t.Errorf("accesorio desconocido: err = %v, want ErrUnknownAccessory", err)
+	}
+}
+
+func TestCheckEngraveArea(t *testing.T) {
+	b := testBlank()
+	if err := checkEngraveArea(b, 3, 4); err != nil {
+		t.Errorf("girado debería caber: %v", err)
+	}
+	if err := checkEngraveArea(b, 4.5, 3); !errors.Is(err, ErrEngraveArea) {
+		t.Errorf("4.5 × 3 no cabe en 4 × 3: err = %v", err)
+	}
+	if err := checkEngraveArea(b, 0, 3); !errors.Is(err, ErrEngraveArea) {
+		t.Errorf("sin alto: err = %v", err)
+	}
+	b.EngraveMaxAltoCM, b.EngraveMaxAnchoCM = 0, 0
+	if err := checkEngraveArea(b, 20, 20); err != nil {
+		t.Errorf("sin límite configurado: %v", err)
+	}
+}
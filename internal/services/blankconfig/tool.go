@@ -0,0 +1,87 @@
+package blankconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/llm"
+)
+
+// Tool es la declaración de configurar_blank, la misma para todos los agentes
+func Tool() llm.Tool {
+	return llm.Tool{
+		Name: "configurar_blank",
+		Description: "Cotiza un blank del catálogo (llaveros, medallas, etc.) con cantidad, accesorios y grabado, con el desglose completo: " +
+			"precio por tramo de volumen, accesorios por paquete, costo del grabado por cara, subtotal, IVA y total. " +
+			"Usar después de consultar_blank, cuando ya se sabe cuál blank y cuántas unidades. No sumes montos a mano: usá los de esta tool.",
+		Parameters: &llm.Schema{
+			Type: llm.TypeObject,
+			Properties: map[string]*llm.Schema{
+				"blank_id": {Type: llm.TypeInteger, Description: "ID del blank (de consultar_blank o del catálogo)"},
+				"cantidad": {Type: llm.TypeInteger, Description: "Unidades; debe alcanzar el min_qty del blank"},
+				"accesorios": {
+					Type:        llm.TypeArray,
+					Items:       &llm.Schema{Type: llm.TypeString},
+					Description: "Nombres de los accesorios_opcionales que el cliente quiere, ej: [\"Argolla metálica\"]. Vacío si no pidió",
+				},
+				"grabado_alto_cm":  {Type: llm.TypeNumber, Description: "Alto del grabado por cara en cm. 0 o sin incluir = sin grabado"},
+				"grabado_ancho_cm": {Type: llm.TypeNumber, Description: "Ancho del grabado por cara en cm"},
+				"engrave_type_id":  {Type: llm.TypeInteger, Description: "1=Vectorial, 2=Rasterizado, 3=Fotograbado, 4=3D/Relieve. Default: 1"},
+				"caras":            {Type: llm.TypeInteger, Description: "Caras a grabar: 1 (default) o 2"},
+			},
+			Required: []string{"blank_id", "cantidad"},
+		},
+	}
+}
+
+// Handler ejecuta configurar_blank. Los errores de configuración (mínimo,
+// accesorio, área) vuelven como campo error para que el agente los explique.
+func (c *Configurator) Handler() llm.ToolHandler {
+	return func(ctx context.Context, args map[string]any) (map[string]any, error) {
+		in := Input{
+			BlankID:        uint(number(args, "blank_id")),
+			Cantidad:       int(number(args, "cantidad")),
+			GrabadoAltoCM:  number(args, "grabado_alto_cm"),
+			GrabadoAnchoCM: number(args, "grabado_ancho_cm"),
+			EngraveTypeID:  uint(number(args, "engrave_type_id")),
+			Caras:          int(number(args, "caras")),
+		}
+		if list, ok := args["accesorios"].([]any); ok {
+			for _, v := range list {
+				if name, ok := v.(string); ok {
+					in.Accesorios = append(in.Accesorios, name)
+				}
+			}
+		}
+
+		res, err := c.Configure(ctx, in)
+		if err != nil {
+			if IsConfigError(err) {
+				return map[string]any{"error": err.Error()}, nil
+			}
+			return nil, fmt.Errorf("configurar_blank: %w", err)
+		}
+		return map[string]any{"configuracion": res}, nil
+	}
+}
+
+// IsConfigError indica si err es un problema de la configuración pedida y no del servidor
+func IsConfigError(err error) bool {
+	for _, e := range []error{ErrBlankNotFound, ErrBelowMinimum, ErrUnknownAccessory, ErrNotEngravable, ErrEngraveArea, ErrSides, ErrEngraveType} {
+		if errors.Is(err, e) {
+			return true
+		}
+	}
+	return false
+}
+
+func number(args map[string]any, key string) float64 {
+	switch v := args[key].(type) {
+	case float64:
+		return v
+	case int:
+		return float64(v)
+	}
+	return 0
+}
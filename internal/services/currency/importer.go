@@ -0,0 +1,200 @@
+// Package currency importa tipos de cambio desde archivos CSV o exportaciones del BCCR.
+package currency
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+)
+
+// ErrEmptyFile indica que el archivo no trae filas de tipo de cambio
+var ErrEmptyFile = errors.New("archivo sin tipos de cambio")
+
+// RowError describe una fila que no se pudo interpretar
+type RowError struct {
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+// ImportResult agrupa las filas válidas y los errores por línea
+type ImportResult struct {
+	Rates  []models.ExchangeRate `json:"rates"`
+	Errors []RowError            `json:"errors,omitempty"`
+}
+
+// Formatos de fecha aceptados: ISO, dd/mm/yyyy (BCCR) y "18 Oct 2026" (BCCR en español)
+var dateLayouts = []string{
+	"2006-01-02",
+	"02/01/2006",
+	"2/1/2006",
+	"02-01-2006",
+	"2 Jan 2006",
+	"02 Jan 2006",
+}
+
+// Meses abreviados del BCCR → inglés para time.Parse
+var spanishMonths = strings.NewReplacer(
+	"Ene", "Jan", "Abr", "Apr", "Ago", "Aug", "Set", "Sep", "Sept", "Sep", "Dic", "Dec",
+	"ene", "Jan", "abr", "Apr", "ago", "Aug", "set", "Sep", "dic", "Dec",
+)
+
+// ParseRatesCSV lee un CSV de tipos de cambio USD→CRC.
+//
+// Columnas reconocidas por encabezado (en cualquier orden): fecha/date, compra/buy, venta/sell.
+// Sin encabezado se asume fecha,compra,venta. Acepta coma o punto y coma como separador
+// y coma decimal ("505,32"), que es como exporta el BCCR.
+// source debe ser models.ExchangeRateSourceCSV o models.ExchangeRateSourceBCCR.
+func ParseRatesCSV(r io.Reader, source string) (*ImportResult, error) {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("currency: error leyendo archivo: %w", err)
+	}
+	raw = bytes.TrimPrefix(raw, []byte("\xef\xbb\xbf")) // BOM de Excel
+
+	reader := csv.NewReader(bytes.NewReader(raw))
+	reader.Comma = detectDelimiter(raw)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("currency: CSV inválido: %w", err)
+	}
+
+	dateCol, buyCol, sellCol := 0, 1, 2
+	start := 0
+	if len(records) > 0 {
+		if d, b, s, ok := headerColumns(records[0]); ok {
+			dateCol, buyCol, sellCol = d, b, s
+			start = 1
+		}
+	}
+
+	result := &ImportResult{}
+	for i := start; i < len(records); i++ {
+		rec := records[i]
+		line := i + 1
+		if isBlank(rec) {
+			continue
+		}
+		if len(rec) <= dateCol || len(rec) <= buyCol {
+			result.Errors = append(result.Errors, RowError{Line: line, Message: "faltan columnas"})
+			continue
+		}
+
+		date, err := parseDate(rec[dateCol])
+		if err != nil {
+			result.Errors = append(result.Errors, RowError{Line: line, Message: err.Error()})
+			continue
+		}
+		buy, err := parseAmount(rec[buyCol])
+		if err != nil {
+			result.Errors = append(result.Errors, RowError{Line: line, Message: "compra: " + err.Error()})
+			continue
+		}
+		sell := buy
+		if sellCol < len(rec) && strings.TrimSpace(rec[sellCol]) != "" {
+			if sell, err = parseAmount(rec[sellCol]); err != nil {
+				result.Errors = append(result.Errors, RowError{Line: line, Message: "venta: " + err.Error()})
+				continue
+			}
+		}
+		if buy <= 0 || sell <= 0 {
+			result.Errors = append(result.Errors, RowError{Line: line, Message: "tipo de cambio debe ser mayor a 0"})
+			continue
+		}
+
+		result.Rates = append(result.Rates, models.ExchangeRate{
+			RateDate:      date,
+			BaseCurrency:  models.CurrencyUSD,
+			QuoteCurrency: models.CurrencyCRC,
+			BuyRate:       buy,
+			SellRate:      sell,
+			Source:        source,
+		})
+	}
+
+	if len(result.Rates) == 0 && len(result.Errors) == 0 {
+		return nil, ErrEmptyFile
+	}
+	return result, nil
+}
+
+func detectDelimiter(raw []byte) rune {
+	firstLine := raw
+	if i := bytes.IndexByte(raw, '\n'); i >= 0 {
+		firstLine = raw[:i]
+	}
+	if bytes.Count(firstLine, []byte(";")) > 0 {
+		return ';'
+	}
+	if bytes.Count(firstLine, []byte("\t")) > 0 {
+		return '\t'
+	}
+	return ','
+}
+
+func headerColumns(rec []string) (dateCol, buyCol, sellCol int, ok bool) {
+	dateCol, buyCol, sellCol = -1, -1, -1
+	for i, h := range rec {
+		switch strings.ToLower(strings.TrimSpace(h)) {
+		case "fecha", "date", "rate_date":
+			dateCol = i
+		case "compra", "buy", "buy_rate", "tipo cambio compra":
+			buyCol = i
+		case "venta", "sell", "sell_rate", "tipo cambio venta":
+			sellCol = i
+		}
+	}
+	if dateCol < 0 || (buyCol < 0 && sellCol < 0) {
+		return 0, 1, 2, false
+	}
+	// Solo una columna de monto: usarla para compra y venta
+	if buyCol < 0 {
+		buyCol = sellCol
+	}
+	if sellCol < 0 {
+		sellCol = buyCol
+	}
+	return dateCol, buyCol, sellCol, true
+}
+
+func parseDate(s string) (time.Time, error) {
+	s = spanishMonths.Replace(strings.TrimSpace(s))
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("fecha inválida: %q", s)
+}
+
+// parseAmount acepta "505.32", "505,32" y "1.505,32"
+func parseAmount(s string) (float64, error) {
+	s = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(s), "₡"))
+	if strings.Contains(s, ",") {
+		s = strings.ReplaceAll(s, ".", "")
+		s = strings.ReplaceAll(s, ",", ".")
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("monto inválido: %q", s)
+	}
+	return v, nil
+}
+
+func isBlank(rec []string) bool {
+	for _, f := range rec {
+		if strings.TrimSpace(f) != "" {
+			return false
+		}
+	}
+	return true
+}
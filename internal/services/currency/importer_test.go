@@ -0,0 +1,72 @@
+package currency
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+)
+
+func TestParseRatesCSV_WithHeader(t *testing.T) {
+	input := "fecha,compra,venta\n2026-10-16,498.50,505.25\n2026-10-17,499.00,506.10\n"
+
+	result, err := ParseRatesCSV(strings.NewReader(input), models.ExchangeRateSourceCSV)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(result.Rates) != 2 {
+		t.Fatalf("expected 2 rates, got %d", len(result.Rates))
+	}
+	if result.Rates[1].SellRate != 506.10 || result.Rates[1].BuyRate != 499.00 {
+		t.Errorf("unexpected rate: %+v", result.Rates[1])
+	}
+	if result.Rates[0].Source != models.ExchangeRateSourceCSV {
+		t.Errorf("expected source csv, got %s", result.Rates[0].Source)
+	}
+}
+
+func TestParseRatesCSV_BCCRFormat(t *testing.T) {
+	// Exportación BCCR: punto y coma, coma decimal, mes en español, columnas en otro orden
+	input := "\xef\xbb\xbfVenta;Fecha;Compra\n505,25;16 Set 2026;498,50\n"
+
+	result, err := ParseRatesCSV(strings.NewReader(input), models.ExchangeRateSourceBCCR)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(result.Rates) != 1 {
+		t.Fatalf("expected 1 rate, got %d (errors: %+v)", len(result.Rates), result.Errors)
+	}
+	r := result.Rates[0]
+	if r.RateDate.Format("2006-01-02") != "2026-09-16" {
+		t.Errorf("unexpected date: %s", r.RateDate)
+	}
+	if r.BuyRate != 498.50 || r.SellRate != 505.25 {
+		t.Errorf("unexpected amounts: buy=%v sell=%v", r.BuyRate, r.SellRate)
+	}
+}
+
+func TestParseRatesCSV_RowErrors(t *testing.T) {
+	input := "16/10/2026,498.5,505.2\nno-es-fecha,1,2\n17/10/2026,abc,505\n"
+
+	result, err := ParseRatesCSV(strings.NewReader(input), models.ExchangeRateSourceCSV)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(result.Rates) != 1 {
+		t.Errorf("expected 1 valid rate, got %d", len(result.Rates))
+	}
+	if len(result.Errors) != 2 {
+		t.Fatalf("expected 2 row errors, got %d", len(result.Errors))
+	}
+	if result.Errors[0].Line != 2 || result.Errors[1].Line != 3 {
+		t.Errorf("unexpected error lines: %+v", result.Errors)
+	}
+}
+
+func TestParseRatesCSV_Empty(t *testing.T) {
+	_, err := ParseRatesCSV(strings.NewReader("fecha,compra,venta\n"), models.ExchangeRateSourceCSV)
+	if !errors.Is(err, ErrEmptyFile) {
+		t.Errorf("expected ErrEmptyFile, got %v", err)
+	}
+}
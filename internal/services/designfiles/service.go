@@ -0,0 +1,493 @@
+// Package designfiles analiza y cotiza los archivos de diseño (SVG o DXF) que los
+// clientes mandan como documento por los canales de chat, y las imágenes que
+// mandan como foto una vez vectorizadas con el tracer.
+//
+// El archivo pasa por el mismo svgengine.Analyzer y pricing.Calculator que el
+// cotizador web, así que el precio sale de la geometría real y no del rectángulo
+// sintético que usa el estimado por medidas. Si el teléfono del cliente coincide
+// con una cuenta registrada, el análisis queda guardado en esa cuenta.
+package designfiles
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/channels"
+	"github.com/alonsoalpizar/fabricalaser/internal/database"
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+	"github.com/alonsoalpizar/fabricalaser/internal/repository"
+	"github.com/alonsoalpizar/fabricalaser/internal/services/pricing"
+	"github.com/alonsoalpizar/fabricalaser/internal/services/svgengine"
+	"github.com/alonsoalpizar/fabricalaser/internal/services/tax"
+	"github.com/alonsoalpizar/fabricalaser/internal/services/tracer"
+)
+
+var (
+	ErrNoFile          = errors.New("no hay un archivo de diseño reciente en esta conversación")
+	ErrNoImage         = errors.New("no hay una imagen reciente en esta conversación")
+	ErrUnsupportedFile = errors.New("solo se pueden analizar archivos SVG o DXF")
+	ErrFileTooLarge    = errors.New("el archivo supera el máximo de 5MB")
+	ErrEmptyDesign     = errors.New("el archivo no contiene elementos procesables")
+	ErrIncompatible    = errors.New("combinación de tecnología, material y grosor no disponible")
+)
+
+const (
+	maxFileSize = 5 * 1024 * 1024 // igual que el upload del cotizador web
+
+	// fileTTL: el último archivo de la conversación vive lo mismo que la sesión del chat
+	fileTTL = 4 * time.Hour
+
+	defaultTechCode  = "CO2"
+	defaultThickness = 3.0
+	defaultEngrave   = 1 // grabado vectorial
+)
+
+// Options son los parámetros de cotización del archivo. Los campos en cero toman
+// los valores por defecto (CO2, primer material cortable compatible, 3 mm, 1 unidad).
+type Options struct {
+	TechnologyID     uint    `json:"technology_id"`
+	MaterialID       uint    `json:"material_id"`
+	EngraveTypeID    uint    `json:"engrave_type_id"`
+	Thickness        float64 `json:"thickness"`
+	Quantity         int     `json:"cantidad"`
+	MaterialIncluded bool    `json:"material_included"`
+	CutTechnologyID  *uint   `json:"cut_technology_id,omitempty"`
+	IgnoreCutLines   bool    `json:"ignore_cut_lines,omitempty"`
+	Currency         string  `json:"moneda,omitempty"`
+}
+
+// Estimate es el precio del archivo con la geometría usada para calcularlo
+type Estimate struct {
+	AnalysisID     uint    `json:"analysis_id,omitempty"` // 0 si el cliente no está registrado
+	Archivo        string  `json:"archivo"`
+	AnchoMM        float64 `json:"ancho_mm"`
+	AltoMM         float64 `json:"alto_mm"`
+	CorteMM        float64 `json:"corte_mm"`
+	GrabadoMM      float64 `json:"grabado_vectorial_mm"`
+	GrabadoAreaMM2 float64 `json:"grabado_raster_mm2"`
+	Tecnologia     string  `json:"tecnologia"`
+	Material       string  `json:"material"`
+	Grosor         float64 `json:"grosor_mm"`
+	Cantidad       int     `json:"cantidad"`
+	Subtotal       float64 `json:"subtotal"` // Sin IVA
+	PrecioUnitario float64 `json:"precio_unitario"`
+	TarifaIVA      float64 `json:"tarifa_iva"`
+	IVA            float64 `json:"iva"`
+	Total          float64 `json:"total"`
+	ModoIVA        string  `json:"modo_iva"`
+	Moneda         string  `json:"moneda"`
+	Advertencia    string  `json:"advertencia,omitempty"`
+}
+
+type Service struct {
+	analyzer     *svgengine.Analyzer
+	calculator   *pricing.Calculator
+	configLoader *pricing.ConfigLoader
+	analysisRepo *repository.SVGAnalysisRepository
+	userRepo     *repository.UserRepository
+	store        channels.Store
+}
+
+// NewService construye el servicio; store guarda el último archivo de cada conversación
+// para poder recotizarlo con otro material o cantidad.
+func NewService(store channels.Store) *Service {
+	configLoader := pricing.NewConfigLoader(database.Get())
+	return &Service{
+		analyzer:     svgengine.NewAnalyzer(),
+		calculator:   pricing.NewCalculator(configLoader),
+		configLoader: configLoader,
+		analysisRepo: repository.NewSVGAnalysisRepository(),
+		userRepo:     repository.NewUserRepository(),
+		store:        store,
+	}
+}
+
+// Supports indica si el documento es un SVG o DXF, por extensión o por mime type
+func (s *Service) Supports(filename, mimeType string) bool {
+	_, ok := fileKind(filename, mimeType)
+	return ok
+}
+
+// AnalyzeDocument analiza el archivo, lo liga a la cuenta del cliente si existe,
+// y retorna la respuesta para el cliente con la geometría real y el precio de
+// referencia. Los problemas del archivo (vacío, inválido, muy grande) se
+// responden como texto; error solo en fallas internas.
+func (s *Service) AnalyzeDocument(ctx context.Context, from channels.Identity, data []byte, mimeType, filename string) (string, error) {
+	analysis, err := s.analyze(ctx, from, data, mimeType, filename)
+	switch {
+	case errors.Is(err, ErrUnsupportedFile):
+		return "Por ahora solo puedo leer archivos *SVG* o *DXF*. " +
+			"Si tenés el diseño en otro formato, exportalo a SVG o DXF y mandámelo de nuevo.", nil
+	case errors.Is(err, ErrFileTooLarge):
+		return "El archivo pesa más de 5MB. ¿Me lo podés mandar más liviano?", nil
+	case errors.Is(err, svgengine.ErrInvalidDXF), errors.Is(err, ErrEmptyDesign):
+		return "No encontré líneas para cortar o grabar en *" + filename + "*. " +
+			"En SVG usá trazo rojo (#FF0000) para corte, azul (#0000FF) para grabado vectorial " +
+			"y relleno negro para grabado raster.", nil
+	case err != nil:
+		return "", err
+	}
+
+	estimate, err := s.price(analysis, Options{})
+	if err != nil {
+		// El análisis ya está guardado; sin precio igual se le muestra la geometría
+		slog.Warn("designfiles: no se pudo calcular precio de referencia", "archivo", filename, "error", err)
+	}
+	return summary(analysis, estimate), nil
+}
+
+// Quote recotiza el último archivo de la conversación con las opciones dadas
+func (s *Service) Quote(ctx context.Context, from channels.Identity, opts Options) (*Estimate, error) {
+	raw, err := s.store.Get(ctx, fileKey(from))
+	if err != nil {
+		return nil, ErrNoFile
+	}
+	var analysis models.SVGAnalysis
+	if err := json.Unmarshal([]byte(raw), &analysis); err != nil {
+		return nil, fmt.Errorf("designfiles: error leyendo archivo guardado: %w", err)
+	}
+	return s.price(&analysis, opts)
+}
+
+func (s *Service) analyze(ctx context.Context, from channels.Identity, data []byte, mimeType, filename string) (*models.SVGAnalysis, error) {
+	kind, ok := fileKind(filename, mimeType)
+	if !ok {
+		return nil, ErrUnsupportedFile
+	}
+	if len(data) > maxFileSize {
+		return nil, ErrFileTooLarge
+	}
+
+	svg := string(data)
+	if kind == "dxf" || svgengine.IsDXF(svg) {
+		converted, err := svgengine.ConvertDXF(svg)
+		if err != nil {
+			return nil, err
+		}
+		svg = converted
+	} else if !strings.Contains(svg, "<svg") {
+		return nil, ErrEmptyDesign
+	}
+
+	result, err := s.analyzer.Analyze(svg)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrEmptyDesign, err)
+	}
+	analysis := s.analyzer.ToModel(result, 0, filename, svg)
+	if !analysis.HasAnyWork() {
+		return nil, ErrEmptyDesign
+	}
+
+	analysis = s.keep(ctx, from, analysis)
+	slog.Info("designfiles: archivo analizado",
+		"canal", from.Channel,
+		"from", from.ID,
+		"archivo", filename,
+		"analysis_id", analysis.ID,
+		"corte_mm", analysis.CutLengthMM,
+		"vector_mm", analysis.VectorLengthMM,
+		"raster_mm2", analysis.RasterAreaMM2,
+	)
+	return analysis, nil
+}
+
+// RememberImage guarda la última imagen de la conversación para poder
+// vectorizarla cuando el cliente diga las medidas. Las más grandes se ignoran.
+func (s *Service) RememberImage(ctx context.Context, from channels.Identity, data []byte, mimeType string) {
+	if len(data) > maxFileSize {
+		return
+	}
+	if err := s.store.Set(ctx, imageKey(from), base64.StdEncoding.EncodeToString(data), fileTTL); err != nil {
+		slog.Warn("designfiles: no se pudo guardar la imagen de la conversación", "key", from.Key, "error", err)
+	}
+}
+
+// TraceImage vectoriza la última imagen de la conversación al tamaño pedido y la
+// cotiza como archivo: queda como el archivo actual, así cotizar_archivo la recotiza.
+func (s *Service) TraceImage(ctx context.Context, from channels.Identity, trace tracer.Options, opts Options) (*Estimate, error) {
+	raw, err := s.store.Get(ctx, imageKey(from))
+	if err != nil {
+		return nil, ErrNoImage
+	}
+	data, err := base64.StdEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, fmt.Errorf("designfiles: error leyendo imagen guardada: %w", err)
+	}
+
+	if trace.Mode != tracer.ModeVector {
+		trace.Mode = tracer.ModeRaster
+	}
+	traced, err := tracer.Trace(data, trace)
+	if err != nil {
+		return nil, err
+	}
+	result, err := s.analyzer.Analyze(traced.SVG)
+	if err != nil {
+		return nil, fmt.Errorf("designfiles: error analizando imagen vectorizada: %w", err)
+	}
+	filename := fmt.Sprintf("imagen-%s-%gx%gmm.svg", trace.Mode, traced.WidthMM, traced.HeightMM)
+	analysis := s.keep(ctx, from, s.analyzer.ToModel(result, 0, filename, traced.SVG))
+
+	slog.Info("designfiles: imagen vectorizada",
+		"canal", from.Channel,
+		"from", from.ID,
+		"analysis_id", analysis.ID,
+		"formas", traced.Shapes,
+		"huecos", traced.Holes,
+		"ancho_mm", traced.WidthMM,
+		"alto_mm", traced.HeightMM,
+	)
+	return s.price(analysis, opts)
+}
+
+// keep liga el análisis a la cuenta del cliente (solo canales con teléfono) y lo
+// deja como archivo actual de la conversación.
+func (s *Service) keep(ctx context.Context, from channels.Identity, analysis *models.SVGAnalysis) *models.SVGAnalysis {
+	if from.Phone != "" {
+		if user, err := s.userRepo.FindByTelefono(localPhone(from.Phone)); err == nil {
+			analysis = s.saveForUser(user.ID, analysis)
+		}
+	}
+
+	// Sin elementos ni SVG: solo la geometría agregada
+	stash := *analysis
+	stash.Elements = nil
+	if raw, err := json.Marshal(stash); err == nil {
+		if err := s.store.Set(ctx, fileKey(from), string(raw), fileTTL); err != nil {
+			slog.Warn("designfiles: no se pudo guardar el archivo de la conversación", "key", from.Key, "error", err)
+		}
+	}
+	return analysis
+}
+
+// saveForUser persiste el análisis en la cuenta (reutiliza el existente si el
+// archivo ya fue subido). Si falla, se sigue con el análisis en memoria.
+func (s *Service) saveForUser(userID uint, analysis *models.SVGAnalysis) *models.SVGAnalysis {
+	if existing, _ := s.analysisRepo.FindByFileHash(userID, analysis.FileHash); existing != nil {
+		return existing
+	}
+	analysis.UserID = userID
+	if err := s.analysisRepo.Create(analysis); err != nil {
+		slog.Error("designfiles: error guardando análisis", "user_id", userID, "error", err)
+		analysis.ID = 0
+		analysis.UserID = 0
+	}
+	return analysis
+}
+
+func (s *Service) price(analysis *models.SVGAnalysis, opts Options) (*Estimate, error) {
+	config, err := s.configLoader.Load()
+	if err != nil {
+		return nil, fmt.Errorf("designfiles: error cargando configuración: %w", err)
+	}
+
+	if opts.Thickness <= 0 {
+		opts.Thickness = defaultThickness
+	}
+	if opts.Quantity < 1 {
+		opts.Quantity = 1
+	}
+	if opts.EngraveTypeID == 0 {
+		opts.EngraveTypeID = defaultEngrave
+	}
+	if opts.TechnologyID == 0 {
+		opts.TechnologyID = defaultTechnology(config)
+	}
+	if opts.MaterialID == 0 {
+		opts.MaterialID = defaultMaterial(config, opts.TechnologyID, opts.Thickness)
+	}
+	if opts.TechnologyID == 0 || opts.MaterialID == 0 {
+		return nil, ErrIncompatible
+	}
+	if ok, _ := config.IsCompatible(opts.TechnologyID, opts.MaterialID, opts.Thickness); !ok {
+		return nil, ErrIncompatible
+	}
+	currency := models.NormalizeCurrency(opts.Currency)
+
+	result, err := s.calculator.Calculate(
+		analysis,
+		opts.TechnologyID,
+		opts.MaterialID,
+		opts.EngraveTypeID,
+		opts.Thickness,
+		opts.Quantity,
+		opts.MaterialIncluded,
+		opts.CutTechnologyID,
+		opts.IgnoreCutLines,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("designfiles: error calculando precio: %w", err)
+	}
+
+	// PriceFinal = MAX(Hybrid, Value) — igual que ToQuoteModel; IVA sobre moneda base
+	basePrice := math.Max(result.PriceHybridTotal, result.PriceValueTotal)
+	iva := tax.For(config, tax.KindForMaterial(opts.MaterialIncluded), basePrice, nil, time.Now()).
+		Convert(result.BaseCurrency, currency, result.ExchangeRate)
+
+	est := &Estimate{
+		AnalysisID:     analysis.ID,
+		Archivo:        analysis.Filename,
+		AnchoMM:        round1(analysis.Width),
+		AltoMM:         round1(analysis.Height),
+		CorteMM:        round1(analysis.CutLengthMM),
+		GrabadoMM:      round1(analysis.VectorLengthMM),
+		GrabadoAreaMM2: round1(analysis.RasterAreaMM2),
+		Grosor:         opts.Thickness,
+		Cantidad:       opts.Quantity,
+		Subtotal:       iva.Subtotal,
+		PrecioUnitario: models.RoundCurrency(iva.Subtotal/float64(opts.Quantity), currency),
+		TarifaIVA:      iva.Rate,
+		IVA:            iva.Tax,
+		Total:          iva.Total,
+		ModoIVA:        iva.Display,
+		Moneda:         currency,
+	}
+	if tech := config.GetTechnology(opts.TechnologyID); tech != nil {
+		est.Tecnologia = tech.Name
+	}
+	if mat := config.GetMaterial(opts.MaterialID); mat != nil {
+		est.Material = mat.Name
+	}
+	switch result.Status {
+	case models.QuoteStatusNeedsReview:
+		est.Advertencia = "Este trabajo requiere revisión de un asesor antes de confirmar precio final"
+	case models.QuoteStatusRejected:
+		est.Advertencia = "Diseño complejo — precio de referencia solamente, requiere revisión"
+	}
+	return est, nil
+}
+
+// defaultTechnology es la tecnología CO2 (la única que corta)
+func defaultTechnology(config *pricing.PricingConfig) uint {
+	for id, tech := range config.Technologies {
+		if tech.IsActive && strings.EqualFold(tech.Code, defaultTechCode) {
+			return id
+		}
+	}
+	return 0
+}
+
+// defaultMaterial es el material cortable activo de menor ID compatible con la tecnología
+func defaultMaterial(config *pricing.PricingConfig, techID uint, thickness float64) uint {
+	var best uint
+	for id, mat := range config.Materials {
+		if !mat.IsActive || !mat.IsCuttable || (best != 0 && id > best) {
+			continue
+		}
+		if ok, _ := config.IsCompatible(techID, id, thickness); ok {
+			best = id
+		}
+	}
+	return best
+}
+
+// summary arma la respuesta para el cliente: geometría real y precio de referencia
+func summary(a *models.SVGAnalysis, est *Estimate) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Analicé tu archivo *%s* (%s × %s cm):\n", a.Filename, cm(a.Width), cm(a.Height))
+	if a.CutLengthMM > 0 {
+		fmt.Fprintf(&b, "• Corte: %s cm de recorrido\n", cm(a.CutLengthMM))
+	}
+	if a.VectorLengthMM > 0 {
+		fmt.Fprintf(&b, "• Grabado vectorial: %s cm de recorrido\n", cm(a.VectorLengthMM))
+	}
+	if a.RasterAreaMM2 > 0 {
+		fmt.Fprintf(&b, "• Grabado de área: %s cm²\n", cm(a.RasterAreaMM2/10))
+	}
+
+	if est != nil {
+		fmt.Fprintf(&b, "\nPrecio de referencia por 1 unidad en %s de %s mm (%s): %s\n",
+			est.Material, trimFloat(est.Grosor), est.Tecnologia, formatPrice(est))
+		if est.Advertencia != "" {
+			b.WriteString("⚠️ " + est.Advertencia + "\n")
+		}
+		b.WriteString("\n¿En qué material y cuántas unidades lo necesitás? Con eso te ajusto el precio.")
+	} else {
+		b.WriteString("\n¿En qué material y cuántas unidades lo necesitás? Con eso te paso el precio.")
+	}
+
+	if a.ID != 0 {
+		fmt.Fprintf(&b, "\n\nLo guardé en tu cuenta de fabricalaser.com (análisis #%d).", a.ID)
+	}
+	return b.String()
+}
+
+func formatPrice(est *Estimate) string {
+	symbol := "₡"
+	if est.Moneda == models.CurrencyUSD {
+		symbol = "$"
+	}
+	if est.ModoIVA == tax.DisplayInclusive {
+		return symbol + formatAmount(est.Total, est.Moneda) + " IVA incluido"
+	}
+	return symbol + formatAmount(est.Subtotal, est.Moneda) + " + IVA"
+}
+
+// formatAmount agrupa miles con espacio: 12500 → "12 500"; USD con 2 decimales
+func formatAmount(v float64, currency string) string {
+	if currency == models.CurrencyUSD {
+		return fmt.Sprintf("%.2f", v)
+	}
+	s := fmt.Sprintf("%.0f", math.Round(v))
+	var out []byte
+	for i := range s {
+		if i > 0 && (len(s)-i)%3 == 0 && s[i-1] != '-' {
+			out = append(out, ' ')
+		}
+		out = append(out, s[i])
+	}
+	return string(out)
+}
+
+// cm convierte mm a cm con un decimal y coma decimal: 1234 → "123,4"
+func cm(mm float64) string {
+	return strings.Replace(trimFloat(math.Round(mm)/10), ".", ",", 1)
+}
+
+func trimFloat(v float64) string {
+	return strings.TrimSuffix(strings.TrimRight(fmt.Sprintf("%.1f", v), "0"), ".")
+}
+
+func round1(v float64) float64 {
+	return math.Round(v*10) / 10
+}
+
+func fileKind(filename, mimeType string) (string, bool) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".svg":
+		return "svg", true
+	case ".dxf":
+		return "dxf", true
+	}
+	switch strings.ToLower(mimeType) {
+	case "image/svg+xml":
+		return "svg", true
+	case "image/vnd.dxf", "image/x-dxf", "application/dxf":
+		return "dxf", true
+	}
+	return "", false
+}
+
+func fileKey(from channels.Identity) string {
+	return "chat:file:" + from.Key
+}
+
+func imageKey(from channels.Identity) string {
+	return "chat:image:" + from.Key
+}
+
+// localPhone extrae el número local de CR desde el E.164: "50686091954" → "86091954"
+func localPhone(phone string) string {
+	if strings.HasPrefix(phone, "506") && len(phone) == 11 {
+		return phone[3:]
+	}
+	return phone
+}
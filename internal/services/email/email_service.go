@@ -7,6 +7,7 @@ import (
 	"log"
 	"net"
 	"net/smtp"
+	"strings"
 )
 
 const (
@@ -210,6 +211,84 @@ func buildPasswordResetBody(toEmail, nombre, token string) string {
 	return buf.String()
 }
 
+// SendNotification envía un aviso de cotización o pedido. A diferencia de los
+// demás correos es síncrono: el despachador de notificaciones registra el
+// resultado y reintenta si falla. body es texto plano (un párrafo por línea);
+// link, si viene, se muestra como botón.
+func SendNotification(toEmail, nombre, subject, body, link string) error {
+	return sendMail(toEmail, subject+" — FabricaLaser", buildNotificationBody(nombre, subject, body, link))
+}
+
+func buildNotificationBody(nombre, title, body, link string) string {
+	var buf bytes.Buffer
+
+	buf.WriteString(`<!DOCTYPE html>
+<html lang="es">
+<head>
+  <meta charset="UTF-8">
+  <meta name="viewport" content="width=device-width, initial-scale=1.0">
+  <style>
+    body { margin: 0; padding: 0; background-color: #f4f4f4; font-family: Arial, sans-serif; }
+    .wrap { max-width: 620px; margin: 32px auto; background: #ffffff; border-radius: 10px; overflow: hidden; box-shadow: 0 2px 12px rgba(0,0,0,0.1); }
+    .header { background: #9B2020; padding: 36px 32px; text-align: center; }
+    .header h1 { color: #ffffff; margin: 0; font-size: 22px; letter-spacing: 0.5px; }
+    .body { padding: 32px; color: #1a1a1a; font-size: 15px; line-height: 1.7; }
+    .body p { margin: 0 0 16px; }
+    .btn { display: inline-block; background: #9B2020; color: #ffffff; text-decoration: none; padding: 13px 32px; border-radius: 6px; font-size: 15px; font-weight: bold; margin: 8px 0; }
+    .footer { background: #1a1a1a; color: #9ca3af; font-size: 12px; text-align: center; padding: 20px 32px; line-height: 1.6; }
+    .footer a { color: #f87171; text-decoration: none; }
+  </style>
+</head>
+<body>
+<div class="wrap">
+
+  <div class="header">
+    <h1>`)
+	buf.WriteString(html.EscapeString(title))
+	buf.WriteString(`</h1>
+  </div>
+
+  <div class="body">
+    <p>Hola <strong>`)
+	buf.WriteString(html.EscapeString(nombre))
+	buf.WriteString(`</strong>,</p>
+`)
+	for _, line := range strings.Split(body, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			buf.WriteString("    <p>" + html.EscapeString(line) + "</p>\n")
+		}
+	}
+	if link != "" {
+		buf.WriteString(`
+    <p style="text-align: center; margin-top: 28px;">
+      <a href="`)
+		buf.WriteString(html.EscapeString(link))
+		buf.WriteString(`" class="btn">Ver cotización</a>
+    </p>
+`)
+	}
+	buf.WriteString(`
+    <p style="font-size: 13px; color: #6b7280; margin-top: 24px;">
+      Si no querés recibir estos avisos, desactivalos en tu perfil en <a href="`)
+	buf.WriteString(siteURL)
+	buf.WriteString(`" style="color:#9B2020;">fabricalaser.com</a>.
+    </p>
+  </div>
+
+  <div class="footer">
+    © FabricaLaser · Costa Rica<br>
+    <a href="`)
+	buf.WriteString(siteURL)
+	buf.WriteString(`">fabricalaser.com</a>
+  </div>
+
+</div>
+</body>
+</html>`)
+
+	return buf.String()
+}
+
 func sendMail(toEmail, subject, htmlBody string) error {
 	conn, err := net.Dial("tcp", smtpAddr)
 	if err != nil {
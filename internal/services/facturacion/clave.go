@@ -0,0 +1,97 @@
+// Package facturacion genera, firma y envía comprobantes electrónicos de Hacienda (v4.4)
+// a partir de cotizaciones convertidas.
+package facturacion
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// Código de país para la clave numérica
+const codigoPais = "506"
+
+// Situación del comprobante (posición 42 de la clave)
+const (
+	SituacionNormal       = "1"
+	SituacionContingencia = "2"
+	SituacionSinInternet  = "3"
+)
+
+// Tipos de identificación de Hacienda
+const (
+	TipoIDFisica   = "01"
+	TipoIDJuridica = "02"
+	TipoIDDIMEX    = "03"
+	TipoIDNITE     = "04"
+)
+
+// Zona horaria de Costa Rica (UTC-6, sin horario de verano)
+var zonaCR = time.FixedZone("CST", -6*60*60)
+
+var ErrInvalidCedula = errors.New("facturacion: cédula inválida para el comprobante")
+
+// Consecutivo arma el número consecutivo de 20 dígitos:
+// sucursal(3) + terminal(5) + tipo de comprobante(2) + número(10)
+func Consecutivo(sucursal, terminal int, docType string, numero int64) string {
+	return fmt.Sprintf("%03d%05d%s%010d", sucursal, terminal, docType, numero)
+}
+
+// Clave arma la clave numérica de 50 dígitos:
+// país(3) + día(2) + mes(2) + año(2) + cédula emisor(12) + consecutivo(20) + situación(1) + código de seguridad(8)
+func Clave(fecha time.Time, cedulaEmisor, consecutivo, situacion, codigoSeguridad string) (string, error) {
+	cedula := onlyDigits(cedulaEmisor)
+	if cedula == "" || len(cedula) > 12 {
+		return "", ErrInvalidCedula
+	}
+	if len(consecutivo) != 20 || len(codigoSeguridad) != 8 || len(situacion) != 1 {
+		return "", fmt.Errorf("facturacion: consecutivo, situación o código de seguridad con longitud inválida")
+	}
+	f := fecha.In(zonaCR)
+	return fmt.Sprintf("%s%02d%02d%02d%012s%s%s%s",
+		codigoPais, f.Day(), int(f.Month()), f.Year()%100, cedula, consecutivo, situacion, codigoSeguridad), nil
+}
+
+// NewCodigoSeguridad genera el código de seguridad aleatorio de 8 dígitos
+func NewCodigoSeguridad() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(100000000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%08d", n.Int64()), nil
+}
+
+// TipoIdentificacion deduce el tipo de identificación de Hacienda a partir de la cédula
+// y del tipo registrado en users.cedula_type ("fisica" o "juridica").
+func TipoIdentificacion(cedula, cedulaType string) (string, error) {
+	digits := onlyDigits(cedula)
+	switch {
+	case strings.EqualFold(cedulaType, "juridica") && len(digits) == 10:
+		return TipoIDJuridica, nil
+	case len(digits) == 9:
+		return TipoIDFisica, nil
+	case len(digits) == 10 && strings.HasPrefix(digits, "3"):
+		return TipoIDJuridica, nil
+	case len(digits) == 11 || len(digits) == 12:
+		return TipoIDDIMEX, nil
+	}
+	return "", ErrInvalidCedula
+}
+
+// IsValidCabys returns true for a 13-digit CABYS code
+func IsValidCabys(code string) bool {
+	return len(code) == 13 && onlyDigits(code) == code
+}
+
+func onlyDigits(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
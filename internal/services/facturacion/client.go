@@ -0,0 +1,295 @@
+package facturacion
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Estados devueltos por Hacienda en ind-estado
+const (
+	EstadoRecibido   = "recibido"
+	EstadoProcesando = "procesando"
+	EstadoAceptado   = "aceptado"
+	EstadoRechazado  = "rechazado"
+	EstadoError      = "error"
+)
+
+var ErrNotFoundInHacienda = errors.New("facturacion: Hacienda no tiene registro de la clave")
+
+// ReceptionRequest es el cuerpo que se envía a la API de recepción
+type ReceptionRequest struct {
+	Clave          string
+	Fecha          time.Time
+	Emisor         Identificacion
+	Receptor       *Identificacion
+	ComprobanteXML []byte // XML firmado
+}
+
+// ReceptionStatus es el estado de un comprobante en Hacienda
+type ReceptionStatus struct {
+	Clave        string
+	Estado       string // ind-estado
+	RespuestaXML []byte // Mensaje de Hacienda (decodificado), vacío mientras procesa
+}
+
+// ReceptionClient abstrae la API de recepción de Hacienda para poder usar
+// un stub local en pruebas y desarrollo.
+type ReceptionClient interface {
+	Send(ctx context.Context, req ReceptionRequest) error
+	Status(ctx context.Context, clave string) (*ReceptionStatus, error)
+}
+
+// ─── Cliente HTTP de Hacienda ────────────────────────────────────────────────
+
+// Endpoints por ambiente
+var haciendaEndpoints = map[string]struct {
+	tokenURL, recepcionURL, clientID string
+}{
+	"stag": {
+		tokenURL:     "https://idp.comprobanteselectronicos.go.cr/auth/realms/rut-stag/protocol/openid-connect/token",
+		recepcionURL: "https://api-sandbox.comprobanteselectronicos.go.cr/recepcion/v1/recepcion",
+		clientID:     "api-stag",
+	},
+	"prod": {
+		tokenURL:     "https://idp.comprobanteselectronicos.go.cr/auth/realms/rut/protocol/openid-connect/token",
+		recepcionURL: "https://api.comprobanteselectronicos.go.cr/recepcion/v1/recepcion",
+		clientID:     "api-prod",
+	},
+}
+
+// HaciendaClient habla con la API de recepción usando las credenciales de ATV
+type HaciendaClient struct {
+	httpClient   *http.Client
+	tokenURL     string
+	recepcionURL string
+	clientID     string
+	username     string
+	password     string
+
+	mu          sync.Mutex
+	token       string
+	tokenExpiry time.Time
+}
+
+// NewHaciendaClient creates a client for ambiente "stag" or "prod"
+func NewHaciendaClient(ambiente, username, password string) (*HaciendaClient, error) {
+	ep, ok := haciendaEndpoints[ambiente]
+	if !ok {
+		return nil, fmt.Errorf("facturacion: ambiente desconocido %q (use stag o prod)", ambiente)
+	}
+	return &HaciendaClient{
+		httpClient:   &http.Client{Timeout: 30 * time.Second},
+		tokenURL:     ep.tokenURL,
+		recepcionURL: ep.recepcionURL,
+		clientID:     ep.clientID,
+		username:     username,
+		password:     password,
+	}, nil
+}
+
+type haciendaIdentificacion struct {
+	TipoIdentificacion   string `json:"tipoIdentificacion"`
+	NumeroIdentificacion string `json:"numeroIdentificacion"`
+}
+
+type haciendaRecepcion struct {
+	Clave          string                  `json:"clave"`
+	Fecha          string                  `json:"fecha"`
+	Emisor         haciendaIdentificacion  `json:"emisor"`
+	Receptor       *haciendaIdentificacion `json:"receptor,omitempty"`
+	ComprobanteXML string                  `json:"comprobanteXml"`
+}
+
+// Send posts the signed document. Hacienda answers 202 and processes asynchronously.
+func (c *HaciendaClient) Send(ctx context.Context, req ReceptionRequest) error {
+	body := haciendaRecepcion{
+		Clave: req.Clave,
+		Fecha: req.Fecha.In(zonaCR).Format("2006-01-02T15:04:05-07:00"),
+		Emisor: haciendaIdentificacion{
+			TipoIdentificacion:   req.Emisor.Tipo,
+			NumeroIdentificacion: req.Emisor.Numero,
+		},
+		ComprobanteXML: base64.StdEncoding.EncodeToString(req.ComprobanteXML),
+	}
+	if req.Receptor != nil {
+		body.Receptor = &haciendaIdentificacion{
+			TipoIdentificacion:   req.Receptor.Tipo,
+			NumeroIdentificacion: req.Receptor.Numero,
+		}
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.do(ctx, http.MethodPost, c.recepcionURL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted && resp.StatusCode != http.StatusOK {
+		// Hacienda explica el motivo en el header X-Error-Cause
+		cause := resp.Header.Get("X-Error-Cause")
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("facturacion: Hacienda respondió %d: %s %s", resp.StatusCode, cause, strings.TrimSpace(string(respBody)))
+	}
+	return nil
+}
+
+// Status queries the processing state of a clave
+func (c *HaciendaClient) Status(ctx context.Context, clave string) (*ReceptionStatus, error) {
+	resp, err := c.do(ctx, http.MethodGet, c.recepcionURL+"/"+url.PathEscape(clave), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFoundInHacienda
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("facturacion: Hacienda respondió %d: %s", resp.StatusCode, resp.Header.Get("X-Error-Cause"))
+	}
+
+	var result struct {
+		Clave        string `json:"clave"`
+		IndEstado    string `json:"ind-estado"`
+		RespuestaXML string `json:"respuesta-xml"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("facturacion: respuesta inválida de Hacienda: %w", err)
+	}
+
+	status := &ReceptionStatus{Clave: result.Clave, Estado: strings.ToLower(result.IndEstado)}
+	if result.RespuestaXML != "" {
+		if decoded, err := base64.StdEncoding.DecodeString(result.RespuestaXML); err == nil {
+			status.RespuestaXML = decoded
+		}
+	}
+	return status, nil
+}
+
+func (c *HaciendaClient) do(ctx context.Context, method, endpoint string, body io.Reader) (*http.Response, error) {
+	token, err := c.accessToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("facturacion: error de comunicación con Hacienda: %w", err)
+	}
+	return resp, nil
+}
+
+// accessToken obtiene (o reutiliza) el token OAuth2 del IDP de Hacienda
+func (c *HaciendaClient) accessToken(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token != "" && time.Now().Before(c.tokenExpiry) {
+		return c.token, nil
+	}
+
+	form := url.Values{
+		"grant_type": {"password"},
+		"client_id":  {c.clientID},
+		"username":   {c.username},
+		"password":   {c.password},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("facturacion: error obteniendo token de Hacienda: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("facturacion: credenciales de ATV rechazadas (%d)", resp.StatusCode)
+	}
+
+	var tok struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tok); err != nil {
+		return "", fmt.Errorf("facturacion: token inválido: %w", err)
+	}
+
+	c.token = tok.AccessToken
+	// Renovar 30s antes de que expire
+	c.tokenExpiry = time.Now().Add(time.Duration(tok.ExpiresIn)*time.Second - 30*time.Second)
+	return c.token, nil
+}
+
+// ─── Stub local ──────────────────────────────────────────────────────────────
+
+// StubClient simula la API de recepción en memoria: acepta todo lo que recibe.
+// Se usa en desarrollo (sin credenciales de ATV) y en pruebas.
+type StubClient struct {
+	mu       sync.Mutex
+	received map[string]ReceptionRequest
+
+	// Estado que devuelve Status para claves recibidas (default: aceptado)
+	Estado string
+	// SendErr, si no es nil, se devuelve en Send
+	SendErr error
+}
+
+// NewStubClient creates an in-memory reception client
+func NewStubClient() *StubClient {
+	return &StubClient{
+		received: make(map[string]ReceptionRequest),
+		Estado:   EstadoAceptado,
+	}
+}
+
+func (s *StubClient) Send(ctx context.Context, req ReceptionRequest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.SendErr != nil {
+		return s.SendErr
+	}
+	s.received[req.Clave] = req
+	return nil
+}
+
+func (s *StubClient) Status(ctx context.Context, clave string) (*ReceptionStatus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.received[clave]; !ok {
+		return nil, ErrNotFoundInHacienda
+	}
+	return &ReceptionStatus{Clave: clave, Estado: s.Estado}, nil
+}
+
+// Received returns the document sent for a clave (for tests)
+func (s *StubClient) Received(clave string) (ReceptionRequest, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	req, ok := s.received[clave]
+	return req, ok
+}
@@ -0,0 +1,245 @@
+package facturacion
+
+import (
+	"math"
+	"time"
+)
+
+// Tarifa general de IVA (Ley 9635)
+const (
+	ImpuestoIVA         = "01" // Código de impuesto: IVA
+	TarifaIVACero       = "01" // CodigoTarifaIVA: tarifa 0%
+	TarifaIVAReducida1  = "02" // 1%
+	TarifaIVAReducida2  = "03" // 2%
+	TarifaIVAReducida4  = "04" // 4%
+	TarifaIVATransit8   = "07" // 8% transitorio
+	TarifaIVAGeneral    = "08" // CodigoTarifaIVA: tarifa general 13%
+	PorcentajeIVA13     = 13.0
+	UnidadServicios     = "Sp"   // Servicios profesionales
+	UnidadUnidades      = "Unid" // Mercancía por unidad
+	CondicionContado    = "01"
+	MedioPagoEfectivo   = "01"
+	MedioPagoTransfer   = "04"
+	MedioPagoSINPEMovil = "06"
+)
+
+// Identificacion de emisor o receptor
+type Identificacion struct {
+	Tipo   string // 01 física, 02 jurídica, 03 DIMEX, 04 NITE
+	Numero string
+}
+
+// Ubicacion según la división territorial de Hacienda (códigos numéricos)
+type Ubicacion struct {
+	Provincia  string // 1 dígito
+	Canton     string // 2 dígitos
+	Distrito   string // 2 dígitos
+	OtrasSenas string
+}
+
+// Emisor es FabricaLaser
+type Emisor struct {
+	Nombre            string
+	NombreComercial   string
+	Identificacion    Identificacion
+	CodigoActividad   string
+	Ubicacion         Ubicacion
+	Telefono          string // 8 dígitos, código de país 506 implícito
+	CorreoElectronico string
+}
+
+// Receptor es el cliente. Nil en tiquetes electrónicos.
+type Receptor struct {
+	Nombre            string
+	Identificacion    Identificacion
+	CorreoElectronico string
+}
+
+// Linea es una línea de detalle del comprobante. Montos en la moneda del comprobante, sin IVA.
+type Linea struct {
+	CodigoCABYS    string
+	Cantidad       float64
+	UnidadMedida   string
+	Detalle        string
+	PrecioUnitario float64
+	Descuento      float64 // Monto total de descuento de la línea
+	NaturalezaDesc string
+	EsServicio     bool
+	TarifaIVA      float64 // Porcentaje (13 = 13%)
+	CodigoTarifa   string
+	Exoneracion    *Exoneracion // Solo en facturas con receptor exonerado
+}
+
+// Exoneracion del IVA de una línea (documento de exoneración del cliente)
+type Exoneracion struct {
+	TipoDocumento     string // 01 compras autorizadas, 03 ley especial, 99 otros...
+	NumeroDocumento   string
+	NombreInstitucion string
+	FechaEmision      time.Time
+	Porcentaje        float64 // % del IVA exonerado (100 = total)
+}
+
+// CodigoTarifaIVA returns Hacienda's CodigoTarifaIVA for a rate percentage
+func CodigoTarifaIVA(pct float64) string {
+	switch pct {
+	case 0:
+		return TarifaIVACero
+	case 1:
+		return TarifaIVAReducida1
+	case 2:
+		return TarifaIVAReducida2
+	case 4:
+		return TarifaIVAReducida4
+	case 8:
+		return TarifaIVATransit8
+	default:
+		return TarifaIVAGeneral
+	}
+}
+
+// MontoTotal = cantidad × precio unitario
+func (l Linea) MontoTotal() float64 {
+	return round5(l.Cantidad * l.PrecioUnitario)
+}
+
+// SubTotal = monto total - descuento
+func (l Linea) SubTotal() float64 {
+	return round5(l.MontoTotal() - l.Descuento)
+}
+
+// Impuesto = subtotal × tarifa
+func (l Linea) Impuesto() float64 {
+	return round5(l.SubTotal() * l.TarifaIVA / 100)
+}
+
+// TarifaExonerada = puntos de tarifa exonerados (13% exonerado al 100% → 13)
+func (l Linea) TarifaExonerada() float64 {
+	if l.Exoneracion == nil {
+		return 0
+	}
+	return round5(l.TarifaIVA * math.Min(l.Exoneracion.Porcentaje, 100) / 100)
+}
+
+// MontoExoneracion = subtotal × tarifa exonerada
+func (l Linea) MontoExoneracion() float64 {
+	return round5(l.SubTotal() * l.TarifaExonerada() / 100)
+}
+
+// ImpuestoNeto = impuesto - exoneración
+func (l Linea) ImpuestoNeto() float64 {
+	return round5(l.Impuesto() - l.MontoExoneracion())
+}
+
+// MontoTotalLinea = subtotal + impuesto neto
+func (l Linea) MontoTotalLinea() float64 {
+	return round5(l.SubTotal() + l.ImpuestoNeto())
+}
+
+// Comprobante agrupa todo lo necesario para generar el XML
+type Comprobante struct {
+	DocType           string // models.InvoiceDocFactura o models.InvoiceDocTiquete
+	Clave             string
+	NumeroConsecutivo string
+	FechaEmision      time.Time
+	ProveedorSistemas string
+	Emisor            Emisor
+	Receptor          *Receptor
+	CondicionVenta    string
+	MedioPago         string
+	Moneda            string
+	TipoCambio        float64
+	Lineas            []Linea
+}
+
+// DesgloseImpuesto es el total de impuesto por código de tarifa
+type DesgloseImpuesto struct {
+	Codigo             string
+	CodigoTarifa       string
+	TotalMontoImpuesto float64
+}
+
+// Resumen contiene los totales del comprobante (ResumenFactura)
+type Resumen struct {
+	TotalServGravados       float64
+	TotalServExentos        float64
+	TotalServExonerado      float64
+	TotalMercanciasGravadas float64
+	TotalMercanciasExentas  float64
+	TotalMercExonerada      float64
+	TotalGravado            float64
+	TotalExento             float64
+	TotalExonerado          float64
+	TotalVenta              float64
+	TotalDescuentos         float64
+	TotalVentaNeta          float64
+	Desglose                []DesgloseImpuesto
+	TotalImpuesto           float64
+	TotalComprobante        float64
+}
+
+// Resumen calcula los totales a partir de las líneas.
+// Según la v4.4, los totales gravados/exentos van antes de descuentos. La parte
+// exonerada de una línea gravada se reporta como exonerada en proporción a la
+// tarifa exonerada.
+func (c *Comprobante) Resumen() Resumen {
+	var r Resumen
+	desglose := map[string]float64{}
+	var orden []string
+
+	for _, l := range c.Lineas {
+		monto := l.MontoTotal()
+		gravada := l.TarifaIVA > 0
+		exonerado := 0.0
+		if gravada {
+			exonerado = monto * l.TarifaExonerada() / l.TarifaIVA
+		}
+		switch {
+		case l.EsServicio && gravada:
+			r.TotalServGravados += monto - exonerado
+			r.TotalServExonerado += exonerado
+		case l.EsServicio:
+			r.TotalServExentos += monto
+		case gravada:
+			r.TotalMercanciasGravadas += monto - exonerado
+			r.TotalMercExonerada += exonerado
+		default:
+			r.TotalMercanciasExentas += monto
+		}
+		r.TotalDescuentos += l.Descuento
+		if gravada {
+			if _, ok := desglose[l.CodigoTarifa]; !ok {
+				orden = append(orden, l.CodigoTarifa)
+			}
+			desglose[l.CodigoTarifa] += l.ImpuestoNeto()
+			r.TotalImpuesto += l.ImpuestoNeto()
+		}
+	}
+
+	r.TotalServGravados = round5(r.TotalServGravados)
+	r.TotalServExentos = round5(r.TotalServExentos)
+	r.TotalMercanciasGravadas = round5(r.TotalMercanciasGravadas)
+	r.TotalMercanciasExentas = round5(r.TotalMercanciasExentas)
+	r.TotalServExonerado = round5(r.TotalServExonerado)
+	r.TotalMercExonerada = round5(r.TotalMercExonerada)
+	r.TotalGravado = round5(r.TotalServGravados + r.TotalMercanciasGravadas)
+	r.TotalExento = round5(r.TotalServExentos + r.TotalMercanciasExentas)
+	r.TotalExonerado = round5(r.TotalServExonerado + r.TotalMercExonerada)
+	r.TotalVenta = round5(r.TotalGravado + r.TotalExento + r.TotalExonerado)
+	r.TotalDescuentos = round5(r.TotalDescuentos)
+	r.TotalVentaNeta = round5(r.TotalVenta - r.TotalDescuentos)
+	r.TotalImpuesto = round5(r.TotalImpuesto)
+	r.TotalComprobante = round5(r.TotalVentaNeta + r.TotalImpuesto)
+	for _, codigo := range orden {
+		r.Desglose = append(r.Desglose, DesgloseImpuesto{
+			Codigo:             ImpuestoIVA,
+			CodigoTarifa:       codigo,
+			TotalMontoImpuesto: round5(desglose[codigo]),
+		})
+	}
+	return r
+}
+
+// Hacienda acepta hasta 5 decimales en montos
+func round5(v float64) float64 {
+	return math.Round(v*100000) / 100000
+}
@@ -0,0 +1,219 @@
+package facturacion
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"math/big"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+)
+
+func TestConsecutivoYClave(t *testing.T) {
+	consecutivo := Consecutivo(1, 1, models.InvoiceDocFactura, 42)
+	if consecutivo != "00100001010000000042" {
+		t.Fatalf("unexpected consecutivo: %s", consecutivo)
+	}
+
+	fecha := time.Date(2026, 10, 18, 15, 0, 0, 0, zonaCR)
+	clave, err := Clave(fecha, "1-1234-0567", consecutivo, SituacionNormal, "12345678")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "506" + "181026" + "000112340567" + consecutivo + "1" + "12345678"
+	if clave != want {
+		t.Errorf("clave = %s, want %s", clave, want)
+	}
+	if len(clave) != 50 {
+		t.Errorf("clave must have 50 digits, got %d", len(clave))
+	}
+
+	if _, err := Clave(fecha, "", consecutivo, SituacionNormal, "12345678"); err == nil {
+		t.Error("expected error for empty cedula")
+	}
+}
+
+func TestTipoIdentificacion(t *testing.T) {
+	tests := []struct {
+		cedula, tipo, want string
+		wantErr            bool
+	}{
+		{"112340567", "fisica", TipoIDFisica, false},
+		{"3101123456", "juridica", TipoIDJuridica, false},
+		{"3101123456", "fisica", TipoIDJuridica, false},
+		{"155812345678", "fisica", TipoIDDIMEX, false},
+		{"123", "fisica", "", true},
+	}
+	for _, tt := range tests {
+		got, err := TipoIdentificacion(tt.cedula, tt.tipo)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("TipoIdentificacion(%q, %q) = %q, %v", tt.cedula, tt.tipo, got, err)
+		}
+	}
+}
+
+func TestResumen(t *testing.T) {
+	c := &Comprobante{Lineas: []Linea{
+		{Cantidad: 10, PrecioUnitario: 1000, Descuento: 1000, EsServicio: true, TarifaIVA: PorcentajeIVA13, CodigoTarifa: TarifaIVAGeneral},
+		{Cantidad: 2, PrecioUnitario: 500, TarifaIVA: PorcentajeIVA13, CodigoTarifa: TarifaIVAGeneral},
+	}}
+	r := c.Resumen()
+
+	if r.TotalServGravados != 10000 || r.TotalMercanciasGravadas != 1000 {
+		t.Errorf("gravados: serv=%v merc=%v", r.TotalServGravados, r.TotalMercanciasGravadas)
+	}
+	if r.TotalVenta != 11000 || r.TotalDescuentos != 1000 || r.TotalVentaNeta != 10000 {
+		t.Errorf("venta=%v desc=%v neta=%v", r.TotalVenta, r.TotalDescuentos, r.TotalVentaNeta)
+	}
+	if r.TotalImpuesto != 1300 || r.TotalComprobante != 11300 {
+		t.Errorf("impuesto=%v total=%v", r.TotalImpuesto, r.TotalComprobante)
+	}
+	if len(r.Desglose) != 1 || r.Desglose[0].TotalMontoImpuesto != 1300 {
+		t.Errorf("desglose: %+v", r.Desglose)
+	}
+}
+
+func TestResumenExonerado(t *testing.T) {
+	c := &Comprobante{Lineas: []Linea{{
+		Cantidad: 1, PrecioUnitario: 10000, EsServicio: true, TarifaIVA: 13, CodigoTarifa: CodigoTarifaIVA(13),
+		Exoneracion: &Exoneracion{TipoDocumento: "03", NumeroDocumento: "AL-1", Porcentaje: 50},
+	}}}
+	l := c.Lineas[0]
+	if l.TarifaExonerada() != 6.5 || l.MontoExoneracion() != 650 || l.ImpuestoNeto() != 650 {
+		t.Errorf("linea: tarifa=%v monto=%v neto=%v", l.TarifaExonerada(), l.MontoExoneracion(), l.ImpuestoNeto())
+	}
+
+	r := c.Resumen()
+	if r.TotalServGravados != 5000 || r.TotalServExonerado != 5000 || r.TotalVenta != 10000 {
+		t.Errorf("gravado=%v exonerado=%v venta=%v", r.TotalServGravados, r.TotalServExonerado, r.TotalVenta)
+	}
+	if r.TotalImpuesto != 650 || r.TotalComprobante != 10650 {
+		t.Errorf("impuesto=%v total=%v", r.TotalImpuesto, r.TotalComprobante)
+	}
+}
+
+func TestSignComprobante(t *testing.T) {
+	signer := testSigner(t)
+	c := &Comprobante{
+		Clave:             strings.Repeat("1", 50),
+		NumeroConsecutivo: Consecutivo(1, 1, models.InvoiceDocFactura, 1),
+		FechaEmision:      time.Date(2026, 10, 18, 9, 30, 0, 0, zonaCR),
+		ProveedorSistemas: "112340567",
+		Emisor: Emisor{
+			Nombre:            "FabricaLaser & Co",
+			Identificacion:    Identificacion{Tipo: TipoIDFisica, Numero: "112340567"},
+			CodigoActividad:   "222100",
+			Ubicacion:         Ubicacion{Provincia: "1", Canton: "01", Distrito: "01", OtrasSenas: "Frente al parque"},
+			CorreoElectronico: "facturas@fabricalaser.com",
+		},
+		Receptor: &Receptor{
+			Nombre:         "Cliente \"Prueba\"",
+			Identificacion: Identificacion{Tipo: TipoIDJuridica, Numero: "3101123456"},
+		},
+		CondicionVenta: CondicionContado,
+		MedioPago:      MedioPagoTransfer,
+		Moneda:         models.CurrencyCRC,
+		TipoCambio:     1,
+		Lineas: []Linea{{
+			CodigoCABYS: "1234567890123", Cantidad: 2, UnidadMedida: UnidadServicios, Detalle: "Grabado <láser>",
+			PrecioUnitario: 5000, EsServicio: true, TarifaIVA: PorcentajeIVA13, CodigoTarifa: TarifaIVAGeneral,
+		}},
+	}
+
+	signed, err := SignComprobante(c, signer)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	doc := string(signed)
+
+	for _, want := range []string{
+		`<FacturaElectronica xmlns="` + NamespaceFactura + `">`,
+		"<Detalle>Grabado &lt;láser&gt;</Detalle>",
+		"<TotalComprobante>11300.00000</TotalComprobante>",
+		`<ds:Signature xmlns:ds="` + nsDS + `"`,
+		"<xades:SignaturePolicyIdentifier>",
+	} {
+		if !strings.Contains(doc, want) {
+			t.Errorf("signed XML missing %q", want)
+		}
+	}
+
+	// Digest del documento: quitar la firma (enveloped) y comparar
+	body := strings.TrimPrefix(doc, `<?xml version="1.0" encoding="UTF-8"?>`+"\n")
+	sigStart := strings.Index(body, "<ds:Signature ")
+	sigEnd := strings.Index(body, "</ds:Signature>") + len("</ds:Signature>")
+	unsigned := body[:sigStart] + body[sigEnd:]
+	digests := regexp.MustCompile(`<ds:Reference [^>]*URI=""[^>]*>.*?<ds:DigestValue>([^<]+)</ds:DigestValue>`).FindStringSubmatch(body)
+	if digests == nil {
+		t.Fatal("document reference not found")
+	}
+	sum := sha256.Sum256([]byte(unsigned))
+	if digests[1] != base64.StdEncoding.EncodeToString(sum[:]) {
+		t.Error("document digest does not match enveloped content")
+	}
+
+	// SignatureValue sobre SignedInfo canonicalizado con los namespaces heredados
+	siStart := strings.Index(body, "<ds:SignedInfo>")
+	siEnd := strings.Index(body, "</ds:SignedInfo>") + len("</ds:SignedInfo>")
+	signedInfo := strings.Replace(body[siStart:siEnd], "<ds:SignedInfo>",
+		`<ds:SignedInfo xmlns="`+NamespaceFactura+`" xmlns:ds="`+nsDS+`">`, 1)
+	sigValue := regexp.MustCompile(`<ds:SignatureValue [^>]*>([^<]+)</ds:SignatureValue>`).FindStringSubmatch(body)
+	raw, err := base64.StdEncoding.DecodeString(sigValue[1])
+	if err != nil {
+		t.Fatalf("invalid signature encoding: %v", err)
+	}
+	hashed := sha256.Sum256([]byte(signedInfo))
+	if err := rsa.VerifyPKCS1v15(&signer.key.PublicKey, crypto.SHA256, hashed[:], raw); err != nil {
+		t.Errorf("signature does not verify: %v", err)
+	}
+}
+
+func TestStubClient(t *testing.T) {
+	stub := NewStubClient()
+	if _, err := stub.Status(context.Background(), "x"); err != ErrNotFoundInHacienda {
+		t.Errorf("expected ErrNotFoundInHacienda, got %v", err)
+	}
+	if err := stub.Send(context.Background(), ReceptionRequest{Clave: "x"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	status, err := stub.Status(context.Background(), "x")
+	if err != nil || status.Estado != EstadoAceptado {
+		t.Errorf("unexpected status: %+v, %v", status, err)
+	}
+}
+
+func testSigner(t *testing.T) *Signer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "FABRICALASER PRUEBA"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := NewSigner(key, cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
@@ -0,0 +1,424 @@
+package facturacion
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/config"
+	"github.com/alonsoalpizar/fabricalaser/internal/database"
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+	"github.com/alonsoalpizar/fabricalaser/internal/repository"
+	"github.com/alonsoalpizar/fabricalaser/internal/services/pricing"
+)
+
+var (
+	ErrNotConfigured     = errors.New("facturación electrónica no configurada (llave criptográfica o datos del emisor)")
+	ErrQuoteNotConverted = errors.New("solo se pueden facturar cotizaciones convertidas")
+	ErrAlreadyInvoiced   = errors.New("la cotización ya tiene una factura vigente")
+	ErrMissingCabys      = errors.New("falta el código CABYS (material o cabys_servicio_default / cabys_producto_default)")
+	ErrInvoiceFinal      = errors.New("Hacienda ya resolvió esta factura")
+)
+
+// Service emite comprobantes electrónicos a partir de cotizaciones convertidas
+type Service struct {
+	emisor            Emisor
+	proveedorSistemas string
+	sucursal          int
+	terminal          int
+	signer            *Signer
+	client            ReceptionClient
+	invoiceRepo       *repository.InvoiceRepository
+	quoteRepo         *repository.QuoteRepository
+	configLoader      *pricing.ConfigLoader
+	now               func() time.Time
+}
+
+// NewService creates a service with explicit emisor, signer and reception client
+func NewService(emisor Emisor, proveedorSistemas string, sucursal, terminal int, signer *Signer, client ReceptionClient) *Service {
+	if proveedorSistemas == "" {
+		proveedorSistemas = emisor.Identificacion.Numero
+	}
+	return &Service{
+		emisor:            emisor,
+		proveedorSistemas: proveedorSistemas,
+		sucursal:          sucursal,
+		terminal:          terminal,
+		signer:            signer,
+		client:            client,
+		invoiceRepo:       repository.NewInvoiceRepository(),
+		quoteRepo:         repository.NewQuoteRepository(),
+		configLoader:      pricing.NewConfigLoader(database.Get()),
+		now:               time.Now,
+	}
+}
+
+// NewServiceFromConfig builds the service from FABRICALASER_FE_* variables.
+// Sin credenciales de ATV usa el StubClient, salvo en producción.
+func NewServiceFromConfig() (*Service, error) {
+	cfg := config.Get()
+	if cfg.FEP12Path == "" || cfg.FEEmisorCedula == "" || cfg.FEEmisorNombre == "" {
+		return nil, ErrNotConfigured
+	}
+
+	signer, err := LoadSignerP12(cfg.FEP12Path, cfg.FEP12Pin)
+	if err != nil {
+		return nil, err
+	}
+
+	var client ReceptionClient
+	if cfg.FEATVUser != "" && cfg.FEATVPassword != "" {
+		client, err = NewHaciendaClient(cfg.FEAmbiente, cfg.FEATVUser, cfg.FEATVPassword)
+		if err != nil {
+			return nil, err
+		}
+	} else if cfg.IsProduction() {
+		return nil, ErrNotConfigured
+	} else {
+		slog.Warn("facturacion: sin credenciales de ATV, usando stub local de recepción")
+		client = NewStubClient()
+	}
+
+	emisor := Emisor{
+		Nombre:          cfg.FEEmisorNombre,
+		NombreComercial: cfg.FEEmisorNombreComer,
+		Identificacion: Identificacion{
+			Tipo:   cfg.FEEmisorTipoID,
+			Numero: onlyDigits(cfg.FEEmisorCedula),
+		},
+		CodigoActividad: cfg.FEEmisorActividad,
+		Ubicacion: Ubicacion{
+			Provincia:  cfg.FEEmisorProvincia,
+			Canton:     cfg.FEEmisorCanton,
+			Distrito:   cfg.FEEmisorDistrito,
+			OtrasSenas: cfg.FEEmisorOtrasSenas,
+		},
+		Telefono:          onlyDigits(cfg.FEEmisorTelefono),
+		CorreoElectronico: cfg.FEEmisorCorreo,
+	}
+	return NewService(emisor, onlyDigits(cfg.FEProveedorSistemas), cfg.FESucursal, cfg.FETerminal, signer, client), nil
+}
+
+// IssueForQuote genera, firma, guarda y envía el comprobante de una cotización convertida.
+// Si el envío falla, la factura queda guardada con estado error para reintentar con Send.
+func (s *Service) IssueForQuote(ctx context.Context, quoteID uint) (*models.Invoice, error) {
+	quote, err := s.quoteRepo.FindByIDWithRelations(quoteID)
+	if err != nil {
+		return nil, err
+	}
+	if quote.Status != models.QuoteStatusConverted {
+		return nil, ErrQuoteNotConverted
+	}
+	pricingCfg, err := s.configLoader.Load()
+	if err != nil {
+		return nil, fmt.Errorf("facturacion: error cargando configuración: %w", err)
+	}
+
+	receptor := receptorFromUser(quote.User)
+	docType := models.InvoiceDocTiquete
+	if receptor != nil {
+		docType = models.InvoiceDocFactura
+	}
+
+	fecha := s.now()
+	invoice, err := s.invoiceRepo.CreateNumbered(quote.ID, s.sucursal, s.terminal, docType, func(numero int64) (*models.Invoice, error) {
+		consecutivo := Consecutivo(s.sucursal, s.terminal, docType, numero)
+		codigo, err := NewCodigoSeguridad()
+		if err != nil {
+			return nil, err
+		}
+		clave, err := Clave(fecha, s.emisor.Identificacion.Numero, consecutivo, SituacionNormal, codigo)
+		if err != nil {
+			return nil, err
+		}
+
+		comprobante, err := s.buildComprobante(quote, pricingCfg, receptor)
+		if err != nil {
+			return nil, err
+		}
+		comprobante.DocType = docType
+		comprobante.Clave = clave
+		comprobante.NumeroConsecutivo = consecutivo
+		comprobante.FechaEmision = fecha
+
+		signed, err := SignComprobante(comprobante, s.signer)
+		if err != nil {
+			return nil, err
+		}
+
+		resumen := comprobante.Resumen()
+		return &models.Invoice{
+			QuoteID:          quote.ID,
+			UserID:           quote.UserID,
+			DocType:          docType,
+			Clave:            clave,
+			Consecutivo:      consecutivo,
+			IssuedAt:         fecha,
+			Currency:         comprobante.Moneda,
+			ExchangeRate:     comprobante.TipoCambio,
+			TotalVenta:       resumen.TotalVenta,
+			TotalImpuesto:    resumen.TotalImpuesto,
+			TotalComprobante: resumen.TotalComprobante,
+			Status:           models.InvoiceStatusSigned,
+			XMLSigned:        string(signed),
+		}, nil
+	})
+	if errors.Is(err, repository.ErrInvoiceExists) {
+		return nil, ErrAlreadyInvoiced
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Info("facturacion: comprobante emitido",
+		"quote_id", quote.ID,
+		"clave", invoice.Clave,
+		"doc_type", docType,
+		"total", invoice.TotalComprobante,
+	)
+
+	if err := s.Send(ctx, invoice); err != nil {
+		slog.Error("facturacion: error enviando a Hacienda", "clave", invoice.Clave, "error", err)
+	}
+	return invoice, nil
+}
+
+// Send envía (o reenvía) una factura firmada a Hacienda y actualiza su estado
+func (s *Service) Send(ctx context.Context, invoice *models.Invoice) error {
+	if invoice.IsFinal() {
+		return ErrInvoiceFinal
+	}
+
+	emisor := s.emisor.Identificacion
+	req := ReceptionRequest{
+		Clave:          invoice.Clave,
+		Fecha:          invoice.IssuedAt,
+		Emisor:         emisor,
+		ComprobanteXML: []byte(invoice.XMLSigned),
+	}
+	if invoice.DocType == models.InvoiceDocFactura && invoice.User == nil {
+		if quote, err := s.quoteRepo.FindByIDWithRelations(invoice.QuoteID); err == nil {
+			invoice.User = quote.User
+		}
+	}
+	if r := receptorFromUser(invoice.User); r != nil && invoice.DocType == models.InvoiceDocFactura {
+		req.Receptor = &r.Identificacion
+	}
+
+	sendErr := s.client.Send(ctx, req)
+	now := s.now()
+	if sendErr != nil {
+		msg := sendErr.Error()
+		invoice.Status = models.InvoiceStatusError
+		invoice.LastError = &msg
+	} else {
+		invoice.Status = models.InvoiceStatusSent
+		invoice.LastError = nil
+		invoice.SentAt = &now
+		estado := EstadoRecibido
+		invoice.HaciendaStatus = &estado
+	}
+
+	if err := s.invoiceRepo.Update(invoice); err != nil {
+		return err
+	}
+	return sendErr
+}
+
+// RefreshStatus consulta a Hacienda el estado de la factura y lo guarda
+func (s *Service) RefreshStatus(ctx context.Context, invoiceID uint) (*models.Invoice, error) {
+	invoice, err := s.invoiceRepo.FindByID(invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	if invoice.Status == models.InvoiceStatusSigned || invoice.Status == models.InvoiceStatusError {
+		// Nunca llegó a Hacienda: reintentar el envío
+		if err := s.Send(ctx, invoice); err != nil {
+			return invoice, err
+		}
+		return invoice, nil
+	}
+
+	status, err := s.client.Status(ctx, invoice.Clave)
+	if err != nil {
+		return invoice, err
+	}
+
+	invoice.HaciendaStatus = &status.Estado
+	if len(status.RespuestaXML) > 0 {
+		resp := string(status.RespuestaXML)
+		invoice.HaciendaResponse = &resp
+	}
+	switch status.Estado {
+	case EstadoAceptado:
+		invoice.Status = models.InvoiceStatusAccepted
+	case EstadoRechazado:
+		invoice.Status = models.InvoiceStatusRejected
+	}
+
+	if err := s.invoiceRepo.Update(invoice); err != nil {
+		return nil, err
+	}
+	return invoice, nil
+}
+
+// buildComprobante arma emisor, receptor y líneas a partir de la cotización.
+// Los montos de la cotización están en BaseCurrency (CRC) sin IVA; si el cliente
+// cotizó en USD se factura en USD con el tipo de cambio congelado en la cotización.
+func (s *Service) buildComprobante(quote *models.Quote, cfg *pricing.PricingConfig, receptor *Receptor) (*Comprobante, error) {
+	linea, err := lineaFromQuote(quote, cfg)
+	if err != nil {
+		return nil, err
+	}
+	// La exoneración requiere receptor identificado (solo factura, nunca tiquete)
+	if receptor != nil {
+		linea.Exoneracion = exoneracionFromQuote(quote)
+	}
+
+	moneda := models.CurrencyCRC
+	tipoCambio := 1.0
+	if models.NormalizeCurrency(quote.Currency) == models.CurrencyUSD && quote.ExchangeRate > 0 {
+		moneda = models.CurrencyUSD
+		tipoCambio = quote.ExchangeRate
+		linea.PrecioUnitario = round5(linea.PrecioUnitario / tipoCambio)
+		linea.Descuento = round5(linea.Descuento / tipoCambio)
+	}
+
+	condicion := cfg.GetSystemConfigString("fe_condicion_venta")
+	if condicion == "" {
+		condicion = CondicionContado
+	}
+	medioPago := cfg.GetSystemConfigString("fe_medio_pago")
+	if medioPago == "" {
+		medioPago = MedioPagoTransfer
+	}
+
+	return &Comprobante{
+		ProveedorSistemas: s.proveedorSistemas,
+		Emisor:            s.emisor,
+		Receptor:          receptor,
+		CondicionVenta:    condicion,
+		MedioPago:         medioPago,
+		Moneda:            moneda,
+		TipoCambio:        tipoCambio,
+		Lineas:            []Linea{linea},
+	}, nil
+}
+
+// lineaFromQuote convierte la cotización en una línea de detalle.
+// Material provisto por FabricaLaser → mercancía con el CABYS del material;
+// material del cliente → servicio con cabys_servicio_default.
+func lineaFromQuote(quote *models.Quote, cfg *pricing.PricingConfig) (Linea, error) {
+	materialIncluded := quote.MaterialIncluded == nil || *quote.MaterialIncluded
+
+	var cabys string
+	if materialIncluded {
+		if quote.Material != nil && quote.Material.CabysCode != nil {
+			cabys = *quote.Material.CabysCode
+		}
+		if cabys == "" {
+			cabys = cfg.GetSystemConfigString("cabys_producto_default")
+		}
+	} else {
+		cabys = cfg.GetSystemConfigString("cabys_servicio_default")
+	}
+	if !IsValidCabys(cabys) {
+		return Linea{}, ErrMissingCabys
+	}
+
+	qty := max(quote.Quantity, 1)
+	neto := quote.PriceFinal
+
+	// Reconstruir el bruto para mostrar el descuento por volumen en la línea
+	bruto := neto
+	if quote.DiscountVolumePct > 0 && quote.DiscountVolumePct < 1 {
+		bruto = neto / (1 - quote.DiscountVolumePct)
+	}
+
+	unidad := UnidadUnidades
+	if !materialIncluded {
+		unidad = UnidadServicios
+	}
+
+	return Linea{
+		CodigoCABYS:    cabys,
+		Cantidad:       float64(qty),
+		UnidadMedida:   unidad,
+		Detalle:        detalleFromQuote(quote, materialIncluded),
+		PrecioUnitario: round5(bruto / float64(qty)),
+		Descuento:      round5(bruto - neto),
+		NaturalezaDesc: fmt.Sprintf("Descuento por volumen %.0f%%", quote.DiscountVolumePct*100),
+		EsServicio:     !materialIncluded,
+		TarifaIVA:      quote.TaxRate,
+		CodigoTarifa:   CodigoTarifaIVA(quote.TaxRate),
+	}, nil
+}
+
+// exoneracionFromQuote returns the exemption frozen on the quote, with the
+// document data from the customer. Nil if the quote has no exemption.
+func exoneracionFromQuote(quote *models.Quote) *Exoneracion {
+	if quote.TaxExemptPct <= 0 || quote.TaxExoneracion == nil || quote.User == nil {
+		return nil
+	}
+	user := quote.User
+	ex := &Exoneracion{
+		TipoDocumento:   "99",
+		NumeroDocumento: *quote.TaxExoneracion,
+		FechaEmision:    quote.CreatedAt,
+		Porcentaje:      quote.TaxExemptPct,
+	}
+	if user.ExoneracionTipo != nil && *user.ExoneracionTipo != "" {
+		ex.TipoDocumento = *user.ExoneracionTipo
+	}
+	if user.ExoneracionInstitucion != nil {
+		ex.NombreInstitucion = *user.ExoneracionInstitucion
+	}
+	if user.ExoneracionFecha != nil {
+		ex.FechaEmision = *user.ExoneracionFecha
+	}
+	return ex
+}
+
+func detalleFromQuote(quote *models.Quote, materialIncluded bool) string {
+	parts := []string{"Grabado/corte láser"}
+	if quote.Technology != nil {
+		parts = append(parts, quote.Technology.Name)
+	}
+	if quote.Material != nil {
+		if materialIncluded {
+			parts = append(parts, "en "+quote.Material.Name)
+		} else {
+			parts = append(parts, "sobre "+quote.Material.Name+" del cliente")
+		}
+	}
+	return fmt.Sprintf("%s (cotización #%d)", strings.Join(parts, " "), quote.ID)
+}
+
+// receptorFromUser returns nil when the user cannot receive a factura
+// (sin cédula válida → se emite tiquete electrónico)
+func receptorFromUser(user *models.User) *Receptor {
+	if user == nil || user.Cedula == "" {
+		return nil
+	}
+	tipo, err := TipoIdentificacion(user.Cedula, user.CedulaType)
+	if err != nil {
+		return nil
+	}
+	return &Receptor{
+		Nombre:            truncate(user.NombreCompleto(), 100),
+		Identificacion:    Identificacion{Tipo: tipo, Numero: onlyDigits(user.Cedula)},
+		CorreoElectronico: user.Email,
+	}
+}
+
+// SignComprobante genera el XML v4.4 del comprobante y lo firma con XAdES-EPES
+func SignComprobante(c *Comprobante, signer *Signer) ([]byte, error) {
+	root, err := buildDocument(c)
+	if err != nil {
+		return nil, err
+	}
+	return signer.sign(root)
+}
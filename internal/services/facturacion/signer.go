@@ -0,0 +1,186 @@
+package facturacion
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/pkcs12"
+)
+
+// Algoritmos y namespaces de XML-DSig / XAdES
+const (
+	nsDS    = "http://www.w3.org/2000/09/xmldsig#"
+	nsXAdES = "http://uri.etsi.org/01903/v1.3.2#"
+
+	algC14N         = "http://www.w3.org/TR/2001/REC-xml-c14n-20010315"
+	algRSASHA256    = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"
+	algSHA256       = "http://www.w3.org/2001/04/xmlenc#sha256"
+	algEnveloped    = "http://www.w3.org/2000/09/xmldsig#enveloped-signature"
+	typeSignedProps = "http://uri.etsi.org/01903#SignedProperties"
+)
+
+// Política de firma publicada por Hacienda (XAdES-EPES). El hash es SHA-256 del PDF
+// de la resolución; se puede sobreescribir en Signer si Hacienda publica otra versión.
+const (
+	DefaultPolicyIdentifier = "https://www.hacienda.go.cr/ATV/ComprobanteElectronico/docs/esquemas/2016/v4.3/Resoluci%C3%B3n_General_sobre_disposiciones_t%C3%A9cnicas_comprobantes_electr%C3%B3nicos_para_efectos_tributarios.pdf"
+	DefaultPolicyHash       = "0h7Q3dFHhu0bHbcZEgVc07cEcDlquUeG08HG6Iototo="
+)
+
+var ErrNoRSAKey = errors.New("facturacion: la llave criptográfica no es RSA")
+
+// Signer firma comprobantes con XAdES-EPES usando la llave criptográfica de ATV
+type Signer struct {
+	key              *rsa.PrivateKey
+	cert             *x509.Certificate
+	PolicyIdentifier string
+	PolicyHash       string
+	now              func() time.Time
+}
+
+// NewSigner creates a signer from an already decoded key and certificate
+func NewSigner(key crypto.PrivateKey, cert *x509.Certificate) (*Signer, error) {
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, ErrNoRSAKey
+	}
+	return &Signer{
+		key:              rsaKey,
+		cert:             cert,
+		PolicyIdentifier: DefaultPolicyIdentifier,
+		PolicyHash:       DefaultPolicyHash,
+		now:              time.Now,
+	}, nil
+}
+
+// LoadSignerP12 reads the .p12 file downloaded from ATV
+func LoadSignerP12(path, pin string) (*Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("facturacion: error leyendo llave criptográfica: %w", err)
+	}
+	key, cert, err := pkcs12.Decode(data, pin)
+	if err != nil {
+		return nil, fmt.Errorf("facturacion: error abriendo llave criptográfica (¿PIN incorrecto?): %w", err)
+	}
+	return NewSigner(key, cert)
+}
+
+// Certificate returns the signing certificate
+func (s *Signer) Certificate() *x509.Certificate {
+	return s.cert
+}
+
+// sign agrega una firma XAdES-EPES envuelta (enveloped) como último hijo de root
+// y devuelve el documento completo listo para enviar.
+func (s *Signer) sign(root *node) ([]byte, error) {
+	id := uuid.NewString()
+	signatureID := "Signature-" + id
+	signedPropsID := "SignedProperties-" + id
+	docRefID := "Reference-" + id
+
+	// Namespaces en alcance: el default del comprobante viene de la raíz
+	rootNS := append([]attr(nil), root.nsDecls...)
+	dsNS := attr{name: "xmlns:ds", value: nsDS}
+	xadesNS := attr{name: "xmlns:xades", value: nsXAdES}
+
+	// 1. Digest del documento sin la firma (transformación enveloped)
+	docDigest := digest(canonical(root))
+
+	// 2. SignedProperties (XAdES-EPES)
+	certDigest := sha256.Sum256(s.cert.Raw)
+	signedProps := el("xades:SignedProperties",
+		el("xades:SignedSignatureProperties",
+			textEl("xades:SigningTime", s.now().In(zonaCR).Format("2006-01-02T15:04:05-07:00")),
+			el("xades:SigningCertificate",
+				el("xades:Cert",
+					el("xades:CertDigest",
+						el("ds:DigestMethod").attr("Algorithm", algSHA256),
+						textEl("ds:DigestValue", base64.StdEncoding.EncodeToString(certDigest[:])),
+					),
+					el("xades:IssuerSerial",
+						textEl("ds:X509IssuerName", s.cert.Issuer.String()),
+						textEl("ds:X509SerialNumber", s.cert.SerialNumber.String()),
+					),
+				),
+			),
+			el("xades:SignaturePolicyIdentifier",
+				el("xades:SignaturePolicyId",
+					el("xades:SigPolicyId",
+						textEl("xades:Identifier", s.PolicyIdentifier),
+						textEl("xades:Description", ""),
+					),
+					el("xades:SigPolicyHash",
+						el("ds:DigestMethod").attr("Algorithm", algSHA256),
+						textEl("ds:DigestValue", s.PolicyHash),
+					),
+				),
+			),
+		),
+		el("xades:SignedDataObjectProperties",
+			el("xades:DataObjectFormat",
+				textEl("xades:MimeType", "text/xml"),
+				textEl("xades:Encoding", "UTF-8"),
+			).attr("ObjectReference", "#"+docRefID),
+		),
+	).attr("Id", signedPropsID)
+	propsDigest := digest(canonical(signedProps, append(rootNS, dsNS, xadesNS)...))
+
+	// 3. SignedInfo con ambas referencias
+	signedInfo := el("ds:SignedInfo",
+		el("ds:CanonicalizationMethod").attr("Algorithm", algC14N),
+		el("ds:SignatureMethod").attr("Algorithm", algRSASHA256),
+		el("ds:Reference",
+			el("ds:Transforms",
+				el("ds:Transform").attr("Algorithm", algEnveloped),
+			),
+			el("ds:DigestMethod").attr("Algorithm", algSHA256),
+			textEl("ds:DigestValue", docDigest),
+		).attr("Id", docRefID).attr("URI", ""),
+		el("ds:Reference",
+			el("ds:DigestMethod").attr("Algorithm", algSHA256),
+			textEl("ds:DigestValue", propsDigest),
+		).attr("Type", typeSignedProps).attr("URI", "#"+signedPropsID),
+	)
+
+	// 4. Firma RSA-SHA256 del SignedInfo canonicalizado
+	hashed := sha256.Sum256(canonical(signedInfo, append(rootNS, dsNS)...))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, hashed[:])
+	if err != nil {
+		return nil, fmt.Errorf("facturacion: error firmando: %w", err)
+	}
+
+	signature := el("ds:Signature",
+		signedInfo,
+		textEl("ds:SignatureValue", base64.StdEncoding.EncodeToString(sig)).attr("Id", "SignatureValue-"+id),
+		el("ds:KeyInfo",
+			el("ds:X509Data",
+				textEl("ds:X509Certificate", base64.StdEncoding.EncodeToString(s.cert.Raw)),
+			),
+		).attr("Id", "KeyInfo-"+id),
+		el("ds:Object",
+			el("xades:QualifyingProperties", signedProps).
+				xmlns("xades", nsXAdES).
+				attr("Target", "#"+signatureID),
+		),
+	).xmlns("ds", nsDS).attr("Id", signatureID)
+
+	signed := *root
+	signed.children = append(append([]*node(nil), root.children...), signature)
+
+	out := []byte(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	return append(out, canonical(&signed)...), nil
+}
+
+func digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,314 @@
+package facturacion
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Namespaces de los esquemas v4.4
+const (
+	NamespaceFactura = "https://cdn.comprobanteselectronicos.go.cr/xml-schemas/v4.4/facturaElectronica"
+	NamespaceTiquete = "https://cdn.comprobanteselectronicos.go.cr/xml-schemas/v4.4/tiqueteElectronico"
+)
+
+// node es un elemento XML mínimo. El documento se arma como árbol y se serializa
+// directamente en forma canónica (C14N 1.0 inclusivo), así lo que se digiere al
+// firmar es byte a byte lo mismo que se envía.
+type node struct {
+	name     string
+	nsDecls  []attr // xmlns / xmlns:prefix declarados en este elemento
+	attrs    []attr
+	text     string
+	children []*node
+}
+
+type attr struct {
+	name  string
+	value string
+}
+
+func el(name string, children ...*node) *node {
+	return &node{name: name, children: children}
+}
+
+func textEl(name, text string) *node {
+	return &node{name: name, text: text}
+}
+
+func (n *node) add(children ...*node) *node {
+	for _, c := range children {
+		if c != nil {
+			n.children = append(n.children, c)
+		}
+	}
+	return n
+}
+
+func (n *node) attr(name, value string) *node {
+	n.attrs = append(n.attrs, attr{name: name, value: value})
+	return n
+}
+
+func (n *node) xmlns(prefix, uri string) *node {
+	name := "xmlns"
+	if prefix != "" {
+		name = "xmlns:" + prefix
+	}
+	n.nsDecls = append(n.nsDecls, attr{name: name, value: uri})
+	return n
+}
+
+// optText returns nil for empty values so optional elements are omitted
+func optText(name, text string) *node {
+	if text == "" {
+		return nil
+	}
+	return textEl(name, text)
+}
+
+// canonical serializes n in C14N form. inherited are the namespace declarations
+// in scope from ancestors that are not part of the serialized subset; inclusive
+// C14N renders them on the apex element.
+func canonical(n *node, inherited ...attr) []byte {
+	var buf bytes.Buffer
+	writeCanonical(&buf, n, inherited)
+	return buf.Bytes()
+}
+
+func writeCanonical(buf *bytes.Buffer, n *node, inherited []attr) {
+	decls := mergeNamespaces(inherited, n.nsDecls)
+	sort.SliceStable(decls, func(i, j int) bool {
+		// xmlns (default) primero, luego por prefijo
+		return nsPrefix(decls[i].name) < nsPrefix(decls[j].name)
+	})
+	attrs := append([]attr(nil), n.attrs...)
+	sort.SliceStable(attrs, func(i, j int) bool { return attrs[i].name < attrs[j].name })
+
+	buf.WriteByte('<')
+	buf.WriteString(n.name)
+	for _, a := range decls {
+		fmt.Fprintf(buf, ` %s="%s"`, a.name, escapeAttr(a.value))
+	}
+	for _, a := range attrs {
+		fmt.Fprintf(buf, ` %s="%s"`, a.name, escapeAttr(a.value))
+	}
+	buf.WriteByte('>')
+	buf.WriteString(escapeText(n.text))
+	for _, c := range n.children {
+		writeCanonical(buf, c, nil)
+	}
+	buf.WriteString("</")
+	buf.WriteString(n.name)
+	buf.WriteByte('>')
+}
+
+func mergeNamespaces(inherited, own []attr) []attr {
+	out := make([]attr, 0, len(inherited)+len(own))
+	for _, a := range inherited {
+		overridden := false
+		for _, o := range own {
+			if o.name == a.name {
+				overridden = true
+				break
+			}
+		}
+		if !overridden {
+			out = append(out, a)
+		}
+	}
+	return append(out, own...)
+}
+
+func nsPrefix(declName string) string {
+	return strings.TrimPrefix(strings.TrimPrefix(declName, "xmlns"), ":")
+}
+
+var (
+	textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\r", "&#xD;")
+	attrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", `"`, "&quot;", "\t", "&#x9;", "\n", "&#xA;", "\r", "&#xD;")
+)
+
+func escapeText(s string) string { return textEscaper.Replace(s) }
+func escapeAttr(s string) string { return attrEscaper.Replace(s) }
+
+// ─── Comprobante → XML ───────────────────────────────────────────────────────
+
+// buildDocument arma el árbol del comprobante (sin firma)
+func buildDocument(c *Comprobante) (*node, error) {
+	rootName, ns := "FacturaElectronica", NamespaceFactura
+	if c.Receptor == nil {
+		rootName, ns = "TiqueteElectronico", NamespaceTiquete
+	}
+	if len(c.Lineas) == 0 {
+		return nil, fmt.Errorf("facturacion: el comprobante no tiene líneas")
+	}
+
+	root := el(rootName).xmlns("", ns)
+	root.add(
+		textEl("Clave", c.Clave),
+		textEl("ProveedorSistemas", c.ProveedorSistemas),
+		textEl("CodigoActividadEmisor", c.Emisor.CodigoActividad),
+		textEl("NumeroConsecutivo", c.NumeroConsecutivo),
+		textEl("FechaEmision", c.FechaEmision.In(zonaCR).Format("2006-01-02T15:04:05-07:00")),
+		buildEmisor(&c.Emisor),
+		buildReceptor(c.Receptor),
+		textEl("CondicionVenta", c.CondicionVenta),
+	)
+
+	detalle := el("DetalleServicio")
+	for i, l := range c.Lineas {
+		detalle.add(buildLinea(i+1, l))
+	}
+	root.add(detalle)
+	root.add(buildResumen(c))
+	return root, nil
+}
+
+func buildEmisor(e *Emisor) *node {
+	n := el("Emisor",
+		textEl("Nombre", e.Nombre),
+		el("Identificacion",
+			textEl("Tipo", e.Identificacion.Tipo),
+			textEl("Numero", e.Identificacion.Numero),
+		),
+	)
+	n.add(optText("NombreComercial", e.NombreComercial))
+	n.add(el("Ubicacion",
+		textEl("Provincia", e.Ubicacion.Provincia),
+		textEl("Canton", e.Ubicacion.Canton),
+		textEl("Distrito", e.Ubicacion.Distrito),
+		textEl("OtrasSenas", e.Ubicacion.OtrasSenas),
+	))
+	if e.Telefono != "" {
+		n.add(el("Telefono",
+			textEl("CodigoPais", codigoPais),
+			textEl("NumTelefono", e.Telefono),
+		))
+	}
+	n.add(textEl("CorreoElectronico", e.CorreoElectronico))
+	return n
+}
+
+func buildReceptor(r *Receptor) *node {
+	if r == nil {
+		return nil
+	}
+	n := el("Receptor",
+		textEl("Nombre", r.Nombre),
+		el("Identificacion",
+			textEl("Tipo", r.Identificacion.Tipo),
+			textEl("Numero", r.Identificacion.Numero),
+		),
+	)
+	n.add(optText("CorreoElectronico", r.CorreoElectronico))
+	return n
+}
+
+func buildLinea(numero int, l Linea) *node {
+	n := el("LineaDetalle",
+		textEl("NumeroLinea", fmt.Sprintf("%d", numero)),
+		textEl("CodigoCABYS", l.CodigoCABYS),
+		textEl("Cantidad", amount(l.Cantidad, 3)),
+		textEl("UnidadMedida", l.UnidadMedida),
+		textEl("Detalle", truncate(l.Detalle, 200)),
+		textEl("PrecioUnitario", money(l.PrecioUnitario)),
+		textEl("MontoTotal", money(l.MontoTotal())),
+	)
+	if l.Descuento > 0 {
+		naturaleza := l.NaturalezaDesc
+		if naturaleza == "" {
+			naturaleza = "Descuento por volumen"
+		}
+		n.add(el("Descuento",
+			textEl("MontoDescuento", money(l.Descuento)),
+			textEl("CodigoDescuento", "99"),
+			textEl("CodigoDescuentoOTRO", "Volumen"),
+			textEl("NaturalezaDescuento", truncate(naturaleza, 80)),
+		))
+	}
+	n.add(textEl("SubTotal", money(l.SubTotal())))
+	n.add(textEl("BaseImponible", money(l.SubTotal())))
+	if l.TarifaIVA > 0 {
+		impuesto := el("Impuesto",
+			textEl("Codigo", ImpuestoIVA),
+			textEl("CodigoTarifaIVA", l.CodigoTarifa),
+			textEl("Tarifa", amount(l.TarifaIVA, 2)),
+			textEl("Monto", money(l.Impuesto())),
+		)
+		if ex := l.Exoneracion; ex != nil {
+			impuesto.add(el("Exoneracion",
+				textEl("TipoDocumentoEX1", ex.TipoDocumento),
+				textEl("NumeroDocumento", truncate(ex.NumeroDocumento, 40)),
+				textEl("NombreInstitucion", truncate(ex.NombreInstitucion, 160)),
+				textEl("FechaEmisionEX", ex.FechaEmision.In(zonaCR).Format("2006-01-02T15:04:05-07:00")),
+				textEl("TarifaExonerada", amount(l.TarifaExonerada(), 2)),
+				textEl("MontoExoneracion", money(l.MontoExoneracion())),
+			))
+		}
+		n.add(impuesto)
+	}
+	n.add(
+		textEl("ImpuestoAsumidoEmisorFabrica", money(0)),
+		textEl("ImpuestoNeto", money(l.ImpuestoNeto())),
+		textEl("MontoTotalLinea", money(l.MontoTotalLinea())),
+	)
+	return n
+}
+
+func buildResumen(c *Comprobante) *node {
+	r := c.Resumen()
+	tipoCambio := c.TipoCambio
+	if tipoCambio <= 0 {
+		tipoCambio = 1
+	}
+
+	n := el("ResumenFactura",
+		el("CodigoTipoMoneda",
+			textEl("CodigoMoneda", c.Moneda),
+			textEl("TipoCambio", money(tipoCambio)),
+		),
+		textEl("TotalServGravados", money(r.TotalServGravados)),
+		textEl("TotalServExentos", money(r.TotalServExentos)),
+		textEl("TotalServExonerado", money(r.TotalServExonerado)),
+		textEl("TotalMercanciasGravadas", money(r.TotalMercanciasGravadas)),
+		textEl("TotalMercanciasExentas", money(r.TotalMercanciasExentas)),
+		textEl("TotalMercExonerada", money(r.TotalMercExonerada)),
+		textEl("TotalGravado", money(r.TotalGravado)),
+		textEl("TotalExento", money(r.TotalExento)),
+		textEl("TotalExonerado", money(r.TotalExonerado)),
+		textEl("TotalVenta", money(r.TotalVenta)),
+		textEl("TotalDescuentos", money(r.TotalDescuentos)),
+		textEl("TotalVentaNeta", money(r.TotalVentaNeta)),
+	)
+	for _, d := range r.Desglose {
+		n.add(el("TotalDesgloseImpuesto",
+			textEl("Codigo", d.Codigo),
+			textEl("CodigoTarifaIVA", d.CodigoTarifa),
+			textEl("TotalMontoImpuesto", money(d.TotalMontoImpuesto)),
+		))
+	}
+	n.add(
+		textEl("TotalImpuesto", money(r.TotalImpuesto)),
+		el("MedioPago", textEl("TipoMedioPago", c.MedioPago)),
+		textEl("TotalComprobante", money(r.TotalComprobante)),
+	)
+	return n
+}
+
+func money(v float64) string {
+	return amount(v, 5)
+}
+
+func amount(v float64, decimals int) string {
+	return fmt.Sprintf("%.*f", decimals, v)
+}
+
+func truncate(s string, max int) string {
+	r := []rune(s)
+	if len(r) <= max {
+		return s
+	}
+	return string(r[:max])
+}
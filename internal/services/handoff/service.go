@@ -0,0 +1,280 @@
+// Package handoff gestiona el traspaso de conversaciones de mensajería del bot a
+// un asesor humano: escalado (pending_human), toma desde el panel (human) y
+// cierre (resolved), manual o por inactividad.
+//
+// Postgres (conversation_handoffs) es la bandeja del panel. Redis guarda el estado
+// de las conversaciones abiertas con TTL = inactividad permitida, así el motor de
+// conversación sabe en cada mensaje si debe callarse sin consultar la BD, y la
+// conversación vuelve sola al bot cuando la clave expira.
+package handoff
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/channels"
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+	"github.com/alonsoalpizar/fabricalaser/internal/repository"
+)
+
+var (
+	ErrNotOpen            = errors.New("la atención ya fue cerrada")
+	ErrAlreadyClaimed     = errors.New("otro asesor ya tomó esta conversación")
+	ErrNotClaimed         = errors.New("tomá la conversación antes de responder")
+	ErrEmptyMessage       = errors.New("el mensaje está vacío")
+	ErrChannelUnavailable = errors.New("el canal de la conversación no está disponible")
+)
+
+const (
+	defaultTimeout = 60 * time.Minute
+	asesorTurn     = "[Asesor] %s"
+)
+
+type Service struct {
+	repo      *repository.HandoffRepository
+	sysConfig *repository.SystemConfigRepository
+	store     channels.Store
+	archive   channels.Archive
+	registry  *channels.Registry
+	now       func() time.Time
+}
+
+// NewService conecta la bandeja con el estado en Redis, el archivo de
+// conversaciones y los canales por los que responde el asesor.
+func NewService(store channels.Store, archive channels.Archive, registry *channels.Registry) *Service {
+	return &Service{
+		repo:      repository.NewHandoffRepository(),
+		sysConfig: repository.NewSystemConfigRepository(),
+		store:     store,
+		archive:   archive,
+		registry:  registry,
+		now:       time.Now,
+	}
+}
+
+// Escalate abre la atención humana de la conversación. Si ya hay una abierta se
+// actualiza el resumen y se mantiene el asesor que la tenga tomada.
+func (s *Service) Escalate(ctx context.Context, from channels.Identity, summary string) (*models.Handoff, error) {
+	s.resolveInactive()
+	now := s.now()
+
+	h, err := s.repo.FindOpenByKey(from.Key)
+	switch {
+	case err == nil:
+		h.Summary = optional(summary)
+		h.LastActivityAt = now
+		if err := s.repo.Update(h); err != nil {
+			return nil, fmt.Errorf("handoff: error actualizando atención: %w", err)
+		}
+	case errors.Is(err, repository.ErrHandoffNotFound):
+		h = &models.Handoff{
+			Channel:         from.Channel,
+			ConversationKey: from.Key,
+			ContactID:       from.ID,
+			ContactName:     optional(from.Name),
+			ContactUsername: optional(from.Username),
+			Phone:           optional(from.Phone),
+			Summary:         optional(summary),
+			Status:          models.HandoffPending,
+			LastActivityAt:  now,
+		}
+		if err := s.repo.Create(h); err != nil {
+			return nil, fmt.Errorf("handoff: error creando atención: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("handoff: error buscando atención abierta: %w", err)
+	}
+
+	s.cache(ctx, h)
+	slog.Info("handoff: conversación escalada", "id", h.ID, "canal", h.Channel, "cliente", h.ConversationKey)
+	return h, nil
+}
+
+// Paused implementa channels.Handoffs: true mientras la conversación esté
+// escalada o tomada. Cada mensaje del cliente renueva el plazo de inactividad.
+// Si Redis falla responde el bot: es preferible a dejar al cliente sin respuesta.
+func (s *Service) Paused(ctx context.Context, from channels.Identity) bool {
+	status, err := s.store.Get(ctx, stateKey(from.Key))
+	if err != nil {
+		return false
+	}
+	if status != string(models.HandoffPending) && status != string(models.HandoffHuman) {
+		return false
+	}
+
+	_ = s.store.Expire(ctx, stateKey(from.Key), s.timeout())
+	if err := s.repo.TouchOpen(from.Key, s.now()); err != nil {
+		slog.Warn("handoff: no se pudo registrar actividad", "cliente", from.Key, "error", err)
+	}
+	return true
+}
+
+// List retorna la bandeja; sin estados, las atenciones abiertas
+func (s *Service) List(page, limit int, statuses ...models.HandoffStatus) ([]models.Handoff, int64, error) {
+	s.resolveInactive()
+	return s.repo.FindByStatus(page, limit, statuses...)
+}
+
+// Get retorna una atención por ID
+func (s *Service) Get(id uint) (*models.Handoff, error) {
+	s.resolveInactive()
+	return s.repo.FindByID(id)
+}
+
+// Claim asigna la conversación al admin. Tomar de nuevo una atención propia no es error.
+func (s *Service) Claim(ctx context.Context, id, adminID uint) (*models.Handoff, error) {
+	h, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if !h.IsOpen() {
+		return nil, ErrNotOpen
+	}
+	if h.Status == models.HandoffHuman && h.ClaimedBy != nil && *h.ClaimedBy != adminID {
+		return nil, ErrAlreadyClaimed
+	}
+
+	now := s.now()
+	if h.Status != models.HandoffHuman {
+		h.Status = models.HandoffHuman
+		h.ClaimedBy = &adminID
+		h.ClaimedAt = &now
+	}
+	h.LastActivityAt = now
+	if err := s.repo.Update(h); err != nil {
+		return nil, fmt.Errorf("handoff: error tomando atención: %w", err)
+	}
+	s.cache(ctx, h)
+	return h, nil
+}
+
+// Reply envía el mensaje del admin al cliente por el canal de la conversación y
+// lo deja en el historial, así el bot tiene el contexto cuando retome.
+func (s *Service) Reply(ctx context.Context, id, adminID uint, text string) (*models.Handoff, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, ErrEmptyMessage
+	}
+	h, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if !h.IsOpen() {
+		return nil, ErrNotOpen
+	}
+	if h.Status != models.HandoffHuman || h.ClaimedBy == nil {
+		return nil, ErrNotClaimed
+	}
+	if *h.ClaimedBy != adminID {
+		return nil, ErrAlreadyClaimed
+	}
+
+	ch, ok := s.registry.Get(h.Channel)
+	if !ok {
+		return nil, ErrChannelUnavailable
+	}
+	if err := ch.SendText(ctx, identity(h), text); err != nil {
+		return nil, fmt.Errorf("%w: error enviando por %s: %v", ErrChannelUnavailable, ch.Label(), err)
+	}
+
+	now := s.now()
+	turn := channels.Turn{Role: "model", Content: fmt.Sprintf(asesorTurn, text)}
+	if err := channels.AppendHistory(ctx, s.store, ch.Namespace(), h.ConversationKey, turn); err != nil {
+		slog.Error("handoff: error actualizando historial", "id", h.ID, "error", err)
+	}
+	archived := channels.ArchivedTurn{Phone: h.ConversationKey, Role: turn.Role, Content: turn.Content, CreatedAt: now}
+	if err := s.archive.SaveTurn(ctx, archived); err != nil {
+		slog.Error("handoff: error archivando respuesta del asesor", "id", h.ID, "error", err)
+	}
+
+	h.LastActivityAt = now
+	if err := s.repo.Update(h); err != nil {
+		return nil, fmt.Errorf("handoff: error actualizando atención: %w", err)
+	}
+	s.cache(ctx, h)
+	return h, nil
+}
+
+// Resolve cierra la atención y devuelve la conversación al bot
+func (s *Service) Resolve(ctx context.Context, id uint) (*models.Handoff, error) {
+	h, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if !h.IsOpen() {
+		return nil, ErrNotOpen
+	}
+
+	now := s.now()
+	reason := models.HandoffResolvedByAdmin
+	h.Status = models.HandoffResolved
+	h.ResolvedAt = &now
+	h.ResolvedReason = &reason
+	if err := s.repo.Update(h); err != nil {
+		return nil, fmt.Errorf("handoff: error cerrando atención: %w", err)
+	}
+	s.cache(ctx, h)
+	return h, nil
+}
+
+// cache deja el estado en Redis. Las cerradas también se escriben (en lugar de
+// borrar la clave) para que el motor deje de pausar de inmediato.
+func (s *Service) cache(ctx context.Context, h *models.Handoff) {
+	if err := s.store.Set(ctx, stateKey(h.ConversationKey), string(h.Status), s.timeout()); err != nil {
+		slog.Error("handoff: error guardando estado en Redis", "id", h.ID, "error", err)
+	}
+}
+
+// resolveInactive cierra en la BD las atenciones cuyo plazo ya venció en Redis
+func (s *Service) resolveInactive() {
+	now := s.now()
+	n, err := s.repo.ResolveInactive(now.Add(-s.timeout()), now)
+	if err != nil {
+		slog.Error("handoff: error cerrando atenciones inactivas", "error", err)
+		return
+	}
+	if n > 0 {
+		slog.Info("handoff: atenciones devueltas al bot por inactividad", "cantidad", n)
+	}
+}
+
+// timeout lee handoff_inactividad_min de system_config (default 60 minutos)
+func (s *Service) timeout() time.Duration {
+	if cfg, err := s.sysConfig.FindByKey("handoff_inactividad_min"); err == nil {
+		if n, err := strconv.Atoi(strings.TrimSpace(cfg.ConfigValue)); err == nil && n > 0 {
+			return time.Duration(n) * time.Minute
+		}
+	}
+	return defaultTimeout
+}
+
+func stateKey(conversationKey string) string {
+	return "handoff:" + conversationKey
+}
+
+// identity reconstruye el contacto para responderle por su canal
+func identity(h *models.Handoff) channels.Identity {
+	id := channels.Identity{Channel: h.Channel, ID: h.ContactID, Key: h.ConversationKey}
+	if h.Phone != nil {
+		id.Phone = *h.Phone
+	}
+	if h.ContactName != nil {
+		id.Name = *h.ContactName
+	}
+	if h.ContactUsername != nil {
+		id.Username = *h.ContactUsername
+	}
+	return id
+}
+
+func optional(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
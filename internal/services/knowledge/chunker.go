@@ -0,0 +1,128 @@
+package knowledge
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+)
+
+// maxPassageChars es el tamaño máximo de un pasaje. Los pasajes chicos hacen
+// que el agente reciba solo lo relevante; una sección más larga se parte por
+// párrafos (y un párrafo enorme, por oraciones).
+const maxPassageChars = 700
+
+// Passage es un fragmento citable de un artículo
+type Passage struct {
+	ArticleID uint
+	Title     string
+	Heading   string // Encabezado markdown de la sección ("" si el artículo no tiene)
+	Text      string
+	Audience  string
+}
+
+// Citation es la referencia que el agente menciona al usar el pasaje
+func (p Passage) Citation() string {
+	c := "KB-" + strconv.FormatUint(uint64(p.ArticleID), 10) + " " + p.Title
+	if p.Heading != "" && p.Heading != p.Title {
+		c += " › " + p.Heading
+	}
+	return c
+}
+
+// Chunk parte un artículo en pasajes: cada encabezado markdown abre una sección
+// y las secciones se agrupan por párrafos hasta maxChars.
+func Chunk(a models.KBArticle, maxChars int) []Passage {
+	if maxChars <= 0 {
+		maxChars = maxPassageChars
+	}
+
+	var passages []Passage
+	emit := func(heading string, paragraphs []string) {
+		for _, text := range pack(paragraphs, maxChars) {
+			passages = append(passages, Passage{
+				ArticleID: a.ID,
+				Title:     a.Title,
+				Heading:   heading,
+				Text:      text,
+				Audience:  a.Audience,
+			})
+		}
+	}
+
+	heading := ""
+	var paragraphs []string
+	var current strings.Builder
+	flushParagraph := func() {
+		if p := strings.TrimSpace(current.String()); p != "" {
+			paragraphs = append(paragraphs, p)
+		}
+		current.Reset()
+	}
+
+	for _, line := range strings.Split(a.Content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, "#"):
+			flushParagraph()
+			emit(heading, paragraphs)
+			paragraphs = nil
+			heading = strings.TrimSpace(strings.TrimLeft(trimmed, "#"))
+		case trimmed == "":
+			flushParagraph()
+		default:
+			if current.Len() > 0 {
+				current.WriteByte(' ')
+			}
+			current.WriteString(trimmed)
+		}
+	}
+	flushParagraph()
+	emit(heading, paragraphs)
+	return passages
+}
+
+// pack junta párrafos consecutivos mientras quepan en maxChars
+func pack(paragraphs []string, maxChars int) []string {
+	var out []string
+	var b strings.Builder
+	flush := func() {
+		if b.Len() > 0 {
+			out = append(out, b.String())
+			b.Reset()
+		}
+	}
+	for _, p := range paragraphs {
+		for _, piece := range splitLong(p, maxChars) {
+			if b.Len() > 0 && b.Len()+1+len(piece) > maxChars {
+				flush()
+			}
+			if b.Len() > 0 {
+				b.WriteByte('\n')
+			}
+			b.WriteString(piece)
+		}
+	}
+	flush()
+	return out
+}
+
+// splitLong parte un párrafo más largo que maxChars en grupos de oraciones
+func splitLong(p string, maxChars int) []string {
+	if len(p) <= maxChars {
+		return []string{p}
+	}
+	var out []string
+	var b strings.Builder
+	for _, sentence := range strings.SplitAfter(p, ". ") {
+		if b.Len() > 0 && b.Len()+len(sentence) > maxChars {
+			out = append(out, strings.TrimSpace(b.String()))
+			b.Reset()
+		}
+		b.WriteString(sentence)
+	}
+	if s := strings.TrimSpace(b.String()); s != "" {
+		out = append(out, s)
+	}
+	return out
+}
@@ -0,0 +1,149 @@
+package knowledge
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// Vector es la representación de un texto para compararlo por similitud coseno
+type Vector []float32
+
+// Embedder convierte pasajes y consultas en vectores comparables. Fit recibe
+// el corpus completo antes de embeber: TF-IDF necesita el vocabulario y las
+// frecuencias de documento; un embedder remoto (Vertex) lo puede ignorar.
+type Embedder interface {
+	Fit(corpus []string)
+	Embed(text string) Vector
+}
+
+// Cosine es la similitud coseno entre dos vectores (0 si alguno es nulo o
+// tienen dimensiones distintas)
+func Cosine(a, b Vector) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
+
+// TFIDF es el embedder local: bolsa de palabras con tf sublineal e idf
+// suavizado. No depende de ningún servicio externo; alcanza para una base de
+// conocimiento de decenas o cientos de artículos.
+type TFIDF struct {
+	vocab map[string]int
+	idf   []float32
+}
+
+// NewTFIDF crea el embedder sin entrenar (Fit arma el vocabulario)
+func NewTFIDF() *TFIDF {
+	return &TFIDF{vocab: map[string]int{}}
+}
+
+// Fit arma el vocabulario y el idf con el corpus
+func (t *TFIDF) Fit(corpus []string) {
+	t.vocab = map[string]int{}
+	var df []int
+	for _, doc := range corpus {
+		seen := map[int]bool{}
+		for _, term := range Tokenize(doc) {
+			idx, ok := t.vocab[term]
+			if !ok {
+				idx = len(df)
+				t.vocab[term] = idx
+				df = append(df, 0)
+			}
+			if !seen[idx] {
+				seen[idx] = true
+				df[idx]++
+			}
+		}
+	}
+	n := float64(len(corpus))
+	t.idf = make([]float32, len(df))
+	for i, d := range df {
+		t.idf[i] = float32(math.Log((1+n)/(1+float64(d))) + 1)
+	}
+}
+
+// Embed vectoriza el texto con el vocabulario de Fit; los términos
+// desconocidos se ignoran
+func (t *TFIDF) Embed(text string) Vector {
+	v := make(Vector, len(t.idf))
+	counts := map[int]int{}
+	for _, term := range Tokenize(text) {
+		if idx, ok := t.vocab[term]; ok {
+			counts[idx]++
+		}
+	}
+	for idx, c := range counts {
+		v[idx] = float32(1+math.Log(float64(c))) * t.idf[idx]
+	}
+	return v
+}
+
+// stopwords del español que no aportan a la búsqueda
+var stopwords = map[string]bool{}
+
+func init() {
+	for _, w := range strings.Fields(`a al algo como con cual cuando de del el ella ellos en entre era es esa ese eso esta este esto
+		fue ha hay la las le les lo los mas me mi mis muy no nos o para pero por que se si sin sobre son su sus te
+		tu un una uno unos unas y ya yo vos usted ustedes puedo puede pueden quiero tengo tienen tiene hacer hacen
+		cuanto cuanta cuantos cuantas donde como que quien hola gracias favor`) {
+		stopwords[w] = true
+	}
+}
+
+// Tokenize normaliza el texto para indexarlo: minúsculas, sin tildes, sin
+// stopwords y con un stemming liviano (plural y vocal final) para que
+// "envíos", "envio" y "enviar" no queden como términos distintos
+func Tokenize(text string) []string {
+	folded := accents.Replace(strings.ToLower(text))
+	words := strings.FieldsFunc(folded, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	terms := make([]string, 0, len(words))
+	for _, w := range words {
+		if len(w) < 2 || stopwords[w] {
+			continue
+		}
+		terms = append(terms, stem(w))
+	}
+	return terms
+}
+
+// stem recorta plural, infinitivo y vocal final de palabras largas
+func stem(w string) string {
+	if len(w) <= 4 || isDigits(w) {
+		return w
+	}
+	w = strings.TrimSuffix(w, "s")
+	for _, suffix := range []string{"ar", "er", "ir"} {
+		if len(w) > 5 && strings.HasSuffix(w, suffix) {
+			return w[:len(w)-2]
+		}
+	}
+	if last := w[len(w)-1]; len(w) > 4 && (last == 'a' || last == 'e' || last == 'o') {
+		w = w[:len(w)-1]
+	}
+	return w
+}
+
+func isDigits(w string) bool {
+	for _, r := range w {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
+
+var accents = strings.NewReplacer("á", "a", "é", "e", "í", "i", "ó", "o", "ú", "u", "ü", "u", "ñ", "n")
@@ -0,0 +1,156 @@
+// Package knowledge es la base de conocimiento de los agentes: artículos de
+// negocio (envíos, tiempos, preparación de archivos, cuidado de piezas, FAQ)
+// partidos en pasajes e indexados en memoria para la tool buscar_conocimiento.
+//
+// El índice se arma con un Embedder (TF-IDF local por defecto) la primera vez
+// que se busca, y se rearma cuando el panel admin edita un artículo
+// (Invalidate) o cada indexTTL por si otro proceso cambió la tabla.
+package knowledge
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+	"github.com/alonsoalpizar/fabricalaser/internal/repository"
+)
+
+const (
+	indexTTL = 10 * time.Minute
+
+	// DefaultResults es la cantidad de pasajes que recibe el agente
+	DefaultResults = 3
+
+	// minScore descarta pasajes que solo comparten palabras sueltas con la consulta
+	minScore = 0.08
+)
+
+// Result es un pasaje encontrado con su puntaje de similitud
+type Result struct {
+	Passage
+	Score float64
+}
+
+// index son los pasajes ya vectorizados
+type index struct {
+	embedder Embedder
+	passages []Passage
+	vectors  []Vector
+	builtAt  time.Time
+}
+
+// Service busca en la base de conocimiento. Es seguro para uso concurrente y
+// se comparte entre todos los agentes.
+type Service struct {
+	repo        *repository.KnowledgeRepository
+	newEmbedder func() Embedder
+
+	mu    sync.RWMutex
+	index *index
+}
+
+// NewService crea el servicio con el embedder TF-IDF local
+func NewService() *Service {
+	return &Service{
+		repo:        repository.NewKnowledgeRepository(),
+		newEmbedder: func() Embedder { return NewTFIDF() },
+	}
+}
+
+// Search retorna los k pasajes más parecidos a la consulta. Los artículos
+// internos solo se incluyen con includeInternal (chat admin).
+func (s *Service) Search(query string, k int, includeInternal bool) ([]Result, error) {
+	idx, err := s.current()
+	if err != nil {
+		return nil, err
+	}
+	return idx.search(query, k, includeInternal), nil
+}
+
+// Invalidate descarta el índice; la próxima búsqueda lo rearma con la tabla
+func (s *Service) Invalidate() {
+	s.mu.Lock()
+	s.index = nil
+	s.mu.Unlock()
+}
+
+// Preview parte un artículo en pasajes sin guardarlo (panel admin)
+func (s *Service) Preview(a models.KBArticle) []Passage {
+	return Chunk(a, maxPassageChars)
+}
+
+func (s *Service) current() (*index, error) {
+	s.mu.RLock()
+	idx := s.index
+	s.mu.RUnlock()
+	if idx != nil && time.Since(idx.builtAt) < indexTTL {
+		return idx, nil
+	}
+
+	articles, err := s.repo.FindActive()
+	if err != nil {
+		return nil, fmt.Errorf("knowledge: cargando artículos: %w", err)
+	}
+	idx = buildIndex(articles, s.newEmbedder())
+
+	s.mu.Lock()
+	s.index = idx
+	s.mu.Unlock()
+	return idx, nil
+}
+
+// buildIndex parte los artículos y vectoriza los pasajes. El texto indexado
+// suma título, sección y tags del artículo para que una consulta corta
+// ("¿hacen envíos?") encuentre el pasaje aunque no repita sus palabras.
+func buildIndex(articles []models.KBArticle, embedder Embedder) *index {
+	var passages []Passage
+	var corpus []string
+	for _, a := range articles {
+		tags := strings.Join(articleTags(a), " ")
+		for _, p := range Chunk(a, maxPassageChars) {
+			passages = append(passages, p)
+			corpus = append(corpus, strings.Join([]string{p.Title, p.Heading, tags, p.Text}, " "))
+		}
+	}
+
+	embedder.Fit(corpus)
+	vectors := make([]Vector, len(corpus))
+	for i, doc := range corpus {
+		vectors[i] = embedder.Embed(doc)
+	}
+	return &index{embedder: embedder, passages: passages, vectors: vectors, builtAt: time.Now()}
+}
+
+func (idx *index) search(query string, k int, includeInternal bool) []Result {
+	if k <= 0 {
+		k = DefaultResults
+	}
+	q := idx.embedder.Embed(query)
+
+	var results []Result
+	for i, p := range idx.passages {
+		if p.Audience == models.KBAudienceInternal && !includeInternal {
+			continue
+		}
+		if score := Cosine(q, idx.vectors[i]); score >= minScore {
+			results = append(results, Result{Passage: p, Score: score})
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	if len(results) > k {
+		results = results[:k]
+	}
+	return results
+}
+
+func articleTags(a models.KBArticle) []string {
+	var tags []string
+	if len(a.Tags) > 0 {
+		_ = json.Unmarshal(a.Tags, &tags)
+	}
+	return tags
+}
@@ -0,0 +1,72 @@
+package knowledge
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+)
+
+var testArticles = []models.KBArticle{
+	{
+		ID: 1, Title: "Retiro en taller y envíos", Audience: models.KBAudiencePublic,
+		Content: "# Retiro en taller\nEl retiro es solo con cita previa.\n\n# Envíos\nEnviamos a todo el país por Correos de Costa Rica.\nTarifa: ₡3.500 el primer kilo.",
+		Tags:    []byte(`["encomienda", "domicilio"]`),
+	},
+	{
+		ID: 2, Title: "Cuidado del acrílico grabado", Audience: models.KBAudiencePublic,
+		Content: "Limpiar con un paño suave y agua con jabón neutro. No usar alcohol ni limpiavidrios: opacan el acrílico.",
+	},
+	{
+		ID: 3, Title: "Margen mínimo en cotizaciones", Audience: models.KBAudienceInternal,
+		Content: "Las cotizaciones manuales no bajan del 35% de margen sobre el costo.",
+	},
+}
+
+func TestChunk(t *testing.T) {
+	passages := Chunk(testArticles[0], 0)
+	if len(passages) != 2 {
+		t.Fatalf("passages = %d, want 2: %+v", len(passages), passages)
+	}
+	if passages[1].Heading != "Envíos" || !strings.HasPrefix(passages[1].Text, "Enviamos a todo el país") {
+		t.Errorf("passage = %+v", passages[1])
+	}
+	if got := passages[1].Citation(); got != "KB-1 Retiro en taller y envíos › Envíos" {
+		t.Errorf("citation = %q", got)
+	}
+
+	// Una sección larga se parte por oraciones sin pasar de maxChars
+	long := models.KBArticle{ID: 9, Title: "Largo", Content: strings.Repeat("Una oración de relleno. ", 40)}
+	for _, p := range Chunk(long, 120) {
+		if len(p.Text) > 120 {
+			t.Errorf("pasaje de %d caracteres", len(p.Text))
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+	idx := buildIndex(testArticles, NewTFIDF())
+
+	cases := []struct {
+		query    string
+		internal bool
+		want     string // prefijo de la cita del primer resultado; "" = sin resultados
+	}{
+		{"¿hacen envíos a Limón por correos?", false, "KB-1 Retiro en taller y envíos › Envíos"},
+		{"me lo mandan a domicilio?", false, "KB-1 "}, // por los tags del artículo
+		{"cómo limpio el acrilico", false, "KB-2 Cuidado del acrílico grabado"},
+		{"margen de las cotizaciones manuales", false, ""},
+		{"margen de las cotizaciones manuales", true, "KB-3 Margen mínimo en cotizaciones"},
+		{"horario de atención", false, ""},
+	}
+	for _, c := range cases {
+		results := idx.search(c.query, 3, c.internal)
+		got := ""
+		if len(results) > 0 {
+			got = results[0].Citation()
+		}
+		if (c.want == "" && got != "") || !strings.HasPrefix(got, c.want) {
+			t.Errorf("search(%q, internal=%v) = %q, want %q", c.query, c.internal, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package knowledge
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/llm"
+)
+
+// Tool es la declaración de buscar_conocimiento, la misma para todos los agentes
+func Tool() llm.Tool {
+	return llm.Tool{
+		Name:        "buscar_conocimiento",
+		Description: "Busca en la base de conocimiento de FabricaLaser: envíos y retiro, tiempos de producción, preparación de archivos de diseño, cuidado de las piezas y preguntas frecuentes. Devuelve los pasajes más relevantes con su cita. Usar antes de responder cualquier duda del negocio que no sea un precio.",
+		Parameters: &llm.Schema{
+			Type: llm.TypeObject,
+			Properties: map[string]*llm.Schema{
+				"consulta": {
+					Type:        llm.TypeString,
+					Description: "La duda del cliente en pocas palabras, ej: \"costo de envío a Limón\", \"cómo limpiar acrílico grabado\"",
+				},
+			},
+			Required: []string{"consulta"},
+		},
+	}
+}
+
+// Handler ejecuta buscar_conocimiento. includeInternal habilita los artículos
+// internos (solo el chat admin).
+func (s *Service) Handler(includeInternal bool) llm.ToolHandler {
+	return func(ctx context.Context, args map[string]any) (map[string]any, error) {
+		consulta, _ := args["consulta"].(string)
+		if strings.TrimSpace(consulta) == "" {
+			return map[string]any{"error": "consulta es obligatoria"}, nil
+		}
+
+		results, err := s.Search(consulta, DefaultResults, includeInternal)
+		if err != nil {
+			return nil, fmt.Errorf("buscar_conocimiento: %w", err)
+		}
+		if len(results) == 0 {
+			return map[string]any{
+				"encontrado": false,
+				"nota":       "No hay información sobre esto en la base de conocimiento. No inventés: decilo y ofrecé consultarlo con un asesor.",
+			}, nil
+		}
+
+		pasajes := make([]map[string]any, len(results))
+		for i, r := range results {
+			pasajes[i] = map[string]any{
+				"cita":       r.Citation(),
+				"texto":      r.Text,
+				"relevancia": math.Round(r.Score*100) / 100,
+			}
+		}
+		return map[string]any{
+			"encontrado": true,
+			"pasajes":    pasajes,
+			"nota":       "Respondé solo con lo que dicen los pasajes.",
+		}, nil
+	}
+}
@@ -0,0 +1,192 @@
+// Package leads sigue a cada contacto de WhatsApp y Telegram a lo largo del embudo
+// de ventas: primer contacto, precio del bot, escalado a asesor y pedido.
+//
+// El motor de conversación registra cada mensaje (Touch), las tools del agente
+// avanzan la etapa (Advance) y los eventos de dominio ligan las cotizaciones y
+// pedidos de la cuenta del cliente una vez que el lead se asoció a un usuario.
+package leads
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/channels"
+	"github.com/alonsoalpizar/fabricalaser/internal/events"
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+	"github.com/alonsoalpizar/fabricalaser/internal/repository"
+)
+
+type Service struct {
+	repo     *repository.LeadRepository
+	userRepo *repository.UserRepository
+	now      func() time.Time
+}
+
+func NewService() *Service {
+	return &Service{
+		repo:     repository.NewLeadRepository(),
+		userRepo: repository.NewUserRepository(),
+		now:      time.Now,
+	}
+}
+
+// Subscribe liga al embudo las cotizaciones y pedidos de los clientes con lead
+func (s *Service) Subscribe(bus *events.Bus) {
+	bus.Subscribe(s.handleEvent, events.QuoteCreated, events.OrderStatusChanged)
+}
+
+// Touch implementa channels.Leads: crea el lead en el primer contacto y registra
+// la actividad en los siguientes. Mientras no esté asociado a una cuenta intenta
+// asociarlo (el cliente puede registrarse a mitad de la conversación).
+func (s *Service) Touch(ctx context.Context, from channels.Identity) {
+	if err := s.touch(from); err != nil {
+		slog.Error("leads: error registrando contacto", "canal", from.Channel, "cliente", from.Key, "error", err)
+	}
+}
+
+func (s *Service) touch(from channels.Identity) error {
+	now := s.now()
+	lead, err := s.repo.FindByKey(from.Key)
+	switch {
+	case errors.Is(err, repository.ErrLeadNotFound):
+		lead = &models.Lead{
+			Channel:         from.Channel,
+			ConversationKey: from.Key,
+			ContactID:       from.ID,
+			Stage:           models.LeadNew,
+			MessageCount:    1,
+			FirstContactAt:  now,
+			LastContactAt:   now,
+		}
+		s.refreshContact(lead, from)
+		s.enrich(lead, from)
+		if err := s.repo.Create(lead); err != nil {
+			// Dos mensajes simultáneos del mismo contacto nuevo: el otro ya lo creó
+			if existing, findErr := s.repo.FindByKey(from.Key); findErr == nil {
+				lead = existing
+				break
+			}
+			return fmt.Errorf("error creando lead: %w", err)
+		}
+		slog.Info("leads: nuevo contacto", "id", lead.ID, "canal", lead.Channel, "registrado", lead.UserID != nil)
+		return nil
+	case err != nil:
+		return fmt.Errorf("error buscando lead: %w", err)
+	}
+
+	lead.MessageCount++
+	lead.LastContactAt = now
+	s.refreshContact(lead, from)
+	if lead.UserID == nil {
+		s.enrich(lead, from)
+	}
+	return s.repo.Update(lead)
+}
+
+// Advance mueve el lead de la conversación a stage (las tools del agente).
+// Si el lead no existe todavía se crea: el hito no se pierde.
+func (s *Service) Advance(ctx context.Context, from channels.Identity, stage models.LeadStage) {
+	lead, err := s.repo.FindByKey(from.Key)
+	if errors.Is(err, repository.ErrLeadNotFound) {
+		if err = s.touch(from); err == nil {
+			lead, err = s.repo.FindByKey(from.Key)
+		}
+	}
+	if err != nil {
+		slog.Error("leads: error buscando lead", "cliente", from.Key, "error", err)
+		return
+	}
+
+	if !lead.Advance(stage, s.now()) {
+		return
+	}
+	if err := s.repo.Update(lead); err != nil {
+		slog.Error("leads: error avanzando etapa", "id", lead.ID, "etapa", stage, "error", err)
+		return
+	}
+	slog.Info("leads: etapa actualizada", "id", lead.ID, "canal", lead.Channel, "etapa", lead.Stage)
+}
+
+// handleEvent liga la cotización a los leads del cliente y avanza la etapa
+func (s *Service) handleEvent(ctx context.Context, ev events.Event) {
+	stage := models.LeadQuoted
+	if ev.Type == events.OrderStatusChanged {
+		if ev.Status != string(models.QuoteStatusConverted) {
+			return
+		}
+		stage = models.LeadConverted
+	}
+
+	leads, err := s.repo.FindByUserID(ev.UserID)
+	if err != nil {
+		slog.Error("leads: error buscando leads del cliente", "user_id", ev.UserID, "error", err)
+		return
+	}
+	for i := range leads {
+		lead := &leads[i]
+		if ev.QuoteID > 0 {
+			if err := s.repo.LinkQuote(lead.ID, ev.QuoteID); err != nil {
+				slog.Error("leads: error ligando cotización", "id", lead.ID, "quote_id", ev.QuoteID, "error", err)
+			}
+		}
+		if lead.Advance(stage, ev.OccurredAt) {
+			if err := s.repo.Update(lead); err != nil {
+				slog.Error("leads: error avanzando etapa", "id", lead.ID, "etapa", stage, "error", err)
+			}
+		}
+	}
+}
+
+// refreshContact actualiza los datos del contacto que reporta el canal
+func (s *Service) refreshContact(lead *models.Lead, from channels.Identity) {
+	if from.Name != "" {
+		lead.ContactName = &from.Name
+	}
+	if from.Username != "" {
+		lead.ContactUsername = &from.Username
+	}
+	if from.Phone != "" {
+		lead.Phone = &from.Phone
+	}
+}
+
+// enrich asocia el lead a una cuenta: por teléfono en WhatsApp (mismo criterio que
+// FindUserByPhone: activo y con contraseña) o por el chat de Telegram que el
+// cliente vinculó para avisos.
+func (s *Service) enrich(lead *models.Lead, from channels.Identity) {
+	var user *models.User
+	var err error
+	switch {
+	case from.Phone != "":
+		user, err = s.userRepo.FindByTelefono(localPhone(from.Phone))
+	case from.Channel == channels.Telegram:
+		chatID, parseErr := strconv.ParseInt(from.ID, 10, 64)
+		if parseErr != nil {
+			return
+		}
+		user, err = s.userRepo.FindByTelegramChatID(chatID)
+	default:
+		return
+	}
+	if err != nil {
+		return
+	}
+	lead.UserID = &user.ID
+	if lead.ContactName == nil {
+		nombre := user.NombreCompleto()
+		lead.ContactName = &nombre
+	}
+}
+
+// localPhone: "50688887777" → "88887777", como se guarda users.telefono
+func localPhone(phone string) string {
+	if strings.HasPrefix(phone, "506") && len(phone) == 11 {
+		return phone[3:]
+	}
+	return phone
+}
@@ -0,0 +1,85 @@
+package memory
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+)
+
+func TestParseProfile(t *testing.T) {
+	text := "```json\n{\"resumen\": \"Tiene una cafetería en Heredia\", \"materiales\": [\"MDF 3mm\"], \"pendientes\": [\"enviar logo en SVG\"]}\n```"
+	p, err := parseProfile(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Resumen != "Tiene una cafetería en Heredia" || len(p.Materiales) != 1 || p.Pendientes[0] != "enviar logo en SVG" {
+		t.Errorf("profile = %+v", p)
+	}
+
+	if _, err := parseProfile("El cliente quiere llaveros."); err == nil {
+		t.Error("expected error for non-JSON reply")
+	}
+}
+
+func TestApplyClampsProfile(t *testing.T) {
+	var products []string
+	for i := 1; i <= 10; i++ {
+		products = append(products, fmt.Sprintf("llaveros lote %d", i))
+	}
+	p := Profile{
+		Resumen:      strings.Repeat("á", 500),
+		Productos:    products,
+		Preferencias: []string{"acabado mate", "Acabado mate", "  ", strings.Repeat("x", 300)},
+	}
+
+	var m models.CustomerMemory
+	p.Apply(&m)
+	got := ProfileOf(&m)
+
+	if n := utf8.RuneCountInString(got.Resumen); n != maxSummaryChar {
+		t.Errorf("summary runes = %d, want %d", n, maxSummaryChar)
+	}
+	// Se quedan los más recientes
+	if len(got.Productos) != maxItems || got.Productos[maxItems-1] != "llaveros lote 10" || got.Productos[0] != "llaveros lote 5" {
+		t.Errorf("products = %v", got.Productos)
+	}
+	// Duplicados y vacíos fuera, elementos largos recortados
+	if len(got.Preferencias) != 2 || utf8.RuneCountInString(got.Preferencias[1]) != maxItemChars {
+		t.Errorf("preferences = %v", got.Preferencias)
+	}
+}
+
+func TestRenderBudget(t *testing.T) {
+	m := &models.CustomerMemory{LastSession: "Cotizó 50 llaveros de acrílico."}
+	Profile{
+		Resumen:    "Tiene una tienda de regalos en Cartago.",
+		Pendientes: []string{"confirmar colores del acrílico"},
+		Productos:  []string{"llaveros", "rótulo", "medallas", "portarretratos"},
+		Materiales: []string{"acrílico 3mm", "MDF 3mm"},
+	}.Apply(m)
+
+	full := Render(m, DefaultBudget)
+	for _, want := range []string{"MEMORIA DEL CLIENTE", "Perfil: Tiene una tienda", "Pendiente de la vez anterior: confirmar colores", "Productos que ha cotizado o pedido:\n  llaveros\n  rótulo"} {
+		if !strings.Contains(full, want) {
+			t.Errorf("render missing %q:\n%s", want, full)
+		}
+	}
+
+	// Con poco presupuesto entran el perfil y la última sesión, no las listas
+	short := Render(m, 300)
+	if len(short) > 300 || !strings.Contains(short, "Última conversación") || strings.Contains(short, "Materiales habituales") {
+		t.Errorf("short render (%d chars):\n%s", len(short), short)
+	}
+
+	// Opt-out o memoria vacía no llegan al prompt
+	m.OptedOut = true
+	if got := Render(m, DefaultBudget); got != "" {
+		t.Errorf("opted-out render = %q", got)
+	}
+	if got := Render(&models.CustomerMemory{Preferences: []byte("[]")}, DefaultBudget); got != "" {
+		t.Errorf("empty render = %q", got)
+	}
+}
@@ -0,0 +1,193 @@
+package memory
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+	"gorm.io/datatypes"
+)
+
+const (
+	maxItems       = 6   // por lista
+	maxItemChars   = 120 // por elemento de lista
+	maxSummaryChar = 400 // resumen y última sesión
+
+	// DefaultBudget es el tope de caracteres del bloque que se suma al prompt
+	DefaultBudget = 1200
+)
+
+// Profile es la forma estructurada de la memoria: lo que el modelo devuelve al
+// fundir una sesión nueva y lo que edita el panel admin.
+type Profile struct {
+	Resumen      string   `json:"resumen"`
+	Preferencias []string `json:"preferencias"`
+	Productos    []string `json:"productos"`
+	Materiales   []string `json:"materiales"`
+	Pendientes   []string `json:"pendientes"`
+}
+
+// ProfileOf lee el perfil guardado en una memoria
+func ProfileOf(m *models.CustomerMemory) Profile {
+	return Profile{
+		Resumen:      m.Summary,
+		Preferencias: decodeList(m.Preferences),
+		Productos:    decodeList(m.PastProducts),
+		Materiales:   decodeList(m.TypicalMaterials),
+		Pendientes:   decodeList(m.OpenQuestions),
+	}
+}
+
+// Apply guarda el perfil en la memoria respetando los topes de tamaño
+func (p Profile) Apply(m *models.CustomerMemory) {
+	p = p.clamp()
+	m.Summary = p.Resumen
+	m.Preferences = encodeList(p.Preferencias)
+	m.PastProducts = encodeList(p.Productos)
+	m.TypicalMaterials = encodeList(p.Materiales)
+	m.OpenQuestions = encodeList(p.Pendientes)
+}
+
+// clamp recorta el perfil a los topes: se quedan los últimos elementos de
+// cada lista (el modelo agrega lo nuevo al final)
+func (p Profile) clamp() Profile {
+	p.Resumen = truncate(p.Resumen, maxSummaryChar)
+	p.Preferencias = clampList(p.Preferencias)
+	p.Productos = clampList(p.Productos)
+	p.Materiales = clampList(p.Materiales)
+	p.Pendientes = clampList(p.Pendientes)
+	return p
+}
+
+// mergePrompt arma el pedido al modelo para fundir el resumen de la sesión
+// que terminó con la memoria que ya había
+func mergePrompt(current Profile, session string) string {
+	actual, _ := json.Marshal(current.clamp())
+	return "Mantenés la ficha de un cliente de FabricaLaser (corte y grabado láser) para que el " +
+		"agente virtual lo atienda mejor la próxima vez. Fundí el resumen de su última conversación " +
+		"con la ficha actual y devolvé SOLO un objeto JSON con esta forma:\n" +
+		`{"resumen": "quién es y para qué usa el láser, 1-2 oraciones", ` +
+		`"preferencias": ["..."], "productos": ["productos cotizados o pedidos"], ` +
+		`"materiales": ["materiales que suele usar"], "pendientes": ["preguntas o pasos que quedaron abiertos"]}` + "\n\n" +
+		"Reglas: frases cortas y concretas; sin datos de contacto, pagos ni documentos de identidad; " +
+		fmt.Sprintf("máximo %d elementos por lista, lo más reciente al final; ", maxItems) +
+		"quitá de pendientes lo que la última conversación resolvió; no inventés nada que no esté en el texto.\n\n" +
+		"Ficha actual:\n" + string(actual) + "\n\n" +
+		"Resumen de la última conversación:\n" + session
+}
+
+// parseProfile lee la respuesta del modelo, con o sin bloque de código
+func parseProfile(text string) (Profile, error) {
+	text = strings.TrimSpace(text)
+	text = strings.TrimPrefix(text, "```json")
+	text = strings.TrimPrefix(text, "```")
+	text = strings.TrimSuffix(text, "```")
+	if i, j := strings.Index(text, "{"), strings.LastIndex(text, "}"); i >= 0 && j > i {
+		text = text[i : j+1]
+	}
+	var p Profile
+	if err := json.Unmarshal([]byte(text), &p); err != nil {
+		return Profile{}, fmt.Errorf("memory: respuesta del modelo no es JSON: %w", err)
+	}
+	return p, nil
+}
+
+// Render arma el bloque MEMORIA DEL CLIENTE para el system prompt sin pasar de
+// budget caracteres: se cortan primero las listas menos útiles para retomar
+// la conversación.
+func Render(m *models.CustomerMemory, budget int) string {
+	if m == nil || m.OptedOut || m.Empty() {
+		return ""
+	}
+	if budget <= 0 {
+		budget = DefaultBudget
+	}
+	p := ProfileOf(m)
+
+	header := "\n\nMEMORIA DEL CLIENTE (resumen de conversaciones anteriores; usala con naturalidad, " +
+		"no la recites ni digas que la tenés guardada):\n"
+	sections := []struct {
+		label string
+		lines []string
+	}{
+		{"Perfil", nonEmpty(p.Resumen)},
+		{"Última conversación", nonEmpty(m.LastSession)},
+		{"Pendiente de la vez anterior", p.Pendientes},
+		{"Productos que ha cotizado o pedido", p.Productos},
+		{"Materiales habituales", p.Materiales},
+		{"Preferencias", p.Preferencias},
+	}
+
+	var b strings.Builder
+	b.WriteString(header)
+	for _, s := range sections {
+		for i, line := range s.lines {
+			entry := "  " + line + "\n"
+			switch {
+			case len(s.lines) == 1:
+				entry = s.label + ": " + line + "\n"
+			case i == 0:
+				entry = s.label + ":\n" + entry
+			}
+			if b.Len()+len(entry) > budget {
+				break
+			}
+			b.WriteString(entry)
+		}
+	}
+	if b.Len() == len(header) {
+		return ""
+	}
+	return b.String()
+}
+
+func decodeList(j datatypes.JSON) []string {
+	var items []string
+	if len(j) > 0 {
+		_ = json.Unmarshal(j, &items)
+	}
+	return items
+}
+
+func encodeList(items []string) datatypes.JSON {
+	if items == nil {
+		items = []string{}
+	}
+	b, _ := json.Marshal(items)
+	return datatypes.JSON(b)
+}
+
+func clampList(items []string) []string {
+	out := make([]string, 0, len(items))
+	seen := map[string]bool{}
+	for _, it := range items {
+		it = truncate(it, maxItemChars)
+		if it == "" || seen[strings.ToLower(it)] {
+			continue
+		}
+		seen[strings.ToLower(it)] = true
+		out = append(out, it)
+	}
+	if len(out) > maxItems {
+		out = out[len(out)-maxItems:]
+	}
+	return out
+}
+
+// truncate recorta en runas (no parte tildes) y agrega "…" si cortó
+func truncate(s string, max int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	r := []rune(s)
+	if len(r) <= max {
+		return s
+	}
+	return strings.TrimSpace(string(r[:max-1])) + "…"
+}
+
+func nonEmpty(s string) []string {
+	if strings.TrimSpace(s) == "" {
+		return nil
+	}
+	return []string{s}
+}
@@ -0,0 +1,263 @@
+// Package memory es la memoria de largo plazo de los clientes del bot. El
+// historial de la sesión vive 4 horas en Redis; cuando la sesión termina (4
+// horas sin mensajes o cambio de día en Costa Rica) el job resume los turnos
+// archivados con SummarizeConversation y los funde en un perfil por contacto
+// (customer_memories). En el próximo contacto el motor de conversación suma
+// ese perfil a DATOS DEL CLIENTE, recortado a un tope de caracteres.
+//
+// El panel admin puede ver, corregir y borrar la memoria (pedidos de
+// privacidad); un contacto con opted_out no vuelve a guardar memoria.
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/channels"
+	"github.com/alonsoalpizar/fabricalaser/internal/llm"
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+	"github.com/alonsoalpizar/fabricalaser/internal/repository"
+	"github.com/alonsoalpizar/fabricalaser/internal/tenant"
+)
+
+const (
+	// sessionIdle coincide con el TTL del historial Redis del motor
+	sessionIdle = 4 * time.Hour
+
+	batchSize     = 20 // contactos por corrida del job
+	maxTurns      = 80 // turnos por sesión que se resumen
+	summarizeWait = 45 * time.Second
+)
+
+var zonaCR = time.FixedZone("America/Costa_Rica", -6*60*60)
+
+// ErrNotFound indica que el contacto no tiene memoria
+var ErrNotFound = errors.New("memory: el contacto no tiene memoria")
+
+// Summarizer resume una sesión (lo implementa el agente de mensajería)
+type Summarizer interface {
+	SummarizeConversation(ctx context.Context, history []channels.Turn) (string, error)
+}
+
+// Service consolida y entrega la memoria de los clientes
+type Service struct {
+	repo       *repository.MemoryRepository
+	summarizer Summarizer
+	llm        llm.Provider
+	budget     int
+	now        func() time.Time
+}
+
+// NewService crea el servicio. summarizer resume cada sesión y model funde el
+// resumen con la memoria anterior.
+func NewService(summarizer Summarizer, model llm.Provider) *Service {
+	return &Service{
+		repo:       repository.NewMemoryRepository(),
+		summarizer: summarizer,
+		llm:        model,
+		budget:     DefaultBudget,
+		now:        time.Now,
+	}
+}
+
+// Context retorna el bloque MEMORIA DEL CLIENTE del contacto ("" si no hay).
+// Implementa channels.Memory.
+func (s *Service) Context(ctx context.Context, from channels.Identity) string {
+	m, err := s.repo.WithContext(ctx).FindByKey(from.Key)
+	if err != nil {
+		slog.Warn("memory: no se pudo leer la memoria", "key", from.Key, "error", err)
+		return ""
+	}
+	return Render(m, s.budget)
+}
+
+// StartJob consolida las sesiones terminadas de cada tenant activo cada
+// interval hasta que ctx termine
+func (s *Service) StartJob(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				tenant.EachActive(ctx, func(ctx context.Context) {
+					done, err := s.Consolidate(ctx)
+					if err != nil {
+						slog.Error("memory: error consolidando sesiones", "tenant_id", tenant.ID(ctx), "error", err)
+						return
+					}
+					if done > 0 {
+						slog.Info("memory: sesiones consolidadas", "tenant_id", tenant.ID(ctx), "contactos", done)
+					}
+				})
+			}
+		}
+	}()
+}
+
+// Consolidate resume las sesiones terminadas pendientes del tenant de ctx y
+// retorna cuántos contactos actualizó. Un contacto que falla se reintenta en la
+// próxima corrida.
+func (s *Service) Consolidate(ctx context.Context) (int, error) {
+	now := s.now()
+	dayStart := startOfDay(now)
+	candidates, err := s.repo.WithContext(ctx).PendingCandidates(now.Add(-sessionIdle), dayStart, batchSize)
+	if err != nil {
+		return 0, fmt.Errorf("memory: buscando sesiones pendientes: %w", err)
+	}
+
+	done := 0
+	for _, c := range candidates {
+		if ctx.Err() != nil {
+			break
+		}
+		// Sesión en curso que empezó ayer: se resume solo lo de antes de medianoche
+		until := c.LastMessage
+		if !c.LastMessage.Before(now.Add(-sessionIdle)) {
+			until = dayStart
+		}
+		if err := s.consolidateOne(ctx, c.Phone, until); err != nil {
+			slog.Warn("memory: no se pudo consolidar", "key", c.Phone, "error", err)
+			continue
+		}
+		done++
+	}
+	return done, nil
+}
+
+func (s *Service) consolidateOne(ctx context.Context, key string, until time.Time) error {
+	repo := s.repo.WithContext(ctx)
+	m, err := repo.FindByKey(key)
+	if err != nil {
+		return err
+	}
+	if m == nil {
+		m = &models.CustomerMemory{ConversationKey: key, Channel: channels.KeyChannel(key)}
+	}
+	if m.OptedOut {
+		return nil
+	}
+
+	turns, err := repo.TurnsSince(key, m.SummarizedThrough, until, maxTurns)
+	if err != nil {
+		return err
+	}
+	if len(turns) == 0 {
+		return nil
+	}
+	history := make([]channels.Turn, len(turns))
+	for i, t := range turns {
+		history[i] = channels.Turn{Role: t.Role, Content: t.Content}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, summarizeWait)
+	defer cancel()
+
+	session, err := s.summarizer.SummarizeConversation(ctx, history)
+	if err != nil {
+		return err
+	}
+	if session != "" {
+		profile, err := s.merge(ctx, ProfileOf(m), session)
+		if err != nil {
+			return err
+		}
+		profile.Apply(m)
+		m.LastSession = truncate(session, maxSummaryChar)
+		m.Sessions++
+	}
+	// Aunque el resumen venga vacío (saludo suelto) los turnos quedan vistos
+	m.SummarizedThrough = turns[len(turns)-1].CreatedAt
+	return repo.Save(m)
+}
+
+// merge funde el resumen de la sesión con el perfil. Si el modelo no devuelve
+// JSON, el resumen se agrega al perfil tal cual para no perder la sesión.
+func (s *Service) merge(ctx context.Context, current Profile, session string) (Profile, error) {
+	text, err := llm.Summarize(ctx, s.llm, llm.Config{
+		Temperature:     llm.Float32(0.1),
+		MaxOutputTokens: llm.Int32(600),
+	}, mergePrompt(current, session))
+	if errors.Is(err, llm.ErrEmptyResponse) {
+		return mergeFallback(current, session), nil
+	}
+	if err != nil {
+		return Profile{}, err
+	}
+	profile, err := parseProfile(text)
+	if err != nil {
+		slog.Warn("memory: fusión sin JSON, se guarda el resumen", "error", err)
+		return mergeFallback(current, session), nil
+	}
+	return profile, nil
+}
+
+func mergeFallback(current Profile, session string) Profile {
+	if current.Resumen == "" {
+		current.Resumen = session
+	}
+	return current
+}
+
+// ─── Panel admin ─────────────────────────────────────────────────────────────
+
+// Get retorna la memoria de un contacto (nil si no tiene)
+func (s *Service) Get(key string) (*models.CustomerMemory, error) {
+	return s.repo.FindByKey(key)
+}
+
+// List retorna las memorias más recientes; q filtra por clave o contenido
+func (s *Service) List(q string, limit, offset int) ([]models.CustomerMemory, int64, error) {
+	return s.repo.List(q, limit, offset)
+}
+
+// Edit reemplaza el perfil de un contacto con la corrección de un admin
+func (s *Service) Edit(key string, p Profile, lastSession string, adminID uint) (*models.CustomerMemory, error) {
+	m, err := s.repo.FindByKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return nil, ErrNotFound
+	}
+	p.Apply(m)
+	m.LastSession = truncate(lastSession, maxSummaryChar)
+	s.markEdited(m, adminID)
+	return m, s.repo.Save(m)
+}
+
+// Forget borra el contenido de la memoria (pedido de privacidad). La fila queda
+// con summarized_through = ahora para que el job no la rearme con los turnos
+// viejos; optOut además deja de guardar memoria del contacto.
+func (s *Service) Forget(key string, optOut bool, adminID uint) (*models.CustomerMemory, error) {
+	m, err := s.repo.FindByKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		m = &models.CustomerMemory{ConversationKey: key, Channel: channels.KeyChannel(key)}
+	}
+	Profile{}.Apply(m)
+	m.LastSession = ""
+	m.Sessions = 0
+	m.SummarizedThrough = s.now()
+	m.OptedOut = m.OptedOut || optOut
+	s.markEdited(m, adminID)
+	return m, s.repo.Save(m)
+}
+
+func (s *Service) markEdited(m *models.CustomerMemory, adminID uint) {
+	now := s.now()
+	m.EditedBy = &adminID
+	m.EditedAt = &now
+}
+
+func startOfDay(t time.Time) time.Time {
+	local := t.In(zonaCR)
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, zonaCR)
+}
@@ -0,0 +1,326 @@
+// Package notifications avisa a los clientes sobre sus cotizaciones y pedidos.
+//
+// El Dispatcher escucha el bus de eventos de dominio, arma el aviso y lo envía
+// por el canal preferido del cliente (email, plantilla de WhatsApp o Telegram),
+// respetando su opt-out. Cada aviso queda en notification_deliveries; los que
+// fallan se reintentan con espera creciente desde un job periódico, que también
+// detecta las cotizaciones por vencer.
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/events"
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+	"github.com/alonsoalpizar/fabricalaser/internal/repository"
+	"github.com/alonsoalpizar/fabricalaser/internal/telegram"
+	"github.com/alonsoalpizar/fabricalaser/internal/tenant"
+	"github.com/alonsoalpizar/fabricalaser/internal/whatsapp"
+)
+
+var ErrNotRetryable = errors.New("solo se pueden reintentar avisos fallidos o pendientes")
+
+const (
+	maxAttempts          = 5
+	defaultExpiringHours = 48
+	dueBatch             = 50
+)
+
+// retryDelays es la espera antes de cada reintento (después del intento 1, 2, ...)
+var retryDelays = []time.Duration{time.Minute, 5 * time.Minute, 30 * time.Minute, 2 * time.Hour}
+
+type Dispatcher struct {
+	repo      *repository.NotificationRepository
+	userRepo  *repository.UserRepository
+	quoteRepo *repository.QuoteRepository
+	sysConfig *repository.SystemConfigRepository
+	senders   map[string]Sender
+	now       func() time.Time
+}
+
+// NewDispatcher conecta los tres canales con sus clientes por defecto
+func NewDispatcher() *Dispatcher {
+	return &Dispatcher{
+		repo:      repository.NewNotificationRepository(),
+		userRepo:  repository.NewUserRepository(),
+		quoteRepo: repository.NewQuoteRepository(),
+		sysConfig: repository.NewSystemConfigRepository(),
+		senders: map[string]Sender{
+			models.NotifChannelEmail:    emailSender{},
+			models.NotifChannelWhatsApp: whatsappSender{sender: whatsapp.NewSender()},
+			models.NotifChannelTelegram: telegramSender{sender: telegram.NewSender()},
+		},
+		now: time.Now,
+	}
+}
+
+// Start se suscribe a los eventos de cotizaciones y pedidos y arranca el job de
+// reintentos y avisos de vencimiento, que corre cada interval hasta que ctx
+// termine. Los avisos de vencimiento se buscan en cada tenant activo.
+func (d *Dispatcher) Start(ctx context.Context, bus *events.Bus, interval time.Duration) {
+	bus.Subscribe(d.Handle,
+		events.QuoteCreated,
+		events.QuoteApproved,
+		events.QuoteRejected,
+		events.QuoteExpiring,
+		events.OrderStatusChanged,
+	)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				tenant.EachActive(ctx, func(ctx context.Context) {
+					d.publishExpiring(ctx, bus)
+				})
+				if sent, failed := d.RetryDue(ctx); sent > 0 || failed > 0 {
+					slog.Info("notifications: reintentos", "enviados", sent, "fallidos", failed)
+				}
+			}
+		}
+	}()
+}
+
+// Handle arma y envía el aviso de un evento
+func (d *Dispatcher) Handle(ctx context.Context, ev events.Event) {
+	if err := d.handle(ctx, ev); err != nil {
+		slog.Error("notifications: error procesando evento",
+			"tipo", ev.Type,
+			"user_id", ev.UserID,
+			"quote_id", ev.QuoteID,
+			"error", err,
+		)
+	}
+}
+
+func (d *Dispatcher) handle(ctx context.Context, ev events.Event) error {
+	repo := d.repo.WithContext(ctx)
+	user, err := d.userRepo.WithContext(ctx).FindByID(ev.UserID)
+	if err != nil {
+		return fmt.Errorf("cliente no encontrado: %w", err)
+	}
+	var quote *models.Quote
+	if ev.QuoteID > 0 {
+		if quote, err = d.quoteRepo.WithContext(ctx).FindByID(ev.QuoteID); err != nil {
+			return fmt.Errorf("cotización no encontrada: %w", err)
+		}
+	}
+
+	msg, ok := render(ev, user, quote)
+	if !ok {
+		return nil
+	}
+	params, _ := json.Marshal(msg.params)
+
+	channel, recipient := route(user)
+	now := d.now()
+	delivery := &models.NotificationDelivery{
+		EventType:     ev.Type,
+		UserID:        user.ID,
+		Channel:       channel,
+		Recipient:     recipient,
+		Subject:       msg.subject,
+		Body:          msg.body,
+		Params:        params,
+		Status:        models.NotificationPending,
+		NextAttemptAt: &now,
+	}
+	if quote != nil {
+		delivery.QuoteID = &quote.ID
+	}
+	if msg.link != "" {
+		delivery.Link = &msg.link
+	}
+	if channel == models.NotifChannelWhatsApp {
+		delivery.Template = &msg.template
+	}
+
+	switch {
+	case user.NotifOptOut:
+		// Queda en la bitácora para que el admin sepa por qué el cliente no se enteró
+		delivery.Status = models.NotificationSkipped
+		delivery.NextAttemptAt = nil
+		return repo.Create(delivery)
+	case recipient == "":
+		delivery.Status = models.NotificationFailed
+		delivery.NextAttemptAt = nil
+		reason := "el cliente no tiene email ni otro canal configurado"
+		delivery.LastError = &reason
+		return repo.Create(delivery)
+	}
+
+	if err := repo.Create(delivery); err != nil {
+		return fmt.Errorf("error registrando aviso: %w", err)
+	}
+	d.attempt(ctx, delivery, user.Nombre)
+	return nil
+}
+
+// Retry reenvía un aviso fallido o pendiente desde el panel
+func (d *Dispatcher) Retry(ctx context.Context, id uint) (*models.NotificationDelivery, error) {
+	delivery, err := d.repo.WithContext(ctx).FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if delivery.Status != models.NotificationFailed && delivery.Status != models.NotificationPending {
+		return nil, ErrNotRetryable
+	}
+	user, err := d.userRepo.WithContext(ctx).FindByID(delivery.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("cliente no encontrado: %w", err)
+	}
+	// Un reintento manual da una nueva ronda de intentos automáticos
+	delivery.Attempts = 0
+	delivery.Status = models.NotificationPending
+	d.attempt(ctx, delivery, user.Nombre)
+	return delivery, nil
+}
+
+// RetryDue reintenta los avisos pendientes de todos los tenants cuyo turno ya
+// llegó; cada uno se envía con el tenant de su fila.
+func (d *Dispatcher) RetryDue(ctx context.Context) (sent, failed int) {
+	due, err := d.repo.FindDue(d.now(), dueBatch)
+	if err != nil {
+		slog.Error("notifications: error buscando avisos pendientes", "error", err)
+		return 0, 0
+	}
+	for i := range due {
+		tctx := tenant.WithID(ctx, due[i].TenantID)
+		nombre := ""
+		if user, err := d.userRepo.WithContext(tctx).FindByID(due[i].UserID); err == nil {
+			nombre = user.Nombre
+		}
+		d.attempt(tctx, &due[i], nombre)
+		switch due[i].Status {
+		case models.NotificationSent:
+			sent++
+		case models.NotificationFailed:
+			failed++
+		}
+	}
+	return sent, failed
+}
+
+// attempt envía y deja el resultado en la bitácora: sent, pending con el próximo
+// intento programado, o failed al agotar los intentos.
+func (d *Dispatcher) attempt(ctx context.Context, delivery *models.NotificationDelivery, nombre string) {
+	sender, ok := d.senders[delivery.Channel]
+	var err error
+	if !ok {
+		err = fmt.Errorf("canal %q no disponible", delivery.Channel)
+	} else {
+		err = sender.Send(ctx, delivery, nombre)
+	}
+
+	now := d.now()
+	delivery.Attempts++
+	if err == nil {
+		delivery.Status = models.NotificationSent
+		delivery.SentAt = &now
+		delivery.NextAttemptAt = nil
+		delivery.LastError = nil
+	} else {
+		msg := err.Error()
+		delivery.LastError = &msg
+		if next, retry := nextAttempt(delivery.Attempts, now); retry {
+			delivery.NextAttemptAt = &next
+		} else {
+			delivery.Status = models.NotificationFailed
+			delivery.NextAttemptAt = nil
+		}
+		slog.Warn("notifications: error enviando aviso",
+			"id", delivery.ID,
+			"canal", delivery.Channel,
+			"intento", delivery.Attempts,
+			"error", err,
+		)
+	}
+
+	if err := d.repo.WithContext(ctx).Update(delivery); err != nil {
+		slog.Error("notifications: error actualizando bitácora", "id", delivery.ID, "error", err)
+	}
+}
+
+// publishExpiring emite quote.expiring para las cotizaciones aprobadas que vencen
+// dentro de notif_vencimiento_horas y todavía no fueron avisadas, en el tenant de ctx
+func (d *Dispatcher) publishExpiring(ctx context.Context, bus *events.Bus) {
+	now := d.now()
+	repo := d.repo.WithContext(ctx)
+	quotes, err := d.quoteRepo.WithContext(ctx).FindExpiring(now, now.Add(time.Duration(d.expiringHours(ctx))*time.Hour))
+	if err != nil {
+		slog.Error("notifications: error buscando cotizaciones por vencer", "error", err)
+		return
+	}
+	for _, q := range quotes {
+		if done, err := repo.ExistsForQuote(events.QuoteExpiring, q.ID); err != nil || done {
+			continue
+		}
+		bus.Publish(events.Event{Type: events.QuoteExpiring, TenantID: q.TenantID, UserID: q.UserID, QuoteID: q.ID})
+	}
+}
+
+func (d *Dispatcher) expiringHours(ctx context.Context) int {
+	if cfg, err := d.sysConfig.WithContext(ctx).FindByKey("notif_vencimiento_horas"); err == nil {
+		if n, err := strconv.Atoi(strings.TrimSpace(cfg.ConfigValue)); err == nil && n > 0 {
+			return n
+		}
+	}
+	return defaultExpiringHours
+}
+
+// nextAttempt programa el reintento según cuántos intentos van; false = no reintentar más
+func nextAttempt(attempts int, now time.Time) (time.Time, bool) {
+	if attempts >= maxAttempts {
+		return time.Time{}, false
+	}
+	delay := retryDelays[len(retryDelays)-1]
+	if attempts-1 < len(retryDelays) {
+		delay = retryDelays[attempts-1]
+	}
+	return now.Add(delay), true
+}
+
+// route elige canal y destinatario: el preferido si tiene destinatario, si no email
+func route(user *models.User) (channel, recipient string) {
+	switch user.NotifChannel {
+	case models.NotifChannelWhatsApp:
+		if user.Telefono != nil {
+			if phone := whatsappNumber(*user.Telefono); phone != "" {
+				return models.NotifChannelWhatsApp, phone
+			}
+		}
+	case models.NotifChannelTelegram:
+		if user.TelegramChatID != nil && *user.TelegramChatID != 0 {
+			return models.NotifChannelTelegram, strconv.FormatInt(*user.TelegramChatID, 10)
+		}
+	}
+	return models.NotifChannelEmail, strings.TrimSpace(user.Email)
+}
+
+// whatsappNumber deja solo dígitos y antepone 506 a números locales de 8 dígitos
+func whatsappNumber(phone string) string {
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, phone)
+	if len(digits) == 8 {
+		digits = "506" + digits
+	}
+	if len(digits) < 10 {
+		return ""
+	}
+	return digits
+}
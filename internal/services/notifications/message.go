@@ -0,0 +1,134 @@
+package notifications
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/events"
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+)
+
+// quoteURL abre la cotización en el cotizador web
+const quoteURL = "https://fabricalaser.com/cotizar/?cotizacion=%d"
+
+// zonaCR: las fechas se muestran en hora de Costa Rica (UTC-6, sin horario de verano)
+var zonaCR = time.FixedZone("America/Costa_Rica", -6*60*60)
+
+// message es el aviso ya armado, independiente del canal. Email y Telegram usan
+// subject + body; WhatsApp usa la plantilla aprobada con params.
+type message struct {
+	subject  string
+	body     string
+	link     string
+	template string
+	params   []string // {{1}} nombre, {{2}} número de cotización, {{3}} detalle
+}
+
+// orderStatusLabels traduce el estado de la cotización convertida en pedido
+var orderStatusLabels = map[string]string{
+	string(models.QuoteStatusConverted): "confirmado y en cola de producción",
+	string(models.QuoteStatusApproved):  "devuelto a cotización aprobada",
+	string(models.QuoteStatusRejected):  "cancelado",
+	string(models.QuoteStatusExpired):   "vencido",
+}
+
+// render arma el aviso para el evento. ok = false si el evento no se notifica.
+func render(ev events.Event, user *models.User, quote *models.Quote) (msg message, ok bool) {
+	if quote == nil {
+		return message{}, false
+	}
+	number := fmt.Sprintf("#%d", quote.ID)
+	msg.link = fmt.Sprintf(quoteURL, quote.ID)
+
+	switch ev.Type {
+	case events.QuoteCreated:
+		total := formatAmount(quote.ToDisplayCurrency(quote.PriceTotalWithTax), quote.Currency)
+		msg.subject = "Cotización " + number + " recibida"
+		if quote.NeedsReview() {
+			msg.body = fmt.Sprintf("Recibimos tu cotización %s. Por la complejidad del diseño, un técnico la va a revisar y te avisamos apenas esté aprobada.\nMonto de referencia: %s (IVA incluido).", number, total)
+		} else {
+			msg.body = fmt.Sprintf("Tu cotización %s está lista por %s (IVA incluido).\nEs válida hasta el %s.", number, total, formatDate(quote.ValidUntil))
+		}
+		msg.template, msg.params = "cotizacion_creada", []string{total}
+
+	case events.QuoteApproved:
+		total := formatAmount(quote.ToDisplayCurrency(quote.PriceTotalWithTax), quote.Currency)
+		msg.subject = "Cotización " + number + " aprobada"
+		msg.body = fmt.Sprintf("Revisamos tu cotización %s y está aprobada por %s (IVA incluido).\nEs válida hasta el %s. Escribinos para coordinar el pedido.", number, total, formatDate(quote.ValidUntil))
+		msg.template, msg.params = "cotizacion_aprobada", []string{total}
+
+	case events.QuoteRejected:
+		msg.subject = "Cotización " + number + " no aprobada"
+		msg.body = fmt.Sprintf("Revisamos tu cotización %s y no la podemos producir como está.", number)
+		detail := "Escribinos y te ayudamos a ajustar el diseño."
+		if ev.Note != "" {
+			detail = ev.Note
+		}
+		msg.body += "\n" + detail
+		msg.template, msg.params = "cotizacion_rechazada", []string{detail}
+
+	case events.QuoteExpiring:
+		msg.subject = "Cotización " + number + " por vencer"
+		msg.body = fmt.Sprintf("Tu cotización %s vence el %s. Si querés hacer el pedido, escribinos antes de esa fecha para mantener el precio.", number, formatDate(quote.ValidUntil))
+		msg.template, msg.params = "cotizacion_por_vencer", []string{formatDate(quote.ValidUntil)}
+
+	case events.OrderStatusChanged:
+		label, known := orderStatusLabels[ev.Status]
+		if !known {
+			return message{}, false
+		}
+		msg.subject = "Pedido " + number + " actualizado"
+		msg.body = fmt.Sprintf("Tu pedido %s fue %s.", number, label)
+		if ev.Note != "" {
+			msg.body += "\n" + ev.Note
+		}
+		msg.template, msg.params = "pedido_actualizado", []string{label}
+
+	default:
+		return message{}, false
+	}
+
+	msg.params = append([]string{user.Nombre, number}, msg.params...)
+	return msg, true
+}
+
+// text es la versión de texto plano (Telegram)
+func (m message) text() string {
+	var b strings.Builder
+	b.WriteString(m.subject + "\n\n" + m.body)
+	if m.link != "" {
+		b.WriteString("\n\n" + m.link)
+	}
+	return b.String()
+}
+
+// formatAmount: ₡25 000 o $1 234.50
+func formatAmount(v float64, currency string) string {
+	if currency == models.CurrencyUSD {
+		whole := math.Floor(v)
+		cents := math.Round((v - whole) * 100)
+		if cents >= 100 {
+			whole, cents = whole+1, 0
+		}
+		return fmt.Sprintf("$%s.%02.0f", groupThousands(whole), cents)
+	}
+	return "₡" + groupThousands(math.Round(v))
+}
+
+func groupThousands(v float64) string {
+	s := fmt.Sprintf("%.0f", v)
+	var out []byte
+	for i := range s {
+		if i > 0 && (len(s)-i)%3 == 0 {
+			out = append(out, ' ')
+		}
+		out = append(out, s[i])
+	}
+	return string(out)
+}
+
+func formatDate(t time.Time) string {
+	return t.In(zonaCR).Format("02/01/2006")
+}
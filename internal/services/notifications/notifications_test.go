@@ -0,0 +1,90 @@
+package notifications
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/events"
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+)
+
+func testQuote() *models.Quote {
+	return &models.Quote{
+		ID:                42,
+		Status:            models.QuoteStatusApproved,
+		Currency:          models.CurrencyCRC,
+		BaseCurrency:      models.CurrencyCRC,
+		PriceTotalWithTax: 25000,
+		ValidUntil:        time.Date(2026, 3, 10, 18, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestRender(t *testing.T) {
+	user := &models.User{Nombre: "Ana"}
+
+	msg, ok := render(events.Event{Type: events.QuoteApproved}, user, testQuote())
+	if !ok {
+		t.Fatal("quote.approved debería notificarse")
+	}
+	if msg.template != "cotizacion_aprobada" {
+		t.Errorf("template = %q", msg.template)
+	}
+	want := []string{"Ana", "#42", "₡25 000"}
+	if strings.Join(msg.params, "|") != strings.Join(want, "|") {
+		t.Errorf("params = %v, want %v", msg.params, want)
+	}
+	if !strings.Contains(msg.body, "10/03/2026") {
+		t.Errorf("body sin fecha de vencimiento: %q", msg.body)
+	}
+
+	msg, ok = render(events.Event{Type: events.QuoteRejected, Note: "El grosor supera 6mm"}, user, testQuote())
+	if !ok || !strings.Contains(msg.body, "El grosor supera 6mm") || msg.params[2] != "El grosor supera 6mm" {
+		t.Errorf("rechazo sin nota del revisor: %+v", msg)
+	}
+
+	if _, ok := render(events.Event{Type: events.OrderStatusChanged, Status: "needs_review"}, user, testQuote()); ok {
+		t.Error("un estado de pedido sin etiqueta no debería notificarse")
+	}
+	if _, ok := render(events.Event{Type: events.QuoteCreated}, user, nil); ok {
+		t.Error("sin cotización no hay aviso")
+	}
+}
+
+func TestRoute(t *testing.T) {
+	phone := "8888-7777"
+	chat := int64(123456)
+	tests := []struct {
+		name          string
+		user          models.User
+		wantChannel   string
+		wantRecipient string
+	}{
+		{"email", models.User{NotifChannel: "email", Email: "ana@example.com"}, "email", "ana@example.com"},
+		{"whatsapp local", models.User{NotifChannel: "whatsapp", Telefono: &phone}, "whatsapp", "50688887777"},
+		{"whatsapp sin teléfono", models.User{NotifChannel: "whatsapp", Email: "ana@example.com"}, "email", "ana@example.com"},
+		{"telegram", models.User{NotifChannel: "telegram", TelegramChatID: &chat}, "telegram", "123456"},
+		{"telegram sin chat", models.User{NotifChannel: "telegram", Email: "ana@example.com"}, "email", "ana@example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			channel, recipient := route(&tt.user)
+			if channel != tt.wantChannel || recipient != tt.wantRecipient {
+				t.Errorf("route = (%q, %q), want (%q, %q)", channel, recipient, tt.wantChannel, tt.wantRecipient)
+			}
+		})
+	}
+}
+
+func TestNextAttempt(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	for attempts, want := range map[int]time.Duration{1: time.Minute, 2: 5 * time.Minute, 4: 2 * time.Hour} {
+		next, retry := nextAttempt(attempts, now)
+		if !retry || next.Sub(now) != want {
+			t.Errorf("intento %d: next = %v (retry %v), want +%v", attempts, next, retry, want)
+		}
+	}
+	if _, retry := nextAttempt(maxAttempts, now); retry {
+		t.Error("no debería reintentar al agotar los intentos")
+	}
+}
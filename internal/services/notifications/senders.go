@@ -0,0 +1,63 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+	"github.com/alonsoalpizar/fabricalaser/internal/services/email"
+	"github.com/alonsoalpizar/fabricalaser/internal/telegram"
+	"github.com/alonsoalpizar/fabricalaser/internal/whatsapp"
+)
+
+// Sender entrega un aviso ya registrado por un canal. Recibe la fila de la
+// bitácora: todo lo necesario para enviar (y reintentar) está ahí.
+type Sender interface {
+	Send(ctx context.Context, d *models.NotificationDelivery, nombre string) error
+}
+
+type emailSender struct{}
+
+func (emailSender) Send(_ context.Context, d *models.NotificationDelivery, nombre string) error {
+	link := ""
+	if d.Link != nil {
+		link = *d.Link
+	}
+	return email.SendNotification(d.Recipient, nombre, d.Subject, d.Body, link)
+}
+
+// whatsappSender usa plantillas: los avisos casi siempre caen fuera de la ventana de 24h
+type whatsappSender struct {
+	sender *whatsapp.Sender
+}
+
+func (s whatsappSender) Send(ctx context.Context, d *models.NotificationDelivery, _ string) error {
+	if d.Template == nil {
+		return fmt.Errorf("notifications: aviso %d sin plantilla de WhatsApp", d.ID)
+	}
+	var params []string
+	if len(d.Params) > 0 {
+		if err := json.Unmarshal(d.Params, &params); err != nil {
+			return fmt.Errorf("notifications: parámetros inválidos: %w", err)
+		}
+	}
+	return s.sender.SendTemplate(ctx, d.Recipient, *d.Template, "es", params)
+}
+
+type telegramSender struct {
+	sender *telegram.Sender
+}
+
+func (s telegramSender) Send(ctx context.Context, d *models.NotificationDelivery, _ string) error {
+	chatID, err := strconv.ParseInt(d.Recipient, 10, 64)
+	if err != nil {
+		return fmt.Errorf("notifications: chat de Telegram inválido %q", d.Recipient)
+	}
+	msg := message{subject: d.Subject, body: d.Body}
+	if d.Link != nil {
+		msg.link = *d.Link
+	}
+	return s.sender.SendText(ctx, chatID, msg.text())
+}
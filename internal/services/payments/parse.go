@@ -0,0 +1,438 @@
+package payments
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+)
+
+// ErrEmptyImport indica que el archivo o texto no trae movimientos
+var ErrEmptyImport = errors.New("no se encontraron movimientos")
+
+// RowError describe una fila o mensaje que no se pudo interpretar
+type RowError struct {
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+// ParseResult agrupa los movimientos válidos y los errores por línea
+type ParseResult struct {
+	Transactions []models.PaymentTransaction `json:"transactions"`
+	Skipped      int                         `json:"skipped"` // Débitos u otras filas que no son ingresos
+	Errors       []RowError                  `json:"errors,omitempty"`
+}
+
+// Formatos de fecha de los estados de cuenta de BN, BCR, BAC y Popular
+var txDateLayouts = []string{
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"02/01/2006 15:04:05",
+	"02/01/2006 15:04",
+	"02/01/2006",
+	"2/1/2006 15:04",
+	"2/1/2006",
+	"02/01/06",
+	"02-01-2006",
+}
+
+// ParseStatementCSV lee un estado de cuenta bancario exportado a CSV.
+//
+// Columnas reconocidas por encabezado: fecha, descripción/detalle/concepto, monto/importe
+// o crédito/débito, referencia/documento/comprobante, teléfono y nombre/origen.
+// Solo los créditos (ingresos) se importan; los débitos se cuentan como omitidos.
+func ParseStatementCSV(r io.Reader) (*ParseResult, error) {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("payments: error leyendo archivo: %w", err)
+	}
+	raw = bytes.TrimPrefix(raw, []byte("\xef\xbb\xbf")) // BOM de Excel
+
+	reader := csv.NewReader(bytes.NewReader(raw))
+	reader.Comma = detectDelimiter(raw)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.LazyQuotes = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("payments: CSV inválido: %w", err)
+	}
+
+	// Los bancos ponen líneas de título antes del encabezado: buscarlo en las primeras filas
+	cols, headerRow := statementColumns{}, -1
+	for i := 0; i < len(records) && i < 15; i++ {
+		if c, ok := headerStatementColumns(records[i]); ok {
+			cols, headerRow = c, i
+			break
+		}
+	}
+	if headerRow < 0 {
+		return nil, errors.New("payments: no se encontró el encabezado (fecha, descripción, monto/crédito)")
+	}
+
+	result := &ParseResult{}
+	for i := headerRow + 1; i < len(records); i++ {
+		rec := records[i]
+		line := i + 1
+		if isBlankRecord(rec) {
+			continue
+		}
+
+		date, err := parseTxDate(field(rec, cols.date))
+		if err != nil {
+			result.Errors = append(result.Errors, RowError{Line: line, Message: err.Error()})
+			continue
+		}
+
+		amount, err := cols.amountOf(rec)
+		if err != nil {
+			result.Errors = append(result.Errors, RowError{Line: line, Message: err.Error()})
+			continue
+		}
+		if amount <= 0 {
+			result.Skipped++
+			continue
+		}
+
+		tx := models.PaymentTransaction{
+			Source:     models.PaymentSourceCSV,
+			OccurredAt: date,
+			Amount:     amount,
+			Currency:   models.CurrencyCRC,
+		}
+		description := field(rec, cols.description)
+		tx.Description = optional(description)
+		tx.BankRef = optional(field(rec, cols.reference))
+		tx.PayerName = optional(field(rec, cols.name))
+		if phone := NormalizePhone(field(rec, cols.phone)); phone != "" {
+			tx.PayerPhone = &phone
+		} else if phone := findPhone(description); phone != "" {
+			tx.PayerPhone = &phone
+		}
+		rawLine := strings.Join(rec, string(reader.Comma))
+		tx.Raw = &rawLine
+		tx.Fingerprint = Fingerprint(&tx)
+
+		result.Transactions = append(result.Transactions, tx)
+	}
+
+	if len(result.Transactions) == 0 && len(result.Errors) == 0 && result.Skipped == 0 {
+		return nil, ErrEmptyImport
+	}
+	return result, nil
+}
+
+type statementColumns struct {
+	date, description, amount, credit, debit, reference, phone, name int
+}
+
+func (c statementColumns) amountOf(rec []string) (float64, error) {
+	if c.credit >= 0 {
+		credit := field(rec, c.credit)
+		if credit == "" {
+			return 0, nil // fila de débito
+		}
+		return parseTxAmount(credit)
+	}
+	return parseTxAmount(field(rec, c.amount))
+}
+
+func headerStatementColumns(rec []string) (statementColumns, bool) {
+	c := statementColumns{-1, -1, -1, -1, -1, -1, -1, -1}
+	for i, h := range rec {
+		switch normalizeHeader(h) {
+		case "fecha", "date", "fecha movimiento", "fecha contable", "fecha transaccion":
+			if c.date < 0 {
+				c.date = i
+			}
+		case "descripcion", "detalle", "concepto", "description", "motivo":
+			c.description = i
+		case "monto", "importe", "amount", "valor":
+			c.amount = i
+		case "credito", "creditos", "credit", "abono", "abonos", "deposito":
+			c.credit = i
+		case "debito", "debitos", "debit", "cargo", "cargos":
+			c.debit = i
+		case "referencia", "documento", "comprobante", "reference", "numero referencia", "no. referencia":
+			c.reference = i
+		case "telefono", "phone", "celular", "telefono origen":
+			c.phone = i
+		case "nombre", "origen", "ordenante", "nombre origen", "cliente":
+			c.name = i
+		}
+	}
+	ok := c.date >= 0 && (c.amount >= 0 || c.credit >= 0)
+	return c, ok
+}
+
+var headerAccents = strings.NewReplacer("á", "a", "é", "e", "í", "i", "ó", "o", "ú", "u", "ñ", "n")
+
+func normalizeHeader(h string) string {
+	return headerAccents.Replace(strings.ToLower(strings.TrimSpace(h)))
+}
+
+// ── SINPE Móvil (SMS / notificaciones) ──────────────────────────────────────
+
+var (
+	sinpeAmountRe = regexp.MustCompile(`(?i)(?:₡|¢|CRC|colones)\s*([\d][\d.,]*\d)|([\d][\d.,]*\d)\s*(?:colones|CRC)`)
+	sinpeDescRe   = regexp.MustCompile(`(?i)(?:descripci[oó]n|motivo|detalle|concepto)\s*:?\s*([^\n]+?)(?:\.\s|\.$|\s+ref|\s+comprobante|$)`)
+	sinpeRefRe    = regexp.MustCompile(`(?i)(?:ref(?:erencia)?|comprobante|documento|autorizaci[oó]n)\.?\s*(?:#|no\.?|n[uú]mero)?\s*:?\s*(\d{6,})`)
+	sinpeNameRe   = regexp.MustCompile(`\b(?:de|DE)\s+(?:\d{4}-?\d{4}\s+)?([A-ZÁÉÍÓÚÑ][A-ZÁÉÍÓÚÑ]+(?:\s+[A-ZÁÉÍÓÚÑ][A-ZÁÉÍÓÚÑ]+)+)`)
+	sinpeDateRe   = regexp.MustCompile(`(\d{1,2}/\d{1,2}/\d{2,4})(?:\s+(\d{1,2}:\d{2}(?::\d{2})?))?`)
+	phoneRe       = regexp.MustCompile(`(?:^|[^\d])(?:\+?506[\s-]?)?([5-8]\d{3})[\s-]?(\d{4})(?:[^\d]|$)`)
+	blankLineRe   = regexp.MustCompile(`\n\s*\n`)
+)
+
+// ParseSINPEText extrae movimientos de uno o varios SMS/notificaciones de SINPE Móvil
+// recibidos (BN, BCR, BAC, Popular). Los mensajes se separan por línea en blanco
+// o uno por línea. now se usa cuando el mensaje no trae fecha.
+func ParseSINPEText(text string, now time.Time) (*ParseResult, error) {
+	text = strings.ReplaceAll(strings.TrimSpace(text), "\r\n", "\n")
+	if text == "" {
+		return nil, ErrEmptyImport
+	}
+
+	var messages []string
+	for _, block := range blankLineRe.Split(text, -1) {
+		lines := strings.Split(block, "\n")
+		// Varias notificaciones pegadas una por línea
+		if len(lines) > 1 && countSINPELines(lines) == len(lines) {
+			messages = append(messages, lines...)
+			continue
+		}
+		messages = append(messages, strings.Join(lines, " "))
+	}
+
+	result := &ParseResult{}
+	for i, msg := range messages {
+		msg = strings.TrimSpace(msg)
+		if msg == "" {
+			continue
+		}
+		tx, err := parseSINPEMessage(msg, now)
+		if err != nil {
+			result.Errors = append(result.Errors, RowError{Line: i + 1, Message: err.Error()})
+			continue
+		}
+		if tx == nil {
+			result.Skipped++
+			continue
+		}
+		result.Transactions = append(result.Transactions, *tx)
+	}
+
+	if len(result.Transactions) == 0 && len(result.Errors) == 0 && result.Skipped == 0 {
+		return nil, ErrEmptyImport
+	}
+	return result, nil
+}
+
+// parseSINPEMessage returns nil, nil for messages that are not incoming payments
+// (SINPE enviado, códigos de verificación, etc.)
+func parseSINPEMessage(msg string, now time.Time) (*models.PaymentTransaction, error) {
+	lower := strings.ToLower(msg)
+	if !strings.Contains(lower, "recib") && !strings.Contains(lower, "acredit") {
+		return nil, nil
+	}
+
+	m := sinpeAmountRe.FindStringSubmatch(msg)
+	if m == nil {
+		return nil, errors.New("no se encontró el monto")
+	}
+	rawAmount := m[1]
+	if rawAmount == "" {
+		rawAmount = m[2]
+	}
+	amount, err := parseTxAmount(rawAmount)
+	if err != nil || amount <= 0 {
+		return nil, fmt.Errorf("monto inválido: %q", rawAmount)
+	}
+
+	tx := &models.PaymentTransaction{
+		Source:     models.PaymentSourceSINPESMS,
+		OccurredAt: now,
+		Amount:     amount,
+		Currency:   models.CurrencyCRC,
+		Raw:        &msg,
+	}
+	if d := sinpeDateRe.FindStringSubmatch(msg); d != nil {
+		if t, err := parseTxDate(strings.TrimSpace(d[1] + " " + d[2])); err == nil {
+			tx.OccurredAt = t
+		}
+	}
+	if d := sinpeDescRe.FindStringSubmatch(msg); d != nil {
+		tx.Description = optional(strings.TrimSpace(d[1]))
+	}
+	// La referencia se quita antes de buscar el teléfono: puede tener 8 dígitos
+	phoneSource := msg
+	if ref := sinpeRefRe.FindStringSubmatch(msg); ref != nil {
+		tx.BankRef = &ref[1]
+		phoneSource = strings.Replace(msg, ref[0], "", 1)
+	}
+	if name := sinpeNameRe.FindStringSubmatch(msg); name != nil {
+		tx.PayerName = optional(strings.TrimSpace(name[1]))
+	}
+	if phone := findPhone(phoneSource); phone != "" {
+		tx.PayerPhone = &phone
+	}
+	tx.Fingerprint = Fingerprint(tx)
+	return tx, nil
+}
+
+func countSINPELines(lines []string) int {
+	n := 0
+	for _, l := range lines {
+		lower := strings.ToLower(l)
+		if strings.Contains(lower, "sinpe") && sinpeAmountRe.MatchString(l) {
+			n++
+		}
+	}
+	return n
+}
+
+// ── Helpers ─────────────────────────────────────────────────────────────────
+
+// Fingerprint identifies a movement regardless of how it was imported.
+// Con referencia bancaria basta referencia + monto; sin ella se usa
+// fecha + monto + teléfono + descripción.
+func Fingerprint(tx *models.PaymentTransaction) string {
+	amount := strconv.FormatFloat(tx.Amount, 'f', 2, 64)
+	var key string
+	if tx.BankRef != nil && *tx.BankRef != "" {
+		key = "ref|" + *tx.BankRef + "|" + amount
+	} else {
+		key = strings.Join([]string{
+			"tx", tx.OccurredAt.Format("2006-01-02"), amount, deref(tx.PayerPhone),
+			strings.ToUpper(strings.TrimSpace(deref(tx.Description))),
+		}, "|")
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// NormalizePhone returns the 8-digit Costa Rican number, or "" if s is not one
+func NormalizePhone(s string) string {
+	digits := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] >= '0' && s[i] <= '9' {
+			digits = append(digits, s[i])
+		}
+	}
+	if len(digits) == 11 && strings.HasPrefix(string(digits), "506") {
+		digits = digits[3:]
+	}
+	if len(digits) != 8 {
+		return ""
+	}
+	return string(digits)
+}
+
+func findPhone(s string) string {
+	if m := phoneRe.FindStringSubmatch(s); m != nil {
+		return m[1] + m[2]
+	}
+	return ""
+}
+
+func parseTxDate(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range txDateLayouts {
+		if t, err := time.ParseInLocation(layout, s, zonaCR); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("fecha inválida: %q", s)
+}
+
+// parseTxAmount acepta "25000", "25,000.00", "25.000,00", "₡25 000" y "-1.500,00".
+// Un único separador seguido de exactamente 3 dígitos se toma como separador de miles
+// (los montos en colones rara vez traen 3 decimales).
+func parseTxAmount(s string) (float64, error) {
+	s = strings.NewReplacer("₡", "", "¢", "", "CRC", "", " ", "", "\u00a0", "").Replace(strings.TrimSpace(s))
+	if s == "" {
+		return 0, errors.New("monto vacío")
+	}
+
+	lastComma, lastDot := strings.LastIndex(s, ","), strings.LastIndex(s, ".")
+	switch {
+	case lastComma >= 0 && lastDot >= 0:
+		if lastComma > lastDot { // 25.000,00
+			s = strings.ReplaceAll(s, ".", "")
+			s = strings.Replace(s, ",", ".", 1)
+		} else { // 25,000.00
+			s = strings.ReplaceAll(s, ",", "")
+		}
+	case lastComma >= 0:
+		if strings.Count(s, ",") > 1 || len(s)-lastComma-1 == 3 {
+			s = strings.ReplaceAll(s, ",", "")
+		} else {
+			s = strings.Replace(s, ",", ".", 1)
+		}
+	case lastDot >= 0:
+		if strings.Count(s, ".") > 1 || len(s)-lastDot-1 == 3 {
+			s = strings.ReplaceAll(s, ".", "")
+		}
+	}
+
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("monto inválido: %q", s)
+	}
+	return v, nil
+}
+
+func detectDelimiter(raw []byte) rune {
+	firstLines := raw
+	if len(firstLines) > 2048 {
+		firstLines = firstLines[:2048]
+	}
+	switch {
+	case bytes.Count(firstLines, []byte(";")) > bytes.Count(firstLines, []byte(",")):
+		return ';'
+	case bytes.Count(firstLines, []byte("\t")) > 0:
+		return '\t'
+	default:
+		return ','
+	}
+}
+
+func field(rec []string, col int) string {
+	if col < 0 || col >= len(rec) {
+		return ""
+	}
+	return strings.TrimSpace(rec[col])
+}
+
+func isBlankRecord(rec []string) bool {
+	for _, f := range rec {
+		if strings.TrimSpace(f) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func optional(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
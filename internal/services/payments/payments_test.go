@@ -0,0 +1,133 @@
+package payments
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+)
+
+func TestParseSINPEText(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, zonaCR)
+	text := `BN: Ha recibido un SINPE MOVIL de JUAN PEREZ MORA por 25,000.00 colones. Descripcion: fl ab23cd. Ref: 2026101800012345
+
+Usted ha recibido ₡12.500,00 de 8888-1234 ANA SOLIS por SINPE Movil el 17/10/2026 09:15. Comprobante 987654321
+
+BN: Usted envio un SINPE MOVIL por 5,000.00 colones a PEDRO`
+
+	result, err := ParseSINPEText(text, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(result.Transactions) != 2 || result.Skipped != 1 {
+		t.Fatalf("got %d transactions, %d skipped, errors %+v", len(result.Transactions), result.Skipped, result.Errors)
+	}
+
+	first := result.Transactions[0]
+	if first.Amount != 25000 || deref(first.BankRef) != "2026101800012345" || deref(first.PayerName) != "JUAN PEREZ MORA" {
+		t.Errorf("first: %+v", first)
+	}
+	if FindReference(deref(first.Description)) != "FL-AB23CD" {
+		t.Errorf("reference not found in %q", deref(first.Description))
+	}
+
+	second := result.Transactions[1]
+	if second.Amount != 12500 || deref(second.PayerPhone) != "88881234" || second.OccurredAt.Day() != 17 {
+		t.Errorf("second: amount=%v phone=%q date=%v", second.Amount, deref(second.PayerPhone), second.OccurredAt)
+	}
+	if first.Fingerprint == "" || first.Fingerprint == second.Fingerprint {
+		t.Error("fingerprints must be set and distinct")
+	}
+}
+
+func TestParseStatementCSV(t *testing.T) {
+	csv := "Banco Nacional - Estado de cuenta\n" +
+		"Fecha;Descripción;Débito;Crédito;Referencia\n" +
+		"18/10/2026;SINPE MOVIL 88881234 FL-XY45ZW;;40.000,00;5551\n" +
+		"18/10/2026;COMPRA SUPER;3.500,00;;5552\n" +
+		"fecha mala;X;;1,00;5553\n"
+
+	result, err := ParseStatementCSV(strings.NewReader(csv))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(result.Transactions) != 1 || result.Skipped != 1 || len(result.Errors) != 1 {
+		t.Fatalf("got %d transactions, %d skipped, %d errors", len(result.Transactions), result.Skipped, len(result.Errors))
+	}
+	tx := result.Transactions[0]
+	if tx.Amount != 40000 || deref(tx.PayerPhone) != "88881234" || deref(tx.BankRef) != "5551" {
+		t.Errorf("tx: %+v", tx)
+	}
+}
+
+func TestParseTxAmount(t *testing.T) {
+	tests := map[string]float64{
+		"25000":     25000,
+		"25,000.00": 25000,
+		"25.000,00": 25000,
+		"25.000":    25000,
+		"1,250,000": 1250000,
+		"₡ 1500,50": 1500.5,
+		"-3.500,00": -3500,
+	}
+	for in, want := range tests {
+		got, err := parseTxAmount(in)
+		if err != nil || got != want {
+			t.Errorf("parseTxAmount(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	phone := "88881234"
+	created := time.Date(2026, 10, 17, 10, 0, 0, 0, zonaCR)
+	pending := []models.PaymentIntent{
+		{ID: 1, Provider: models.PaymentProviderSINPE, Reference: "FL-AB23CD", Amount: 20000, CreatedAt: created},
+		{ID: 2, Provider: models.PaymentProviderSINPE, Reference: "FL-QWERTY", Amount: 15000, PayerPhone: &phone, CreatedAt: created},
+		{ID: 3, Provider: models.PaymentProviderSINPE, Reference: "FL-ZZZZ22", Amount: 9000, CreatedAt: created},
+		{ID: 4, Provider: models.PaymentProviderSINPE, Reference: "FL-ZZZZ33", Amount: 9000, CreatedAt: created},
+	}
+	at := time.Date(2026, 10, 18, 9, 0, 0, 0, zonaCR)
+	desc := "pago fl-ab23cd adelanto"
+	other := "70001111"
+
+	tests := []struct {
+		name   string
+		tx     models.PaymentTransaction
+		wantID uint
+		rule   string
+	}{
+		{"referencia con abono parcial", models.PaymentTransaction{Amount: 5000, Description: &desc, OccurredAt: at}, 1, RuleReference},
+		{"teléfono y monto", models.PaymentTransaction{Amount: 15000.4, PayerPhone: &phone, OccurredAt: at}, 2, RulePhoneAmount},
+		{"monto único", models.PaymentTransaction{Amount: 20000, PayerPhone: &other, OccurredAt: at}, 1, RuleUniqueAmount},
+		{"monto ambiguo", models.PaymentTransaction{Amount: 9000, OccurredAt: at}, 0, ""},
+		{"anterior al cobro", models.PaymentTransaction{Amount: 20000, OccurredAt: created.AddDate(0, 0, -2)}, 0, ""},
+	}
+	for _, tt := range tests {
+		intent, rule := Match(&tt.tx, pending)
+		var got uint
+		if intent != nil {
+			got = intent.ID
+		}
+		if got != tt.wantID || rule != tt.rule {
+			t.Errorf("%s: got intent %d rule %q, want %d %q", tt.name, got, rule, tt.wantID, tt.rule)
+		}
+	}
+}
+
+func TestCardWebhookSignature(t *testing.T) {
+	p := NewCardProvider(NewStubGateway(), "secreto")
+	body := []byte(`{"type":"payment.succeeded","reference":"FL-AB23CD","provider_ref":"ch_1","amount":1000}`)
+
+	header := map[string][]string{"X-Signature": {Sign(body, []byte("secreto"))}}
+	event, err := p.ParseWebhook(body, header)
+	if err != nil || event.Reference != "FL-AB23CD" || event.Amount != 1000 {
+		t.Fatalf("unexpected result: %+v, %v", event, err)
+	}
+
+	header["X-Signature"] = []string{Sign(body, []byte("otro"))}
+	if _, err := p.ParseWebhook(body, header); err != ErrInvalidSignature {
+		t.Errorf("expected ErrInvalidSignature, got %v", err)
+	}
+}
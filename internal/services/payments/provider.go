@@ -0,0 +1,189 @@
+package payments
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+)
+
+// ErrInvalidSignature indica que el webhook no viene firmado por la pasarela
+var ErrInvalidSignature = errors.New("firma del webhook inválida")
+
+// Provider is a payment method. Each intent is created against one provider.
+type Provider interface {
+	Name() string
+	// Prepare completes a new intent with what the customer needs to pay
+	// (checkout URL, referencia del proveedor). Se llama antes de guardarlo.
+	Prepare(ctx context.Context, intent *models.PaymentIntent) error
+	// Refund returns money to the customer. completed = false when the refund
+	// requires a manual step (SINPE: el admin hace la transferencia).
+	Refund(ctx context.Context, intent *models.PaymentIntent, amount float64) (providerRef string, completed bool, err error)
+}
+
+// WebhookProvider is a provider that notifies payments by webhook (pasarela de tarjeta)
+type WebhookProvider interface {
+	Provider
+	ParseWebhook(body []byte, header http.Header) (*WebhookEvent, error)
+}
+
+// Tipos de evento de webhook
+const (
+	EventPaymentSucceeded = "payment.succeeded"
+	EventPaymentFailed    = "payment.failed"
+)
+
+// WebhookEvent is a normalized gateway notification
+type WebhookEvent struct {
+	Type        string    `json:"type"`
+	Reference   string    `json:"reference"`    // Referencia FL-XXXXXX del cobro
+	ProviderRef string    `json:"provider_ref"` // ID del cargo en la pasarela
+	Amount      float64   `json:"amount"`
+	Currency    string    `json:"currency"`
+	OccurredAt  time.Time `json:"occurred_at"`
+}
+
+// ── SINPE Móvil ─────────────────────────────────────────────────────────────
+
+// SINPEProvider cobra por SINPE Móvil. No hay API: el cliente deposita con la
+// referencia en la descripción y el movimiento se concilia después.
+type SINPEProvider struct{}
+
+func (SINPEProvider) Name() string { return models.PaymentProviderSINPE }
+
+func (SINPEProvider) Prepare(context.Context, *models.PaymentIntent) error { return nil }
+
+// Refund queda pendiente: la devolución por SINPE la hace el admin desde el banco
+func (SINPEProvider) Refund(context.Context, *models.PaymentIntent, float64) (string, bool, error) {
+	return "", false, nil
+}
+
+// ── Pasarela de tarjeta ─────────────────────────────────────────────────────
+
+// CheckoutRequest is what the gateway needs to open a hosted checkout
+type CheckoutRequest struct {
+	Reference   string
+	Amount      float64
+	Currency    string
+	Description string
+}
+
+// CheckoutSession is the gateway's answer
+type CheckoutSession struct {
+	ID  string
+	URL string
+}
+
+// CardGateway is the minimal API of a card processor with hosted checkout.
+// Cada pasarela concreta (Tilopay, Greenpay, BAC...) implementa esta interfaz.
+type CardGateway interface {
+	CreateCheckout(ctx context.Context, req CheckoutRequest) (*CheckoutSession, error)
+	Refund(ctx context.Context, chargeID string, amount float64) (refundID string, err error)
+}
+
+// CardProvider cobra con tarjeta a través de un CardGateway. Los pagos se
+// confirman por webhook firmado con HMAC-SHA256 (header X-Signature, hex).
+type CardProvider struct {
+	gateway       CardGateway
+	webhookSecret []byte
+}
+
+func NewCardProvider(gateway CardGateway, webhookSecret string) *CardProvider {
+	return &CardProvider{gateway: gateway, webhookSecret: []byte(webhookSecret)}
+}
+
+func (p *CardProvider) Name() string { return models.PaymentProviderCard }
+
+func (p *CardProvider) Prepare(ctx context.Context, intent *models.PaymentIntent) error {
+	session, err := p.gateway.CreateCheckout(ctx, CheckoutRequest{
+		Reference:   intent.Reference,
+		Amount:      intent.Amount,
+		Currency:    intent.Currency,
+		Description: fmt.Sprintf("FabricaLaser pedido #%d", intent.QuoteID),
+	})
+	if err != nil {
+		return fmt.Errorf("payments: error creando checkout: %w", err)
+	}
+	intent.ProviderRef = &session.ID
+	intent.CheckoutURL = &session.URL
+	return nil
+}
+
+func (p *CardProvider) Refund(ctx context.Context, intent *models.PaymentIntent, amount float64) (string, bool, error) {
+	if intent.ProviderRef == nil {
+		return "", false, errors.New("payments: cobro sin referencia de la pasarela")
+	}
+	ref, err := p.gateway.Refund(ctx, *intent.ProviderRef, amount)
+	if err != nil {
+		return "", false, err
+	}
+	return ref, true, nil
+}
+
+func (p *CardProvider) ParseWebhook(body []byte, header http.Header) (*WebhookEvent, error) {
+	if !VerifySignature(body, header.Get("X-Signature"), p.webhookSecret) {
+		return nil, ErrInvalidSignature
+	}
+	var event WebhookEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, fmt.Errorf("payments: webhook inválido: %w", err)
+	}
+	if event.Reference == "" {
+		return nil, errors.New("payments: webhook sin referencia")
+	}
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now()
+	}
+	return &event, nil
+}
+
+// Sign returns the hex HMAC-SHA256 of body
+func Sign(body, secret []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature compares the signature in constant time
+func VerifySignature(body []byte, signature string, secret []byte) bool {
+	if len(secret) == 0 || signature == "" {
+		return false
+	}
+	expected := Sign(body, secret)
+	return hmac.Equal([]byte(expected), []byte(strings.ToLower(strings.TrimPrefix(signature, "sha256="))))
+}
+
+// StubGateway simula una pasarela en memoria (desarrollo y pruebas).
+// El checkout no cobra nada: el pago se confirma enviando el webhook a mano.
+type StubGateway struct {
+	mu      sync.Mutex
+	counter int
+}
+
+func NewStubGateway() *StubGateway {
+	return &StubGateway{}
+}
+
+func (g *StubGateway) CreateCheckout(_ context.Context, req CheckoutRequest) (*CheckoutSession, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.counter++
+	id := fmt.Sprintf("stub_ch_%d", g.counter)
+	return &CheckoutSession{ID: id, URL: "https://pagos.stub.local/checkout/" + req.Reference}, nil
+}
+
+func (g *StubGateway) Refund(_ context.Context, chargeID string, _ float64) (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.counter++
+	return fmt.Sprintf("stub_re_%d_%s", g.counter, chargeID), nil
+}
@@ -0,0 +1,99 @@
+package payments
+
+import (
+	"math"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+)
+
+// Reglas de conciliación, de la más a la menos confiable
+const (
+	RuleReference    = "reference"     // La descripción trae la referencia FL-XXXXXX
+	RulePhoneAmount  = "phone_amount"  // Mismo teléfono y monto pendiente exacto
+	RuleUniqueAmount = "unique_amount" // Único cobro SINPE pendiente con ese monto
+	RuleManual       = "manual"        // Asignado por el admin
+)
+
+// AmountTolerance absorbe redondeos (colones enteros vs decimales del banco)
+const AmountTolerance = 1.0
+
+// Referencias tal como las escriben los clientes: "FL-AB12CD", "fl ab12cd", "FLAB12CD"
+var referenceRe = regexp.MustCompile(`(?i)\bFL[\s-]?([` + referenceAlphabet + `]{6})\b`)
+
+// FindReference extracts a normalized FL-XXXXXX reference from free text
+func FindReference(text string) string {
+	m := referenceRe.FindStringSubmatch(strings.ToUpper(text))
+	if m == nil {
+		return ""
+	}
+	return "FL-" + m[1]
+}
+
+// Match picks the pending intent a movement pays, or nil if it is ambiguous.
+//
+//  1. reference: la descripción trae la referencia del cobro (cualquier monto: abono parcial)
+//  2. phone_amount: el teléfono del pagador coincide y el monto cubre lo pendiente
+//  3. unique_amount: un solo cobro SINPE pendiente con ese monto exacto
+func Match(tx *models.PaymentTransaction, pending []models.PaymentIntent) (*models.PaymentIntent, string) {
+	if tx.Amount <= 0 {
+		return nil, ""
+	}
+
+	if ref := FindReference(deref(tx.Description) + " " + deref(tx.Raw)); ref != "" {
+		for i := range pending {
+			if pending[i].Reference == ref {
+				return &pending[i], RuleReference
+			}
+		}
+	}
+
+	phone := NormalizePhone(deref(tx.PayerPhone))
+	if phone != "" {
+		var found *models.PaymentIntent
+		for i := range pending {
+			p := &pending[i]
+			if NormalizePhone(deref(p.PayerPhone)) == phone && sameAmount(tx.Amount, p.Outstanding()) {
+				if found != nil {
+					found = nil // dos cobros del mismo cliente por el mismo monto: revisar a mano
+					break
+				}
+				found = p
+			}
+		}
+		if found != nil {
+			return found, RulePhoneAmount
+		}
+	}
+
+	var found *models.PaymentIntent
+	for i := range pending {
+		p := &pending[i]
+		if p.Provider != models.PaymentProviderSINPE || !sameAmount(tx.Amount, p.Outstanding()) {
+			continue
+		}
+		// Un movimiento de un día anterior al cobro no puede pagarlo (los CSV solo traen fecha)
+		if !p.CreatedAt.IsZero() && tx.OccurredAt.Before(startOfDay(p.CreatedAt)) {
+			continue
+		}
+		if found != nil {
+			return nil, ""
+		}
+		found = p
+	}
+	if found != nil {
+		return found, RuleUniqueAmount
+	}
+	return nil, ""
+}
+
+func startOfDay(t time.Time) time.Time {
+	t = t.In(zonaCR)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, zonaCR)
+}
+
+func sameAmount(a, b float64) bool {
+	return math.Abs(a-b) <= AmountTolerance
+}
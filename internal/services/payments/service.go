@@ -0,0 +1,543 @@
+// Package payments gestiona los cobros de pedidos: intenciones de pago por
+// cotización convertida (adelanto, saldo o total), proveedores (SINPE Móvil y
+// pasarela de tarjeta), conciliación de estados de cuenta y SMS de SINPE, y
+// devoluciones.
+//
+// Todos los montos están en CRC con IVA incluido (quotes.price_total_with_tax).
+package payments
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/big"
+	"time"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/config"
+	"github.com/alonsoalpizar/fabricalaser/internal/database"
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+	"github.com/alonsoalpizar/fabricalaser/internal/repository"
+	"github.com/alonsoalpizar/fabricalaser/internal/services/pricing"
+)
+
+var (
+	ErrQuoteNotPayable    = errors.New("la cotización debe estar aprobada o convertida en pedido para cobrarla")
+	ErrNothingToPay       = errors.New("el pedido no tiene saldo pendiente")
+	ErrInvalidAmount      = errors.New("monto inválido")
+	ErrUnknownProvider    = errors.New("proveedor de pago no disponible")
+	ErrInvalidKind        = errors.New("tipo de cobro inválido (deposit, balance o full)")
+	ErrIntentNotPending   = repository.ErrPaymentIntentNotPending
+	ErrRefundExceeds      = errors.New("el monto excede lo cobrado")
+	ErrAlreadyMatched     = repository.ErrPaymentTransactionMatched
+	ErrRefundNotPending   = errors.New("la devolución no está pendiente")
+	ErrWebhookUnsupported = errors.New("el proveedor no recibe webhooks")
+)
+
+// zonaCR: las fechas de los bancos vienen en hora local (UTC-6, sin horario de verano)
+var zonaCR = time.FixedZone("America/Costa_Rica", -6*60*60)
+
+// Sin 0/O ni 1/I para que el cliente no se equivoque al escribir la referencia
+const referenceAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+const defaultIntentHours = 72
+
+type Service struct {
+	repo         *repository.PaymentRepository
+	quoteRepo    *repository.QuoteRepository
+	configLoader *pricing.ConfigLoader
+	providers    map[string]Provider
+	now          func() time.Time
+}
+
+func NewService(providers ...Provider) *Service {
+	s := &Service{
+		repo:         repository.NewPaymentRepository(),
+		quoteRepo:    repository.NewQuoteRepository(),
+		configLoader: pricing.NewConfigLoader(database.Get()),
+		providers:    map[string]Provider{},
+		now:          time.Now,
+	}
+	for _, p := range providers {
+		s.providers[p.Name()] = p
+	}
+	return s
+}
+
+// NewServiceFromConfig registers SINPE always and the card gateway when a webhook
+// secret is configured. Fuera de producción se usa la pasarela stub.
+func NewServiceFromConfig() *Service {
+	cfg := config.Get()
+	providers := []Provider{SINPEProvider{}}
+	switch {
+	case cfg.PayCardWebhookSecret == "":
+		slog.Info("payments: pasarela de tarjeta deshabilitada (sin webhook secret)")
+	case cfg.IsProduction():
+		// No hay pasarela concreta integrada todavía: no cobrar con el stub en producción
+		slog.Warn("payments: pasarela de tarjeta sin implementar, solo SINPE disponible")
+	default:
+		slog.Warn("payments: usando pasarela de tarjeta stub")
+		providers = append(providers, NewCardProvider(NewStubGateway(), cfg.PayCardWebhookSecret))
+	}
+	return NewService(providers...)
+}
+
+// Providers returns the names of the available providers
+func (s *Service) Providers() []string {
+	names := make([]string, 0, len(s.providers))
+	for name := range s.providers {
+		names = append(names, name)
+	}
+	return names
+}
+
+// CreateIntent opens a payment for an order. amount = 0 uses the default for the
+// kind: deposit = pago_adelanto_pct del total, balance/full = saldo pendiente.
+func (s *Service) CreateIntent(ctx context.Context, quoteID uint, kind, providerName string, amount float64) (*models.PaymentIntent, error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+
+	quote, err := s.quoteRepo.FindByIDWithRelations(quoteID)
+	if err != nil {
+		return nil, err
+	}
+	if quote.Status != models.QuoteStatusConverted && !quote.CanBeConverted() {
+		return nil, ErrQuoteNotPayable
+	}
+
+	cfg, err := s.configLoader.Load()
+	if err != nil {
+		return nil, fmt.Errorf("payments: error cargando configuración: %w", err)
+	}
+
+	total := quote.PriceTotalWithTax
+	if total <= 0 {
+		total = quote.PriceFinal
+	}
+	balance := math.Round(total - quote.AmountPaid)
+	if balance <= AmountTolerance {
+		return nil, ErrNothingToPay
+	}
+
+	switch kind {
+	case models.PaymentKindDeposit:
+		if amount == 0 {
+			pct := cfg.GetSystemConfigFloat("pago_adelanto_pct", 50)
+			amount = math.Round(total * pct / 100)
+		}
+	case models.PaymentKindBalance, models.PaymentKindFull, "":
+		if kind == "" {
+			kind = models.PaymentKindFull
+		}
+		if amount == 0 {
+			amount = balance
+		}
+	default:
+		return nil, ErrInvalidKind
+	}
+	if amount <= 0 || amount > balance+AmountTolerance {
+		return nil, ErrInvalidAmount
+	}
+
+	reference, err := newReference()
+	if err != nil {
+		return nil, err
+	}
+	now := s.now()
+	expires := now.Add(time.Duration(cfg.GetSystemConfigFloat("pago_vigencia_horas", defaultIntentHours)) * time.Hour)
+	intent := &models.PaymentIntent{
+		QuoteID:   quote.ID,
+		UserID:    quote.UserID,
+		Provider:  provider.Name(),
+		Kind:      kind,
+		Reference: reference,
+		Amount:    amount,
+		Currency:  models.CurrencyCRC,
+		Status:    models.PaymentIntentPending,
+		ExpiresAt: &expires,
+	}
+	if quote.User != nil && quote.User.Telefono != nil {
+		if phone := NormalizePhone(*quote.User.Telefono); phone != "" {
+			intent.PayerPhone = &phone
+		}
+	}
+
+	if err := provider.Prepare(ctx, intent); err != nil {
+		return nil, err
+	}
+	if err := s.repo.CreateIntent(intent); err != nil {
+		return nil, err
+	}
+	return intent, nil
+}
+
+// CancelIntent cancels a pending intent that received nothing
+func (s *Service) CancelIntent(intentID uint) (*models.PaymentIntent, error) {
+	intent, err := s.repo.FindIntentByID(intentID)
+	if err != nil {
+		return nil, err
+	}
+	if intent.Status != models.PaymentIntentPending || intent.AmountReceived > 0 {
+		return nil, ErrIntentNotPending
+	}
+	intent.Status = models.PaymentIntentCanceled
+	if err := s.repo.UpdateIntent(intent); err != nil {
+		return nil, err
+	}
+	return intent, nil
+}
+
+// Instructions returns the text the customer needs to pay the intent
+func (s *Service) Instructions(intent *models.PaymentIntent) string {
+	if intent.Provider == models.PaymentProviderCard && intent.CheckoutURL != nil {
+		return fmt.Sprintf("Pagá ₡%s con tarjeta en: %s", formatColones(intent.Amount), *intent.CheckoutURL)
+	}
+
+	numero, titular := "", ""
+	if cfg, err := s.configLoader.Load(); err == nil {
+		numero = cfg.GetSystemConfigString("sinpe_movil_numero")
+		titular = cfg.GetSystemConfigString("sinpe_movil_titular")
+	}
+	text := fmt.Sprintf("Hacé un SINPE Móvil por ₡%s", formatColones(intent.Amount))
+	if numero != "" {
+		text += " al " + numero
+		if titular != "" {
+			text += " (" + titular + ")"
+		}
+	}
+	return text + fmt.Sprintf(" y escribí %s en la descripción.", intent.Reference)
+}
+
+// ImportResult summarizes an import + reconciliation run
+type ImportResult struct {
+	Imported   int           `json:"imported"`
+	Duplicates int           `json:"duplicates"`
+	Skipped    int           `json:"skipped"`
+	Matched    []MatchResult `json:"matched"`
+	Unmatched  []uint        `json:"unmatched"` // IDs de movimientos para revisar a mano
+	Errors     []RowError    `json:"errors,omitempty"`
+}
+
+// MatchResult is a movement applied to an intent
+type MatchResult struct {
+	TransactionID uint    `json:"transaction_id"`
+	IntentID      uint    `json:"intent_id"`
+	QuoteID       uint    `json:"quote_id"`
+	Reference     string  `json:"reference"`
+	Amount        float64 `json:"amount"`
+	Rule          string  `json:"rule"`
+}
+
+// Import stores parsed movements (skipping ones already imported) and reconciles them
+func (s *Service) Import(parsed *ParseResult) (*ImportResult, error) {
+	result := &ImportResult{Skipped: parsed.Skipped, Errors: parsed.Errors, Matched: []MatchResult{}, Unmatched: []uint{}}
+
+	pending, err := s.repo.FindPendingIntents("")
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range parsed.Transactions {
+		tx := parsed.Transactions[i]
+		tx.Status = models.PaymentTxUnmatched
+		created, err := s.repo.CreateTransaction(&tx)
+		if err != nil {
+			return nil, err
+		}
+		if !created {
+			result.Duplicates++
+			continue
+		}
+		result.Imported++
+
+		match, err := s.reconcile(&tx, &pending)
+		if err != nil {
+			return nil, err
+		}
+		if match != nil {
+			result.Matched = append(result.Matched, *match)
+		} else {
+			result.Unmatched = append(result.Unmatched, tx.ID)
+		}
+	}
+	return result, nil
+}
+
+// reconcile matches one stored movement and applies it. pending is refreshed in place.
+func (s *Service) reconcile(tx *models.PaymentTransaction, pending *[]models.PaymentIntent) (*MatchResult, error) {
+	intent, rule := Match(tx, *pending)
+	if intent == nil {
+		return nil, nil
+	}
+	updated, err := s.repo.ApplyPayment(tx, intent.ID, rule, AmountTolerance)
+	if errors.Is(err, ErrIntentNotPending) || errors.Is(err, ErrAlreadyMatched) {
+		// pending quedó desactualizado: el movimiento sigue sin conciliar
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("payments: movimiento conciliado",
+		"transaction_id", tx.ID, "reference", updated.Reference, "quote_id", updated.QuoteID,
+		"amount", tx.Amount, "rule", rule)
+
+	// Refrescar la lista: un cobro pagado sale de pendientes, uno parcial actualiza lo recibido
+	list := (*pending)[:0]
+	for _, p := range *pending {
+		if p.ID == updated.ID {
+			if updated.Status != models.PaymentIntentPending {
+				continue
+			}
+			p = *updated
+		}
+		list = append(list, p)
+	}
+	*pending = list
+
+	return &MatchResult{
+		TransactionID: tx.ID,
+		IntentID:      updated.ID,
+		QuoteID:       updated.QuoteID,
+		Reference:     updated.Reference,
+		Amount:        tx.Amount,
+		Rule:          rule,
+	}, nil
+}
+
+// MatchManually assigns an unmatched movement to an intent chosen by the admin.
+// ApplyPayment rejects a movement already matched or an intent that is not pending.
+func (s *Service) MatchManually(transactionID, intentID uint) (*models.PaymentIntent, error) {
+	tx, err := s.repo.FindTransactionByID(transactionID)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.ApplyPayment(tx, intentID, RuleManual, AmountTolerance)
+}
+
+// IgnoreTransaction marks a movement that is not a customer payment
+func (s *Service) IgnoreTransaction(transactionID uint) (*models.PaymentTransaction, error) {
+	tx, err := s.repo.FindTransactionByID(transactionID)
+	if err != nil {
+		return nil, err
+	}
+	if tx.Status == models.PaymentTxMatched {
+		return nil, ErrAlreadyMatched
+	}
+	tx.Status = models.PaymentTxIgnored
+	if err := s.repo.UpdateTransaction(tx); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// HandleWebhook applies a gateway notification
+func (s *Service) HandleWebhook(ctx context.Context, providerName string, body []byte, header map[string][]string) error {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return ErrUnknownProvider
+	}
+	wp, ok := provider.(WebhookProvider)
+	if !ok {
+		return ErrWebhookUnsupported
+	}
+	event, err := wp.ParseWebhook(body, header)
+	if err != nil {
+		return err
+	}
+
+	intent, err := s.repo.FindIntentByReference(event.Reference)
+	if err != nil {
+		return err
+	}
+
+	switch event.Type {
+	case EventPaymentSucceeded:
+		raw := string(body)
+		ref := event.ProviderRef
+		tx := &models.PaymentTransaction{
+			Source:      models.PaymentSourceWebhook,
+			OccurredAt:  event.OccurredAt,
+			Amount:      event.Amount,
+			Currency:    models.CurrencyCRC,
+			BankRef:     &ref,
+			Description: &event.Reference,
+			Raw:         &raw,
+			Status:      models.PaymentTxUnmatched,
+		}
+		tx.Fingerprint = Fingerprint(tx)
+		created, err := s.repo.CreateTransaction(tx)
+		if err != nil || !created {
+			return err // reintento de la pasarela: ya aplicado
+		}
+		_, err = s.repo.ApplyPayment(tx, intent.ID, RuleReference, AmountTolerance)
+		if errors.Is(err, ErrIntentNotPending) {
+			// Cobro ya pagado, vencido o cancelado: el movimiento queda sin conciliar para revisión
+			slog.Warn("payments: pago de pasarela sobre un cobro no pendiente",
+				"reference", event.Reference, "transaction_id", tx.ID)
+			return nil
+		}
+		return err
+	case EventPaymentFailed:
+		if intent.Status == models.PaymentIntentPending && intent.AmountReceived == 0 {
+			intent.Status = models.PaymentIntentFailed
+			return s.repo.UpdateIntent(intent)
+		}
+		return nil
+	default:
+		slog.Info("payments: evento de webhook ignorado", "type", event.Type, "reference", event.Reference)
+		return nil
+	}
+}
+
+// Refund returns part or all of what an intent received. Card refunds complete
+// immediately; SINPE refunds stay pending until CompleteRefund.
+func (s *Service) Refund(ctx context.Context, intentID uint, amount float64, reason string, adminID *uint) (*models.PaymentRefund, error) {
+	intent, err := s.repo.FindIntentByID(intentID)
+	if err != nil {
+		return nil, err
+	}
+	if amount == 0 {
+		amount = intent.Refundable()
+	}
+	if amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+	if amount > intent.Refundable()+0.001 {
+		return nil, ErrRefundExceeds
+	}
+	provider, ok := s.providers[intent.Provider]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+
+	providerRef, completed, err := provider.Refund(ctx, intent, amount)
+	if err != nil {
+		return nil, err
+	}
+
+	refund := &models.PaymentRefund{
+		IntentID:  intent.ID,
+		Amount:    amount,
+		Reason:    optional(reason),
+		Status:    models.RefundPending,
+		CreatedBy: adminID,
+	}
+	if completed {
+		now := s.now()
+		refund.Status = models.RefundCompleted
+		refund.CompletedAt = &now
+		refund.ProviderRef = optional(providerRef)
+	}
+	if err := s.repo.CreateRefund(refund, AmountTolerance); err != nil {
+		return nil, err
+	}
+	return refund, nil
+}
+
+// CompleteRefund confirms (or fails) a pending manual refund
+func (s *Service) CompleteRefund(refundID uint, success bool, providerRef string) (*models.PaymentRefund, error) {
+	refund, err := s.repo.FindRefundByID(refundID)
+	if err != nil {
+		return nil, err
+	}
+	if refund.Status != models.RefundPending {
+		return nil, ErrRefundNotPending
+	}
+	if success {
+		now := s.now()
+		refund.Status = models.RefundCompleted
+		refund.CompletedAt = &now
+		refund.ProviderRef = optional(providerRef)
+	} else {
+		refund.Status = models.RefundFailed
+	}
+	if err := s.repo.UpdateRefund(refund, AmountTolerance); err != nil {
+		return nil, err
+	}
+	return refund, nil
+}
+
+// RunReconciliation expires stale intents and retries unmatched movements
+// (un cobro creado después del movimiento puede conciliarlo ahora)
+func (s *Service) RunReconciliation() (expired int64, matched []MatchResult, err error) {
+	expired, err = s.repo.ExpireIntents(s.now())
+	if err != nil {
+		return 0, nil, err
+	}
+
+	txs, err := s.repo.FindUnmatchedTransactions()
+	if err != nil || len(txs) == 0 {
+		return expired, nil, err
+	}
+	pending, err := s.repo.FindPendingIntents("")
+	if err != nil {
+		return expired, nil, err
+	}
+	for i := range txs {
+		match, err := s.reconcile(&txs[i], &pending)
+		if err != nil {
+			return expired, matched, err
+		}
+		if match != nil {
+			matched = append(matched, *match)
+		}
+	}
+	return expired, matched, nil
+}
+
+// StartReconciliationJob runs RunReconciliation every interval until ctx is done.
+// Payments only serve the default tenant (their routes and webhook are behind
+// tenant.DefaultOnly and payment_intents has no tenant_id), so the job does not
+// iterate over tenants: the repositories run unscoped to the default tenant.
+func (s *Service) StartReconciliationJob(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				expired, matched, err := s.RunReconciliation()
+				if err != nil {
+					slog.Error("payments: error en conciliación", "error", err)
+					continue
+				}
+				if expired > 0 || len(matched) > 0 {
+					slog.Info("payments: conciliación", "expirados", expired, "conciliados", len(matched))
+				}
+			}
+		}
+	}()
+}
+
+// newReference returns FL- plus 6 random characters
+func newReference() (string, error) {
+	b := make([]byte, 6)
+	max := big.NewInt(int64(len(referenceAlphabet)))
+	for i := range b {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", fmt.Errorf("payments: error generando referencia: %w", err)
+		}
+		b[i] = referenceAlphabet[n.Int64()]
+	}
+	return "FL-" + string(b), nil
+}
+
+// formatColones formats 25000 as "25 000" (separador de miles con espacio, como Hacienda)
+func formatColones(v float64) string {
+	s := fmt.Sprintf("%.0f", math.Round(v))
+	var out []byte
+	for i := range s {
+		if i > 0 && (len(s)-i)%3 == 0 && s[i-1] != '-' {
+			out = append(out, ' ')
+		}
+		out = append(out, s[i])
+	}
+	return string(out)
+}
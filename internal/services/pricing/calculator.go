@@ -1,6 +1,7 @@
 package pricing
 
 import (
+	"context"
 	"math"
 	"time"
 
@@ -54,6 +55,12 @@ type PriceResult struct {
 	SimHybridWithMaterialFactor float64 // What hybrid would be WITH material factor
 	SimDifferencePct            float64 // Percentage difference
 
+	// Currency: all amounts above are in BaseCurrency.
+	// ExchangeRate (CRC per USD) is the rate used for every conversion in this result.
+	BaseCurrency     string
+	ExchangeRate     float64
+	ExchangeRateDate *time.Time
+
 	// Cut technology (when different from main engrave tech)
 	CutTechnologyID *uint // nil = misma tech principal
 
@@ -70,6 +77,7 @@ type PriceResult struct {
 type Calculator struct {
 	configLoader  *ConfigLoader
 	timeEstimator *TimeEstimator
+	ctx           context.Context // tenant whose config is used (nil = default tenant)
 }
 
 // NewCalculator creates a calculator with the given config loader
@@ -79,6 +87,13 @@ func NewCalculator(configLoader *ConfigLoader) *Calculator {
 	}
 }
 
+// WithContext returns a calculator that prices with the config of the tenant in ctx
+func (c *Calculator) WithContext(ctx context.Context) *Calculator {
+	clone := *c
+	clone.ctx = ctx
+	return &clone
+}
+
 // Calculate computes full pricing for an SVG analysis with given options
 // thickness is used to look up specific speeds from tech_material_speeds
 // materialIncluded indicates whether we provide material (true) or client provides (false)
@@ -94,13 +109,16 @@ func (c *Calculator) Calculate(
 	ignoreCutLines bool,   // true = ignorar líneas de corte (material no cortable)
 ) (*PriceResult, error) {
 	// Load current config from DB
-	config, err := c.configLoader.Load()
+	config, err := c.configLoader.LoadContext(c.ctx)
 	if err != nil {
 		return nil, err
 	}
 
 	result := &PriceResult{
 		MaterialIncluded: materialIncluded,
+		BaseCurrency:     config.GetBaseCurrency(),
+		ExchangeRate:     config.GetExchangeRate(),
+		ExchangeRateDate: config.GetExchangeRateDate(),
 	}
 
 	// =============================================================
@@ -364,12 +382,13 @@ func (c *Calculator) ToQuoteModel(
 	thickness float64,
 	cutTechnologyID *uint,
 	ignoreCutLines bool,
+	currency string, // moneda de presentación ("CRC" o "USD")
 ) *models.Quote {
 	now := time.Now()
 
 	// Get quote validity days from config (with fallback)
 	validityDays := 7
-	if config, err := c.configLoader.Load(); err == nil {
+	if config, err := c.configLoader.LoadContext(c.ctx); err == nil {
 		validityDays = config.GetQuoteValidityDays()
 	}
 	validUntil := now.AddDate(0, 0, validityDays)
@@ -429,6 +448,12 @@ func (c *Calculator) ToQuoteModel(
 		PriceModel:       result.PriceModel,
 		PriceModelDetail: result.PriceModelDetail,
 
+		// Currency fields — rate frozen for reproducibility
+		Currency:         models.NormalizeCurrency(currency),
+		BaseCurrency:     result.BaseCurrency,
+		ExchangeRate:     result.ExchangeRate,
+		ExchangeRateDate: result.ExchangeRateDate,
+
 		// Simulation fields
 		SimHybridWithMaterialFactor: result.SimHybridWithMaterialFactor,
 		SimDifferencePct:            result.SimDifferencePct,
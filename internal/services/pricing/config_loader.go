@@ -1,12 +1,14 @@
 package pricing
 
 import (
+	"context"
 	"math"
 	"strconv"
 	"sync"
 	"time"
 
 	"github.com/alonsoalpizar/fabricalaser/internal/models"
+	"github.com/alonsoalpizar/fabricalaser/internal/tenant"
 	"gorm.io/gorm"
 )
 
@@ -21,14 +23,17 @@ type PricingConfig struct {
 	SystemConfigs      map[string]*models.SystemConfig    // config_key → config
 	TechMaterialSpeeds []models.TechMaterialSpeed         // All speed configurations
 	MaterialCosts      []models.MaterialCost              // Material costs by thickness
+	ExchangeRate       *models.ExchangeRate               // Latest USD→CRC rate (nil = none registered)
+	Tenant             *models.Tenant                     // Shop this config belongs to (branding for PDFs)
 
 	LoadedAt time.Time
 }
 
-// ConfigLoader loads and caches pricing configuration from database
+// ConfigLoader loads and caches pricing configuration from database,
+// one cache entry per tenant
 type ConfigLoader struct {
 	db       *gorm.DB
-	cache    *PricingConfig
+	cache    map[uint]*PricingConfig // tenant_id → config
 	cacheTTL time.Duration
 	mu       sync.RWMutex
 }
@@ -37,32 +42,46 @@ type ConfigLoader struct {
 func NewConfigLoader(db *gorm.DB) *ConfigLoader {
 	return &ConfigLoader{
 		db:       db,
+		cache:    make(map[uint]*PricingConfig),
 		cacheTTL: 5 * time.Minute, // Cache for 5 minutes
 	}
 }
 
-// Load fetches all pricing configuration from database
+// Load fetches the default tenant's pricing configuration
 // Uses cache if available and not expired
 func (l *ConfigLoader) Load() (*PricingConfig, error) {
+	return l.LoadContext(context.Background())
+}
+
+// LoadContext fetches the pricing configuration of the tenant in ctx
+// (default tenant if ctx has none). Uses cache if available and not expired
+func (l *ConfigLoader) LoadContext(ctx context.Context) (*PricingConfig, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	id := tenant.ID(ctx)
+
 	l.mu.RLock()
-	if l.cache != nil && time.Since(l.cache.LoadedAt) < l.cacheTTL {
-		config := l.cache
+	if config := l.cache[id]; config != nil && time.Since(config.LoadedAt) < l.cacheTTL {
 		l.mu.RUnlock()
 		return config, nil
 	}
 	l.mu.RUnlock()
 
 	// Cache expired or not loaded, fetch from DB
-	return l.refresh()
+	return l.refresh(tenant.WithID(ctx, id))
 }
 
-// Refresh forces a reload from database
+// Refresh forces a reload of the default tenant from database
 func (l *ConfigLoader) Refresh() (*PricingConfig, error) {
-	return l.refresh()
+	return l.refresh(tenant.WithID(context.Background(), models.DefaultTenantID))
 }
 
-// refresh loads all config from database
-func (l *ConfigLoader) refresh() (*PricingConfig, error) {
+// refresh loads all config of the tenant in ctx from database.
+// The tenant plugin scopes every query below except exchange rates (shared)
+func (l *ConfigLoader) refresh(ctx context.Context) (*PricingConfig, error) {
+	id := tenant.ID(ctx)
+	db := l.db.WithContext(ctx)
 	config := &PricingConfig{
 		TechRates:          make(map[uint]*models.TechRate),
 		Technologies:       make(map[uint]*models.Technology),
@@ -72,12 +91,13 @@ func (l *ConfigLoader) refresh() (*PricingConfig, error) {
 		SystemConfigs:      make(map[string]*models.SystemConfig),
 		TechMaterialSpeeds: make([]models.TechMaterialSpeed, 0),
 		MaterialCosts:      make([]models.MaterialCost, 0),
+		Tenant:             tenant.Lookup(id),
 		LoadedAt:           time.Now(),
 	}
 
 	// Load technologies
 	var technologies []models.Technology
-	if err := l.db.Where("is_active = ?", true).Find(&technologies).Error; err != nil {
+	if err := db.Where("is_active = ?", true).Find(&technologies).Error; err != nil {
 		return nil, err
 	}
 	for i := range technologies {
@@ -86,7 +106,7 @@ func (l *ConfigLoader) refresh() (*PricingConfig, error) {
 
 	// Load tech rates
 	var techRates []models.TechRate
-	if err := l.db.Where("is_active = ?", true).Find(&techRates).Error; err != nil {
+	if err := db.Where("is_active = ?", true).Find(&techRates).Error; err != nil {
 		return nil, err
 	}
 	for i := range techRates {
@@ -95,7 +115,7 @@ func (l *ConfigLoader) refresh() (*PricingConfig, error) {
 
 	// Load materials
 	var materials []models.Material
-	if err := l.db.Where("is_active = ?", true).Find(&materials).Error; err != nil {
+	if err := db.Where("is_active = ?", true).Find(&materials).Error; err != nil {
 		return nil, err
 	}
 	for i := range materials {
@@ -104,7 +124,7 @@ func (l *ConfigLoader) refresh() (*PricingConfig, error) {
 
 	// Load engrave types
 	var engraveTypes []models.EngraveType
-	if err := l.db.Where("is_active = ?", true).Find(&engraveTypes).Error; err != nil {
+	if err := db.Where("is_active = ?", true).Find(&engraveTypes).Error; err != nil {
 		return nil, err
 	}
 	for i := range engraveTypes {
@@ -113,14 +133,14 @@ func (l *ConfigLoader) refresh() (*PricingConfig, error) {
 
 	// Load volume discounts (sorted by min_qty)
 	var volumeDiscounts []models.VolumeDiscount
-	if err := l.db.Where("is_active = ?", true).Order("min_qty ASC").Find(&volumeDiscounts).Error; err != nil {
+	if err := db.Where("is_active = ?", true).Order("min_qty ASC").Find(&volumeDiscounts).Error; err != nil {
 		return nil, err
 	}
 	config.VolumeDiscounts = volumeDiscounts
 
 	// Load system configs
 	var systemConfigs []models.SystemConfig
-	if err := l.db.Where("is_active = ?", true).Find(&systemConfigs).Error; err != nil {
+	if err := db.Where("is_active = ?", true).Find(&systemConfigs).Error; err != nil {
 		return nil, err
 	}
 	for i := range systemConfigs {
@@ -131,21 +151,35 @@ func (l *ConfigLoader) refresh() (*PricingConfig, error) {
 	// NOTE: Changed to load ALL active records (not just compatible ones)
 	// This enables IsCompatible() to check and return proper error messages
 	var techMaterialSpeeds []models.TechMaterialSpeed
-	if err := l.db.Where("is_active = ?", true).Find(&techMaterialSpeeds).Error; err != nil {
+	if err := db.Where("is_active = ?", true).Find(&techMaterialSpeeds).Error; err != nil {
 		return nil, err
 	}
 	config.TechMaterialSpeeds = techMaterialSpeeds
 
 	// Load material costs (for raw material pricing)
 	var materialCosts []models.MaterialCost
-	if err := l.db.Where("is_active = ?", true).Find(&materialCosts).Error; err != nil {
+	if err := db.Where("is_active = ?", true).Find(&materialCosts).Error; err != nil {
 		return nil, err
 	}
 	config.MaterialCosts = materialCosts
 
+	// Load latest USD→CRC exchange rate (optional — falls back to system_config)
+	var exchangeRate models.ExchangeRate
+	err := db.Where("base_currency = ? AND quote_currency = ? AND rate_date <= ?",
+		models.CurrencyUSD, models.CurrencyCRC, time.Now()).
+		Order("rate_date DESC").
+		Limit(1).
+		Find(&exchangeRate).Error
+	if err != nil {
+		return nil, err
+	}
+	if exchangeRate.ID != 0 {
+		config.ExchangeRate = &exchangeRate
+	}
+
 	// Update cache
 	l.mu.Lock()
-	l.cache = config
+	l.cache[id] = config
 	l.mu.Unlock()
 
 	return config, nil
@@ -183,7 +217,7 @@ func (c *PricingConfig) GetVolumeDiscount(quantity int) float64 {
 }
 
 // GetCostPerMinEngrave returns cost per minute for engraving
-// Formula: (tarifa_operador + overhead_global + overhead_maquina) / 60
+// Formula: (tarifa_operador + overhead_global + overhead_maquina) / 60 — en moneda base
 func (c *PricingConfig) GetCostPerMinEngrave(techID uint) float64 {
 	rate := c.TechRates[techID]
 	if rate == nil {
@@ -191,11 +225,11 @@ func (c *PricingConfig) GetCostPerMinEngrave(techID uint) float64 {
 	}
 	overheadGlobal := c.GetOverheadGlobalPerHourCRC()
 	overheadMaquina := c.GetOverheadMaquinaPerHourCRC(techID)
-	return (rate.EngraveRateHour + overheadGlobal + overheadMaquina) / 60
+	return (c.ToBase(rate.EngraveRateHour, rate.Currency) + overheadGlobal + overheadMaquina) / 60
 }
 
 // GetCostPerMinCut returns cost per minute for cutting
-// Formula: (tarifa_operador + overhead_global + overhead_maquina) / 60
+// Formula: (tarifa_operador + overhead_global + overhead_maquina) / 60 — en moneda base
 func (c *PricingConfig) GetCostPerMinCut(techID uint) float64 {
 	rate := c.TechRates[techID]
 	if rate == nil {
@@ -203,7 +237,7 @@ func (c *PricingConfig) GetCostPerMinCut(techID uint) float64 {
 	}
 	overheadGlobal := c.GetOverheadGlobalPerHourCRC()
 	overheadMaquina := c.GetOverheadMaquinaPerHourCRC(techID)
-	return (rate.CutRateHour + overheadGlobal + overheadMaquina) / 60
+	return (c.ToBase(rate.CutRateHour, rate.Currency) + overheadGlobal + overheadMaquina) / 60
 }
 
 // GetMarginPercent returns the margin percentage for a technology
@@ -215,10 +249,10 @@ func (c *PricingConfig) GetMarginPercent(techID uint) float64 {
 	return c.GetSystemConfigFloat("default_margin_percent", 0.40)
 }
 
-// GetSetupFee returns the setup fee for a technology
+// GetSetupFee returns the setup fee for a technology in base currency
 func (c *PricingConfig) GetSetupFee(techID uint) float64 {
 	if rate := c.TechRates[techID]; rate != nil {
-		return rate.SetupFee
+		return c.ToBase(rate.SetupFee, rate.Currency)
 	}
 	return 0
 }
@@ -267,11 +301,11 @@ func (c *PricingConfig) GetHorasTrabajoMes() float64 {
 	return c.GetSystemConfigFloat("horas_trabajo_mes", 120)
 }
 
-// GetOverheadGlobalPerHourCRC returns shared taller overhead per hour in CRC
+// GetOverheadGlobalPerHourCRC returns shared taller overhead per hour in base currency
 // Includes: alquiler, internet — costs shared across all machines
 func (c *PricingConfig) GetOverheadGlobalPerHourCRC() float64 {
-	alquiler := c.GetSystemConfigFloat("overhead_alquiler", 0)
-	internet := c.GetSystemConfigFloat("overhead_internet", 0)
+	alquiler := c.GetSystemConfigMoney("overhead_alquiler", 0)
+	internet := c.GetSystemConfigMoney("overhead_internet", 0)
 	totalGlobal := alquiler + internet
 
 	horasMes := c.GetHorasTrabajoMes()
@@ -281,7 +315,7 @@ func (c *PricingConfig) GetOverheadGlobalPerHourCRC() float64 {
 	return totalGlobal / horasMes
 }
 
-// GetOverheadMaquinaPerHourCRC returns machine-specific overhead per hour in CRC
+// GetOverheadMaquinaPerHourCRC returns machine-specific overhead per hour in base currency
 // Includes: electricidad, mantenimiento, depreciación, seguro, consumibles
 func (c *PricingConfig) GetOverheadMaquinaPerHourCRC(techID uint) float64 {
 	rate := c.TechRates[techID]
@@ -289,11 +323,11 @@ func (c *PricingConfig) GetOverheadMaquinaPerHourCRC(techID uint) float64 {
 		return 0
 	}
 
-	totalMaquina := rate.ElectricidadMes +
-		rate.MantenimientoMes +
-		rate.DepreciacionMes +
-		rate.SeguroMes +
-		rate.ConsumiblesMes
+	totalMaquina := c.ToBase(rate.ElectricidadMes+
+		rate.MantenimientoMes+
+		rate.DepreciacionMes+
+		rate.SeguroMes+
+		rate.ConsumiblesMes, rate.Currency)
 
 	horasMes := c.GetHorasTrabajoMes()
 	if horasMes <= 0 {
@@ -334,6 +368,48 @@ func (c *PricingConfig) GetSystemConfigInt(key string, defaultVal int) int {
 	return defaultVal
 }
 
+// =============================================================
+// Currency Methods
+// Todo el cálculo ocurre en la moneda base; cada config monetaria
+// se convierte desde su propia moneda al cargarla.
+// =============================================================
+
+// GetBaseCurrency returns the currency every calculation is done in
+func (c *PricingConfig) GetBaseCurrency() string {
+	return models.NormalizeCurrency(c.GetSystemConfigString("base_currency"))
+}
+
+// GetExchangeRate returns CRC per 1 USD: latest exchange_rates row, else system_config fallback
+func (c *PricingConfig) GetExchangeRate() float64 {
+	if c.ExchangeRate != nil && c.ExchangeRate.Rate() > 0 {
+		return c.ExchangeRate.Rate()
+	}
+	return c.GetSystemConfigFloat("usd_crc_fallback_rate", 505.0)
+}
+
+// GetExchangeRateDate returns the date of the rate in use (nil = system_config fallback)
+func (c *PricingConfig) GetExchangeRateDate() *time.Time {
+	if c.ExchangeRate != nil {
+		d := c.ExchangeRate.RateDate
+		return &d
+	}
+	return nil
+}
+
+// ToBase converts an amount in the given currency to the base currency
+func (c *PricingConfig) ToBase(amount float64, currency string) float64 {
+	return models.ConvertCurrency(amount, currency, c.GetBaseCurrency(), c.GetExchangeRate())
+}
+
+// GetSystemConfigMoney returns a monetary system_config value converted to base currency
+func (c *PricingConfig) GetSystemConfigMoney(key string, defaultVal float64) float64 {
+	val := c.GetSystemConfigFloat(key, defaultVal)
+	if cfg := c.SystemConfigs[key]; cfg != nil && cfg.Currency != nil {
+		return c.ToBase(val, *cfg.Currency)
+	}
+	return val
+}
+
 // =============================================================
 // Base Speeds from System Config
 // =============================================================
@@ -373,14 +449,14 @@ func (c *PricingConfig) GetQuoteValidityDays() int {
 	return c.GetSystemConfigInt("quote_validity_days", 7)
 }
 
-// GetMinValueBase returns minimum value base price in CRC
+// GetMinValueBase returns minimum value base price in base currency
 func (c *PricingConfig) GetMinValueBase() float64 {
-	return c.GetSystemConfigFloat("min_value_base", 2575.0)
+	return c.GetSystemConfigMoney("min_value_base", 2575.0)
 }
 
-// GetPricePerMM2 returns price per mm² in CRC
+// GetPricePerMM2 returns price per mm² in base currency
 func (c *PricingConfig) GetPricePerMM2() float64 {
-	return c.GetSystemConfigFloat("price_per_mm2", 0.515)
+	return c.GetSystemConfigMoney("price_per_mm2", 0.515)
 }
 
 // GetMinAreaMM2 returns minimum area for pricing
@@ -390,7 +466,7 @@ func (c *PricingConfig) GetMinAreaMM2() float64 {
 
 // GetPricePerMmCut returns price per mm of cut for cut-only Value-Based pricing
 func (c *PricingConfig) GetPricePerMmCut() float64 {
-	return c.GetSystemConfigFloat("price_per_mm_cut", 0.25)
+	return c.GetSystemConfigMoney("price_per_mm_cut", 0.25)
 }
 
 // =============================================================
@@ -454,13 +530,13 @@ type MaterialCostResult struct {
 	Found      bool
 }
 
-// GetMaterialCost returns the material cost for a material/thickness combination
+// GetMaterialCost returns the material cost for a material/thickness combination in base currency
 // Returns zero cost if no specific configuration exists (client provides material)
 func (c *PricingConfig) GetMaterialCost(materialID uint, thickness float64) MaterialCostResult {
 	for _, mc := range c.MaterialCosts {
 		if mc.MaterialID == materialID && mc.Thickness == thickness {
 			return MaterialCostResult{
-				CostPerMm2: mc.CostPerMm2,
+				CostPerMm2: c.ToBase(mc.CostPerMm2, mc.Currency),
 				WastePct:   mc.WastePct,
 				Found:      true,
 			}
@@ -471,7 +547,7 @@ func (c *PricingConfig) GetMaterialCost(materialID uint, thickness float64) Mate
 		for _, mc := range c.MaterialCosts {
 			if mc.MaterialID == materialID && mc.Thickness == 0 {
 				return MaterialCostResult{
-					CostPerMm2: mc.CostPerMm2,
+					CostPerMm2: c.ToBase(mc.CostPerMm2, mc.Currency),
 					WastePct:   mc.WastePct,
 					Found:      true,
 				}
@@ -0,0 +1,352 @@
+package pricing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+)
+
+// Operation is what the customer wants done to the material
+type Operation string
+
+const (
+	OpEngrave Operation = "grabado"
+	OpCut     Operation = "corte"
+	OpBoth    Operation = "grabado_corte"
+)
+
+var (
+	ErrRecommendMaterial  = errors.New("material no encontrado o inactivo")
+	ErrRecommendOperation = errors.New("operación inválida: usar grabado, corte o grabado_corte")
+)
+
+// ParseOperation accepts the API/tool values ("grabado", "corte", "grabado_corte")
+// plus the English aliases engrave/cut/both
+func ParseOperation(s string) (Operation, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "grabado", "engrave":
+		return OpEngrave, nil
+	case "corte", "cut":
+		return OpCut, nil
+	case "grabado_corte", "ambos", "both":
+		return OpBoth, nil
+	}
+	return "", ErrRecommendOperation
+}
+
+// RecommendInput describes the job to recommend technologies for
+type RecommendInput struct {
+	MaterialID  uint
+	Thickness   float64 // mm; 0 = not known yet
+	Operation   Operation
+	ColorFinish bool // colored finish to respect or produce (anodized aluminum, coated tumbler)
+}
+
+// TechRef identifies a technology in a plan
+type TechRef struct {
+	ID   uint   `json:"id"`
+	Code string `json:"code"`
+	Name string `json:"name"`
+}
+
+// TechPlan is one valid way to do the job. Engrave is nil for cut-only jobs;
+// Cut is nil when there is nothing to cut. IgnoreCut means the customer asked
+// for a cut the material does not allow, so only the engraving is quoted.
+type TechPlan struct {
+	Engrave   *TechRef `json:"engrave,omitempty"`
+	Cut       *TechRef `json:"cut,omitempty"`
+	IgnoreCut bool     `json:"ignore_cut"`
+	Reasons   []string `json:"reasons"`
+}
+
+// QuoteArgs maps the plan to the technology arguments of calcular_cotizacion
+func (p TechPlan) QuoteArgs() map[string]any {
+	args := map[string]any{"incluye_corte": p.Cut != nil}
+	switch {
+	case p.Engrave != nil:
+		args["technology_id"] = p.Engrave.ID
+		if p.Cut != nil && p.Cut.ID != p.Engrave.ID {
+			args["cut_technology_id"] = p.Cut.ID
+		}
+	case p.Cut != nil:
+		args["technology_id"] = p.Cut.ID
+	}
+	return args
+}
+
+// Recommendation is the ranked list of plans for a job (best first). An empty
+// Plans list means the job cannot be done as asked; Notes explain why.
+type Recommendation struct {
+	MaterialID     uint       `json:"material_id"`
+	Material       string     `json:"material"`
+	Operation      Operation  `json:"operation"`
+	Thickness      float64    `json:"thickness"`
+	Cuttable       bool       `json:"cuttable"`
+	CutThicknesses []float64  `json:"cut_thicknesses"` // valid thicknesses for cutting (mm)
+	NeedsThickness bool       `json:"needs_thickness"` // there is a cut and the thickness is still unknown
+	Plans          []TechPlan `json:"plans"`
+	Notes          []string   `json:"notes,omitempty"`
+}
+
+// Recommender picks the laser technologies for a material from the
+// tech_material_speeds matrix (compatibility, cut speeds per thickness,
+// engrave preference) and Material.IsCuttable — the rules the agents'
+// prompts used to spell out by hand.
+type Recommender struct {
+	configLoader *ConfigLoader
+	ctx          context.Context // tenant whose config is used (nil = default tenant)
+}
+
+// NewRecommender creates a recommender with the given config loader
+func NewRecommender(configLoader *ConfigLoader) *Recommender {
+	return &Recommender{configLoader: configLoader}
+}
+
+// WithContext returns a recommender that uses the config of the tenant in ctx
+func (r *Recommender) WithContext(ctx context.Context) *Recommender {
+	return &Recommender{configLoader: r.configLoader, ctx: ctx}
+}
+
+// Recommend returns the valid technology plans for the job, best first
+func (r *Recommender) Recommend(in RecommendInput) (*Recommendation, error) {
+	config, err := r.configLoader.LoadContext(r.ctx)
+	if err != nil {
+		return nil, err
+	}
+	return recommend(config, in)
+}
+
+// techCapability is what one technology can do with the material
+type techCapability struct {
+	ref            TechRef
+	rank           int
+	colorMarking   bool
+	engraves       bool
+	cutThicknesses []float64
+	note           string
+}
+
+func (t *techCapability) cutsAt(thickness float64) bool {
+	if thickness == 0 {
+		return len(t.cutThicknesses) > 0
+	}
+	return slices.Contains(t.cutThicknesses, thickness)
+}
+
+func recommend(config *PricingConfig, in RecommendInput) (*Recommendation, error) {
+	material := config.GetMaterial(in.MaterialID)
+	if material == nil {
+		return nil, ErrRecommendMaterial
+	}
+	switch in.Operation {
+	case OpEngrave, OpCut, OpBoth:
+	default:
+		return nil, ErrRecommendOperation
+	}
+
+	rec := &Recommendation{
+		MaterialID:     material.ID,
+		Material:       material.Name,
+		Operation:      in.Operation,
+		Thickness:      in.Thickness,
+		Cuttable:       material.IsCuttable,
+		CutThicknesses: []float64{},
+		Plans:          []TechPlan{},
+	}
+
+	caps := capabilities(config, material, in.Thickness)
+	var engravers, cutters []*techCapability
+	for _, c := range caps {
+		if c.engraves {
+			engravers = append(engravers, c)
+		}
+		if material.IsCuttable && len(c.cutThicknesses) > 0 {
+			cutters = append(cutters, c)
+			for _, t := range c.cutThicknesses {
+				if !slices.Contains(rec.CutThicknesses, t) {
+					rec.CutThicknesses = append(rec.CutThicknesses, t)
+				}
+			}
+		}
+	}
+	sort.Float64s(rec.CutThicknesses)
+	engravers = rankEngravers(engravers, in.ColorFinish, rec)
+
+	wantsCut := in.Operation == OpCut || in.Operation == OpBoth
+	if wantsCut && len(cutters) > 0 {
+		if in.Thickness == 0 {
+			rec.NeedsThickness = true
+			rec.Notes = append(rec.Notes, fmt.Sprintf("Preguntar el grosor: %s se corta en %s.", material.Name, formatThicknesses(rec.CutThicknesses)))
+		} else if !slices.Contains(rec.CutThicknesses, in.Thickness) {
+			rec.Notes = append(rec.Notes, fmt.Sprintf("No cortamos %s de %s mm. Grosores válidos para corte: %s.",
+				material.Name, formatMM(in.Thickness), formatThicknesses(rec.CutThicknesses)))
+			return rec, nil
+		}
+	}
+
+	switch {
+	case in.Operation == OpCut && len(cutters) == 0:
+		rec.Notes = append(rec.Notes, fmt.Sprintf("%s no se corta con láser; solo se puede grabar.", material.Name))
+
+	case in.Operation == OpCut:
+		for _, c := range cutters {
+			if !c.cutsAt(in.Thickness) {
+				continue
+			}
+			rec.Plans = append(rec.Plans, TechPlan{
+				Cut:     &c.ref,
+				Reasons: []string{cutReason(c, material, in.Thickness)},
+			})
+		}
+
+	case in.Operation == OpEngrave || len(cutters) == 0:
+		ignoreCut := in.Operation == OpBoth
+		if ignoreCut {
+			rec.Notes = append(rec.Notes, fmt.Sprintf("%s no se corta con láser: se cotiza solo el grabado.", material.Name))
+		}
+		for i, e := range engravers {
+			rec.Plans = append(rec.Plans, TechPlan{
+				Engrave:   &e.ref,
+				IgnoreCut: ignoreCut,
+				Reasons:   engraveReasons(e, material, i == 0),
+			})
+		}
+
+	default: // grabado + corte en material cortable
+		for i, e := range engravers {
+			reasons := engraveReasons(e, material, i == 0)
+			if e.cutsAt(in.Thickness) {
+				reasons = append(reasons, fmt.Sprintf("%s graba y corta: una sola máquina.", e.ref.Code))
+				rec.Plans = append(rec.Plans, TechPlan{Engrave: &e.ref, Cut: &e.ref, Reasons: reasons})
+				continue
+			}
+			for _, c := range cutters {
+				if !c.cutsAt(in.Thickness) {
+					continue
+				}
+				reasons = append(reasons, fmt.Sprintf("%s graba y %s corta (dos máquinas, proceso premium).", e.ref.Code, c.ref.Code), cutReason(c, material, in.Thickness))
+				rec.Plans = append(rec.Plans, TechPlan{Engrave: &e.ref, Cut: &c.ref, Reasons: reasons})
+				break
+			}
+		}
+	}
+
+	if len(rec.Plans) == 0 && len(rec.Notes) == 0 {
+		rec.Notes = append(rec.Notes, fmt.Sprintf("Ninguna tecnología activa trabaja %s así; revisar la matriz de velocidades.", material.Name))
+	}
+	return rec, nil
+}
+
+// capabilities groups the active matrix rows of the material by technology.
+// Rows marked incompatible (at the thickness or generic) exclude the technology.
+func capabilities(config *PricingConfig, material *models.Material, thickness float64) []*techCapability {
+	byTech := map[uint]*techCapability{}
+	var order []uint
+	for _, s := range config.TechMaterialSpeeds {
+		if s.MaterialID != material.ID || !s.IsCompatible {
+			continue
+		}
+		tech := config.GetTechnology(s.TechnologyID)
+		if tech == nil {
+			continue
+		}
+		if ok, _ := config.IsCompatible(s.TechnologyID, material.ID, thickness); !ok {
+			continue
+		}
+		c, exists := byTech[tech.ID]
+		if !exists {
+			c = &techCapability{ref: TechRef{ID: tech.ID, Code: tech.Code, Name: tech.Name}, rank: s.EngraveRank}
+			byTech[tech.ID] = c
+			order = append(order, tech.ID)
+		}
+		if s.EngraveRank < c.rank {
+			c.rank = s.EngraveRank
+		}
+		c.colorMarking = c.colorMarking || s.ColorMarking
+		if positive(s.EngraveSpeedMmMin) || positive(s.RasterSpeedMm2Min) {
+			c.engraves = true
+		}
+		if positive(s.CutSpeedMmMin) && s.Thickness > 0 && !slices.Contains(c.cutThicknesses, s.Thickness) {
+			c.cutThicknesses = append(c.cutThicknesses, s.Thickness)
+		}
+		if c.note == "" && s.Notes != nil && (s.Thickness == 0 || s.Thickness == thickness) {
+			c.note = strings.TrimSpace(*s.Notes)
+		}
+	}
+
+	caps := make([]*techCapability, 0, len(order))
+	for _, id := range order {
+		sort.Float64s(byTech[id].cutThicknesses)
+		caps = append(caps, byTech[id])
+	}
+	sort.SliceStable(caps, func(a, b int) bool { return caps[a].ref.ID < caps[b].ref.ID })
+	return caps
+}
+
+// rankEngravers orders by preference; with a colored finish, only the
+// technologies that respect it remain (if the material has any)
+func rankEngravers(engravers []*techCapability, colorFinish bool, rec *Recommendation) []*techCapability {
+	if colorFinish {
+		var color []*techCapability
+		for _, e := range engravers {
+			if e.colorMarking {
+				color = append(color, e)
+			}
+		}
+		if len(color) > 0 {
+			engravers = color
+		} else if len(engravers) > 0 {
+			rec.Notes = append(rec.Notes, "El acabado de color no cambia la tecnología para este material.")
+		}
+	}
+	sort.SliceStable(engravers, func(a, b int) bool {
+		if engravers[a].rank != engravers[b].rank {
+			return engravers[a].rank < engravers[b].rank
+		}
+		return engravers[a].ref.ID < engravers[b].ref.ID
+	})
+	return engravers
+}
+
+func engraveReasons(c *techCapability, material *models.Material, first bool) []string {
+	reason := fmt.Sprintf("%s graba %s.", c.ref.Code, material.Name)
+	if first {
+		reason = fmt.Sprintf("%s es la primera opción para grabar %s.", c.ref.Code, material.Name)
+	}
+	if c.colorMarking {
+		reason += " Respeta acabados de color."
+	}
+	reasons := []string{reason}
+	if c.note != "" {
+		reasons = append(reasons, c.note)
+	}
+	return reasons
+}
+
+func cutReason(c *techCapability, material *models.Material, thickness float64) string {
+	if thickness > 0 {
+		return fmt.Sprintf("%s corta %s de %s mm.", c.ref.Code, material.Name, formatMM(thickness))
+	}
+	return fmt.Sprintf("%s corta %s en %s.", c.ref.Code, material.Name, formatThicknesses(c.cutThicknesses))
+}
+
+func positive(v *float64) bool {
+	return v != nil && *v > 0
+}
+
+func formatMM(v float64) string {
+	return strings.TrimSuffix(strings.TrimRight(fmt.Sprintf("%.2f", v), "0"), ".")
+}
+
+func formatThicknesses(ts []float64) string {
+	parts := make([]string, len(ts))
+	for i, t := range ts {
+		parts[i] = formatMM(t)
+	}
+	return strings.Join(parts, ", ") + " mm"
+}
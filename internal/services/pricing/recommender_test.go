@@ -0,0 +1,183 @@
+package pricing
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+)
+
+func testMatrix() *PricingConfig {
+	speed := func(v float64) *float64 { return &v }
+	note := func(s string) *string { return &s }
+	return &PricingConfig{
+		Technologies: map[uint]*models.Technology{
+			1: {ID: 1, Code: "CO2", Name: "Láser CO2"},
+			2: {ID: 2, Code: "UV", Name: "Láser UV"},
+			3: {ID: 3, Code: "FIBRA", Name: "Láser de Fibra"},
+			4: {ID: 4, Code: "MOPA", Name: "Láser MOPA"},
+		},
+		Materials: map[uint]*models.Material{
+			1:  {ID: 1, Name: "Madera / MDF", IsCuttable: true},
+			2:  {ID: 2, Name: "Acrílico transparente", IsCuttable: true},
+			7:  {ID: 7, Name: "Vidrio / Cristal"},
+			20: {ID: 20, Name: "Aluminio"},
+		},
+		TechMaterialSpeeds: []models.TechMaterialSpeed{
+			{TechnologyID: 1, MaterialID: 1, Thickness: 3, CutSpeedMmMin: speed(30), EngraveSpeedMmMin: speed(600), IsCompatible: true, EngraveRank: 1},
+			{TechnologyID: 1, MaterialID: 1, Thickness: 6, CutSpeedMmMin: speed(15), EngraveSpeedMmMin: speed(600), IsCompatible: true, EngraveRank: 1},
+
+			{TechnologyID: 1, MaterialID: 2, Thickness: 3, CutSpeedMmMin: speed(20), EngraveSpeedMmMin: speed(500), IsCompatible: true, EngraveRank: 2},
+			{TechnologyID: 1, MaterialID: 2, Thickness: 5, CutSpeedMmMin: speed(12), EngraveSpeedMmMin: speed(500), IsCompatible: true, EngraveRank: 2},
+			{TechnologyID: 2, MaterialID: 2, Thickness: 0, EngraveSpeedMmMin: speed(800), IsCompatible: true, EngraveRank: 1},
+
+			{TechnologyID: 1, MaterialID: 7, Thickness: 0, IsCompatible: false, Notes: note("CO2 fractura el vidrio")},
+			{TechnologyID: 2, MaterialID: 7, Thickness: 0, EngraveSpeedMmMin: speed(700), IsCompatible: true, EngraveRank: 1},
+
+			{TechnologyID: 3, MaterialID: 20, Thickness: 0, EngraveSpeedMmMin: speed(4000), IsCompatible: true, EngraveRank: 1},
+			{TechnologyID: 4, MaterialID: 20, Thickness: 0, EngraveSpeedMmMin: speed(5000), IsCompatible: true, EngraveRank: 2, ColorMarking: true,
+				Notes: note("MOPA: colores y contraste en anodizado")},
+		},
+	}
+}
+
+// planCodes resume un plan como "grabado/corte" (p. ej. "UV/CO2", "FIBRA/-")
+func planCodes(p TechPlan) string {
+	code := func(t *TechRef) string {
+		if t == nil {
+			return "-"
+		}
+		return t.Code
+	}
+	return code(p.Engrave) + "/" + code(p.Cut)
+}
+
+func TestRecommend(t *testing.T) {
+	cfg := testMatrix()
+	cases := []struct {
+		name  string
+		in    RecommendInput
+		plans []string
+		check func(t *testing.T, rec *Recommendation)
+	}{
+		{
+			name:  "solo corte en madera",
+			in:    RecommendInput{MaterialID: 1, Thickness: 6, Operation: OpCut},
+			plans: []string{"-/CO2"},
+		},
+		{
+			name:  "grabado en acrílico: UV primero",
+			in:    RecommendInput{MaterialID: 2, Operation: OpEngrave},
+			plans: []string{"UV/-", "CO2/-"},
+		},
+		{
+			name:  "grabado + corte en madera: CO2 hace todo",
+			in:    RecommendInput{MaterialID: 1, Thickness: 3, Operation: OpBoth},
+			plans: []string{"CO2/CO2"},
+		},
+		{
+			name:  "grabado + corte en acrílico: UV graba, CO2 corta",
+			in:    RecommendInput{MaterialID: 2, Thickness: 5, Operation: OpBoth},
+			plans: []string{"UV/CO2", "CO2/CO2"},
+			check: func(t *testing.T, rec *Recommendation) {
+				args := rec.Plans[0].QuoteArgs()
+				if args["technology_id"] != uint(2) || args["cut_technology_id"] != uint(1) || args["incluye_corte"] != true {
+					t.Errorf("quote args = %v", args)
+				}
+				if _, ok := rec.Plans[1].QuoteArgs()["cut_technology_id"]; ok {
+					t.Error("CO2 solo no lleva cut_technology_id")
+				}
+			},
+		},
+		{
+			name:  "corte sin grosor: pide grosor",
+			in:    RecommendInput{MaterialID: 2, Operation: OpBoth},
+			plans: []string{"UV/CO2", "CO2/CO2"},
+			check: func(t *testing.T, rec *Recommendation) {
+				if !rec.NeedsThickness || len(rec.CutThicknesses) != 2 {
+					t.Errorf("rec = %+v", rec)
+				}
+			},
+		},
+		{
+			name:  "grosor sin corte",
+			in:    RecommendInput{MaterialID: 2, Thickness: 8, Operation: OpBoth},
+			plans: nil,
+			check: func(t *testing.T, rec *Recommendation) {
+				if len(rec.Notes) != 1 || rec.Notes[0] != "No cortamos Acrílico transparente de 8 mm. Grosores válidos para corte: 3, 5 mm." {
+					t.Errorf("notes = %q", rec.Notes)
+				}
+			},
+		},
+		{
+			name:  "vidrio no cortable: se ignora el corte",
+			in:    RecommendInput{MaterialID: 7, Thickness: 4, Operation: OpBoth},
+			plans: []string{"UV/-"},
+			check: func(t *testing.T, rec *Recommendation) {
+				if !rec.Plans[0].IgnoreCut {
+					t.Error("IgnoreCut = false")
+				}
+			},
+		},
+		{
+			name:  "vidrio solo corte: sin planes",
+			in:    RecommendInput{MaterialID: 7, Operation: OpCut},
+			plans: nil,
+		},
+		{
+			name:  "aluminio sin color: Fibra primero",
+			in:    RecommendInput{MaterialID: 20, Operation: OpEngrave},
+			plans: []string{"FIBRA/-", "MOPA/-"},
+		},
+		{
+			name:  "aluminio anodizado: solo MOPA",
+			in:    RecommendInput{MaterialID: 20, Operation: OpEngrave, ColorFinish: true},
+			plans: []string{"MOPA/-"},
+			check: func(t *testing.T, rec *Recommendation) {
+				if len(rec.Plans[0].Reasons) != 2 || rec.Plans[0].Reasons[1] != "MOPA: colores y contraste en anodizado" {
+					t.Errorf("reasons = %q", rec.Plans[0].Reasons)
+				}
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec, err := recommend(cfg, tc.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, p := range rec.Plans {
+				got = append(got, planCodes(p))
+			}
+			if len(got) != len(tc.plans) {
+				t.Fatalf("plans = %v, want %v (notes %q)", got, tc.plans, rec.Notes)
+			}
+			for i := range got {
+				if got[i] != tc.plans[i] {
+					t.Fatalf("plans = %v, want %v", got, tc.plans)
+				}
+			}
+			if len(rec.Plans) == 0 && len(rec.Notes) == 0 {
+				t.Error("sin planes ni explicación")
+			}
+			if tc.check != nil {
+				tc.check(t, rec)
+			}
+		})
+	}
+}
+
+func TestRecommendErrors(t *testing.T) {
+	cfg := testMatrix()
+	if _, err := recommend(cfg, RecommendInput{MaterialID: 99, Operation: OpCut}); !errors.Is(err, ErrRecommendMaterial) {
+		t.Errorf("material: %v", err)
+	}
+	if _, err := recommend(cfg, RecommendInput{MaterialID: 1, Operation: "pintar"}); !errors.Is(err, ErrRecommendOperation) {
+		t.Errorf("operación: %v", err)
+	}
+	if op, err := ParseOperation(" Ambos "); err != nil || op != OpBoth {
+		t.Errorf("ParseOperation = %q, %v", op, err)
+	}
+}
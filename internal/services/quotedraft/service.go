@@ -0,0 +1,333 @@
+// Package quotedraft arma borradores de cotización a partir de las conversaciones
+// del bot. Los parámetros del trabajo llegan ya estructurados en los argumentos de
+// las tools del agente (calcular_cotizacion, escalar_a_humano): el modelo los
+// extrae del chat al llamarlas. El borrador queda en quotes con status draft y
+// source bot, ligado al lead, para que el admin lo ajuste y apruebe.
+package quotedraft
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/channels"
+	"github.com/alonsoalpizar/fabricalaser/internal/database"
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+	"github.com/alonsoalpizar/fabricalaser/internal/repository"
+	"github.com/alonsoalpizar/fabricalaser/internal/services/pricing"
+	"github.com/alonsoalpizar/fabricalaser/internal/services/tax"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrIncomplete   = errors.New("faltan datos para calcular el precio: medidas, material y tecnología")
+	ErrIncompatible = errors.New("la tecnología no puede trabajar ese material")
+	ErrNotDraft     = errors.New("solo se pueden ajustar borradores del bot")
+	ErrUserNotFound = errors.New("cliente no encontrado")
+)
+
+const (
+	defaultThickness = 3.0
+	defaultEngrave   = 1 // Vectorial
+)
+
+// Params son los datos del trabajo extraídos de la conversación. Los campos en
+// cero no se conocen: al actualizar un borrador no pisan lo que ya tenía.
+type Params struct {
+	AnchoCM          float64 `json:"ancho_cm,omitempty"`
+	AltoCM           float64 `json:"alto_cm,omitempty"`
+	Cantidad         int     `json:"cantidad,omitempty"`
+	TechnologyID     uint    `json:"technology_id,omitempty"`
+	MaterialID       uint    `json:"material_id,omitempty"`
+	EngraveTypeID    uint    `json:"engrave_type_id,omitempty"`
+	Thickness        float64 `json:"thickness,omitempty"`
+	MaterialIncluded *bool   `json:"material_included,omitempty"`
+	IncluyeCorte     *bool   `json:"incluye_corte,omitempty"`
+	CutTechnologyID  *uint   `json:"cut_technology_id,omitempty"`
+	Moneda           string  `json:"moneda,omitempty"`
+	BlankID          uint    `json:"blank_id,omitempty"`
+	BlankCategoria   string  `json:"blank_categoria,omitempty"`
+	Descripcion      string  `json:"descripcion,omitempty"` // Qué quiere el cliente, en sus palabras
+	Resumen          string  `json:"resumen,omitempty"`     // Resumen del escalado
+}
+
+// Merge completa p con los datos conocidos de next
+func (p Params) Merge(next Params) Params {
+	if next.AnchoCM > 0 {
+		p.AnchoCM = next.AnchoCM
+	}
+	if next.AltoCM > 0 {
+		p.AltoCM = next.AltoCM
+	}
+	if next.Cantidad > 0 {
+		p.Cantidad = next.Cantidad
+	}
+	if next.TechnologyID > 0 {
+		p.TechnologyID = next.TechnologyID
+	}
+	if next.MaterialID > 0 {
+		p.MaterialID = next.MaterialID
+	}
+	if next.EngraveTypeID > 0 {
+		p.EngraveTypeID = next.EngraveTypeID
+	}
+	if next.Thickness > 0 {
+		p.Thickness = next.Thickness
+	}
+	if next.MaterialIncluded != nil {
+		p.MaterialIncluded = next.MaterialIncluded
+	}
+	if next.IncluyeCorte != nil {
+		p.IncluyeCorte = next.IncluyeCorte
+	}
+	if next.CutTechnologyID != nil {
+		p.CutTechnologyID = next.CutTechnologyID
+	}
+	if next.Moneda != "" {
+		p.Moneda = next.Moneda
+	}
+	if next.BlankID > 0 {
+		p.BlankID = next.BlankID
+	}
+	if next.BlankCategoria != "" {
+		p.BlankCategoria = next.BlankCategoria
+	}
+	if next.Descripcion != "" {
+		p.Descripcion = next.Descripcion
+	}
+	if next.Resumen != "" {
+		p.Resumen = next.Resumen
+	}
+	return p
+}
+
+// Priceable indica si alcanzan los datos para correr el Calculator
+func (p Params) Priceable() bool {
+	return p.AnchoCM > 0 && p.AltoCM > 0 && p.TechnologyID > 0 && p.MaterialID > 0
+}
+
+// ParamsFromArgs lee los argumentos de una tool del agente (mismos nombres que Params)
+func ParamsFromArgs(args map[string]any) (Params, error) {
+	var p Params
+	raw, err := json.Marshal(args)
+	if err != nil {
+		return p, err
+	}
+	err = json.Unmarshal(raw, &p)
+	return p, err
+}
+
+type Service struct {
+	quoteRepo    *repository.QuoteRepository
+	leadRepo     *repository.LeadRepository
+	userRepo     *repository.UserRepository
+	calculator   *pricing.Calculator
+	configLoader *pricing.ConfigLoader
+}
+
+func NewService() *Service {
+	configLoader := pricing.NewConfigLoader(database.Get())
+	return &Service{
+		quoteRepo:    repository.NewQuoteRepository(),
+		leadRepo:     repository.NewLeadRepository(),
+		userRepo:     repository.NewUserRepository(),
+		calculator:   pricing.NewCalculator(configLoader),
+		configLoader: configLoader,
+	}
+}
+
+// FromConversation crea o actualiza el borrador abierto del lead de la
+// conversación. Hay un borrador por lead: cada precio nuevo lo reemplaza, así el
+// asesor ve lo último que se conversó. Sin un borrador previo y sin datos para
+// calcular el precio retorna ErrIncomplete.
+func (s *Service) FromConversation(ctx context.Context, from channels.Identity, p Params) (*models.Quote, error) {
+	lead, err := s.leadRepo.FindByKey(from.Key)
+	if err != nil {
+		return nil, fmt.Errorf("quotedraft: lead de la conversación: %w", err)
+	}
+
+	draft, err := s.quoteRepo.FindDraftByLead(lead.ID)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		draft = nil
+	case err != nil:
+		return nil, fmt.Errorf("quotedraft: error buscando borrador: %w", err)
+	}
+
+	var userID uint
+	if lead.UserID != nil {
+		userID = *lead.UserID
+	}
+	if draft != nil {
+		p = paramsOf(draft).Merge(p)
+		if draft.UserID != 0 {
+			userID = draft.UserID
+		}
+	}
+
+	quote, err := s.build(draft, p, userID)
+	switch {
+	case errors.Is(err, ErrIncomplete) && draft != nil:
+		// Ya hay precio: solo se actualizan los datos extraídos (p.ej. el resumen)
+		quote = draft
+		if err := setParams(quote, p); err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, err
+	}
+	quote.LeadID = &lead.ID
+
+	if quote.ID == 0 {
+		err = s.quoteRepo.Create(quote)
+	} else {
+		err = s.quoteRepo.Update(quote)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("quotedraft: error guardando borrador: %w", err)
+	}
+
+	slog.Info("quotedraft: borrador actualizado",
+		"quote_id", quote.ID,
+		"lead_id", lead.ID,
+		"canal", from.Channel,
+		"precio", quote.PriceFinal,
+	)
+	return quote, nil
+}
+
+// Adjust aplica los cambios del admin a un borrador del bot y recalcula el precio.
+// userID asigna la cuenta del cliente (necesaria para aprobar).
+func (s *Service) Adjust(id uint, p Params, userID *uint) (*models.Quote, error) {
+	draft, err := s.quoteRepo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if draft.Source != models.QuoteSourceBot || draft.Status != models.QuoteStatusDraft {
+		return nil, ErrNotDraft
+	}
+
+	owner := draft.UserID
+	if userID != nil {
+		if _, err := s.userRepo.FindByID(*userID); err != nil {
+			return nil, ErrUserNotFound
+		}
+		owner = *userID
+	}
+
+	quote, err := s.build(draft, paramsOf(draft).Merge(p), owner)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.quoteRepo.Update(quote); err != nil {
+		return nil, fmt.Errorf("quotedraft: error guardando borrador: %w", err)
+	}
+	return s.quoteRepo.FindByIDWithRelations(quote.ID)
+}
+
+// ForCustomer crea una cotización para un cliente registrado con los datos que dio
+// el gestor (asistente admin). A diferencia del borrador del bot conserva el
+// estado que decide el Calculator (auto_approved / needs_review).
+func (s *Service) ForCustomer(userID uint, p Params) (*models.Quote, error) {
+	if _, err := s.userRepo.FindByID(userID); err != nil {
+		return nil, ErrUserNotFound
+	}
+	quote, err := s.price(p, userID)
+	if err != nil {
+		return nil, err
+	}
+	quote.Source = models.QuoteSourceAdmin
+	if err := setParams(quote, p); err != nil {
+		return nil, err
+	}
+	if err := s.quoteRepo.Create(quote); err != nil {
+		return nil, fmt.Errorf("quotedraft: error guardando cotización: %w", err)
+	}
+	return s.quoteRepo.FindByIDWithRelations(quote.ID)
+}
+
+// build arma el borrador del bot con el precio de p. Conserva la identidad del
+// borrador previo.
+func (s *Service) build(prev *models.Quote, p Params, userID uint) (*models.Quote, error) {
+	quote, err := s.price(p, userID)
+	if err != nil {
+		return nil, err
+	}
+	quote.Status = models.QuoteStatusDraft
+	quote.Source = models.QuoteSourceBot
+	if prev != nil {
+		quote.ID = prev.ID
+		quote.CreatedAt = prev.CreatedAt
+		quote.LeadID = prev.LeadID
+		quote.ReviewNotes = prev.ReviewNotes
+	}
+	if err := setParams(quote, p); err != nil {
+		return nil, err
+	}
+	return quote, nil
+}
+
+// price calcula el precio con el mismo Calculator del cotizador web sobre un
+// análisis sintético de las medidas, con el IVA del cliente.
+func (s *Service) price(p Params, userID uint) (*models.Quote, error) {
+	if !p.Priceable() {
+		return nil, ErrIncomplete
+	}
+	config, err := s.configLoader.Load()
+	if err != nil {
+		return nil, fmt.Errorf("quotedraft: error cargando configuración: %w", err)
+	}
+
+	thickness := p.Thickness
+	if thickness <= 0 {
+		thickness = defaultThickness
+	}
+	quantity := max(p.Cantidad, 1)
+	incluyeCorte := p.IncluyeCorte != nil && *p.IncluyeCorte
+	materialIncluded := p.MaterialIncluded == nil || *p.MaterialIncluded
+	engraveTypeID := p.EngraveTypeID
+	if engraveTypeID == 0 && !incluyeCorte {
+		engraveTypeID = defaultEngrave
+	}
+
+	if ok, _ := config.IsCompatible(p.TechnologyID, p.MaterialID, thickness); !ok {
+		return nil, ErrIncompatible
+	}
+
+	analysis := pricing.BuildSyntheticAnalysis(p.AltoCM*10, p.AnchoCM*10, incluyeCorte, engraveTypeID)
+	result, err := s.calculator.Calculate(analysis, p.TechnologyID, p.MaterialID, engraveTypeID,
+		thickness, quantity, materialIncluded, p.CutTechnologyID, false)
+	if err != nil {
+		return nil, fmt.Errorf("quotedraft: error calculando precio: %w", err)
+	}
+
+	quote := s.calculator.ToQuoteModel(result, userID, 0, p.TechnologyID, p.MaterialID, engraveTypeID,
+		quantity, thickness, p.CutTechnologyID, false, models.NormalizeCurrency(p.Moneda))
+	var user *models.User
+	if userID != 0 {
+		user, _ = s.userRepo.FindByID(userID)
+	}
+	tax.ApplyToQuote(quote, config, user)
+	return quote, nil
+}
+
+func paramsOf(q *models.Quote) Params {
+	var p Params
+	if len(q.RequestParams) > 0 {
+		_ = json.Unmarshal(q.RequestParams, &p)
+	}
+	return p
+}
+
+func setParams(q *models.Quote, p Params) error {
+	p.Moneda = strings.ToUpper(p.Moneda)
+	raw, err := json.Marshal(p)
+	if err != nil {
+		return fmt.Errorf("quotedraft: error serializando parámetros: %w", err)
+	}
+	q.RequestParams = raw
+	return nil
+}
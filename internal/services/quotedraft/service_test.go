@@ -0,0 +1,35 @@
+package quotedraft
+
+import "testing"
+
+func TestParamsFromArgsAndMerge(t *testing.T) {
+	// Así llegan los argumentos de calcular_cotizacion desde el function calling
+	calc, err := ParamsFromArgs(map[string]any{
+		"ancho_cm":          5.0,
+		"alto_cm":           5.0,
+		"cantidad":          30.0,
+		"technology_id":     1.0,
+		"material_id":       2.0,
+		"material_included": true,
+		"incluye_corte":     true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !calc.Priceable() || calc.Cantidad != 30 || calc.IncluyeCorte == nil || !*calc.IncluyeCorte {
+		t.Fatalf("params = %+v", calc)
+	}
+
+	// El escalado trae el resumen y una cantidad nueva; lo que no trae se conserva
+	esc, err := ParamsFromArgs(map[string]any{"resumen": "30 llaveros de MDF con logo", "cantidad": 50.0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if esc.Priceable() {
+		t.Error("el escalado solo no alcanza para calcular precio")
+	}
+	merged := calc.Merge(esc)
+	if !merged.Priceable() || merged.Cantidad != 50 || merged.MaterialID != 2 || merged.Resumen == "" {
+		t.Errorf("merged = %+v", merged)
+	}
+}
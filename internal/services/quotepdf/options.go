@@ -0,0 +1,119 @@
+package quotepdf
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/config"
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+	"github.com/alonsoalpizar/fabricalaser/internal/services/pricing"
+)
+
+const (
+	// defaultLogoPath es el logo oficial servido por el sitio (PNG con transparencia)
+	defaultLogoPath = "/opt/FabricaLaser/web/logo.png"
+	// defaultQuoteURL apunta al cotizador; %d = ID de la cotización
+	defaultQuoteURL = "https://fabricalaser.com/cotizar/?cotizacion=%d"
+)
+
+// defaultTerms se usan cuando system_config.cotizacion_terminos está vacío
+var defaultTerms = []string{
+	"Precios en la moneda indicada; el IVA se detalla por separado.",
+	"Se requiere un adelanto del 50% para iniciar la producción; el saldo se cancela contra entrega.",
+	"El tiempo de entrega se confirma al aprobar el diseño final.",
+	"El cliente garantiza que tiene derecho a usar los diseños y logotipos enviados.",
+}
+
+// Company holds the issuer data printed in the header
+type Company struct {
+	Name    string // Nombre comercial
+	Legal   string // Razón social
+	TipoID  string // 01 física, 02 jurídica (códigos de Hacienda)
+	Cedula  string
+	Phone   string
+	Email   string
+	Address string
+}
+
+// Options controls what goes around the quote data
+type Options struct {
+	Company  Company
+	LogoPath string   // PNG o JPG; vacío o inexistente = encabezado solo con texto
+	Terms    []string // Un término por línea
+	QuoteURL string   // URL codificada en el QR
+}
+
+// DefaultOptions builds Options from the emisor config (facturación) and system_config
+func DefaultOptions(cfg *pricing.PricingConfig, quoteID uint) Options {
+	env := config.Get()
+
+	address := strings.Join(nonEmpty(env.FEEmisorOtrasSenas, env.FEEmisorDistrito, env.FEEmisorCanton, env.FEEmisorProvincia), ", ")
+	opts := Options{
+		Company: Company{
+			Name:    env.FEEmisorNombreComer,
+			Legal:   env.FEEmisorNombre,
+			TipoID:  env.FEEmisorTipoID,
+			Cedula:  env.FEEmisorCedula,
+			Phone:   env.FEEmisorTelefono,
+			Email:   env.FEEmisorCorreo,
+			Address: address,
+		},
+		LogoPath: defaultLogoPath,
+		Terms:    defaultTerms,
+		QuoteURL: fmt.Sprintf(defaultQuoteURL, quoteID),
+	}
+	if opts.Company.Name == "" {
+		opts.Company.Name = "FabricaLaser"
+	}
+
+	// Otros talleres: su nombre, logo y cotizador; los datos fiscales del
+	// emisor (entorno) son de FabricaLaser y no se imprimen
+	if cfg != nil && cfg.Tenant != nil {
+		brand := cfg.Tenant.Brand()
+		if cfg.Tenant.ID != models.DefaultTenantID {
+			opts.Company = Company{Name: brand.DisplayName}
+		}
+		if brand.LogoPath != "" {
+			opts.LogoPath = brand.LogoPath
+		}
+		if strings.Contains(brand.QuoteURL, "%d") {
+			opts.QuoteURL = fmt.Sprintf(brand.QuoteURL, quoteID)
+		}
+	}
+
+	if cfg != nil {
+		if terms := nonEmpty(strings.Split(cfg.GetSystemConfigString("cotizacion_terminos"), "\n")...); len(terms) > 0 {
+			opts.Terms = terms
+		}
+		if format := cfg.GetSystemConfigString("cotizacion_pdf_url"); strings.Contains(format, "%d") {
+			opts.QuoteURL = fmt.Sprintf(format, quoteID)
+		}
+	}
+	return opts
+}
+
+// Generate renders the quote with DefaultOptions. The quote must have its
+// relations loaded (User, Technology, Material, EngraveType, SVGAnalysis).
+func Generate(q *models.Quote, cfg *pricing.PricingConfig) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := Render(&buf, q, DefaultOptions(cfg, q.ID)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Filename returns the download name, e.g. "cotizacion-000123.pdf"
+func Filename(q *models.Quote) string {
+	return fmt.Sprintf("cotizacion-%06d.pdf", q.ID)
+}
+
+func nonEmpty(values ...string) []string {
+	out := make([]string, 0, len(values))
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,95 @@
+package quotepdf
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+)
+
+const testSVG = `<svg xmlns="http://www.w3.org/2000/svg" width="100mm" height="50mm" viewBox="0 0 100 50">
+  <rect x="1" y="1" width="98" height="48" fill="none" stroke="#FF0000"/>
+  <circle cx="25" cy="25" r="10" fill="none" stroke="#0000FF"/>
+  <path d="M50 10 L90 10 L90 40 Z M60 20 L80 20 L80 30 Z" fill="#000000"/>
+</svg>`
+
+func testQuote() *models.Quote {
+	apellido := "Mora"
+	telefono := "88887777"
+	created := time.Date(2026, 3, 10, 15, 0, 0, 0, time.UTC)
+	return &models.Quote{
+		ID:                42,
+		CreatedAt:         created,
+		UpdatedAt:         created,
+		Quantity:          10,
+		Thickness:         3,
+		TimeCutMins:       12,
+		TimeEngraveMins:   8,
+		TimeTotalMins:     25,
+		PriceFinal:        25000,
+		Currency:          models.CurrencyCRC,
+		BaseCurrency:      models.CurrencyCRC,
+		TaxRate:           13,
+		TaxAmount:         3250,
+		PriceTotalWithTax: 28250,
+		TaxDisplay:        "exclusive",
+		Status:            models.QuoteStatusAutoApproved,
+		ValidUntil:        created.AddDate(0, 0, 7),
+		User: &models.User{
+			Nombre:     "Ana",
+			Apellido:   &apellido,
+			Cedula:     "3101123456",
+			CedulaType: "juridica",
+			Email:      "ana@example.com",
+			Telefono:   &telefono,
+		},
+		Technology:  &models.Technology{Name: "CO2"},
+		Material:    &models.Material{Name: "MDF"},
+		EngraveType: &models.EngraveType{Name: "Vectorial"},
+		SVGAnalysis: &models.SVGAnalysis{Filename: "rotulo.svg", Width: 100, Height: 50, SVGData: testSVG},
+	}
+}
+
+func TestRender(t *testing.T) {
+	q := testQuote()
+	opts := Options{
+		Company:  Company{Name: "FabricaLaser", Email: "info@fabricalaser.com"},
+		Terms:    []string{"Adelanto del 50%."},
+		QuoteURL: "https://fabricalaser.com/cotizar/?cotizacion=42",
+	}
+
+	var buf bytes.Buffer
+	if err := Render(&buf, q, opts); err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if !bytes.HasPrefix(buf.Bytes(), []byte("%PDF-")) {
+		t.Fatalf("output is not a PDF")
+	}
+
+	// Sin SVG ni usuario también debe generar el documento
+	q.SVGAnalysis, q.User = nil, nil
+	buf.Reset()
+	if err := Render(&buf, q, opts); err != nil {
+		t.Fatalf("Render without relations: %v", err)
+	}
+}
+
+func TestFormatMoney(t *testing.T) {
+	tests := []struct {
+		v        float64
+		currency string
+		want     string
+	}{
+		{25000, models.CurrencyCRC, "¢25 000"},
+		{999.6, models.CurrencyCRC, "¢1 000"},
+		{1234.5, models.CurrencyUSD, "$1 234.50"},
+		{0.999, models.CurrencyUSD, "$1.00"},
+		{-500, models.CurrencyCRC, "-¢500"},
+	}
+	for _, tt := range tests {
+		if got := formatMoney(tt.v, tt.currency); got != tt.want {
+			t.Errorf("formatMoney(%v, %s) = %q, want %q", tt.v, tt.currency, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,608 @@
+// Package quotepdf renders a quote as a formal PDF document (pure Go, sin
+// dependencias del sistema): encabezado con logo, datos del cliente, detalle,
+// IVA, especificaciones técnicas, vista previa del diseño, términos y un QR
+// que vuelve a la cotización en línea.
+package quotepdf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+	"github.com/alonsoalpizar/fabricalaser/internal/services/svgengine"
+	"github.com/jung-kurt/gofpdf"
+	"github.com/skip2/go-qrcode"
+)
+
+// Página A4 en mm
+const (
+	pageMargin   = 15.0
+	contentWidth = 210.0 - 2*pageMargin
+	pageBottom   = 297.0 - 20.0
+)
+
+var zonaCR = time.FixedZone("America/Costa_Rica", -6*60*60)
+
+// Colores de la marca y de la convención de operaciones del SVG
+var (
+	colorBrand  = [3]int{155, 32, 32}
+	colorMuted  = [3]int{110, 110, 110}
+	colorFill   = [3]int{244, 240, 238}
+	colorCut    = [3]int{220, 0, 0}
+	colorVector = [3]int{0, 70, 220}
+	colorRaster = [3]int{40, 40, 40}
+)
+
+var statusLabels = map[models.QuoteStatus]string{
+	models.QuoteStatusDraft:        "Borrador",
+	models.QuoteStatusAutoApproved: "Aprobada",
+	models.QuoteStatusNeedsReview:  "En revisión",
+	models.QuoteStatusRejected:     "Rechazada",
+	models.QuoteStatusApproved:     "Aprobada",
+	models.QuoteStatusExpired:      "Vencida",
+	models.QuoteStatusConverted:    "Pedido confirmado",
+}
+
+// renderer keeps the document and the cp1252 translator of the core fonts
+type renderer struct {
+	pdf  *gofpdf.Fpdf
+	tr   func(string) string
+	q    *models.Quote
+	opts Options
+}
+
+// Render writes the quote PDF to w
+func Render(w io.Writer, q *models.Quote, opts Options) error {
+	if q == nil {
+		return errors.New("quotepdf: cotización nula")
+	}
+
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(pageMargin, pageMargin, pageMargin)
+	pdf.SetAutoPageBreak(true, 297-pageBottom)
+	pdf.AliasNbPages("{nb}")
+
+	r := &renderer{
+		pdf:  pdf,
+		tr:   pdf.UnicodeTranslatorFromDescriptor(""),
+		q:    q,
+		opts: opts,
+	}
+
+	pdf.SetTitle(r.tr(fmt.Sprintf("Cotización %06d", q.ID)), false)
+	pdf.SetAuthor(r.tr(opts.Company.Name), false)
+	pdf.SetCreator("FabricaLaser", false)
+	pdf.SetCreationDate(q.CreatedAt)
+	pdf.SetModificationDate(q.UpdatedAt)
+	pdf.SetFooterFunc(r.footer)
+
+	pdf.AddPage()
+	r.header()
+	r.title()
+	r.customer()
+	r.items()
+	r.totals()
+	r.specs()
+	r.terms()
+
+	if err := pdf.Error(); err != nil {
+		return fmt.Errorf("quotepdf: %w", err)
+	}
+	return pdf.Output(w)
+}
+
+func (r *renderer) header() {
+	pdf, c := r.pdf, r.opts.Company
+	top := pdf.GetY()
+
+	logoBottom := top
+	if r.opts.LogoPath != "" {
+		if _, err := os.Stat(r.opts.LogoPath); err == nil {
+			info := pdf.RegisterImageOptions(r.opts.LogoPath, gofpdf.ImageOptions{})
+			if pdf.Ok() && info != nil {
+				w := 55.0
+				h := w * info.Height() / info.Width()
+				pdf.ImageOptions(r.opts.LogoPath, pageMargin, top, w, h, false, gofpdf.ImageOptions{}, 0, "")
+				logoBottom = top + h
+			}
+			// Un logo ilegible no debe impedir el PDF
+			pdf.ClearError()
+		}
+	}
+	if logoBottom == top {
+		r.setColor(colorBrand)
+		pdf.SetFont("Helvetica", "B", 20)
+		pdf.CellFormat(80, 10, r.tr(c.Name), "", 0, "L", false, 0, "")
+		logoBottom = top + 10
+	}
+
+	pdf.SetXY(pageMargin, top)
+	r.setColor(colorBrand)
+	pdf.SetFont("Helvetica", "B", 11)
+	pdf.CellFormat(contentWidth, 5, r.tr(c.Name), "", 1, "R", false, 0, "")
+
+	pdf.SetFont("Helvetica", "", 8)
+	r.setColor(colorMuted)
+	lines := []string{}
+	if c.Legal != "" && c.Legal != c.Name {
+		lines = append(lines, c.Legal)
+	}
+	if c.Cedula != "" {
+		label := "Cédula"
+		if c.TipoID == "02" {
+			label = "Cédula jurídica"
+		}
+		lines = append(lines, label+" "+c.Cedula)
+	}
+	lines = append(lines, nonEmpty(c.Phone, c.Email, c.Address)...)
+	for _, line := range lines {
+		pdf.CellFormat(contentWidth, 4, r.tr(line), "", 1, "R", false, 0, "")
+	}
+
+	pdf.SetY(math.Max(pdf.GetY(), logoBottom) + 4)
+	r.rule()
+}
+
+func (r *renderer) title() {
+	pdf, q := r.pdf, r.q
+	top := pdf.GetY() + 3
+
+	pdf.SetXY(pageMargin, top)
+	r.setColor(colorBrand)
+	pdf.SetFont("Helvetica", "B", 18)
+	pdf.CellFormat(90, 9, r.tr("COTIZACIÓN"), "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	r.setColor(colorMuted)
+	pdf.CellFormat(90, 5, r.tr(fmt.Sprintf("N.º %06d", q.ID)), "", 1, "L", false, 0, "")
+	bottom := pdf.GetY()
+
+	status := statusLabels[q.Status]
+	if status == "" {
+		status = string(q.Status)
+	}
+	rows := [][2]string{
+		{"Fecha", formatDate(q.CreatedAt)},
+		{"Válida hasta", formatDate(q.ValidUntil)},
+		{"Estado", status},
+		{"Moneda", q.Currency},
+	}
+	pdf.SetY(top)
+	for _, row := range rows {
+		pdf.SetX(pageMargin + contentWidth - 70)
+		r.setColor(colorMuted)
+		pdf.SetFont("Helvetica", "", 8.5)
+		pdf.CellFormat(30, 5, r.tr(row[0]), "", 0, "L", false, 0, "")
+		r.setColor([3]int{0, 0, 0})
+		pdf.SetFont("Helvetica", "B", 8.5)
+		pdf.CellFormat(40, 5, r.tr(row[1]), "", 1, "R", false, 0, "")
+	}
+
+	pdf.SetY(math.Max(pdf.GetY(), bottom) + 4)
+}
+
+func (r *renderer) customer() {
+	pdf := r.pdf
+	r.sectionTitle("CLIENTE")
+
+	u := r.q.User
+	if u == nil {
+		r.setColor(colorMuted)
+		pdf.SetFont("Helvetica", "I", 9)
+		pdf.CellFormat(contentWidth, 5, r.tr("Cliente no disponible"), "", 1, "L", false, 0, "")
+		pdf.Ln(3)
+		return
+	}
+
+	name := u.Nombre
+	if u.Apellido != nil && *u.Apellido != "" {
+		name += " " + *u.Apellido
+	}
+	cedulaLabel := "Cédula física"
+	if u.CedulaType == "juridica" {
+		cedulaLabel = "Cédula jurídica"
+	}
+	address := strings.Join(nonEmpty(deref(u.Direccion), deref(u.Distrito), deref(u.Canton), deref(u.Provincia)), ", ")
+
+	r.setColor([3]int{0, 0, 0})
+	pdf.SetFont("Helvetica", "B", 10)
+	pdf.CellFormat(contentWidth, 5.5, r.tr(name), "", 1, "L", false, 0, "")
+
+	rows := [][2]string{
+		{cedulaLabel, u.Cedula},
+		{"Correo", u.Email},
+		{"Teléfono", deref(u.Telefono)},
+		{"Dirección", address},
+	}
+	if r.q.TaxExoneracion != nil {
+		rows = append(rows, [2]string{"Exoneración IVA", *r.q.TaxExoneracion})
+	}
+	for _, row := range rows {
+		if row[1] == "" {
+			continue
+		}
+		r.labelValue(row[0], row[1], 30, contentWidth-30)
+	}
+	pdf.Ln(4)
+}
+
+func (r *renderer) items() {
+	pdf, q := r.pdf, r.q
+	r.sectionTitle("DETALLE")
+
+	cols := []float64{100, 20, 30, 30}
+	headers := []string{"Descripción", "Cant.", "Precio unit.", "Total"}
+	aligns := []string{"L", "C", "R", "R"}
+
+	pdf.SetFont("Helvetica", "B", 8.5)
+	r.setFill(colorFill)
+	r.setColor([3]int{0, 0, 0})
+	for i, h := range headers {
+		pdf.CellFormat(cols[i], 6, r.tr(h), "", 0, aligns[i], true, 0, "")
+	}
+	pdf.Ln(-1)
+
+	quantity := max(q.Quantity, 1)
+	description := r.itemDescription()
+	pdf.SetFont("Helvetica", "", 8.5)
+	lines := pdf.SplitLines([]byte(r.tr(description)), cols[0]-2)
+	rowH := math.Max(float64(len(lines))*4.2, 6) + 2
+
+	x, y := pdf.GetX(), pdf.GetY()
+	pdf.SetXY(x+1, y+1)
+	pdf.MultiCell(cols[0]-2, 4.2, r.tr(description), "", "L", false)
+	pdf.SetXY(x+cols[0], y)
+	pdf.CellFormat(cols[1], rowH, fmt.Sprintf("%d", quantity), "", 0, "C", false, 0, "")
+	pdf.CellFormat(cols[2], rowH, r.tr(r.money(q.PriceFinal/float64(quantity))), "", 0, "R", false, 0, "")
+	pdf.CellFormat(cols[3], rowH, r.tr(r.money(q.PriceFinal)), "", 0, "R", false, 0, "")
+	pdf.SetXY(pageMargin, y+rowH)
+	r.rule()
+
+	if q.DiscountVolumePct > 0 {
+		r.setColor(colorMuted)
+		pdf.SetFont("Helvetica", "I", 7.5)
+		pdf.CellFormat(contentWidth, 4.5, r.tr(fmt.Sprintf("Incluye descuento por volumen de %s%%.", trimFloat(q.DiscountVolumePct*100))), "", 1, "L", false, 0, "")
+	}
+}
+
+// itemDescription describes the job: what is made, on which material and how
+func (r *renderer) itemDescription() string {
+	q := r.q
+	title := "Trabajo láser a la medida"
+	switch {
+	case q.TimeCutMins > 0 && q.TimeEngraveMins > 0:
+		title = "Corte y grabado láser a la medida"
+	case q.TimeCutMins > 0:
+		title = "Corte láser a la medida"
+	case q.TimeEngraveMins > 0:
+		title = "Grabado láser a la medida"
+	}
+	if q.SVGAnalysis != nil && q.SVGAnalysis.Filename != "" {
+		title += " — diseño " + q.SVGAnalysis.Filename
+	}
+
+	details := nonEmpty(r.materialLabel(), nameOf(q.Technology), nameOfEngrave(q.EngraveType))
+	if len(details) == 0 {
+		return title
+	}
+	return title + "\n" + strings.Join(details, " · ")
+}
+
+func (r *renderer) totals() {
+	pdf, q := r.pdf, r.q
+	pdf.Ln(1)
+
+	labelX := pageMargin + contentWidth - 85
+	row := func(label, value string, bold bool) {
+		pdf.SetX(labelX)
+		style := ""
+		if bold {
+			style = "B"
+		}
+		pdf.SetFont("Helvetica", style, 9)
+		pdf.CellFormat(55, 5.5, r.tr(label), "", 0, "R", false, 0, "")
+		pdf.CellFormat(30, 5.5, r.tr(value), "", 1, "R", false, 0, "")
+	}
+
+	r.setColor([3]int{0, 0, 0})
+	row("Subtotal", r.money(q.PriceFinal), false)
+
+	grossTax := q.PriceFinal * q.TaxRate / 100
+	row(fmt.Sprintf("IVA %s%%", trimFloat(q.TaxRate)), r.money(grossTax), false)
+	if q.TaxExemptPct > 0 {
+		exempt := q.ToDisplayCurrency(grossTax) - q.ToDisplayCurrency(q.TaxAmount)
+		row(fmt.Sprintf("Exoneración (%s%% del IVA)", trimFloat(q.TaxExemptPct)), "-"+formatMoney(exempt, q.Currency), false)
+	}
+
+	pdf.SetX(labelX)
+	r.setDraw(colorBrand)
+	pdf.Line(labelX+25, pdf.GetY(), pageMargin+contentWidth, pdf.GetY())
+	r.setColor(colorBrand)
+	row("TOTAL", r.money(q.PriceTotalWithTax), true)
+
+	r.setColor(colorMuted)
+	pdf.SetFont("Helvetica", "", 7.5)
+	notes := []string{}
+	if q.TaxDisplay == "inclusive" {
+		notes = append(notes, "Precio final con IVA incluido.")
+	}
+	if q.Currency != q.BaseCurrency && q.ExchangeRate > 0 {
+		note := fmt.Sprintf("Tipo de cambio: %s por USD", formatMoney(q.ExchangeRate, models.CurrencyCRC))
+		if q.ExchangeRateDate != nil {
+			note += " del " + formatDate(*q.ExchangeRateDate)
+		}
+		notes = append(notes, note+".")
+	}
+	for _, note := range notes {
+		pdf.CellFormat(contentWidth, 4, r.tr(note), "", 1, "R", false, 0, "")
+	}
+	pdf.Ln(4)
+}
+
+func (r *renderer) specs() {
+	pdf, q := r.pdf, r.q
+	r.sectionTitle("ESPECIFICACIONES TÉCNICAS")
+
+	const boxW, boxH = 70.0, 55.0
+	if pdf.GetY()+boxH+8 > pageBottom {
+		pdf.AddPage()
+	}
+	top := pdf.GetY()
+	boxX := pageMargin + contentWidth - boxW
+
+	rows := [][2]string{
+		{"Material", r.materialLabel()},
+		{"Tecnología", nameOf(q.Technology)},
+		{"Tipo de grabado", nameOfEngrave(q.EngraveType)},
+	}
+	if q.SVGAnalysis != nil && q.SVGAnalysis.Width > 0 && q.SVGAnalysis.Height > 0 {
+		rows = append(rows, [2]string{"Medidas del diseño", fmt.Sprintf("%s × %s mm", trimFloat(q.SVGAnalysis.Width), trimFloat(q.SVGAnalysis.Height))})
+	}
+	if q.MaterialIncluded != nil && !*q.MaterialIncluded {
+		rows = append(rows, [2]string{"Material", "Lo aporta el cliente"})
+	}
+	if q.TimeTotalMins > 0 {
+		rows = append(rows, [2]string{"Tiempo de máquina", formatMinutes(q.TimeTotalMins)})
+	}
+	if q.IgnoreCutLines {
+		rows = append(rows, [2]string{"Nota", "Las líneas de corte no se cotizaron: el material no es cortable con esta tecnología."})
+	}
+	for _, row := range rows {
+		if row[1] == "" {
+			continue
+		}
+		r.labelValue(row[0], row[1], 35, boxX-pageMargin-40)
+	}
+	textBottom := pdf.GetY()
+
+	r.thumbnail(boxX, top, boxW, boxH)
+	pdf.SetY(math.Max(textBottom, top+boxH+6) + 3)
+}
+
+// thumbnail draws the design outline scaled into the box, colored by operation
+func (r *renderer) thumbnail(x, y, w, h float64) {
+	pdf := r.pdf
+	r.setDraw([3]int{200, 200, 200})
+	pdf.SetLineWidth(0.2)
+	pdf.Rect(x, y, w, h, "D")
+
+	var outline *svgengine.Outline
+	if r.q.SVGAnalysis != nil && r.q.SVGAnalysis.SVGData != "" {
+		outline, _ = svgengine.BuildOutline(r.q.SVGAnalysis.SVGData)
+	}
+	b := svgengine.BoundingBox{}
+	if outline != nil {
+		b = outline.Bounds
+	}
+	bw, bh := b.MaxX-b.MinX, b.MaxY-b.MinY
+	if outline == nil || len(outline.Paths) == 0 || bw <= 0 || bh <= 0 {
+		r.setColor(colorMuted)
+		pdf.SetFont("Helvetica", "I", 8)
+		pdf.SetXY(x, y+h/2-2.5)
+		pdf.CellFormat(w, 5, r.tr("Vista previa no disponible"), "", 0, "C", false, 0, "")
+		return
+	}
+
+	const pad = 4.0
+	scale := math.Min((w-2*pad)/bw, (h-2*pad)/bh)
+	offX := x + (w-bw*scale)/2
+	offY := y + (h-bh*scale)/2
+
+	pdf.SetLineWidth(0.25)
+	for _, path := range outline.Paths {
+		switch path.Category {
+		case models.CategoryCut:
+			r.setDraw(colorCut)
+		case models.CategoryVector:
+			r.setDraw(colorVector)
+		default:
+			r.setDraw(colorRaster)
+		}
+		for i, p := range path.Points {
+			px, py := offX+(p.X-b.MinX)*scale, offY+(p.Y-b.MinY)*scale
+			if i == 0 {
+				pdf.MoveTo(px, py)
+				continue
+			}
+			pdf.LineTo(px, py)
+		}
+		if path.Closed {
+			pdf.ClosePath()
+		}
+		pdf.DrawPath("D")
+	}
+
+	r.setColor(colorMuted)
+	pdf.SetFont("Helvetica", "", 6.5)
+	pdf.SetXY(x, y+h+0.5)
+	pdf.CellFormat(w, 3.5, r.tr("Rojo: corte · Azul: grabado vectorial · Negro: relleno"), "", 0, "C", false, 0, "")
+}
+
+func (r *renderer) terms() {
+	pdf := r.pdf
+	const qrSize = 28.0
+	if pdf.GetY()+qrSize+12 > pageBottom {
+		pdf.AddPage()
+	}
+	r.sectionTitle("TÉRMINOS Y CONDICIONES")
+	top := pdf.GetY()
+	textW := contentWidth - qrSize - 8
+
+	terms := append([]string{fmt.Sprintf("Esta cotización es válida hasta el %s.", formatDate(r.q.ValidUntil))}, r.opts.Terms...)
+	r.setColor([3]int{60, 60, 60})
+	pdf.SetFont("Helvetica", "", 7.5)
+	for i, t := range terms {
+		pdf.SetX(pageMargin)
+		pdf.MultiCell(textW, 3.8, r.tr(fmt.Sprintf("%d. %s", i+1, t)), "", "L", false)
+	}
+	textBottom := pdf.GetY()
+
+	if r.opts.QuoteURL != "" {
+		if png, err := qrcode.Encode(r.opts.QuoteURL, qrcode.Medium, 256); err == nil {
+			name := fmt.Sprintf("qr-%d", r.q.ID)
+			pdf.RegisterImageOptionsReader(name, gofpdf.ImageOptions{ImageType: "PNG"}, bytes.NewReader(png))
+			qrX := pageMargin + contentWidth - qrSize
+			pdf.ImageOptions(name, qrX, top, qrSize, qrSize, false, gofpdf.ImageOptions{ImageType: "PNG"}, 0, r.opts.QuoteURL)
+			r.setColor(colorMuted)
+			pdf.SetFont("Helvetica", "", 6.5)
+			pdf.SetXY(qrX-4, top+qrSize)
+			pdf.CellFormat(qrSize+8, 3.5, r.tr("Ver cotización en línea"), "", 0, "C", false, 0, r.opts.QuoteURL)
+			textBottom = math.Max(textBottom, top+qrSize+4)
+		}
+	}
+	pdf.SetY(textBottom)
+}
+
+func (r *renderer) footer() {
+	pdf := r.pdf
+	pdf.SetY(-15)
+	r.setColor(colorMuted)
+	pdf.SetFont("Helvetica", "", 7)
+	left := strings.Join(nonEmpty(r.opts.Company.Name, r.opts.Company.Email, r.opts.Company.Phone), " · ")
+	pdf.CellFormat(contentWidth/2, 5, r.tr(left), "", 0, "L", false, 0, "")
+	pdf.CellFormat(contentWidth/2, 5, r.tr(fmt.Sprintf("Cotización %06d · Página %d/{nb}", r.q.ID, pdf.PageNo())), "", 0, "R", false, 0, "")
+}
+
+// ── Helpers de dibujo ───────────────────────────────────────────────────────
+
+func (r *renderer) sectionTitle(title string) {
+	pdf := r.pdf
+	r.setColor(colorBrand)
+	pdf.SetFont("Helvetica", "B", 9)
+	pdf.CellFormat(contentWidth, 6, r.tr(title), "", 1, "L", false, 0, "")
+	r.setDraw(colorBrand)
+	pdf.SetLineWidth(0.4)
+	pdf.Line(pageMargin, pdf.GetY(), pageMargin+contentWidth, pdf.GetY())
+	pdf.Ln(2)
+}
+
+func (r *renderer) rule() {
+	r.setDraw([3]int{210, 210, 210})
+	r.pdf.SetLineWidth(0.2)
+	y := r.pdf.GetY()
+	r.pdf.Line(pageMargin, y, pageMargin+contentWidth, y)
+}
+
+func (r *renderer) labelValue(label, value string, labelW, valueW float64) {
+	pdf := r.pdf
+	pdf.SetX(pageMargin)
+	r.setColor(colorMuted)
+	pdf.SetFont("Helvetica", "", 8.5)
+	pdf.CellFormat(labelW, 4.8, r.tr(label), "", 0, "L", false, 0, "")
+	r.setColor([3]int{0, 0, 0})
+	pdf.MultiCell(valueW, 4.8, r.tr(value), "", "L", false)
+}
+
+func (r *renderer) setColor(c [3]int) { r.pdf.SetTextColor(c[0], c[1], c[2]) }
+func (r *renderer) setDraw(c [3]int)  { r.pdf.SetDrawColor(c[0], c[1], c[2]) }
+func (r *renderer) setFill(c [3]int)  { r.pdf.SetFillColor(c[0], c[1], c[2]) }
+
+// money formats an amount stored in the base currency in the quote's currency
+func (r *renderer) money(base float64) string {
+	return formatMoney(r.q.ToDisplayCurrency(base), r.q.Currency)
+}
+
+func (r *renderer) materialLabel() string {
+	if r.q.Material == nil {
+		return ""
+	}
+	if r.q.Thickness > 0 {
+		return fmt.Sprintf("%s %s mm", r.q.Material.Name, trimFloat(r.q.Thickness))
+	}
+	return r.q.Material.Name
+}
+
+// ── Formato ─────────────────────────────────────────────────────────────────
+
+// formatMoney formats with the colón sign ¢ (el ₡ no existe en las fuentes
+// base del PDF) and spaces as thousands separator: ¢25 000, $1 234.50
+func formatMoney(v float64, currency string) string {
+	sign := ""
+	if v < 0 {
+		sign, v = "-", -v
+	}
+	if currency == models.CurrencyUSD {
+		whole := math.Floor(v)
+		cents := math.Round((v - whole) * 100)
+		if cents >= 100 {
+			whole, cents = whole+1, 0
+		}
+		return fmt.Sprintf("%s$%s.%02.0f", sign, groupThousands(whole), cents)
+	}
+	return sign + "¢" + groupThousands(math.Round(v))
+}
+
+func groupThousands(v float64) string {
+	s := fmt.Sprintf("%.0f", v)
+	var out []byte
+	for i := range s {
+		if i > 0 && (len(s)-i)%3 == 0 {
+			out = append(out, ' ')
+		}
+		out = append(out, s[i])
+	}
+	return string(out)
+}
+
+func formatDate(t time.Time) string {
+	return t.In(zonaCR).Format("02/01/2006")
+}
+
+func formatMinutes(mins float64) string {
+	total := int(math.Ceil(mins))
+	if total < 60 {
+		return fmt.Sprintf("%d min", total)
+	}
+	return fmt.Sprintf("%d h %02d min", total/60, total%60)
+}
+
+// trimFloat prints 3 as "3" and 2.5 as "2.5"
+func trimFloat(v float64) string {
+	return strings.TrimRight(strings.TrimRight(fmt.Sprintf("%.2f", v), "0"), ".")
+}
+
+func nameOf(t *models.Technology) string {
+	if t == nil {
+		return ""
+	}
+	return t.Name
+}
+
+func nameOfEngrave(e *models.EngraveType) string {
+	if e == nil {
+		return ""
+	}
+	return e.Name
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,400 @@
+package svgengine
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// DXF support: a DXF drawing is converted to an equivalent SVG in mm so it can go
+// through the same Analyzer as any uploaded SVG. Only 2D geometry is read.
+//
+// Color convention for DXF (no fills, so no raster engrave):
+//   - ACI color 5 (blue), or a layer whose name contains "grab"/"engrav" → vector engrave
+//   - anything else → cut (CAD files sent for laser are almost always cut outlines)
+
+// ErrInvalidDXF is returned when the content is not an ASCII DXF with entities
+var ErrInvalidDXF = errors.New("el archivo no parece ser un DXF válido")
+
+const (
+	aciBlue = 5
+
+	// arcSegmentMM is the max chord length when approximating arcs and ellipses
+	arcSegmentMM = 1.0
+	minArcSteps  = 8
+)
+
+// dxfPair is one group code/value pair of the DXF tagged format
+type dxfPair struct {
+	code  int
+	value string
+}
+
+// dxfEntity is an entity from the ENTITIES section with its raw pairs
+type dxfEntity struct {
+	kind  string
+	pairs []dxfPair
+}
+
+// dxfShape is an entity already converted to points in drawing units
+type dxfShape struct {
+	points  []Point
+	closed  bool
+	circle  bool // points[0] = center, radius in r
+	r       float64
+	engrave bool
+}
+
+// IsDXF reports whether content looks like an ASCII DXF file
+func IsDXF(content string) bool {
+	head := content
+	if len(head) > 4096 {
+		head = head[:4096]
+	}
+	return strings.Contains(head, "SECTION") && (strings.Contains(head, "HEADER") || strings.Contains(head, "ENTITIES"))
+}
+
+// ConvertDXF converts an ASCII DXF drawing to SVG (mm, Y axis pointing down).
+// Supported entities: LINE, LWPOLYLINE (with bulges), POLYLINE/VERTEX, CIRCLE, ARC,
+// ELLIPSE and SPLINE (approximated through its fit or control points).
+func ConvertDXF(content string) (string, error) {
+	pairs, err := readDXFPairs(content)
+	if err != nil {
+		return "", err
+	}
+
+	scale := 1.0
+	var entities []dxfEntity
+	section := ""
+	for i := 0; i < len(pairs); i++ {
+		p := pairs[i]
+		switch {
+		case p.code == 0 && p.value == "SECTION" && i+1 < len(pairs) && pairs[i+1].code == 2:
+			section = pairs[i+1].value
+			i++
+		case p.code == 0 && p.value == "ENDSEC":
+			section = ""
+		case section == "HEADER" && p.code == 9 && p.value == "$INSUNITS" && i+1 < len(pairs):
+			scale = insUnitsToMM(pairs[i+1].value)
+			i++
+		case section == "ENTITIES" && p.code == 0:
+			entities = append(entities, dxfEntity{kind: p.value})
+		case section == "ENTITIES" && len(entities) > 0:
+			last := &entities[len(entities)-1]
+			last.pairs = append(last.pairs, p)
+		}
+	}
+	if len(entities) == 0 {
+		return "", ErrInvalidDXF
+	}
+
+	shapes := buildDXFShapes(entities)
+	if len(shapes) == 0 {
+		return "", fmt.Errorf("%w: sin geometría 2D soportada", ErrInvalidDXF)
+	}
+	return shapesToSVG(shapes, scale), nil
+}
+
+func readDXFPairs(content string) ([]dxfPair, error) {
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var pairs []dxfPair
+	for scanner.Scan() {
+		codeLine := strings.TrimSpace(scanner.Text())
+		if !scanner.Scan() {
+			break
+		}
+		code, err := strconv.Atoi(codeLine)
+		if err != nil {
+			return nil, ErrInvalidDXF
+		}
+		pairs = append(pairs, dxfPair{code: code, value: strings.TrimSpace(scanner.Text())})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDXF, err)
+	}
+	return pairs, nil
+}
+
+// insUnitsToMM maps $INSUNITS to a mm factor. Unitless drawings are assumed in mm.
+func insUnitsToMM(value string) float64 {
+	switch value {
+	case "1":
+		return 25.4 // pulgadas
+	case "2":
+		return 304.8 // pies
+	case "5":
+		return 10 // cm
+	case "6":
+		return 1000 // metros
+	default:
+		return 1
+	}
+}
+
+func buildDXFShapes(entities []dxfEntity) []dxfShape {
+	var shapes []dxfShape
+	for i := 0; i < len(entities); i++ {
+		e := entities[i]
+		var shape dxfShape
+		switch e.kind {
+		case "LINE":
+			shape.points = []Point{
+				{e.float(10), e.float(20)},
+				{e.float(11), e.float(21)},
+			}
+		case "CIRCLE":
+			shape.circle = true
+			shape.points = []Point{{e.float(10), e.float(20)}}
+			shape.r = e.float(40)
+		case "ARC":
+			shape.points = arcPoints(Point{e.float(10), e.float(20)}, e.float(40), e.float(50), e.float(51))
+		case "ELLIPSE":
+			shape.points = ellipsePoints(e)
+			shape.closed = e.float(42)-e.float(41) >= 2*math.Pi-1e-6
+		case "LWPOLYLINE":
+			shape.points, shape.closed = lwPolylinePoints(e)
+		case "SPLINE":
+			shape.points = splinePoints(e)
+			shape.closed = e.int(70)&1 != 0
+		case "POLYLINE":
+			// Los vértices vienen como entidades VERTEX hasta SEQEND
+			shape.closed = e.int(70)&1 != 0
+			for i+1 < len(entities) && entities[i+1].kind == "VERTEX" {
+				i++
+				v := entities[i]
+				shape.points = append(shape.points, Point{v.float(10), v.float(20)})
+			}
+		default:
+			continue
+		}
+		if !shape.circle && len(shape.points) < 2 || shape.circle && shape.r <= 0 {
+			continue
+		}
+		shape.engrave = e.isEngrave()
+		shapes = append(shapes, shape)
+	}
+	return shapes
+}
+
+func (e dxfEntity) value(code int) (string, bool) {
+	for _, p := range e.pairs {
+		if p.code == code {
+			return p.value, true
+		}
+	}
+	return "", false
+}
+
+func (e dxfEntity) float(code int) float64 {
+	v, _ := e.value(code)
+	f, _ := strconv.ParseFloat(v, 64)
+	return f
+}
+
+func (e dxfEntity) int(code int) int {
+	v, _ := e.value(code)
+	n, _ := strconv.Atoi(v)
+	return n
+}
+
+func (e dxfEntity) isEngrave() bool {
+	if e.int(62) == aciBlue {
+		return true
+	}
+	layer, _ := e.value(8)
+	layer = strings.ToLower(layer)
+	return strings.Contains(layer, "grab") || strings.Contains(layer, "engrav")
+}
+
+// arcPoints approximates a counter-clockwise arc (angles in degrees, as in DXF)
+func arcPoints(c Point, r, startDeg, endDeg float64) []Point {
+	start := startDeg * math.Pi / 180
+	end := endDeg * math.Pi / 180
+	for end <= start {
+		end += 2 * math.Pi
+	}
+	return sampleCurve(r*(end-start), func(t float64) Point {
+		a := start + (end-start)*t
+		return Point{c.X + r*math.Cos(a), c.Y + r*math.Sin(a)}
+	})
+}
+
+func ellipsePoints(e dxfEntity) []Point {
+	c := Point{e.float(10), e.float(20)}
+	major := Point{e.float(11), e.float(21)}
+	ratio := e.float(40)
+	start, end := e.float(41), e.float(42)
+	if end <= start {
+		end += 2 * math.Pi
+	}
+	a := math.Hypot(major.X, major.Y)
+	b := a * ratio
+	rot := math.Atan2(major.Y, major.X)
+	return sampleCurve(math.Max(a, b)*(end-start), func(t float64) Point {
+		p := start + (end-start)*t
+		x, y := a*math.Cos(p), b*math.Sin(p)
+		return Point{
+			c.X + x*math.Cos(rot) - y*math.Sin(rot),
+			c.Y + x*math.Sin(rot) + y*math.Cos(rot),
+		}
+	})
+}
+
+// lwPolylinePoints reads vertices (10/20) and bulges (42); a bulge on a vertex
+// turns the segment to the next vertex into an arc.
+func lwPolylinePoints(e dxfEntity) ([]Point, bool) {
+	type vertex struct {
+		p     Point
+		bulge float64
+	}
+	var verts []vertex
+	for _, p := range e.pairs {
+		switch p.code {
+		case 10:
+			x, _ := strconv.ParseFloat(p.value, 64)
+			verts = append(verts, vertex{p: Point{X: x}})
+		case 20:
+			if len(verts) > 0 {
+				verts[len(verts)-1].p.Y, _ = strconv.ParseFloat(p.value, 64)
+			}
+		case 42:
+			if len(verts) > 0 {
+				verts[len(verts)-1].bulge, _ = strconv.ParseFloat(p.value, 64)
+			}
+		}
+	}
+	closed := e.int(70)&1 != 0
+
+	var points []Point
+	for i, v := range verts {
+		points = append(points, v.p)
+		next := i + 1
+		if next == len(verts) {
+			if !closed {
+				break
+			}
+			next = 0
+		}
+		if v.bulge != 0 {
+			arc := bulgePoints(v.p, verts[next].p, v.bulge)
+			points = append(points, arc[1:len(arc)-1]...)
+		}
+	}
+	return points, closed
+}
+
+// bulgePoints converts a bulge segment (bulge = tan(θ/4)) into arc points
+func bulgePoints(p1, p2 Point, bulge float64) []Point {
+	chord := math.Hypot(p2.X-p1.X, p2.Y-p1.Y)
+	if chord == 0 {
+		return []Point{p1, p2}
+	}
+	theta := 4 * math.Atan(bulge)
+	r := chord / (2 * math.Sin(math.Abs(theta)/2))
+	// Centro: sobre la mediatriz de la cuerda, del lado que indica el signo del bulge
+	mid := Point{(p1.X + p2.X) / 2, (p1.Y + p2.Y) / 2}
+	d := r * math.Cos(theta/2)
+	ux, uy := (p2.X-p1.X)/chord, (p2.Y-p1.Y)/chord
+	sign := 1.0
+	if bulge < 0 {
+		sign = -1
+	}
+	c := Point{mid.X - sign*uy*d, mid.Y + sign*ux*d}
+	a1 := math.Atan2(p1.Y-c.Y, p1.X-c.X)
+	return sampleCurve(r*math.Abs(theta), func(t float64) Point {
+		a := a1 + theta*t
+		return Point{c.X + r*math.Cos(a), c.Y + r*math.Sin(a)}
+	})
+}
+
+func splinePoints(e dxfEntity) []Point {
+	xCode, yCode := 11, 21 // fit points
+	if _, ok := e.value(11); !ok {
+		xCode, yCode = 10, 20 // control points
+	}
+	var points []Point
+	for _, p := range e.pairs {
+		switch p.code {
+		case xCode:
+			x, _ := strconv.ParseFloat(p.value, 64)
+			points = append(points, Point{X: x})
+		case yCode:
+			if len(points) > 0 {
+				points[len(points)-1].Y, _ = strconv.ParseFloat(p.value, 64)
+			}
+		}
+	}
+	return points
+}
+
+// sampleCurve evaluates f on [0,1] with chords of at most arcSegmentMM
+func sampleCurve(length float64, f func(t float64) Point) []Point {
+	steps := int(math.Ceil(math.Abs(length) / arcSegmentMM))
+	if steps < minArcSteps {
+		steps = minArcSteps
+	}
+	points := make([]Point, steps+1)
+	for i := 0; i <= steps; i++ {
+		points[i] = f(float64(i) / float64(steps))
+	}
+	return points
+}
+
+// shapesToSVG translates to the origin and flips Y (DXF is Y-up, SVG is Y-down)
+func shapesToSVG(shapes []dxfShape, scale float64) string {
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, s := range shapes {
+		if s.circle {
+			c := s.points[0]
+			minX, maxX = math.Min(minX, c.X-s.r), math.Max(maxX, c.X+s.r)
+			minY, maxY = math.Min(minY, c.Y-s.r), math.Max(maxY, c.Y+s.r)
+			continue
+		}
+		for _, p := range s.points {
+			minX, maxX = math.Min(minX, p.X), math.Max(maxX, p.X)
+			minY, maxY = math.Min(minY, p.Y), math.Max(maxY, p.Y)
+		}
+	}
+
+	tr := func(p Point) (float64, float64) {
+		return (p.X - minX) * scale, (maxY - p.Y) * scale
+	}
+	num := func(v float64) string { return strconv.FormatFloat(v, 'f', 3, 64) }
+
+	width := (maxX - minX) * scale
+	height := (maxY - minY) * scale
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%smm" height="%smm" viewBox="0 0 %s %s">`+"\n",
+		num(width), num(height), num(width), num(height))
+
+	for _, s := range shapes {
+		stroke := "#FF0000"
+		if s.engrave {
+			stroke = "#0000FF"
+		}
+		if s.circle {
+			x, y := tr(s.points[0])
+			fmt.Fprintf(&b, `<circle cx="%s" cy="%s" r="%s" stroke="%s" fill="none"/>`+"\n",
+				num(x), num(y), num(s.r*scale), stroke)
+			continue
+		}
+		coords := make([]string, len(s.points))
+		for i, p := range s.points {
+			x, y := tr(p)
+			coords[i] = num(x) + "," + num(y)
+		}
+		tag := "polyline"
+		if s.closed {
+			tag = "polygon"
+		}
+		fmt.Fprintf(&b, `<%s points="%s" stroke="%s" fill="none"/>`+"\n", tag, strings.Join(coords, " "), stroke)
+	}
+	b.WriteString("</svg>\n")
+	return b.String()
+}
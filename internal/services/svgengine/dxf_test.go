@@ -0,0 +1,78 @@
+package svgengine
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+// dxf arma un DXF con la sección HEADER ($INSUNITS) y las entidades dadas
+func dxf(insUnits string, entities ...string) string {
+	return "0\nSECTION\n2\nHEADER\n9\n$INSUNITS\n70\n" + insUnits + "\n0\nENDSEC\n" +
+		"0\nSECTION\n2\nENTITIES\n" + strings.Join(entities, "") + "0\nENDSEC\n0\nEOF\n"
+}
+
+func TestConvertDXF(t *testing.T) {
+	content := dxf("4",
+		// Rectángulo 100×50 cerrado (corte)
+		"0\nLWPOLYLINE\n8\nCONTORNO\n90\n4\n70\n1\n10\n0\n20\n0\n10\n100\n20\n0\n10\n100\n20\n50\n10\n0\n20\n50\n",
+		// Círculo Ø20 en capa de grabado
+		"0\nCIRCLE\n8\nGRABADO\n10\n50\n20\n25\n40\n10\n",
+		// Línea azul (ACI 5) de 20 mm
+		"0\nLINE\n8\n0\n62\n5\n10\n10\n20\n10\n11\n30\n21\n10\n",
+	)
+
+	svg, err := ConvertDXF(content)
+	if err != nil {
+		t.Fatalf("ConvertDXF: %v", err)
+	}
+	if !strings.Contains(svg, `width="100.000mm"`) || !strings.Contains(svg, `height="50.000mm"`) {
+		t.Fatalf("unexpected size in %s", svg)
+	}
+
+	result, err := NewAnalyzer().Analyze(svg)
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	if math.Abs(result.CutLengthMM-300) > 0.5 {
+		t.Errorf("cut length = %.2f, want 300", result.CutLengthMM)
+	}
+	if want := 2*math.Pi*10 + 20; math.Abs(result.VectorLengthMM-want) > 0.5 {
+		t.Errorf("vector length = %.2f, want %.2f", result.VectorLengthMM, want)
+	}
+	if result.RasterAreaMM2 != 0 {
+		t.Errorf("raster area = %.2f, want 0", result.RasterAreaMM2)
+	}
+}
+
+func TestConvertDXFUnitsAndBulge(t *testing.T) {
+	// Ranura en cm: 2 rectas de 2 cm cerradas con semicírculos (bulge = 1)
+	content := dxf("5",
+		"0\nLWPOLYLINE\n90\n4\n70\n1\n"+
+			"10\n0\n20\n0\n10\n2\n20\n0\n42\n1\n10\n2\n20\n1\n10\n0\n20\n1\n42\n1\n",
+	)
+
+	svg, err := ConvertDXF(content)
+	if err != nil {
+		t.Fatalf("ConvertDXF: %v", err)
+	}
+	result, err := NewAnalyzer().Analyze(svg)
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	if want := 40 + 2*math.Pi*5; math.Abs(result.CutLengthMM-want) > 0.5 {
+		t.Errorf("cut length = %.2f, want %.2f", result.CutLengthMM, want)
+	}
+	if math.Abs(result.Width-30) > 0.01 {
+		t.Errorf("width = %.2f, want 30", result.Width)
+	}
+}
+
+func TestConvertDXFInvalid(t *testing.T) {
+	if _, err := ConvertDXF("<svg></svg>"); err == nil {
+		t.Error("expected error for non-DXF content")
+	}
+	if IsDXF("<svg></svg>") {
+		t.Error("SVG detected as DXF")
+	}
+}
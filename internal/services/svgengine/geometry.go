@@ -252,7 +252,14 @@ func (g *GeometryCalculator) calculatePath(attrs map[string]string) GeometryResu
 // pathToPoints converts SVG path data to a list of points
 // Supports: M, L, H, V, C, S, Q, T, A, Z (uppercase = absolute, lowercase = relative)
 func (g *GeometryCalculator) pathToPoints(d string) []Point {
+	points, _ := g.pathToSubpaths(d)
+	return points
+}
+
+// pathToSubpaths is pathToPoints plus the index where each subpath (MoveTo) starts
+func (g *GeometryCalculator) pathToSubpaths(d string) ([]Point, []int) {
 	points := make([]Point, 0)
+	starts := make([]int, 0)
 	current := Point{0, 0}
 	start := Point{0, 0}
 	lastControl := Point{0, 0}
@@ -286,6 +293,7 @@ func (g *GeometryCalculator) pathToPoints(d string) []Point {
 				current = Point{x, y}
 				if i == 0 {
 					start = current
+					starts = append(starts, len(points))
 				}
 				points = append(points, current)
 			}
@@ -417,7 +425,7 @@ func (g *GeometryCalculator) pathToPoints(d string) []Point {
 		lastCmd = cmd
 	}
 
-	return points
+	return points, starts
 }
 
 // cubicBezier approximates a cubic Bezier curve with line segments
@@ -0,0 +1,112 @@
+package svgengine
+
+import (
+	"math"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+)
+
+// ellipseSegments is the number of segments used to draw circles and ellipses
+const ellipseSegments = 48
+
+// OutlinePath is one polyline of the drawing, in mm
+type OutlinePath struct {
+	Points   []Point
+	Closed   bool
+	Category models.ElementCategory // cut, vector, raster
+}
+
+// Outline is a simplified line drawing of an SVG, used for previews (PDF, thumbnails).
+// Ignores transforms and styles: only geometry and the operation color convention.
+type Outline struct {
+	Width  float64 // Document width in mm
+	Height float64 // Document height in mm
+	Bounds BoundingBox
+	Paths  []OutlinePath
+}
+
+// BuildOutline parses svgContent and linearizes every classified element
+func BuildOutline(svgContent string) (*Outline, error) {
+	parser := NewParser()
+	parsed, err := parser.Parse(svgContent)
+	if err != nil {
+		return nil, err
+	}
+
+	scaleX, scaleY := parser.GetScaleFactor(parsed)
+	geomCalc := NewGeometryCalculator(scaleX, scaleY)
+	classified := NewClassifier().ClassifyAll(parsed.Elements)
+
+	outline := &Outline{Width: parsed.Width, Height: parsed.Height}
+	boundsInit := false
+
+	for _, elem := range classified {
+		if elem.Category == models.CategoryIgnored {
+			continue
+		}
+		for _, path := range geomCalc.outlinePaths(elem.Raw) {
+			path.Category = elem.Category
+			for _, p := range path.Points {
+				if !boundsInit {
+					outline.Bounds = BoundingBox{MinX: p.X, MinY: p.Y, MaxX: p.X, MaxY: p.Y}
+					boundsInit = true
+					continue
+				}
+				outline.Bounds = geomCalc.expandBounds(outline.Bounds, p)
+			}
+			outline.Paths = append(outline.Paths, path)
+		}
+	}
+
+	return outline, nil
+}
+
+// outlinePaths returns the polylines that draw an element
+func (g *GeometryCalculator) outlinePaths(elem RawElement) []OutlinePath {
+	switch elem.Type {
+	case "rect":
+		b := g.calculateRect(elem.Attributes).Bounds
+		if b.MaxX <= b.MinX || b.MaxY <= b.MinY {
+			return nil
+		}
+		return []OutlinePath{{
+			Points: []Point{{b.MinX, b.MinY}, {b.MaxX, b.MinY}, {b.MaxX, b.MaxY}, {b.MinX, b.MaxY}},
+			Closed: true,
+		}}
+	case "circle", "ellipse":
+		b := g.Calculate(elem).Bounds
+		rx, ry := (b.MaxX-b.MinX)/2, (b.MaxY-b.MinY)/2
+		if rx <= 0 || ry <= 0 {
+			return nil
+		}
+		cx, cy := b.MinX+rx, b.MinY+ry
+		points := make([]Point, ellipseSegments)
+		for i := range points {
+			a := 2 * math.Pi * float64(i) / ellipseSegments
+			points[i] = Point{cx + rx*math.Cos(a), cy + ry*math.Sin(a)}
+		}
+		return []OutlinePath{{Points: points, Closed: true}}
+	case "line", "polyline":
+		if pts := g.Calculate(elem).Points; len(pts) >= 2 {
+			return []OutlinePath{{Points: pts}}
+		}
+	case "polygon":
+		if pts := g.Calculate(elem).Points; len(pts) >= 2 {
+			return []OutlinePath{{Points: pts, Closed: true}}
+		}
+	case "path":
+		points, starts := g.pathToSubpaths(elem.Attributes["d"])
+		var paths []OutlinePath
+		for i, start := range starts {
+			end := len(points)
+			if i+1 < len(starts) {
+				end = starts[i+1]
+			}
+			if end-start >= 2 {
+				paths = append(paths, OutlinePath{Points: points[start:end]})
+			}
+		}
+		return paths
+	}
+	return nil
+}
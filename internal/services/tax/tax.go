@@ -0,0 +1,141 @@
+// Package tax calcula el IVA de cotizaciones, estimados y blanks.
+//
+// Todos los precios del sistema (price_final, estimados, blanks) son montos SIN IVA.
+// La tarifa depende del tipo de venta y se configura en system_config
+// (iva_tarifa_servicio, iva_tarifa_mercancia, iva_tarifa_blank). La exoneración
+// del cliente reduce el IVA en el porcentaje indicado mientras esté vigente.
+package tax
+
+import (
+	"math"
+	"time"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+	"github.com/alonsoalpizar/fabricalaser/internal/services/pricing"
+)
+
+// Tipos de venta (cada uno con su tarifa configurable)
+const (
+	KindServicio  = "servicio"  // Servicio láser sobre material del cliente
+	KindMercancia = "mercancia" // FabricaLaser provee el material
+	KindBlank     = "blank"     // Producto del catálogo de blanks
+)
+
+// Modos de presentación del precio
+const (
+	DisplayExclusive = "exclusive" // "₡10 000 + IVA"
+	DisplayInclusive = "inclusive" // "₡11 300 IVA incluido"
+)
+
+// DefaultRate es la tarifa general de IVA (13%)
+const DefaultRate = 13.0
+
+// Breakdown es el desglose de impuesto de un monto
+type Breakdown struct {
+	Kind         string  `json:"kind"`
+	Rate         float64 `json:"rate"`       // % IVA
+	ExemptPct    float64 `json:"exempt_pct"` // % del IVA exonerado
+	Exoneracion  string  `json:"exoneracion,omitempty"`
+	Subtotal     float64 `json:"subtotal"`      // Sin IVA
+	GrossTax     float64 `json:"gross_tax"`     // IVA antes de exoneración
+	ExemptAmount float64 `json:"exempt_amount"` // IVA exonerado
+	Tax          float64 `json:"tax"`           // IVA a cobrar
+	Total        float64 `json:"total"`         // Subtotal + IVA
+	Display      string  `json:"display"`
+}
+
+// Compute calculates the breakdown for a net subtotal. Amounts are rounded to 2 decimals.
+func Compute(subtotal, ratePct, exemptPct float64) Breakdown {
+	ratePct = math.Max(ratePct, 0)
+	exemptPct = math.Min(math.Max(exemptPct, 0), 100)
+
+	gross := round2(subtotal * ratePct / 100)
+	exempt := round2(gross * exemptPct / 100)
+	tax := round2(gross - exempt)
+	return Breakdown{
+		Rate:         ratePct,
+		ExemptPct:    exemptPct,
+		Subtotal:     round2(subtotal),
+		GrossTax:     gross,
+		ExemptAmount: exempt,
+		Tax:          tax,
+		Total:        round2(subtotal + tax),
+	}
+}
+
+// KindForMaterial returns the sale kind depending on who provides the material
+func KindForMaterial(materialIncluded bool) string {
+	if materialIncluded {
+		return KindMercancia
+	}
+	return KindServicio
+}
+
+// RateFor returns the configured IVA rate for a sale kind
+func RateFor(cfg *pricing.PricingConfig, kind string) float64 {
+	if cfg == nil {
+		return DefaultRate
+	}
+	return cfg.GetSystemConfigFloat("iva_tarifa_"+kind, DefaultRate)
+}
+
+// DisplayMode returns the configured presentation mode (exclusive by default)
+func DisplayMode(cfg *pricing.PricingConfig) string {
+	if cfg != nil && cfg.GetSystemConfigString("iva_modo_presentacion") == DisplayInclusive {
+		return DisplayInclusive
+	}
+	return DisplayExclusive
+}
+
+// For computes the breakdown for a subtotal, applying the user's exemption if valid.
+// user can be nil (estimados anónimos del bot).
+func For(cfg *pricing.PricingConfig, kind string, subtotal float64, user *models.User, at time.Time) Breakdown {
+	exemptPct := 0.0
+	exoneracion := ""
+	if user != nil {
+		if exemptPct = user.ExemptPctAt(at); exemptPct > 0 {
+			exoneracion = *user.ExoneracionNumero
+		}
+	}
+	b := Compute(subtotal, RateFor(cfg, kind), exemptPct)
+	b.Kind = kind
+	b.Exoneracion = exoneracion
+	b.Display = DisplayMode(cfg)
+	return b
+}
+
+// ApplyToQuote freezes the tax breakdown on the quote (PriceFinal is the subtotal)
+func ApplyToQuote(q *models.Quote, cfg *pricing.PricingConfig, user *models.User) Breakdown {
+	materialIncluded := q.MaterialIncluded == nil || *q.MaterialIncluded
+	b := For(cfg, KindForMaterial(materialIncluded), q.PriceFinal, user, time.Now())
+
+	q.TaxKind = b.Kind
+	q.TaxRate = b.Rate
+	q.TaxExemptPct = b.ExemptPct
+	q.TaxAmount = b.Tax
+	q.PriceTotalWithTax = b.Total
+	q.TaxDisplay = b.Display
+	q.TaxExoneracion = nil
+	if b.Exoneracion != "" {
+		q.TaxExoneracion = &b.Exoneracion
+	}
+	return b
+}
+
+// Convert returns the breakdown expressed in another currency, rounded for display
+func (b Breakdown) Convert(from, to string, crcPerUSD float64) Breakdown {
+	conv := func(v float64) float64 {
+		return models.RoundCurrency(models.ConvertCurrency(v, from, to, crcPerUSD), to)
+	}
+	out := b
+	out.Subtotal = conv(b.Subtotal)
+	out.GrossTax = conv(b.GrossTax)
+	out.ExemptAmount = conv(b.ExemptAmount)
+	out.Tax = conv(b.Tax)
+	out.Total = models.RoundCurrency(out.Subtotal+out.Tax, to)
+	return out
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,48 @@
+package tax
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+)
+
+func TestCompute(t *testing.T) {
+	b := Compute(10000, 13, 0)
+	if b.Tax != 1300 || b.Total != 11300 || b.ExemptAmount != 0 {
+		t.Errorf("sin exoneración: %+v", b)
+	}
+
+	b = Compute(10000, 13, 50)
+	if b.GrossTax != 1300 || b.ExemptAmount != 650 || b.Tax != 650 || b.Total != 10650 {
+		t.Errorf("exoneración 50%%: %+v", b)
+	}
+
+	b = Compute(10000, 13, 150)
+	if b.ExemptPct != 100 || b.Tax != 0 || b.Total != 10000 {
+		t.Errorf("exoneración acotada a 100%%: %+v", b)
+	}
+}
+
+func TestForAppliesOnlyValidExemption(t *testing.T) {
+	numero := "AL-00012345-24"
+	vence := time.Date(2026, 12, 31, 0, 0, 0, 0, time.UTC)
+	user := &models.User{ExoneracionNumero: &numero, ExoneracionPct: 100, ExoneracionVence: &vence}
+
+	b := For(nil, KindServicio, 5000, user, time.Date(2026, 12, 31, 15, 0, 0, 0, time.UTC))
+	if b.Tax != 0 || b.Exoneracion != numero || b.Rate != DefaultRate {
+		t.Errorf("exoneración vigente: %+v", b)
+	}
+
+	b = For(nil, KindServicio, 5000, user, time.Date(2027, 1, 2, 0, 0, 0, 0, time.UTC))
+	if b.Tax != 650 || b.Exoneracion != "" {
+		t.Errorf("exoneración vencida: %+v", b)
+	}
+}
+
+func TestConvert(t *testing.T) {
+	b := Compute(50500, 13, 0).Convert(models.CurrencyCRC, models.CurrencyUSD, 505)
+	if b.Subtotal != 100 || b.Tax != 13 || b.Total != 113 {
+		t.Errorf("USD: %+v", b)
+	}
+}
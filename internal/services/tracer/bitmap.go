@@ -0,0 +1,160 @@
+package tracer
+
+import (
+	"image"
+)
+
+// bitmap es la imagen binarizada: true = tinta (parte del diseño)
+type bitmap struct {
+	w, h int
+	px   []bool
+}
+
+func (b *bitmap) at(x, y int) bool {
+	if x < 0 || y < 0 || x >= b.w || y >= b.h {
+		return false
+	}
+	return b.px[y*b.w+x]
+}
+
+// grayscale convierte a luminancia 0-255 sobre fondo blanco (lo transparente es
+// fondo) y reduce la imagen por promedio de bloques hasta que el lado mayor
+// quepa en maxSide. Las fotos de celular no necesitan más resolución para trazar.
+func grayscale(img image.Image, maxSide int) ([]uint8, int, int) {
+	bounds := img.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+
+	factor := 1
+	for srcW/factor > maxSide || srcH/factor > maxSide {
+		factor++
+	}
+	w, h := srcW/factor, srcH/factor
+	if w == 0 || h == 0 {
+		return nil, 0, 0
+	}
+
+	gray := make([]uint8, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var sum uint64
+			for dy := 0; dy < factor; dy++ {
+				for dx := 0; dx < factor; dx++ {
+					r, g, b, a := img.At(bounds.Min.X+x*factor+dx, bounds.Min.Y+y*factor+dy).RGBA()
+					// RGBA() viene premultiplicado: componer sobre blanco suma (1 - alpha)
+					bg := 0xffff - a
+					lum := (299*uint64(r+bg) + 587*uint64(g+bg) + 114*uint64(b+bg)) / 1000
+					sum += lum >> 8
+				}
+			}
+			gray[y*w+x] = uint8(sum / uint64(factor*factor))
+		}
+	}
+	return gray, w, h
+}
+
+// otsu calcula el umbral global que maximiza la varianza entre clases
+func otsu(gray []uint8) int {
+	var hist [256]int
+	for _, v := range gray {
+		hist[v]++
+	}
+
+	total := len(gray)
+	var sumAll float64
+	for i, n := range hist {
+		sumAll += float64(i * n)
+	}
+
+	var sumB, best float64
+	var wB int
+	threshold := 128
+	for t := 0; t < 256; t++ {
+		wB += hist[t]
+		if wB == 0 {
+			continue
+		}
+		wF := total - wB
+		if wF == 0 {
+			break
+		}
+		sumB += float64(t * hist[t])
+		mB := sumB / float64(wB)
+		mF := (sumAll - sumB) / float64(wF)
+		between := float64(wB) * float64(wF) * (mB - mF) * (mB - mF)
+		if between > best {
+			best = between
+			threshold = t
+		}
+	}
+	return threshold
+}
+
+// binarize marca como tinta lo más oscuro que el umbral global
+func binarize(gray []uint8, w, h, threshold int) *bitmap {
+	b := &bitmap{w: w, h: h, px: make([]bool, w*h)}
+	for i, v := range gray {
+		b.px[i] = int(v) <= threshold
+	}
+	return b
+}
+
+// binarizeAdaptive compara cada píxel con el promedio de su vecindario (imagen
+// integral). Sirve para fotos con sombras o luz despareja, donde un umbral
+// global se come parte del diseño.
+func binarizeAdaptive(gray []uint8, w, h int) *bitmap {
+	integral := make([]int, (w+1)*(h+1))
+	for y := 0; y < h; y++ {
+		rowSum := 0
+		for x := 0; x < w; x++ {
+			rowSum += int(gray[y*w+x])
+			integral[(y+1)*(w+1)+x+1] = integral[y*(w+1)+x+1] + rowSum
+		}
+	}
+
+	radius := min(w, h) / 16
+	if radius < 7 {
+		radius = 7
+	}
+	b := &bitmap{w: w, h: h, px: make([]bool, w*h)}
+	for y := 0; y < h; y++ {
+		y0, y1 := max(y-radius, 0), min(y+radius+1, h)
+		for x := 0; x < w; x++ {
+			x0, x1 := max(x-radius, 0), min(x+radius+1, w)
+			area := (x1 - x0) * (y1 - y0)
+			sum := integral[y1*(w+1)+x1] - integral[y0*(w+1)+x1] - integral[y1*(w+1)+x0] + integral[y0*(w+1)+x0]
+			b.px[y*w+x] = int(gray[y*w+x])*area < (sum - adaptiveOffset*area)
+		}
+	}
+	return b
+}
+
+// invertIfDarkBackground invierte cuando la mayoría del borde quedó como tinta:
+// es un diseño claro sobre fondo oscuro (ej. logo blanco sobre negro).
+func (b *bitmap) invertIfDarkBackground() bool {
+	var ink, total int
+	for x := 0; x < b.w; x++ {
+		total += 2
+		if b.at(x, 0) {
+			ink++
+		}
+		if b.at(x, b.h-1) {
+			ink++
+		}
+	}
+	for y := 1; y < b.h-1; y++ {
+		total += 2
+		if b.at(0, y) {
+			ink++
+		}
+		if b.at(b.w-1, y) {
+			ink++
+		}
+	}
+	if ink*2 <= total {
+		return false
+	}
+	for i := range b.px {
+		b.px[i] = !b.px[i]
+	}
+	return true
+}
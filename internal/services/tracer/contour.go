@@ -0,0 +1,219 @@
+package tracer
+
+import "math"
+
+type point struct{ X, Y float64 }
+
+// contour es un borde cerrado entre tinta y fondo sobre la grilla de esquinas de
+// píxel. Con la tinta siempre a la derecha del recorrido (eje Y hacia abajo), los
+// contornos exteriores tienen área con signo positiva y los huecos negativa.
+type contour struct {
+	points []point
+	area   float64 // con signo
+}
+
+func (c contour) isHole() bool { return c.area < 0 }
+
+// Direcciones sobre la grilla: derecha, abajo, izquierda, arriba
+var (
+	dirX = [4]int{1, 0, -1, 0}
+	dirY = [4]int{0, 1, 0, -1}
+)
+
+// traceContours recorre los bordes de la tinta al estilo potrace: cada arista
+// entre un píxel de tinta y uno de fondo es una arista dirigida, y los caminos
+// cerrados que las encadenan son los contornos. En los cruces diagonales se gira
+// siempre a la derecha, así dos píxeles que solo se tocan en una esquina quedan
+// como figuras separadas. Los contornos con área menor a minArea se descartan (ruido).
+func traceContours(b *bitmap, minArea float64) []contour {
+	stride := b.w + 1
+	out := make([]uint8, stride*(b.h+1))
+	for y := 0; y < b.h; y++ {
+		for x := 0; x < b.w; x++ {
+			if !b.at(x, y) {
+				continue
+			}
+			if !b.at(x, y-1) {
+				out[y*stride+x] |= 1 << 0
+			}
+			if !b.at(x+1, y) {
+				out[y*stride+x+1] |= 1 << 1
+			}
+			if !b.at(x, y+1) {
+				out[(y+1)*stride+x+1] |= 1 << 2
+			}
+			if !b.at(x-1, y) {
+				out[(y+1)*stride+x] |= 1 << 3
+			}
+		}
+	}
+
+	var contours []contour
+	for start := range out {
+		for out[start] != 0 {
+			dir := 0
+			for out[start]&(1<<dir) == 0 {
+				dir++
+			}
+
+			x, y := start%stride, start/stride
+			pts := []point{{float64(x), float64(y)}}
+			cur := start
+			for {
+				out[cur] &^= 1 << dir
+				x, y = x+dirX[dir], y+dirY[dir]
+				cur = y*stride + x
+				if cur == start {
+					break
+				}
+				next := -1
+				for _, turn := range [3]int{1, 0, 3} { // derecha, recto, izquierda
+					if d := (dir + turn) % 4; out[cur]&(1<<d) != 0 {
+						next = d
+						break
+					}
+				}
+				if next == -1 {
+					break // no debería pasar: cada vértice tiene tantas entradas como salidas
+				}
+				if next != dir {
+					pts = append(pts, point{float64(x), float64(y)})
+				}
+				dir = next
+			}
+
+			c := contour{points: pts, area: signedArea(pts)}
+			if math.Abs(c.area) >= minArea && len(pts) >= 4 {
+				contours = append(contours, c)
+			}
+		}
+	}
+	return contours
+}
+
+func signedArea(pts []point) float64 {
+	var sum float64
+	for i := range pts {
+		j := (i + 1) % len(pts)
+		sum += pts[i].X*pts[j].Y - pts[j].X*pts[i].Y
+	}
+	return sum / 2
+}
+
+// contains: ray casting de p contra el polígono
+func (c contour) contains(p point) bool {
+	inside := false
+	pts := c.points
+	for i, j := 0, len(pts)-1; i < len(pts); j, i = i, i+1 {
+		if (pts[i].Y > p.Y) != (pts[j].Y > p.Y) &&
+			p.X < (pts[j].X-pts[i].X)*(p.Y-pts[i].Y)/(pts[j].Y-pts[i].Y)+pts[i].X {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// probe retorna un punto del borde que no es vértice de la grilla (medio de una
+// arista vertical): nunca está sobre el borde de otro contorno, así que sirve
+// para decidir en qué contorno exterior cae un hueco.
+func (c contour) probe() point {
+	pts := c.points
+	for i := range pts {
+		j := (i + 1) % len(pts)
+		if pts[i].X == pts[j].X {
+			return point{pts[i].X, math.Min(pts[i].Y, pts[j].Y) + 0.5}
+		}
+	}
+	return pts[0]
+}
+
+// assignHoles asocia cada hueco al contorno exterior más chico que lo contiene.
+// Retorna, por índice de exterior, los índices de sus huecos.
+func assignHoles(contours []contour) map[int][]int {
+	holes := make(map[int][]int)
+	for h, hole := range contours {
+		if !hole.isHole() {
+			continue
+		}
+		p := hole.probe()
+		parent := -1
+		for o, outer := range contours {
+			if outer.isHole() || (parent != -1 && outer.area >= contours[parent].area) {
+				continue
+			}
+			if outer.contains(p) {
+				parent = o
+			}
+		}
+		if parent != -1 {
+			holes[parent] = append(holes[parent], h)
+		}
+	}
+	return holes
+}
+
+// simplify reduce el contorno con Douglas-Peucker: la escalera de píxeles se
+// vuelve rectas y curvas suaves. El polígono cerrado se parte en el punto más
+// lejano al primero y se simplifican las dos mitades.
+func simplify(pts []point, tolerance float64) []point {
+	if len(pts) < 4 {
+		return pts
+	}
+	far, farDist := 0, -1.0
+	for i, p := range pts {
+		if d := dist(p, pts[0]); d > farDist {
+			far, farDist = i, d
+		}
+	}
+
+	// Se cierra el polígono repitiendo el primer punto al final
+	closed := append(append(make([]point, 0, len(pts)+1), pts...), pts[0])
+	keep := make([]bool, len(closed))
+	keep[0], keep[far] = true, true
+	douglasPeucker(closed, 0, far, tolerance, keep)
+	douglasPeucker(closed, far, len(closed)-1, tolerance, keep)
+
+	var out []point
+	for i, k := range keep[:len(pts)] {
+		if k {
+			out = append(out, pts[i])
+		}
+	}
+	if len(out) < 3 {
+		return pts
+	}
+	return out
+}
+
+// douglasPeucker marca en keep los puntos entre first y last que se conservan
+func douglasPeucker(pts []point, first, last int, tolerance float64, keep []bool) {
+	if last-first < 2 {
+		return
+	}
+	idx, maxDist := 0, 0.0
+	for i := first + 1; i < last; i++ {
+		if d := segmentDistance(pts[i], pts[first], pts[last]); d > maxDist {
+			idx, maxDist = i, d
+		}
+	}
+	if maxDist <= tolerance {
+		return
+	}
+	keep[idx] = true
+	douglasPeucker(pts, first, idx, tolerance, keep)
+	douglasPeucker(pts, idx, last, tolerance, keep)
+}
+
+func segmentDistance(p, a, b point) float64 {
+	dx, dy := b.X-a.X, b.Y-a.Y
+	lenSq := dx*dx + dy*dy
+	if lenSq == 0 {
+		return dist(p, a)
+	}
+	t := math.Max(0, math.Min(1, ((p.X-a.X)*dx+(p.Y-a.Y)*dy)/lenSq))
+	return dist(p, point{a.X + t*dx, a.Y + t*dy})
+}
+
+func dist(a, b point) float64 {
+	return math.Hypot(a.X-b.X, a.Y-b.Y)
+}
@@ -0,0 +1,144 @@
+package tracer
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// segment es un tramo del trazo: recta (L, un punto) o Bézier cúbica (C, tres puntos).
+// El último punto es donde termina el tramo.
+type segment struct {
+	cubic bool
+	pts   []point
+}
+
+func (s segment) end() point { return s.pts[len(s.pts)-1] }
+
+// curve es un contorno ya ajustado: punto de inicio más tramos hasta volver a él
+type curve struct {
+	start    point
+	segments []segment
+}
+
+// fitCurve ajusta Béziers sobre el polígono simplificado al estilo potrace: el
+// trazo pasa por el punto medio de cada lado y en cada vértice decide entre
+// esquina (dos rectas) o curva suave (cuadrática con control en el vértice,
+// elevada a cúbica). Es esquina cuando el giro supera cornerAngle.
+func fitCurve(pts []point, cornerAngle float64) curve {
+	n := len(pts)
+	mid := func(i int) point {
+		a, b := pts[(i+n)%n], pts[(i+1)%n]
+		return point{(a.X + b.X) / 2, (a.Y + b.Y) / 2}
+	}
+
+	c := curve{start: mid(-1)}
+	from := c.start
+	for i := 0; i < n; i++ {
+		v := pts[i]
+		to := mid(i)
+		if turnAngle(pts[(i-1+n)%n], v, pts[(i+1)%n]) > cornerAngle {
+			c.segments = append(c.segments,
+				segment{pts: []point{v}},
+				segment{pts: []point{to}},
+			)
+		} else {
+			c1 := point{from.X + 2.0/3*(v.X-from.X), from.Y + 2.0/3*(v.Y-from.Y)}
+			c2 := point{to.X + 2.0/3*(v.X-to.X), to.Y + 2.0/3*(v.Y-to.Y)}
+			c.segments = append(c.segments, segment{cubic: true, pts: []point{c1, c2, to}})
+		}
+		from = to
+	}
+	return c
+}
+
+// turnAngle es el cambio de dirección en b, en radianes (0 = sigue recto)
+func turnAngle(a, b, c point) float64 {
+	ux, uy := b.X-a.X, b.Y-a.Y
+	vx, vy := c.X-b.X, c.Y-b.Y
+	lu, lv := math.Hypot(ux, uy), math.Hypot(vx, vy)
+	if lu == 0 || lv == 0 {
+		return 0
+	}
+	cos := (ux*vx + uy*vy) / (lu * lv)
+	return math.Acos(math.Max(-1, math.Min(1, cos)))
+}
+
+// transform escala y traslada el contorno (píxeles → mm)
+func (c curve) transform(f func(point) point) curve {
+	out := curve{start: f(c.start), segments: make([]segment, len(c.segments))}
+	for i, s := range c.segments {
+		pts := make([]point, len(s.pts))
+		for j, p := range s.pts {
+			pts[j] = f(p)
+		}
+		out.segments[i] = segment{cubic: s.cubic, pts: pts}
+	}
+	return out
+}
+
+// pathData arma el atributo d de un contorno
+func (c curve) pathData() string {
+	var b strings.Builder
+	b.WriteString("M" + coord(c.start))
+	writeSegments(&b, c.segments)
+	b.WriteString(" Z")
+	return b.String()
+}
+
+// keyholePath une un contorno exterior con sus huecos en un único trazo: desde
+// el final del tramo exterior más cercano a cada hueco sale un puente recto al
+// hueco, lo recorre y vuelve por el mismo puente. Ida y vuelta se cancelan en el
+// área, y como los huecos giran al revés que el exterior, el área del trazo es
+// la del relleno real (exterior menos huecos). Se hace así porque el analizador
+// mide cada <path> como un único polígono.
+func keyholePath(outer curve, holes []curve) string {
+	attach := make(map[int][]curve)
+	for _, h := range holes {
+		best, bestDist := -1, math.Inf(1)
+		if d := dist(outer.start, h.start); d < bestDist {
+			bestDist = d
+		}
+		for i, s := range outer.segments {
+			if d := dist(s.end(), h.start); d < bestDist {
+				best, bestDist = i, d
+			}
+		}
+		attach[best] = append(attach[best], h)
+	}
+
+	var b strings.Builder
+	b.WriteString("M" + coord(outer.start))
+	bridge := func(at point, hs []curve) {
+		for _, h := range hs {
+			b.WriteString(" L" + coord(h.start))
+			writeSegments(&b, h.segments)
+			b.WriteString(" L" + coord(at))
+		}
+	}
+	bridge(outer.start, attach[-1])
+	for i, s := range outer.segments {
+		writeSegments(&b, []segment{s})
+		bridge(s.end(), attach[i])
+	}
+	b.WriteString(" Z")
+	return b.String()
+}
+
+func writeSegments(b *strings.Builder, segments []segment) {
+	for _, s := range segments {
+		if s.cubic {
+			b.WriteString(" C" + coord(s.pts[0]) + " " + coord(s.pts[1]) + " " + coord(s.pts[2]))
+		} else {
+			b.WriteString(" L" + coord(s.pts[0]))
+		}
+	}
+}
+
+func coord(p point) string {
+	return num(p.X) + " " + num(p.Y)
+}
+
+func num(v float64) string {
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+}
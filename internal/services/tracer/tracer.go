@@ -0,0 +1,191 @@
+// Package tracer convierte fotos o imágenes de logos en SVG (vectorización) para
+// cotizarlas con la geometría real en lugar de adivinar medidas.
+//
+// Pipeline, todo en Go puro: escala de grises → umbral (Otsu o adaptativo) →
+// trazado de contornos estilo potrace → simplificación Douglas-Peucker → ajuste de
+// Béziers. El SVG sale en mm al tamaño físico pedido y con la convención de
+// colores del svgengine: relleno negro = grabado raster, trazo azul = grabado vectorial.
+package tracer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"  // registrar decoders
+	_ "image/jpeg" // para image.Decode
+	_ "image/png"
+	"math"
+	"strings"
+)
+
+var (
+	ErrInvalidImage = errors.New("no se pudo leer la imagen (formatos soportados: PNG, JPG, GIF)")
+	ErrInvalidSize  = errors.New("el ancho o alto del diseño debe estar entre 1 y 1000 mm")
+	ErrNoShapes     = errors.New("no se encontraron formas para vectorizar en la imagen")
+)
+
+// Modos de salida
+const (
+	ModeRaster = "raster" // Relleno negro: grabado de área
+	ModeVector = "vector" // Contornos azules: grabado vectorial
+)
+
+const (
+	maxDesignMM    = 1000.0 // área de trabajo de la máquina más grande
+	defaultMaxSide = 1000   // px de trabajo: suficiente para logos, rápido en fotos de 12MP
+	adaptiveOffset = 10     // cuánto más oscuro que el vecindario debe ser un píxel para ser tinta
+	defaultTol     = 1.0    // px, tolerancia de Douglas-Peucker
+	defaultCorner  = 1.0    // rad (~57°): giros mayores quedan como esquina
+)
+
+// Options controla la vectorización. Solo WidthMM o HeightMM es obligatorio: es
+// el tamaño físico del diseño (no de la foto) y el otro lado sale de la proporción.
+// Si vienen ambos, el diseño se ajusta dentro de ese tamaño sin deformarse.
+type Options struct {
+	WidthMM   float64
+	HeightMM  float64
+	Mode      string  // ModeRaster (default) o ModeVector
+	Threshold int     // 1-254 fija el umbral; 0 = automático (Otsu)
+	Adaptive  bool    // umbral por vecindario, para fotos con sombras
+	MinArea   float64 // px², manchas más chicas se descartan; 0 = automático
+	MaxSide   int     // px de trabajo; 0 = 1000
+}
+
+// Result es el SVG generado con sus medidas
+type Result struct {
+	SVG      string
+	WidthMM  float64
+	HeightMM float64
+	Shapes   int  // contornos exteriores
+	Holes    int  // huecos (interior de letras, etc.)
+	Inverted bool // el diseño era claro sobre fondo oscuro
+}
+
+// Trace decodifica la imagen y la vectoriza
+func Trace(data []byte, opts Options) (*Result, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+	return TraceImage(img, opts)
+}
+
+// TraceImage vectoriza una imagen ya decodificada
+func TraceImage(img image.Image, opts Options) (*Result, error) {
+	if opts.WidthMM < 0 || opts.HeightMM < 0 || opts.WidthMM > maxDesignMM || opts.HeightMM > maxDesignMM ||
+		(opts.WidthMM < 1 && opts.HeightMM < 1) {
+		return nil, ErrInvalidSize
+	}
+	if opts.Mode != ModeVector {
+		opts.Mode = ModeRaster
+	}
+	if opts.MaxSide <= 0 {
+		opts.MaxSide = defaultMaxSide
+	}
+
+	gray, w, h := grayscale(img, opts.MaxSide)
+	if w < 2 || h < 2 {
+		return nil, ErrInvalidImage
+	}
+
+	var bm *bitmap
+	switch {
+	case opts.Adaptive:
+		bm = binarizeAdaptive(gray, w, h)
+	case opts.Threshold > 0 && opts.Threshold < 255:
+		bm = binarize(gray, w, h, opts.Threshold)
+	default:
+		bm = binarize(gray, w, h, otsu(gray))
+	}
+	inverted := bm.invertIfDarkBackground()
+
+	minArea := opts.MinArea
+	if minArea <= 0 {
+		// Ruido de JPEG y polvo: ~0.01% de la imagen, mínimo 4 px²
+		minArea = math.Max(4, float64(w*h)/10000)
+	}
+	contours := traceContours(bm, minArea)
+
+	var shapes int
+	for _, c := range contours {
+		if !c.isHole() {
+			shapes++
+		}
+	}
+	if shapes == 0 {
+		return nil, ErrNoShapes
+	}
+
+	// Escala: la caja de los contornos exteriores mide lo pedido
+	minX, minY, maxX, maxY := bounds(contours)
+	scale := math.Inf(1)
+	if opts.WidthMM >= 1 {
+		scale = opts.WidthMM / (maxX - minX)
+	}
+	if opts.HeightMM >= 1 {
+		scale = math.Min(scale, opts.HeightMM/(maxY-minY))
+	}
+	toMM := func(p point) point {
+		return point{(p.X - minX) * scale, (p.Y - minY) * scale}
+	}
+
+	curves := make([]curve, len(contours))
+	for i, c := range contours {
+		curves[i] = fitCurve(simplify(c.points, defaultTol), defaultCorner).transform(toMM)
+	}
+
+	result := &Result{
+		WidthMM:  round2((maxX - minX) * scale),
+		HeightMM: round2((maxY - minY) * scale),
+		Shapes:   shapes,
+		Holes:    len(contours) - shapes,
+		Inverted: inverted,
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%smm" height="%smm" viewBox="0 0 %s %s">`+"\n",
+		num(result.WidthMM), num(result.HeightMM), num(result.WidthMM), num(result.HeightMM))
+
+	if opts.Mode == ModeVector {
+		// Cada borde (exterior o hueco) es una línea de grabado
+		for _, c := range curves {
+			fmt.Fprintf(&b, `<path d="%s" fill="none" stroke="#0000FF"/>`+"\n", c.pathData())
+		}
+	} else {
+		holes := assignHoles(contours)
+		for i, c := range contours {
+			if c.isHole() {
+				continue
+			}
+			var hs []curve
+			for _, h := range holes[i] {
+				hs = append(hs, curves[h])
+			}
+			fmt.Fprintf(&b, `<path d="%s" fill="#000000" stroke="none"/>`+"\n", keyholePath(curves[i], hs))
+		}
+	}
+	b.WriteString("</svg>\n")
+	result.SVG = b.String()
+	return result, nil
+}
+
+// bounds es la caja de los contornos exteriores (los huecos quedan adentro)
+func bounds(contours []contour) (minX, minY, maxX, maxY float64) {
+	minX, minY = math.Inf(1), math.Inf(1)
+	maxX, maxY = math.Inf(-1), math.Inf(-1)
+	for _, c := range contours {
+		if c.isHole() {
+			continue
+		}
+		for _, p := range c.points {
+			minX, maxX = math.Min(minX, p.X), math.Max(maxX, p.X)
+			minY, maxY = math.Min(minY, p.Y), math.Max(maxY, p.Y)
+		}
+	}
+	return minX, minY, maxX, maxY
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
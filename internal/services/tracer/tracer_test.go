@@ -0,0 +1,119 @@
+package tracer
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"testing"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/services/svgengine"
+)
+
+// marco negro de 160×60 px con un hueco de 120×20, sobre fondo blanco
+func frameImage() *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, 200, 100))
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 200; x++ {
+			ink := x >= 20 && x < 180 && y >= 20 && y < 80 && !(x >= 40 && x < 160 && y >= 40 && y < 60)
+			if ink {
+				img.SetGray(x, y, color.Gray{Y: 0})
+			} else {
+				img.SetGray(x, y, color.Gray{Y: 255})
+			}
+		}
+	}
+	return img
+}
+
+func analyze(t *testing.T, svg string) *svgengine.AnalysisResult {
+	t.Helper()
+	result, err := svgengine.NewAnalyzer().Analyze(svg)
+	if err != nil {
+		t.Fatalf("Analyze: %v\n%s", err, svg)
+	}
+	return result
+}
+
+func TestTraceRaster(t *testing.T) {
+	res, err := TraceImage(frameImage(), Options{WidthMM: 160})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.WidthMM != 160 || res.HeightMM != 60 || res.Shapes != 1 || res.Holes != 1 {
+		t.Fatalf("result = %.2f×%.2f, %d shapes, %d holes", res.WidthMM, res.HeightMM, res.Shapes, res.Holes)
+	}
+
+	a := analyze(t, res.SVG)
+	// Relleno real: marco menos hueco
+	if want := 160.0*60 - 120*20; math.Abs(a.RasterAreaMM2-want) > 1 {
+		t.Errorf("raster area = %.2f, want %.2f", a.RasterAreaMM2, want)
+	}
+	if a.CutLengthMM != 0 || a.VectorLengthMM != 0 {
+		t.Errorf("unexpected cut/vector: %.2f / %.2f", a.CutLengthMM, a.VectorLengthMM)
+	}
+}
+
+func TestTraceVectorFromPNG(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, frameImage()); err != nil {
+		t.Fatal(err)
+	}
+	// 80 mm de ancho → 0.5 mm por píxel
+	res, err := Trace(buf.Bytes(), Options{WidthMM: 80, Mode: ModeVector})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := analyze(t, res.SVG)
+	if want := (2*(160+60) + 2*(120+20)) * 0.5; math.Abs(a.VectorLengthMM-want) > 1 {
+		t.Errorf("vector length = %.2f, want %.2f", a.VectorLengthMM, want)
+	}
+	if a.RasterAreaMM2 != 0 {
+		t.Errorf("raster area = %.2f, want 0", a.RasterAreaMM2)
+	}
+}
+
+func TestTraceCircleAndInverted(t *testing.T) {
+	// Disco blanco de radio 40 px sobre fondo negro: se invierte y el disco es el diseño
+	img := image.NewGray(image.Rect(0, 0, 120, 120))
+	for y := 0; y < 120; y++ {
+		for x := 0; x < 120; x++ {
+			if math.Hypot(float64(x)+0.5-60, float64(y)+0.5-60) <= 40 {
+				img.SetGray(x, y, color.Gray{Y: 250})
+			} else {
+				img.SetGray(x, y, color.Gray{Y: 10})
+			}
+		}
+	}
+
+	res, err := TraceImage(img, Options{WidthMM: 80})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Inverted || res.Shapes != 1 {
+		t.Fatalf("inverted = %v, shapes = %d", res.Inverted, res.Shapes)
+	}
+	a := analyze(t, res.SVG)
+	if want := math.Pi * 40 * 40; math.Abs(a.RasterAreaMM2-want)/want > 0.03 {
+		t.Errorf("disc area = %.2f, want ≈ %.2f", a.RasterAreaMM2, want)
+	}
+}
+
+func TestTraceErrors(t *testing.T) {
+	if _, err := TraceImage(frameImage(), Options{}); !errors.Is(err, ErrInvalidSize) {
+		t.Errorf("missing size: err = %v", err)
+	}
+	blank := image.NewGray(image.Rect(0, 0, 50, 50))
+	for i := range blank.Pix {
+		blank.Pix[i] = 255
+	}
+	if _, err := TraceImage(blank, Options{WidthMM: 50}); !errors.Is(err, ErrNoShapes) {
+		t.Errorf("blank image: err = %v", err)
+	}
+	if _, err := Trace([]byte("no es imagen"), Options{WidthMM: 50}); !errors.Is(err, ErrInvalidImage) {
+		t.Errorf("garbage: err = %v", err)
+	}
+}
@@ -0,0 +1,102 @@
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// Los endpoints los configura el admin de cada tenant y la bitácora muestra la
+// respuesta, así que el dispatcher solo se conecta a direcciones públicas: nada
+// de loopback, redes privadas, link-local (metadata de la nube) ni rangos
+// reservados. Se valida al guardar el endpoint y otra vez al conectar, porque
+// el DNS puede cambiar entre una cosa y la otra.
+
+// blockedNets son los rangos reservados que net.IP no clasifica
+var blockedNets = mustParseCIDRs(
+	"0.0.0.0/8",       // "Esta" red
+	"100.64.0.0/10",   // CGNAT
+	"192.0.0.0/24",    // Asignaciones de protocolo IETF
+	"192.0.2.0/24",    // TEST-NET-1
+	"198.18.0.0/15",   // Pruebas de rendimiento
+	"198.51.100.0/24", // TEST-NET-2
+	"203.0.113.0/24",  // TEST-NET-3
+	"240.0.0.0/4",     // Reservado
+	"64:ff9b::/96",    // NAT64: puede traducir a una IPv4 interna
+	"2001:db8::/32",   // Documentación
+)
+
+// lookupIPAddr resuelve los hosts de los endpoints (reemplazable en tests)
+var lookupIPAddr = net.DefaultResolver.LookupIPAddr
+
+// publicIP indica si ip es una dirección pública a la que se puede entregar
+func publicIP(ip net.IP) bool {
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, n := range blockedNets {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkHost resuelve host y exige que todas sus direcciones sean públicas
+func checkHost(ctx context.Context, host string) error {
+	addrs, err := lookupIPAddr(ctx, host)
+	if err != nil || len(addrs) == 0 {
+		return fmt.Errorf("%w: no se pudo resolver %s", ErrInvalidURL, host)
+	}
+	for _, addr := range addrs {
+		if !publicIP(addr.IP) {
+			return fmt.Errorf("%w: %s apunta a una dirección interna", ErrInvalidURL, host)
+		}
+	}
+	return nil
+}
+
+// newClient arma el cliente de las entregas: sin proxy y con un dialer que
+// rechaza las direcciones no públicas después de resolver el DNS, también en
+// las redirecciones
+func newClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if !publicIP(net.ParseIP(host)) {
+				return fmt.Errorf("webhooks: conexión a %s bloqueada: dirección interna", host)
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout: requestTimeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 5 * time.Second,
+			MaxIdleConns:        20,
+			IdleConnTimeout:     90 * time.Second,
+		},
+	}
+}
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	nets := make([]*net.IPNet, len(cidrs))
+	for i, c := range cidrs {
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			panic(err)
+		}
+		nets[i] = n
+	}
+	return nets
+}
@@ -0,0 +1,454 @@
+// Package webhooks entrega los eventos de cotizaciones y pedidos a los endpoints
+// suscritos por cada tenant o cliente de la API.
+//
+// Cada entrega es un POST JSON firmado con HMAC-SHA256, con el mismo esquema que
+// verificamos en los webhooks entrantes de Meta (X-Hub-Signature-256). Las
+// entregas quedan en webhook_deliveries; las fallidas se reintentan con espera
+// exponencial desde un job periódico, y un endpoint que falla demasiadas veces
+// seguidas se deshabilita solo. El panel puede reenviar cualquier entrega.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/events"
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+	"github.com/alonsoalpizar/fabricalaser/internal/repository"
+	"github.com/alonsoalpizar/fabricalaser/internal/tenant"
+)
+
+var ErrEndpointDisabled = errors.New("el endpoint está deshabilitado")
+
+// PingEvent es el evento de prueba que se envía desde el panel
+const PingEvent = "webhook.ping"
+
+const (
+	maxAttempts      = 8
+	firstRetryDelay  = 30 * time.Second
+	maxRetryDelay    = 6 * time.Hour
+	disableAfter     = 15 // Intentos fallidos seguidos que deshabilitan el endpoint
+	dueBatch         = 50
+	maxResponseBytes = 2048
+	requestTimeout   = 10 * time.Second
+	sendLease        = 2 * time.Minute // Una entrega en curso no se reintenta antes de esto
+	userAgent        = "FabricaLaser-Webhooks/1.0"
+)
+
+// EventTypes son los eventos a los que se puede suscribir un endpoint
+var EventTypes = []string{
+	events.QuoteCreated,
+	events.QuoteApproved,
+	events.QuoteRejected,
+	events.QuoteExpiring,
+	events.OrderStatusChanged,
+}
+
+// Payload es el cuerpo JSON de cada entrega
+type Payload struct {
+	ID        string      `json:"id"` // evt_<hex>, igual en reintentos y reenvíos
+	Type      string      `json:"type"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      PayloadData `json:"data"`
+}
+
+type PayloadData struct {
+	Quote  *QuoteData `json:"quote,omitempty"`
+	Status string     `json:"status,omitempty"` // Estado nuevo (order.status_changed)
+	Note   string     `json:"note,omitempty"`   // Notas del revisor o motivo
+}
+
+// QuoteData es la cotización en su moneda, sin el desglose interno de costos
+type QuoteData struct {
+	ID            uint      `json:"id"`
+	UserID        uint      `json:"user_id"`
+	Status        string    `json:"status"`
+	Source        string    `json:"source"`
+	Quantity      int       `json:"quantity"`
+	Currency      string    `json:"currency"`
+	Subtotal      float64   `json:"subtotal"` // Sin IVA
+	Tax           float64   `json:"tax"`
+	Total         float64   `json:"total"`
+	PaymentStatus string    `json:"payment_status"`
+	ValidUntil    time.Time `json:"valid_until"`
+}
+
+type Dispatcher struct {
+	repo      *repository.WebhookRepository
+	quoteRepo *repository.QuoteRepository
+	keyRepo   *repository.APIKeyRepository
+	client    *http.Client
+	now       func() time.Time
+}
+
+func NewDispatcher() *Dispatcher {
+	return &Dispatcher{
+		repo:      repository.NewWebhookRepository(),
+		quoteRepo: repository.NewQuoteRepository(),
+		keyRepo:   repository.NewAPIKeyRepository(),
+		client:    newClient(),
+		now:       time.Now,
+	}
+}
+
+// Start se suscribe a los eventos de cotizaciones y pedidos y arranca el job de
+// reintentos, que corre cada interval hasta que ctx termine.
+func (d *Dispatcher) Start(ctx context.Context, bus *events.Bus, interval time.Duration) {
+	bus.Subscribe(d.Handle, EventTypes...)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if sent, failed := d.RetryDue(ctx); sent > 0 || failed > 0 {
+					slog.Info("webhooks: reintentos", "enviados", sent, "fallidos", failed)
+				}
+			}
+		}
+	}()
+}
+
+// Handle entrega un evento a los endpoints del tenant suscritos a su tipo
+func (d *Dispatcher) Handle(ctx context.Context, ev events.Event) {
+	if err := d.handle(ctx, ev); err != nil {
+		slog.Error("webhooks: error procesando evento",
+			"tipo", ev.Type,
+			"tenant_id", ev.TenantID,
+			"quote_id", ev.QuoteID,
+			"error", err,
+		)
+	}
+}
+
+func (d *Dispatcher) handle(ctx context.Context, ev events.Event) error {
+	repo := d.repo.WithContext(ctx)
+	endpoints, err := repo.FindActiveEndpoints()
+	if err != nil {
+		return fmt.Errorf("error buscando endpoints: %w", err)
+	}
+	if len(endpoints) == 0 {
+		return nil
+	}
+
+	var quote *models.Quote
+	if ev.QuoteID > 0 {
+		if quote, err = d.quoteRepo.WithContext(ctx).FindByID(ev.QuoteID); err != nil {
+			return fmt.Errorf("cotización no encontrada: %w", err)
+		}
+	}
+
+	payload := Payload{
+		ID:        newEventID(),
+		Type:      ev.Type,
+		CreatedAt: ev.OccurredAt,
+		Data:      PayloadData{Quote: toQuoteData(quote), Status: ev.Status, Note: ev.Note},
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	now := d.now()
+	for i := range endpoints {
+		ep := &endpoints[i]
+		if !ep.Wants(ev.Type) || !visibleTo(ep, quote, now) {
+			continue
+		}
+		delivery := &models.WebhookDelivery{
+			EndpointID:    ep.ID,
+			EventID:       payload.ID,
+			EventType:     ev.Type,
+			Payload:       body,
+			Status:        models.WebhookPending,
+			NextAttemptAt: leased(now),
+		}
+		if quote != nil {
+			delivery.QuoteID = &quote.ID
+		}
+		if err := repo.CreateDelivery(delivery); err != nil {
+			slog.Error("webhooks: error registrando entrega", "endpoint_id", ep.ID, "error", err)
+			continue
+		}
+		d.attempt(ctx, ep, delivery)
+	}
+	return nil
+}
+
+// visibleTo indica si el endpoint puede recibir el evento: los de una API key
+// solo reciben las cotizaciones de la cuenta de la key, mientras siga vigente
+func visibleTo(ep *models.WebhookEndpoint, quote *models.Quote, now time.Time) bool {
+	if ep.APIKeyID == nil {
+		return true
+	}
+	return ep.APIKey != nil && ep.APIKey.Usable(now) && quote != nil && quote.UserID == ep.APIKey.UserID
+}
+
+// Replay reenvía una entrega desde el panel como una entrega nueva, con el mismo
+// event id y cuerpo
+func (d *Dispatcher) Replay(ctx context.Context, deliveryID uint) (*models.WebhookDelivery, error) {
+	repo := d.repo.WithContext(ctx)
+	orig, err := repo.FindDelivery(deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	ep, err := repo.FindEndpoint(orig.EndpointID)
+	if err != nil {
+		return nil, err
+	}
+	if !ep.IsActive {
+		return nil, ErrEndpointDisabled
+	}
+
+	now := d.now()
+	delivery := &models.WebhookDelivery{
+		EndpointID:    ep.ID,
+		EventID:       orig.EventID,
+		EventType:     orig.EventType,
+		QuoteID:       orig.QuoteID,
+		Payload:       orig.Payload,
+		Status:        models.WebhookPending,
+		NextAttemptAt: leased(now),
+		ReplayOfID:    &orig.ID,
+	}
+	if err := repo.CreateDelivery(delivery); err != nil {
+		return nil, err
+	}
+	d.attempt(ctx, ep, delivery)
+	return delivery, nil
+}
+
+// Test envía un webhook.ping al endpoint para probar la URL y la firma
+func (d *Dispatcher) Test(ctx context.Context, endpointID uint) (*models.WebhookDelivery, error) {
+	repo := d.repo.WithContext(ctx)
+	ep, err := repo.FindEndpoint(endpointID)
+	if err != nil {
+		return nil, err
+	}
+	if !ep.IsActive {
+		return nil, ErrEndpointDisabled
+	}
+
+	now := d.now()
+	eventID := newEventID()
+	body, err := json.Marshal(Payload{ID: eventID, Type: PingEvent, CreatedAt: now})
+	if err != nil {
+		return nil, err
+	}
+	delivery := &models.WebhookDelivery{
+		EndpointID:    ep.ID,
+		EventID:       eventID,
+		EventType:     PingEvent,
+		Payload:       body,
+		Status:        models.WebhookPending,
+		NextAttemptAt: leased(now),
+	}
+	if err := repo.CreateDelivery(delivery); err != nil {
+		return nil, err
+	}
+	d.attempt(ctx, ep, delivery)
+	return delivery, nil
+}
+
+// RetryDue reintenta las entregas pendientes de todos los tenants cuyo turno ya
+// llegó. Las reclama de forma atómica: otra instancia no las toma mientras se envían.
+func (d *Dispatcher) RetryDue(ctx context.Context) (sent, failed int) {
+	now := d.now()
+	due, err := d.repo.WithContext(ctx).ClaimDue(now, *leased(now), dueBatch)
+	if err != nil {
+		slog.Error("webhooks: error buscando entregas pendientes", "error", err)
+		return 0, 0
+	}
+
+	endpoints := map[uint]*models.WebhookEndpoint{}
+	for i := range due {
+		delivery := &due[i]
+		tctx := tenant.WithID(ctx, delivery.TenantID)
+		ep, ok := endpoints[delivery.EndpointID]
+		if !ok {
+			if ep, err = d.repo.WithContext(tctx).FindEndpoint(delivery.EndpointID); err != nil {
+				ep = nil
+			}
+			endpoints[delivery.EndpointID] = ep
+		}
+		if ep == nil {
+			d.fail(tctx, delivery, "endpoint no encontrado")
+		} else {
+			d.attempt(tctx, ep, delivery)
+		}
+		switch delivery.Status {
+		case models.WebhookSent:
+			sent++
+		case models.WebhookFailed:
+			failed++
+		}
+	}
+	return sent, failed
+}
+
+// attempt envía y deja el resultado en la bitácora: sent, pending con el próximo
+// intento programado, o failed al agotar los intentos o deshabilitarse el endpoint.
+// Cada falla cuenta para el endpoint; un éxito reinicia el contador.
+func (d *Dispatcher) attempt(ctx context.Context, ep *models.WebhookEndpoint, delivery *models.WebhookDelivery) {
+	ctx = tenant.WithID(ctx, delivery.TenantID)
+	if !ep.IsActive {
+		d.fail(ctx, delivery, ErrEndpointDisabled.Error())
+		return
+	}
+
+	status, respBody, err := d.send(ctx, ep, delivery)
+	now := d.now()
+	repo := d.repo.WithContext(ctx)
+
+	delivery.Attempts++
+	if status > 0 {
+		delivery.ResponseStatus = &status
+		delivery.ResponseBody = &respBody
+	}
+	if err == nil {
+		delivery.Status = models.WebhookSent
+		delivery.DeliveredAt = &now
+		delivery.NextAttemptAt = nil
+		delivery.LastError = nil
+		if err := repo.MarkEndpointSuccess(ep.ID); err != nil {
+			slog.Error("webhooks: error actualizando endpoint", "endpoint_id", ep.ID, "error", err)
+		}
+		ep.ConsecutiveFailures = 0
+	} else {
+		msg := err.Error()
+		delivery.LastError = &msg
+		if next, retry := nextAttempt(delivery.Attempts, now); retry {
+			delivery.NextAttemptAt = &next
+		} else {
+			delivery.Status = models.WebhookFailed
+			delivery.NextAttemptAt = nil
+		}
+		slog.Warn("webhooks: error entregando evento",
+			"id", delivery.ID,
+			"endpoint_id", ep.ID,
+			"intento", delivery.Attempts,
+			"error", err,
+		)
+
+		reason := fmt.Sprintf("%d intentos fallidos seguidos; último error: %s", disableAfter, msg)
+		disabled, err := repo.MarkEndpointFailure(ep.ID, disableAfter, reason, now)
+		if err != nil {
+			slog.Error("webhooks: error actualizando endpoint", "endpoint_id", ep.ID, "error", err)
+		}
+		ep.ConsecutiveFailures++
+		if disabled {
+			ep.IsActive = false
+			ep.DisabledAt = &now
+			ep.DisabledReason = &reason
+			delivery.Status = models.WebhookFailed
+			delivery.NextAttemptAt = nil
+			slog.Warn("webhooks: endpoint deshabilitado", "endpoint_id", ep.ID, "url", ep.URL)
+		}
+	}
+
+	if err := repo.UpdateDelivery(delivery); err != nil {
+		slog.Error("webhooks: error actualizando bitácora", "id", delivery.ID, "error", err)
+	}
+}
+
+// leased es el next_attempt_at de una entrega que se está enviando: si el
+// proceso cae antes de registrar el resultado, el job la retoma al vencer
+func leased(now time.Time) *time.Time {
+	until := now.Add(sendLease)
+	return &until
+}
+
+// fail cierra una entrega sin enviarla
+func (d *Dispatcher) fail(ctx context.Context, delivery *models.WebhookDelivery, reason string) {
+	delivery.Status = models.WebhookFailed
+	delivery.NextAttemptAt = nil
+	delivery.LastError = &reason
+	if err := d.repo.WithContext(ctx).UpdateDelivery(delivery); err != nil {
+		slog.Error("webhooks: error actualizando bitácora", "id", delivery.ID, "error", err)
+	}
+}
+
+// send hace el POST firmado; cualquier 2xx es éxito. Retorna el status y los
+// primeros 2 KB de la respuesta.
+func (d *Dispatcher) send(ctx context.Context, ep *models.WebhookEndpoint, delivery *models.WebhookDelivery) (int, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ep.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("X-Hub-Signature-256", Sign(ep.Secret, delivery.Payload))
+	req.Header.Set("X-FabricaLaser-Event", delivery.EventType)
+	req.Header.Set("X-FabricaLaser-Delivery", delivery.EventID)
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, string(body), fmt.Errorf("el endpoint respondió HTTP %d", resp.StatusCode)
+	}
+	return resp.StatusCode, string(body), nil
+}
+
+// Sign retorna la firma X-Hub-Signature-256 del cuerpo: sha256=<HMAC-SHA256 hex>
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// nextAttempt programa el reintento con espera exponencial (30s, 1m, 2m, ... hasta
+// 6h) según cuántos intentos van; false = no reintentar más
+func nextAttempt(attempts int, now time.Time) (time.Time, bool) {
+	if attempts >= maxAttempts {
+		return time.Time{}, false
+	}
+	delay := maxRetryDelay
+	if attempts < 20 {
+		delay = min(firstRetryDelay<<(attempts-1), maxRetryDelay)
+	}
+	return now.Add(delay), true
+}
+
+func toQuoteData(q *models.Quote) *QuoteData {
+	if q == nil {
+		return nil
+	}
+	return &QuoteData{
+		ID:            q.ID,
+		UserID:        q.UserID,
+		Status:        string(q.Status),
+		Source:        q.Source,
+		Quantity:      q.Quantity,
+		Currency:      q.Currency,
+		Subtotal:      q.ToDisplayCurrency(q.PriceFinal),
+		Tax:           q.ToDisplayCurrency(q.TaxAmount),
+		Total:         q.ToDisplayCurrency(q.PriceTotalWithTax),
+		PaymentStatus: q.PaymentStatus,
+		ValidUntil:    q.ValidUntil,
+	}
+}
+
+func newEventID() string {
+	buf := make([]byte, 12)
+	_, _ = rand.Read(buf)
+	return "evt_" + hex.EncodeToString(buf)
+}
@@ -0,0 +1,166 @@
+package webhooks
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+)
+
+var (
+	ErrInvalidURL   = errors.New("la URL debe ser https hacia un host público")
+	ErrInvalidEvent = errors.New("tipo de evento inválido")
+)
+
+const secretPrefix = "whsec_"
+
+// EndpointInput son los datos editables de una suscripción
+type EndpointInput struct {
+	URL         string   `json:"url"`
+	Events      []string `json:"events"` // vacío = todos
+	Description string   `json:"description"`
+	APIKeyID    *uint    `json:"api_key_id"` // nil = todo el tenant
+	IsActive    *bool    `json:"is_active"`  // nil = sin cambio
+}
+
+// CreateEndpoint valida y guarda una suscripción. Retorna el secreto de firma,
+// que solo se muestra esta vez.
+func (d *Dispatcher) CreateEndpoint(ctx context.Context, in EndpointInput, createdBy uint) (*models.WebhookEndpoint, string, error) {
+	secret, err := generateSecret()
+	if err != nil {
+		return nil, "", err
+	}
+	ep := &models.WebhookEndpoint{Secret: secret, IsActive: true, CreatedBy: &createdBy}
+	if err := d.apply(ctx, ep, in); err != nil {
+		return nil, "", err
+	}
+	if err := d.repo.WithContext(ctx).CreateEndpoint(ep); err != nil {
+		return nil, "", err
+	}
+	return ep, secret, nil
+}
+
+// UpdateEndpoint edita una suscripción. Reactivarla reinicia el contador de fallas.
+func (d *Dispatcher) UpdateEndpoint(ctx context.Context, id uint, in EndpointInput) (*models.WebhookEndpoint, error) {
+	repo := d.repo.WithContext(ctx)
+	ep, err := repo.FindEndpoint(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.apply(ctx, ep, in); err != nil {
+		return nil, err
+	}
+	if err := repo.UpdateEndpoint(ep); err != nil {
+		return nil, err
+	}
+	return ep, nil
+}
+
+// RotateSecret reemplaza el secreto de firma y retorna el nuevo
+func (d *Dispatcher) RotateSecret(ctx context.Context, id uint) (string, error) {
+	repo := d.repo.WithContext(ctx)
+	ep, err := repo.FindEndpoint(id)
+	if err != nil {
+		return "", err
+	}
+	secret, err := generateSecret()
+	if err != nil {
+		return "", err
+	}
+	ep.Secret = secret
+	if err := repo.UpdateEndpoint(ep); err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+// DeleteEndpoint elimina una suscripción con su bitácora
+func (d *Dispatcher) DeleteEndpoint(ctx context.Context, id uint) error {
+	return d.repo.WithContext(ctx).DeleteEndpoint(id)
+}
+
+// Endpoints lista las suscripciones del tenant
+func (d *Dispatcher) Endpoints(ctx context.Context) ([]models.WebhookEndpoint, error) {
+	return d.repo.WithContext(ctx).FindEndpoints()
+}
+
+// Deliveries lista la bitácora de entregas del tenant
+func (d *Dispatcher) Deliveries(ctx context.Context, page, limit int, endpointID uint, status string) ([]models.WebhookDelivery, int64, error) {
+	return d.repo.WithContext(ctx).FindDeliveries(page, limit, endpointID, status)
+}
+
+// apply valida in y lo copia en ep
+func (d *Dispatcher) apply(ctx context.Context, ep *models.WebhookEndpoint, in EndpointInput) error {
+	rawURL := strings.TrimSpace(in.URL)
+	if err := validateURL(rawURL); err != nil {
+		return err
+	}
+	u, _ := url.Parse(rawURL)
+	if err := checkHost(ctx, u.Hostname()); err != nil {
+		return err
+	}
+	for _, t := range in.Events {
+		if !slices.Contains(EventTypes, t) {
+			return ErrInvalidEvent
+		}
+	}
+	if in.APIKeyID != nil {
+		// La key debe ser del mismo tenant
+		if _, err := d.keyRepo.WithContext(ctx).FindByID(*in.APIKeyID); err != nil {
+			return err
+		}
+	}
+
+	events := in.Events
+	if events == nil {
+		events = []string{}
+	}
+	eventsJSON, _ := json.Marshal(events)
+
+	ep.URL = rawURL
+	ep.Events = eventsJSON
+	ep.Description = strings.TrimSpace(in.Description)
+	ep.APIKeyID = in.APIKeyID
+	ep.APIKey = nil
+	if in.IsActive != nil {
+		if *in.IsActive && !ep.IsActive {
+			ep.ConsecutiveFailures = 0
+			ep.DisabledAt = nil
+			ep.DisabledReason = nil
+		}
+		ep.IsActive = *in.IsActive
+	}
+	return nil
+}
+
+// validateURL exige una URL https; una IP literal debe ser pública (los
+// nombres se resuelven en checkHost)
+func validateURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Hostname() == "" || u.Scheme != "https" || u.User != nil {
+		return ErrInvalidURL
+	}
+	host := strings.ToLower(u.Hostname())
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrInvalidURL
+	}
+	if ip := net.ParseIP(host); ip != nil && !publicIP(ip) {
+		return ErrInvalidURL
+	}
+	return nil
+}
+
+func generateSecret() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return secretPrefix + hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,92 @@
+// Package sse escribe respuestas Server-Sent Events para los chats en streaming
+// (chat web y chat admin). Cada evento lleva un nombre y un payload JSON:
+//
+//	event: text
+//	data: {"text":"Hola"}
+//
+// Los handlers generan la respuesta con un contexto desligado del request para
+// poder guardarla completa aunque el cliente cierre la conexión: Writer descarta
+// en silencio los eventos desde que el cliente se desconecta.
+package sse
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+)
+
+// ErrUnsupported indica que el ResponseWriter no permite hacer flush
+var ErrUnsupported = errors.New("sse: la conexión no soporta streaming")
+
+// Writer emite eventos sobre una respuesta HTTP. Es seguro usarlo desde varias
+// goroutines.
+type Writer struct {
+	mu      sync.Mutex
+	w       http.ResponseWriter
+	flusher http.Flusher
+	done    <-chan struct{}
+	closed  bool
+}
+
+// NewWriter escribe los headers del stream y lo deja abierto. Después de esto
+// la respuesta ya es 200: los errores van como eventos.
+func NewWriter(w http.ResponseWriter, r *http.Request) (*Writer, error) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, ErrUnsupported
+	}
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Connection", "keep-alive")
+	h.Set("X-Accel-Buffering", "no") // nginx: no bufferizar el stream
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	return &Writer{w: w, flusher: flusher, done: r.Context().Done()}, nil
+}
+
+// Send emite el evento con data serializado a JSON. Retorna false si el
+// cliente ya no está conectado (el evento se descarta).
+func (s *Writer) Send(event string, data any) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	select {
+	case <-s.done:
+		s.closed = true
+		return false
+	default:
+	}
+
+	payload, err := json.Marshal(data)
+	if err != nil {
+		slog.Error("sse: error serializando evento", "event", event, "error", err)
+		return true
+	}
+	if _, err := fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event, payload); err != nil {
+		s.closed = true
+		return false
+	}
+	s.flusher.Flush()
+	return true
+}
+
+// Connected indica si el cliente sigue conectado
+func (s *Writer) Connected() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	select {
+	case <-s.done:
+		s.closed = true
+	default:
+	}
+	return !s.closed
+}
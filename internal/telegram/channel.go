@@ -0,0 +1,171 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/channels"
+	"github.com/alonsoalpizar/fabricalaser/internal/whatsapp"
+)
+
+// Channel conecta Telegram Bot API al motor de conversación.
+// La clave de conversación es "tg:<chat_id>" (columna phone de whatsapp_conversations).
+type Channel struct {
+	sender          *Sender
+	contextProvider *whatsapp.WAContextProvider
+}
+
+// NewChannel construye el canal; contextProvider aporta el chat ID del asesor.
+func NewChannel(contextProvider *whatsapp.WAContextProvider) *Channel {
+	return &Channel{
+		sender:          NewSender(),
+		contextProvider: contextProvider,
+	}
+}
+
+func (c *Channel) Name() string      { return channels.Telegram }
+func (c *Channel) Label() string     { return "Telegram" }
+func (c *Channel) Namespace() string { return "tg" }
+
+// Normalize traduce un Update a mensaje del motor. ok=false si no hay nada que procesar.
+func (c *Channel) Normalize(update *Update) (channels.Message, bool) {
+	msg := update.Message
+	if msg == nil {
+		return channels.Message{}, false
+	}
+
+	m := channels.Message{
+		ID:   strconv.FormatInt(msg.MessageID, 10),
+		From: identity(msg.Chat.ID, msg.From),
+	}
+	switch {
+	case len(msg.Photo) > 0:
+		// La última del array es la de mayor resolución
+		m.Media = &channels.Media{
+			Kind:    channels.MediaImage,
+			ID:      msg.Photo[len(msg.Photo)-1].FileID,
+			Caption: msg.Caption,
+		}
+	case msg.Document != nil:
+		m.Media = &channels.Media{
+			Kind:     channels.MediaDocument,
+			ID:       msg.Document.FileID,
+			MimeType: msg.Document.MimeType,
+			Filename: msg.Document.FileName,
+			Caption:  msg.Caption,
+		}
+	case msg.Text != "":
+		m.Text = msg.Text
+	default:
+		slog.Info("telegram: tipo de mensaje ignorado", "chat_id", msg.Chat.ID)
+		return channels.Message{}, false
+	}
+	return m, true
+}
+
+func identity(chatID int64, from *TGUser) channels.Identity {
+	id := strconv.FormatInt(chatID, 10)
+	ident := channels.Identity{Channel: channels.Telegram, ID: id, Key: "tg:" + id}
+	if from != nil {
+		ident.Name = strings.TrimSpace(from.FirstName + " " + from.LastName)
+		ident.Username = from.Username
+	}
+	return ident
+}
+
+func chatID(id channels.Identity) (int64, error) {
+	n, err := strconv.ParseInt(id.ID, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("telegram: chat ID inválido %q: %w", id.ID, err)
+	}
+	return n, nil
+}
+
+func (c *Channel) SendText(ctx context.Context, to channels.Identity, text string) error {
+	id, err := chatID(to)
+	if err != nil {
+		return err
+	}
+	return c.sender.SendText(ctx, id, text)
+}
+
+func (c *Channel) SendImage(ctx context.Context, to channels.Identity, data []byte, mimeType, caption string) error {
+	id, err := chatID(to)
+	if err != nil {
+		return err
+	}
+	return c.sender.SendFile(ctx, id, "sendPhoto", "photo", data, "imagen"+extensionFor(mimeType), caption)
+}
+
+func (c *Channel) SendDocument(ctx context.Context, to channels.Identity, data []byte, mimeType, filename, caption string) error {
+	id, err := chatID(to)
+	if err != nil {
+		return err
+	}
+	return c.sender.SendFile(ctx, id, "sendDocument", "document", data, filename, caption)
+}
+
+func (c *Channel) SendButtons(ctx context.Context, to channels.Identity, text string, buttons []channels.Button) error {
+	id, err := chatID(to)
+	if err != nil {
+		return err
+	}
+	options := make([]string, len(buttons))
+	for i, btn := range buttons {
+		options[i] = btn.Title
+	}
+	return c.sender.SendKeyboard(ctx, id, text, options)
+}
+
+func (c *Channel) DownloadMedia(ctx context.Context, media *channels.Media) ([]byte, string, error) {
+	return c.sender.GetFileBytes(ctx, media.ID)
+}
+
+func extensionFor(mimeType string) string {
+	switch mimeType {
+	case "image/png":
+		return ".png"
+	case "image/webp":
+		return ".webp"
+	default:
+		return ".jpg"
+	}
+}
+
+// Asesor retorna el chat del asesor (TelegramAsesorChatID en system_config)
+func (c *Channel) Asesor(ctx context.Context) (channels.Identity, bool) {
+	id := c.contextProvider.GetAsesorTelegramChatID(ctx)
+	if id == 0 {
+		return channels.Identity{}, false
+	}
+	return identity(id, nil), true
+}
+
+func (c *Channel) IsAsesor(ctx context.Context, id channels.Identity) bool {
+	asesor, ok := c.Asesor(ctx)
+	return ok && id.ID == asesor.ID
+}
+
+// UserContext arma el contexto con el perfil de Telegram: el canal no expone
+// teléfono, así que el cliente nunca se liga a una cuenta de fabricalaser.com.
+func (c *Channel) UserContext(ctx context.Context, from channels.Identity) string {
+	var b strings.Builder
+	b.WriteString("\n\nDATOS DEL CLIENTE (Telegram):\n")
+	b.WriteString("Canal: Telegram\n")
+
+	if from.Name != "" {
+		b.WriteString(fmt.Sprintf("Nombre: %s\n", from.Name))
+	}
+	if from.Username != "" {
+		b.WriteString(fmt.Sprintf("Username: @%s\n", from.Username))
+	}
+
+	b.WriteString("Estado: NO registrado en fabricalaser.com (contacto vía Telegram)\n")
+	b.WriteString("\nINSTRUCCIONES ESPECÍFICAS PARA TELEGRAM:")
+	b.WriteString("\n- El cliente está en TELEGRAM. Cuando escales a un asesor, decile al cliente que el asesor lo contactará por TELEGRAM. NUNCA menciones WhatsApp como medio de contacto con este cliente.")
+	b.WriteString("\n- RECORDATORIO CRÍTICO: NUNCA uses 'Pura vida' bajo ninguna circunstancia. Ni como saludo, ni como despedida, ni como afirmación.")
+	return b.String()
+}
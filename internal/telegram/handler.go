@@ -6,22 +6,39 @@ import (
 	"io"
 	"log/slog"
 	"net/http"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/channels"
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+	"github.com/alonsoalpizar/fabricalaser/internal/tenant"
+	"github.com/go-chi/chi/v5"
 )
 
 // Handler atiende el webhook de Telegram Bot API.
 type Handler struct {
-	processor *Processor
+	channel *Channel
+	engine  *channels.Engine
 }
 
-// NewHandler construye el Handler con el procesador de mensajes.
-func NewHandler(processor *Processor) *Handler {
-	return &Handler{processor: processor}
+// NewHandler construye el Handler; los mensajes se procesan en el motor de conversación común.
+func NewHandler(channel *Channel, engine *channels.Engine) *Handler {
+	return &Handler{channel: channel, engine: engine}
 }
 
 // HandleWebhook maneja el POST que Telegram envía con cada Update.
 // Responde 200 inmediatamente y procesa el mensaje de forma asíncrona.
 // Telegram valida por el token secreto en la URL del webhook — no hay firma HMAC.
+// Cada tenant registra su bot en /api/v1/telegram/webhook/{tenant} (slug); la
+// ruta sin slug es la del tenant por defecto.
 func (h *Handler) HandleWebhook(w http.ResponseWriter, r *http.Request) {
+	t := tenant.Lookup(models.DefaultTenantID)
+	if slug := chi.URLParam(r, "tenant"); slug != "" {
+		var ok bool
+		if t, ok = tenant.ForSlug(slug); !ok {
+			http.Error(w, "Not Found", http.StatusNotFound)
+			return
+		}
+	}
+
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
 		slog.Error("telegram: error leyendo body", "error", err)
@@ -42,5 +59,7 @@ func (h *Handler) HandleWebhook(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(`{"ok":true}`))
 
 	// Procesar de forma asíncrona
-	go h.processor.Process(context.Background(), &update)
+	if msg, ok := h.channel.Normalize(&update); ok {
+		go h.engine.Handle(tenant.WithTenant(context.Background(), t), h.channel, msg)
+	}
 }
@@ -14,6 +14,7 @@ type TGMessage struct {
 	Date      int64         `json:"date"`
 	Text      string        `json:"text,omitempty"`
 	Photo     []TGPhotoSize `json:"photo,omitempty"`
+	Document  *TGDocument   `json:"document,omitempty"`
 	Caption   string        `json:"caption,omitempty"`
 }
 
@@ -39,3 +40,12 @@ type TGPhotoSize struct {
 	Height       int    `json:"height"`
 	FileSize     int    `json:"file_size,omitempty"`
 }
+
+// TGDocument es un archivo enviado como documento (sin compresión).
+type TGDocument struct {
+	FileID       string `json:"file_id"`
+	FileUniqueID string `json:"file_unique_id"`
+	FileName     string `json:"file_name,omitempty"`
+	MimeType     string `json:"mime_type,omitempty"`
+	FileSize     int    `json:"file_size,omitempty"`
+}
@@ -7,10 +7,13 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"mime/multipart"
 	"net/http"
-	"os"
+	"strconv"
 	"strings"
 	"time"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/tenant"
 )
 
 const (
@@ -18,22 +21,40 @@ const (
 	tgHTTPTimeout   = 15 * time.Second
 )
 
-// Sender encapsula el token y el cliente HTTP para enviar mensajes via Telegram Bot API.
+// Sender encapsula el cliente HTTP para enviar mensajes via Telegram Bot API.
+// El bot es el del tenant del contexto de cada llamada.
 type Sender struct {
-	botToken   string
 	httpClient *http.Client
 }
 
-// NewSender construye el Sender leyendo TELEGRAM_BOT_TOKEN del entorno.
+// NewSender construye el Sender; cada llamada usa el token del bot del tenant
+// de su contexto (TELEGRAM_BOT_TOKEN para el tenant por defecto).
 func NewSender() *Sender {
 	return &Sender{
-		botToken: os.Getenv("TELEGRAM_BOT_TOKEN"),
 		httpClient: &http.Client{
 			Timeout: tgHTTPTimeout,
 		},
 	}
 }
 
+// botToken retorna el token del bot del tenant de ctx
+func botToken(ctx context.Context) (string, error) {
+	token := tenant.Channels(tenant.ID(ctx)).TelegramBotToken
+	if token == "" {
+		return "", fmt.Errorf("telegram: el tenant %d no tiene bot de Telegram", tenant.ID(ctx))
+	}
+	return token, nil
+}
+
+// botURL arma la URL de method de la Bot API con el bot del tenant de ctx
+func botURL(ctx context.Context, method string) (string, error) {
+	token, err := botToken(ctx)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("https://api.telegram.org/bot%s/%s", token, method), nil
+}
+
 // SendText envía un mensaje de texto a un chat de Telegram.
 // Si el texto excede 4096 caracteres, lo parte en chunks por salto de línea.
 func (s *Sender) SendText(ctx context.Context, chatID int64, text string) error {
@@ -46,12 +67,69 @@ func (s *Sender) SendText(ctx context.Context, chatID int64, text string) error
 	return nil
 }
 
-func (s *Sender) sendOneMessage(ctx context.Context, chatID int64, text string) error {
-	url := fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", s.botToken)
+// SendKeyboard envía un mensaje con un teclado de respuestas rápidas.
+// Al tocar una opción, Telegram la envía como mensaje de texto normal.
+func (s *Sender) SendKeyboard(ctx context.Context, chatID int64, text string, options []string) error {
+	rows := make([][]map[string]string, 0, len(options))
+	for _, opt := range options {
+		rows = append(rows, []map[string]string{{"text": opt}})
+	}
+	return s.postJSON(ctx, "sendMessage", chatID, map[string]interface{}{
+		"chat_id": chatID,
+		"text":    text,
+		"reply_markup": map[string]interface{}{
+			"keyboard":          rows,
+			"one_time_keyboard": true,
+			"resize_keyboard":   true,
+		},
+	})
+}
 
-	payload := map[string]interface{}{
+// SendFile envía una foto (method "sendPhoto", field "photo") o un documento
+// (method "sendDocument", field "document") subiéndolo como multipart.
+func (s *Sender) SendFile(ctx context.Context, chatID int64, method, field string, data []byte, filename, caption string) error {
+	url, err := botURL(ctx, method)
+	if err != nil {
+		return err
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	_ = mw.WriteField("chat_id", strconv.FormatInt(chatID, 10))
+	if caption != "" {
+		_ = mw.WriteField("caption", caption)
+	}
+	part, err := mw.CreateFormFile(field, filename)
+	if err != nil {
+		return fmt.Errorf("telegram sender: error creando multipart: %w", err)
+	}
+	if _, err := part.Write(data); err != nil {
+		return fmt.Errorf("telegram sender: error escribiendo archivo: %w", err)
+	}
+	if err := mw.Close(); err != nil {
+		return fmt.Errorf("telegram sender: error cerrando multipart: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, &body)
+	if err != nil {
+		return fmt.Errorf("telegram sender: error creando request: %w", err)
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+
+	return s.do(req, method, chatID)
+}
+
+func (s *Sender) sendOneMessage(ctx context.Context, chatID int64, text string) error {
+	return s.postJSON(ctx, "sendMessage", chatID, map[string]interface{}{
 		"chat_id": chatID,
 		"text":    text,
+	})
+}
+
+func (s *Sender) postJSON(ctx context.Context, method string, chatID int64, payload map[string]interface{}) error {
+	url, err := botURL(ctx, method)
+	if err != nil {
+		return err
 	}
 
 	body, err := json.Marshal(payload)
@@ -65,6 +143,10 @@ func (s *Sender) sendOneMessage(ctx context.Context, chatID int64, text string)
 	}
 	req.Header.Set("Content-Type", "application/json")
 
+	return s.do(req, method, chatID)
+}
+
+func (s *Sender) do(req *http.Request, method string, chatID int64) error {
 	resp, err := s.httpClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("telegram sender: error enviando mensaje: %w", err)
@@ -76,7 +158,7 @@ func (s *Sender) sendOneMessage(ctx context.Context, chatID int64, text string)
 		return fmt.Errorf("telegram sender: Telegram respondió con status %d: %s", resp.StatusCode, string(respBody))
 	}
 
-	slog.Info("telegram: mensaje enviado exitosamente", "chat_id", chatID)
+	slog.Info("telegram: mensaje enviado exitosamente", "chat_id", chatID, "method", method)
 	return nil
 }
 
@@ -85,7 +167,11 @@ func (s *Sender) sendOneMessage(ctx context.Context, chatID int64, text string)
 // Retorna los bytes, el mimeType inferido, y error.
 func (s *Sender) GetFileBytes(ctx context.Context, fileID string) ([]byte, string, error) {
 	// 1. Obtener file_path via getFile
-	getFileURL := fmt.Sprintf("https://api.telegram.org/bot%s/getFile?file_id=%s", s.botToken, fileID)
+	token, err := botToken(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	getFileURL := fmt.Sprintf("https://api.telegram.org/bot%s/getFile?file_id=%s", token, fileID)
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, getFileURL, nil)
 	if err != nil {
@@ -117,7 +203,7 @@ func (s *Sender) GetFileBytes(ctx context.Context, fileID string) ([]byte, strin
 	}
 
 	// 2. Descargar el archivo
-	downloadURL := fmt.Sprintf("https://api.telegram.org/file/bot%s/%s", s.botToken, result.Result.FilePath)
+	downloadURL := fmt.Sprintf("https://api.telegram.org/file/bot%s/%s", token, result.Result.FilePath)
 
 	dlReq, err := http.NewRequestWithContext(ctx, http.MethodGet, downloadURL, nil)
 	if err != nil {
@@ -188,6 +274,10 @@ func inferMimeType(filePath string) string {
 		return "image/webp"
 	case strings.HasSuffix(lower, ".gif"):
 		return "image/gif"
+	case strings.HasSuffix(lower, ".svg"):
+		return "image/svg+xml"
+	case strings.HasSuffix(lower, ".dxf"):
+		return "image/vnd.dxf"
 	default:
 		return "image/jpeg" // Telegram photos son casi siempre JPEG
 	}
@@ -0,0 +1,91 @@
+package tenant
+
+import (
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// Column es la columna que marca el tenant de una fila
+const Column = "tenant_id"
+
+// skipScopeKey marca las sentencias que cruzan tenants (ver AllTenants)
+const skipScopeKey = "tenant:all"
+
+// Plugin aísla las filas por tenant en todo modelo con columna tenant_id:
+// consultas, updates y deletes filtran por el tenant del contexto de la
+// sentencia, y los inserts y updates completan TenantID si viene en cero.
+// db.Raw y db.Exec no pasan por el plugin: esas consultas filtran a mano.
+type Plugin struct{}
+
+func (Plugin) Name() string { return "tenant" }
+
+func (Plugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	if err := cb.Create().Before("gorm:create").Register("tenant:assign", assignTenant); err != nil {
+		return err
+	}
+	if err := cb.Query().Before("gorm:query").Register("tenant:scope", scopeTenant); err != nil {
+		return err
+	}
+	if err := cb.Update().Before("gorm:update").Register("tenant:assign", assignTenant); err != nil {
+		return err
+	}
+	if err := cb.Update().Before("gorm:update").Register("tenant:scope", scopeTenant); err != nil {
+		return err
+	}
+	if err := cb.Delete().Before("gorm:delete").Register("tenant:scope", scopeTenant); err != nil {
+		return err
+	}
+	return cb.Row().Before("gorm:row").Register("tenant:scope", scopeTenant)
+}
+
+// AllTenants retorna db sin el filtro por tenant, para las búsquedas que
+// todavía no conocen el tenant: la API key de un request identifica a su taller.
+func AllTenants(db *gorm.DB) *gorm.DB {
+	return db.Set(skipScopeKey, true)
+}
+
+// tenantField retorna el campo tenant_id del modelo de la sentencia, o nil
+func tenantField(db *gorm.DB) *schema.Field {
+	if db.Error != nil || db.Statement.Schema == nil {
+		return nil
+	}
+	return db.Statement.Schema.LookUpField(Column)
+}
+
+func scopeTenant(db *gorm.DB) {
+	if tenantField(db) == nil {
+		return
+	}
+	if skip, _ := db.Get(skipScopeKey); skip == true {
+		return
+	}
+	db.Statement.AddClause(clause.Where{Exprs: []clause.Expression{
+		clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: Column}, Value: ID(db.Statement.Context)},
+	}})
+}
+
+func assignTenant(db *gorm.DB) {
+	field := tenantField(db)
+	if field == nil {
+		return
+	}
+	ctx, id := db.Statement.Context, ID(db.Statement.Context)
+	set := func(rv reflect.Value) {
+		if _, zero := field.ValueOf(ctx, rv); zero {
+			_ = field.Set(ctx, rv, id)
+		}
+	}
+	rv := db.Statement.ReflectValue
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			set(reflect.Indirect(rv.Index(i)))
+		}
+	case reflect.Struct:
+		set(rv)
+	}
+}
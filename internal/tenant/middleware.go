@@ -0,0 +1,61 @@
+package tenant
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+)
+
+// Middleware resuelve el tenant de cada request y lo deja en el contexto.
+// Una API key desconocida es 401, un host desconocido o un taller inactivo 404
+// y si los tenants no se pueden cargar 503: nunca se atiende un dominio ajeno
+// con los datos y la marca del tenant por defecto.
+func (r *Resolver) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		t, err := r.Resolve(req)
+		switch {
+		case errors.Is(err, ErrUnknownAPIKey):
+			respondTenantError(w, http.StatusUnauthorized, "INVALID_API_KEY", "API key inválida")
+			return
+		case errors.Is(err, ErrUnknownHost):
+			respondTenantError(w, http.StatusNotFound, "UNKNOWN_HOST", "Este sitio no está disponible")
+			return
+		case errors.Is(err, ErrInactive):
+			respondTenantError(w, http.StatusNotFound, "TENANT_INACTIVE", "Este sitio no está disponible")
+			return
+		case err != nil:
+			log.Printf("[tenant] no se pudo resolver %s: %v", req.Host, err)
+			respondTenantError(w, http.StatusServiceUnavailable, "TENANT_UNAVAILABLE", "Servicio no disponible, intentá de nuevo en unos minutos")
+			return
+		}
+		next.ServeHTTP(w, req.WithContext(WithTenant(req.Context(), t)))
+	})
+}
+
+// DefaultOnly protege las rutas cuyos servicios todavía no propagan el
+// tenant: solo las atiende el tenant por defecto, el resto recibe 404 en
+// lugar de ver datos de FabricaLaser.
+func DefaultOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if ID(req.Context()) != models.DefaultTenantID {
+			respondTenantError(w, http.StatusNotFound, "NOT_AVAILABLE", "Función no disponible para este taller")
+			return
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+func respondTenantError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data": nil,
+		"error": map[string]string{
+			"code":    code,
+			"message": message,
+		},
+	})
+}
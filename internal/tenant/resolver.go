@@ -0,0 +1,308 @@
+package tenant
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+	"gorm.io/gorm"
+)
+
+// APIKeyHeader es el header con la API key del tenant
+const APIKeyHeader = "X-API-Key"
+
+var (
+	ErrUnknownAPIKey = errors.New("tenant: API key desconocida")
+	ErrUnknownHost   = errors.New("tenant: host desconocido")
+	ErrInactive      = errors.New("tenant: taller inactivo")
+)
+
+// Resolver carga los tenants y los resuelve por API key o por host. Los
+// tenants cambian poco: se recargan cada minuto o con Invalidate.
+type Resolver struct {
+	db  *gorm.DB
+	ttl time.Duration
+
+	mu         sync.RWMutex
+	byID       map[uint]*models.Tenant
+	byHost     map[string]*models.Tenant
+	byKey      map[string]*models.Tenant
+	bySlug     map[string]*models.Tenant
+	byWhatsApp map[string]*models.Tenant // phone_number_id de Meta → tenant
+	loadedAt   time.Time
+}
+
+// NewResolver crea un resolver sobre db
+func NewResolver(db *gorm.DB) *Resolver {
+	return &Resolver{db: db, ttl: time.Minute}
+}
+
+// Shared es el resolver del proceso (lo arma Setup): lo usan el middleware
+// HTTP y los componentes que leen el branding o las credenciales de un tenant
+var Shared *Resolver
+
+// Setup registra el plugin de aislamiento en db y arma el resolver compartido.
+// database.Connect lo llama al abrir la conexión.
+func Setup(db *gorm.DB) error {
+	Shared = NewResolver(db)
+	return db.Use(Plugin{})
+}
+
+// Get retorna el tenant id
+func (r *Resolver) Get(id uint) (*models.Tenant, error) {
+	if err := r.ensure(); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	t, ok := r.byID[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return t, nil
+}
+
+// Resolve retorna el tenant del request: por X-API-Key si viene, si no por el
+// Host. Un host desconocido es ErrUnknownHost, salvo loopback: las llamadas
+// internas del propio servidor (tools del bot) son del tenant por defecto.
+func (r *Resolver) Resolve(req *http.Request) (*models.Tenant, error) {
+	if err := r.ensure(); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var t *models.Tenant
+	if key := strings.TrimSpace(req.Header.Get(APIKeyHeader)); key != "" {
+		if t = r.byKey[HashAPIKey(key)]; t == nil {
+			return nil, ErrUnknownAPIKey
+		}
+	} else if t = r.byHost[normalizeHost(req.Host)]; t == nil {
+		if !loopbackHost(req.Host) {
+			return nil, ErrUnknownHost
+		}
+		t = r.byID[models.DefaultTenantID]
+	}
+	if t == nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	if !t.IsActive {
+		return nil, ErrInactive
+	}
+	return t, nil
+}
+
+// BySlug retorna el tenant con ese slug (ruta del webhook de Telegram)
+func (r *Resolver) BySlug(slug string) (*models.Tenant, bool) {
+	if err := r.ensure(); err != nil {
+		return nil, false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	t, ok := r.bySlug[strings.ToLower(slug)]
+	return t, ok
+}
+
+// ByWhatsAppNumber retorna el tenant dueño del número de WhatsApp (el
+// phone_number_id que Meta manda en metadata de cada cambio del webhook)
+func (r *Resolver) ByWhatsAppNumber(phoneNumberID string) (*models.Tenant, bool) {
+	if err := r.ensure(); err != nil {
+		return nil, false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	t, ok := r.byWhatsApp[phoneNumberID]
+	return t, ok
+}
+
+// Active retorna los tenants activos ordenados por id
+func (r *Resolver) Active() ([]*models.Tenant, error) {
+	if err := r.ensure(); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]*models.Tenant, 0, len(r.byID))
+	for _, t := range r.byID {
+		if t.IsActive {
+			out = append(out, t)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
+
+// Invalidate fuerza la recarga en la próxima consulta
+func (r *Resolver) Invalidate() {
+	r.mu.Lock()
+	r.loadedAt = time.Time{}
+	r.mu.Unlock()
+}
+
+func (r *Resolver) ensure() error {
+	r.mu.RLock()
+	fresh := r.byID != nil && time.Since(r.loadedAt) < r.ttl
+	r.mu.RUnlock()
+	if fresh {
+		return nil
+	}
+
+	var tenants []models.Tenant
+	if err := r.db.Order("id").Find(&tenants).Error; err != nil {
+		r.mu.RLock()
+		stale := r.byID != nil
+		r.mu.RUnlock()
+		if stale {
+			// Mejor los tenants de hace un minuto que cortar todos los requests
+			log.Printf("[tenant] recarga falló, se siguen usando los anteriores: %v", err)
+			return nil
+		}
+		return err
+	}
+
+	byID := make(map[uint]*models.Tenant, len(tenants))
+	byHost := make(map[string]*models.Tenant)
+	byKey := make(map[string]*models.Tenant)
+	bySlug := make(map[string]*models.Tenant, len(tenants))
+	byWhatsApp := make(map[string]*models.Tenant)
+	for i := range tenants {
+		t := &tenants[i]
+		byID[t.ID] = t
+		bySlug[strings.ToLower(t.Slug)] = t
+		for _, h := range t.HostList() {
+			byHost[normalizeHost(h)] = t
+		}
+		if t.APIKeyHash != nil && *t.APIKeyHash != "" {
+			byKey[strings.ToLower(*t.APIKeyHash)] = t
+		}
+		if n := channelCredentials(t).WhatsAppPhoneNumberID; n != "" {
+			byWhatsApp[n] = t
+		}
+	}
+
+	r.mu.Lock()
+	r.byID, r.byHost, r.byKey, r.bySlug, r.byWhatsApp, r.loadedAt = byID, byHost, byKey, bySlug, byWhatsApp, time.Now()
+	r.mu.Unlock()
+	return nil
+}
+
+// Lookup retorna el tenant id del resolver compartido. Si no se puede cargar
+// retorna un tenant vacío con ese id: quien lo usa cae en sus valores por
+// defecto (branding, credenciales del entorno).
+func Lookup(id uint) *models.Tenant {
+	if Shared != nil {
+		if t, err := Shared.Get(id); err == nil {
+			return t
+		}
+	}
+	return &models.Tenant{ID: id, IsActive: true}
+}
+
+// ForWhatsAppNumber retorna el tenant dueño del número de WhatsApp. Un número
+// desconocido (o sin resolver) es del tenant por defecto, como antes de
+// multi-tenant; la firma del webhook se verifica igual con su app secret.
+func ForWhatsAppNumber(phoneNumberID string) *models.Tenant {
+	if Shared != nil {
+		if t, ok := Shared.ByWhatsAppNumber(phoneNumberID); ok {
+			return t
+		}
+	}
+	return Lookup(models.DefaultTenantID)
+}
+
+// ForSlug retorna el tenant activo con ese slug
+func ForSlug(slug string) (*models.Tenant, bool) {
+	if Shared == nil {
+		return nil, false
+	}
+	t, ok := Shared.BySlug(slug)
+	if !ok || !t.IsActive {
+		return nil, false
+	}
+	return t, true
+}
+
+// Active retorna los tenants activos; sin resolver, solo el tenant por defecto
+func Active() []*models.Tenant {
+	if Shared != nil {
+		if tenants, err := Shared.Active(); err == nil {
+			return tenants
+		}
+	}
+	return []*models.Tenant{Lookup(models.DefaultTenantID)}
+}
+
+// EachActive corre fn con el contexto de cada tenant activo, en orden de id.
+// Lo usan los jobs periódicos: cada corrida queda en el tenant de sus datos.
+func EachActive(ctx context.Context, fn func(ctx context.Context)) {
+	for _, t := range Active() {
+		if ctx.Err() != nil {
+			return
+		}
+		fn(WithTenant(ctx, t))
+	}
+}
+
+// Channels retorna las credenciales de canales del tenant id. Solo para el
+// tenant por defecto los campos vacíos se completan con las variables de
+// entorno de siempre; un taller asociado sin credenciales no tiene ese canal.
+func Channels(id uint) models.TenantCredentials {
+	return channelCredentials(Lookup(id))
+}
+
+func channelCredentials(t *models.Tenant) models.TenantCredentials {
+	c := t.ChannelCredentials()
+	if t.ID != models.DefaultTenantID {
+		return c
+	}
+	for _, f := range []struct {
+		v   *string
+		env string
+	}{
+		{&c.WhatsAppPhoneNumberID, "WHATSAPP_PHONE_NUMBER_ID"},
+		{&c.WhatsAppAccessToken, "WHATSAPP_ACCESS_TOKEN"},
+		{&c.WhatsAppAppSecret, "WHATSAPP_APP_SECRET"},
+		{&c.WhatsAppVerifyToken, "WHATSAPP_VERIFY_TOKEN"},
+		{&c.TelegramBotToken, "TELEGRAM_BOT_TOKEN"},
+	} {
+		if *f.v == "" {
+			*f.v = os.Getenv(f.env)
+		}
+	}
+	return c
+}
+
+// HashAPIKey retorna el SHA-256 hex de una API key, como se guarda en tenants.api_key_hash
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// normalizeHost pasa a minúsculas y quita el puerto
+// loopbackHost indica si host es localhost o una IP de loopback
+func loopbackHost(host string) bool {
+	host = normalizeHost(host)
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(strings.Trim(host, "[]"))
+	return ip != nil && ip.IsLoopback()
+}
+
+func normalizeHost(host string) string {
+	host = strings.ToLower(strings.TrimSpace(host))
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.TrimSuffix(host, ".")
+}
@@ -0,0 +1,44 @@
+// Package tenant resuelve a qué taller (tenant) pertenece cada request y aísla
+// sus filas en la base de datos.
+//
+// El middleware del resolver deja el tenant en el contexto del request; el
+// plugin GORM filtra por tenant_id toda consulta hecha con ese contexto
+// (repo.WithContext(r.Context())) y completa tenant_id en los inserts. Sin
+// tenant en el contexto rige el tenant por defecto, así que un camino que
+// todavía no propaga el request sigue viendo solo los datos de FabricaLaser.
+package tenant
+
+import (
+	"context"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+)
+
+type ctxKey struct{}
+
+// WithTenant retorna ctx con el tenant t
+func WithTenant(ctx context.Context, t *models.Tenant) context.Context {
+	return context.WithValue(ctx, ctxKey{}, t)
+}
+
+// WithID retorna ctx con el tenant id (jobs y tests que no tienen la fila)
+func WithID(ctx context.Context, id uint) context.Context {
+	return WithTenant(ctx, &models.Tenant{ID: id, IsActive: true})
+}
+
+// FromContext retorna el tenant de ctx; nil si el contexto no trae tenant
+func FromContext(ctx context.Context) *models.Tenant {
+	if ctx == nil {
+		return nil
+	}
+	t, _ := ctx.Value(ctxKey{}).(*models.Tenant)
+	return t
+}
+
+// ID retorna el id del tenant de ctx; el tenant por defecto si no trae
+func ID(ctx context.Context) uint {
+	if t := FromContext(ctx); t != nil && t.ID != 0 {
+		return t.ID
+	}
+	return models.DefaultTenantID
+}
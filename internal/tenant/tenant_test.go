@@ -0,0 +1,105 @@
+package tenant
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// dryDB arma SQL sin conectarse a Postgres
+func dryDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{DryRun: true, DisableAutomaticPing: true, SkipDefaultTransaction: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Use(Plugin{}); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestPluginScopesQueries(t *testing.T) {
+	db := dryDB(t)
+	ctx := WithID(context.Background(), 7)
+
+	stmt := db.WithContext(ctx).Where("is_active = ?", true).Find(&[]models.Material{}).Statement
+	if sql := stmt.SQL.String(); !strings.Contains(sql, `"materials"."tenant_id" = $2`) || stmt.Vars[1] != uint(7) {
+		t.Errorf("query not scoped: %s %v", sql, stmt.Vars)
+	}
+
+	// Sin tenant en el contexto rige el tenant por defecto
+	stmt = db.Delete(&models.Technology{}, 3).Statement
+	if sql := stmt.SQL.String(); !strings.Contains(sql, `"technologies"."tenant_id" =`) || stmt.Vars[len(stmt.Vars)-1] != models.DefaultTenantID {
+		t.Errorf("delete not scoped: %s %v", sql, stmt.Vars)
+	}
+
+	// Modelos sin tenant_id no se tocan
+	if sql := db.WithContext(ctx).Find(&[]models.ExchangeRate{}).Statement.SQL.String(); strings.Contains(sql, "tenant_id") {
+		t.Errorf("unscoped model filtered: %s", sql)
+	}
+
+	if sql := AllTenants(db.WithContext(ctx)).Find(&[]models.Material{}).Statement.SQL.String(); strings.Contains(sql, "tenant_id") {
+		t.Errorf("AllTenants filtered: %s", sql)
+	}
+
+	m := models.Material{Name: "MDF"}
+	db.WithContext(ctx).Create(&m)
+	if m.TenantID != 7 {
+		t.Errorf("create TenantID = %d, want 7", m.TenantID)
+	}
+}
+
+func TestResolveHost(t *testing.T) {
+	key := "secreta"
+	hash := HashAPIKey(key)
+	r := &Resolver{ttl: time.Hour, loadedAt: time.Now()}
+	def := &models.Tenant{ID: 1, IsActive: true}
+	otro := &models.Tenant{ID: 2, IsActive: true}
+	apagado := &models.Tenant{ID: 3}
+	r.byID = map[uint]*models.Tenant{1: def, 2: otro, 3: apagado}
+	r.byHost = map[string]*models.Tenant{"otro.cr": otro, "apagado.cr": apagado}
+	r.byKey = map[string]*models.Tenant{hash: otro}
+
+	cases := []struct {
+		host, key string
+		want      uint
+		err       error
+	}{
+		{"OTRO.cr:8083", "", 2, nil},
+		{"desconocido.com", "", 0, ErrUnknownHost},
+		{"localhost:8083", "", 1, nil},
+		{"127.0.0.1:8083", "", 1, nil},
+		{"fabricalaser.com", key, 2, nil},
+		{"otro.cr", "mala", 0, ErrUnknownAPIKey},
+		{"apagado.cr", "", 0, ErrInactive},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Host = c.host
+		if c.key != "" {
+			req.Header.Set(APIKeyHeader, c.key)
+		}
+		got, err := r.Resolve(req)
+		if err != c.err || (err == nil && got.ID != c.want) {
+			t.Errorf("%s key=%q: got %v %v, want %d %v", c.host, c.key, got, err, c.want, c.err)
+		}
+	}
+}
+
+func TestChannelsEnvFallbackOnlyForDefaultTenant(t *testing.T) {
+	t.Setenv("WHATSAPP_ACCESS_TOKEN", "token-del-entorno")
+
+	if got := Channels(models.DefaultTenantID).WhatsAppAccessToken; got != "token-del-entorno" {
+		t.Errorf("tenant por defecto: token = %q, want el del entorno", got)
+	}
+	if got := Channels(2).WhatsAppAccessToken; got != "" {
+		t.Errorf("taller asociado sin credenciales no debe usar el token del entorno: %q", got)
+	}
+}
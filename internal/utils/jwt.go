@@ -22,12 +22,13 @@ type TokenClaims struct {
 	Nombre string `json:"nombre"`
 	Email  string `json:"email"`
 	Role   string `json:"role"`
-	Tipo   string `json:"tipo"` // "customer" for user tokens
+	Tipo   string `json:"tipo"`             // "customer" for user tokens
+	Tenant uint   `json:"tenant,omitempty"` // taller del usuario; 0 en tokens previos a multi-tenant (= tenant 1)
 	jwt.RegisteredClaims
 }
 
 // GenerateToken creates a new JWT token for a user
-func GenerateToken(id uint, cedula, nombre, email, role string) (string, error) {
+func GenerateToken(id, tenantID uint, cedula, nombre, email, role string) (string, error) {
 	cfg := config.Get()
 
 	claims := TokenClaims{
@@ -37,6 +38,7 @@ func GenerateToken(id uint, cedula, nombre, email, role string) (string, error)
 		Email:  email,
 		Role:   role,
 		Tipo:   "customer",
+		Tenant: tenantID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
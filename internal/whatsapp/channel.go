@@ -0,0 +1,280 @@
+package whatsapp
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/channels"
+	"github.com/alonsoalpizar/fabricalaser/internal/tenant"
+)
+
+// ─── Interfaces — permiten tests sin dependencias reales ─────────────────────
+
+// RedisClient define las operaciones Redis que necesitan el canal y el motor.
+type RedisClient = channels.Store
+
+// ChatTurn representa un turno en el historial de conversación (formato Vertex AI).
+type ChatTurn = channels.Turn
+
+// ConversationTurn es lo que se persiste en PostgreSQL como archivo.
+type ConversationTurn = channels.ArchivedTurn
+
+// UserProfile contiene los datos del cliente registrado en FabricaLaser.
+type UserProfile struct {
+	Nombre     string
+	Apellido   string
+	CedulaType string // "fisica" o "juridica"
+	Email      string
+	Provincia  string
+	Canton     string
+	Direccion  string
+}
+
+// PGClient define las operaciones PostgreSQL para archivo de conversaciones.
+type PGClient interface {
+	channels.Archive
+	FindUserByPhone(ctx context.Context, phone string) (*UserProfile, error)
+}
+
+const userCtxTTL = 15 * time.Minute
+
+// ─── Channel ─────────────────────────────────────────────────────────────────
+
+// Channel conecta WhatsApp Cloud API al motor de conversación.
+// La identidad es el número E.164 sin "+", que también es la clave de conversación.
+type Channel struct {
+	sender          *Sender
+	downloader      *ImageDownloader
+	redis           RedisClient
+	pg              PGClient
+	contextProvider *WAContextProvider
+}
+
+// NewChannel construye el canal; redis y pg se usan para el contexto del cliente registrado.
+func NewChannel(redis RedisClient, pg PGClient, contextProvider *WAContextProvider) *Channel {
+	return &Channel{
+		sender:          NewSender(),
+		downloader:      NewImageDownloader(),
+		redis:           redis,
+		pg:              pg,
+		contextProvider: contextProvider,
+	}
+}
+
+func (c *Channel) Name() string      { return channels.WhatsApp }
+func (c *Channel) Label() string     { return "WhatsApp" }
+func (c *Channel) Namespace() string { return "wa" }
+
+// Normalize traduce el payload de Meta a mensajes del motor.
+// Los tipos que el motor no maneja se descartan aquí con log.
+func (c *Channel) Normalize(payload *WebhookPayload) []channels.Message {
+	var out []channels.Message
+	for _, entry := range payload.Entry {
+		for _, change := range entry.Changes {
+			for _, msg := range change.Value.Messages {
+				m := channels.Message{ID: msg.ID, From: identity(msg.From)}
+				switch {
+				case msg.Type == "text" && msg.Text != nil:
+					m.Text = msg.Text.Body
+				case msg.Type == "interactive" && msg.Interactive != nil:
+					reply := msg.Interactive.ButtonReply
+					if reply == nil {
+						reply = msg.Interactive.ListReply
+					}
+					if reply == nil {
+						slog.Info("whatsapp: interactive sin respuesta ignorado", "type", msg.Interactive.Type, "from", msg.From)
+						continue
+					}
+					m.Text = reply.Title
+					m.Choice = &channels.Button{ID: reply.ID, Title: reply.Title, Description: reply.Description}
+				case msg.Type == "button" && msg.Button != nil:
+					// Botón de respuesta rápida de una plantilla
+					m.Text = msg.Button.Text
+					m.Choice = &channels.Button{ID: msg.Button.Payload, Title: msg.Button.Text}
+				case msg.Type == "image" && msg.Image != nil:
+					m.Media = &channels.Media{
+						Kind:     channels.MediaImage,
+						ID:       msg.Image.ID,
+						MimeType: msg.Image.MimeType,
+						Caption:  msg.Image.Caption,
+					}
+				case msg.Type == "document" && msg.Document != nil:
+					m.Media = &channels.Media{
+						Kind:     channels.MediaDocument,
+						ID:       msg.Document.ID,
+						MimeType: msg.Document.MimeType,
+						Filename: msg.Document.Filename,
+						Caption:  msg.Document.Caption,
+					}
+				default:
+					slog.Info("whatsapp: tipo de mensaje ignorado",
+						"type", msg.Type,
+						"from", msg.From,
+					)
+					continue
+				}
+				out = append(out, m)
+			}
+		}
+	}
+	return out
+}
+
+func identity(phone string) channels.Identity {
+	return channels.Identity{Channel: channels.WhatsApp, ID: phone, Key: phone, Phone: phone}
+}
+
+func (c *Channel) SendText(ctx context.Context, to channels.Identity, text string) error {
+	return c.sender.SendText(ctx, to.ID, text)
+}
+
+func (c *Channel) SendImage(ctx context.Context, to channels.Identity, data []byte, mimeType, caption string) error {
+	mediaID, err := c.sender.UploadMedia(ctx, data, mimeType, "imagen"+extensionFor(mimeType))
+	if err != nil {
+		return err
+	}
+	return c.sender.SendImage(ctx, to.ID, mediaID, caption)
+}
+
+func (c *Channel) SendDocument(ctx context.Context, to channels.Identity, data []byte, mimeType, filename, caption string) error {
+	mediaID, err := c.sender.UploadMedia(ctx, data, mimeType, filename)
+	if err != nil {
+		return err
+	}
+	return c.sender.SendDocument(ctx, to.ID, mediaID, filename, caption)
+}
+
+// SendButtons elige el formato según la cantidad de opciones: hasta 3 como botones,
+// hasta 10 como lista, y más que eso como texto numerado (límites de Meta).
+func (c *Channel) SendButtons(ctx context.Context, to channels.Identity, text string, buttons []channels.Button) error {
+	switch {
+	case len(buttons) == 0:
+		return c.sender.SendText(ctx, to.ID, text)
+	case len(buttons) <= maxReplyButtons:
+		reply := make([]ReplyButton, len(buttons))
+		for i, b := range buttons {
+			reply[i] = ReplyButton{ID: b.ID, Title: b.Title}
+		}
+		return c.sender.SendButtons(ctx, to.ID, text, reply)
+	case len(buttons) <= maxListRows:
+		rows := make([]ListRow, len(buttons))
+		for i, b := range buttons {
+			rows[i] = ListRow{ID: b.ID, Title: b.Title, Description: b.Description}
+		}
+		return c.sender.SendList(ctx, to.ID, text, "Ver opciones", []ListSection{{Rows: rows}})
+	default:
+		return c.sender.SendText(ctx, to.ID, channels.ButtonsAsText(text, buttons))
+	}
+}
+
+func (c *Channel) DownloadMedia(ctx context.Context, media *channels.Media) ([]byte, string, error) {
+	return c.downloader.DownloadImage(ctx, media.ID)
+}
+
+// Asesor retorna el número del asesor del tenant (TelAsesor en system_config)
+func (c *Channel) Asesor(ctx context.Context) (channels.Identity, bool) {
+	phone := strings.TrimPrefix(c.contextProvider.GetAsesorPhone(ctx), "+")
+	return identity(phone), phone != ""
+}
+
+// IsAsesor compara por sufijo: TelAsesor puede venir con o sin código de país
+func (c *Channel) IsAsesor(ctx context.Context, id channels.Identity) bool {
+	asesor, ok := c.Asesor(ctx)
+	return ok && strings.HasSuffix(id.ID, asesor.ID)
+}
+
+// UserContext consulta DB (vía cache Redis 15min) y retorna el bloque
+// de contexto que se inyecta al system prompt de Gemini.
+func (c *Channel) UserContext(ctx context.Context, from channels.Identity) string {
+	localPhone := stripCRPrefix(from.Phone)
+	cacheKey := fmt.Sprintf("wa:userctx:%d:%s", tenant.ID(ctx), localPhone)
+
+	if cached, err := c.redis.Get(ctx, cacheKey); err == nil {
+		return cached
+	}
+
+	profile, err := c.pg.FindUserByPhone(ctx, localPhone)
+	var userCtx string
+	if err != nil {
+		userCtx = buildUnregisteredCtx(localPhone)
+		slog.Info("whatsapp: usuario NO registrado", "phone", localPhone)
+	} else {
+		userCtx = buildRegisteredCtx(profile)
+		slog.Info("whatsapp: usuario REGISTRADO", "phone", localPhone, "nombre", profile.Nombre)
+	}
+
+	_ = c.redis.Set(ctx, cacheKey, userCtx, userCtxTTL)
+	return userCtx
+}
+
+// stripCRPrefix extrae el número local de CR desde el E.164 de WhatsApp.
+// "50686091954" → "86091954"
+func stripCRPrefix(waPhone string) string {
+	if strings.HasPrefix(waPhone, "506") && len(waPhone) == 11 {
+		return waPhone[3:]
+	}
+	return waPhone
+}
+
+func extensionFor(mimeType string) string {
+	switch mimeType {
+	case "image/png":
+		return ".png"
+	case "image/webp":
+		return ".webp"
+	default:
+		return ".jpg"
+	}
+}
+
+func buildRegisteredCtx(p *UserProfile) string {
+	var b strings.Builder
+	b.WriteString("\n\nDATOS DEL CLIENTE (base de datos FabricaLaser):\n")
+	b.WriteString("Canal: WhatsApp\n")
+
+	if p.CedulaType == "juridica" {
+		b.WriteString(fmt.Sprintf("Empresa: %s\n", p.Nombre))
+		b.WriteString("Tipo: empresa (cédula jurídica)\n")
+	} else {
+		nombre := p.Nombre
+		if p.Apellido != "" {
+			nombre += " " + p.Apellido
+		}
+		b.WriteString(fmt.Sprintf("Nombre: %s\n", nombre))
+		b.WriteString("Tipo: persona física\n")
+	}
+
+	if p.Email != "" {
+		b.WriteString(fmt.Sprintf("Email: %s\n", p.Email))
+	}
+
+	var location []string
+	if p.Canton != "" {
+		location = append(location, p.Canton)
+	}
+	if p.Provincia != "" {
+		location = append(location, p.Provincia)
+	}
+	if len(location) > 0 {
+		b.WriteString(fmt.Sprintf("Ubicación: %s\n", strings.Join(location, ", ")))
+	}
+	if p.Direccion != "" {
+		b.WriteString(fmt.Sprintf("Dirección: %s\n", p.Direccion))
+	}
+
+	b.WriteString("Estado: REGISTRADO en fabricalaser.com\n")
+	return b.String()
+}
+
+func buildUnregisteredCtx(localPhone string) string {
+	registerLink := fmt.Sprintf("https://fabricalaser.com/?login=1&tel=%s", localPhone)
+	var b strings.Builder
+	b.WriteString("\n\nDATOS DEL CLIENTE (base de datos FabricaLaser):\n")
+	b.WriteString("Canal: WhatsApp\n")
+	b.WriteString("Estado: NO registrado en fabricalaser.com\n")
+	b.WriteString(fmt.Sprintf("Link de registro (teléfono pre-llenado): %s\n", registerLink))
+	return b.String()
+}
@@ -0,0 +1,45 @@
+package whatsapp
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestNormalizeInteractive(t *testing.T) {
+	raw := `{"entry":[{"changes":[{"value":{"messages":[
+		{"from":"50688887777","id":"a","type":"text","text":{"body":"hola"}},
+		{"from":"50688887777","id":"b","type":"interactive","interactive":{"type":"list_reply","list_reply":{"id":"material_id=4","title":"Acrílico"}}},
+		{"from":"50688887777","id":"c","type":"button","button":{"payload":"confirmar","text":"Sí, confirmo"}},
+		{"from":"50688887777","id":"d","type":"sticker"}
+	]}}]}]}`
+	var payload WebhookPayload
+	if err := json.Unmarshal([]byte(raw), &payload); err != nil {
+		t.Fatal(err)
+	}
+
+	msgs := (&Channel{}).Normalize(&payload)
+	if len(msgs) != 3 {
+		t.Fatalf("got %d messages, want 3 (sticker ignored)", len(msgs))
+	}
+	if msgs[0].Text != "hola" || msgs[0].Choice != nil {
+		t.Errorf("text message = %+v", msgs[0])
+	}
+	if msgs[1].Text != "Acrílico" || msgs[1].Choice == nil || msgs[1].Choice.ID != "material_id=4" {
+		t.Errorf("list reply = %+v", msgs[1])
+	}
+	if msgs[2].Choice == nil || msgs[2].Choice.ID != "confirmar" {
+		t.Errorf("template button = %+v", msgs[2])
+	}
+	if msgs[1].From.Key != "50688887777" || msgs[1].From.Phone != "50688887777" {
+		t.Errorf("identity = %+v", msgs[1].From)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	if got := truncate("Acrílico transparente 3mm", 20); got != "Acrílico transparen…" {
+		t.Errorf("truncate = %q", got)
+	}
+	if got := truncate("MDF", 20); got != "MDF" {
+		t.Errorf("truncate = %q", got)
+	}
+}
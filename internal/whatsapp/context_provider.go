@@ -1,6 +1,7 @@
 package whatsapp
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"strings"
@@ -8,6 +9,7 @@ import (
 	"time"
 
 	"github.com/alonsoalpizar/fabricalaser/internal/repository"
+	"github.com/alonsoalpizar/fabricalaser/internal/tenant"
 )
 
 const (
@@ -17,14 +19,20 @@ const (
 )
 
 // WAContextProvider carga contexto dinámico desde BD (tecnologías, materiales, TelAsesor)
-// con cache de 5 minutos para evitar hits innecesarios a la DB.
+// por tenant, con cache de 5 minutos para evitar hits innecesarios a la DB.
+// El tenant es el del contexto: el dueño del número o bot que recibió el mensaje.
 type WAContextProvider struct {
 	techRepo      *repository.TechnologyRepository
 	matRepo       *repository.MaterialRepository
 	sysConfigRepo *repository.SystemConfigRepository
 	blankRepo     *repository.BlankRepository
-	mu                   sync.RWMutex
-	cachedContext        string
+	mu            sync.RWMutex
+	cache         map[uint]*tenantContext
+}
+
+// tenantContext es el contexto cacheado de un tenant
+type tenantContext struct {
+	content              string
 	asesorPhone          string
 	asesorTelegramChatID int64
 	maxMensajes          int
@@ -38,108 +46,60 @@ func NewWAContextProvider() *WAContextProvider {
 		matRepo:       repository.NewMaterialRepository(),
 		sysConfigRepo: repository.NewSystemConfigRepository(),
 		blankRepo:     repository.NewBlankRepository(),
+		cache:         make(map[uint]*tenantContext),
 	}
 }
 
 // GetDynamicContext retorna el bloque de contexto dinámico para inyectar al system prompt.
 // Incluye IDs exactos de tecnologías y materiales para que Gemini pueda usarlos en tools.
-// El resultado se cachea por 5 minutos.
-func (p *WAContextProvider) GetDynamicContext() string {
-	p.mu.RLock()
-	if p.cachedContext != "" && time.Since(p.fetchedAt) < contextCacheTTL {
-		ctx := p.cachedContext
-		p.mu.RUnlock()
-		return ctx
-	}
-	p.mu.RUnlock()
-
-	content := p.buildContext()
-
-	p.mu.Lock()
-	p.cachedContext = content
-	p.fetchedAt = time.Now()
-	p.mu.Unlock()
-
-	return content
+func (p *WAContextProvider) GetDynamicContext(ctx context.Context) string {
+	return p.load(ctx).content
 }
 
 // GetMaxMensajesDia retorna el límite diario de mensajes por número desde system_config.
-// Fallback: 20. Si no está en cache, lee de la DB directamente.
-func (p *WAContextProvider) GetMaxMensajesDia() int {
-	p.mu.RLock()
-	v := p.maxMensajes
-	p.mu.RUnlock()
-	if v > 0 {
-		return v
-	}
-
-	// Si no está en cache, leerlo ahora de la DB
-	maxMensajes := defaultMaxMsgs
-	if cfg, err := p.sysConfigRepo.FindByKey("wa_max_mensajes_dia"); err == nil && cfg.ConfigValue != "" {
-		if n, err := fmt.Sscanf(cfg.ConfigValue, "%d", &maxMensajes); err != nil || n != 1 {
-			maxMensajes = defaultMaxMsgs
-		}
-	}
-
-	p.mu.Lock()
-	p.maxMensajes = maxMensajes
-	p.mu.Unlock()
-
-	return maxMensajes
+// Fallback: 20.
+func (p *WAContextProvider) GetMaxMensajesDia(ctx context.Context) int {
+	return p.load(ctx).maxMensajes
 }
 
 // GetAsesorPhone retorna el teléfono del asesor desde system_config (TelAsesor).
-// Fallback: +50686091954. El valor se cachea junto con el contexto dinámico.
-func (p *WAContextProvider) GetAsesorPhone() string {
-	p.mu.RLock()
-	phone := p.asesorPhone
-	p.mu.RUnlock()
-
-	if phone != "" {
-		return phone
-	}
-
-	// Si no está en cache, leerlo ahora
-	if cfg, err := p.sysConfigRepo.FindByKey("TelAsesor"); err == nil && cfg.ConfigValue != "" {
-		phone = cfg.ConfigValue
-	} else {
-		phone = defaultAsesorPhone
-	}
-
-	p.mu.Lock()
-	p.asesorPhone = phone
-	p.mu.Unlock()
-
-	return phone
+// Fallback: +50686091954.
+func (p *WAContextProvider) GetAsesorPhone(ctx context.Context) string {
+	return p.load(ctx).asesorPhone
 }
 
 // GetAsesorTelegramChatID retorna el chat ID de Telegram del asesor desde system_config.
-// Retorna 0 si no está configurado. El valor se cachea junto con el contexto dinámico.
-func (p *WAContextProvider) GetAsesorTelegramChatID() int64 {
+// Retorna 0 si no está configurado.
+func (p *WAContextProvider) GetAsesorTelegramChatID(ctx context.Context) int64 {
+	return p.load(ctx).asesorTelegramChatID
+}
+
+// load retorna el contexto del tenant de ctx, recargándolo si venció
+func (p *WAContextProvider) load(ctx context.Context) *tenantContext {
+	id := tenant.ID(ctx)
 	p.mu.RLock()
-	chatID := p.asesorTelegramChatID
+	c := p.cache[id]
 	p.mu.RUnlock()
-
-	if chatID != 0 {
-		return chatID
+	if c != nil && time.Since(c.fetchedAt) < contextCacheTTL {
+		return c
 	}
 
-	// Si no está en cache, leerlo ahora
-	if cfg, err := p.sysConfigRepo.FindByKey("TelegramAsesorChatID"); err == nil && cfg.ConfigValue != "" {
-		if _, err := fmt.Sscanf(cfg.ConfigValue, "%d", &chatID); err == nil {
-			p.mu.Lock()
-			p.asesorTelegramChatID = chatID
-			p.mu.Unlock()
-		}
-	}
+	c = p.buildContext(ctx)
 
-	return chatID
+	p.mu.Lock()
+	if p.cache == nil {
+		p.cache = make(map[uint]*tenantContext)
+	}
+	p.cache[id] = c
+	p.mu.Unlock()
+	return c
 }
 
-func (p *WAContextProvider) buildContext() string {
+func (p *WAContextProvider) buildContext(ctx context.Context) *tenantContext {
 	var b strings.Builder
+	sysConfig := p.sysConfigRepo.WithContext(ctx)
 
-	techs, err := p.techRepo.FindAll()
+	techs, err := p.techRepo.WithContext(ctx).FindAll()
 	if err != nil {
 		slog.Error("WAContextProvider: error cargando tecnologías", "error", err)
 	} else {
@@ -149,7 +109,7 @@ func (p *WAContextProvider) buildContext() string {
 		}
 	}
 
-	mats, err := p.matRepo.FindAll()
+	mats, err := p.matRepo.WithContext(ctx).FindAll()
 	if err != nil {
 		slog.Error("WAContextProvider: error cargando materiales", "error", err)
 	} else {
@@ -161,19 +121,19 @@ func (p *WAContextProvider) buildContext() string {
 
 	// Teléfono del asesor
 	asesorPhone := defaultAsesorPhone
-	if cfg, err := p.sysConfigRepo.FindByKey("TelAsesor"); err == nil && cfg.ConfigValue != "" {
+	if cfg, err := sysConfig.FindByKey("TelAsesor"); err == nil && cfg.ConfigValue != "" {
 		asesorPhone = cfg.ConfigValue
 	}
 
 	// Costo de vectorización
 	costoVectorizacion := "10000"
-	if cfg, err := p.sysConfigRepo.FindByKey("CostoVectorizacion"); err == nil && cfg.ConfigValue != "" {
+	if cfg, err := sysConfig.FindByKey("CostoVectorizacion"); err == nil && cfg.ConfigValue != "" {
 		costoVectorizacion = cfg.ConfigValue
 	}
 
 	// Límite diario de mensajes
 	maxMensajes := defaultMaxMsgs
-	if cfg, err := p.sysConfigRepo.FindByKey("wa_max_mensajes_dia"); err == nil && cfg.ConfigValue != "" {
+	if cfg, err := sysConfig.FindByKey("wa_max_mensajes_dia"); err == nil && cfg.ConfigValue != "" {
 		if v, err := fmt.Sscanf(cfg.ConfigValue, "%d", &maxMensajes); err != nil || v != 1 {
 			maxMensajes = defaultMaxMsgs
 		}
@@ -181,23 +141,16 @@ func (p *WAContextProvider) buildContext() string {
 
 	// Chat ID de Telegram del asesor
 	var asesorTgChatID int64
-	if cfg, err := p.sysConfigRepo.FindByKey("TelegramAsesorChatID"); err == nil && cfg.ConfigValue != "" {
+	if cfg, err := sysConfig.FindByKey("TelegramAsesorChatID"); err == nil && cfg.ConfigValue != "" {
 		if v, err := fmt.Sscanf(cfg.ConfigValue, "%d", &asesorTgChatID); err != nil || v != 1 {
 			asesorTgChatID = 0
 		}
 	}
 
-	// Actualizar cache del teléfono, Telegram chat ID y límite al mismo tiempo que el contexto
-	p.mu.Lock()
-	p.asesorPhone = asesorPhone
-	p.asesorTelegramChatID = asesorTgChatID
-	p.maxMensajes = maxMensajes
-	p.mu.Unlock()
-
 	b.WriteString(fmt.Sprintf("\n## Configuración operativa:\n- Teléfono asesor para escalado: %s\n- Costo de vectorización: ₡%s\n", asesorPhone, costoVectorizacion))
 
 	// Catálogo de blanks (resumen para que el agente sepa qué categorías existen)
-	blanks, err := p.blankRepo.FindAll()
+	blanks, err := p.blankRepo.WithContext(ctx).FindAll()
 	if err != nil {
 		slog.Error("WAContextProvider: error cargando blanks", "error", err)
 	} else if len(blanks) > 0 {
@@ -212,5 +165,11 @@ func (p *WAContextProvider) buildContext() string {
 		}
 	}
 
-	return b.String()
+	return &tenantContext{
+		content:              b.String(),
+		asesorPhone:          asesorPhone,
+		asesorTelegramChatID: asesorTgChatID,
+		maxMensajes:          maxMensajes,
+		fetchedAt:            time.Now(),
+	}
 }
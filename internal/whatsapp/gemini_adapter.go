@@ -4,25 +4,33 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
 	"log/slog"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
-	"cloud.google.com/go/vertexai/genai"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
+	"github.com/alonsoalpizar/fabricalaser/internal/channels"
+	"github.com/alonsoalpizar/fabricalaser/internal/database"
+	"github.com/alonsoalpizar/fabricalaser/internal/llm"
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+	"github.com/alonsoalpizar/fabricalaser/internal/repository"
+	"github.com/alonsoalpizar/fabricalaser/internal/services/blankconfig"
+	"github.com/alonsoalpizar/fabricalaser/internal/services/designfiles"
+	"github.com/alonsoalpizar/fabricalaser/internal/services/handoff"
+	"github.com/alonsoalpizar/fabricalaser/internal/services/knowledge"
+	"github.com/alonsoalpizar/fabricalaser/internal/services/leads"
+	"github.com/alonsoalpizar/fabricalaser/internal/services/pricing"
+	"github.com/alonsoalpizar/fabricalaser/internal/services/quotedraft"
+	"github.com/alonsoalpizar/fabricalaser/internal/services/quotepdf"
+	"github.com/alonsoalpizar/fabricalaser/internal/services/tracer"
 )
 
 const (
-	waProjectID = "div-aloalpizar"
-	waLocation  = "us-central1"
-	waModelName = "gemini-2.5-flash"
-
 	toolLoopMax     = 5
 	estimateURL     = "http://localhost:8083/api/v1/quotes/estimate"
 	consultarBlankURL = "http://localhost:8083/api/v1/blanks/consultar"
@@ -49,48 +57,19 @@ Si el cliente no da su nombre o evade, no insistás más de una vez.
 MATERIALES Y CORTABILIDAD:
 REGLA CRÍTICA DE MATERIALES: Solo podés aceptar y cotizar materiales que aparezcan EXACTAMENTE en la lista "Materiales disponibles" al final de este prompt (datos en tiempo real desde la base de datos). Si el cliente menciona un material que NO está en esa lista, respondé: "Ese material no está disponible en nuestro catálogo actualmente. Los materiales que trabajamos son: [lista los de la BD]." No cotices ni confirmes disponibilidad de materiales fuera de esa lista, sin importar si técnicamente serían grabables.
 
-Cortables con CO2 (única tecnología que corta): Madera/MDF, Acrílico, Cuero/Piel, Plástico ABS/PC
-NO cortables (solo grabado): Vidrio/Cristal, Cerámica, Metal con coating
-Si el cliente pide corte en material no cortable → explicá que no cortamos ese material y ofrecé solo grabado.
-
-Espesores para corte con CO2:
-Madera/MDF: 3, 5, 6, 9, 12mm — Acrílico: 3, 5, 6, 8, 10mm — Cuero/Piel: 2, 4mm — Plástico ABS/PC: 2, 3mm
-
-ÁRBOL DE DECISIÓN — 4 casos:
-
-CASO 1 — Solo corte sin grabado:
-Tecnología: CO2, solo materiales cortables.
-tool: technology_id=CO2, incluye_corte=true, sin cut_technology_id.
-
-CASO 2 — Solo grabado sin corte:
-Madera/MDF/Cuero → CO2
-Acrílico (cualquier color) → UV siempre
-Vidrio/Cerámica → UV
-Plástico ABS/PC → UV
-Metales sin color especial → Fibra
-Aluminio anodizado con color / metal con acabado de color → MOPA
-tool: technology_id=tech correspondiente, incluye_corte=false, sin cut_technology_id.
-
-CASO 3A — Grabado + corte en material orgánico (Madera, MDF, Cuero):
-CO2 hace todo: graba Y corta.
-tool: technology_id=CO2, incluye_corte=true, sin cut_technology_id.
-
-CASO 3B — Grabado + corte en Acrílico o Plástico:
-UV graba, CO2 corta (dos máquinas, proceso premium).
-OBLIGATORIO preguntar el grosor antes de cotizar.
-Avisar al cliente: "El grabado lo hacemos con láser UV y el corte con CO2."
-tool: technology_id=UV, cut_technology_id=ID_CO2, incluye_corte=true, thickness=grosor_cliente.
-
-CASO 3C — Material no cortable con grabado + corte solicitado:
-Ignorar el corte, solo grabado.
-Vidrio/Cerámica → UV. Metal → Fibra o MOPA según acabado.
-tool: technology_id=tech, incluye_corte=false, sin cut_technology_id.
+TECNOLOGÍA, CORTE Y GROSORES — recomendar_tecnologia:
+Qué tecnología graba o corta cada material, qué materiales se pueden cortar y en qué grosores lo decide la base de datos, no vos. Llamá recomendar_tecnologia con material_id, operacion (grabado, corte o grabado_corte), thickness si ya lo sabés y acabado_color=true si la pieza tiene color que respetar.
+Usá el primer plan: pasá a calcular_cotizacion exactamente los valores de "cotizar_con" (technology_id, cut_technology_id, incluye_corte).
+Si needs_thickness = true → preguntá el grosor; los válidos vienen en cut_thicknesses.
+Si no hay planes → explicá con amabilidad lo que dicen las notas (por ejemplo, que no cortamos ese grosor) y ofrecé los grosores válidos.
+Si ignore_cut = true → no cortamos ese material: explicalo y ofrecé solo el grabado (incluye_corte=false).
+Si el plan graba con una tecnología y corta con otra, avisale al cliente: "El grabado lo hacemos con láser [tecnología de grabado] y el corte con [tecnología de corte]."
 
 FLUJO DE PREGUNTAS (en orden, una a la vez):
 1. ¿Qué quiere hacer? (grabar, cortar, o ambos)
 2. ¿En qué material?
-3. Inferir el caso según el árbol de arriba.
-4. Si hay corte con CO2 → ¿Qué grosor necesitás?
+3. Llamar recomendar_tecnologia para saber la tecnología.
+4. Si hay corte → ¿Qué grosor necesitás? (solo los grosores válidos del tool)
 5. ¿Qué medidas? (alto × ancho en cm) — ver reglas especiales para cajas abajo
 6. ¿Cuántas piezas/unidades del producto final?
 7. Si hay grabado → ¿El grabado es con relleno (foto, sello, área completa) o solo contornos/líneas del diseño?
@@ -106,11 +85,7 @@ OBJETOS CILÍNDRICOS Y COPAS (termos, botellas, tazas, vasos, copas, cilindros):
 El cliente trae su propio objeto. FabricaLaser graba en la superficie curva usando el accesorio rotativo — el proceso de cotización es idéntico al grabado plano.
 Preguntar solo las medidas del área de grabado (alto × ancho en cm) y cantidad de piezas.
 Preguntar siempre: ¿FabricaLaser provee el objeto o el cliente lo trae?
-Tecnología según el material:
-  - Termo/botella Yeti, Stanley, Hydro Flask u otro con pintura o coating de color → MOPA
-  - Termo o botella de acero inoxidable sin color especial → Fibra
-  - Taza, vaso, copa o cualquier objeto de vidrio o cristal → UV
-  - Taza de cerámica → UV
+La tecnología sale de recomendar_tecnologia con operacion=grabado; los termos o botellas con pintura o coating de color (Yeti, Stanley, Hydro Flask) van con acabado_color=true.
 Estos objetos NO son productos para ensamblar — cotizarlos normalmente con calcular_cotizacion.
 
 PRODUCTOS 3D Y ENSAMBLADOS — ESCALAR SIEMPRE:
@@ -123,14 +98,18 @@ Cuando el cliente consulte sobre llaveros, medallas u otros blanks del catálogo
 El catálogo está en la base de datos — no asumas precios fijos.
 
 Si hay múltiples opciones en una categoría, el tool retorna una lista; presentala de forma natural y preguntale al cliente cuál prefiere.
-Si el blank tiene accesorios_opcionales, mencionarlos solo si el cliente pregunta. Si los quiere, sumar al total: precio_accesorio × cantidad.
+Si el blank tiene accesorios_opcionales, mencionarlos solo si el cliente pregunta.
+Cuando el cliente ya eligió el blank y la cantidad y quiere accesorios o un grabado (medidas por cara y cuántas caras), usá configurar_blank: retorna el precio por tramo, los accesorios por paquete, el grabado y el total. Presentá ese desglose tal cual, sin sumar montos a mano.
+Si configurar_blank retorna error, explicale al cliente qué ajustar (mínimo de unidades, accesorio, tamaño o caras del grabado).
 Si el campo bajo_minimo = true, avisá amablemente el mínimo de unidades requerido.
 Si el campo sin_stock o stock_bajo = true, incluí el mensaje_stock en tu respuesta.
 
 AL PRESENTAR CUALQUIER PRECIO:
 Si el cliente SÍ tiene archivo SVG:
 "Para [cantidad] [descripción] en [material], trabajadas con [tecnología/s] — trabajo de grabado/corte láser premium:
-Precio de referencia: ₡[precio_estimado] (₡[precio_unitario] c/u)
+Subtotal: ₡[subtotal] (₡[precio_unitario] c/u)
+IVA ([tarifa_iva]%): ₡[iva]
+Total de referencia: ₡[total]
 
 Este es un precio de referencia. El asesor confirmará el precio final antes de procesar tu pedido.
 
@@ -140,13 +119,21 @@ Si el cliente NO tiene archivo SVG:
 "Para [cantidad] [descripción] en [material], trabajadas con [tecnología/s] — trabajo de grabado/corte láser premium:
 [Grabado/Corte]: ₡[precio_estimado]
 Vectorización del diseño: ₡[CostoVectorizacion]
-Total estimado: ₡[precio_estimado + CostoVectorizacion] (₡[unitario_con_vectorizacion] c/u)
+Subtotal: ₡[precio_estimado + CostoVectorizacion] (₡[unitario_con_vectorizacion] c/u)
+IVA ([tarifa_iva]%): ₡[IVA del subtotal a la tarifa_iva]
+Total estimado: ₡[subtotal + IVA]
 
 Este es un precio de referencia. El asesor confirmará el precio final antes de procesar tu pedido.
 
 ¿Te interesa coordinar el pedido?"
 
-Ejemplos de mención de tecnología según el caso:
+IVA — SIEMPRE CONSISTENTE:
+Los precios de calcular_cotizacion, consultar_blank y configurar_blank vienen SIN IVA; los tools ya retornan subtotal, tarifa_iva, iva y total.
+Mostrá siempre el desglose subtotal + IVA = total, usando los montos del tool (no recalcules el IVA salvo al sumar la vectorización).
+Si modo_iva = "inclusive", mostrá solo el total con la leyenda "IVA incluido (₡[iva])" en lugar del desglose.
+Si el cliente pide dólares, usá el mismo desglose con el símbolo $.
+
+Ejemplos de mención de tecnología según el plan:
 "trabajadas con láser CO2" — "grabadas con láser UV premium y cortadas con CO2" — "marcadas con láser MOPA"
 
 IMPORTANTE: Siempre incluí la/s tecnología/s y "trabajo de grabado/corte láser premium". La frase de precio de referencia debe aparecer SIEMPRE, sin excepción.
@@ -163,19 +150,42 @@ La consulta es muy técnica o requiere revisión de diseño
 El trabajo necesita revisión según el resultado de la cotización
 
 FLUJO CORRECTO cuando el cliente confirma:
-1. Llamá INMEDIATAMENTE a escalar_a_humano (sin texto previo)
+1. Llamá INMEDIATAMENTE a escalar_a_humano (sin texto previo). Además del resumen, pasá los datos del trabajo que ya se conocen (descripcion, medidas, cantidad, material_id, thickness, technology_id, blank_id): con eso se arma el borrador de cotización para el asesor
 2. Después de recibir la respuesta del tool, escribí el mensaje de confirmación al cliente
 3. En el mensaje de confirmación, decí que el asesor lo contactará POR EL MISMO CANAL donde está la conversación (ver DATOS DEL CLIENTE). NUNCA menciones otro canal.
-
-RETIRO Y ENVÍOS:
-Taller: Avenida 67, San Jerónimo, Tibás, San José. Solo con cita previa coordinada por mensajería.
-Envíos a todo el país. 3.500 colones el primer kilo por Correos CR o mensajería.
-Tiempo de producción: 1 día hábil desde confirmación de pago.
+4. Ese es tu último mensaje: a partir de ahí el asesor continúa la conversación. No termines con pregunta.
+Los mensajes que empiezan con "[Asesor]" en el historial los escribió el asesor; si la conversación vuelve a vos, seguí desde lo que él acordó con el cliente.
+
+COTIZACIÓN FORMAL EN PDF:
+Si un cliente REGISTRADO pide su cotización en PDF, la cotización formal, "la proforma" o un documento para su empresa, llamá enviar_cotizacion_pdf.
+Si menciona el número de cotización, pasalo en cotizacion_id; si no, el tool envía la más reciente.
+El PDF solo existe para cotizaciones guardadas en el cotizador web; los estimados de este chat no tienen PDF.
+Si el tool responde enviado = true, el documento ya le llegó: confirmá en una línea ("Listo, te mandé la cotización #[cotizacion_id] en PDF") sin repetir los montos.
+Si responde motivo = "cliente_no_registrado" o "sin_cotizaciones", explicá que puede generar su cotización formal en fabricalaser.com/cotizar.
+
+OPCIONES PARA TOCAR:
+Cuando preguntes el material (paso 2 del flujo) o haya que elegir tecnología para un material, llamá mostrar_opciones en lugar de escribir la lista: el cliente recibe botones o una lista y elige con un toque.
+tipo = "material" para el catálogo de materiales; tipo = "tecnologia" con material_id (y thickness si ya lo sabés) para las tecnologías compatibles.
+Si el tool responde enviado = true, las opciones ya le llegaron: respondé solo con una frase corta ("Elegí la opción que más te sirva") sin repetir la lista.
+Cuando el cliente toca una opción, su mensaje trae "[opción elegida: material_id=N]" o "[opción elegida: technology_id=N]": usá ese ID tal cual en calcular_cotizacion.
+Si el cliente ya dijo el material o la tecnología por escrito, no le muestres opciones.
+
+BASE DE CONOCIMIENTO — buscar_conocimiento:
+Retiro en taller, envíos, tiempos de producción, preparación del archivo de diseño, cuidado de las piezas y preguntas frecuentes están en la base de conocimiento, no en este prompt. Ante cualquiera de esas dudas llamá buscar_conocimiento con la consulta en pocas palabras y respondé solo con lo que dicen los pasajes, en tus palabras y sin mencionar la cita.
+Si responde encontrado = false, no inventés: decí que lo confirma el asesor.
 
 IMÁGENES:
 Este agente puede recibir y analizar imágenes enviadas por el cliente.
 Cuando el cliente diga que va a mandar una imagen, respondé ÚNICAMENTE: "¡Perfecto! Mandala cuando quieras." — nada más, sin agregar ninguna aclaración.
 Cuando el cliente mande una imagen, la analizarás y preguntarás medidas — nunca cotizarás directamente desde la imagen.
+Si la imagen es un logo, texto o diseño para grabar y el cliente ya dio la medida, llamá vectorizar_imagen (ancho_cm o alto_cm del diseño, material, cantidad) en lugar de calcular_cotizacion: el precio sale del trazado real, no de un rectángulo.
+Usá modo "vector" solo si el cliente quiere el contorno del diseño; si no, "raster". Después, para cambiar material o cantidad, usá cotizar_archivo.
+Si vectorizar_imagen responde error, cotizá con calcular_cotizacion usando las medidas como siempre.
+
+ARCHIVOS SVG/DXF:
+Cuando el cliente manda un archivo SVG o DXF, el sistema ya le respondió con el largo de corte, el grabado y un precio de referencia (mensaje "[El cliente mandó el archivo ...]" en el historial).
+Si después indica material, grosor, tecnología o cantidad, llamá cotizar_archivo en lugar de calcular_cotizacion: usa la geometría real del archivo, no hace falta pedir medidas.
+Si cotizar_archivo responde error "sin_archivo", pedile que vuelva a mandar el archivo.
 PROHIBIDO ABSOLUTO: Nunca uses las frases "asistente de texto", "no puedo ver imágenes", "no tengo capacidad visual" ni ninguna variante. Bajo ninguna circunstancia, ni como aclaración ni como recordatorio.
 
 COLORES DE ACRÍLICO:
@@ -238,67 +248,43 @@ Reglas para imágenes:
 - No des precios ni estimados basados en la imagen`
 
 type geminiAdapter struct {
-	client          *genai.Client
+	llm             llm.Provider
 	contextProvider *WAContextProvider
-	sender          *Sender
-	tgSender        *tgSenderAdapter
-}
-
-// tgSenderAdapter es un wrapper liviano para enviar mensajes vía Telegram Bot API.
-// Se usa solo para notificaciones al asesor cuando el cliente viene de Telegram.
-type tgSenderAdapter struct {
-	botToken   string
-	httpClient *http.Client
+	registry        *channels.Registry // para escalar al asesor y enviar documentos por el canal del cliente
+	files           *designfiles.Service
+	handoffs        *handoff.Service
+	leads           *leads.Service
+	drafts          *quotedraft.Service
+	knowledge       *knowledge.Service              // Base de conocimiento (buscar_conocimiento)
+	recommender     *pricing.Recommender            // Tecnologías válidas por material (recomendar_tecnologia)
+	blanks          *blankconfig.Configurator       // Blank con accesorios y grabado (configurar_blank)
+	botEvents       *repository.AnalyticsRepository // Llamadas a tools para la analítica de conversaciones
 }
 
-func (s *tgSenderAdapter) sendText(ctx context.Context, chatID int64, text string) error {
-	url := fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", s.botToken)
-	payload := fmt.Sprintf(`{"chat_id":%d,"text":%s}`, chatID, jsonEscapeString(text))
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, strings.NewReader(payload))
-	if err != nil {
-		return err
-	}
-	req.Header.Set("Content-Type", "application/json")
-	resp, err := s.httpClient.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("telegram API status %d: %s", resp.StatusCode, string(body))
-	}
-	return nil
-}
-
-func jsonEscapeString(s string) string {
-	b, _ := json.Marshal(s)
-	return string(b)
-}
-
-// NewGeminiAdapter crea un GeminiCaller con soporte de tools y contexto dinámico.
-func NewGeminiAdapter(provider *WAContextProvider) GeminiCaller {
-	ctx := context.Background()
-	client, err := genai.NewClient(ctx, waProjectID, waLocation)
-	if err != nil {
-		log.Fatalf("whatsapp: failed to create Vertex AI client: %v", err)
-	}
+// NewGeminiAdapter crea el agente con soporte de tools y contexto dinámico.
+// model es el proveedor del modelo (Gemini en producción, llm.Script en tests);
+// files recotiza los archivos SVG/DXF que el cliente mandó en la conversación;
+// handoffs abre la atención humana al escalar (el bot queda en pausa; nil solo
+// avisa al asesor, como en cmd/boteval);
+// leads avanza el embudo de ventas cuando el bot da un precio o escala;
+// drafts deja el trabajo conversado como borrador de cotización para el asesor;
+// kb responde las dudas del negocio con pasajes de la base de conocimiento.
+func NewGeminiAdapter(model llm.Provider, provider *WAContextProvider, registry *channels.Registry, files *designfiles.Service, handoffs *handoff.Service, leadService *leads.Service, drafts *quotedraft.Service, kb *knowledge.Service) channels.Agent {
 	return &geminiAdapter{
-		client:          client,
+		llm:             model,
 		contextProvider: provider,
-		sender:          NewSender(),
-		tgSender: &tgSenderAdapter{
-			botToken:   os.Getenv("TELEGRAM_BOT_TOKEN"),
-			httpClient: &http.Client{Timeout: 15 * time.Second},
-		},
+		registry:        registry,
+		files:           files,
+		handoffs:        handoffs,
+		leads:           leadService,
+		drafts:          drafts,
+		knowledge:       kb,
+		recommender:     pricing.NewRecommender(pricing.NewConfigLoader(database.Get())),
+		blanks:          blankconfig.NewConfigurator(),
+		botEvents:       repository.NewAnalyticsRepository(),
 	}
 }
 
-// CallWithHistory es el método legacy — delega a CallWithTools sin contexto de usuario.
-func (g *geminiAdapter) CallWithHistory(ctx context.Context, history []ChatTurn, newMessage string) (string, error) {
-	return g.CallWithTools(ctx, "", history, newMessage, "")
-}
-
 // SummarizeConversation genera un resumen conciso de la conversación para el asesor.
 // Usa un modelo sin tools y con temperatura baja para obtener un resumen factual.
 func (g *geminiAdapter) SummarizeConversation(ctx context.Context, history []ChatTurn) (string, error) {
@@ -323,223 +309,143 @@ func (g *geminiAdapter) SummarizeConversation(ctx context.Context, history []Cha
 		"y si mostró intención de compra. Solo datos concretos, sin adornos.\n\n" +
 		"Conversación:\n" + sb.String()
 
-	model := g.client.GenerativeModel(waModelName)
-	model.SetTemperature(0.1)
-	model.SetMaxOutputTokens(300)
-
 	ctxTimeout, cancel := context.WithTimeout(ctx, 15*time.Second)
 	defer cancel()
 
-	resp, err := model.GenerateContent(ctxTimeout, genai.Text(prompt))
-	if err != nil {
-		return "", fmt.Errorf("SummarizeConversation: %w", err)
-	}
-	if len(resp.Candidates) == 0 || len(resp.Candidates[0].Content.Parts) == 0 {
+	summary, err := llm.Summarize(ctxTimeout, g.llm, llm.Config{
+		Temperature:     llm.Float32(0.1),
+		MaxOutputTokens: llm.Int32(300),
+	}, prompt)
+	if errors.Is(err, llm.ErrEmptyResponse) {
 		return "", nil
 	}
-	if txt, ok := resp.Candidates[0].Content.Parts[0].(genai.Text); ok {
-		return strings.TrimSpace(string(txt)), nil
+	if err != nil {
+		return "", fmt.Errorf("SummarizeConversation: %w", err)
 	}
-	return "", nil
+	return summary, nil
 }
 
-// CallWithTools llama a Gemini con historial, tools habilitadas y contexto dinámico.
+// CallWithTools llama al modelo con historial, tools habilitadas y contexto dinámico.
 // Ejecuta el loop de tool calling hasta toolLoopMax iteraciones.
-func (g *geminiAdapter) CallWithTools(ctx context.Context, phone string, history []ChatTurn, newMessage string, userCtx string) (string, error) {
-	model := g.client.GenerativeModel(waModelName)
-
-	dynCtx := g.contextProvider.GetDynamicContext()
-	model.SystemInstruction = &genai.Content{
-		Parts: []genai.Part{genai.Text(systemPromptWA + dynCtx + userCtx)},
-	}
-	model.Tools = []*genai.Tool{{
-		FunctionDeclarations: []*genai.FunctionDeclaration{
-			calcularCotizacionTool(),
-			consultarBlankTool(),
-			escalarAHumanoTool(),
-		},
-	}}
-	model.SetTemperature(0.3)
-	model.SetTopP(0.95)
-	model.SetMaxOutputTokens(1024)
-
-	chat := model.StartChat()
-	for _, turn := range history {
-		chat.History = append(chat.History, &genai.Content{
-			Role:  turn.Role,
-			Parts: []genai.Part{genai.Text(turn.Content)},
-		})
-	}
-
-	// Primer turno también con retry ante 429
-	resp, err := sendWithRetry(ctx, chat, genai.Text(newMessage))
+func (g *geminiAdapter) CallWithTools(ctx context.Context, from channels.Identity, history []ChatTurn, newMessage string, userCtx string) (string, error) {
+	tools := g.tools(from)
+	chat := llm.NewChat(g.llm, llm.Config{
+		System:          systemPromptWA + g.contextProvider.GetDynamicContext(ctx) + userCtx,
+		Tools:           tools.Tools(),
+		Temperature:     llm.Float32(0.3),
+		TopP:            llm.Float32(0.95),
+		MaxOutputTokens: llm.Int32(1024),
+	}, chatHistory(history))
+
+	resp, err := chat.Send(ctx, llm.Text(newMessage))
 	if err != nil {
 		return "", fmt.Errorf("geminiAdapter: error llamando al modelo: %w", err)
 	}
 
-	// Loop de tool calling
-	for i := 0; i < toolLoopMax; i++ {
-		if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil {
-			break
-		}
-
-		// Buscar FunctionCall en los parts de la respuesta
-		var fc *genai.FunctionCall
-		for _, part := range resp.Candidates[0].Content.Parts {
-			if call, ok := part.(genai.FunctionCall); ok {
-				fc = &call
-				break
-			}
-		}
-
-		if fc == nil {
-			// Sin tool call → extraer texto y retornar
-			var sb strings.Builder
-			for _, part := range resp.Candidates[0].Content.Parts {
-				if text, ok := part.(genai.Text); ok {
-					sb.WriteString(string(text))
-				}
-			}
-			if sb.Len() > 0 {
-				return sb.String(), nil
-			}
-			// Respuesta vacía — salir del loop y usar fallback
-			break
-		}
-
-		// Ejecutar la función
-		result, err := g.executeFunction(ctx, phone, fc)
-		if err != nil {
-			slog.Error("geminiAdapter: error ejecutando tool", "tool", fc.Name, "error", err)
-			result = map[string]any{"error": err.Error()}
-		}
-
-		// Enviar FunctionResponse al modelo (con retry en caso de 429)
-		resp, err = sendWithRetry(ctx, chat, genai.FunctionResponse{
-			Name:     fc.Name,
-			Response: result,
-		})
-		if err != nil {
-			return "", fmt.Errorf("geminiAdapter: error enviando FunctionResponse: %w", err)
+	resp, err = chat.RunTools(ctx, resp, toolLoopMax, tools, func(tr llm.ToolTrace) {
+		ok := tr.Err == nil && toolSucceeded(tr.Result)
+		if ok {
+			g.trackLead(ctx, from, tr.Call.Name)
 		}
+		go g.recordToolCall(context.WithoutCancel(ctx), from, tr.Call.Name, ok)
+	})
+	if err != nil {
+		return "", fmt.Errorf("geminiAdapter: error enviando FunctionResponse: %w", err)
 	}
 
-	// Extraer texto del último resp
-	if len(resp.Candidates) > 0 && resp.Candidates[0].Content != nil {
-		var sb strings.Builder
-		for _, part := range resp.Candidates[0].Content.Parts {
-			if text, ok := part.(genai.Text); ok {
-				sb.WriteString(string(text))
-			}
-		}
-		if sb.Len() > 0 {
-			return sb.String(), nil
-		}
+	if text := resp.Text(); text != "" {
+		return text, nil
 	}
-
 	return "Hubo un problema procesando tu consulta. Por favor escribinos al +506 7018-3073.", nil
 }
 
-// CallWithImage llama a Gemini con historial y una imagen inline (sin tools).
+// CallWithImage llama al modelo con historial y una imagen inline (sin tools).
 // Usa systemPromptImagen adicional para guiar el análisis de la imagen.
-func (g *geminiAdapter) CallWithImage(ctx context.Context, phone string, history []ChatTurn, imageBytes []byte, mimeType string, caption string, userCtx string) (string, error) {
-	model := g.client.GenerativeModel(waModelName)
-
-	dynCtx := g.contextProvider.GetDynamicContext()
-	model.SystemInstruction = &genai.Content{
-		Parts: []genai.Part{genai.Text(systemPromptWA + systemPromptImagen + dynCtx + userCtx)},
-	}
+func (g *geminiAdapter) CallWithImage(ctx context.Context, from channels.Identity, history []ChatTurn, imageBytes []byte, mimeType string, caption string, userCtx string) (string, error) {
 	// Sin tools — solo análisis visual y respuesta de texto
-	model.SetTemperature(0.7)
-	model.SetTopP(0.95)
-	model.SetMaxOutputTokens(512)
-
-	chat := model.StartChat()
-	for _, turn := range history {
-		chat.History = append(chat.History, &genai.Content{
-			Role:  turn.Role,
-			Parts: []genai.Part{genai.Text(turn.Content)},
-		})
-	}
+	chat := llm.NewChat(g.llm, llm.Config{
+		System:          systemPromptWA + systemPromptImagen + g.contextProvider.GetDynamicContext(ctx) + userCtx,
+		Temperature:     llm.Float32(0.7),
+		TopP:            llm.Float32(0.95),
+		MaxOutputTokens: llm.Int32(512),
+	}, chatHistory(history))
 
 	// Construir mensaje con imagen + texto
-	parts := []genai.Part{
-		genai.Blob{MIMEType: mimeType, Data: imageBytes},
+	if caption == "" {
+		caption = "El cliente mandó esta imagen."
 	}
-	if caption != "" {
-		parts = append(parts, genai.Text(caption))
-	} else {
-		parts = append(parts, genai.Text("El cliente mandó esta imagen."))
-	}
-
-	resp, err := chat.SendMessage(ctx, parts...)
+	resp, err := chat.Send(ctx, llm.Image(mimeType, imageBytes), llm.Text(caption))
 	if err != nil {
 		return "", fmt.Errorf("geminiAdapter: error llamando al modelo con imagen: %w", err)
 	}
 
-	if len(resp.Candidates) > 0 && resp.Candidates[0].Content != nil {
-		var sb strings.Builder
-		for _, part := range resp.Candidates[0].Content.Parts {
-			if text, ok := part.(genai.Text); ok {
-				sb.WriteString(string(text))
-			}
-		}
-		if sb.Len() > 0 {
-			return sb.String(), nil
-		}
+	if text := resp.Text(); text != "" {
+		return text, nil
 	}
-
 	return "No pude analizar la imagen. ¿Me podés describir qué querés hacer?", nil
 }
 
+// chatHistory convierte el historial guardado en Redis a mensajes del modelo
+func chatHistory(history []ChatTurn) []llm.Message {
+	out := make([]llm.Message, len(history))
+	for i, turn := range history {
+		out[i] = llm.Message{Role: turn.Role, Parts: []llm.Part{llm.Text(turn.Content)}}
+	}
+	return out
+}
+
 // ─── Tool Definitions ────────────────────────────────────────────────────────
 
-func calcularCotizacionTool() *genai.FunctionDeclaration {
-	return &genai.FunctionDeclaration{
+func calcularCotizacionTool() llm.Tool {
+	return llm.Tool{
 		Name:        "calcular_cotizacion",
 		Description: "Calcula el precio estimado de un trabajo de grabado o corte láser según las medidas del área de trabajo. Usar cuando el cliente ya proporcionó material, medidas y cantidad.",
-		Parameters: &genai.Schema{
-			Type: genai.TypeObject,
-			Properties: map[string]*genai.Schema{
+		Parameters: &llm.Schema{
+			Type: llm.TypeObject,
+			Properties: map[string]*llm.Schema{
 				"alto_cm": {
-					Type:        genai.TypeNumber,
+					Type:        llm.TypeNumber,
 					Description: "Alto del área a grabar o cortar, en centímetros",
 				},
 				"ancho_cm": {
-					Type:        genai.TypeNumber,
+					Type:        llm.TypeNumber,
 					Description: "Ancho del área a grabar o cortar, en centímetros",
 				},
 				"cantidad": {
-					Type:        genai.TypeInteger,
+					Type:        llm.TypeInteger,
 					Description: "Número de unidades a producir",
 				},
 				"technology_id": {
-					Type:        genai.TypeInteger,
+					Type:        llm.TypeInteger,
 					Description: "ID de la tecnología láser a usar (ver IDs al final del system prompt)",
 				},
 				"material_id": {
-					Type:        genai.TypeInteger,
+					Type:        llm.TypeInteger,
 					Description: "ID del material a trabajar (ver IDs al final del system prompt)",
 				},
 				"engrave_type_id": {
-					Type:        genai.TypeInteger,
+					Type:        llm.TypeInteger,
 					Description: "ID del tipo de grabado: 1=Vectorial, 2=Rasterizado, 3=Fotograbado, 4=3D/Relieve. Default: 1",
 				},
 				"thickness": {
-					Type:        genai.TypeNumber,
+					Type:        llm.TypeNumber,
 					Description: "Grosor del material en milímetros. Default: 3.0",
 				},
 				"material_included": {
-					Type:        genai.TypeBoolean,
+					Type:        llm.TypeBoolean,
 					Description: "true si FabricaLaser provee el material, false si el cliente lo trae",
 				},
 				"incluye_corte": {
-					Type:        genai.TypeBoolean,
+					Type:        llm.TypeBoolean,
 					Description: "true si el trabajo incluye corte del perímetro además del grabado",
 				},
 				"cut_technology_id": {
-					Type:        genai.TypeInteger,
-					Description: "ID de tecnología para el corte cuando es diferente a la tecnología de grabado. Usar SOLO en Caso 3B: cuando el cliente quiere grabar con UV y cortar con CO2 (acrílico o plástico con grabado+corte). En todos los demás casos omitir este campo.",
+					Type:        llm.TypeInteger,
+					Description: "ID de tecnología para el corte cuando es diferente a la tecnología de grabado. Usar solo si recomendar_tecnologia lo indica en cotizar_con; en los demás casos omitir este campo.",
+				},
+				"moneda": {
+					Type:        llm.TypeString,
+					Description: "Moneda del precio: \"CRC\" (default) o \"USD\". Usar USD solo si el cliente pide el precio en dólares.",
 				},
 			},
 			Required: []string{"alto_cm", "ancho_cm", "cantidad", "technology_id", "material_id", "material_included", "incluye_corte"},
@@ -547,40 +453,110 @@ func calcularCotizacionTool() *genai.FunctionDeclaration {
 	}
 }
 
-func escalarAHumanoTool() *genai.FunctionDeclaration {
-	return &genai.FunctionDeclaration{
+func recomendarTecnologiaTool() llm.Tool {
+	return llm.Tool{
+		Name:        "recomendar_tecnologia",
+		Description: "Dice qué tecnología usar para un trabajo según el material, si se graba, se corta o ambas, el grosor y si la pieza tiene acabado de color. Devuelve los planes válidos (mejor primero) con sus motivos, los grosores en que se corta el material y los valores de technology_id, cut_technology_id e incluye_corte para calcular_cotizacion. Usar SIEMPRE antes de cotizar o de afirmar qué tecnología aplica.",
+		Parameters: &llm.Schema{
+			Type: llm.TypeObject,
+			Properties: map[string]*llm.Schema{
+				"material_id": {
+					Type:        llm.TypeInteger,
+					Description: "ID del material (ver lista de materiales)",
+				},
+				"operacion": {
+					Type:        llm.TypeString,
+					Description: "Qué quiere el cliente: grabado, corte o grabado_corte",
+					Enum:        []string{"grabado", "corte", "grabado_corte"},
+				},
+				"thickness": {
+					Type:        llm.TypeNumber,
+					Description: "Grosor del material en mm. 0 (o no incluir) si no se sabe todavía",
+				},
+				"acabado_color": {
+					Type:        llm.TypeBoolean,
+					Description: "true si la pieza tiene acabado o recubrimiento de color que hay que respetar (aluminio anodizado, termo pintado tipo Yeti/Stanley)",
+				},
+			},
+			Required: []string{"material_id", "operacion"},
+		},
+	}
+}
+
+func escalarAHumanoTool() llm.Tool {
+	return llm.Tool{
 		Name:        "escalar_a_humano",
 		Description: "Envía al asesor de ventas un resumen de la conversación cuando el cliente está listo para hacer el pedido o necesita atención personalizada.",
-		Parameters: &genai.Schema{
-			Type: genai.TypeObject,
-			Properties: map[string]*genai.Schema{
+		Parameters: &llm.Schema{
+			Type: llm.TypeObject,
+			Properties: map[string]*llm.Schema{
 				"resumen": {
-					Type:        genai.TypeString,
+					Type:        llm.TypeString,
 					Description: "Resumen del contexto de la conversación: qué quiere el cliente, producto, medidas, cantidad, precio estimado si se calculó",
 				},
+				"descripcion": {
+					Type:        llm.TypeString,
+					Description: "El trabajo en palabras del cliente, p.ej. \"30 llaveros de MDF con logo\"",
+				},
+				"ancho_cm": {
+					Type:        llm.TypeNumber,
+					Description: "Ancho del área a grabar o cortar en cm, si el cliente lo dijo",
+				},
+				"alto_cm": {
+					Type:        llm.TypeNumber,
+					Description: "Alto del área a grabar o cortar en cm, si el cliente lo dijo",
+				},
+				"cantidad": {
+					Type:        llm.TypeInteger,
+					Description: "Unidades, si el cliente lo dijo",
+				},
+				"material_id": {
+					Type:        llm.TypeInteger,
+					Description: "ID del material, si ya se definió (ver IDs al final del system prompt)",
+				},
+				"thickness": {
+					Type:        llm.TypeNumber,
+					Description: "Grosor del material en mm, si se definió",
+				},
+				"technology_id": {
+					Type:        llm.TypeInteger,
+					Description: "ID de la tecnología, si ya se definió",
+				},
+				"engrave_type_id": {
+					Type:        llm.TypeInteger,
+					Description: "ID del tipo de grabado, si ya se definió",
+				},
+				"blank_id": {
+					Type:        llm.TypeInteger,
+					Description: "ID del blank del catálogo, si el pedido es sobre un blank consultado con consultar_blank",
+				},
+				"blank_categoria": {
+					Type:        llm.TypeString,
+					Description: "Categoría del blank: 'llavero', 'medalla', etc.",
+				},
 			},
 			Required: []string{"resumen"},
 		},
 	}
 }
 
-func consultarBlankTool() *genai.FunctionDeclaration {
-	return &genai.FunctionDeclaration{
+func consultarBlankTool() llm.Tool {
+	return llm.Tool{
 		Name:        "consultar_blank",
 		Description: "Consulta precio y disponibilidad de un blank (producto preconfigurado) del catálogo de FabricaLaser, como llaveros o medallas. Usar cuando el cliente pregunte por estos productos.",
-		Parameters: &genai.Schema{
-			Type: genai.TypeObject,
-			Properties: map[string]*genai.Schema{
+		Parameters: &llm.Schema{
+			Type: llm.TypeObject,
+			Properties: map[string]*llm.Schema{
 				"categoria": {
-					Type:        genai.TypeString,
+					Type:        llm.TypeString,
 					Description: "Categoría del blank: 'llavero', 'medalla', etc.",
 				},
 				"cantidad": {
-					Type:        genai.TypeInteger,
+					Type:        llm.TypeInteger,
 					Description: "Cantidad de unidades que el cliente quiere",
 				},
 				"blank_id": {
-					Type:        genai.TypeInteger,
+					Type:        llm.TypeInteger,
 					Description: "ID específico del blank. Usar 0 (o no incluir) si no se conoce — el tool retorna todas las opciones de la categoría",
 				},
 			},
@@ -589,22 +565,219 @@ func consultarBlankTool() *genai.FunctionDeclaration {
 	}
 }
 
+func enviarCotizacionPDFTool() llm.Tool {
+	return llm.Tool{
+		Name:        "enviar_cotizacion_pdf",
+		Description: "Envía al cliente registrado su cotización formal en PDF como documento de WhatsApp. Usar cuando pida la cotización en PDF, la proforma o un documento formal.",
+		Parameters: &llm.Schema{
+			Type: llm.TypeObject,
+			Properties: map[string]*llm.Schema{
+				"cotizacion_id": {
+					Type:        llm.TypeInteger,
+					Description: "Número de la cotización. Usar 0 (o no incluir) para enviar la más reciente del cliente",
+				},
+			},
+		},
+	}
+}
+
+func mostrarOpcionesTool() llm.Tool {
+	return llm.Tool{
+		Name:        "mostrar_opciones",
+		Description: "Envía al cliente las opciones de material o de tecnología como botones o lista para que elija con un toque.",
+		Parameters: &llm.Schema{
+			Type: llm.TypeObject,
+			Properties: map[string]*llm.Schema{
+				"tipo": {
+					Type:        llm.TypeString,
+					Description: "Qué opciones mostrar",
+					Enum:        []string{"material", "tecnologia"},
+				},
+				"pregunta": {
+					Type:        llm.TypeString,
+					Description: "Texto corto que acompaña las opciones, ej: \"¿En qué material lo querés?\"",
+				},
+				"material_id": {
+					Type:        llm.TypeInteger,
+					Description: "Requerido para tipo = tecnologia: ID del material elegido",
+				},
+				"thickness": {
+					Type:        llm.TypeNumber,
+					Description: "Opcional para tipo = tecnologia: grosor en mm para filtrar",
+				},
+			},
+			Required: []string{"tipo"},
+		},
+	}
+}
+
+func cotizarArchivoTool() llm.Tool {
+	return llm.Tool{
+		Name:        "cotizar_archivo",
+		Description: "Recalcula el precio del último archivo SVG o DXF que mandó el cliente, con la geometría real del archivo (largo de corte y grabado). Usar cuando el cliente ya mandó el archivo y elige material, grosor, tecnología o cantidad.",
+		Parameters: &llm.Schema{
+			Type: llm.TypeObject,
+			Properties: map[string]*llm.Schema{
+				"cantidad": {
+					Type:        llm.TypeInteger,
+					Description: "Número de unidades a producir",
+				},
+				"technology_id": {
+					Type:        llm.TypeInteger,
+					Description: "ID de la tecnología láser (ver IDs al final del system prompt). Omitir para usar CO2",
+				},
+				"material_id": {
+					Type:        llm.TypeInteger,
+					Description: "ID del material (ver IDs al final del system prompt)",
+				},
+				"engrave_type_id": {
+					Type:        llm.TypeInteger,
+					Description: "ID del tipo de grabado: 1=Vectorial, 2=Rasterizado, 3=Fotograbado, 4=3D/Relieve. Default: 1",
+				},
+				"thickness": {
+					Type:        llm.TypeNumber,
+					Description: "Grosor del material en milímetros. Default: 3.0",
+				},
+				"material_included": {
+					Type:        llm.TypeBoolean,
+					Description: "true si FabricaLaser provee el material, false si el cliente lo trae",
+				},
+				"cut_technology_id": {
+					Type:        llm.TypeInteger,
+					Description: "ID de tecnología para el corte cuando es diferente a la de grabado (mismo uso que en calcular_cotizacion)",
+				},
+				"moneda": {
+					Type:        llm.TypeString,
+					Description: "Moneda del precio: \"CRC\" (default) o \"USD\"",
+				},
+			},
+			Required: []string{"cantidad", "material_id", "material_included"},
+		},
+	}
+}
+
+func vectorizarImagenTool() llm.Tool {
+	return llm.Tool{
+		Name:        "vectorizar_imagen",
+		Description: "Vectoriza la última imagen que mandó el cliente (logo o diseño) al tamaño indicado y calcula el precio con la geometría real del trazado. Usar cuando el cliente mandó la imagen de su diseño y ya dijo la medida.",
+		Parameters: &llm.Schema{
+			Type: llm.TypeObject,
+			Properties: map[string]*llm.Schema{
+				"ancho_cm": {
+					Type:        llm.TypeNumber,
+					Description: "Ancho del diseño en centímetros (no de la foto)",
+				},
+				"alto_cm": {
+					Type:        llm.TypeNumber,
+					Description: "Alto del diseño en centímetros. Opcional si se dio el ancho: se respeta la proporción",
+				},
+				"modo": {
+					Type:        llm.TypeString,
+					Description: "raster = grabado relleno del diseño (default, logos y textos); vector = solo el contorno de las formas",
+					Enum:        []string{tracer.ModeRaster, tracer.ModeVector},
+				},
+				"cantidad": {
+					Type:        llm.TypeInteger,
+					Description: "Número de unidades a producir",
+				},
+				"technology_id": {
+					Type:        llm.TypeInteger,
+					Description: "ID de la tecnología láser (ver IDs al final del system prompt). Omitir para usar CO2",
+				},
+				"material_id": {
+					Type:        llm.TypeInteger,
+					Description: "ID del material (ver IDs al final del system prompt)",
+				},
+				"thickness": {
+					Type:        llm.TypeNumber,
+					Description: "Grosor del material en milímetros. Default: 3.0",
+				},
+				"material_included": {
+					Type:        llm.TypeBoolean,
+					Description: "true si FabricaLaser provee el material, false si el cliente lo trae",
+				},
+				"moneda": {
+					Type:        llm.TypeString,
+					Description: "Moneda del precio: \"CRC\" (default) o \"USD\"",
+				},
+			},
+			Required: []string{"cantidad", "material_id", "material_included"},
+		},
+	}
+}
+
 // ─── Tool Execution ──────────────────────────────────────────────────────────
 
-func (g *geminiAdapter) executeFunction(ctx context.Context, clientPhone string, fc *genai.FunctionCall) (map[string]any, error) {
-	switch fc.Name {
-	case "calcular_cotizacion":
-		return g.execCalcCotizacion(ctx, fc.Args)
-	case "consultar_blank":
-		return g.execConsultarBlank(ctx, fc.Args)
-	case "escalar_a_humano":
-		return g.execEscalarAHumano(ctx, clientPhone, fc.Args)
-	default:
-		return nil, fmt.Errorf("tool desconocida: %s", fc.Name)
+// tools arma el registro de tools para una conversación: los handlers conocen al
+// cliente para escalar, mandar documentos y guardar borradores por su canal.
+func (g *geminiAdapter) tools(from channels.Identity) *llm.Registry {
+	withFrom := func(exec func(context.Context, channels.Identity, map[string]any) (map[string]any, error)) llm.ToolHandler {
+		return func(ctx context.Context, args map[string]any) (map[string]any, error) {
+			return exec(ctx, from, args)
+		}
+	}
+	r := llm.NewRegistry()
+	r.Register(calcularCotizacionTool(), withFrom(g.execCalcCotizacion))
+	r.Register(consultarBlankTool(), g.execConsultarBlank)
+	r.Register(blankconfig.Tool(), g.blanks.Handler())
+	r.Register(recomendarTecnologiaTool(), g.execRecomendarTecnologia)
+	r.Register(escalarAHumanoTool(), withFrom(g.execEscalarAHumano))
+	r.Register(enviarCotizacionPDFTool(), withFrom(g.execEnviarCotizacionPDF))
+	r.Register(mostrarOpcionesTool(), withFrom(g.execMostrarOpciones))
+	r.Register(cotizarArchivoTool(), withFrom(g.execCotizarArchivo))
+	r.Register(vectorizarImagenTool(), withFrom(g.execVectorizarImagen))
+	if g.knowledge != nil {
+		r.Register(knowledge.Tool(), g.knowledge.Handler(false))
+	}
+	return r
+}
+
+// leadStages: tools que mueven el lead en el embudo de ventas
+var leadStages = map[string]models.LeadStage{
+	"calcular_cotizacion": models.LeadQuoted,
+	"cotizar_archivo":     models.LeadQuoted,
+	"vectorizar_imagen":   models.LeadQuoted,
+	"escalar_a_humano":    models.LeadHot,
+}
+
+// trackLead avanza el embudo; se llama solo si la tool cumplió (hubo precio o se escaló)
+func (g *geminiAdapter) trackLead(ctx context.Context, from channels.Identity, tool string) {
+	stage, ok := leadStages[tool]
+	if !ok || g.leads == nil || !channels.BotInbox(ctx) {
+		return
+	}
+	g.leads.Advance(ctx, from, stage)
+}
+
+// toolSucceeded: la tool no reportó error ni un envío fallido
+func toolSucceeded(result map[string]any) bool {
+	if _, failed := result["error"]; failed {
+		return false
+	}
+	if enviado, ok := result["enviado"].(bool); ok && !enviado {
+		return false
+	}
+	return true
+}
+
+// recordToolCall deja la llamada en bot_events para la analítica de conversaciones
+func (g *geminiAdapter) recordToolCall(ctx context.Context, from channels.Identity, tool string, ok bool) {
+	if g.botEvents == nil {
+		return
+	}
+	ev := &models.BotEvent{
+		Kind:            models.BotEventToolCall,
+		Channel:         from.Channel,
+		ConversationKey: from.Key,
+		Name:            &tool,
+		OK:              ok,
+	}
+	if err := g.botEvents.WithContext(ctx).RecordEvent(ev); err != nil {
+		slog.Warn("whatsapp: error registrando llamada a tool", "tool", tool, "error", err)
 	}
 }
 
-func (g *geminiAdapter) execCalcCotizacion(ctx context.Context, args map[string]any) (map[string]any, error) {
+func (g *geminiAdapter) execCalcCotizacion(ctx context.Context, from channels.Identity, args map[string]any) (map[string]any, error) {
 	body, err := json.Marshal(args)
 	if err != nil {
 		return nil, fmt.Errorf("execCalcCotizacion: error serializando args: %w", err)
@@ -645,9 +818,34 @@ func (g *geminiAdapter) execCalcCotizacion(ctx context.Context, args map[string]
 		"precio_estimado", result["precio_estimado"],
 	)
 
+	if _, failed := result["error"]; !failed && resp.StatusCode == http.StatusOK {
+		g.saveDraft(ctx, from, args)
+	}
 	return result, nil
 }
 
+// saveDraft deja los datos del trabajo en el borrador de cotización del lead.
+// Nunca corta el flujo del agente: los errores solo se registran.
+func (g *geminiAdapter) saveDraft(ctx context.Context, from channels.Identity, args map[string]any) *models.Quote {
+	if g.drafts == nil {
+		return nil
+	}
+	params, err := quotedraft.ParamsFromArgs(args)
+	if err != nil {
+		slog.Warn("whatsapp: argumentos inválidos para el borrador", "cliente", from.Key, "error", err)
+		return nil
+	}
+	draft, err := g.drafts.FromConversation(ctx, from, params)
+	switch {
+	case errors.Is(err, quotedraft.ErrIncomplete), errors.Is(err, quotedraft.ErrIncompatible):
+		return nil
+	case err != nil:
+		slog.Error("whatsapp: error guardando borrador de cotización", "cliente", from.Key, "error", err)
+		return nil
+	}
+	return draft
+}
+
 func (g *geminiAdapter) execConsultarBlank(ctx context.Context, args map[string]any) (map[string]any, error) {
 	body, err := json.Marshal(args)
 	if err != nil {
@@ -693,95 +891,305 @@ func (g *geminiAdapter) execConsultarBlank(ctx context.Context, args map[string]
 	return result, nil
 }
 
-func (g *geminiAdapter) execEscalarAHumano(ctx context.Context, clientPhone string, args map[string]any) (map[string]any, error) {
+// execRecomendarTecnologia consulta el recomendador con la matriz tecnología×material
+func (g *geminiAdapter) execRecomendarTecnologia(ctx context.Context, args map[string]any) (map[string]any, error) {
+	operacion, _ := args["operacion"].(string)
+	op, err := pricing.ParseOperation(operacion)
+	if err != nil {
+		return map[string]any{"error": err.Error()}, nil
+	}
+	materialID, _ := args["material_id"].(float64)
+	thickness, _ := args["thickness"].(float64)
+	color, _ := args["acabado_color"].(bool)
+
+	rec, err := g.recommender.Recommend(pricing.RecommendInput{
+		MaterialID:  uint(materialID),
+		Thickness:   thickness,
+		Operation:   op,
+		ColorFinish: color,
+	})
+	if errors.Is(err, pricing.ErrRecommendMaterial) {
+		return map[string]any{"error": err.Error()}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("execRecomendarTecnologia: %w", err)
+	}
+
+	result := map[string]any{"recomendacion": rec}
+	if len(rec.Plans) > 0 {
+		result["cotizar_con"] = rec.Plans[0].QuoteArgs()
+	}
+	return result, nil
+}
+
+func (g *geminiAdapter) execEscalarAHumano(ctx context.Context, from channels.Identity, args map[string]any) (map[string]any, error) {
 	resumen, _ := args["resumen"].(string)
 
+	// Los datos del trabajo quedan como borrador de cotización: el asesor no relee el chat
+	if draft := g.saveDraft(ctx, from, args); draft != nil {
+		resumen += fmt.Sprintf("\n\nBorrador de cotización #%d listo para revisar en el panel.", draft.ID)
+	}
+
+	// La conversación pasa a la bandeja del panel: el bot deja de responder hasta
+	// que un admin la cierre o venza el plazo de inactividad
+	opened := false
+	if g.handoffs != nil && channels.BotInbox(ctx) {
+		if h, err := g.handoffs.Escalate(ctx, from, resumen); err != nil {
+			slog.Error("escalar_a_humano: error abriendo atención", "canal", from.Channel, "error", err)
+		} else {
+			opened = true
+			resumen += fmt.Sprintf("\n\nAtención #%d — respondé desde el panel (Bandeja de atención).", h.ID)
+		}
+	}
+
 	var msg strings.Builder
 	msg.WriteString("FabricaLaser — Cliente listo para coordinar\n\n")
 	msg.WriteString(resumen)
-
-	// Detectar canal por prefijo del identificador y enviar por el canal correspondiente
-	if strings.HasPrefix(clientPhone, "tg:") {
-		msg.WriteString(fmt.Sprintf("\n\nCanal: Telegram\nChat ID: %s", strings.TrimPrefix(clientPhone, "tg:")))
-
-		// Enviar al asesor por Telegram
-		asesorChatID := g.contextProvider.GetAsesorTelegramChatID()
-		if asesorChatID != 0 {
-			if err := g.tgSender.sendText(ctx, asesorChatID, msg.String()); err != nil {
-				slog.Error("escalar_a_humano: error enviando al asesor por Telegram",
-					"asesor_chat_id", asesorChatID,
-					"error", err,
-				)
-				return map[string]any{"enviado": false, "error": err.Error()}, nil
-			}
-			slog.Info("escalar_a_humano: mensaje enviado al asesor por Telegram",
-				"asesor_chat_id", asesorChatID,
-				"cliente", clientPhone,
-			)
-			return map[string]any{"enviado": true}, nil
-		}
-		// Fallback a WhatsApp si no hay TelegramAsesorChatID configurado
-		slog.Warn("escalar_a_humano: TelegramAsesorChatID no configurado, fallback a WhatsApp")
-	} else if clientPhone != "" {
-		msg.WriteString(fmt.Sprintf("\n\nNúmero del cliente: %s", clientPhone))
+	if from.ID != "" {
+		msg.WriteString("\n\n" + channels.Describe(g.registry.Label(from.Channel), from))
 	}
 
-	// Enviar al asesor por WhatsApp (canal por defecto o fallback)
-	asesorPhone := g.contextProvider.GetAsesorPhone()
-	if err := g.sender.SendText(ctx, asesorPhone, msg.String()); err != nil {
-		slog.Error("escalar_a_humano: error enviando al asesor por WhatsApp",
-			"asesor", asesorPhone,
+	// Se avisa al asesor por el canal del cliente; el registry hace fallback al canal por defecto
+	if err := g.registry.NotifyAsesor(ctx, from.Channel, msg.String()); err != nil {
+		slog.Error("escalar_a_humano: error enviando al asesor",
+			"canal", from.Channel,
 			"error", err,
 		)
-		return map[string]any{"enviado": false, "error": err.Error()}, nil
+		if !opened {
+			return map[string]any{"enviado": false, "error": err.Error()}, nil
+		}
 	}
 
-	slog.Info("escalar_a_humano: mensaje enviado al asesor por WhatsApp",
-		"asesor", asesorPhone,
-		"cliente", clientPhone,
+	slog.Info("escalar_a_humano: conversación escalada",
+		"canal", from.Channel,
+		"cliente", from.Key,
+		"bandeja", opened,
 	)
 	return map[string]any{"enviado": true}, nil
 }
 
-// ─── Retry helper ────────────────────────────────────────────────────────────
+// execEnviarCotizacionPDF genera el PDF de una cotización del cliente y lo envía
+// como documento. Solo para clientes registrados cuyo teléfono coincide con el chat.
+func (g *geminiAdapter) execEnviarCotizacionPDF(ctx context.Context, from channels.Identity, args map[string]any) (map[string]any, error) {
+	ch, ok := g.registry.Get(from.Channel)
+	if !ok || from.Phone == "" {
+		// Canales sin teléfono (Telegram) no permiten ligar al cliente con su cuenta de forma segura
+		return map[string]any{"enviado": false, "motivo": "cliente_no_registrado"}, nil
+	}
 
-// sendWithRetry envía un mensaje al chat con reintentos exponenciales ante errores 429.
-// Vertex AI puede retornar ResourceExhausted (429) en el segundo turno del tool loop
-// cuando se envía el FunctionResponse. Hasta 3 reintentos: 2s, 4s, 8s.
-func sendWithRetry(ctx context.Context, chat *genai.ChatSession, part genai.Part) (*genai.GenerateContentResponse, error) {
-	delays := []time.Duration{2 * time.Second, 4 * time.Second, 8 * time.Second}
+	user, err := repository.NewUserRepository().FindByTelefono(stripCRPrefix(from.Phone))
+	if err != nil {
+		return map[string]any{"enviado": false, "motivo": "cliente_no_registrado"}, nil
+	}
 
-	var lastErr error
-	for attempt := 0; attempt <= len(delays); attempt++ {
-		resp, err := chat.SendMessage(ctx, part)
-		if err == nil {
-			return resp, nil
+	quoteRepo := repository.NewQuoteRepository()
+	quoteID := uint(0)
+	if v, ok := args["cotizacion_id"].(float64); ok && v > 0 {
+		quoteID = uint(v)
+	} else {
+		latest, err := quoteRepo.FindByUserID(user.ID, 1, 0)
+		if err != nil {
+			return nil, fmt.Errorf("execEnviarCotizacionPDF: error buscando cotizaciones: %w", err)
 		}
-
-		st, ok := status.FromError(err)
-		if !ok || st.Code() != codes.ResourceExhausted {
-			// Error no recuperable — retornar de inmediato
-			return nil, err
+		if len(latest) == 0 {
+			return map[string]any{"enviado": false, "motivo": "sin_cotizaciones"}, nil
 		}
+		quoteID = latest[0].ID
+	}
 
-		if attempt == len(delays) {
-			lastErr = err
-			break
-		}
+	quote, err := quoteRepo.FindByIDWithRelations(quoteID)
+	if err != nil || quote.UserID != user.ID {
+		// No revelar si la cotización existe pero es de otro cliente
+		return map[string]any{"enviado": false, "motivo": "cotizacion_no_encontrada"}, nil
+	}
 
-		slog.Warn("geminiAdapter: Vertex AI 429 — reintentando",
-			"attempt", attempt+1,
-			"wait", delays[attempt],
-		)
+	config, err := pricing.NewConfigLoader(database.Get()).Load()
+	if err != nil {
+		return nil, fmt.Errorf("execEnviarCotizacionPDF: error cargando configuración: %w", err)
+	}
+	pdf, err := quotepdf.Generate(quote, config)
+	if err != nil {
+		return nil, fmt.Errorf("execEnviarCotizacionPDF: error generando PDF: %w", err)
+	}
 
-		select {
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		case <-time.After(delays[attempt]):
+	filename := quotepdf.Filename(quote)
+	caption := fmt.Sprintf("Cotización #%d — FabricaLaser", quote.ID)
+	if err := ch.SendDocument(ctx, from, pdf, "application/pdf", filename, caption); err != nil {
+		return map[string]any{"enviado": false, "error": err.Error()}, nil
+	}
+
+	slog.Info("whatsapp: enviar_cotizacion_pdf ejecutado",
+		"canal", from.Channel,
+		"cliente", from.Key,
+		"quote_id", quote.ID,
+		"bytes", len(pdf),
+	)
+	return map[string]any{
+		"enviado":       true,
+		"cotizacion_id": quote.ID,
+		"moneda":        quote.Currency,
+		"total":         quote.ToDisplayCurrency(quote.PriceTotalWithTax),
+		"valida_hasta":  quote.ValidUntil.Format("02/01/2006"),
+		"vencida":       quote.IsExpired(),
+	}, nil
+}
+
+// execMostrarOpciones arma las opciones de material o tecnología (las mismas que
+// GET /config/compatible-options) y las envía como botones por el canal del cliente.
+// El ID de cada opción ("material_id=3") vuelve en el mensaje cuando el cliente la toca.
+func (g *geminiAdapter) execMostrarOpciones(ctx context.Context, from channels.Identity, args map[string]any) (map[string]any, error) {
+	ch, ok := g.registry.Get(from.Channel)
+	if !ok {
+		return map[string]any{"enviado": false, "motivo": "canal_no_disponible"}, nil
+	}
+
+	tipo, _ := args["tipo"].(string)
+	pregunta, _ := args["pregunta"].(string)
+	var buttons []channels.Button
+
+	switch tipo {
+	case "material":
+		materials, err := repository.NewMaterialRepository().FindAll()
+		if err != nil {
+			return nil, fmt.Errorf("execMostrarOpciones: error cargando materiales: %w", err)
 		}
+		for _, m := range materials {
+			desc := m.Category
+			if m.IsCuttable {
+				desc += " · se puede cortar"
+			}
+			buttons = append(buttons, channels.Button{ID: fmt.Sprintf("material_id=%d", m.ID), Title: m.Name, Description: desc})
+		}
+		if pregunta == "" {
+			pregunta = "¿En qué material lo querés?"
+		}
+	case "tecnologia":
+		materialID, _ := args["material_id"].(float64)
+		if materialID <= 0 {
+			return map[string]any{"enviado": false, "motivo": "falta_material_id"}, nil
+		}
+		thickness, _ := args["thickness"].(float64)
+		techs, err := repository.NewTechMaterialSpeedRepository().FindCompatibleOptions(uint(materialID), thickness)
+		if err != nil {
+			return nil, fmt.Errorf("execMostrarOpciones: error cargando tecnologías: %w", err)
+		}
+		for _, t := range techs {
+			buttons = append(buttons, channels.Button{ID: fmt.Sprintf("technology_id=%d", t.ID), Title: t.Name, Description: describeCompatible(t)})
+		}
+		if pregunta == "" {
+			pregunta = "¿Con qué tecnología lo trabajamos?"
+		}
+	default:
+		return nil, fmt.Errorf("execMostrarOpciones: tipo inválido %q", tipo)
+	}
+
+	if len(buttons) == 0 {
+		return map[string]any{"enviado": false, "motivo": "sin_opciones"}, nil
+	}
+	if err := ch.SendButtons(ctx, from, pregunta, buttons); err != nil {
+		return map[string]any{"enviado": false, "error": err.Error()}, nil
+	}
+
+	titles := make([]string, len(buttons))
+	for i, b := range buttons {
+		titles[i] = b.Title
+	}
+	slog.Info("whatsapp: mostrar_opciones ejecutado", "canal", from.Channel, "tipo", tipo, "opciones", len(buttons))
+	return map[string]any{"enviado": true, "opciones": titles}, nil
+}
+
+// execCotizarArchivo recotiza el último SVG/DXF de la conversación con pricing.Calculator
+func (g *geminiAdapter) execCotizarArchivo(ctx context.Context, from channels.Identity, args map[string]any) (map[string]any, error) {
+	body, err := json.Marshal(args)
+	if err != nil {
+		return nil, fmt.Errorf("execCotizarArchivo: error serializando args: %w", err)
+	}
+	var opts designfiles.Options
+	if err := json.Unmarshal(body, &opts); err != nil {
+		return nil, fmt.Errorf("execCotizarArchivo: argumentos inválidos: %w", err)
+	}
+
+	estimate, err := g.files.Quote(ctx, from, opts)
+	switch {
+	case errors.Is(err, designfiles.ErrNoFile):
+		return map[string]any{"error": "sin_archivo"}, nil
+	case errors.Is(err, designfiles.ErrIncompatible):
+		return map[string]any{"error": err.Error()}, nil
+	case err != nil:
+		return nil, fmt.Errorf("execCotizarArchivo: %w", err)
+	}
+
+	slog.Info("whatsapp: cotizar_archivo ejecutado",
+		"canal", from.Channel,
+		"cliente", from.Key,
+		"archivo", estimate.Archivo,
+		"subtotal", estimate.Subtotal,
+	)
+	return estimateResult(estimate)
+}
 
-		lastErr = err
+// execVectorizarImagen traza la última imagen de la conversación y la cotiza
+func (g *geminiAdapter) execVectorizarImagen(ctx context.Context, from channels.Identity, args map[string]any) (map[string]any, error) {
+	body, err := json.Marshal(args)
+	if err != nil {
+		return nil, fmt.Errorf("execVectorizarImagen: error serializando args: %w", err)
+	}
+	var opts designfiles.Options
+	if err := json.Unmarshal(body, &opts); err != nil {
+		return nil, fmt.Errorf("execVectorizarImagen: argumentos inválidos: %w", err)
+	}
+	anchoCM, _ := args["ancho_cm"].(float64)
+	altoCM, _ := args["alto_cm"].(float64)
+	modo, _ := args["modo"].(string)
+
+	estimate, err := g.files.TraceImage(ctx, from, tracer.Options{WidthMM: anchoCM * 10, HeightMM: altoCM * 10, Mode: modo}, opts)
+	switch {
+	case errors.Is(err, designfiles.ErrNoImage):
+		return map[string]any{"error": "sin_imagen"}, nil
+	case errors.Is(err, tracer.ErrInvalidSize), errors.Is(err, tracer.ErrNoShapes),
+		errors.Is(err, tracer.ErrInvalidImage), errors.Is(err, designfiles.ErrIncompatible):
+		return map[string]any{"error": err.Error()}, nil
+	case err != nil:
+		return nil, fmt.Errorf("execVectorizarImagen: %w", err)
 	}
 
-	return nil, fmt.Errorf("sendWithRetry: agotados los reintentos tras 429: %w", lastErr)
+	slog.Info("whatsapp: vectorizar_imagen ejecutado",
+		"canal", from.Channel,
+		"cliente", from.Key,
+		"ancho_mm", estimate.AnchoMM,
+		"alto_mm", estimate.AltoMM,
+		"subtotal", estimate.Subtotal,
+	)
+	return estimateResult(estimate)
+}
+
+// estimateResult pasa el Estimate al formato map que espera el function calling
+func estimateResult(estimate *designfiles.Estimate) (map[string]any, error) {
+	var result map[string]any
+	raw, _ := json.Marshal(estimate)
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return nil, fmt.Errorf("error serializando resultado: %w", err)
+	}
+	return result, nil
+}
+
+// describeCompatible resume capacidades y grosores: "Corta y graba · 3, 5, 6 mm"
+func describeCompatible(t repository.CompatibleTechnology) string {
+	var desc string
+	switch {
+	case t.CanCut && t.CanEngrave:
+		desc = "Corta y graba"
+	case t.CanCut:
+		desc = "Solo corte"
+	default:
+		desc = "Solo grabado"
+	}
+	if len(t.Thicknesses) > 0 {
+		parts := make([]string, len(t.Thicknesses))
+		for i, th := range t.Thicknesses {
+			parts[i] = strconv.FormatFloat(th, 'f', -1, 64)
+		}
+		desc += " · " + strings.Join(parts, ", ") + " mm"
+	}
+	return desc
 }
@@ -0,0 +1,99 @@
+package whatsapp
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/channels"
+	"github.com/alonsoalpizar/fabricalaser/internal/llm"
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+)
+
+// testAdapter arma el agente sobre un guion, con el contexto dinámico ya en
+// cache y sin canales registrados (no toca DB ni red)
+func testAdapter(script *llm.Script) *geminiAdapter {
+	provider := &WAContextProvider{cache: map[uint]*tenantContext{
+		models.DefaultTenantID: {content: "\nCTX-DINAMICO", fetchedAt: time.Now()},
+	}}
+	return &geminiAdapter{
+		llm:             script,
+		contextProvider: provider,
+		registry:        channels.NewRegistry(),
+	}
+}
+
+func TestCallWithToolsLoop(t *testing.T) {
+	script := llm.NewScript(
+		llm.CallTool("mostrar_opciones", map[string]any{"tipo": "material"}),
+		llm.CallTool("no_existe", nil),
+		llm.Reply("¿En qué material lo querés?"),
+	)
+	g := testAdapter(script)
+	from := channels.Identity{Channel: channels.WhatsApp, Key: "50688887777"}
+	history := []ChatTurn{{Role: "user", Content: "hola"}, {Role: "model", Content: "¿Con quién tengo el gusto?"}}
+
+	reply, err := g.CallWithTools(context.Background(), from, history, "Ana, quiero un rótulo", "\nCLIENTE")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply != "¿En qué material lo querés?" {
+		t.Errorf("reply = %q", reply)
+	}
+
+	reqs := script.Requests()
+	if len(reqs) != 3 || script.Pending() != 0 {
+		t.Fatalf("requests = %d, pending = %d", len(reqs), script.Pending())
+	}
+	cfg := reqs[0].Config
+	if len(cfg.Tools) != 9 || !strings.HasSuffix(cfg.System, "\nCTX-DINAMICO\nCLIENTE") {
+		t.Errorf("config: %d tools, system termina en %q", len(cfg.Tools), cfg.System[len(cfg.System)-30:])
+	}
+	if len(reqs[0].Contents) != 3 || reqs[0].Contents[1].Role != llm.RoleModel {
+		t.Errorf("contents = %v", reqs[0].Contents)
+	}
+
+	// Sin canal disponible la tool reporta que no envió; la tool desconocida vuelve como error
+	if r := reqs[1].Last().Parts[0].Response; r == nil || r.Result["enviado"] != false {
+		t.Errorf("resultado mostrar_opciones = %v", reqs[1].Last())
+	}
+	if r := reqs[2].Last().Parts[0].Response; r == nil || r.Result["error"] != "tool desconocida: no_existe" {
+		t.Errorf("resultado no_existe = %v", reqs[2].Last())
+	}
+}
+
+func TestCallWithToolsFallbacks(t *testing.T) {
+	from := channels.Identity{Channel: channels.WhatsApp, Key: "50688887777"}
+
+	// Respuesta vacía: mensaje de respaldo
+	g := testAdapter(llm.NewScript(llm.Step{}))
+	reply, err := g.CallWithTools(context.Background(), from, nil, "hola", "")
+	if err != nil || !strings.HasPrefix(reply, "Hubo un problema") {
+		t.Errorf("reply = %q, err = %v", reply, err)
+	}
+
+	// Falla el modelo: el error sube al motor
+	g = testAdapter(llm.NewScript(llm.Fail(errors.New("caído"))))
+	if _, err := g.CallWithTools(context.Background(), from, nil, "hola", ""); err == nil {
+		t.Error("esperaba error")
+	}
+}
+
+func TestCallWithImage(t *testing.T) {
+	script := llm.NewScript(llm.Reply("Veo acrílico."))
+	g := testAdapter(script)
+
+	reply, err := g.CallWithImage(context.Background(), channels.Identity{}, nil, []byte{0xff, 0xd8}, "image/jpeg", "", "")
+	if err != nil || reply != "Veo acrílico." {
+		t.Fatalf("reply = %q, err = %v", reply, err)
+	}
+	req := script.Requests()[0]
+	if len(req.Config.Tools) != 0 || !strings.Contains(req.Config.System, systemPromptImagen) {
+		t.Errorf("config = %+v", req.Config)
+	}
+	if got := req.Last().String(); got != "user: blob:image/jpeg | El cliente mandó esta imagen." {
+		t.Errorf("turno = %s", got)
+	}
+}
@@ -9,38 +9,43 @@ import (
 	"io"
 	"log/slog"
 	"net/http"
-	"os"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/channels"
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+	"github.com/alonsoalpizar/fabricalaser/internal/tenant"
 )
 
 // Handler agrupa las dependencias necesarias para el webhook de WhatsApp.
-// Se inicializa una vez y se reutiliza en cada request.
+// Se inicializa una vez y se reutiliza en cada request. Todos los tenants
+// comparten la URL del webhook: cada cambio del payload se atribuye al tenant
+// dueño del número que lo recibió (metadata.phone_number_id).
 type Handler struct {
-	appSecret   string // WHATSAPP_APP_SECRET — para verificar firma X-Hub-Signature-256
-	verifyToken string // WHATSAPP_VERIFY_TOKEN — para el handshake inicial con Meta
-	processor   *MessageProcessor
+	channel *Channel
+	engine  *channels.Engine
 }
 
-// NewHandler construye el Handler leyendo configuración exclusivamente de variables de entorno.
-// rateLimiter es opcional (puede ser nil para deshabilitar el rate limiting).
-func NewHandler(redisClient RedisClient, pgClient PGClient, geminiCaller GeminiCaller, rateLimiter *RateLimiter, contextProvider *WAContextProvider) *Handler {
+// NewHandler construye el Handler; los mensajes normalizados por channel se
+// procesan en el motor de conversación común con el tenant del número.
+func NewHandler(channel *Channel, engine *channels.Engine) *Handler {
 	return &Handler{
-		appSecret:   os.Getenv("WHATSAPP_APP_SECRET"),
-		verifyToken: os.Getenv("WHATSAPP_VERIFY_TOKEN"),
-		processor:   NewMessageProcessor(redisClient, pgClient, geminiCaller, rateLimiter, contextProvider),
+		channel: channel,
+		engine:  engine,
 	}
 }
 
 // VerifyWebhook maneja el GET que Meta envía al configurar el webhook.
-// Meta espera recibir el hub.challenge de vuelta si el verify_token coincide.
+// Meta espera recibir el hub.challenge de vuelta si el verify_token coincide
+// con el de algún tenant activo.
 func (h *Handler) VerifyWebhook(w http.ResponseWriter, r *http.Request) {
 	mode      := r.URL.Query().Get("hub.mode")
 	token     := r.URL.Query().Get("hub.verify_token")
 	challenge := r.URL.Query().Get("hub.challenge")
 
-	if mode != "subscribe" || token != h.verifyToken {
+	tokenMatch := verifyTokenMatches(token)
+	if mode != "subscribe" || !tokenMatch {
 		slog.Warn("whatsapp: verificación fallida",
 			"mode", mode,
-			"token_match", token == h.verifyToken,
+			"token_match", tokenMatch,
 		)
 		http.Error(w, "Forbidden", http.StatusForbidden)
 		return
@@ -63,14 +68,7 @@ func (h *Handler) HandleMessage(w http.ResponseWriter, r *http.Request) {
 	}
 	defer r.Body.Close()
 
-	// 2. Verificar firma HMAC-SHA256 — seguridad obligatoria
-	if !h.verifySignature(r.Header.Get("X-Hub-Signature-256"), body) {
-		slog.Warn("whatsapp: firma inválida — posible request no autorizado")
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
-	}
-
-	// 3. Parsear payload de Meta
+	// 2. Parsear payload de Meta: el número que recibió cada cambio dice de qué tenant es
 	var payload WebhookPayload
 	if err := json.Unmarshal(body, &payload); err != nil {
 		slog.Error("whatsapp: error parseando payload", "error", err)
@@ -78,22 +76,88 @@ func (h *Handler) HandleMessage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// 3. Verificar firma HMAC-SHA256 con el app secret de cada tenant — seguridad obligatoria.
+	// Solo se procesan los cambios de los tenants cuya firma coincide.
+	signature := r.Header.Get("X-Hub-Signature-256")
+	accepted := make(map[uint]*WebhookPayload)
+	for id, p := range splitByTenant(&payload) {
+		if verifySignature(tenant.Channels(id).WhatsAppAppSecret, signature, body) {
+			accepted[id] = p
+		}
+	}
+	if len(accepted) == 0 {
+		slog.Warn("whatsapp: firma inválida — posible request no autorizado")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
 	// 4. ACK inmediato a Meta — Meta requiere respuesta en menos de 5 segundos
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte(`{"status":"ok"}`))
 
 	// 5. Procesar mensaje de forma asíncrona — usar Background para que el contexto
 	// no se cancele cuando el handler HTTP retorna el 200 a Meta
-	go h.processor.Process(context.Background(), &payload)
+	for id, p := range accepted {
+		go h.process(tenant.WithTenant(context.Background(), tenant.Lookup(id)), p)
+	}
+}
+
+// splitByTenant agrupa los cambios del payload por el tenant dueño del número
+// que los recibió. Los cambios de talleres inactivos se descartan; un payload
+// sin cambios queda en el tenant por defecto para que igual se verifique la firma.
+func splitByTenant(payload *WebhookPayload) map[uint]*WebhookPayload {
+	out := make(map[uint]*WebhookPayload)
+	changes := 0
+	for _, entry := range payload.Entry {
+		for _, change := range entry.Changes {
+			changes++
+			t := tenant.ForWhatsAppNumber(change.Value.Metadata.PhoneNumberID)
+			if !t.IsActive {
+				slog.Warn("whatsapp: mensaje para un taller inactivo ignorado", "tenant_id", t.ID, "phone_number_id", change.Value.Metadata.PhoneNumberID)
+				continue
+			}
+			p, ok := out[t.ID]
+			if !ok {
+				p = &WebhookPayload{Object: payload.Object}
+				out[t.ID] = p
+			}
+			p.Entry = append(p.Entry, Entry{ID: entry.ID, Changes: []Change{change}})
+		}
+	}
+	if changes == 0 {
+		out[models.DefaultTenantID] = payload
+	}
+	return out
+}
+
+// verifyTokenMatches indica si token es el verify_token de WhatsApp de algún tenant activo
+func verifyTokenMatches(token string) bool {
+	if token == "" {
+		return false
+	}
+	for _, t := range tenant.Active() {
+		if tenant.Channels(t.ID).WhatsAppVerifyToken == token {
+			return true
+		}
+	}
+	return false
+}
+
+// process entrega cada mensaje del payload al motor, en orden
+func (h *Handler) process(ctx context.Context, payload *WebhookPayload) {
+	for _, msg := range h.channel.Normalize(payload) {
+		h.engine.Handle(ctx, h.channel, msg)
+	}
 }
 
-// verifySignature valida la firma HMAC-SHA256 que Meta adjunta en cada request.
-func (h *Handler) verifySignature(signature string, body []byte) bool {
-	if len(signature) < 7 || signature[:7] != "sha256=" {
+// verifySignature valida la firma HMAC-SHA256 que Meta adjunta en cada request
+// contra el app secret del tenant. Un tenant sin app secret nunca valida.
+func verifySignature(appSecret, signature string, body []byte) bool {
+	if appSecret == "" || len(signature) < 7 || signature[:7] != "sha256=" {
 		return false
 	}
 
-	mac := hmac.New(sha256.New, []byte(h.appSecret))
+	mac := hmac.New(sha256.New, []byte(appSecret))
 	mac.Write(body)
 	expected := "sha256=" + hex.EncodeToString(mac.Sum(nil))
 
@@ -0,0 +1,44 @@
+package whatsapp
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+)
+
+func TestVerifySignature(t *testing.T) {
+	body := []byte(`{"object":"whatsapp_business_account"}`)
+	mac := hmac.New(sha256.New, []byte("secreto"))
+	mac.Write(body)
+	signature := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	if !verifySignature("secreto", signature, body) {
+		t.Error("la firma con el app secret del tenant debería validar")
+	}
+	if verifySignature("otro", signature, body) {
+		t.Error("la firma no debería validar con el app secret de otro tenant")
+	}
+	if verifySignature("", "sha256="+hex.EncodeToString(hmac.New(sha256.New, nil).Sum(nil)), nil) {
+		t.Error("un tenant sin app secret nunca debería validar")
+	}
+}
+
+func TestSplitByTenantDefaultsToDefaultTenant(t *testing.T) {
+	// Sin resolver, todo número es del tenant por defecto
+	payload := &WebhookPayload{Object: "whatsapp_business_account", Entry: []Entry{{ID: "waba", Changes: []Change{
+		{Value: ChangeValue{Metadata: Metadata{PhoneNumberID: "111"}}},
+		{Value: ChangeValue{Metadata: Metadata{PhoneNumberID: "222"}}},
+	}}}}
+	got := splitByTenant(payload)
+	if len(got) != 1 || len(got[models.DefaultTenantID].Entry) != 2 {
+		t.Errorf("splitByTenant = %+v", got)
+	}
+
+	empty := &WebhookPayload{Object: "whatsapp_business_account"}
+	if got := splitByTenant(empty); got[models.DefaultTenantID] != empty {
+		t.Errorf("un payload sin cambios debería quedar en el tenant por defecto: %+v", got)
+	}
+}
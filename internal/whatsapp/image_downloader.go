@@ -6,40 +6,39 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"os"
 	"time"
 )
 
 // ImageDownloader descarga imágenes desde la Media API de Meta.
 type ImageDownloader struct {
-	accessToken string
-	apiVersion  string
-	httpClient  *http.Client
+	apiVersion string
+	httpClient *http.Client
 }
 
-// NewImageDownloader construye el downloader leyendo credenciales de variables de entorno.
+// NewImageDownloader construye el downloader; cada descarga usa el token del
+// tenant de su contexto, el dueño del número que recibió la imagen.
 func NewImageDownloader() *ImageDownloader {
-	version := os.Getenv("WHATSAPP_API_VERSION")
-	if version == "" {
-		version = "v22.0"
-	}
 	return &ImageDownloader{
-		accessToken: os.Getenv("WHATSAPP_ACCESS_TOKEN"),
-		apiVersion:  version,
-		httpClient:  &http.Client{Timeout: 30 * time.Second},
+		apiVersion: apiVersion(),
+		httpClient: &http.Client{Timeout: 30 * time.Second},
 	}
 }
 
 // DownloadImage obtiene la URL del media desde la Graph API y descarga los bytes de la imagen.
 // Retorna (imageBytes, mimeType, error).
 func (d *ImageDownloader) DownloadImage(ctx context.Context, mediaID string) ([]byte, string, error) {
+	creds, err := credentials(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+
 	// Paso 1: obtener metadata del media (URL + mime_type)
 	metaURL := fmt.Sprintf("https://graph.facebook.com/%s/%s", d.apiVersion, mediaID)
 	metaReq, err := http.NewRequestWithContext(ctx, http.MethodGet, metaURL, nil)
 	if err != nil {
 		return nil, "", fmt.Errorf("imageDownloader: error creando request metadata: %w", err)
 	}
-	metaReq.Header.Set("Authorization", "Bearer "+d.accessToken)
+	metaReq.Header.Set("Authorization", "Bearer "+creds.WhatsAppAccessToken)
 
 	metaResp, err := d.httpClient.Do(metaReq)
 	if err != nil {
@@ -68,7 +67,7 @@ func (d *ImageDownloader) DownloadImage(ctx context.Context, mediaID string) ([]
 	if err != nil {
 		return nil, "", fmt.Errorf("imageDownloader: error creando request de imagen: %w", err)
 	}
-	imgReq.Header.Set("Authorization", "Bearer "+d.accessToken)
+	imgReq.Header.Set("Authorization", "Bearer "+creds.WhatsAppAccessToken)
 
 	imgResp, err := d.httpClient.Do(imgReq)
 	if err != nil {
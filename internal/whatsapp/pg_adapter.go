@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/alonsoalpizar/fabricalaser/internal/tenant"
 	"gorm.io/gorm"
 )
 
@@ -18,8 +19,8 @@ func NewPGAdapter(db *gorm.DB) PGClient {
 
 func (p *pgAdapter) SaveTurn(ctx context.Context, turn ConversationTurn) error {
 	result := p.db.WithContext(ctx).Exec(
-		"INSERT INTO whatsapp_conversations (phone, role, content, created_at) VALUES (?, ?, ?, ?)",
-		turn.Phone, turn.Role, turn.Content, turn.CreatedAt,
+		"INSERT INTO whatsapp_conversations (tenant_id, phone, role, content, created_at) VALUES (?, ?, ?, ?, ?)",
+		tenant.ID(ctx), turn.Phone, turn.Role, turn.Content, turn.CreatedAt,
 	)
 	if result.Error != nil {
 		return fmt.Errorf("pgAdapter.SaveTurn: %w", result.Error)
@@ -42,8 +43,8 @@ func (p *pgAdapter) FindUserByPhone(ctx context.Context, phone string) (*UserPro
 
 	// Raw().Scan() garantiza RowsAffected correcto con el driver pgx.
 	result := p.db.WithContext(ctx).Raw(
-		"SELECT nombre, apellido, cedula_type, email, provincia, canton, direccion FROM users WHERE telefono = ? AND activo = true AND password_hash IS NOT NULL",
-		phone,
+		"SELECT nombre, apellido, cedula_type, email, provincia, canton, direccion FROM users WHERE tenant_id = ? AND telefono = ? AND activo = true AND password_hash IS NOT NULL",
+		tenant.ID(ctx), phone,
 	).Scan(&row)
 
 	if result.Error != nil {
@@ -8,6 +8,9 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/alonsoalpizar/fabricalaser/internal/channels"
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+	"github.com/alonsoalpizar/fabricalaser/internal/repository"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -26,6 +29,7 @@ type RateLimiter struct {
 	limit     int // WHATSAPP_DAILY_LIMIT (default 230)
 	alertAt   int // WHATSAPP_ALERT_THRESHOLD (default 200)
 	alertMail string
+	events    *repository.AnalyticsRepository // Rechazos para la analítica de conversaciones
 }
 
 // NewRateLimiter construye el limitador leyendo configuración de env vars.
@@ -34,13 +38,14 @@ func NewRateLimiter(rc *redis.Client) *RateLimiter {
 	alertAt := envInt("WHATSAPP_ALERT_THRESHOLD", 200)
 	alertMail := os.Getenv("WHATSAPP_ALERT_EMAIL")
 	if alertMail == "" {
-		alertMail = digestTo // reutiliza la constante de summary_mailer.go
+		alertMail = digestRecipient() // mismo destinatario que el resumen (summary_mailer.go)
 	}
 	return &RateLimiter{
 		rc:        rc,
 		limit:     limit,
 		alertAt:   alertAt,
 		alertMail: alertMail,
+		events:    repository.NewAnalyticsRepository(),
 	}
 }
 
@@ -56,6 +61,15 @@ const (
 	Deny
 )
 
+// Allow implementa channels.Limiter: solo Deny bloquea el mensaje.
+// Un RateLimiter nil deja pasar todo (fail open).
+func (rl *RateLimiter) Allow(ctx context.Context, key string) bool {
+	if rl == nil {
+		return true
+	}
+	return rl.Check(ctx, key) != Deny
+}
+
 // Check verifica si el teléfono puede ser procesado.
 //
 // Lógica:
@@ -99,6 +113,7 @@ func (rl *RateLimiter) Check(ctx context.Context, phone string) CheckResult {
 			"limit", rl.limit,
 		)
 		go rl.sendLimitAlert(current)
+		go rl.recordDeny(context.WithoutCancel(ctx), phone)
 		return Deny
 	}
 
@@ -147,6 +162,22 @@ func (rl *RateLimiter) DailyStats(ctx context.Context) (count int, phones []stri
 	return
 }
 
+// recordDeny deja el rechazo en bot_events: el contador de Redis expira en 48h
+func (rl *RateLimiter) recordDeny(ctx context.Context, key string) {
+	if rl.events == nil {
+		return
+	}
+	ev := &models.BotEvent{
+		Kind:            models.BotEventRateLimit,
+		Channel:         channels.KeyChannel(key),
+		ConversationKey: key,
+		OK:              true,
+	}
+	if err := rl.events.WithContext(ctx).RecordEvent(ev); err != nil {
+		slog.Warn("whatsapp: error registrando rechazo del rate limiter", "error", err)
+	}
+}
+
 // ─── Alertas de email ────────────────────────────────────────────────────────
 
 func (rl *RateLimiter) sendThresholdAlert(count int) {
@@ -7,13 +7,20 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"mime/multipart"
 	"net/http"
+	"net/textproto"
 	"os"
 	"time"
+
+	"github.com/alonsoalpizar/fabricalaser/internal/channels"
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
+	"github.com/alonsoalpizar/fabricalaser/internal/tenant"
 )
 
 // ErrMetaRateLimit indica que Meta rechazó el mensaje por límite de conversaciones (código 131049).
-var ErrMetaRateLimit = fmt.Errorf("whatsapp: Meta rate limit alcanzado (131049)")
+// Envuelve channels.ErrRateLimited para que el motor de conversación lo reconozca.
+var ErrMetaRateLimit = fmt.Errorf("whatsapp: Meta rate limit alcanzado (131049): %w", channels.ErrRateLimited)
 
 // metaErrorResponse es la estructura del error que devuelve Meta cuando falla el envío.
 type metaErrorResponse struct {
@@ -54,12 +61,15 @@ type Metadata struct {
 }
 
 type Message struct {
-	From      string    `json:"from"`
-	ID        string    `json:"id"`
-	Timestamp string    `json:"timestamp"`
-	Type      string    `json:"type"`
-	Text      *TextMsg  `json:"text,omitempty"`
-	Image     *ImageMsg `json:"image,omitempty"`
+	From        string          `json:"from"`
+	ID          string          `json:"id"`
+	Timestamp   string          `json:"timestamp"`
+	Type        string          `json:"type"`
+	Text        *TextMsg        `json:"text,omitempty"`
+	Image       *ImageMsg       `json:"image,omitempty"`
+	Document    *DocumentMsg    `json:"document,omitempty"`
+	Interactive *InteractiveMsg `json:"interactive,omitempty"`
+	Button      *ButtonMsg      `json:"button,omitempty"`
 }
 
 type TextMsg struct {
@@ -72,6 +82,33 @@ type ImageMsg struct {
 	Caption  string `json:"caption,omitempty"`
 }
 
+// DocumentMsg es un archivo adjunto (SVG, DXF, PDF...) enviado por el cliente
+type DocumentMsg struct {
+	ID       string `json:"id"`
+	MimeType string `json:"mime_type"`
+	Filename string `json:"filename,omitempty"`
+	Caption  string `json:"caption,omitempty"`
+}
+
+// InteractiveMsg es la respuesta del cliente a un mensaje con botones o lista
+type InteractiveMsg struct {
+	Type        string    `json:"type"` // "button_reply" o "list_reply"
+	ButtonReply *ReplyMsg `json:"button_reply,omitempty"`
+	ListReply   *ReplyMsg `json:"list_reply,omitempty"`
+}
+
+type ReplyMsg struct {
+	ID          string `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+}
+
+// ButtonMsg es el toque de un botón de respuesta rápida de una plantilla
+type ButtonMsg struct {
+	Payload string `json:"payload"`
+	Text    string `json:"text"`
+}
+
 type Status struct {
 	ID          string `json:"id"`
 	Status      string `json:"status"`
@@ -80,40 +117,72 @@ type Status struct {
 
 // ─── Cliente para enviar mensajes vía Meta Cloud API ────────────────────────
 
-// Sender encapsula el cliente HTTP y las credenciales para enviar mensajes.
+// Límites de Meta para mensajes interactivos
+const (
+	maxReplyButtons   = 3
+	maxButtonTitleLen = 20
+	maxListRows       = 10
+	maxRowTitleLen    = 24
+	maxRowDescLen     = 72
+	maxBodyLen        = 1024
+)
+
+// ReplyButton es un botón de respuesta rápida (máximo 3 por mensaje)
+type ReplyButton struct {
+	ID    string
+	Title string
+}
+
+// ListRow es una fila de un mensaje de lista
+type ListRow struct {
+	ID          string
+	Title       string
+	Description string
+}
+
+// ListSection agrupa filas bajo un título (máximo 10 filas en total)
+type ListSection struct {
+	Title string
+	Rows  []ListRow
+}
+
+// Sender encapsula el cliente HTTP para enviar mensajes. Las credenciales son
+// las del tenant del contexto de cada envío (ver tenant.Channels).
 type Sender struct {
-	phoneNumberID string
-	accessToken   string
-	apiVersion    string
-	httpClient    *http.Client
+	apiVersion string
+	httpClient *http.Client
 }
 
-// NewSender construye el Sender leyendo credenciales de variables de entorno.
+// NewSender construye el Sender; cada envío usa el número y el token del
+// tenant de su contexto (variables de entorno para el tenant por defecto).
 func NewSender() *Sender {
-	version := os.Getenv("WHATSAPP_API_VERSION")
-	if version == "" {
-		version = "v22.0"
-	}
-
 	return &Sender{
-		phoneNumberID: os.Getenv("WHATSAPP_PHONE_NUMBER_ID"),
-		accessToken:   os.Getenv("WHATSAPP_ACCESS_TOKEN"),
-		apiVersion:    version,
+		apiVersion: apiVersion(),
 		httpClient: &http.Client{
 			Timeout: 15 * time.Second,
 		},
 	}
 }
 
+// credentials retorna el número y el token de WhatsApp del tenant de ctx
+func credentials(ctx context.Context) (models.TenantCredentials, error) {
+	creds := tenant.Channels(tenant.ID(ctx))
+	if creds.WhatsAppPhoneNumberID == "" || creds.WhatsAppAccessToken == "" {
+		return creds, fmt.Errorf("whatsapp: el tenant %d no tiene credenciales de WhatsApp", tenant.ID(ctx))
+	}
+	return creds, nil
+}
+
+func apiVersion() string {
+	if version := os.Getenv("WHATSAPP_API_VERSION"); version != "" {
+		return version
+	}
+	return "v22.0"
+}
+
 // SendText envía un mensaje de texto plano al número destinatario en formato E.164.
 func (s *Sender) SendText(ctx context.Context, to, text string) error {
-	url := fmt.Sprintf(
-		"https://graph.facebook.com/%s/%s/messages",
-		s.apiVersion,
-		s.phoneNumberID,
-	)
-
-	payload := map[string]interface{}{
+	return s.sendMessage(ctx, to, map[string]interface{}{
 		"messaging_product": "whatsapp",
 		"recipient_type":    "individual",
 		"to":                to,
@@ -122,7 +191,202 @@ func (s *Sender) SendText(ctx context.Context, to, text string) error {
 			"preview_url": "false",
 			"body":        text,
 		},
+	})
+}
+
+// SendDocument envía un documento ya subido con UploadMedia (PDF de cotización, etc.).
+func (s *Sender) SendDocument(ctx context.Context, to, mediaID, filename, caption string) error {
+	document := map[string]string{
+		"id":       mediaID,
+		"filename": filename,
+	}
+	if caption != "" {
+		document["caption"] = caption
+	}
+	return s.sendMessage(ctx, to, map[string]interface{}{
+		"messaging_product": "whatsapp",
+		"recipient_type":    "individual",
+		"to":                to,
+		"type":              "document",
+		"document":          document,
+	})
+}
+
+// SendButtons envía un mensaje con hasta 3 botones de respuesta rápida.
+// Los títulos se recortan a 20 caracteres (límite de Meta).
+func (s *Sender) SendButtons(ctx context.Context, to, body string, buttons []ReplyButton) error {
+	if len(buttons) == 0 || len(buttons) > maxReplyButtons {
+		return fmt.Errorf("sender: se requieren entre 1 y %d botones, recibidos %d", maxReplyButtons, len(buttons))
+	}
+	items := make([]map[string]interface{}, len(buttons))
+	for i, b := range buttons {
+		items[i] = map[string]interface{}{
+			"type":  "reply",
+			"reply": map[string]string{"id": b.ID, "title": truncate(b.Title, maxButtonTitleLen)},
+		}
 	}
+	return s.sendMessage(ctx, to, map[string]interface{}{
+		"messaging_product": "whatsapp",
+		"recipient_type":    "individual",
+		"to":                to,
+		"type":              "interactive",
+		"interactive": map[string]interface{}{
+			"type":   "button",
+			"body":   map[string]string{"text": truncate(body, maxBodyLen)},
+			"action": map[string]interface{}{"buttons": items},
+		},
+	})
+}
+
+// SendList envía un mensaje de lista: el cliente toca buttonText y elige una fila.
+func (s *Sender) SendList(ctx context.Context, to, body, buttonText string, sections []ListSection) error {
+	total := 0
+	items := make([]map[string]interface{}, len(sections))
+	for i, sec := range sections {
+		rows := make([]map[string]string, len(sec.Rows))
+		for j, r := range sec.Rows {
+			row := map[string]string{"id": r.ID, "title": truncate(r.Title, maxRowTitleLen)}
+			if r.Description != "" {
+				row["description"] = truncate(r.Description, maxRowDescLen)
+			}
+			rows[j] = row
+		}
+		total += len(rows)
+		item := map[string]interface{}{"rows": rows}
+		if sec.Title != "" {
+			item["title"] = truncate(sec.Title, maxRowTitleLen)
+		}
+		items[i] = item
+	}
+	if total == 0 || total > maxListRows {
+		return fmt.Errorf("sender: una lista admite entre 1 y %d filas, recibidas %d", maxListRows, total)
+	}
+	return s.sendMessage(ctx, to, map[string]interface{}{
+		"messaging_product": "whatsapp",
+		"recipient_type":    "individual",
+		"to":                to,
+		"type":              "interactive",
+		"interactive": map[string]interface{}{
+			"type": "list",
+			"body": map[string]string{"text": truncate(body, maxBodyLen)},
+			"action": map[string]interface{}{
+				"button":   truncate(buttonText, maxButtonTitleLen),
+				"sections": items,
+			},
+		},
+	})
+}
+
+// SendTemplate envía una plantilla aprobada en Meta Business Manager. Es la única
+// forma de escribirle a un cliente fuera de la ventana de 24h desde su último mensaje.
+// params llena las variables {{1}}, {{2}}, ... del cuerpo de la plantilla.
+func (s *Sender) SendTemplate(ctx context.Context, to, name, language string, params []string) error {
+	template := map[string]interface{}{
+		"name":     name,
+		"language": map[string]string{"code": language},
+	}
+	if len(params) > 0 {
+		parameters := make([]map[string]string, len(params))
+		for i, p := range params {
+			parameters[i] = map[string]string{"type": "text", "text": p}
+		}
+		template["components"] = []map[string]interface{}{
+			{"type": "body", "parameters": parameters},
+		}
+	}
+	return s.sendMessage(ctx, to, map[string]interface{}{
+		"messaging_product": "whatsapp",
+		"recipient_type":    "individual",
+		"to":                to,
+		"type":              "template",
+		"template":          template,
+	})
+}
+
+// SendImage envía una imagen ya subida con UploadMedia.
+func (s *Sender) SendImage(ctx context.Context, to, mediaID, caption string) error {
+	image := map[string]string{"id": mediaID}
+	if caption != "" {
+		image["caption"] = caption
+	}
+	return s.sendMessage(ctx, to, map[string]interface{}{
+		"messaging_product": "whatsapp",
+		"recipient_type":    "individual",
+		"to":                to,
+		"type":              "image",
+		"image":             image,
+	})
+}
+
+// UploadMedia sube un archivo a la Media API de Meta y retorna su media ID.
+// Meta conserva el archivo 30 días; alcanza para enviarlo de inmediato.
+func (s *Sender) UploadMedia(ctx context.Context, data []byte, mimeType, filename string) (string, error) {
+	creds, err := credentials(ctx)
+	if err != nil {
+		return "", err
+	}
+	url := fmt.Sprintf(
+		"https://graph.facebook.com/%s/%s/media",
+		s.apiVersion,
+		creds.WhatsAppPhoneNumberID,
+	)
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	_ = mw.WriteField("messaging_product", "whatsapp")
+	_ = mw.WriteField("type", mimeType)
+	partHeader := textproto.MIMEHeader{}
+	partHeader.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename="%s"`, filename))
+	partHeader.Set("Content-Type", mimeType)
+	part, err := mw.CreatePart(partHeader)
+	if err != nil {
+		return "", fmt.Errorf("sender: error creando multipart: %w", err)
+	}
+	if _, err := part.Write(data); err != nil {
+		return "", fmt.Errorf("sender: error escribiendo archivo: %w", err)
+	}
+	if err := mw.Close(); err != nil {
+		return "", fmt.Errorf("sender: error cerrando multipart: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, &body)
+	if err != nil {
+		return "", fmt.Errorf("sender: error creando request: %w", err)
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+creds.WhatsAppAccessToken)
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("sender: error subiendo media: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("sender: Meta respondió con status %d al subir media: %s", resp.StatusCode, string(respBody))
+	}
+
+	var media struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(respBody, &media); err != nil || media.ID == "" {
+		return "", fmt.Errorf("sender: respuesta de media inválida: %s", string(respBody))
+	}
+	return media.ID, nil
+}
+
+// sendMessage hace el POST a /messages y traduce los errores de Meta.
+func (s *Sender) sendMessage(ctx context.Context, to string, payload map[string]interface{}) error {
+	creds, err := credentials(ctx)
+	if err != nil {
+		return err
+	}
+	url := fmt.Sprintf(
+		"https://graph.facebook.com/%s/%s/messages",
+		s.apiVersion,
+		creds.WhatsAppPhoneNumberID,
+	)
 
 	body, err := json.Marshal(payload)
 	if err != nil {
@@ -135,7 +399,7 @@ func (s *Sender) SendText(ctx context.Context, to, text string) error {
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+s.accessToken)
+	req.Header.Set("Authorization", "Bearer "+creds.WhatsAppAccessToken)
 
 	resp, err := s.httpClient.Do(req)
 	if err != nil {
@@ -157,6 +421,15 @@ func (s *Sender) SendText(ctx context.Context, to, text string) error {
 		return fmt.Errorf("sender: Meta respondió con status %d: %s", resp.StatusCode, string(bodyBytes))
 	}
 
-	slog.Info("whatsapp: mensaje enviado exitosamente", "to", to)
+	slog.Info("whatsapp: mensaje enviado exitosamente", "to", to, "type", payload["type"])
 	return nil
 }
+
+// truncate recorta a max runas, terminando en "…" si hubo recorte
+func truncate(text string, max int) string {
+	runes := []rune(text)
+	if len(runes) <= max {
+		return text
+	}
+	return string(runes[:max-1]) + "…"
+}
@@ -12,8 +12,10 @@ import (
 	"sync"
 	"time"
 
-	"cloud.google.com/go/vertexai/genai"
+	"github.com/alonsoalpizar/fabricalaser/internal/llm"
+	"github.com/alonsoalpizar/fabricalaser/internal/models"
 	"github.com/alonsoalpizar/fabricalaser/internal/repository"
+	"github.com/alonsoalpizar/fabricalaser/internal/tenant"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -85,7 +87,7 @@ func SendDigest(rc *redis.Client) error {
 	}
 
 	setLastSent(rc, sentAt)
-	log.Printf("[WhatsApp digest] Sent to %s — %d contacto(s), %d mensaje(s)", digestTo, len(groups), len(messages))
+	log.Printf("[WhatsApp digest] Sent to %s — %d contacto(s), %d mensaje(s)", digestRecipient(), len(groups), len(messages))
 	return nil
 }
 
@@ -133,7 +135,7 @@ func summarizeGroups(groups []phoneGroup) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
 	defer cancel()
 
-	client, err := genai.NewClient(ctx, waProjectID, waLocation)
+	client, err := llm.NewGemini(ctx, llm.GCPProjectID, llm.GCPLocation)
 	if err != nil {
 		return fmt.Errorf("vertex ai client: %w", err)
 	}
@@ -170,11 +172,7 @@ func summarizeGroups(groups []phoneGroup) error {
 	return nil
 }
 
-func geminiSummarize(ctx context.Context, client *genai.Client, g phoneGroup) (string, error) {
-	model := client.GenerativeModel(waModelName)
-	model.SetTemperature(0.2)
-	model.SetMaxOutputTokens(2048)
-
+func geminiSummarize(ctx context.Context, model llm.Provider, g phoneGroup) (string, error) {
 	// Limitar a los últimos 40 mensajes para no exceder el contexto de Gemini
 	msgs := g.messages
 	const maxMsgsForSummary = 40
@@ -206,21 +204,10 @@ Escribí en español directo, como si le hablaras al asesor de igual a igual. Si
 %sConversación (%d mensajes):
 %s`, contextNote, len(msgs), conv.String())
 
-	resp, err := model.GenerateContent(ctx, genai.Text(prompt))
-	if err != nil {
-		return "", err
-	}
-	if len(resp.Candidates) == 0 || len(resp.Candidates[0].Content.Parts) == 0 {
-		return "", fmt.Errorf("empty response")
-	}
-
-	var result strings.Builder
-	for _, part := range resp.Candidates[0].Content.Parts {
-		if text, ok := part.(genai.Text); ok {
-			result.WriteString(string(text))
-		}
-	}
-	return strings.TrimSpace(result.String()), nil
+	return llm.Summarize(ctx, model, llm.Config{
+		Temperature:     llm.Float32(0.2),
+		MaxOutputTokens: llm.Int32(2048),
+	}, prompt)
 }
 
 // ─────────────────────────────────────────────
@@ -326,6 +313,14 @@ func buildEmailBody(groups []phoneGroup, since, sentAt time.Time) string {
 // SMTP
 // ─────────────────────────────────────────────
 
+// digestRecipient returns the default tenant's digest_email, or digestTo when it has none.
+func digestRecipient() string {
+	if to := tenant.Lookup(models.DefaultTenantID).DigestEmail; to != "" {
+		return to
+	}
+	return digestTo
+}
+
 // sendMail sends via local Postfix without STARTTLS (localhost relay, no cert needed).
 func sendMail(subject, htmlBody string) error {
 	conn, err := net.Dial("tcp", smtpAddr)
@@ -338,10 +333,11 @@ func sendMail(subject, htmlBody string) error {
 	}
 	defer client.Close()
 
+	to := digestRecipient()
 	if err := client.Mail(digestFrom); err != nil {
 		return fmt.Errorf("MAIL FROM: %w", err)
 	}
-	if err := client.Rcpt(digestTo); err != nil {
+	if err := client.Rcpt(to); err != nil {
 		return fmt.Errorf("RCPT TO: %w", err)
 	}
 	w, err := client.Data()
@@ -350,7 +346,7 @@ func sendMail(subject, htmlBody string) error {
 	}
 	mime := "MIME-Version: 1.0\r\nContent-Type: text/html; charset=UTF-8\r\n"
 	msg := fmt.Sprintf("From: FabricaLaser <%s>\r\nTo: %s\r\nSubject: %s\r\n%s\r\n%s",
-		digestFrom, digestTo, subject, mime, htmlBody)
+		digestFrom, to, subject, mime, htmlBody)
 	if _, err := fmt.Fprint(w, msg); err != nil {
 		return fmt.Errorf("write: %w", err)
 	}
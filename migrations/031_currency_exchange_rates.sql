@@ -0,0 +1,66 @@
+-- Migration 031: Moneda explícita + tipo de cambio diario
+-- Hasta ahora las conversiones eran implícitas: tech_rates decía "USD/hora" en
+-- comentarios pero se sumaba con overheads en ₡, y el estimado devolvía CRC.
+--
+-- 1. Cada configuración monetaria declara su moneda (default CRC = comportamiento actual)
+-- 2. exchange_rates guarda el tipo de cambio USD→CRC por día (manual, CSV o BCCR)
+-- 3. quotes guarda moneda de presentación + tipo de cambio usado (reproducibilidad)
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS exchange_rates (
+    id             SERIAL PRIMARY KEY,
+    rate_date      DATE NOT NULL,
+    base_currency  VARCHAR(3) NOT NULL DEFAULT 'USD',
+    quote_currency VARCHAR(3) NOT NULL DEFAULT 'CRC',
+    buy_rate       DECIMAL(12,4) NOT NULL,
+    sell_rate      DECIMAL(12,4) NOT NULL,
+    source         VARCHAR(20) NOT NULL DEFAULT 'manual' CHECK (source IN ('manual', 'csv', 'bccr')),
+    notes          TEXT,
+    created_at     TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at     TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (rate_date, base_currency, quote_currency)
+);
+
+CREATE INDEX IF NOT EXISTS idx_exchange_rates_date ON exchange_rates (rate_date DESC);
+
+COMMENT ON TABLE exchange_rates IS 'Tipo de cambio diario (1 base_currency = N quote_currency)';
+COMMENT ON COLUMN exchange_rates.buy_rate IS 'Tipo de cambio de compra (BCCR)';
+COMMENT ON COLUMN exchange_rates.sell_rate IS 'Tipo de cambio de venta (BCCR) - el que usa el cotizador';
+
+-- Moneda de cada configuración monetaria
+ALTER TABLE tech_rates
+    ADD COLUMN IF NOT EXISTS currency VARCHAR(3) NOT NULL DEFAULT 'CRC';
+ALTER TABLE material_costs
+    ADD COLUMN IF NOT EXISTS currency VARCHAR(3) NOT NULL DEFAULT 'CRC';
+ALTER TABLE system_config
+    ADD COLUMN IF NOT EXISTS currency VARCHAR(3);
+
+COMMENT ON COLUMN tech_rates.currency IS 'Moneda de tarifas, setup y costos fijos mensuales de la fila';
+COMMENT ON COLUMN material_costs.currency IS 'Moneda de cost_per_mm2 y sheet_cost';
+COMMENT ON COLUMN system_config.currency IS 'Moneda del valor (NULL = no monetario)';
+
+UPDATE system_config SET currency = 'CRC'
+WHERE config_key IN ('min_value_base', 'price_per_mm2', 'price_per_mm_cut', 'overhead_alquiler', 'overhead_internet')
+  AND currency IS NULL;
+
+INSERT INTO system_config (config_key, config_value, value_type, category, description, is_active) VALUES
+('base_currency', 'CRC', 'string', 'currency', 'Moneda base del cotizador (todo se convierte a esta moneda)', true),
+('usd_crc_fallback_rate', '505', 'number', 'currency', 'Tipo de cambio USD→CRC si no hay registro en exchange_rates', true)
+ON CONFLICT (config_key) DO NOTHING;
+
+-- Moneda y tipo de cambio congelados en cada cotización
+ALTER TABLE quotes
+    ADD COLUMN IF NOT EXISTS currency VARCHAR(3) NOT NULL DEFAULT 'CRC',
+    ADD COLUMN IF NOT EXISTS base_currency VARCHAR(3) NOT NULL DEFAULT 'CRC',
+    ADD COLUMN IF NOT EXISTS exchange_rate DECIMAL(12,4) NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS exchange_rate_date DATE;
+
+COMMENT ON COLUMN quotes.currency IS 'Moneda en que se presenta la cotización al cliente';
+COMMENT ON COLUMN quotes.base_currency IS 'Moneda en que están guardados los montos de la fila';
+COMMENT ON COLUMN quotes.exchange_rate IS 'CRC por 1 USD usado al cotizar (0 = no se usó conversión)';
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON exchange_rates TO fabricalaser;
+GRANT USAGE, SELECT ON SEQUENCE exchange_rates_id_seq TO fabricalaser;
+
+COMMIT;
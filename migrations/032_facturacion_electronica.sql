@@ -0,0 +1,72 @@
+-- Migration 032: Facturación electrónica (Hacienda v4.4)
+-- Las cotizaciones convertidas se facturan desde el sistema en lugar de hacerlo a mano.
+--
+-- 1. materials.cabys_code: código CABYS del material cuando FabricaLaser lo provee
+-- 2. invoice_sequences: consecutivo por sucursal/terminal/tipo de comprobante (sin huecos)
+-- 3. invoices: comprobante firmado + estado de recepción en Hacienda
+-- 4. system_config: CABYS por defecto, condición de venta y medio de pago
+--
+-- Los códigos CABYS quedan vacíos a propósito: deben tomarse del catálogo vigente
+-- del BCCR. Emitir una factura sin CABYS configurado falla con un error explícito.
+
+BEGIN;
+
+ALTER TABLE materials
+    ADD COLUMN IF NOT EXISTS cabys_code VARCHAR(13);
+
+COMMENT ON COLUMN materials.cabys_code IS 'Código CABYS (13 dígitos) cuando se factura el material como mercancía';
+
+CREATE TABLE IF NOT EXISTS invoice_sequences (
+    id          SERIAL PRIMARY KEY,
+    sucursal    INTEGER NOT NULL DEFAULT 1,
+    terminal    INTEGER NOT NULL DEFAULT 1,
+    doc_type    VARCHAR(2) NOT NULL,
+    last_number BIGINT NOT NULL DEFAULT 0,
+    updated_at  TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (sucursal, terminal, doc_type)
+);
+
+COMMENT ON TABLE invoice_sequences IS 'Último consecutivo emitido por sucursal/terminal/tipo (01 factura, 04 tiquete)';
+
+CREATE TABLE IF NOT EXISTS invoices (
+    id                 SERIAL PRIMARY KEY,
+    quote_id           INTEGER NOT NULL REFERENCES quotes(id),
+    user_id            INTEGER NOT NULL REFERENCES users(id),
+    doc_type           VARCHAR(2) NOT NULL,
+    clave              VARCHAR(50) NOT NULL UNIQUE,
+    consecutivo        VARCHAR(20) NOT NULL UNIQUE,
+    issued_at          TIMESTAMPTZ NOT NULL,
+    currency           VARCHAR(3) NOT NULL DEFAULT 'CRC',
+    exchange_rate      DECIMAL(12,4) NOT NULL DEFAULT 1,
+    total_venta        DECIMAL(18,5) NOT NULL DEFAULT 0,
+    total_impuesto     DECIMAL(18,5) NOT NULL DEFAULT 0,
+    total_comprobante  DECIMAL(18,5) NOT NULL DEFAULT 0,
+    status             VARCHAR(20) NOT NULL DEFAULT 'signed'
+                       CHECK (status IN ('signed', 'sent', 'accepted', 'rejected', 'error')),
+    xml_signed         TEXT NOT NULL,
+    hacienda_status    VARCHAR(30),
+    hacienda_response  TEXT,
+    last_error         TEXT,
+    sent_at            TIMESTAMPTZ,
+    created_at         TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at         TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_invoices_quote ON invoices (quote_id);
+CREATE INDEX IF NOT EXISTS idx_invoices_status ON invoices (status, created_at DESC);
+
+COMMENT ON TABLE invoices IS 'Comprobantes electrónicos (Hacienda v4.4) emitidos a partir de cotizaciones convertidas';
+COMMENT ON COLUMN invoices.hacienda_status IS 'ind-estado devuelto por Hacienda: recibido, procesando, aceptado, rechazado';
+COMMENT ON COLUMN invoices.hacienda_response IS 'respuesta-xml de Hacienda decodificada (mensaje de aceptación/rechazo)';
+
+INSERT INTO system_config (config_key, config_value, value_type, category, description, is_active) VALUES
+('cabys_servicio_default', '', 'string', 'facturacion', 'CABYS del servicio de grabado/corte láser (cuando el cliente trae el material)', true),
+('cabys_producto_default', '', 'string', 'facturacion', 'CABYS por defecto si el material provisto no tiene cabys_code', true),
+('fe_condicion_venta', '01', 'string', 'facturacion', 'Condición de venta Hacienda (01 = contado)', true),
+('fe_medio_pago', '04', 'string', 'facturacion', 'Medio de pago Hacienda (01 efectivo, 04 transferencia, 06 SINPE Móvil)', true)
+ON CONFLICT (config_key) DO NOTHING;
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON invoice_sequences, invoices TO fabricalaser;
+GRANT USAGE, SELECT ON SEQUENCE invoice_sequences_id_seq, invoices_id_seq TO fabricalaser;
+
+COMMIT;
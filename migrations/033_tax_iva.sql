@@ -0,0 +1,50 @@
+-- Migration 033: Motor de impuestos (IVA + exoneraciones)
+-- Los precios calculados (price_final) y los de blanks son montos SIN IVA.
+-- El IVA se calcula aparte según el tipo de venta y la exoneración del cliente,
+-- y se congela en la cotización junto al modo de presentación.
+
+BEGIN;
+
+INSERT INTO system_config (config_key, config_value, value_type, category, description, is_active) VALUES
+('iva_tarifa_servicio', '13', 'number', 'impuestos', 'Tarifa IVA (%) del servicio láser cuando el cliente trae el material', true),
+('iva_tarifa_mercancia', '13', 'number', 'impuestos', 'Tarifa IVA (%) cuando FabricaLaser provee el material', true),
+('iva_tarifa_blank', '13', 'number', 'impuestos', 'Tarifa IVA (%) de productos del catálogo de blanks', true),
+('iva_modo_presentacion', 'exclusive', 'string', 'impuestos', 'exclusive = precio + IVA; inclusive = precio con IVA incluido', true)
+ON CONFLICT (config_key) DO NOTHING;
+
+-- Exoneración del cliente (Hacienda)
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS exoneracion_numero VARCHAR(40),
+    ADD COLUMN IF NOT EXISTS exoneracion_tipo VARCHAR(2),
+    ADD COLUMN IF NOT EXISTS exoneracion_institucion VARCHAR(160),
+    ADD COLUMN IF NOT EXISTS exoneracion_pct DECIMAL(5,2) NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS exoneracion_fecha DATE,
+    ADD COLUMN IF NOT EXISTS exoneracion_vence DATE;
+
+COMMENT ON COLUMN users.exoneracion_numero IS 'Número de documento de exoneración';
+COMMENT ON COLUMN users.exoneracion_pct IS 'Porcentaje del IVA exonerado (100 = exoneración total)';
+COMMENT ON COLUMN users.exoneracion_vence IS 'Fecha de vencimiento; vencida = no se aplica';
+
+-- Impuesto congelado en la cotización
+ALTER TABLE quotes
+    ADD COLUMN IF NOT EXISTS tax_kind VARCHAR(20) NOT NULL DEFAULT 'mercancia',
+    ADD COLUMN IF NOT EXISTS tax_rate DECIMAL(5,2) NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS tax_exempt_pct DECIMAL(5,2) NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS tax_exoneracion VARCHAR(40),
+    ADD COLUMN IF NOT EXISTS tax_amount DECIMAL(12,2) NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS price_total_with_tax DECIMAL(12,2) NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS tax_display VARCHAR(10) NOT NULL DEFAULT 'exclusive';
+
+COMMENT ON COLUMN quotes.tax_rate IS 'Tarifa IVA (%) aplicada; price_final es el subtotal sin IVA';
+COMMENT ON COLUMN quotes.tax_amount IS 'IVA neto (después de exoneración) en moneda base';
+COMMENT ON COLUMN quotes.price_total_with_tax IS 'price_final + tax_amount';
+
+-- Cotizaciones anteriores: IVA general 13% sobre price_final
+UPDATE quotes
+SET tax_rate = 13,
+    tax_amount = ROUND(price_final * 0.13, 2),
+    price_total_with_tax = ROUND(price_final * 1.13, 2),
+    tax_kind = CASE WHEN material_included = false THEN 'servicio' ELSE 'mercancia' END
+WHERE tax_rate = 0;
+
+COMMIT;
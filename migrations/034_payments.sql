@@ -0,0 +1,103 @@
+-- Migration 034: Pagos (SINPE Móvil + pasarela de tarjeta) y conciliación
+-- Hasta ahora los pagos SINPE se confirmaban a mano leyendo los SMS del banco.
+--
+-- 1. payment_intents: cobro esperado por pedido (cotización convertida); adelanto, saldo o total
+-- 2. payment_transactions: movimientos importados (CSV del banco, SMS de SINPE, webhook de tarjeta)
+-- 3. payment_refunds: devoluciones parciales o totales de un cobro
+-- 4. quotes.amount_paid / payment_status: estado de pago del pedido
+-- 5. system_config: número SINPE, % de adelanto y vigencia de los cobros
+--
+-- Todos los montos están en la moneda base (CRC), IVA incluido.
+
+BEGIN;
+
+ALTER TABLE quotes
+    ADD COLUMN IF NOT EXISTS amount_paid DECIMAL(12,2) NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS payment_status VARCHAR(20) NOT NULL DEFAULT 'unpaid'
+        CHECK (payment_status IN ('unpaid', 'partial', 'paid', 'refunded'));
+
+COMMENT ON COLUMN quotes.amount_paid IS 'Total cobrado menos devoluciones (CRC, IVA incluido)';
+COMMENT ON COLUMN quotes.payment_status IS 'unpaid, partial (adelanto), paid, refunded';
+
+CREATE TABLE IF NOT EXISTS payment_intents (
+    id              SERIAL PRIMARY KEY,
+    quote_id        INTEGER NOT NULL REFERENCES quotes(id),
+    user_id         INTEGER NOT NULL REFERENCES users(id),
+    provider        VARCHAR(20) NOT NULL,
+    kind            VARCHAR(20) NOT NULL DEFAULT 'full'
+                    CHECK (kind IN ('deposit', 'balance', 'full')),
+    reference       VARCHAR(20) NOT NULL UNIQUE,
+    amount          DECIMAL(12,2) NOT NULL CHECK (amount > 0),
+    amount_received DECIMAL(12,2) NOT NULL DEFAULT 0,
+    amount_refunded DECIMAL(12,2) NOT NULL DEFAULT 0,
+    currency        VARCHAR(3) NOT NULL DEFAULT 'CRC',
+    status          VARCHAR(20) NOT NULL DEFAULT 'pending'
+                    CHECK (status IN ('pending', 'succeeded', 'failed', 'canceled', 'expired')),
+    payer_phone     VARCHAR(20),
+    provider_ref    VARCHAR(100),
+    checkout_url    TEXT,
+    expires_at      TIMESTAMPTZ,
+    paid_at         TIMESTAMPTZ,
+    created_at      TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at      TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_payment_intents_quote ON payment_intents (quote_id);
+CREATE INDEX IF NOT EXISTS idx_payment_intents_pending ON payment_intents (status, provider) WHERE status = 'pending';
+
+COMMENT ON TABLE payment_intents IS 'Cobros esperados por pedido; la referencia (FL-XXXXXX) va en la descripción del SINPE';
+
+CREATE TABLE IF NOT EXISTS payment_transactions (
+    id           SERIAL PRIMARY KEY,
+    source       VARCHAR(20) NOT NULL CHECK (source IN ('csv', 'sinpe_sms', 'webhook', 'manual')),
+    fingerprint  VARCHAR(64) NOT NULL UNIQUE,
+    occurred_at  TIMESTAMPTZ NOT NULL,
+    amount       DECIMAL(12,2) NOT NULL,
+    currency     VARCHAR(3) NOT NULL DEFAULT 'CRC',
+    payer_name   VARCHAR(160),
+    payer_phone  VARCHAR(20),
+    bank_ref     VARCHAR(100),
+    description  TEXT,
+    raw          TEXT,
+    status       VARCHAR(20) NOT NULL DEFAULT 'unmatched'
+                 CHECK (status IN ('matched', 'unmatched', 'ignored')),
+    intent_id    INTEGER REFERENCES payment_intents(id),
+    match_rule   VARCHAR(30),
+    created_at   TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at   TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_payment_transactions_status ON payment_transactions (status, occurred_at DESC);
+
+COMMENT ON COLUMN payment_transactions.fingerprint IS 'SHA-256 de fecha+monto+referencia bancaria; evita importar dos veces el mismo movimiento';
+COMMENT ON COLUMN payment_transactions.match_rule IS 'reference, phone_amount, unique_amount o manual';
+
+CREATE TABLE IF NOT EXISTS payment_refunds (
+    id            SERIAL PRIMARY KEY,
+    intent_id     INTEGER NOT NULL REFERENCES payment_intents(id),
+    amount        DECIMAL(12,2) NOT NULL CHECK (amount > 0),
+    reason        TEXT,
+    status        VARCHAR(20) NOT NULL DEFAULT 'pending'
+                  CHECK (status IN ('pending', 'completed', 'failed')),
+    provider_ref  VARCHAR(100),
+    created_by    INTEGER REFERENCES users(id),
+    completed_at  TIMESTAMPTZ,
+    created_at    TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at    TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_payment_refunds_intent ON payment_refunds (intent_id);
+
+COMMENT ON TABLE payment_refunds IS 'Devoluciones; las de SINPE quedan pending hasta que el admin confirme la transferencia';
+
+INSERT INTO system_config (config_key, config_value, value_type, category, description, is_active) VALUES
+('sinpe_movil_numero', '', 'string', 'pagos', 'Número SINPE Móvil donde los clientes depositan', true),
+('sinpe_movil_titular', '', 'string', 'pagos', 'Nombre del titular de la cuenta SINPE Móvil', true),
+('pago_adelanto_pct', '50', 'number', 'pagos', 'Porcentaje del total que se cobra como adelanto', true),
+('pago_vigencia_horas', '72', 'number', 'pagos', 'Horas antes de que un cobro pendiente expire', true)
+ON CONFLICT (config_key) DO NOTHING;
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON payment_intents, payment_transactions, payment_refunds TO fabricalaser;
+GRANT USAGE, SELECT ON SEQUENCE payment_intents_id_seq, payment_transactions_id_seq, payment_refunds_id_seq TO fabricalaser;
+
+COMMIT;
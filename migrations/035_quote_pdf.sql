@@ -0,0 +1,15 @@
+-- Migration 035: Cotización formal en PDF
+-- Los términos y condiciones que se imprimen al pie del PDF son editables desde el admin.
+-- Un término por línea; si queda vacío se usan los términos por defecto del renderer.
+
+BEGIN;
+
+INSERT INTO system_config (config_key, config_value, value_type, category, description, is_active) VALUES
+('cotizacion_terminos',
+ E'Precios en la moneda indicada; el IVA se detalla por separado.\nSe requiere un adelanto del 50% para iniciar la producción; el saldo se cancela contra entrega.\nEl tiempo de entrega se confirma al aprobar el diseño final.\nEl cliente garantiza que tiene derecho a usar los diseños y logotipos enviados.\nVariaciones menores de color y veta son propias del material y no constituyen defecto.',
+ 'string', 'cotizacion', 'Términos y condiciones del PDF de cotización (uno por línea)', true),
+('cotizacion_pdf_url', 'https://fabricalaser.com/cotizar/?cotizacion=%d', 'string', 'cotizacion',
+ 'URL del código QR del PDF (%d = ID de la cotización)', true)
+ON CONFLICT (config_key) DO NOTHING;
+
+COMMIT;
@@ -0,0 +1,50 @@
+-- Migration 036: Bandeja de atención humana (handoff)
+-- Hasta ahora escalar_a_humano solo mandaba un resumen al teléfono del asesor y el
+-- bot seguía respondiendo. Ahora cada escalado abre un handoff por conversación:
+--
+--   pending_human → el bot deja de responder y el caso espera en la bandeja
+--   human         → un admin lo tomó y responde desde el panel
+--   resolved      → cerrado por el admin o por inactividad; vuelve el bot
+--
+-- El estado activo también vive en Redis (handoff:<conversation_key>) para que el
+-- motor de conversación lo consulte sin ir a la BD en cada mensaje.
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS conversation_handoffs (
+    id               SERIAL PRIMARY KEY,
+    channel          VARCHAR(20) NOT NULL,
+    conversation_key VARCHAR(40) NOT NULL,
+    contact_id       VARCHAR(40) NOT NULL,
+    contact_name     VARCHAR(160),
+    contact_username VARCHAR(80),
+    phone            VARCHAR(20),
+    summary          TEXT,
+    status           VARCHAR(20) NOT NULL DEFAULT 'pending_human'
+                     CHECK (status IN ('pending_human', 'human', 'resolved')),
+    claimed_by       INTEGER REFERENCES users(id),
+    claimed_at       TIMESTAMPTZ,
+    last_activity_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    resolved_at      TIMESTAMPTZ,
+    resolved_reason  VARCHAR(20) CHECK (resolved_reason IN ('admin', 'timeout')),
+    created_at       TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at       TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Un solo handoff abierto por conversación
+CREATE UNIQUE INDEX IF NOT EXISTS idx_handoffs_open_key
+    ON conversation_handoffs (conversation_key) WHERE status <> 'resolved';
+CREATE INDEX IF NOT EXISTS idx_handoffs_status ON conversation_handoffs (status, last_activity_at DESC);
+
+COMMENT ON COLUMN conversation_handoffs.conversation_key IS 'Clave de la conversación: número en WhatsApp, tg:<chat_id> en Telegram (= whatsapp_conversations.phone)';
+COMMENT ON COLUMN conversation_handoffs.contact_id IS 'ID nativo del contacto en el canal, para responderle';
+
+INSERT INTO system_config (config_key, config_value, value_type, category, description, is_active) VALUES
+('handoff_inactividad_min', '60', 'number', 'operational',
+ 'Minutos sin mensajes del cliente ni del asesor antes de devolver la conversación al bot', true)
+ON CONFLICT (config_key) DO NOTHING;
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON conversation_handoffs TO fabricalaser;
+GRANT USAGE, SELECT ON SEQUENCE conversation_handoffs_id_seq TO fabricalaser;
+
+COMMIT;
@@ -0,0 +1,62 @@
+-- Migration 037: Notificaciones proactivas (cotizaciones y pedidos)
+-- Hasta ahora nada le avisaba al cliente cuando un admin aprobaba una cotización
+-- needs_review o cuando una cotización estaba por vencer.
+--
+-- 1. users: canal preferido (email, whatsapp, telegram), opt-out y chat de Telegram
+-- 2. notification_deliveries: bitácora de envíos con reintentos
+-- 3. system_config: horas de anticipación del aviso de vencimiento
+--
+-- WhatsApp solo permite escribir fuera de la ventana de 24h con plantillas aprobadas
+-- en Meta Business Manager: cotizacion_creada, cotizacion_aprobada,
+-- cotizacion_rechazada, cotizacion_por_vencer y pedido_actualizado, con las
+-- variables {{1}} = nombre, {{2}} = número de cotización y {{3}} = detalle.
+
+BEGIN;
+
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS notif_channel VARCHAR(20) NOT NULL DEFAULT 'email'
+        CHECK (notif_channel IN ('email', 'whatsapp', 'telegram')),
+    ADD COLUMN IF NOT EXISTS notif_opt_out BOOLEAN NOT NULL DEFAULT false,
+    ADD COLUMN IF NOT EXISTS telegram_chat_id BIGINT;
+
+COMMENT ON COLUMN users.notif_channel IS 'Canal preferido para avisos de cotizaciones y pedidos; si no hay destinatario en ese canal se usa email';
+COMMENT ON COLUMN users.notif_opt_out IS 'true = el cliente no quiere avisos proactivos';
+
+CREATE TABLE IF NOT EXISTS notification_deliveries (
+    id              SERIAL PRIMARY KEY,
+    event_type      VARCHAR(40) NOT NULL,
+    user_id         INTEGER NOT NULL REFERENCES users(id),
+    quote_id        INTEGER REFERENCES quotes(id),
+    channel         VARCHAR(20) NOT NULL CHECK (channel IN ('email', 'whatsapp', 'telegram')),
+    recipient       VARCHAR(255) NOT NULL,
+    subject         VARCHAR(200) NOT NULL,
+    body            TEXT NOT NULL,
+    link            TEXT,
+    template        VARCHAR(60),
+    params          JSONB NOT NULL DEFAULT '[]',
+    status          VARCHAR(20) NOT NULL DEFAULT 'pending'
+                    CHECK (status IN ('pending', 'sent', 'failed', 'skipped')),
+    attempts        INTEGER NOT NULL DEFAULT 0,
+    last_error      TEXT,
+    next_attempt_at TIMESTAMPTZ,
+    sent_at         TIMESTAMPTZ,
+    created_at      TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at      TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_notification_deliveries_due
+    ON notification_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_notification_deliveries_quote
+    ON notification_deliveries (quote_id, event_type);
+
+COMMENT ON TABLE notification_deliveries IS 'Un registro por aviso y destinatario; pending se reintenta con espera creciente hasta 5 intentos';
+
+INSERT INTO system_config (config_key, config_value, value_type, category, description, is_active) VALUES
+('notif_vencimiento_horas', '48', 'number', 'cotizacion',
+ 'Horas antes del vencimiento de una cotización aprobada en que se avisa al cliente', true)
+ON CONFLICT (config_key) DO NOTHING;
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON notification_deliveries TO fabricalaser;
+GRANT USAGE, SELECT ON SEQUENCE notification_deliveries_id_seq TO fabricalaser;
+
+COMMIT;
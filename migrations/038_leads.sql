@@ -0,0 +1,67 @@
+-- Migration 038: Leads y embudo de ventas de las conversaciones del bot
+-- whatsapp_conversations guarda los mensajes pero no dice qué chats terminaron en
+-- cotización o pedido. Cada contacto de WhatsApp o Telegram es ahora un lead,
+-- identificado por la misma clave de conversación (número o tg:<chat_id>):
+--
+--   new       → primer contacto
+--   quoted    → el bot le dio un precio (calcular_cotizacion, cotizar_archivo, vectorizar_imagen)
+--   hot       → el bot lo escaló a un asesor (escalar_a_humano)
+--   converted → una cotización de su cuenta se convirtió en pedido
+--
+-- La etapa solo avanza. Cada hito queda con su fecha (quoted_at, escalated_at,
+-- converted_at) para el reporte de embudo por canal y semana.
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS leads (
+    id               SERIAL PRIMARY KEY,
+    channel          VARCHAR(20) NOT NULL,
+    conversation_key VARCHAR(40) NOT NULL UNIQUE,
+    contact_id       VARCHAR(40) NOT NULL,
+    contact_name     VARCHAR(160),
+    contact_username VARCHAR(80),
+    phone            VARCHAR(20),
+    user_id          INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    stage            VARCHAR(20) NOT NULL DEFAULT 'new'
+                     CHECK (stage IN ('new', 'quoted', 'hot', 'converted')),
+    message_count    INTEGER NOT NULL DEFAULT 0,
+    first_contact_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_contact_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    quoted_at        TIMESTAMPTZ,
+    escalated_at     TIMESTAMPTZ,
+    converted_at     TIMESTAMPTZ,
+    created_at       TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at       TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_leads_stage ON leads (stage, last_contact_at DESC);
+CREATE INDEX IF NOT EXISTS idx_leads_first_contact ON leads (first_contact_at);
+CREATE INDEX IF NOT EXISTS idx_leads_user ON leads (user_id) WHERE user_id IS NOT NULL;
+
+-- Cotizaciones hechas por la cuenta del lead después de su primer contacto
+CREATE TABLE IF NOT EXISTS lead_quotes (
+    lead_id    INTEGER NOT NULL REFERENCES leads(id) ON DELETE CASCADE,
+    quote_id   INTEGER NOT NULL REFERENCES quotes(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (lead_id, quote_id)
+);
+
+COMMENT ON COLUMN leads.conversation_key IS 'Clave de la conversación: número en WhatsApp, tg:<chat_id> en Telegram (= whatsapp_conversations.phone)';
+
+-- Contactos anteriores a esta migración: un lead por conversación archivada
+INSERT INTO leads (channel, conversation_key, contact_id, phone, message_count, first_contact_at, last_contact_at)
+SELECT CASE WHEN phone LIKE 'tg:%' THEN 'telegram' ELSE 'whatsapp' END,
+       phone,
+       CASE WHEN phone LIKE 'tg:%' THEN SUBSTRING(phone FROM 4) ELSE phone END,
+       CASE WHEN phone LIKE 'tg:%' THEN NULL ELSE phone END,
+       COUNT(*) FILTER (WHERE role = 'user'),
+       MIN(created_at),
+       MAX(created_at)
+FROM whatsapp_conversations
+GROUP BY phone
+ON CONFLICT (conversation_key) DO NOTHING;
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON leads, lead_quotes TO fabricalaser;
+GRANT USAGE, SELECT ON SEQUENCE leads_id_seq TO fabricalaser;
+
+COMMIT;
@@ -0,0 +1,27 @@
+-- Migration 039: Borradores de cotización desde las conversaciones del bot
+-- Cuando el bot calcula un precio (calcular_cotizacion) o escala la conversación,
+-- los datos del trabajo (material, grosor, medidas, cantidad, tecnología, blank)
+-- quedan en una cotización en estado draft ligada al lead, para que el asesor la
+-- abra, ajuste y apruebe sin releer todo el chat.
+--
+-- Un borrador del bot puede no tener cuenta (contacto sin registrar) ni archivo
+-- (las medidas vienen de la conversación): user_id y svg_analysis_id pasan a ser
+-- opcionales. El admin asigna el cliente antes de aprobar.
+
+BEGIN;
+
+ALTER TABLE quotes ALTER COLUMN user_id DROP NOT NULL;
+ALTER TABLE quotes ALTER COLUMN svg_analysis_id DROP NOT NULL;
+
+ALTER TABLE quotes
+    ADD COLUMN IF NOT EXISTS source VARCHAR(20) NOT NULL DEFAULT 'web'
+        CHECK (source IN ('web', 'bot')),
+    ADD COLUMN IF NOT EXISTS lead_id INTEGER REFERENCES leads(id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS request_params JSONB;
+
+CREATE INDEX IF NOT EXISTS idx_quotes_lead ON quotes (lead_id) WHERE lead_id IS NOT NULL;
+
+COMMENT ON COLUMN quotes.source IS 'Origen: web (cotizador con archivo) o bot (borrador armado desde WhatsApp/Telegram)';
+COMMENT ON COLUMN quotes.request_params IS 'Parámetros del trabajo tal como se extrajeron de la conversación (medidas, blank, resumen)';
+
+COMMIT;
@@ -0,0 +1,85 @@
+-- Migration 040: Analítica de conversaciones del bot
+-- whatsapp_conversations guarda los mensajes (WhatsApp, Telegram y chat web) pero
+-- no qué tools llamó el agente ni qué contactos rechazó el rate limiter; esos
+-- hechos quedan en bot_events.
+--
+-- Un job nocturno resume cada día (hora Costa Rica) en tablas de rollup por canal,
+-- así el dashboard no recorre el archivo completo en cada consulta:
+--   conversation_stats_daily   mensajes, contactos, latencia, escalados, límites
+--   conversation_tool_daily    llamadas por tool
+--   conversation_hourly        mensajes de clientes por hora (horas pico)
+--   conversation_topics_daily  materiales y blanks mencionados por los clientes
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS bot_events (
+    id               BIGSERIAL PRIMARY KEY,
+    kind             VARCHAR(20) NOT NULL CHECK (kind IN ('tool_call', 'rate_limit')),
+    channel          VARCHAR(20) NOT NULL,
+    conversation_key VARCHAR(40) NOT NULL,
+    name             VARCHAR(60),
+    ok               BOOLEAN NOT NULL DEFAULT true,
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_bot_events_created ON bot_events (created_at);
+
+COMMENT ON COLUMN bot_events.kind IS 'tool_call: el agente ejecutó una tool; rate_limit: el límite diario de conversaciones rechazó un contacto nuevo';
+COMMENT ON COLUMN bot_events.name IS 'Nombre de la tool (tool_call)';
+COMMENT ON COLUMN bot_events.ok IS 'false si la tool falló o no cumplió (error o envío fallido)';
+
+CREATE TABLE IF NOT EXISTS conversation_stats_daily (
+    day               DATE NOT NULL,
+    channel           VARCHAR(20) NOT NULL,
+    messages          INTEGER NOT NULL DEFAULT 0,
+    user_messages     INTEGER NOT NULL DEFAULT 0,
+    contacts          INTEGER NOT NULL DEFAULT 0,
+    responses         INTEGER NOT NULL DEFAULT 0,
+    avg_response_secs NUMERIC(10,2) NOT NULL DEFAULT 0,
+    p90_response_secs NUMERIC(10,2) NOT NULL DEFAULT 0,
+    escalations       INTEGER NOT NULL DEFAULT 0,
+    limit_hits        INTEGER NOT NULL DEFAULT 0,
+    tool_calls        INTEGER NOT NULL DEFAULT 0,
+    rolled_up_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (day, channel)
+);
+
+COMMENT ON COLUMN conversation_stats_daily.responses IS 'Respuestas del bot medidas (turno del bot precedido por uno del cliente)';
+COMMENT ON COLUMN conversation_stats_daily.avg_response_secs IS 'Latencia promedio del bot en segundos, desde que llega el mensaje hasta que se responde';
+
+CREATE TABLE IF NOT EXISTS conversation_tool_daily (
+    day      DATE NOT NULL,
+    channel  VARCHAR(20) NOT NULL,
+    tool     VARCHAR(60) NOT NULL,
+    calls    INTEGER NOT NULL DEFAULT 0,
+    failures INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (day, channel, tool)
+);
+
+CREATE TABLE IF NOT EXISTS conversation_hourly (
+    day      DATE NOT NULL,
+    channel  VARCHAR(20) NOT NULL,
+    hour     SMALLINT NOT NULL CHECK (hour BETWEEN 0 AND 23),
+    messages INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (day, channel, hour)
+);
+
+CREATE TABLE IF NOT EXISTS conversation_topics_daily (
+    day      DATE NOT NULL,
+    channel  VARCHAR(20) NOT NULL,
+    kind     VARCHAR(20) NOT NULL CHECK (kind IN ('material', 'blank')),
+    ref_id   INTEGER NOT NULL,
+    name     VARCHAR(160) NOT NULL,
+    mentions INTEGER NOT NULL DEFAULT 0,
+    contacts INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (day, channel, kind, ref_id)
+);
+
+COMMENT ON COLUMN conversation_topics_daily.mentions IS 'Mensajes de clientes que nombran el material o blank (nombre o alias)';
+COMMENT ON COLUMN conversation_topics_daily.contacts IS 'Contactos distintos que lo nombraron en el día';
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON bot_events, conversation_stats_daily, conversation_tool_daily,
+    conversation_hourly, conversation_topics_daily TO fabricalaser;
+GRANT USAGE, SELECT ON SEQUENCE bot_events_id_seq TO fabricalaser;
+
+COMMIT;
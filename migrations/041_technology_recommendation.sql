@@ -0,0 +1,46 @@
+-- Migration 041: Preferencias para el recomendador de tecnología
+-- El "árbol de decisión" (qué tecnología graba o corta cada material) vivía como
+-- texto en los prompts de los agentes y se desfasaba de tech_material_speeds.
+-- El recomendador (pricing.Recommender) lo arma con los datos de la matriz; solo
+-- le faltaba saber qué tecnología se prefiere cuando varias son compatibles:
+--   engrave_rank   orden de preferencia para grabar el material (menor = primero)
+--   color_marking  la tecnología respeta o genera color (anodizado, recubrimientos)
+-- Los valores se ajustan por fila con PUT /api/v1/admin/tech-material-speeds/{id}.
+
+BEGIN;
+
+ALTER TABLE tech_material_speeds
+    ADD COLUMN IF NOT EXISTS engrave_rank SMALLINT NOT NULL DEFAULT 100,
+    ADD COLUMN IF NOT EXISTS color_marking BOOLEAN NOT NULL DEFAULT false;
+
+COMMENT ON COLUMN tech_material_speeds.engrave_rank IS 'Preferencia para grabar el material con esta tecnología (menor = primero)';
+COMMENT ON COLUMN tech_material_speeds.color_marking IS 'La tecnología conserva o genera color en el acabado (anodizado, recubrimiento)';
+
+-- Orgánicos: CO2 graba y corta
+UPDATE tech_material_speeds s SET engrave_rank = 1, updated_at = NOW()
+FROM technologies t, materials m
+WHERE s.technology_id = t.id AND s.material_id = m.id
+  AND t.code = 'CO2' AND m.category IN ('madera', 'cuero');
+
+-- Acrílico (cualquier color) y plásticos: UV graba; CO2 queda de alternativa
+UPDATE tech_material_speeds s SET engrave_rank = CASE t.code WHEN 'UV' THEN 1 WHEN 'CO2' THEN 2 ELSE engrave_rank END,
+    updated_at = NOW()
+FROM technologies t, materials m
+WHERE s.technology_id = t.id AND s.material_id = m.id
+  AND m.category IN ('acrilico', 'plastico');
+
+-- Vidrio y cerámica: UV
+UPDATE tech_material_speeds s SET engrave_rank = 1, updated_at = NOW()
+FROM technologies t, materials m
+WHERE s.technology_id = t.id AND s.material_id = m.id
+  AND t.code = 'UV' AND m.category IN ('vidrio', 'ceramica');
+
+-- Metales: Fibra sin color especial; MOPA para acabados de color
+UPDATE tech_material_speeds s SET engrave_rank = CASE t.code WHEN 'FIBRA' THEN 1 WHEN 'MOPA' THEN 2 ELSE engrave_rank END,
+    color_marking = (t.code = 'MOPA'),
+    updated_at = NOW()
+FROM technologies t, materials m
+WHERE s.technology_id = t.id AND s.material_id = m.id
+  AND m.category IN ('metal', 'metal_puro');
+
+COMMIT;
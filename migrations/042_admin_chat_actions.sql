@@ -0,0 +1,43 @@
+-- Migration 042: Acciones del chat administrativo con confirmación
+-- El asistente de gestores deja de ser solo de consulta: puede crear cotizaciones,
+-- aprobar/rechazar las que están en revisión, ajustar stock de blanks, ampliar la
+-- cuota de un cliente y registrar clientes nuevos. Protocolo en dos fases:
+--   1. El modelo propone la acción (status pending); la UI muestra una tarjeta.
+--   2. El gestor confirma o cancela con POST /api/v1/admin/chat/actions/{id}/...
+-- Solo la confirmación ejecuta. La tabla es también la bitácora de auditoría: el
+-- id de la acción viaja en el ToolCallTrace guardado en admin_chat_messages.tool_calls.
+--
+-- Las cotizaciones creadas desde el chat quedan con source = 'admin'.
+
+BEGIN;
+
+CREATE TABLE admin_chat_actions (
+    id          UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    session_id  UUID REFERENCES admin_chat_sessions(id) ON DELETE SET NULL,
+    admin_id    INTEGER NOT NULL REFERENCES users(id),
+    tool        VARCHAR(50) NOT NULL,
+    args        JSONB NOT NULL DEFAULT '{}',
+    summary     TEXT NOT NULL,
+    status      VARCHAR(20) NOT NULL DEFAULT 'pending'
+                CHECK (status IN ('pending', 'executed', 'failed', 'cancelled', 'expired')),
+    result      JSONB,
+    error       TEXT,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    decided_at  TIMESTAMPTZ
+);
+
+CREATE INDEX idx_admin_chat_actions_session
+    ON admin_chat_actions (session_id, created_at);
+
+CREATE INDEX idx_admin_chat_actions_admin
+    ON admin_chat_actions (admin_id, created_at DESC);
+
+COMMENT ON TABLE admin_chat_actions IS 'Acciones de escritura propuestas por el asistente admin y su resultado (auditoría)';
+COMMENT ON COLUMN admin_chat_actions.summary IS 'Texto de la tarjeta de confirmación que vio el gestor';
+
+ALTER TABLE quotes DROP CONSTRAINT IF EXISTS quotes_source_check;
+ALTER TABLE quotes ADD CONSTRAINT quotes_source_check CHECK (source IN ('web', 'bot', 'admin'));
+
+GRANT INSERT, SELECT, UPDATE ON admin_chat_actions TO fabricalaser;
+
+COMMIT;
@@ -0,0 +1,64 @@
+-- Migration 043: Base de conocimiento para los agentes
+-- Artículos de negocio (cuidado de piezas, envíos y retiro, tiempos de producción,
+-- preparación de archivos, preguntas frecuentes) que los agentes de WhatsApp,
+-- Telegram, chat web y chat admin consultan con la tool buscar_conocimiento en
+-- lugar de llevarlos completos en el system prompt.
+--
+-- Los artículos se parten en pasajes y se indexan en memoria (TF-IDF, ver
+-- internal/services/knowledge); la tabla solo guarda el texto fuente.
+-- audience = 'internal' los deja visibles solo para el chat admin.
+
+BEGIN;
+
+CREATE TABLE kb_articles (
+    id          SERIAL PRIMARY KEY,
+    title       VARCHAR(160) NOT NULL,
+    category    VARCHAR(40) NOT NULL DEFAULT 'faq',
+    content     TEXT NOT NULL,
+    tags        JSONB NOT NULL DEFAULT '[]',
+    audience    VARCHAR(20) NOT NULL DEFAULT 'public'
+                CHECK (audience IN ('public', 'internal')),
+    is_active   BOOLEAN NOT NULL DEFAULT true,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_kb_articles_active ON kb_articles (is_active, category);
+
+COMMENT ON TABLE kb_articles IS 'Base de conocimiento que los agentes citan con buscar_conocimiento';
+COMMENT ON COLUMN kb_articles.content IS 'Texto del artículo; los encabezados markdown (#) delimitan las secciones que se citan';
+COMMENT ON COLUMN kb_articles.tags IS 'Sinónimos y palabras clave que se suman al índice. Ej: ["correos", "encomienda"]';
+
+-- Lo que hoy está repetido en los prompts de los agentes
+INSERT INTO kb_articles (title, category, content, tags) VALUES
+('Retiro en taller y envíos', 'envios',
+'# Retiro en taller
+El taller está en Avenida 67, San Jerónimo, Tibás, San José (código postal 11301). Google Maps: https://maps.app.goo.gl/DY5kv5QwCwBCo3kJ7
+El retiro es solo con cita previa coordinada por WhatsApp o Telegram, con día y hora confirmados. No se puede llegar sin cita porque el encargado puede no estar disponible.
+
+# Envíos
+Enviamos a todo el país por Correos de Costa Rica o mensajería. La tarifa es ₡3.500 por el primer kilo, que cubre la mayoría de pedidos de llaveros y medallas.
+El costo del envío lo asume el cliente y se coordina al confirmar el pedido. No hacemos entregas a domicilio por cuenta propia.',
+'["envio", "correos", "mensajeria", "encomienda", "domicilio", "direccion", "ubicacion", "retirar", "cita"]'),
+
+('Tiempo de producción', 'tiempos',
+'# Tiempo de producción
+Los pedidos se procesan en 1 día hábil desde que se confirma el pago. Aplica para llaveros, medallas y trabajos estándar; los diseños muy complejos o los productos ensamblados pueden requerir coordinación adicional con el asesor.
+Las fechas de entrega específicas las confirma el asesor al coordinar el pedido.',
+'["entrega", "cuanto tarda", "plazo", "dias", "listo", "urgente"]'),
+
+('Preparación del archivo de diseño', 'archivos',
+'# Formato del archivo
+El cotizador web y el bot trabajan con archivos SVG (también DXF por mensajería). Las medidas se toman del archivo, así que conviene exportarlo a tamaño real.
+
+# Colores del SVG
+Las líneas azules se graban en modo vectorial (el láser sigue el contorno), las áreas negras se graban rasterizadas (barrido línea por línea, para rellenos, fotos y degradados) y las líneas rojas se cortan.
+
+# Sin archivo vectorial
+Si el cliente no tiene el diseño en SVG o vectorial, se cobra la vectorización aparte (ver el costo de vectorización en la configuración). Por mensajería el bot puede vectorizar una imagen del logo para dar un precio de referencia.',
+'["svg", "dxf", "vector", "vectorizar", "logo", "diseño", "archivo", "illustrator", "corel", "inkscape"]');
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON kb_articles TO fabricalaser;
+GRANT USAGE, SELECT ON SEQUENCE kb_articles_id_seq TO fabricalaser;
+
+COMMIT;
@@ -0,0 +1,45 @@
+-- Migration 044: Memoria de largo plazo de los clientes del bot
+-- El historial Redis ({ns}:hist:{key}) dura 4 horas: un cliente que vuelve al día
+-- siguiente tiene que explicar de nuevo su negocio. Cuando una sesión termina
+-- (4 horas sin mensajes o cambio de día en Costa Rica) el job de memoria resume
+-- los turnos archivados en whatsapp_conversations y los funde en un registro por
+-- contacto: preferencias, productos pedidos, materiales habituales y preguntas
+-- abiertas. En el próximo contacto el motor lo suma a DATOS DEL CLIENTE.
+--
+-- summarized_through marca el último turno archivado ya resumido. Borrar la
+-- memoria desde el panel (pedido de privacidad) vacía el contenido pero deja la
+-- fila con summarized_through = NOW() para que el job no la reconstruya con los
+-- mensajes viejos; opted_out = true además deja de guardar memoria del contacto.
+
+BEGIN;
+
+CREATE TABLE customer_memories (
+    id                 SERIAL PRIMARY KEY,
+    conversation_key   VARCHAR(40) NOT NULL UNIQUE,
+    channel            VARCHAR(20) NOT NULL,
+    summary            TEXT NOT NULL DEFAULT '',
+    preferences        JSONB NOT NULL DEFAULT '[]',
+    past_products      JSONB NOT NULL DEFAULT '[]',
+    typical_materials  JSONB NOT NULL DEFAULT '[]',
+    open_questions     JSONB NOT NULL DEFAULT '[]',
+    last_session       TEXT NOT NULL DEFAULT '',
+    sessions           INTEGER NOT NULL DEFAULT 0,
+    summarized_through TIMESTAMPTZ NOT NULL,
+    opted_out          BOOLEAN NOT NULL DEFAULT false,
+    edited_by          INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    edited_at          TIMESTAMPTZ,
+    created_at         TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at         TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_customer_memories_updated ON customer_memories (updated_at DESC);
+
+COMMENT ON TABLE customer_memories IS 'Memoria de largo plazo por contacto del bot (WhatsApp, Telegram), resumida al cerrar cada sesión';
+COMMENT ON COLUMN customer_memories.conversation_key IS 'Misma clave que whatsapp_conversations.phone: número o tg:<chat_id>';
+COMMENT ON COLUMN customer_memories.summarized_through IS 'created_at del último turno archivado incluido en la memoria';
+COMMENT ON COLUMN customer_memories.opted_out IS 'El cliente pidió que no se guarde memoria de sus conversaciones';
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON customer_memories TO fabricalaser;
+GRANT USAGE, SELECT ON SEQUENCE customer_memories_id_seq TO fabricalaser;
+
+COMMIT;
@@ -0,0 +1,86 @@
+-- Migration 045: Multi-tenant — varios talleres sobre el mismo despliegue
+-- Cada taller (tenant) tiene su propia configuración de precios, catálogo de
+-- blanks, usuarios, cotizaciones y conversaciones. El tenant de un request se
+-- resuelve por X-API-Key (hash SHA-256 en api_key_hash) o por el Host; un host
+-- desconocido cae en el tenant 1, que es FabricaLaser con todos los datos
+-- existentes (por eso tenant_id tiene DEFAULT 1).
+--
+-- El aislamiento por fila lo aplica el plugin GORM de internal/tenant sobre
+-- todo modelo con columna tenant_id. Las tablas derivadas del bot (handoffs,
+-- leads, borradores, memoria, analítica) siguen colgando de conversation_key y
+-- por ahora solo atienden al tenant 1.
+--
+-- branding: {"display_name", "logo_path", "web_dir", "quote_url"}
+-- credentials: tokens de WhatsApp Cloud API y Telegram del tenant; lo que falte
+-- se toma de las variables de entorno (solo para el tenant 1).
+
+BEGIN;
+
+CREATE TABLE tenants (
+    id           SERIAL PRIMARY KEY,
+    slug         VARCHAR(40) NOT NULL UNIQUE,
+    name         VARCHAR(120) NOT NULL,
+    hosts        JSONB NOT NULL DEFAULT '[]',
+    api_key_hash VARCHAR(64) UNIQUE,
+    branding     JSONB NOT NULL DEFAULT '{}',
+    digest_email VARCHAR(255) NOT NULL DEFAULT '',
+    credentials  JSONB NOT NULL DEFAULT '{}',
+    is_active    BOOLEAN NOT NULL DEFAULT true,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at   TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO tenants (id, slug, name, hosts, branding, digest_email) VALUES (
+    1, 'fabricalaser', 'FabricaLaser',
+    '["fabricalaser.com", "www.fabricalaser.com"]',
+    '{"display_name": "FabricaLaser", "logo_path": "/opt/FabricaLaser/web/logo.png", "web_dir": "/opt/FabricaLaser/web", "quote_url": "https://fabricalaser.com/cotizar/?cotizacion=%d"}',
+    'info@fabricalaser.com'
+);
+SELECT setval('tenants_id_seq', 1);
+
+-- Configuración de precios
+ALTER TABLE technologies         ADD COLUMN tenant_id INTEGER NOT NULL DEFAULT 1 REFERENCES tenants(id);
+ALTER TABLE tech_rates           ADD COLUMN tenant_id INTEGER NOT NULL DEFAULT 1 REFERENCES tenants(id);
+ALTER TABLE materials            ADD COLUMN tenant_id INTEGER NOT NULL DEFAULT 1 REFERENCES tenants(id);
+ALTER TABLE engrave_types        ADD COLUMN tenant_id INTEGER NOT NULL DEFAULT 1 REFERENCES tenants(id);
+ALTER TABLE volume_discounts     ADD COLUMN tenant_id INTEGER NOT NULL DEFAULT 1 REFERENCES tenants(id);
+ALTER TABLE price_references     ADD COLUMN tenant_id INTEGER NOT NULL DEFAULT 1 REFERENCES tenants(id);
+ALTER TABLE tech_material_speeds ADD COLUMN tenant_id INTEGER NOT NULL DEFAULT 1 REFERENCES tenants(id);
+ALTER TABLE material_costs       ADD COLUMN tenant_id INTEGER NOT NULL DEFAULT 1 REFERENCES tenants(id);
+ALTER TABLE system_config        ADD COLUMN tenant_id INTEGER NOT NULL DEFAULT 1 REFERENCES tenants(id);
+ALTER TABLE blanks               ADD COLUMN tenant_id INTEGER NOT NULL DEFAULT 1 REFERENCES tenants(id);
+
+-- Clientes, cotizaciones y conversaciones
+ALTER TABLE users                  ADD COLUMN tenant_id INTEGER NOT NULL DEFAULT 1 REFERENCES tenants(id);
+ALTER TABLE quotes                 ADD COLUMN tenant_id INTEGER NOT NULL DEFAULT 1 REFERENCES tenants(id);
+ALTER TABLE whatsapp_conversations ADD COLUMN tenant_id INTEGER NOT NULL DEFAULT 1 REFERENCES tenants(id);
+
+-- Las claves únicas pasan a ser por tenant
+ALTER TABLE technologies DROP CONSTRAINT technologies_code_key;
+ALTER TABLE technologies ADD CONSTRAINT uq_technologies_tenant_code UNIQUE (tenant_id, code);
+
+ALTER TABLE system_config DROP CONSTRAINT system_config_config_key_key;
+ALTER TABLE system_config ADD CONSTRAINT uq_system_config_tenant_key UNIQUE (tenant_id, config_key);
+
+ALTER TABLE materials DROP CONSTRAINT uq_materials_name_category;
+ALTER TABLE materials ADD CONSTRAINT uq_materials_tenant_name_category UNIQUE (tenant_id, name, category);
+
+DROP INDEX idx_users_cedula_unique;
+CREATE UNIQUE INDEX idx_users_cedula_unique ON users (tenant_id, cedula) WHERE password_hash IS NOT NULL;
+
+CREATE INDEX idx_technologies_tenant     ON technologies (tenant_id);
+CREATE INDEX idx_materials_tenant        ON materials (tenant_id);
+CREATE INDEX idx_blanks_tenant           ON blanks (tenant_id, category);
+CREATE INDEX idx_quotes_tenant_created   ON quotes (tenant_id, created_at DESC);
+CREATE INDEX idx_users_tenant            ON users (tenant_id);
+CREATE INDEX idx_wa_conv_tenant_phone    ON whatsapp_conversations (tenant_id, phone, created_at DESC);
+
+COMMENT ON TABLE tenants IS 'Talleres que comparten el despliegue; id 1 = FabricaLaser';
+COMMENT ON COLUMN tenants.hosts IS 'Hosts (sin puerto) que resuelven a este tenant';
+COMMENT ON COLUMN tenants.api_key_hash IS 'SHA-256 hex de la API key del tenant (header X-API-Key)';
+COMMENT ON COLUMN tenants.credentials IS 'Tokens de canales: whatsapp_phone_number_id, whatsapp_access_token, whatsapp_app_secret, whatsapp_verify_token, telegram_bot_token';
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON tenants TO fabricalaser;
+GRANT USAGE, SELECT ON SEQUENCE tenants_id_seq TO fabricalaser;
+
+COMMIT;
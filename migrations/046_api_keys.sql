@@ -0,0 +1,64 @@
+-- Migration 046: API pública con API keys por cliente
+-- Los revendedores integran el cotizador en sus tiendas (plugins de e-commerce,
+-- sistemas propios). Hasta ahora el único endpoint de máquina era
+-- /quotes/estimate con el INTERNAL_API_TOKEN compartido. Cada cliente recibe
+-- ahora su propia key bajo /api/public/v1:
+--
+--   flk_<prefix>_<secreto>   prefix (8 hex) identifica la key; del secreto solo
+--                            se guarda el SHA-256 de la key completa
+--
+-- Cada key pertenece a un tenant y a la cuenta (users) del cliente: los análisis
+-- y cotizaciones creados por la API quedan a nombre de esa cuenta. Los scopes
+-- limitan qué endpoints puede llamar (estimate, analyze, create-quote,
+-- catalog-read) y rate_limit_per_minute el ritmo (contador Redis por minuto).
+--
+-- Rotación: la key nueva copia cliente, scopes y límites (rotated_from_id) y la
+-- anterior sigue valiendo hasta expires_at para que el cliente cambie la key
+-- sin cortar el servicio.
+--
+-- api_key_usage mide el uso por key, día y endpoint (requests y errores 4xx/5xx).
+
+BEGIN;
+
+CREATE TABLE api_keys (
+    id                    SERIAL PRIMARY KEY,
+    tenant_id             INTEGER NOT NULL DEFAULT 1 REFERENCES tenants(id),
+    user_id               INTEGER NOT NULL REFERENCES users(id),
+    name                  VARCHAR(120) NOT NULL,
+    prefix                VARCHAR(20) NOT NULL UNIQUE,
+    key_hash              VARCHAR(64) NOT NULL,
+    scopes                JSONB NOT NULL DEFAULT '[]',
+    rate_limit_per_minute INTEGER NOT NULL DEFAULT 60,
+    is_active             BOOLEAN NOT NULL DEFAULT true,
+    expires_at            TIMESTAMPTZ,
+    last_used_at          TIMESTAMPTZ,
+    rotated_from_id       INTEGER REFERENCES api_keys(id) ON DELETE SET NULL,
+    created_by            INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at            TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at            TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_api_keys_tenant ON api_keys (tenant_id, created_at DESC);
+
+CREATE TABLE api_key_usage (
+    api_key_id INTEGER NOT NULL REFERENCES api_keys(id) ON DELETE CASCADE,
+    day        DATE NOT NULL,
+    endpoint   VARCHAR(60) NOT NULL,
+    requests   INTEGER NOT NULL DEFAULT 0,
+    errors     INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (api_key_id, day, endpoint)
+);
+
+COMMENT ON TABLE api_keys IS 'API keys de clientes B2B para /api/public/v1';
+COMMENT ON COLUMN api_keys.prefix IS 'Parte visible de la key (flk_<8 hex>), usada para buscarla';
+COMMENT ON COLUMN api_keys.key_hash IS 'SHA-256 hex de la key completa; la key en claro se muestra una sola vez';
+COMMENT ON COLUMN api_keys.user_id IS 'Cuenta del cliente, dueña de los análisis y cotizaciones creados por la API';
+COMMENT ON COLUMN api_keys.scopes IS 'Scopes permitidos: estimate, analyze, create-quote, catalog-read';
+COMMENT ON COLUMN api_keys.expires_at IS 'Fin de validez; al rotar, la key anterior vence tras el periodo de gracia';
+COMMENT ON TABLE api_key_usage IS 'Uso diario por API key y endpoint (metering)';
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON api_keys TO fabricalaser;
+GRANT SELECT, INSERT, UPDATE, DELETE ON api_key_usage TO fabricalaser;
+GRANT USAGE, SELECT ON SEQUENCE api_keys_id_seq TO fabricalaser;
+
+COMMIT;
@@ -0,0 +1,78 @@
+-- Migration 047: Webhooks salientes del ciclo de vida de cotizaciones y pedidos
+-- Socios B2B y automatizaciones propias reaccionan cuando se crea o aprueba una
+-- cotización o cambia un pedido, sin consultar la API. Cada suscripción es de un
+-- tenant y, opcionalmente, de una API key: en ese caso solo recibe los eventos
+-- de las cotizaciones de la cuenta de esa key.
+--
+-- Cada entrega es un POST JSON firmado con el secreto del endpoint, con el mismo
+-- esquema que verificamos en los webhooks de Meta:
+--
+--   X-Hub-Signature-256: sha256=<HMAC-SHA256 hex del cuerpo>
+--   X-FabricaLaser-Event: quote.created
+--   X-FabricaLaser-Delivery: <event_id>   (igual en reintentos y reenvíos)
+--
+-- Una respuesta 2xx es entrega exitosa. Las fallidas se reintentan con espera
+-- exponencial (30s, 1m, 2m, ... hasta 6h) hasta 8 intentos. Un endpoint con 15
+-- intentos fallidos seguidos se deshabilita solo; al reactivarlo desde el panel
+-- se reinicia el contador. El panel puede reenviar cualquier entrega (replay).
+
+BEGIN;
+
+CREATE TABLE webhook_endpoints (
+    id                   SERIAL PRIMARY KEY,
+    tenant_id            INTEGER NOT NULL DEFAULT 1 REFERENCES tenants(id),
+    api_key_id           INTEGER REFERENCES api_keys(id) ON DELETE CASCADE,
+    url                  TEXT NOT NULL,
+    secret               VARCHAR(80) NOT NULL,
+    events               JSONB NOT NULL DEFAULT '[]',
+    description          VARCHAR(200) NOT NULL DEFAULT '',
+    is_active            BOOLEAN NOT NULL DEFAULT true,
+    consecutive_failures INTEGER NOT NULL DEFAULT 0,
+    disabled_at          TIMESTAMPTZ,
+    disabled_reason      TEXT,
+    created_by           INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at           TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at           TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_webhook_endpoints_tenant ON webhook_endpoints (tenant_id) WHERE is_active;
+
+CREATE TABLE webhook_deliveries (
+    id              SERIAL PRIMARY KEY,
+    tenant_id       INTEGER NOT NULL DEFAULT 1 REFERENCES tenants(id),
+    endpoint_id     INTEGER NOT NULL REFERENCES webhook_endpoints(id) ON DELETE CASCADE,
+    event_id        VARCHAR(40) NOT NULL,
+    event_type      VARCHAR(40) NOT NULL,
+    quote_id        INTEGER REFERENCES quotes(id) ON DELETE SET NULL,
+    payload         JSONB NOT NULL,
+    status          VARCHAR(20) NOT NULL DEFAULT 'pending'
+                    CHECK (status IN ('pending', 'sent', 'failed')),
+    attempts        INTEGER NOT NULL DEFAULT 0,
+    response_status INTEGER,
+    response_body   TEXT,
+    last_error      TEXT,
+    next_attempt_at TIMESTAMPTZ,
+    delivered_at    TIMESTAMPTZ,
+    replay_of_id    INTEGER REFERENCES webhook_deliveries(id) ON DELETE SET NULL,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_webhook_deliveries_due
+    ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX idx_webhook_deliveries_endpoint ON webhook_deliveries (endpoint_id, created_at DESC);
+
+COMMENT ON TABLE webhook_endpoints IS 'Suscripciones a eventos de cotizaciones y pedidos por tenant o API key';
+COMMENT ON COLUMN webhook_endpoints.secret IS 'Secreto HMAC-SHA256 de la firma X-Hub-Signature-256';
+COMMENT ON COLUMN webhook_endpoints.events IS 'Tipos de evento suscritos; [] = todos';
+COMMENT ON COLUMN webhook_endpoints.api_key_id IS 'NULL = todo el tenant; si no, solo cotizaciones de la cuenta de la key';
+COMMENT ON COLUMN webhook_endpoints.consecutive_failures IS 'Intentos fallidos seguidos; 15 deshabilita el endpoint';
+COMMENT ON TABLE webhook_deliveries IS 'Bitácora de entregas de webhooks con reintentos y reenvíos';
+COMMENT ON COLUMN webhook_deliveries.event_id IS 'Id del evento para deduplicar en el receptor (X-FabricaLaser-Delivery)';
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON webhook_endpoints TO fabricalaser;
+GRANT SELECT, INSERT, UPDATE, DELETE ON webhook_deliveries TO fabricalaser;
+GRANT USAGE, SELECT ON SEQUENCE webhook_endpoints_id_seq TO fabricalaser;
+GRANT USAGE, SELECT ON SEQUENCE webhook_deliveries_id_seq TO fabricalaser;
+
+COMMIT;
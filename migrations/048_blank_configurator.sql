@@ -0,0 +1,46 @@
+-- Migration 048: Configurador de blanks con grabado
+-- Hasta ahora consultar_blank solo devolvía la tabla de precios y el agente
+-- sumaba accesorios y grabado a mano. El configurador calcula todo en el
+-- servidor: precio unitario por tramo (price_breaks), accesorios por paquete
+-- (min_qty_pack), grabado con el motor de pricing y mínimo de unidades.
+--
+-- Para cotizar el grabado cada blank indica su material, grosor y tecnología.
+-- Un blank sin material_id se vende sin grabado. engrave_max_*_cm limita el
+-- área grabable por cara (0 = sin límite) y max_sides las caras grabables.
+
+BEGIN;
+
+ALTER TABLE blanks
+    ADD COLUMN material_id          INTEGER REFERENCES materials(id) ON DELETE SET NULL,
+    ADD COLUMN technology_id        INTEGER REFERENCES technologies(id) ON DELETE SET NULL,
+    ADD COLUMN thickness            NUMERIC(5,2) NOT NULL DEFAULT 0,
+    ADD COLUMN engrave_max_alto_cm  NUMERIC(5,2) NOT NULL DEFAULT 0,
+    ADD COLUMN engrave_max_ancho_cm NUMERIC(5,2) NOT NULL DEFAULT 0,
+    ADD COLUMN max_sides            INTEGER NOT NULL DEFAULT 1 CHECK (max_sides IN (1, 2));
+
+COMMENT ON COLUMN blanks.material_id IS 'Material del blank para cotizar el grabado; NULL = sin grabado';
+COMMENT ON COLUMN blanks.technology_id IS 'Tecnología con que se graba el blank';
+COMMENT ON COLUMN blanks.engrave_max_alto_cm IS 'Alto máximo grabable por cara en cm; 0 = sin límite';
+COMMENT ON COLUMN blanks.engrave_max_ancho_cm IS 'Ancho máximo grabable por cara en cm; 0 = sin límite';
+COMMENT ON COLUMN blanks.max_sides IS 'Caras grabables (1 o 2)';
+
+-- Backfill de los blanks de acrílico del seed: acrílico 3mm grabado con CO2,
+-- por las dos caras, hasta el tamaño de la pieza
+UPDATE blanks b
+SET material_id = m.id,
+    technology_id = t.id,
+    thickness = 3,
+    max_sides = 2
+FROM materials m, technologies t
+WHERE b.name ILIKE '%acrílico%'
+  AND m.name = 'Acrílico transparente' AND m.tenant_id = b.tenant_id
+  AND t.code = 'CO2' AND t.tenant_id = b.tenant_id
+  AND b.material_id IS NULL;
+
+UPDATE blanks SET engrave_max_alto_cm = 5, engrave_max_ancho_cm = 5
+WHERE name = 'Llavero acrílico 5cm' AND engrave_max_alto_cm = 0;
+
+UPDATE blanks SET engrave_max_alto_cm = 7, engrave_max_ancho_cm = 7
+WHERE name = 'Medalla acrílico 7cm' AND engrave_max_alto_cm = 0;
+
+COMMIT;
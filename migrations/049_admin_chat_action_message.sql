@@ -0,0 +1,18 @@
+-- Migration 049: Enlace de cada acción del chat admin con el mensaje que la propuso
+-- Hasta ahora la única relación entre admin_chat_actions y la conversación era
+-- el action_id dentro del JSON de admin_chat_messages.tool_calls. Para auditar
+-- qué respuesta del asistente propuso cada acción hace falta una columna real:
+-- message_id apunta al turno model que lleva el tool call. Se completa al
+-- guardar ese turno, en la misma transacción.
+
+BEGIN;
+
+ALTER TABLE admin_chat_actions
+    ADD COLUMN message_id BIGINT REFERENCES admin_chat_messages(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_admin_chat_actions_message
+    ON admin_chat_actions (message_id);
+
+COMMENT ON COLUMN admin_chat_actions.message_id IS 'Turno model del asistente cuyo tool call propuso la acción';
+
+COMMIT;
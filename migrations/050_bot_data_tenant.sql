@@ -0,0 +1,46 @@
+-- Migration 050: Memoria y analítica del bot por tenant
+-- Desde que WhatsApp y Telegram atienden a cada taller con sus credenciales,
+-- whatsapp_conversations trae turnos de varios tenants. La memoria de largo
+-- plazo y los rollups de analítica se calculaban sobre todo el archivo: un
+-- mismo número que le escribe a dos talleres compartía memoria, y las
+-- estadísticas de todos quedaban en el tenant 1.
+--
+-- customer_memories, bot_events y los rollups pasan a tener tenant_id (las
+-- filas existentes son del tenant 1). Los jobs recorren los tenants activos y
+-- filtran whatsapp_conversations por tenant_id. Handoffs y leads siguen siendo
+-- solo del tenant 1 (ver migración 045).
+
+BEGIN;
+
+ALTER TABLE customer_memories
+    ADD COLUMN tenant_id INTEGER NOT NULL DEFAULT 1 REFERENCES tenants(id);
+ALTER TABLE customer_memories DROP CONSTRAINT customer_memories_conversation_key_key;
+ALTER TABLE customer_memories
+    ADD CONSTRAINT customer_memories_tenant_key UNIQUE (tenant_id, conversation_key);
+
+ALTER TABLE bot_events
+    ADD COLUMN tenant_id INTEGER NOT NULL DEFAULT 1 REFERENCES tenants(id);
+DROP INDEX IF EXISTS idx_bot_events_created;
+CREATE INDEX idx_bot_events_tenant_created ON bot_events (tenant_id, created_at);
+
+ALTER TABLE conversation_stats_daily
+    ADD COLUMN tenant_id INTEGER NOT NULL DEFAULT 1 REFERENCES tenants(id);
+ALTER TABLE conversation_stats_daily DROP CONSTRAINT conversation_stats_daily_pkey;
+ALTER TABLE conversation_stats_daily ADD PRIMARY KEY (tenant_id, day, channel);
+
+ALTER TABLE conversation_tool_daily
+    ADD COLUMN tenant_id INTEGER NOT NULL DEFAULT 1 REFERENCES tenants(id);
+ALTER TABLE conversation_tool_daily DROP CONSTRAINT conversation_tool_daily_pkey;
+ALTER TABLE conversation_tool_daily ADD PRIMARY KEY (tenant_id, day, channel, tool);
+
+ALTER TABLE conversation_hourly
+    ADD COLUMN tenant_id INTEGER NOT NULL DEFAULT 1 REFERENCES tenants(id);
+ALTER TABLE conversation_hourly DROP CONSTRAINT conversation_hourly_pkey;
+ALTER TABLE conversation_hourly ADD PRIMARY KEY (tenant_id, day, channel, hour);
+
+ALTER TABLE conversation_topics_daily
+    ADD COLUMN tenant_id INTEGER NOT NULL DEFAULT 1 REFERENCES tenants(id);
+ALTER TABLE conversation_topics_daily DROP CONSTRAINT conversation_topics_daily_pkey;
+ALTER TABLE conversation_topics_daily ADD PRIMARY KEY (tenant_id, day, channel, kind, ref_id);
+
+COMMIT;
@@ -0,0 +1,18 @@
+-- Migration 051: Bitácora de avisos por tenant
+-- Los eventos de cotizaciones y pedidos llegan con el tenant del taller, pero
+-- notification_deliveries no lo guardaba: los reintentos y la bitácora del
+-- panel corrían siempre como tenant 1 y los avisos de un taller asociado no
+-- encontraban a su cliente.
+--
+-- notification_deliveries pasa a tener tenant_id (las filas existentes son del
+-- tenant 1). El job de reintentos toma los pendientes de todos los tenants y
+-- envía cada uno con el tenant de su fila.
+
+BEGIN;
+
+ALTER TABLE notification_deliveries
+    ADD COLUMN tenant_id INTEGER NOT NULL DEFAULT 1 REFERENCES tenants(id);
+CREATE INDEX idx_notification_deliveries_tenant
+    ON notification_deliveries (tenant_id, created_at DESC);
+
+COMMIT;
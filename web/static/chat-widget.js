@@ -280,7 +280,32 @@
     var headers = { 'Content-Type': 'application/json' };
     if (token) headers['Authorization'] = 'Bearer ' + token;
 
-    fetch('/api/v1/chat/', {
+    // Respuesta por SSE (/api/v1/chat/stream): el texto se pinta a medida que
+    // llega; el evento done trae la respuesta completa.
+    var streamDiv = null;
+    var streamed = '';
+    var finalText = null;
+    var failed = false;
+
+    function onEvent(event, data) {
+      if (event === 'text') {
+        if (!streamDiv) {
+          hideTyping();
+          streamDiv = document.createElement('div');
+          streamDiv.className = 'fl-msg fl-msg-assistant';
+          messagesDiv.appendChild(streamDiv);
+        }
+        streamed += data.text;
+        streamDiv.innerHTML = formatMarkdown(streamed);
+        messagesDiv.scrollTop = messagesDiv.scrollHeight;
+      } else if (event === 'done') {
+        finalText = data.response;
+      } else if (event === 'error') {
+        failed = true;
+      }
+    }
+
+    fetch('/api/v1/chat/stream', {
       method: 'POST',
       headers: headers,
       body: JSON.stringify({
@@ -288,28 +313,55 @@
         history: chatHistory.slice(-10)
       })
     })
-    .then(function(res) { return res.json(); })
-    .then(function(data) {
+    .then(function(res) {
+      if (!res.ok || !res.body) throw new Error('stream');
+      var reader = res.body.getReader();
+      var decoder = new TextDecoder();
+      var buffer = '';
+      function pump() {
+        return reader.read().then(function(r) {
+          if (r.done) return;
+          buffer += decoder.decode(r.value, { stream: true });
+          var sep;
+          while ((sep = buffer.indexOf('\n\n')) >= 0) {
+            var block = buffer.slice(0, sep);
+            buffer = buffer.slice(sep + 2);
+            var event = 'message';
+            var data = '';
+            block.split('\n').forEach(function(line) {
+              if (line.indexOf('event: ') === 0) event = line.slice(7);
+              else if (line.indexOf('data: ') === 0) data += line.slice(6);
+            });
+            if (data) onEvent(event, JSON.parse(data));
+          }
+          return pump();
+        });
+      }
+      return pump();
+    })
+    .then(function() {
       hideTyping();
-      if (data.error) {
+      if (streamDiv) streamDiv.remove();
+      if (failed || finalText === null) {
         appendMessage('assistant', 'Tuve un problema, intentá de nuevo.');
-      } else {
-        appendMessage('assistant', data.response);
-        // Agregar ambos turnos al historial una vez que el intercambio está completo
-        chatHistory.push({ role: 'user', content: text });
-        chatHistory.push({ role: 'assistant', content: data.response });
-        // Detectar primera aparición de links de mensajería
-        if (!waLinkShown && (data.response.indexOf('wa.me') !== -1 || data.response.indexOf('t.me') !== -1)) {
-          waLinkShown = true;
-        }
-        // Refrescar resumen después de cada turno si el link ya fue mostrado
-        if (waLinkShown) {
-          prefetchWaSummary();
-        }
+        return;
+      }
+      appendMessage('assistant', finalText);
+      // Agregar ambos turnos al historial una vez que el intercambio está completo
+      chatHistory.push({ role: 'user', content: text });
+      chatHistory.push({ role: 'assistant', content: finalText });
+      // Detectar primera aparición de links de mensajería
+      if (!waLinkShown && (finalText.indexOf('wa.me') !== -1 || finalText.indexOf('t.me') !== -1)) {
+        waLinkShown = true;
+      }
+      // Refrescar resumen después de cada turno si el link ya fue mostrado
+      if (waLinkShown) {
+        prefetchWaSummary();
       }
     })
     .catch(function() {
       hideTyping();
+      if (streamDiv) streamDiv.remove();
       appendMessage('assistant', 'No se pudo conectar. Intentá de nuevo en un momento.');
     })
     .finally(function() {